@@ -11,6 +11,7 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -211,6 +212,7 @@ func processFlags() {
 		{flag: "opentsdbListenAddr", value: testOpenTSDBListenAddr},
 		{flag: "loggerLevel", value: testLogLevel},
 		{flag: "opentsdbHTTPListenAddr", value: testOpenTSDBHTTPListenAddr},
+		{flag: "storage.trackMetricNamesStats", value: "true"},
 	} {
 		// panics if flag doesn't exist
 		if err := flag.Lookup(fv.flag).Value.Set(fv.value); err != nil {
@@ -548,6 +550,52 @@ func removeIfFoundSeries(r map[string]string, contains []map[string]string) []ma
 	return contains
 }
 
+func TestMetricNamesStats(t *testing.T) {
+	const metricName = "metric_names_stats_test"
+	ts := time.Now().Add(-time.Minute).UnixMilli()
+	line := fmt.Sprintf(`{"metric":{"__name__":%q,"instance":"foo"},"values":[1],"timestamps":[%d]}`, metricName, ts)
+	httpWrite(t, testReadHTTPPath, "/api/v1/import", bytes.NewBufferString(line))
+	vmstorage.Storage.DebugFlush()
+
+	type metricNamesStats struct {
+		Status  string `json:"status"`
+		Records []struct {
+			MetricName         string `json:"metricName"`
+			QueryRequestsCount uint64 `json:"queryRequestsCount"`
+		} `json:"records"`
+	}
+	f := func(query string, queryRequestsCountExpected uint64) {
+		t.Helper()
+
+		_ = httpReadData(t, testReadHTTPPath, query)
+
+		var stats metricNamesStats
+		httpReadStruct(t, testReadHTTPPath, "/api/v1/status/metric_names_stats?match_pattern="+metricName, &stats)
+		if stats.Status != "success" {
+			t.Fatalf("unexpected status; got %q; want %q", stats.Status, "success")
+		}
+		if len(stats.Records) != 1 || stats.Records[0].MetricName != metricName {
+			t.Fatalf("unexpected records for %q: %+v", metricName, stats.Records)
+		}
+		if n := stats.Records[0].QueryRequestsCount; n != queryRequestsCountExpected {
+			t.Fatalf("unexpected queryRequestsCount after %s; got %d; want %d", query, n, queryRequestsCountExpected)
+		}
+	}
+
+	queryTime := ts/1e3 + 1
+	start := queryTime - 300
+	end := queryTime + 300
+
+	// Export and federation requests mustn't be counted.
+	f(fmt.Sprintf("/api/v1/export?match[]=%s&start=%d&end=%d", metricName, start, end), 0)
+	f(fmt.Sprintf("/federate?match[]=%s", metricName), 0)
+
+	// Query requests must be counted.
+	f(fmt.Sprintf("/api/v1/query?query=%s&time=%d&nocache=1", metricName, queryTime), 1)
+	f(fmt.Sprintf("/api/v1/query_range?query=rate(%s[5m])&start=%d&end=%d&step=60&nocache=1", metricName, start, end), 2)
+	f(fmt.Sprintf("/api/v1/query?query=%s[5m]&time=%d", metricName, queryTime), 3)
+}
+
 func TestImportJSONLines(t *testing.T) {
 	f := func(labelsCount, labelLen int) {
 		t.Helper()
@@ -617,3 +665,82 @@ func generateSizedRandomString(size int) string {
 	}
 	return string(dst)
 }
+
+func TestQueryRangeStream(t *testing.T) {
+	ts := insertionTime.Add(-5 * time.Minute).Truncate(time.Minute)
+	tsMsecs := ts.UnixMilli()
+	var lines []string
+	for _, instance := range []string{"a", "b", "c"} {
+		lines = append(lines, fmt.Sprintf(`stream_test_metric{instance=%q} 1 %d`, instance, tsMsecs))
+		lines = append(lines, fmt.Sprintf(`stream_test_metric{instance=%q} 100 %d`, instance, tsMsecs+30_000))
+	}
+	lines = append(lines, fmt.Sprintf(`stream_test_other{instance="a"} 1 %d`, tsMsecs))
+	httpWrite(t, "http://127.0.0.1"+testHTTPListenAddr+"/api/v1/import/prometheus", "", bytes.NewBufferString(strings.Join(lines, "\n")))
+	vmstorage.Storage.DebugFlush()
+
+	type response struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			Result []json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	f := func(query string, maxResponseSeries, statusCodeExpected int, statusExpected string, seriesCountExpected int) {
+		t.Helper()
+
+		if err := flag.Set("search.maxResponseSeries", strconv.Itoa(maxResponseSeries)); err != nil {
+			t.Fatalf("cannot set -search.maxResponseSeries: %s", err)
+		}
+		defer func() {
+			_ = flag.Set("search.maxResponseSeries", "0")
+		}()
+
+		requestURL := fmt.Sprintf("%s/api/v1/query_range?query=%s&start=%d&end=%d&step=1m&stream=1&nocache=1",
+			testReadHTTPPath, url.QueryEscape(query), ts.Unix(), ts.Add(2*time.Minute).Unix())
+		resp, err := http.Get(requestURL)
+		if err != nil {
+			t.Fatalf("cannot send request to %s: %s", requestURL, err)
+		}
+		data, err := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			t.Fatalf("cannot read response from %s: %s", requestURL, err)
+		}
+		if resp.StatusCode != statusCodeExpected {
+			t.Fatalf("unexpected status code for %s; got %d; want %d; response body: %s", query, resp.StatusCode, statusCodeExpected, data)
+		}
+		var r response
+		if err := json.Unmarshal(data, &r); err != nil {
+			t.Fatalf("cannot parse response for %s: %s; response body: %s", query, err, data)
+		}
+		if r.Status != statusExpected {
+			t.Fatalf("unexpected status for %s; got %q; want %q; response body: %s", query, r.Status, statusExpected, data)
+		}
+		if statusExpected == "error" && r.Error == "" {
+			t.Fatalf("missing error message for %s; response body: %s", query, data)
+		}
+		if len(r.Data.Result) != seriesCountExpected {
+			t.Fatalf("unexpected number of series for %s; got %d; want %d; response body: %s", query, len(r.Data.Result), seriesCountExpected, data)
+		}
+	}
+
+	// successful streaming
+	f(`stream_test_metric`, 0, 200, "success", 3)
+	f(`max_over_time(stream_test_metric[5m])`, 3, 200, "success", 3)
+
+	// non-streamable query
+	f(`sum(stream_test_metric)`, 0, 200, "success", 1)
+
+	// invalid query
+	f(`rate(stream_test_metric`, 0, 422, "error", 0)
+
+	// -search.maxResponseSeries is exceeded, so the error must be returned before sending the response
+	f(`stream_test_metric`, 2, 422, "error", 0)
+
+	// -search.maxResponseSeries is exceeded after sending the first series, so the response must contain the error
+	// in the end of the valid JSON
+	f(`histogram_over_time(stream_test_metric{instance="a"}[5m])`, 1, 200, "error", 1)
+
+	// duplicate output series are detected after sending the first series
+	f(`count_over_time({__name__=~"stream_test_metric|stream_test_other",instance="a"}[5m])`, 0, 200, "error", 1)
+}
@@ -0,0 +1,341 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/graphite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/influx"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/prometheusimport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/promremotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/vmimport"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/kafka"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var (
+	topics = flagutil.NewArrayString("kafka.consumer.topic", "Kafka topic names for data consumption. "+
+		"See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicBrokers = flagutil.NewArrayString("kafka.consumer.topic.brokers", "List of brokers to connect for the corresponding -kafka.consumer.topic, "+
+		"e.g. -kafka.consumer.topic.brokers='host-1:9092;host-2:9092' . See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicGroupIDs = flagutil.NewArrayString("kafka.consumer.topic.groupID", "Consumer group for the corresponding -kafka.consumer.topic. "+
+		"vmagent instances with the same group share topic partitions among themselves. Offsets for the processed messages are committed to this group, "+
+		"so vmagent continues reading the topic from the committed offsets after restart. Every vmagent instance reads all the partitions of the topic "+
+		"and offsets aren't committed if the group isn't set. See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicFormats = flagutil.NewArrayString("kafka.consumer.topic.format", "Data format for the corresponding -kafka.consumer.topic. "+
+		"Valid formats: promremotewrite, influx, prometheus, graphite, jsonline . See also -kafka.consumer.topic.defaultFormat and "+
+		"https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	defaultFormat = flag.String("kafka.consumer.topic.defaultFormat", "promremotewrite", "Expected data format in the topic if -kafka.consumer.topic.format is skipped. "+
+		"See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicConcurrency = flagutil.NewArrayInt("kafka.consumer.topic.concurrency", 1, "The number of concurrently processed messages for the corresponding -kafka.consumer.topic. "+
+		"See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicIsGzipped = flagutil.NewArrayBool("kafka.consumer.topic.isGzipped", "Whether messages in the corresponding -kafka.consumer.topic are gzipped. "+
+		"Only prometheus, jsonline, graphite and influx formats accept gzipped messages. See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicOptions = flagutil.NewArrayString("kafka.consumer.topic.options", "Optional key=value;key1=value2 settings for the corresponding -kafka.consumer.topic. "+
+		"Options are named in the same way as at https://github.com/edenhill/librdkafka/blob/master/CONFIGURATION.md , "+
+		"e.g. -kafka.consumer.topic.options='security.protocol=SASL_SSL;sasl.mechanisms=PLAIN;auto.offset.reset=earliest' . "+
+		"See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicBasicAuthUsername = flagutil.NewArrayString("kafka.consumer.topic.basicAuth.username", "Optional basic auth username for the corresponding -kafka.consumer.topic. "+
+		"Must be used in conjunction with any supported auth methods for kafka client, specified by flag "+
+		"-kafka.consumer.topic.options='security.protocol=SASL_SSL;sasl.mechanisms=PLAIN' . See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+	topicBasicAuthPassword = flagutil.NewArrayString("kafka.consumer.topic.basicAuth.password", "Optional basic auth password for the corresponding -kafka.consumer.topic. "+
+		"Must be used in conjunction with any supported auth methods for kafka client, specified by flag "+
+		"-kafka.consumer.topic.options='security.protocol=SASL_SSL;sasl.mechanisms=PLAIN' . See https://docs.victoriametrics.com/vmagent/#reading-metrics-from-kafka")
+)
+
+func init() {
+	// -kafka.consumer.topic.options may contain sasl.password, so hide it at `/metrics` output.
+	flagutil.RegisterSecretFlag("kafka.consumer.topic.options")
+}
+
+const (
+	// fetchMaxWait is the maximum duration to wait for new messages in a single fetch request.
+	fetchMaxWait = time.Second
+
+	// retryInterval is the interval between attempts to read messages from Kafka or to push them to remote storage after errors.
+	retryInterval = time.Second
+)
+
+var (
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+)
+
+// Init starts reading data from -kafka.consumer.topic topics.
+//
+// Stop must be called for stopping the consumers.
+func Init() {
+	if len(*topics) == 0 {
+		return
+	}
+	var tcs []*topicConsumer
+	for i, topic := range *topics {
+		format := topicFormats.GetOptionalArg(i)
+		if format == "" {
+			format = *defaultFormat
+		}
+		tc, err := newTopicConsumer(topic, format, topicConcurrency.GetOptionalArg(i), topicIsGzipped.GetOptionalArg(i))
+		if err != nil {
+			logger.Fatalf("cannot initialize consumer for -kafka.consumer.topic=%q: %s", topic, err)
+		}
+		cfg, err := getConsumerConfig(i)
+		if err != nil {
+			logger.Fatalf("invalid config for -kafka.consumer.topic=%q: %s", topic, err)
+		}
+		c, err := kafka.NewConsumer(cfg, topic, topicGroupIDs.GetOptionalArg(i))
+		if err != nil {
+			logger.Fatalf("cannot initialize consumer for -kafka.consumer.topic=%q: %s", topic, err)
+		}
+		tc.c = c
+		tcs = append(tcs, tc)
+	}
+
+	stopCh = make(chan struct{})
+	for _, tc := range tcs {
+		wg.Add(1)
+		go func(tc *topicConsumer) {
+			defer wg.Done()
+			tc.run(stopCh)
+		}(tc)
+	}
+	logger.Infof("started reading data from Kafka topics %q", *topics)
+}
+
+// Stop stops reading data from Kafka topics.
+func Stop() {
+	if stopCh == nil {
+		return
+	}
+	close(stopCh)
+	wg.Wait()
+	stopCh = nil
+	logger.Infof("stopped reading data from Kafka topics")
+}
+
+// getConsumerConfig returns Kafka config for -kafka.consumer.topic at the given argIdx.
+func getConsumerConfig(argIdx int) (*kafka.Config, error) {
+	cfg := &kafka.Config{
+		Brokers:  parseBrokers(topicBrokers.GetOptionalArg(argIdx)),
+		ClientID: "vmagent",
+	}
+	if err := cfg.SetOptions(topicOptions.GetOptionalArg(argIdx)); err != nil {
+		return nil, fmt.Errorf("cannot parse -kafka.consumer.topic.options: %w", err)
+	}
+	if username := topicBasicAuthUsername.GetOptionalArg(argIdx); username != "" {
+		cfg.SASLUsername = username
+		cfg.SASLPassword = topicBasicAuthPassword.GetOptionalArg(argIdx)
+	}
+	return cfg, nil
+}
+
+func parseBrokers(s string) []string {
+	var brokers []string
+	for _, broker := range strings.Split(s, ";") {
+		broker = strings.TrimSpace(broker)
+		if broker != "" {
+			brokers = append(brokers, broker)
+		}
+	}
+	return brokers
+}
+
+var errorLogger = logger.WithThrottler("kafkaConsumer", 5*time.Second)
+
+// messageSource reads messages from Kafka topic.
+//
+// It is implemented by kafka.Consumer.
+type messageSource interface {
+	Fetch(maxWait time.Duration) ([]kafka.Message, error)
+	Commit() error
+	MustClose()
+}
+
+// topicConsumer reads messages from a Kafka topic and pushes the data from these messages to remote storage.
+type topicConsumer struct {
+	c           messageSource
+	topic       string
+	format      string
+	concurrency int
+	isGzipped   bool
+
+	messagesRead *metrics.Counter
+	bytesRead    *metrics.Counter
+	fetchErrors  *metrics.Counter
+	parseErrors  *metrics.Counter
+	commitErrors *metrics.Counter
+}
+
+// newTopicConsumer returns a consumer for the given topic. The caller must set messageSource to tc.c before calling tc.run.
+func newTopicConsumer(topic, format string, concurrency int, isGzipped bool) (*topicConsumer, error) {
+	switch format {
+	case "promremotewrite", "influx", "prometheus", "graphite", "jsonline":
+	default:
+		return nil, fmt.Errorf("unsupported format %q; supported formats: promremotewrite, influx, prometheus, graphite, jsonline", format)
+	}
+	if isGzipped && format == "promremotewrite" {
+		return nil, fmt.Errorf("gzipped messages aren't supported for promremotewrite format")
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	tc := &topicConsumer{
+		topic:       topic,
+		format:      format,
+		concurrency: concurrency,
+		isGzipped:   isGzipped,
+
+		messagesRead: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_kafka_consumer_messages_read_total{topic=%q}`, topic)),
+		bytesRead:    metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_kafka_consumer_bytes_read_total{topic=%q}`, topic)),
+		fetchErrors:  metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_kafka_consumer_errors_total{topic=%q, type="fetch"}`, topic)),
+		parseErrors:  metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_kafka_consumer_errors_total{topic=%q, type="parse"}`, topic)),
+		commitErrors: metrics.GetOrCreateCounter(fmt.Sprintf(`vmagent_kafka_consumer_errors_total{topic=%q, type="commit"}`, topic)),
+	}
+	return tc, nil
+}
+
+// run reads messages from tc.topic until stopCh is closed.
+//
+// Offsets are committed only after all the fetched messages are pushed to remote storage,
+// so messages may be read again after vmagent restart, but they cannot be lost.
+func (tc *topicConsumer) run(stopCh <-chan struct{}) {
+	defer tc.c.MustClose()
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+		msgs, err := tc.c.Fetch(fetchMaxWait)
+		if err != nil {
+			tc.fetchErrors.Inc()
+			errorLogger.Warnf("cannot read messages from -kafka.consumer.topic=%q: %s", tc.topic, err)
+			if len(msgs) == 0 {
+				if !sleep(retryInterval, stopCh) {
+					return
+				}
+				continue
+			}
+		}
+		if !tc.processMessages(msgs, stopCh) {
+			// Do not commit offsets for partially processed messages, so they are read again after restart.
+			return
+		}
+		if err := tc.c.Commit(); err != nil {
+			tc.commitErrors.Inc()
+			errorLogger.Warnf("cannot commit offsets for -kafka.consumer.topic=%q: %s", tc.topic, err)
+		}
+	}
+}
+
+// processMessages pushes data from msgs to remote storage.
+//
+// It returns false if stopCh is closed before all the msgs are processed.
+func (tc *topicConsumer) processMessages(msgs []kafka.Message, stopCh <-chan struct{}) bool {
+	if len(msgs) == 0 {
+		return true
+	}
+	workCh := make(chan *kafka.Message, len(msgs))
+	for i := range msgs {
+		workCh <- &msgs[i]
+	}
+	close(workCh)
+
+	var stopped atomic.Bool
+	var wg sync.WaitGroup
+	concurrency := tc.concurrency
+	if concurrency > len(msgs) {
+		concurrency = len(msgs)
+	}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range workCh {
+				if !tc.processMessage(m, stopCh) {
+					stopped.Store(true)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return !stopped.Load()
+}
+
+// processMessage pushes data from m to remote storage.
+//
+// It returns false if stopCh is closed before the data is pushed.
+func (tc *topicConsumer) processMessage(m *kafka.Message, stopCh <-chan struct{}) bool {
+	tc.messagesRead.Inc()
+	tc.bytesRead.Add(len(m.Value))
+	for {
+		err := tc.insertMessage(m)
+		if err == nil {
+			return true
+		}
+		if !errors.Is(err, remotewrite.ErrQueueFullHTTPRetry) {
+			tc.parseErrors.Inc()
+			errorLogger.Warnf("cannot process message at offset %d in partition %d of -kafka.consumer.topic=%q; skipping it: %s", m.Offset, m.Partition, tc.topic, err)
+			return true
+		}
+		// Remote storage cannot keep up with the data ingestion rate. Retry pushing the message later.
+		if !sleep(retryInterval, stopCh) {
+			return false
+		}
+	}
+}
+
+func (tc *topicConsumer) insertMessage(m *kafka.Message) error {
+	r := bytes.NewReader(m.Value)
+	switch tc.format {
+	case "promremotewrite":
+		// Messages written by vmagent contain Content-Type and Content-Encoding headers. Other messages are expected to be snappy-encoded.
+		// The parser falls back to another encoding if the message cannot be decoded,
+		// and it detects the protocol version by the message contents if Content-Type is missing.
+		// Messages with unsupported protobuf message in Content-Type are rejected by the parser.
+		isVMRemoteWrite := string(m.GetHeader("Content-Encoding")) == "zstd"
+		contentType := string(m.GetHeader("Content-Type"))
+		return promremotewrite.InsertHandlerForReader(nil, r, isVMRemoteWrite, contentType)
+	case "influx":
+		return influx.InsertHandlerForReader(nil, r, tc.isGzipped)
+	case "prometheus":
+		return prometheusimport.InsertHandlerForReader(nil, r, tc.isGzipped)
+	case "jsonline":
+		return vmimport.InsertHandlerForReader(nil, r, tc.isGzipped)
+	case "graphite":
+		if !tc.isGzipped {
+			return graphite.InsertHandler(r)
+		}
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped graphite data: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		return graphite.InsertHandler(zr)
+	default:
+		logger.Panicf("BUG: unexpected format %q", tc.format)
+		return nil
+	}
+}
+
+// sleep sleeps for d. It returns false if stopCh is closed during the sleep.
+func sleep(d time.Duration, stopCh <-chan struct{}) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-stopCh:
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,228 @@
+package kafka
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/kafka"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
+)
+
+func TestParseBrokers(t *testing.T) {
+	f := func(s string, resultExpected []string) {
+		t.Helper()
+
+		result := parseBrokers(s)
+		if len(result) != len(resultExpected) {
+			t.Fatalf("unexpected brokers; got %q; want %q", result, resultExpected)
+		}
+		for i := range result {
+			if result[i] != resultExpected[i] {
+				t.Fatalf("unexpected brokers; got %q; want %q", result, resultExpected)
+			}
+		}
+	}
+
+	f("", nil)
+	f("host:9092", []string{"host:9092"})
+	f("host-1:9092; host-2:9092;", []string{"host-1:9092", "host-2:9092"})
+}
+
+func TestNewTopicConsumerFailure(t *testing.T) {
+	f := func(format string, isGzipped bool) {
+		t.Helper()
+
+		if _, err := newTopicConsumer("topic", format, 1, isGzipped); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// unsupported format
+	f("json", false)
+
+	// gzipped promremotewrite
+	f("promremotewrite", true)
+}
+
+// testMessageSource returns messages in batches and registers committed offsets.
+type testMessageSource struct {
+	mu        sync.Mutex
+	batches   [][]kafka.Message
+	committed map[int32]int64
+	pending   []kafka.Message
+}
+
+func (s *testMessageSource) Fetch(maxWait time.Duration) ([]kafka.Message, error) {
+	s.mu.Lock()
+	if len(s.batches) == 0 {
+		s.mu.Unlock()
+		time.Sleep(maxWait / 100)
+		return nil, nil
+	}
+	msgs := s.batches[0]
+	s.batches = s.batches[1:]
+	s.pending = msgs
+	s.mu.Unlock()
+	return msgs, nil
+}
+
+func (s *testMessageSource) Commit() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.pending {
+		s.committed[m.Partition] = m.Offset + 1
+	}
+	s.pending = nil
+	return nil
+}
+
+func (s *testMessageSource) MustClose() {}
+
+func (s *testMessageSource) committedOffset(partition int32) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.committed[partition]
+}
+
+func TestTopicConsumer(t *testing.T) {
+	var bytesReceived atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > 0 {
+			bytesReceived.Add(r.ContentLength)
+		}
+		w.WriteHeader(204)
+	}))
+	defer srv.Close()
+	flag.Parse()
+	remoteWriteFlag := "remoteWrite.url"
+	if err := flag.Lookup(remoteWriteFlag).Value.Set(srv.URL); err != nil {
+		log.Fatalf("unable to set %q with value %q, err: %v", remoteWriteFlag, srv.URL, err)
+	}
+	logger.Init()
+	common.StartUnmarshalWorkers()
+	remotewrite.Init()
+	defer func() {
+		common.StopUnmarshalWorkers()
+		remotewrite.Stop()
+		tmpDataDir := flag.Lookup("remoteWrite.tmpDataPath").Value.String()
+		fs.MustRemoveAll(tmpDataDir)
+	}()
+
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{{
+			Labels: []prompbmarshal.Label{{
+				Name:  "__name__",
+				Value: "foo",
+			}},
+			Samples: []prompbmarshal.Sample{{
+				Value:     1,
+				Timestamp: time.Now().UnixMilli(),
+			}},
+		}},
+	}
+	srcPromRW := &testMessageSource{
+		batches: [][]kafka.Message{{
+			{
+				Partition: 0,
+				Value:     snappy.Encode(nil, wr.MarshalProtobuf(nil)),
+				Headers: []kafka.Header{{
+					Key:   "Content-Encoding",
+					Value: []byte("snappy"),
+				}},
+			},
+			{
+				Partition: 0,
+				Offset:    1,
+				Value:     snappy.Encode(nil, wr.MarshalProtobufV2(nil)),
+				Headers: []kafka.Header{
+					{
+						Key:   "Content-Type",
+						Value: []byte(stream.RemoteWriteV2ContentType),
+					},
+					{
+						Key:   "Content-Encoding",
+						Value: []byte("snappy"),
+					},
+				},
+			},
+			{
+				Partition: 1,
+				Value:     []byte("invalid message"),
+			},
+			{
+				Partition: 1,
+				Offset:    1,
+				Value:     snappy.Encode(nil, wr.MarshalProtobufV2(nil)),
+				Headers: []kafka.Header{{
+					Key:   "Content-Type",
+					Value: []byte("application/x-protobuf;proto=io.prometheus.write.v3.Request"),
+				}},
+			},
+		}},
+		committed: make(map[int32]int64),
+	}
+	srcText := &testMessageSource{
+		batches: [][]kafka.Message{{
+			{
+				Partition: 0,
+				Value:     []byte("cpu,host=a usage=10\ncpu,host=b usage=20"),
+			},
+		}},
+		committed: make(map[int32]int64),
+	}
+
+	tcPromRW, err := newTopicConsumer("prom-rw", "promremotewrite", 2, false)
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	tcPromRW.c = srcPromRW
+	tcInflux, err := newTopicConsumer("influx", "influx", 1, false)
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	tcInflux.c = srcText
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{}, 2)
+	for _, tc := range []*topicConsumer{tcPromRW, tcInflux} {
+		go func(tc *topicConsumer) {
+			tc.run(stopCh)
+			doneCh <- struct{}{}
+		}(tc)
+	}
+
+	// All the messages must be consumed, including the invalid one, so offsets for them must be committed.
+	deadline := time.Now().Add(10 * time.Second)
+	for srcPromRW.committedOffset(0) != 2 || srcPromRW.committedOffset(1) != 2 || srcText.committedOffset(0) != 1 || bytesReceived.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for messages to be consumed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stopCh)
+	<-doneCh
+	<-doneCh
+
+	if n := tcPromRW.messagesRead.Get(); n != 4 {
+		t.Fatalf("unexpected number of messages read from prom-rw topic; got %d; want 4", n)
+	}
+	// The invalid message and the message with unsupported protobuf message in Content-Type must be counted as parse errors.
+	if n := tcPromRW.parseErrors.Get(); n != 2 {
+		t.Fatalf("unexpected number of parse errors for prom-rw topic; got %d; want 2", n)
+	}
+	if n := tcInflux.parseErrors.Get(); n != 0 {
+		t.Fatalf("unexpected number of parse errors for influx topic; got %d; want 0", n)
+	}
+}
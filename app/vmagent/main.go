@@ -18,6 +18,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/datadogv2"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/graphite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/influx"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/kafka"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/native"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/newrelic"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/opentelemetry"
@@ -148,6 +149,7 @@ func main() {
 		opentsdbhttpServer = opentsdbhttpserver.MustStart(*opentsdbHTTPListenAddr, *opentsdbHTTPUseProxyProtocol, httpInsertHandler)
 	}
 
+	kafka.Init()
 	promscrape.Init(remotewrite.PushDropSamplesOnFailure)
 
 	go httpserver.Serve(listenAddrs, useProxyProtocol, requestHandler)
@@ -180,6 +182,7 @@ func main() {
 	if len(*opentsdbHTTPListenAddr) > 0 {
 		opentsdbhttpServer.MustStop()
 	}
+	kafka.Stop()
 	common.StopUnmarshalWorkers()
 	remotewrite.Stop()
 
@@ -271,7 +274,7 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(nil, r); err != nil {
+		if err := promremotewrite.InsertHandler(nil, w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -439,6 +442,14 @@ func requestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/prometheus/target_history", "/target_history":
+		promscrapeTargetHistoryRequests.Inc()
+		if err := promscrape.WriteTargetHistory(w, r); err != nil {
+			promscrapeTargetHistoryErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/prometheus/config", "/config":
 		if !httpserver.CheckAuthFlag(w, r, configAuthKey) {
 			return true
@@ -521,7 +532,7 @@ func processMultitenantRequest(w http.ResponseWriter, r *http.Request, path stri
 	switch p.Suffix {
 	case "prometheus/", "prometheus", "prometheus/api/v1/write", "prometheus/api/v1/push":
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(at, r); err != nil {
+		if err := promremotewrite.InsertHandler(at, w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -718,6 +729,8 @@ var (
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vmagent_http_request_errors_total{path="/target_response"}`)
+	promscrapeTargetHistoryRequests  = metrics.NewCounter(`vmagent_http_requests_total{path="/target_history"}`)
+	promscrapeTargetHistoryErrors    = metrics.NewCounter(`vmagent_http_request_errors_total{path="/target_history"}`)
 
 	promscrapeConfigRequests       = metrics.NewCounter(`vmagent_http_requests_total{path="/config"}`)
 	promscrapeStatusConfigRequests = metrics.NewCounter(`vmagent_http_requests_total{path="/api/v1/status/config"}`)
@@ -1,11 +1,13 @@
 package promremotewrite
 
 import (
+	"io"
 	"net/http"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmagent/remotewrite"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/auth"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
@@ -21,15 +23,43 @@ var (
 )
 
 // InsertHandler processes remote write for prometheus.
-func InsertHandler(at *auth.Token, req *http.Request) error {
+//
+// Both Prometheus Remote Write 1.0 and 2.0 requests are accepted. Remote Write 2.0 response headers are set to w for Remote Write 2.0 requests.
+func InsertHandler(at *auth.Token, w http.ResponseWriter, req *http.Request) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
+	contentType := req.Header.Get("Content-Type")
+	isRemoteWriteV2, err := stream.IsRemoteWriteV2(contentType)
+	if err != nil {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
 	isVMRemoteWrite := req.Header.Get("Content-Encoding") == "zstd"
-	return stream.Parse(req.Body, isVMRemoteWrite, func(tss []prompb.TimeSeries) error {
+	ws, err := stream.Parse(req.Body, isVMRemoteWrite, contentType, func(tss []prompb.TimeSeries, _ []prompb.MetricMetadata) error {
 		return insertRows(at, tss, extraLabels)
 	})
+	if err != nil {
+		return err
+	}
+	if isRemoteWriteV2 {
+		ws.SetResponseHeaders(w.Header())
+	}
+	return nil
+}
+
+// InsertHandlerForReader processes Prometheus remote write data from r.
+//
+// isVMRemoteWrite must be set if the data is encoded with VictoriaMetrics remote write protocol.
+// contentType must contain Content-Type of the data. Prometheus Remote Write 2.0 requests are detected automatically if it is empty.
+func InsertHandlerForReader(at *auth.Token, r io.Reader, isVMRemoteWrite bool, contentType string) error {
+	_, err := stream.Parse(r, isVMRemoteWrite, contentType, func(tss []prompb.TimeSeries, _ []prompb.MetricMetadata) error {
+		return insertRows(at, tss, nil)
+	})
+	return err
 }
 
 func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
@@ -40,6 +70,7 @@ func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []pr
 	tssDst := ctx.WriteRequest.Timeseries[:0]
 	labels := ctx.Labels[:0]
 	samples := ctx.Samples[:0]
+	exemplars := ctx.Exemplars[:0]
 	for i := range timeseries {
 		ts := &timeseries[i]
 		rowsTotal += len(ts.Samples)
@@ -52,6 +83,7 @@ func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []pr
 			})
 		}
 		labels = append(labels, extraLabels...)
+		labelsEnd := len(labels)
 		samplesLen := len(samples)
 		for i := range ts.Samples {
 			sample := &ts.Samples[i]
@@ -60,14 +92,33 @@ func insertRows(at *auth.Token, timeseries []prompb.TimeSeries, extraLabels []pr
 				Timestamp: sample.Timestamp,
 			})
 		}
+		exemplarsLen := len(exemplars)
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			exemplarLabelsLen := len(labels)
+			for j := range e.Labels {
+				label := &e.Labels[j]
+				labels = append(labels, prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				})
+			}
+			exemplars = append(exemplars, prompbmarshal.Exemplar{
+				Labels:    labels[exemplarLabelsLen:],
+				Value:     e.Value,
+				Timestamp: e.Timestamp,
+			})
+		}
 		tssDst = append(tssDst, prompbmarshal.TimeSeries{
-			Labels:  labels[labelsLen:],
-			Samples: samples[samplesLen:],
+			Labels:    labels[labelsLen:labelsEnd:labelsEnd],
+			Samples:   samples[samplesLen:],
+			Exemplars: exemplars[exemplarsLen:],
 		})
 	}
 	ctx.WriteRequest.Timeseries = tssDst
 	ctx.Labels = labels
 	ctx.Samples = samples
+	ctx.Exemplars = exemplars
 	if !remotewrite.TryPush(at, &ctx.WriteRequest) {
 		return remotewrite.ErrQueueFullHTTPRetry
 	}
@@ -18,7 +18,6 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/netutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
-	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/ratelimiter"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
@@ -86,12 +85,17 @@ type client struct {
 	sanitizedURL   string
 	remoteWriteURL string
 
-	// Whether to use VictoriaMetrics remote write protocol for sending the data to remoteWriteURL
-	useVMProto bool
+	// protocol is the protocol for encoding new blocks sent to remoteWriteURL.
+	//
+	// Blocks, which are already stored in fq, are sent with the protocol recorded in their header.
+	protocol protocol
 
 	fq *persistentqueue.FastQueue
 	hc *http.Client
 
+	// kp is set for kafka:// remoteWriteURL
+	kp kafkaProducer
+
 	retryMinInterval time.Duration
 	retryMaxTime     time.Duration
 
@@ -160,23 +164,7 @@ func newHTTPClient(argIdx int, remoteWriteURL, sanitizedURL string, fq *persiste
 	}
 	c.sendBlock = c.sendBlockHTTP
 
-	useVMProto := forceVMProto.GetOptionalArg(argIdx)
-	usePromProto := forcePromProto.GetOptionalArg(argIdx)
-	if useVMProto && usePromProto {
-		logger.Fatalf("-remoteWrite.useVMProto and -remoteWrite.usePromProto cannot be set simultaneously for -remoteWrite.url=%s", sanitizedURL)
-	}
-	if !useVMProto && !usePromProto {
-		// Auto-detect whether the remote storage supports VictoriaMetrics remote write protocol.
-		doRequest := func(url string) (*http.Response, error) {
-			return c.doRequest(url, nil)
-		}
-		useVMProto = common.HandleVMProtoClientHandshake(c.remoteWriteURL, doRequest)
-		if !useVMProto {
-			logger.Infof("the remote storage at %q doesn't support VictoriaMetrics remote write protocol. Switching to Prometheus remote write protocol. "+
-				"See https://docs.victoriametrics.com/vmagent/#victoriametrics-remote-write-protocol", sanitizedURL)
-		}
-	}
-	c.useVMProto = useVMProto
+	c.protocol = getProtocol(argIdx, sanitizedURL, c.detectHTTPProtocol)
 
 	return c
 }
@@ -214,6 +202,9 @@ func (c *client) init(argIdx, concurrency int, sanitizedURL string) {
 func (c *client) MustStop() {
 	close(c.stopCh)
 	c.wg.Wait()
+	if c.kp != nil {
+		c.kp.MustClose()
+	}
 	logger.Infof("stopped client for -remoteWrite.url=%q", c.sanitizedURL)
 }
 
@@ -344,8 +335,8 @@ func (c *client) runWorker() {
 	}
 }
 
-func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
-	req, err := c.newRequest(url, body)
+func (c *client) doRequest(url string, body []byte, p protocol) (*http.Response, error) {
+	req, err := c.newRequest(url, body, p)
 	if err != nil {
 		return nil, err
 	}
@@ -360,7 +351,7 @@ func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
 	// Make another attempt in hope request will succeed.
 	// If not, the error should be handled by the caller as usual.
 	// This should help with https://github.com/VictoriaMetrics/VictoriaMetrics/issues/4139
-	req, err = c.newRequest(url, body)
+	req, err = c.newRequest(url, body, p)
 	if err != nil {
 		return nil, fmt.Errorf("second attempt: %w", err)
 	}
@@ -371,7 +362,7 @@ func (c *client) doRequest(url string, body []byte) (*http.Response, error) {
 	return resp, nil
 }
 
-func (c *client) newRequest(url string, body []byte) (*http.Request, error) {
+func (c *client) newRequest(url string, body []byte, p protocol) (*http.Request, error) {
 	reqBody := bytes.NewBuffer(body)
 	req, err := http.NewRequest(http.MethodPost, url, reqBody)
 	if err != nil {
@@ -383,14 +374,7 @@ func (c *client) newRequest(url string, body []byte) (*http.Request, error) {
 	}
 	h := req.Header
 	h.Set("User-Agent", "vmagent")
-	h.Set("Content-Type", "application/x-protobuf")
-	if c.useVMProto {
-		h.Set("Content-Encoding", "zstd")
-		h.Set("X-VictoriaMetrics-Remote-Write-Version", "1")
-	} else {
-		h.Set("Content-Encoding", "snappy")
-		h.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
-	}
+	p.setHeaders(h)
 	if c.awsCfg != nil {
 		sigv4Hash := awsapi.HashHex(body)
 		if err := c.awsCfg.SignRequest(req, sigv4Hash); err != nil {
@@ -405,6 +389,12 @@ func (c *client) newRequest(url string, body []byte) (*http.Request, error) {
 // The function returns false only if c.stopCh is closed.
 // Otherwise, it tries sending the block to remote storage indefinitely.
 func (c *client) sendBlockHTTP(block []byte) bool {
+	p, block, err := parseBlock(block)
+	if err != nil {
+		remoteWriteRejectedLogger.Errorf("skipping invalid block read from the persistent queue for %q: %s", c.sanitizedURL, err)
+		c.packetsDropped.Inc()
+		return true
+	}
 	c.rl.Register(len(block))
 	maxRetryDuration := timeutil.AddJitterToDuration(c.retryMaxTime)
 	retryDuration := timeutil.AddJitterToDuration(c.retryMinInterval)
@@ -412,7 +402,7 @@ func (c *client) sendBlockHTTP(block []byte) bool {
 
 again:
 	startTime := time.Now()
-	resp, err := c.doRequest(c.remoteWriteURL, block)
+	resp, err := c.doRequest(c.remoteWriteURL, block, p)
 	c.requestDuration.UpdateDuration(startTime)
 	if err != nil {
 		c.errorsCount.Inc()
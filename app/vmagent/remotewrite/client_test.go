@@ -3,8 +3,11 @@ package remotewrite
 import (
 	"math"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
 )
 
 func TestCalculateRetryDuration(t *testing.T) {
@@ -97,3 +100,65 @@ func helper(d time.Duration) time.Duration {
 
 	return d + dv
 }
+
+func TestRemoteWriteProtocolNegotiation(t *testing.T) {
+	f := func(handler http.HandlerFunc, protocolExpected protocol) {
+		t.Helper()
+
+		srv := httptest.NewServer(handler)
+		defer srv.Close()
+
+		c := newHTTPClient(0, srv.URL, "1:secret-url", nil, 1)
+		if c.protocol != protocolExpected {
+			t.Fatalf("unexpected protocol; got %s; want %s", c.protocol, protocolExpected)
+		}
+	}
+
+	// VictoriaMetrics remote write receiver
+	f(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("get_vm_proto_version") != "" {
+			_, _ = w.Write([]byte("1"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, protocolVM)
+
+	// Remote Write 2.0 receiver must be written via Remote Write 1.0 unless -remoteWrite.protocol=prometheus-v2 is set
+	f(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") == stream.RemoteWriteV2ContentType {
+			t.Errorf("unexpected Remote Write 2.0 request")
+		}
+		w.Header().Set("X-Prometheus-Remote-Write-Samples-Written", "0")
+		w.WriteHeader(http.StatusNoContent)
+	}, protocolPrometheus)
+
+	// Remote Write 1.0 receiver
+	f(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, protocolPrometheus)
+}
+
+func TestClientNewRequestHeaders(t *testing.T) {
+	c := newHTTPClient(0, "http://localhost:1234/api/v1/write", "1:secret-url", nil, 1)
+	c.protocol = protocolPrometheus
+
+	f := func(p protocol, contentTypeExpected, contentEncodingExpected string) {
+		t.Helper()
+
+		req, err := c.newRequest(c.remoteWriteURL, []byte("foo"), p)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != contentTypeExpected {
+			t.Fatalf("unexpected Content-Type for %s; got %q; want %q", p, ct, contentTypeExpected)
+		}
+		if ce := req.Header.Get("Content-Encoding"); ce != contentEncodingExpected {
+			t.Fatalf("unexpected Content-Encoding for %s; got %q; want %q", p, ce, contentEncodingExpected)
+		}
+	}
+
+	// Headers must depend on the protocol of the sent block instead of c.protocol
+	f(protocolPrometheus, "application/x-protobuf", "snappy")
+	f(protocolVM, "application/x-protobuf", "zstd")
+	f(protocolPrometheusV2, stream.RemoteWriteV2ContentType, "snappy")
+}
@@ -0,0 +1,202 @@
+package remotewrite
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/kafka"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timerpool"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// Kafka message headers, which are set in the same way as HTTP headers for remote write requests.
+//
+// This allows determining the protocol for the message at the consumer side.
+var (
+	kafkaPromProtoHeaders = []kafka.Header{
+		{Key: "Content-Type", Value: []byte("application/x-protobuf")},
+		{Key: "Content-Encoding", Value: []byte("snappy")},
+		{Key: "X-Prometheus-Remote-Write-Version", Value: []byte("0.1.0")},
+	}
+	kafkaVMProtoHeaders = []kafka.Header{
+		{Key: "Content-Type", Value: []byte("application/x-protobuf")},
+		{Key: "Content-Encoding", Value: []byte("zstd")},
+		{Key: "X-VictoriaMetrics-Remote-Write-Version", Value: []byte("1")},
+	}
+	kafkaPromProtoV2Headers = []kafka.Header{
+		{Key: "Content-Type", Value: []byte(stream.RemoteWriteV2ContentType)},
+		{Key: "Content-Encoding", Value: []byte("snappy")},
+		{Key: "X-Prometheus-Remote-Write-Version", Value: []byte("2.0.0")},
+	}
+)
+
+func getKafkaHeaders(p protocol) []kafka.Header {
+	switch p {
+	case protocolVM:
+		return kafkaVMProtoHeaders
+	case protocolPrometheusV2:
+		return kafkaPromProtoV2Headers
+	default:
+		return kafkaPromProtoHeaders
+	}
+}
+
+// kafkaProducer writes messages to Kafka topic.
+//
+// It is implemented by kafka.Producer.
+type kafkaProducer interface {
+	Produce(value []byte, headers []kafka.Header) error
+	MustClose()
+}
+
+// newKafkaClient returns a client, which writes data blocks as messages to Kafka topic from remoteWriteURL.
+//
+// See parseKafkaURL for the supported remoteWriteURL format.
+// SASL credentials are taken from -remoteWrite.basicAuth.* flags, while TLS settings are taken from -remoteWrite.tls* flags
+// for the corresponding remoteWriteURL.
+func newKafkaClient(argIdx int, remoteWriteURL *url.URL, sanitizedURL string, fq *persistentqueue.FastQueue) *client {
+	cfg, topic, err := parseKafkaURL(remoteWriteURL)
+	if err != nil {
+		logger.Fatalf("invalid -remoteWrite.url=%q: %s", sanitizedURL, err)
+	}
+	cfg.RequestTimeout = sendTimeout.GetOptionalArg(argIdx)
+	if username := basicAuthUsername.GetOptionalArg(argIdx); username != "" {
+		cfg.SASLUsername = username
+		cfg.SASLPassword = basicAuthPassword.GetOptionalArg(argIdx)
+	}
+	if caFile := tlsCAFile.GetOptionalArg(argIdx); caFile != "" {
+		cfg.TLS.CAFile = caFile
+	}
+	if certFile := tlsCertFile.GetOptionalArg(argIdx); certFile != "" {
+		cfg.TLS.CertFile = certFile
+	}
+	if keyFile := tlsKeyFile.GetOptionalArg(argIdx); keyFile != "" {
+		cfg.TLS.KeyFile = keyFile
+	}
+	if serverName := tlsServerName.GetOptionalArg(argIdx); serverName != "" {
+		cfg.TLS.ServerName = serverName
+	}
+	if tlsInsecureSkipVerify.GetOptionalArg(argIdx) {
+		cfg.TLS.InsecureSkipVerify = true
+	}
+	kp, err := kafka.NewProducer(cfg, topic)
+	if err != nil {
+		logger.Fatalf("cannot initialize Kafka producer for -remoteWrite.url=%q: %s", sanitizedURL, err)
+	}
+	c := &client{
+		sanitizedURL:     sanitizedURL,
+		remoteWriteURL:   remoteWriteURL.String(),
+		fq:               fq,
+		kp:               kp,
+		retryMinInterval: retryMinInterval.GetOptionalArg(argIdx),
+		retryMaxTime:     retryMaxTime.GetOptionalArg(argIdx),
+		stopCh:           make(chan struct{}),
+	}
+	c.sendBlock = c.sendBlockKafka
+
+	// There is no way to negotiate the protocol with Kafka consumers, so Prometheus remote write protocol is used by default,
+	// since it is supported by the majority of consumers.
+	c.protocol = getProtocol(argIdx, sanitizedURL, func() protocol {
+		return protocolPrometheus
+	})
+
+	return c
+}
+
+// parseKafkaURL parses u in the form kafka://broker1:9092,...,brokerN:9092/topic?option1=value1&...&optionN=valueN
+//
+// The topic may be passed via `topic` query arg instead of the path.
+// Other query args are passed to kafka.Config.SetOption, e.g. `security.protocol=SASL_SSL&sasl.mechanisms=PLAIN`.
+func parseKafkaURL(u *url.URL) (*kafka.Config, string, error) {
+	if u.Host == "" {
+		return nil, "", fmt.Errorf("missing Kafka brokers; the url must have the form kafka://broker1:9092,...,brokerN:9092/topic")
+	}
+	brokers := strings.Split(u.Host, ",")
+	for _, broker := range brokers {
+		if broker == "" {
+			return nil, "", fmt.Errorf("Kafka broker address cannot be empty in %q", u.Host)
+		}
+	}
+	cfg := &kafka.Config{
+		Brokers:  brokers,
+		ClientID: "vmagent",
+	}
+	topic := strings.Trim(u.Path, "/")
+	for k, vs := range u.Query() {
+		v := vs[len(vs)-1]
+		if k == "topic" {
+			if topic != "" && topic != v {
+				return nil, "", fmt.Errorf("Kafka topic is set simultaneously in the path (%q) and in `topic` query arg (%q)", topic, v)
+			}
+			topic = v
+			continue
+		}
+		if err := cfg.SetOption(k, v); err != nil {
+			return nil, "", fmt.Errorf("cannot apply query arg %q: %w", k, err)
+		}
+	}
+	if topic == "" {
+		return nil, "", fmt.Errorf("missing Kafka topic; the url must have the form kafka://broker1:9092,...,brokerN:9092/topic")
+	}
+	if strings.Contains(topic, "/") {
+		return nil, "", fmt.Errorf("Kafka topic cannot contain `/`; got %q", topic)
+	}
+	return cfg, topic, nil
+}
+
+// sendBlockKafka sends the given block as a message to Kafka topic.
+//
+// The function returns false only if c.stopCh is closed.
+// Otherwise, it tries sending the block to Kafka indefinitely.
+func (c *client) sendBlockKafka(block []byte) bool {
+	p, block, err := parseBlock(block)
+	if err != nil {
+		remoteWriteRejectedLogger.Errorf("skipping invalid block read from the persistent queue for %q: %s", c.sanitizedURL, err)
+		c.packetsDropped.Inc()
+		return true
+	}
+	c.rl.Register(len(block))
+	maxRetryDuration := timeutil.AddJitterToDuration(c.retryMaxTime)
+	retryDuration := timeutil.AddJitterToDuration(c.retryMinInterval)
+	headers := getKafkaHeaders(p)
+
+again:
+	startTime := time.Now()
+	err = c.kp.Produce(block, headers)
+	c.requestDuration.UpdateDuration(startTime)
+	if err == nil {
+		c.requestsOKCount.Inc()
+		c.bytesSent.Add(len(block))
+		c.blocksSent.Inc()
+		return true
+	}
+	if kafka.IsMessageRejected(err) {
+		// Kafka broker never accepts such a message, so just drop it in the same way as blocks rejected with 400 status code.
+		// Too big messages may be avoided by decreasing -remoteWrite.maxBlockSize.
+		remoteWriteRejectedLogger.Errorf("sending a block with size %d bytes to %q was rejected (skipping the block): %s", len(block), c.sanitizedURL, err)
+		c.packetsDropped.Inc()
+		return true
+	}
+	c.errorsCount.Inc()
+	retryDuration *= 2
+	if retryDuration > maxRetryDuration {
+		retryDuration = maxRetryDuration
+	}
+	logger.Warnf("couldn't send a block with size %d bytes to %q: %s; re-sending the block in %.3f seconds",
+		len(block), c.sanitizedURL, err, retryDuration.Seconds())
+	t := timerpool.Get(retryDuration)
+	select {
+	case <-c.stopCh:
+		timerpool.Put(t)
+		return false
+	case <-t.C:
+		timerpool.Put(t)
+	}
+	c.retriesCount.Inc()
+	goto again
+}
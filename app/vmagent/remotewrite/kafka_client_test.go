@@ -0,0 +1,178 @@
+package remotewrite
+
+import (
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/kafka"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/persistentqueue"
+)
+
+func TestParseKafkaURLSuccess(t *testing.T) {
+	f := func(s string, brokersExpected []string, topicExpected, clientIDExpected string) {
+		t.Helper()
+
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse url: %s", err)
+		}
+		cfg, topic, err := parseKafkaURL(u)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(cfg.Brokers, brokersExpected) {
+			t.Fatalf("unexpected brokers; got %q; want %q", cfg.Brokers, brokersExpected)
+		}
+		if topic != topicExpected {
+			t.Fatalf("unexpected topic; got %q; want %q", topic, topicExpected)
+		}
+		if cfg.ClientID != clientIDExpected {
+			t.Fatalf("unexpected client id; got %q; want %q", cfg.ClientID, clientIDExpected)
+		}
+	}
+
+	f("kafka://localhost:9092/prom-rw", []string{"localhost:9092"}, "prom-rw", "vmagent")
+	f("kafka://host1:9092,host2:9093/prom-rw/", []string{"host1:9092", "host2:9093"}, "prom-rw", "vmagent")
+	f("kafka://localhost:9092/?topic=prom-rw", []string{"localhost:9092"}, "prom-rw", "vmagent")
+	f("kafka://localhost:9092/prom-rw?topic=prom-rw&client.id=foo", []string{"localhost:9092"}, "prom-rw", "foo")
+	f("kafka://localhost:9092/prom-rw?security.protocol=SASL_SSL&sasl.mechanisms=PLAIN&compression.type=zstd", []string{"localhost:9092"}, "prom-rw", "vmagent")
+}
+
+func TestParseKafkaURLFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatalf("cannot parse url: %s", err)
+		}
+		if _, _, err := parseKafkaURL(u); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing brokers
+	f("kafka:///prom-rw")
+
+	// empty broker
+	f("kafka://,host1:9092/prom-rw")
+
+	// missing topic
+	f("kafka://localhost:9092")
+	f("kafka://localhost:9092/")
+
+	// conflicting topics
+	f("kafka://localhost:9092/foo?topic=bar")
+
+	// invalid topic
+	f("kafka://localhost:9092/foo/bar")
+
+	// unsupported query arg
+	f("kafka://localhost:9092/foo?acks=0")
+
+	// invalid option value
+	f("kafka://localhost:9092/foo?security.protocol=foo")
+}
+
+// testKafkaProducer collects produced messages and fails producing while err is set.
+type testKafkaProducer struct {
+	mu   sync.Mutex
+	err  error
+	msgs []kafka.Message
+}
+
+func (p *testKafkaProducer) Produce(value []byte, headers []kafka.Header) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	p.msgs = append(p.msgs, kafka.Message{
+		Value:   append([]byte{}, value...),
+		Headers: headers,
+	})
+	return nil
+}
+
+func (p *testKafkaProducer) MustClose() {}
+
+func (p *testKafkaProducer) setError(err error) {
+	p.mu.Lock()
+	p.err = err
+	p.mu.Unlock()
+}
+
+func (p *testKafkaProducer) messages() []kafka.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]kafka.Message{}, p.msgs...)
+}
+
+func TestKafkaClient(t *testing.T) {
+	u, err := url.Parse("kafka://localhost:9092/prom-rw")
+	if err != nil {
+		t.Fatalf("cannot parse url: %s", err)
+	}
+	fq := persistentqueue.MustOpenFastQueue(t.TempDir(), "kafka-test", 10, 0, false)
+	c := newKafkaClient(0, u, "1:kafka-test", fq)
+	c.kp.MustClose()
+	kp := &testKafkaProducer{}
+	c.kp = kp
+	c.retryMinInterval = 10 * time.Millisecond
+	c.retryMaxTime = 10 * time.Millisecond
+	c.init(0, 2, "1:kafka-test")
+
+	// Blocks written to the queue before unavailability of the broker must be sent after it becomes available.
+	kp.setError(kerr.NotEnoughReplicas)
+	blocks := []string{"foo", "bar", "baz"}
+	for _, block := range blocks {
+		fq.MustWriteBlockIgnoreDisabledPQ([]byte(block))
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := len(kp.messages()); n != 0 {
+		t.Fatalf("unexpected number of messages written during broker unavailability: %d", n)
+	}
+	kp.setError(nil)
+
+	deadline := time.Now().Add(10 * time.Second)
+	var msgs []kafka.Message
+	for len(msgs) < len(blocks) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for messages; got %d messages; want %d messages", len(msgs), len(blocks))
+		}
+		time.Sleep(10 * time.Millisecond)
+		msgs = kp.messages()
+	}
+
+	// Blocks rejected by the broker must be dropped.
+	kp.setError(kerr.MessageTooLarge)
+	fq.MustWriteBlockIgnoreDisabledPQ([]byte("too-large"))
+	deadline = time.Now().Add(10 * time.Second)
+	for c.packetsDropped.Get() != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when waiting for the rejected block to be dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	fq.UnblockAllReaders()
+	c.MustStop()
+	fq.MustClose()
+
+	seen := make(map[string]bool)
+	for _, m := range msgs {
+		seen[string(m.Value)] = true
+		if ce := string(m.GetHeader("Content-Encoding")); ce != "snappy" {
+			t.Fatalf("unexpected Content-Encoding header; got %q; want %q", ce, "snappy")
+		}
+	}
+	for _, block := range blocks {
+		if !seen[block] {
+			t.Fatalf("missing block %q in Kafka messages", block)
+		}
+	}
+}
@@ -39,10 +39,10 @@ type pendingSeries struct {
 	periodicFlusherWG sync.WaitGroup
 }
 
-func newPendingSeries(fq *persistentqueue.FastQueue, isVMRemoteWrite bool, significantFigures, roundDigits int) *pendingSeries {
+func newPendingSeries(fq *persistentqueue.FastQueue, p protocol, significantFigures, roundDigits int) *pendingSeries {
 	var ps pendingSeries
 	ps.wr.fq = fq
-	ps.wr.isVMRemoteWrite = isVMRemoteWrite
+	ps.wr.protocol = p
 	ps.wr.significantFigures = significantFigures
 	ps.wr.roundDigits = roundDigits
 	ps.stopCh = make(chan struct{})
@@ -98,8 +98,8 @@ type writeRequest struct {
 	// The queue to send blocks to.
 	fq *persistentqueue.FastQueue
 
-	// Whether to encode the write request with VictoriaMetrics remote write protocol.
-	isVMRemoteWrite bool
+	// The protocol for encoding the write request.
+	protocol protocol
 
 	// How many significant figures must be left before sending the writeRequest to fq.
 	significantFigures int
@@ -109,16 +109,17 @@ type writeRequest struct {
 
 	wr prompbmarshal.WriteRequest
 
-	tss     []prompbmarshal.TimeSeries
-	labels  []prompbmarshal.Label
-	samples []prompbmarshal.Sample
+	tss       []prompbmarshal.TimeSeries
+	labels    []prompbmarshal.Label
+	samples   []prompbmarshal.Sample
+	exemplars []prompbmarshal.Exemplar
 
 	// buf holds labels data
 	buf []byte
 }
 
 func (wr *writeRequest) reset() {
-	// Do not reset lastFlushTime, fq, isVMRemoteWrite, significantFigures and roundDigits, since they are re-used.
+	// Do not reset lastFlushTime, fq, protocol, significantFigures and roundDigits, since they are re-used.
 
 	wr.wr.Timeseries = nil
 
@@ -129,6 +130,10 @@ func (wr *writeRequest) reset() {
 	wr.labels = wr.labels[:0]
 
 	wr.samples = wr.samples[:0]
+
+	clear(wr.exemplars)
+	wr.exemplars = wr.exemplars[:0]
+
 	wr.buf = wr.buf[:0]
 }
 
@@ -137,7 +142,7 @@ func (wr *writeRequest) reset() {
 // This is needed in order to properly save in-memory data to persistent queue on graceful shutdown.
 func (wr *writeRequest) mustFlushOnStop() {
 	wr.wr.Timeseries = wr.tss
-	if !tryPushWriteRequest(&wr.wr, wr.mustWriteBlock, wr.isVMRemoteWrite) {
+	if !tryPushWriteRequest(&wr.wr, wr.mustWriteBlock, wr.protocol) {
 		logger.Panicf("BUG: final flush must always return true")
 	}
 	wr.reset()
@@ -151,7 +156,7 @@ func (wr *writeRequest) mustWriteBlock(block []byte) bool {
 func (wr *writeRequest) tryFlush() bool {
 	wr.wr.Timeseries = wr.tss
 	wr.lastFlushTime.Store(fasttime.UnixTimestamp())
-	if !tryPushWriteRequest(&wr.wr, wr.fq.TryWriteBlock, wr.isVMRemoteWrite) {
+	if !tryPushWriteRequest(&wr.wr, wr.fq.TryWriteBlock, wr.protocol) {
 		return false
 	}
 	wr.reset()
@@ -216,15 +221,39 @@ func (wr *writeRequest) copyTimeSeries(dst, src *prompbmarshal.TimeSeries) {
 	samplesDst = append(samplesDst, src.Samples...)
 	dst.Samples = samplesDst[len(samplesDst)-len(src.Samples):]
 
+	exemplarsDst := wr.exemplars
+	exemplarsLen := len(exemplarsDst)
+	for i := range src.Exemplars {
+		srcExemplar := &src.Exemplars[i]
+		exemplarLabelsLen := len(labelsDst)
+		for j := range srcExemplar.Labels {
+			labelsDst = append(labelsDst, prompbmarshal.Label{})
+			dstLabel := &labelsDst[len(labelsDst)-1]
+			srcLabel := &srcExemplar.Labels[j]
+
+			buf = append(buf, srcLabel.Name...)
+			dstLabel.Name = bytesutil.ToUnsafeString(buf[len(buf)-len(srcLabel.Name):])
+			buf = append(buf, srcLabel.Value...)
+			dstLabel.Value = bytesutil.ToUnsafeString(buf[len(buf)-len(srcLabel.Value):])
+		}
+		exemplarsDst = append(exemplarsDst, prompbmarshal.Exemplar{
+			Labels:    labelsDst[exemplarLabelsLen:],
+			Value:     srcExemplar.Value,
+			Timestamp: srcExemplar.Timestamp,
+		})
+	}
+	dst.Exemplars = exemplarsDst[exemplarsLen:]
+
 	wr.samples = samplesDst
 	wr.labels = labelsDst
+	wr.exemplars = exemplarsDst
 	wr.buf = buf
 }
 
 // marshalConcurrency limits the maximum number of concurrent workers, which marshal and compress WriteRequest.
 var marshalConcurrencyCh = make(chan struct{}, cgroup.AvailableCPUs())
 
-func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block []byte) bool, isVMRemoteWrite bool) bool {
+func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block []byte) bool, p protocol) bool {
 	if len(wr.Timeseries) == 0 {
 		// Nothing to push
 		return true
@@ -233,13 +262,22 @@ func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block
 	marshalConcurrencyCh <- struct{}{}
 
 	bb := writeRequestBufPool.Get()
-	bb.B = wr.MarshalProtobuf(bb.B[:0])
+	if p == protocolPrometheusV2 {
+		bb.B = wr.MarshalProtobufV2(bb.B[:0])
+	} else {
+		bb.B = wr.MarshalProtobuf(bb.B[:0])
+	}
 	if len(bb.B) <= maxUnpackedBlockSize.IntN() {
 		zb := compressBufPool.Get()
-		if isVMRemoteWrite {
-			zb.B = zstd.CompressLevel(zb.B[:0], bb.B, *vmProtoCompressLevel)
+		// The protocol is recorded in the block header, so the block is sent with the proper headers
+		// even if the protocol for the remote storage is changed while the block is stored in the persistent queue.
+		zb.B = appendBlockHeader(zb.B[:0], p)
+		if p == protocolVM {
+			zb.B = zstd.CompressLevel(zb.B, bb.B, *vmProtoCompressLevel)
 		} else {
-			zb.B = snappy.Encode(zb.B[:cap(zb.B)], bb.B)
+			n := len(zb.B)
+			zb.B = bytesutil.ResizeWithCopyMayOverallocate(zb.B, n+snappy.MaxEncodedLen(len(bb.B)))
+			zb.B = zb.B[:n+len(snappy.Encode(zb.B[n:], bb.B))]
 		}
 		writeRequestBufPool.Put(bb)
 
@@ -272,12 +310,12 @@ func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block
 		}
 		n := len(samples) / 2
 		wr.Timeseries[0].Samples = samples[:n]
-		if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+		if !tryPushWriteRequest(wr, tryPushBlock, p) {
 			wr.Timeseries[0].Samples = samples
 			return false
 		}
 		wr.Timeseries[0].Samples = samples[n:]
-		if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+		if !tryPushWriteRequest(wr, tryPushBlock, p) {
 			wr.Timeseries[0].Samples = samples
 			return false
 		}
@@ -287,12 +325,12 @@ func tryPushWriteRequest(wr *prompbmarshal.WriteRequest, tryPushBlock func(block
 	timeseries := wr.Timeseries
 	n := len(timeseries) / 2
 	wr.Timeseries = timeseries[:n]
-	if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+	if !tryPushWriteRequest(wr, tryPushBlock, p) {
 		wr.Timeseries = timeseries
 		return false
 	}
 	wr.Timeseries = timeseries[n:]
-	if !tryPushWriteRequest(wr, tryPushBlock, isVMRemoteWrite) {
+	if !tryPushWriteRequest(wr, tryPushBlock, p) {
 		wr.Timeseries = timeseries
 		return false
 	}
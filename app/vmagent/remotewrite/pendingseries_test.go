@@ -12,17 +12,19 @@ func TestPushWriteRequest(t *testing.T) {
 	rowsCounts := []int{1, 10, 100, 1e3, 1e4}
 	expectedBlockLensProm := []int{216, 1848, 16424, 169882, 1757876}
 	expectedBlockLensVM := []int{138, 492, 3927, 34995, 288476}
+	expectedBlockLensPromV2 := []int{239, 2385, 24051, 265699, 2948788}
 	for i, rowsCount := range rowsCounts {
 		expectedBlockLenProm := expectedBlockLensProm[i]
 		expectedBlockLenVM := expectedBlockLensVM[i]
+		expectedBlockLenPromV2 := expectedBlockLensPromV2[i]
 		t.Run(fmt.Sprintf("%d", rowsCount), func(t *testing.T) {
-			testPushWriteRequest(t, rowsCount, expectedBlockLenProm, expectedBlockLenVM)
+			testPushWriteRequest(t, rowsCount, expectedBlockLenProm, expectedBlockLenVM, expectedBlockLenPromV2)
 		})
 	}
 }
 
-func testPushWriteRequest(t *testing.T, rowsCount, expectedBlockLenProm, expectedBlockLenVM int) {
-	f := func(isVMRemoteWrite bool, expectedBlockLen int, tolerancePrc float64) {
+func testPushWriteRequest(t *testing.T, rowsCount, expectedBlockLenProm, expectedBlockLenVM, expectedBlockLenPromV2 int) {
+	f := func(p protocol, expectedBlockLen int, tolerancePrc float64) {
 		t.Helper()
 		wr := newTestWriteRequest(rowsCount, 20)
 		pushBlockLen := 0
@@ -31,22 +33,32 @@ func testPushWriteRequest(t *testing.T, rowsCount, expectedBlockLenProm, expecte
 				panic(fmt.Errorf("BUG: pushBlock called multiple times; pushBlockLen=%d at first call, len(block)=%d at second call", pushBlockLen, len(block)))
 			}
 			pushBlockLen = len(block)
+			pBlock, _, err := parseBlock(block)
+			if err != nil {
+				panic(fmt.Errorf("cannot parse block: %w", err))
+			}
+			if pBlock != p {
+				panic(fmt.Errorf("unexpected protocol in the block header; got %s; want %s", pBlock, p))
+			}
 			return true
 		}
-		if !tryPushWriteRequest(wr, pushBlock, isVMRemoteWrite) {
+		if !tryPushWriteRequest(wr, pushBlock, p) {
 			t.Fatalf("cannot push data to remote storage")
 		}
 		if math.Abs(float64(pushBlockLen-expectedBlockLen)/float64(expectedBlockLen)*100) > tolerancePrc {
-			t.Fatalf("unexpected block len for rowsCount=%d, isVMRemoteWrite=%v; got %d bytes; expecting %d bytes +- %.0f%%",
-				rowsCount, isVMRemoteWrite, pushBlockLen, expectedBlockLen, tolerancePrc)
+			t.Fatalf("unexpected block len for rowsCount=%d, protocol=%s; got %d bytes; expecting %d bytes +- %.0f%%",
+				rowsCount, p, pushBlockLen, expectedBlockLen, tolerancePrc)
 		}
 	}
 
 	// Check Prometheus remote write
-	f(false, expectedBlockLenProm, 3)
+	f(protocolPrometheus, expectedBlockLenProm, 3)
 
 	// Check VictoriaMetrics remote write
-	f(true, expectedBlockLenVM, 15)
+	f(protocolVM, expectedBlockLenVM, 15)
+
+	// Check Prometheus Remote Write 2.0
+	f(protocolPrometheusV2, expectedBlockLenPromV2, 3)
 }
 
 func newTestWriteRequest(seriesCount, labelsCount int) *prompbmarshal.WriteRequest {
@@ -0,0 +1,162 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
+)
+
+var remoteWriteProtocol = flagutil.NewArrayString("remoteWrite.protocol", "Optional protocol for sending data to the corresponding -remoteWrite.url. "+
+	"Supported values: vm, prometheus, prometheus-v2. By default VictoriaMetrics remote write protocol is used if the remote storage supports it, "+
+	"otherwise Prometheus remote write 1.0 protocol is used. Prometheus remote write 2.0 protocol is used only if it is set explicitly via prometheus-v2. "+
+	"See https://docs.victoriametrics.com/vmagent/#victoriametrics-remote-write-protocol")
+
+// protocol is the protocol for sending data blocks to remote storage.
+type protocol byte
+
+const (
+	// protocolPrometheus is Prometheus remote write 1.0 protocol - snappy-compressed prompb.WriteRequest.
+	protocolPrometheus protocol = 1
+
+	// protocolVM is VictoriaMetrics remote write protocol - zstd-compressed prompb.WriteRequest.
+	protocolVM protocol = 2
+
+	// protocolPrometheusV2 is Prometheus remote write 2.0 protocol - snappy-compressed io.prometheus.write.v2.Request.
+	protocolPrometheusV2 protocol = 3
+)
+
+func (p protocol) String() string {
+	switch p {
+	case protocolPrometheus:
+		return "Prometheus remote write protocol"
+	case protocolVM:
+		return "VictoriaMetrics remote write protocol"
+	case protocolPrometheusV2:
+		return "Prometheus remote write 2.0 protocol"
+	default:
+		return fmt.Sprintf("unknown protocol %d", byte(p))
+	}
+}
+
+// setHeaders sets HTTP headers for sending blocks encoded with p to h.
+func (p protocol) setHeaders(h http.Header) {
+	switch p {
+	case protocolVM:
+		h.Set("Content-Type", "application/x-protobuf")
+		h.Set("Content-Encoding", "zstd")
+		h.Set("X-VictoriaMetrics-Remote-Write-Version", "1")
+	case protocolPrometheusV2:
+		h.Set("Content-Type", stream.RemoteWriteV2ContentType)
+		h.Set("Content-Encoding", "snappy")
+		h.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+	default:
+		h.Set("Content-Type", "application/x-protobuf")
+		h.Set("Content-Encoding", "snappy")
+		h.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+}
+
+// getProtocol returns the protocol for sending data to the -remoteWrite.url with the given argIdx.
+//
+// The protocol is detected with detectProtocol if it isn't set explicitly via command-line flags.
+func getProtocol(argIdx int, sanitizedURL string, detectProtocol func() protocol) protocol {
+	p, err := parseProtocol(remoteWriteProtocol.GetOptionalArg(argIdx))
+	if err != nil {
+		logger.Fatalf("invalid -remoteWrite.protocol for -remoteWrite.url=%s: %s", sanitizedURL, err)
+	}
+	useVMProto := forceVMProto.GetOptionalArg(argIdx)
+	usePromProto := forcePromProto.GetOptionalArg(argIdx)
+	if useVMProto && usePromProto {
+		logger.Fatalf("-remoteWrite.forceVMProto and -remoteWrite.forcePromProto cannot be set simultaneously for -remoteWrite.url=%s", sanitizedURL)
+	}
+	if useVMProto {
+		if p != 0 && p != protocolVM {
+			logger.Fatalf("-remoteWrite.forceVMProto cannot be set together with -remoteWrite.protocol=%s for -remoteWrite.url=%s",
+				remoteWriteProtocol.GetOptionalArg(argIdx), sanitizedURL)
+		}
+		p = protocolVM
+	}
+	if usePromProto {
+		if p != 0 && p != protocolPrometheus {
+			logger.Fatalf("-remoteWrite.forcePromProto cannot be set together with -remoteWrite.protocol=%s for -remoteWrite.url=%s",
+				remoteWriteProtocol.GetOptionalArg(argIdx), sanitizedURL)
+		}
+		p = protocolPrometheus
+	}
+	if p == 0 {
+		p = detectProtocol()
+	}
+	return p
+}
+
+func parseProtocol(s string) (protocol, error) {
+	switch s {
+	case "":
+		return 0, nil
+	case "vm":
+		return protocolVM, nil
+	case "prometheus":
+		return protocolPrometheus, nil
+	case "prometheus-v2":
+		return protocolPrometheusV2, nil
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q; supported values: vm, prometheus, prometheus-v2", s)
+	}
+}
+
+// detectHTTPProtocol returns protocolVM if the remote storage at c.remoteWriteURL supports VictoriaMetrics remote write protocol.
+//
+// Otherwise protocolPrometheus is returned.
+func (c *client) detectHTTPProtocol() protocol {
+	doRequest := func(url string) (*http.Response, error) {
+		return c.doRequest(url, nil, protocolPrometheus)
+	}
+	if common.HandleVMProtoClientHandshake(c.remoteWriteURL, doRequest) {
+		return protocolVM
+	}
+	logger.Infof("the remote storage at %q doesn't support VictoriaMetrics remote write protocol. Switching to Prometheus remote write protocol. "+
+		"See https://docs.victoriametrics.com/vmagent/#victoriametrics-remote-write-protocol", c.sanitizedURL)
+	return protocolPrometheus
+}
+
+// Every block written to the persistent queue starts with blockMarker followed by the protocol byte.
+//
+// This allows sending blocks with the proper headers after the protocol for the -remoteWrite.url is changed,
+// e.g. after vmagent restart with another -remoteWrite.protocol.
+//
+// Blocks written by older vmagent versions have no such prefix. They always start with non-zero byte,
+// since both snappy-compressed blocks with non-empty contents and zstd-compressed blocks start with non-zero byte.
+const blockMarker = 0
+
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// appendBlockHeader appends the header for the block encoded with p to dst and returns the result.
+func appendBlockHeader(dst []byte, p protocol) []byte {
+	return append(dst, blockMarker, byte(p))
+}
+
+// parseBlock returns the protocol and the payload for the given block read from the persistent queue.
+func parseBlock(block []byte) (protocol, []byte, error) {
+	if len(block) == 0 || block[0] != blockMarker {
+		// The block was written by older vmagent version.
+		if bytes.HasPrefix(block, zstdMagic) {
+			return protocolVM, block, nil
+		}
+		return protocolPrometheus, block, nil
+	}
+	if len(block) < 2 {
+		return 0, nil, fmt.Errorf("missing protocol in the block header")
+	}
+	p := protocol(block[1])
+	switch p {
+	case protocolPrometheus, protocolVM, protocolPrometheusV2:
+		return p, block[2:], nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported protocol %d in the block header", block[1])
+	}
+}
@@ -0,0 +1,166 @@
+package remotewrite
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/promremotewrite/stream"
+	"github.com/golang/snappy"
+)
+
+func TestParseProtocolSuccess(t *testing.T) {
+	f := func(s string, pExpected protocol) {
+		t.Helper()
+
+		p, err := parseProtocol(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if p != pExpected {
+			t.Fatalf("unexpected protocol for %q; got %s; want %s", s, p, pExpected)
+		}
+	}
+
+	f("", 0)
+	f("vm", protocolVM)
+	f("prometheus", protocolPrometheus)
+	f("prometheus-v2", protocolPrometheusV2)
+}
+
+func TestParseProtocolFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+
+		if _, err := parseProtocol(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	f("foo")
+	f("VM")
+	f("prometheus-v3")
+}
+
+func TestParseBlockSuccess(t *testing.T) {
+	f := func(block []byte, pExpected protocol, payloadExpected []byte) {
+		t.Helper()
+
+		p, payload, err := parseBlock(block)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if p != pExpected {
+			t.Fatalf("unexpected protocol; got %s; want %s", p, pExpected)
+		}
+		if !bytes.Equal(payload, payloadExpected) {
+			t.Fatalf("unexpected payload; got %X; want %X", payload, payloadExpected)
+		}
+	}
+
+	data := []byte("some data for compression")
+	snappyData := snappy.Encode(nil, data)
+	zstdData := zstd.CompressLevel(nil, data, 0)
+
+	// blocks with the header
+	for _, p := range []protocol{protocolPrometheus, protocolVM, protocolPrometheusV2} {
+		block := appendBlockHeader(nil, p)
+		block = append(block, snappyData...)
+		f(block, p, snappyData)
+	}
+
+	// blocks written by older vmagent versions
+	f(snappyData, protocolPrometheus, snappyData)
+	f(zstdData, protocolVM, zstdData)
+}
+
+func TestParseBlockRoundTrip(t *testing.T) {
+	wr := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{{
+			Labels: []prompbmarshal.Label{
+				{
+					Name:  "__name__",
+					Value: "foo",
+				},
+				{
+					Name:  "job",
+					Value: "bar",
+				},
+			},
+			Samples: []prompbmarshal.Sample{{
+				Value:     123,
+				Timestamp: 1000,
+			}},
+		}},
+	}
+
+	// f verifies that wr is read from the given block with the same contents as sent to remote storage with pExpected protocol.
+	f := func(block []byte, pExpected protocol) {
+		t.Helper()
+
+		p, payload, err := parseBlock(block)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if p != pExpected {
+			t.Fatalf("unexpected protocol; got %s; want %s", p, pExpected)
+		}
+		h := make(http.Header)
+		p.setHeaders(h)
+		var result []string
+		_, err = stream.Parse(bytes.NewReader(payload), h.Get("Content-Encoding") == "zstd", h.Get("Content-Type"), func(tss []prompb.TimeSeries, _ []prompb.MetricMetadata) error {
+			for _, ts := range tss {
+				for _, s := range ts.Samples {
+					result = append(result, fmt.Sprintf("%s %v %d", ts.Labels, s.Value, s.Timestamp))
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("cannot parse block payload: %s", err)
+		}
+		resultExpected := `[{__name__ foo} {job bar}] 123 1000`
+		if len(result) != 1 || result[0] != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+
+	// blocks written by the current vmagent version
+	for _, p := range []protocol{protocolPrometheus, protocolVM, protocolPrometheusV2} {
+		var block []byte
+		ok := tryPushWriteRequest(wr, func(b []byte) bool {
+			block = append(block[:0], b...)
+			return true
+		}, p)
+		if !ok {
+			t.Fatalf("cannot push write request for %s", p)
+		}
+		f(block, p)
+	}
+
+	// blocks written by older vmagent versions
+	data := wr.MarshalProtobuf(nil)
+	f(snappy.Encode(nil, data), protocolPrometheus)
+	f(zstd.CompressLevel(nil, data, 0), protocolVM)
+}
+
+func TestParseBlockFailure(t *testing.T) {
+	f := func(block []byte) {
+		t.Helper()
+
+		if _, _, err := parseBlock(block); err == nil {
+			t.Fatalf("expecting non-nil error for block %X", block)
+		}
+	}
+
+	// missing protocol
+	f([]byte{blockMarker})
+
+	// unknown protocol
+	f([]byte{blockMarker, 0})
+	f([]byte{blockMarker, 123, 1, 2, 3})
+}
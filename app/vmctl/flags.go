@@ -766,6 +766,50 @@ var (
 	}
 )
 
+const (
+	reshardSrcPath         = "reshard-src-path"
+	reshardDstPath         = "reshard-dst-path"
+	reshardMatch           = "reshard-match"
+	reshardFilterTimeStart = "reshard-filter-time-start"
+	reshardFilterTimeEnd   = "reshard-filter-time-end"
+	reshardMaxSeries       = "reshard-max-series"
+)
+
+var (
+	reshardFlags = []cli.Flag{
+		&cli.StringFlag{
+			Name:     reshardSrcPath,
+			Usage:    "Path to the data directory of stopped single-node VictoriaMetrics to copy series from. See -storageDataPath command-line flag at VictoriaMetrics",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     reshardDstPath,
+			Usage:    "Path to the new data directory to copy the matching series to. The directory must be empty or missing",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     reshardMatch,
+			Usage:    "Series selector for the series to copy. E.g. '{team=\"foo\"}' or '{team=~\"foo|bar\"}'",
+			Required: true,
+		},
+		&cli.TimestampFlag{
+			Name:   reshardFilterTimeStart,
+			Usage:  "The time filter in RFC3339 format to copy samples with timestamp equal or higher than provided value. E.g. '2020-01-01T20:07:00Z'. By default, all the samples are copied",
+			Layout: time.RFC3339,
+		},
+		&cli.TimestampFlag{
+			Name:   reshardFilterTimeEnd,
+			Usage:  "The time filter in RFC3339 format to copy samples with timestamp equal or lower than provided value. E.g. '2020-01-01T20:07:00Z'. By default, all the samples are copied",
+			Layout: time.RFC3339,
+		},
+		&cli.IntFlag{
+			Name:  reshardMaxSeries,
+			Value: 10e6,
+			Usage: "The maximum number of series to copy. vmctl reshard fails without copying the data if the number of series matching --reshard-match exceeds this limit",
+		},
+	}
+)
+
 func mergeFlags(flags ...[]cli.Flag) []cli.Flag {
 	var result []cli.Flag
 	for _, f := range flags {
@@ -28,6 +28,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/native/stream"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
 func main() {
@@ -370,6 +371,23 @@ func main() {
 					return p.run(ctx)
 				},
 			},
+			{
+				Name:   "reshard",
+				Usage:  "Copy series matching the given selector from the data directory of stopped single-node VictoriaMetrics to a new data directory",
+				Flags:  mergeFlags(globalFlags, reshardFlags),
+				Before: beforeFn,
+				Action: func(c *cli.Context) error {
+					fmt.Println("Reshard mode")
+					rp := reshardProcessor{
+						srcPath:   c.String(reshardSrcPath),
+						dstPath:   c.String(reshardDstPath),
+						match:     c.String(reshardMatch),
+						tr:        getReshardTimeRange(c.Timestamp(reshardFilterTimeStart), c.Timestamp(reshardFilterTimeEnd)),
+						maxSeries: c.Int(reshardMaxSeries),
+					}
+					return rp.run()
+				},
+			},
 			{
 				Name:  "verify-block",
 				Usage: "Verifies exported block with VictoriaMetrics Native format",
@@ -405,6 +423,42 @@ func main() {
 					return nil
 				},
 			},
+			{
+				Name:  "verify-storage",
+				Usage: "Verifies the integrity of data and indexdb parts in the data directory of stopped single-node VictoriaMetrics",
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "quarantine-path",
+						Usage: "Optional path to move corrupted parts to. The path must be located on the same filesystem as the verified data directory",
+					},
+				},
+				Before: beforeFn,
+				Action: func(c *cli.Context) error {
+					dataPath := c.Args().First()
+					quarantinePath := c.String("quarantine-path")
+					if len(dataPath) == 0 {
+						return cli.Exit("you must provide path to the data directory", 1)
+					}
+					log.Printf("verifying parts at path=%q", dataPath)
+					var corruptedParts int
+					partsCount, err := storage.CheckDataDir(dataPath, quarantinePath, func(partPath string, err error) {
+						log.Printf("found corrupted part at path=%q: %s", partPath, err)
+						corruptedParts++
+					})
+					if err != nil {
+						return cli.Exit(fmt.Errorf("cannot verify parts at path=%q: %w", dataPath, err), 1)
+					}
+					if corruptedParts == 0 {
+						log.Printf("successfully verified %d parts at path=%q", partsCount, dataPath)
+						return nil
+					}
+					if quarantinePath != "" {
+						log.Printf("moved %d corrupted parts out of %d parts at path=%q to quarantine-path=%q", corruptedParts, partsCount, dataPath, quarantinePath)
+						return nil
+					}
+					return cli.Exit(fmt.Errorf("found %d corrupted parts out of %d parts at path=%q", corruptedParts, partsCount, dataPath), 1)
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+type reshardProcessor struct {
+	// srcPath is the path to the data directory of stopped single-node VictoriaMetrics
+	srcPath string
+	// dstPath is the path to the data directory, which must contain only the series matching the filter
+	dstPath string
+	// match is the series selector for the series to copy
+	match string
+	// tr is the time range for the samples to copy
+	tr storage.TimeRange
+	// maxSeries is the maximum number of series to copy
+	maxSeries int
+}
+
+func (rp *reshardProcessor) run() error {
+	tfss, err := storage.ParseSeriesFilter(rp.match)
+	if err != nil {
+		return fmt.Errorf("cannot parse --%s=%q: %w", reshardMatch, rp.match, err)
+	}
+	if !fs.IsPathExist(rp.srcPath) {
+		return fmt.Errorf("source data directory %q doesn't exist", rp.srcPath)
+	}
+	if filepath.Clean(rp.srcPath) == filepath.Clean(rp.dstPath) {
+		return fmt.Errorf("--%s and --%s must point to distinct directories", reshardSrcPath, reshardDstPath)
+	}
+	if des, err := os.ReadDir(rp.dstPath); err == nil && len(des) > 0 {
+		return fmt.Errorf("destination data directory %q must be empty or missing", rp.dstPath)
+	}
+
+	question := fmt.Sprintf("Copy series matching %s on the time range %s from %q to %q? The source VictoriaMetrics must be stopped. Continue?",
+		rp.match, &rp.tr, rp.srcPath, rp.dstPath)
+	if !prompt(question) {
+		return nil
+	}
+
+	src := storage.MustOpenStorageReadOnly(rp.srcPath)
+	defer src.MustClose()
+	dst := storage.MustOpenStorage(rp.dstPath, 0, 0, 0)
+	defer dst.MustClose()
+
+	start := time.Now()
+	stats, err := storage.CopySeries(dst, src, tfss, rp.tr, rp.maxSeries, func(stats *storage.CopySeriesStats) {
+		if !isSilent {
+			log.Printf("copied %d samples for %d series so far", stats.SamplesCopied, stats.SeriesCopied)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("cannot copy series from %q to %q: %w", rp.srcPath, rp.dstPath, err)
+	}
+	log.Printf("copied %d samples from %d blocks for %d series to %q in %.3f seconds",
+		stats.SamplesCopied, stats.BlocksCopied, stats.SeriesCopied, rp.dstPath, time.Since(start).Seconds())
+	return nil
+}
+
+func getReshardTimeRange(start, end *time.Time) storage.TimeRange {
+	// VictoriaMetrics doesn't accept samples with timestamps exceeding the current time by more than 2 days,
+	// so all the stored samples are covered by the default time range.
+	tr := storage.TimeRange{
+		MinTimestamp: 0,
+		MaxTimestamp: time.Now().Add(2 * 24 * time.Hour).UnixMilli(),
+	}
+	if start != nil {
+		tr.MinTimestamp = start.UnixMilli()
+	}
+	if end != nil {
+		tr.MaxTimestamp = end.UnixMilli()
+	}
+	return tr
+}
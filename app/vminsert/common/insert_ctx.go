@@ -20,6 +20,12 @@ type InsertCtx struct {
 	mrs            []storage.MetricRow
 	metricNamesBuf []byte
 
+	ers               []storage.ExemplarRow
+	exemplarTags      []storage.Tag
+	exemplarTagsStart int
+
+	mms []storage.MetricMetadata
+
 	relabelCtx    relabel.Ctx
 	streamAggrCtx streamAggrCtx
 
@@ -42,6 +48,16 @@ func (ctx *InsertCtx) Reset(rowsLen int) {
 	ctx.mrs = mrs[:0]
 
 	ctx.metricNamesBuf = ctx.metricNamesBuf[:0]
+
+	clear(ctx.ers)
+	ctx.ers = ctx.ers[:0]
+	clear(ctx.exemplarTags)
+	ctx.exemplarTags = ctx.exemplarTags[:0]
+	ctx.exemplarTagsStart = 0
+
+	clear(ctx.mms)
+	ctx.mms = ctx.mms[:0]
+
 	ctx.relabelCtx.Reset()
 	ctx.streamAggrCtx.Reset()
 	ctx.skipStreamAggr = false
@@ -96,6 +112,51 @@ func (ctx *InsertCtx) addRow(metricNameRaw []byte, timestamp int64, value float6
 	return nil
 }
 
+// AddExemplarLabel adds (name, value) label for the exemplar, which is written by the next WriteExemplarExt call.
+//
+// name and value must exist until ctx.FlushBufs is called.
+func (ctx *InsertCtx) AddExemplarLabel(name, value string) {
+	ctx.exemplarTags = append(ctx.exemplarTags, storage.Tag{
+		Key:   bytesutil.ToUnsafeBytes(name),
+		Value: bytesutil.ToUnsafeBytes(value),
+	})
+}
+
+// WriteExemplarExt writes exemplar (timestamp, value) with the given metricNameRaw and labels into ctx buffer.
+//
+// Exemplar labels must be added via AddExemplarLabel before the call.
+//
+// It returns metricNameRaw for the given labels if len(metricNameRaw) == 0.
+func (ctx *InsertCtx) WriteExemplarExt(metricNameRaw []byte, labels []prompb.Label, timestamp int64, value float64) []byte {
+	if len(metricNameRaw) == 0 {
+		metricNameRaw = ctx.marshalMetricNameRaw(nil, labels)
+	}
+	tags := ctx.exemplarTags[ctx.exemplarTagsStart:]
+	ctx.exemplarTagsStart = len(ctx.exemplarTags)
+	ctx.ers = append(ctx.ers, storage.ExemplarRow{
+		MetricNameRaw: metricNameRaw,
+		Exemplar: storage.Exemplar{
+			Labels:    tags[:len(tags):len(tags)],
+			Value:     value,
+			Timestamp: timestamp,
+		},
+	})
+	return metricNameRaw
+}
+
+// AddMetricMetadata adds metadata for the given metricFamilyName to ctx buffer.
+//
+// typ must contain metric type name such as "counter" or "gauge".
+// The args must exist until ctx.FlushBufs is called.
+func (ctx *InsertCtx) AddMetricMetadata(metricFamilyName, typ, help, unit string) {
+	ctx.mms = append(ctx.mms, storage.MetricMetadata{
+		MetricFamilyName: metricFamilyName,
+		Type:             typ,
+		Help:             help,
+		Unit:             unit,
+	})
+}
+
 // AddLabelBytes adds (name, value) label to ctx.Labels.
 //
 // name and value must exist until ctx.Labels is used.
@@ -153,6 +214,12 @@ func (ctx *InsertCtx) FlushBufs() error {
 	// since the number of concurrent FlushBufs() calls should be already limited via writeconcurrencylimiter
 	// used at every stream.Parse() call under lib/protoparser/*
 	err := vmstorage.AddRows(ctx.mrs)
+	if err == nil && len(ctx.ers) > 0 {
+		err = vmstorage.AddExemplars(ctx.ers)
+	}
+	if err == nil && len(ctx.mms) > 0 {
+		err = vmstorage.AddMetricMetadata(ctx.mms)
+	}
 	ctx.Reset(0)
 	if err == nil {
 		return nil
@@ -10,6 +10,7 @@ import (
 
 	"github.com/VictoriaMetrics/metrics"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/arrowimport"
 	vminsertCommon "github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/csvimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/datadogsketches"
@@ -22,6 +23,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentelemetry"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentsdb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/opentsdbhttp"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/parquetimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/prometheusimport"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/prompush"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/promremotewrite"
@@ -169,7 +171,7 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		prometheusWriteRequests.Inc()
-		if err := promremotewrite.InsertHandler(r); err != nil {
+		if err := promremotewrite.InsertHandler(w, r); err != nil {
 			prometheusWriteErrors.Inc()
 			httpserver.Errorf(w, r, "%s", err)
 			return true
@@ -203,6 +205,24 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		}
 		w.WriteHeader(http.StatusNoContent)
 		return true
+	case "/prometheus/api/v1/import/parquet", "/api/v1/import/parquet":
+		parquetimportRequests.Inc()
+		if err := parquetimport.InsertHandler(r); err != nil {
+			parquetimportErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	case "/prometheus/api/v1/import/arrow", "/api/v1/import/arrow":
+		arrowimportRequests.Inc()
+		if err := arrowimport.InsertHandler(r); err != nil {
+			arrowimportErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
 	case "/influx/write", "/influx/api/v2/write", "/write", "/api/v2/write":
 		influxWriteRequests.Inc()
 		addInfluxResponseHeaders(w)
@@ -331,6 +351,14 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/prometheus/target_history", "/target_history":
+		promscrapeTargetHistoryRequests.Inc()
+		if err := promscrape.WriteTargetHistory(w, r); err != nil {
+			promscrapeTargetHistoryErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/prometheus/config", "/config":
 		if !httpserver.CheckAuthFlag(w, r, configAuthKey) {
 			return true
@@ -398,6 +426,12 @@ var (
 	nativeimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import/native", protocol="nativeimport"}`)
 	nativeimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import/native", protocol="nativeimport"}`)
 
+	parquetimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import/parquet", protocol="parquetimport"}`)
+	parquetimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import/parquet", protocol="parquetimport"}`)
+
+	arrowimportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/import/arrow", protocol="arrowimport"}`)
+	arrowimportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/import/arrow", protocol="arrowimport"}`)
+
 	influxWriteRequests = metrics.NewCounter(`vm_http_requests_total{path="/influx/write", protocol="influx"}`)
 	influxWriteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/influx/write", protocol="influx"}`)
 
@@ -434,6 +468,8 @@ var (
 
 	promscrapeTargetResponseRequests = metrics.NewCounter(`vm_http_requests_total{path="/target_response"}`)
 	promscrapeTargetResponseErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/target_response"}`)
+	promscrapeTargetHistoryRequests  = metrics.NewCounter(`vm_http_requests_total{path="/target_history"}`)
+	promscrapeTargetHistoryErrors    = metrics.NewCounter(`vm_http_request_errors_total{path="/target_history"}`)
 
 	promscrapeConfigRequests       = metrics.NewCounter(`vm_http_requests_total{path="/config"}`)
 	promscrapeStatusConfigRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/config"}`)
@@ -33,12 +33,12 @@ func InsertHandler(req *http.Request) error {
 			return fmt.Errorf("json encoding isn't supported for opentelemetry format. Use protobuf encoding")
 		}
 	}
-	return stream.ParseStream(req.Body, isGzipped, processBody, func(tss []prompbmarshal.TimeSeries) error {
-		return insertRows(tss, extraLabels)
+	return stream.ParseStream(req.Body, isGzipped, processBody, func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error {
+		return insertRows(tss, mms, extraLabels)
 	})
 }
 
-func insertRows(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -77,6 +77,17 @@ func insertRows(tss []prompbmarshal.TimeSeries, extraLabels []prompbmarshal.Labe
 				return err
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for _, label := range e.Labels {
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			metricNameRaw = ctx.WriteExemplarExt(metricNameRaw, ctx.Labels, e.Timestamp, e.Value)
+		}
+	}
+	for i := range mms {
+		mm := &mms[i]
+		ctx.AddMetricMetadata(mm.MetricFamilyName, mm.Type.String(), mm.Help, mm.Unit)
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
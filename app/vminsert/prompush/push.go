@@ -40,6 +40,20 @@ func Push(wr *prompbmarshal.WriteRequest) {
 		}
 		push(ctx, tssBlock)
 	}
+	if len(wr.Metadata) > 0 {
+		pushMetadata(ctx, wr.Metadata)
+	}
+}
+
+func pushMetadata(ctx *common.InsertCtx, mms []prompbmarshal.MetricMetadata) {
+	ctx.Reset(0)
+	for i := range mms {
+		mm := &mms[i]
+		ctx.AddMetricMetadata(mm.MetricFamilyName, mm.Type.String(), mm.Help, mm.Unit)
+	}
+	if err := ctx.FlushBufs(); err != nil {
+		logger.Errorf("cannot flush promscrape metadata to storage: %s", err)
+	}
 }
 
 func push(ctx *common.InsertCtx, tss []prompbmarshal.TimeSeries) {
@@ -73,6 +87,13 @@ func push(ctx *common.InsertCtx, tss []prompbmarshal.TimeSeries) {
 				return
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for _, label := range e.Labels {
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			metricNameRaw = ctx.WriteExemplarExt(metricNameRaw, ctx.Labels, e.Timestamp, e.Value)
+		}
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
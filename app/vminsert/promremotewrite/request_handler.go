@@ -5,6 +5,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/common"
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vminsert/relabel"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
 	parserCommon "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
@@ -18,18 +19,35 @@ var (
 )
 
 // InsertHandler processes remote write for prometheus.
-func InsertHandler(req *http.Request) error {
+//
+// Both Prometheus Remote Write 1.0 and 2.0 requests are accepted. Remote Write 2.0 response headers are set to w for Remote Write 2.0 requests.
+func InsertHandler(w http.ResponseWriter, req *http.Request) error {
 	extraLabels, err := parserCommon.GetExtraLabels(req)
 	if err != nil {
 		return err
 	}
+	contentType := req.Header.Get("Content-Type")
+	isRemoteWriteV2, err := stream.IsRemoteWriteV2(contentType)
+	if err != nil {
+		return &httpserver.ErrorWithStatusCode{
+			Err:        err,
+			StatusCode: http.StatusUnsupportedMediaType,
+		}
+	}
 	isVMRemoteWrite := req.Header.Get("Content-Encoding") == "zstd"
-	return stream.Parse(req.Body, isVMRemoteWrite, func(tss []prompb.TimeSeries) error {
-		return insertRows(tss, extraLabels)
+	ws, err := stream.Parse(req.Body, isVMRemoteWrite, contentType, func(tss []prompb.TimeSeries, mms []prompb.MetricMetadata) error {
+		return insertRows(tss, mms, extraLabels)
 	})
+	if err != nil {
+		return err
+	}
+	if isRemoteWriteV2 {
+		ws.SetResponseHeaders(w.Header())
+	}
+	return nil
 }
 
-func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Label) error {
+func insertRows(timeseries []prompb.TimeSeries, mms []prompb.MetricMetadata, extraLabels []prompbmarshal.Label) error {
 	ctx := common.GetInsertCtx()
 	defer common.PutInsertCtx(ctx)
 
@@ -70,6 +88,17 @@ func insertRows(timeseries []prompb.TimeSeries, extraLabels []prompbmarshal.Labe
 				return err
 			}
 		}
+		for i := range ts.Exemplars {
+			e := &ts.Exemplars[i]
+			for _, label := range e.Labels {
+				ctx.AddExemplarLabel(label.Name, label.Value)
+			}
+			metricNameRaw = ctx.WriteExemplarExt(metricNameRaw, ctx.Labels, e.Timestamp, e.Value)
+		}
+	}
+	for i := range mms {
+		mm := &mms[i]
+		ctx.AddMetricMetadata(mm.MetricFamilyName, prompbmarshal.MetricMetadataType(mm.Type).String(), mm.Help, mm.Unit)
 	}
 	rowsInserted.Add(rowsTotal)
 	rowsPerInsert.Update(float64(rowsTotal))
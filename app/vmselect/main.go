@@ -35,7 +35,8 @@ var (
 		"See also -search.maxQueueDuration and -search.maxMemoryPerQuery")
 	maxQueueDuration = flag.Duration("search.maxQueueDuration", 10*time.Second, "The maximum time the request waits for execution when -search.maxConcurrentRequests "+
 		"limit is reached; see also -search.maxQueryDuration")
-	resetCacheAuthKey    = flagutil.NewPassword("search.resetCacheAuthKey", "Optional authKey for resetting rollup cache via /internal/resetRollupResultCache call. It overrides -httpAuth.*")
+	resetCacheAuthKey = flagutil.NewPassword("search.resetCacheAuthKey", "Optional authKey for resetting rollup cache via /internal/resetRollupResultCache call "+
+		"and for pre-warming it via /internal/prewarmRollupResultCache call. It overrides -httpAuth.*")
 	logSlowQueryDuration = flag.Duration("search.logSlowQueryDuration", 5*time.Second, "Log queries with execution time exceeding this value. Zero disables slow query logging. "+
 		"See also -search.logQueryMemoryUsage")
 	vmalertProxyURL = flag.String("vmalert.proxyURL", "", "Optional URL for proxying requests to vmalert. For example, if -vmalert.proxyURL=http://vmalert:8880 , then alerting API requests such as /api/v1/rules from Grafana will be proxied to http://vmalert:8880/api/v1/rules")
@@ -61,6 +62,7 @@ func Init() {
 	netstorage.InitTmpBlocksDir(tmpDirPath)
 	promql.InitRollupResultCache(*vmstorage.DataPath + "/cache/rollupResult")
 	prometheus.InitMaxUniqueTimeseries(*maxConcurrentRequests)
+	promql.InitUDF()
 
 	concurrencyLimitCh = make(chan struct{}, *maxConcurrentRequests)
 	initVMAlertProxy()
@@ -178,6 +180,18 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 		promql.ResetRollupResultCache()
 		return true
 	}
+	if path == "/internal/prewarmRollupResultCache" {
+		if !httpserver.CheckAuthFlag(w, r, resetCacheAuthKey) {
+			return true
+		}
+		prewarmRollupResultCacheRequests.Inc()
+		if err := prometheus.PrewarmRollupResultCacheHandler(qt, startTime, w, r); err != nil {
+			prewarmRollupResultCacheErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	}
 
 	if strings.HasPrefix(path, "/api/v1/label/") {
 		s := path[len("/api/v1/label/"):]
@@ -223,6 +237,15 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/query_explain":
+		queryExplainRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.QueryExplainHandler(qt, startTime, w, r); err != nil {
+			queryExplainErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/series":
 		seriesRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -232,6 +255,24 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/metadata":
+		metadataRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.MetadataHandler(qt, startTime, w, r); err != nil {
+			metadataErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/query_exemplars":
+		queryExemplarsRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.QueryExemplarsHandler(qt, startTime, w, r); err != nil {
+			queryExemplarsErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/api/v1/series/count":
 		seriesCountRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -259,6 +300,32 @@ func RequestHandler(w http.ResponseWriter, r *http.Request) bool {
 			return true
 		}
 		return true
+	case "/api/v1/status/metric_names_stats":
+		statusMetricNamesStatsRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.MetricNamesStatsHandler(qt, startTime, w, r); err != nil {
+			statusMetricNamesStatsErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/admin/status/metric_names_stats/reset":
+		resetMetricNamesStatsRequests.Inc()
+		if err := prometheus.ResetMetricNamesStatsHandler(qt); err != nil {
+			resetMetricNamesStatsErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	case "/api/v1/read":
+		remoteReadRequests.Inc()
+		if err := prometheus.RemoteReadHandler(startTime, w, r); err != nil {
+			remoteReadErrors.Inc()
+			httpserver.Errorf(w, r, "%s", err)
+			return true
+		}
+		return true
 	case "/api/v1/export":
 		exportRequests.Inc()
 		if err := prometheus.ExportHandler(startTime, w, r); err != nil {
@@ -493,6 +560,11 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		httpserver.EnableCORS(w, r)
 		promql.ActiveQueriesHandler(w, r)
 		return true
+	case "/api/v1/status/udf":
+		statusUDFRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		promql.UDFHandler(w, r)
+		return true
 	case "/api/v1/status/top_queries":
 		topQueriesRequests.Inc()
 		httpserver.EnableCORS(w, r)
@@ -510,6 +582,33 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		promscrapeTargetRelabelDebugRequests.Inc()
 		promscrape.WriteTargetRelabelDebug(w, r)
 		return true
+	case "/api/v1/format_query":
+		formatQueryRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.FormatQueryHandler(w, r); err != nil {
+			formatQueryErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/parse_query":
+		parseQueryRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.ParseQueryHandler(w, r); err != nil {
+			parseQueryErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
+	case "/api/v1/format_ast":
+		formatASTRequests.Inc()
+		httpserver.EnableCORS(w, r)
+		if err := prometheus.FormatASTHandler(w, r); err != nil {
+			formatASTErrors.Inc()
+			httpserver.SendPrometheusError(w, r, err)
+			return true
+		}
+		return true
 	case "/expand-with-exprs":
 		expandWithExprsRequests.Inc()
 		prometheus.ExpandWithExprs(w, r)
@@ -538,12 +637,6 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		w.Header().Set("Content-Type", "application/json")
 		fmt.Fprint(w, `{"status":"success","data":{"alerts":[]}}`)
 		return true
-	case "/api/v1/metadata":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
-		metadataRequests.Inc()
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":{}}`)
-		return true
 	case "/api/v1/status/buildinfo":
 		buildInfoRequests.Inc()
 		w.Header().Set("Content-Type", "application/json")
@@ -552,12 +645,6 @@ func handleStaticAndSimpleRequests(w http.ResponseWriter, r *http.Request, path
 		// see this issue for more info: https://github.com/VictoriaMetrics/VictoriaMetrics/issues/5370
 		fmt.Fprintf(w, "%s", `{"status":"success","data":{"version":"2.24.0"}}`)
 		return true
-	case "/api/v1/query_exemplars":
-		// Return dumb placeholder for https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
-		queryExemplarsRequests.Inc()
-		w.Header().Set("Content-Type", "application/json")
-		fmt.Fprintf(w, "%s", `{"status":"success","data":[]}`)
-		return true
 	default:
 		return false
 	}
@@ -587,6 +674,9 @@ var (
 	queryRangeRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_range"}`)
 	queryRangeErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_range"}`)
 
+	queryExplainRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_explain"}`)
+	queryExplainErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_explain"}`)
+
 	seriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/series"}`)
 	seriesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/series"}`)
 
@@ -599,7 +689,14 @@ var (
 	statusTSDBRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/tsdb"}`)
 	statusTSDBErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/tsdb"}`)
 
+	statusMetricNamesStatsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/metric_names_stats"}`)
+	statusMetricNamesStatsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/metric_names_stats"}`)
+
+	resetMetricNamesStatsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/admin/status/metric_names_stats/reset"}`)
+	resetMetricNamesStatsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/admin/status/metric_names_stats/reset"}`)
+
 	statusActiveQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/active_queries"}`)
+	statusUDFRequests           = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/udf"}`)
 
 	topQueriesRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/status/top_queries"}`)
 	topQueriesErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/status/top_queries"}`)
@@ -607,6 +704,12 @@ var (
 	deleteRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/admin/tsdb/delete_series"}`)
 	deleteErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/admin/tsdb/delete_series"}`)
 
+	remoteReadRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/read"}`)
+	remoteReadErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/read"}`)
+
+	prewarmRollupResultCacheRequests = metrics.NewCounter(`vm_http_requests_total{path="/internal/prewarmRollupResultCache"}`)
+	prewarmRollupResultCacheErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/internal/prewarmRollupResultCache"}`)
+
 	exportRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/export"}`)
 	exportErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/export"}`)
 
@@ -667,13 +770,22 @@ var (
 	expandWithExprsRequests = metrics.NewCounter(`vm_http_requests_total{path="/expand-with-exprs"}`)
 	prettifyQueryRequests   = metrics.NewCounter(`vm_http_requests_total{path="/prettify-query"}`)
 
+	formatQueryRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/format_query"}`)
+	formatQueryErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/format_query"}`)
+	parseQueryRequests  = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/parse_query"}`)
+	parseQueryErrors    = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/parse_query"}`)
+	formatASTRequests   = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/format_ast"}`)
+	formatASTErrors     = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/format_ast"}`)
+
 	vmalertRequests = metrics.NewCounter(`vm_http_requests_total{path="/vmalert"}`)
 	rulesRequests   = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/rules"}`)
 	alertsRequests  = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/alerts"}`)
 
 	metadataRequests       = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/metadata"}`)
+	metadataErrors         = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/metadata"}`)
 	buildInfoRequests      = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/buildinfo"}`)
 	queryExemplarsRequests = metrics.NewCounter(`vm_http_requests_total{path="/api/v1/query_exemplars"}`)
+	queryExemplarsErrors   = metrics.NewCounter(`vm_http_request_errors_total{path="/api/v1/query_exemplars"}`)
 )
 
 func proxyVMAlertRequests(w http.ResponseWriter, r *http.Request) {
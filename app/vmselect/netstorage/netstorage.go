@@ -5,6 +5,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"net/http"
 	"sort"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,7 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/cgroup"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
@@ -64,6 +66,8 @@ type Results struct {
 	packedTimeseries []packedTimeseries
 	sr               *storage.Search
 	tbf              *tmpBlocksFile
+
+	memorySize int
 }
 
 // Len returns the number of results in rss.
@@ -71,6 +75,38 @@ func (rss *Results) Len() int {
 	return len(rss.packedTimeseries)
 }
 
+// MemorySize returns the estimated size in bytes of memory occupied by rss.
+//
+// It includes the in-memory part of temporary blocks file, metric names and block references for the selected series.
+func (rss *Results) MemorySize() int {
+	return rss.memorySize
+}
+
+// LabelNames returns label names for the series in rss.
+//
+// The returned label names include __name__ if at least a single series in rss has non-empty metric name.
+func (rss *Results) LabelNames() ([]string, error) {
+	m := make(map[string]struct{})
+	var mn storage.MetricName
+	for i := range rss.packedTimeseries {
+		if err := mn.Unmarshal(bytesutil.ToUnsafeBytes(rss.packedTimeseries[i].metricName)); err != nil {
+			return nil, fmt.Errorf("cannot unmarshal metricName: %w", err)
+		}
+		if len(mn.MetricGroup) > 0 {
+			m["__name__"] = struct{}{}
+		}
+		for j := range mn.Tags {
+			m[string(mn.Tags[j].Key)] = struct{}{}
+		}
+	}
+	labelNames := make([]string, 0, len(m))
+	for name := range m {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	return labelNames, nil
+}
+
 // Cancel cancels rss work.
 func (rss *Results) Cancel() {
 	rss.mustClose()
@@ -251,22 +287,7 @@ func (rss *Results) runParallel(qt *querytracer.Tracer, f func(rs *Result, worke
 	maxWorkers := MaxWorkers()
 	if maxWorkers == 1 || tswsLen == 1 {
 		// It is faster to process time series in the current goroutine.
-		var tsw timeseriesWork
-		tmpResult := getTmpResult()
-		rowsProcessedTotal := 0
-		var err error
-		for i := range rss.packedTimeseries {
-			initTimeseriesWork(&tsw, &rss.packedTimeseries[i])
-			err = tsw.do(&tmpResult.rs, 0)
-			rowsReadPerSeries.Update(float64(tsw.rowsProcessed))
-			rowsProcessedTotal += tsw.rowsProcessed
-			if err != nil {
-				break
-			}
-		}
-		putTmpResult(tmpResult)
-
-		return rowsProcessedTotal, err
+		return rss.runSequential(f)
 	}
 
 	// Slow path - spin up multiple local workers for parallel data processing.
@@ -328,6 +349,55 @@ func (rss *Results) runParallel(qt *querytracer.Tracer, f func(rs *Result, worke
 	return rowsProcessedTotal, firstErr
 }
 
+// RunSequential calls f for all the results from rss in the current goroutine.
+//
+// Results are passed to f in the order they are stored in the storage.
+// f shouldn't hold references to rs after returning.
+// Data processing is immediately stopped if f returns non-nil error.
+//
+// rss becomes unusable after the call to RunSequential.
+func (rss *Results) RunSequential(qt *querytracer.Tracer, f func(rs *Result) error) error {
+	qt = qt.NewChild("sequential process of fetched data")
+	defer rss.mustClose()
+
+	rowsProcessedTotal, err := rss.runSequential(func(rs *Result, _ uint) error {
+		return f(rs)
+	})
+	seriesProcessedTotal := len(rss.packedTimeseries)
+	rss.packedTimeseries = rss.packedTimeseries[:0]
+
+	rowsReadPerQuery.Update(float64(rowsProcessedTotal))
+	seriesReadPerQuery.Update(float64(seriesProcessedTotal))
+
+	qt.Donef("series=%d, samples=%d", seriesProcessedTotal, rowsProcessedTotal)
+
+	return err
+}
+
+func (rss *Results) runSequential(f func(rs *Result, workerID uint) error) (int, error) {
+	var mustStop atomic.Bool
+	tsw := timeseriesWork{
+		rss:      rss,
+		f:        f,
+		mustStop: &mustStop,
+	}
+	tmpResult := getTmpResult()
+	rowsProcessedTotal := 0
+	var err error
+	for i := range rss.packedTimeseries {
+		tsw.pts = &rss.packedTimeseries[i]
+		err = tsw.do(&tmpResult.rs, 0)
+		rowsReadPerSeries.Update(float64(tsw.rowsProcessed))
+		rowsProcessedTotal += tsw.rowsProcessed
+		if err != nil {
+			break
+		}
+	}
+	putTmpResult(tmpResult)
+
+	return rowsProcessedTotal, err
+}
+
 var (
 	rowsReadPerSeries  = metrics.NewHistogram(`vm_rows_read_per_series`)
 	rowsReadPerQuery   = metrics.NewHistogram(`vm_rows_read_per_query`)
@@ -622,11 +692,21 @@ func mergeSortBlocks(dst *Result, sbh *sortBlocksHeap, dedupInterval int64) {
 	timestamps, values := storage.DeduplicateSamples(dst.Timestamps, dst.Values, dedupInterval)
 	dedups := len(dst.Timestamps) - len(timestamps)
 	dedupsDuringSelect.Add(dedups)
+
+	// Apply downsampling to samples, which weren't downsampled yet by background merges,
+	// so queries over downsampled time ranges return consistent results.
+	n := len(timestamps)
+	timestamps, values = storage.DownsampleSamples(timestamps, values, int64(fasttime.UnixTimestamp())*1000)
+	downsampledSamplesDuringSelect.Add(n - len(timestamps))
+
 	dst.Timestamps = timestamps
 	dst.Values = values
 }
 
-var dedupsDuringSelect = metrics.NewCounter(`vm_deduplicated_samples_total{type="select"}`)
+var (
+	dedupsDuringSelect             = metrics.NewCounter(`vm_deduplicated_samples_total{type="select"}`)
+	downsampledSamplesDuringSelect = metrics.NewCounter(`vm_downsampled_samples_total{type="select"}`)
+)
 
 func equalSamplesPrefix(a, b *sortBlock) int {
 	n := equalTimestampsPrefix(a.Timestamps[a.NextIdx:], b.Timestamps[b.NextIdx:])
@@ -777,6 +857,20 @@ func DeleteSeries(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline sear
 	return vmstorage.DeleteSeries(qt, tfss, sq.MaxMetrics)
 }
 
+// DeleteSeriesOnTimeRange deletes samples on the time range from sq for time series matching sq.
+//
+// The deleted samples are hidden from search immediately and are physically removed during background merges.
+func DeleteSeriesOnTimeRange(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline) (int, error) {
+	qt = qt.NewChild("delete series on time range: %s", sq)
+	defer qt.Done()
+	tr := sq.GetTimeRange()
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return 0, err
+	}
+	return vmstorage.DeleteSeriesOnTimeRange(qt, tfss, tr, sq.MaxMetrics)
+}
+
 // LabelNames returns label names matching the given sq until the given deadline.
 func LabelNames(qt *querytracer.Tracer, sq *storage.SearchQuery, maxLabelNames int, deadline searchutils.Deadline) ([]string, error) {
 	qt = qt.NewChild("get labels: %s", sq)
@@ -926,8 +1020,10 @@ func TagValueSuffixes(qt *querytracer.Tracer, tr storage.TimeRange, tagKey, tagV
 
 // TSDBStatus returns tsdb status according to https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 //
-// It accepts arbitrary filters on time series in sq.
-func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel string, topN int, deadline searchutils.Deadline) (*storage.TSDBStatus, error) {
+// It accepts arbitrary filters on time series in sq. The stats are collected over all the days in the sq time range.
+// The days outside the retention are skipped. An error with 400 status code is returned if the remaining time range
+// contains more than maxDays days. The number of days isn't limited if maxDays <= 0.
+func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel string, topN, maxDays int, deadline searchutils.Deadline) (*storage.TSDBStatus, error) {
 	qt = qt.NewChild("get tsdb stats: %s, focusLabel=%q, topN=%d", sq, focusLabel, topN)
 	defer qt.Done()
 	if deadline.Exceeded() {
@@ -938,8 +1034,21 @@ func TSDBStatus(qt *querytracer.Tracer, sq *storage.SearchQuery, focusLabel stri
 	if err != nil {
 		return nil, err
 	}
-	date := uint64(tr.MinTimestamp) / (3600 * 24 * 1000)
-	status, err := vmstorage.GetTSDBStatus(qt, tfss, date, focusLabel, topN, sq.MaxMetrics, deadline.Deadline())
+	minDate := uint64(tr.MinTimestamp) / (3600 * 24 * 1000)
+	maxDate := uint64(tr.MaxTimestamp) / (3600 * 24 * 1000)
+	if maxDays > 0 && maxDate > 0 {
+		// Zero maxDate means the global stats, which do not depend on the number of days.
+		// The days outside the retention aren't taken into account, since they contain no data.
+		minRetentionDate := max(minDate, vmstorage.GetMinRetentionDate())
+		if maxDate >= minRetentionDate && maxDate-minRetentionDate+1 > uint64(maxDays) {
+			return nil, &httpserver.ErrorWithStatusCode{
+				Err: fmt.Errorf("the time range %s contains %d days within the retention, which exceeds -search.maxTSDBStatusDays=%d; "+
+					"either narrow down the time range or increase -search.maxTSDBStatusDays", &tr, maxDate-minRetentionDate+1, maxDays),
+				StatusCode: http.StatusBadRequest,
+			}
+		}
+	}
+	status, err := vmstorage.GetTSDBStatusForDateRange(qt, tfss, minDate, maxDate, focusLabel, topN, sq.MaxMetrics, deadline.Deadline())
 	if err != nil {
 		return nil, fmt.Errorf("error during tsdb status request: %w", err)
 	}
@@ -1118,10 +1227,96 @@ func SearchMetricNames(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline
 	return metricNames, nil
 }
 
+// EstimateSeries returns the estimated costs for searching series matching the given sq until the given deadline.
+//
+// Data blocks aren't read during the estimation.
+func EstimateSeries(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline) (*storage.SeriesEstimate, error) {
+	qt = qt.NewChild("estimate series: %s", sq)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to estimate series: %s", deadline.String())
+	}
+
+	// Setup search.
+	tr := sq.GetTimeRange()
+	if err := vmstorage.CheckTimeRange(tr); err != nil {
+		return nil, err
+	}
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	se, err := vmstorage.EstimateSeries(qt, tfss, tr, sq.MaxMetrics, deadline.Deadline())
+	if err != nil {
+		return nil, fmt.Errorf("cannot estimate series: %w", err)
+	}
+	return se, nil
+}
+
+// SearchExemplars returns exemplars for series matching the given sq until the given deadline.
+func SearchExemplars(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline) ([]storage.SeriesExemplars, error) {
+	qt = qt.NewChild("fetch exemplars: %s", sq)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to search exemplars: %s", deadline.String())
+	}
+
+	// Setup search.
+	tr := sq.GetTimeRange()
+	tfss, err := setupTfss(qt, tr, sq.TagFilterss, sq.MaxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := vmstorage.SearchExemplars(qt, tfss, tr, sq.MaxMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find exemplars: %w", err)
+	}
+	return result, nil
+}
+
+// SearchMetricMetadata returns metric metadata for the given metricFamilyName until the given deadline.
+//
+// Metadata for all the metric families is returned if metricFamilyName is empty.
+func SearchMetricMetadata(qt *querytracer.Tracer, metricFamilyName string, limit, limitPerMetric int, deadline searchutils.Deadline) ([]storage.MetricMetadata, error) {
+	qt = qt.NewChild("fetch metric metadata: metric=%q", metricFamilyName)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to search metric metadata: %s", deadline.String())
+	}
+	return vmstorage.SearchMetricMetadata(qt, metricFamilyName, limit, limitPerMetric), nil
+}
+
+// GetMetricNamesStats returns usage stats for metric names until the given deadline.
+func GetMetricNamesStats(qt *querytracer.Tracer, limit, le int, matchPattern string, deadline searchutils.Deadline) (*storage.MetricNamesStatsResult, error) {
+	qt = qt.NewChild("get metric names stats: limit=%d, le=%d, matchPattern=%q", limit, le, matchPattern)
+	defer qt.Done()
+	if deadline.Exceeded() {
+		return nil, fmt.Errorf("timeout exceeded before starting to get metric names stats: %s", deadline.String())
+	}
+	return vmstorage.GetMetricNamesStats(qt, limit, le, matchPattern)
+}
+
+// ResetMetricNamesStats resets usage stats for metric names.
+func ResetMetricNamesStats(qt *querytracer.Tracer) error {
+	return vmstorage.ResetMetricNamesStats(qt)
+}
+
 // ProcessSearchQuery performs sq until the given deadline.
 //
 // Results.RunParallel or Results.Cancel must be called on the returned Results.
 func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline) (*Results, error) {
+	return ProcessSearchQueryWithSeriesCheck(qt, sq, deadline, nil)
+}
+
+// ProcessSearchQueryWithSeriesCheck performs sq until the given deadline.
+//
+// checkSeries is called with the number of series matching sq after the index search and before reading blocks for these series.
+// The query processing is stopped if checkSeries returns non-nil error. checkSeries may be nil.
+//
+// Results.RunParallel or Results.Cancel must be called on the returned Results.
+func ProcessSearchQueryWithSeriesCheck(qt *querytracer.Tracer, sq *storage.SearchQuery, deadline searchutils.Deadline, checkSeries func(seriesCount int) error) (*Results, error) {
 	qt = qt.NewChild("fetch matching series: %s", sq)
 	defer qt.Done()
 	if deadline.Exceeded() {
@@ -1145,6 +1340,15 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	startTime := time.Now()
 	maxSeriesCount := sr.Init(qt, vmstorage.Storage, tfss, tr, sq.MaxMetrics, deadline.Deadline())
 	indexSearchDuration.UpdateDuration(startTime)
+	if sq.TrackMetricNamesStats {
+		sr.TrackMetricNamesStats()
+	}
+	if checkSeries != nil {
+		if err := checkSeries(maxSeriesCount); err != nil {
+			putStorageSearch(sr)
+			return nil, err
+		}
+	}
 	type blockRefs struct {
 		brs []blockRef
 	}
@@ -1163,6 +1367,7 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 		metricNamesBufCap = maxFastAllocBlockSize
 	}
 	metricNamesBuf := make([]byte, 0, metricNamesBufCap)
+	metricNamesSize := 0
 
 	// brssPool is used for holding all the blockRefs objects across all the loaded time series.
 	// It should reduce pressure on Go GC by reducing the number of blockRefs allocations.
@@ -1258,6 +1463,7 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 			}
 			metricNamesBufLen := len(metricNamesBuf)
 			metricNamesBuf = append(metricNamesBuf, metricName...)
+			metricNamesSize += len(metricName)
 			metricNameStr := bytesutil.ToUnsafeString(metricNamesBuf[metricNamesBufLen:])
 
 			orderedMetricNames = append(orderedMetricNames, metricNameStr)
@@ -1293,6 +1499,8 @@ func ProcessSearchQuery(qt *querytracer.Tracer, sq *storage.SearchQuery, deadlin
 	rss.packedTimeseries = pts
 	rss.sr = sr
 	rss.tbf = tbf
+	rss.memorySize = tbf.InmemorySize() + metricNamesSize + blocksRead*int(unsafe.Sizeof(blockRef{})) +
+		len(pts)*int(unsafe.Sizeof(packedTimeseries{})+unsafe.Sizeof(blockRefs{}))
 	return &rss, nil
 }
 
@@ -3,6 +3,8 @@ package netstorage
 import (
 	"reflect"
 	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
 func TestMergeSortBlocks(t *testing.T) {
@@ -194,3 +196,48 @@ func TestMergeSortBlocks(t *testing.T) {
 		Values:     []float64{7, 24, 26},
 	})
 }
+
+func TestResultsLabelNames(t *testing.T) {
+	f := func(metricNames []*storage.MetricName, labelNamesExpected []string) {
+		t.Helper()
+
+		var rss Results
+		for _, mn := range metricNames {
+			rss.packedTimeseries = append(rss.packedTimeseries, packedTimeseries{
+				metricName: string(mn.Marshal(nil)),
+			})
+		}
+		labelNames, err := rss.LabelNames()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(labelNames, labelNamesExpected) {
+			t.Fatalf("unexpected label names; got %q; want %q", labelNames, labelNamesExpected)
+		}
+	}
+
+	newMetricName := func(name string, tags ...string) *storage.MetricName {
+		mn := &storage.MetricName{
+			MetricGroup: []byte(name),
+		}
+		for i := 0; i < len(tags); i += 2 {
+			mn.AddTag(tags[i], tags[i+1])
+		}
+		return mn
+	}
+
+	// no series
+	f(nil, []string{})
+
+	// series without metric names
+	f([]*storage.MetricName{
+		newMetricName("", "job", "foo"),
+	}, []string{"job"})
+
+	// series with distinct labels
+	f([]*storage.MetricName{
+		newMetricName("foo", "job", "a", "instance", "b"),
+		newMetricName("bar", "job", "c", "env", "prod"),
+		newMetricName(""),
+	}, []string{"__name__", "env", "instance", "job"})
+}
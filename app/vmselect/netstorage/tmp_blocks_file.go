@@ -140,6 +140,11 @@ func (tbf *tmpBlocksFile) Len() uint64 {
 	return tbf.offset
 }
 
+// InmemorySize returns the size in bytes of the in-memory part of tbf.
+func (tbf *tmpBlocksFile) InmemorySize() int {
+	return cap(tbf.buf)
+}
+
 func (tbf *tmpBlocksFile) Finalize() error {
 	if tbf.f == nil {
 		return nil
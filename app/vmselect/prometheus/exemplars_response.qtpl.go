@@ -0,0 +1,134 @@
+// Code generated by qtc from "exemplars_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// ExemplarsResponse generates response for /api/v1/query_exemplars.See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:9
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:9
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:9
+func StreamExemplarsResponse(qw422016 *qt422016.Writer, result []storage.SeriesExemplars, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/exemplars_response.qtpl:9
+	qw422016.N().S(`{"status":"success","data":[`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:13
+	for i := range result {
+//line app/vmselect/prometheus/exemplars_response.qtpl:14
+		se := &result[i]
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:14
+		qw422016.N().S(`{"seriesLabels":`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:16
+		streammetricNameObject(qw422016, &se.MetricName)
+//line app/vmselect/prometheus/exemplars_response.qtpl:16
+		qw422016.N().S(`,"exemplars":[`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:18
+		for j := range se.Exemplars {
+//line app/vmselect/prometheus/exemplars_response.qtpl:19
+			e := &se.Exemplars[j]
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:19
+			qw422016.N().S(`{"labels":{`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:22
+			for k := range e.Labels {
+//line app/vmselect/prometheus/exemplars_response.qtpl:23
+				tag := &e.Labels[k]
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+				qw422016.N().QZ(tag.Key)
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+				qw422016.N().S(`:`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+				qw422016.N().QZ(tag.Value)
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+				if k+1 < len(e.Labels) {
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+					qw422016.N().S(`,`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:24
+				}
+//line app/vmselect/prometheus/exemplars_response.qtpl:25
+			}
+//line app/vmselect/prometheus/exemplars_response.qtpl:25
+			qw422016.N().S(`},"value":"`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:27
+			qw422016.N().F(e.Value)
+//line app/vmselect/prometheus/exemplars_response.qtpl:27
+			qw422016.N().S(`","timestamp":`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:28
+			qw422016.N().F(float64(e.Timestamp) / 1e3)
+//line app/vmselect/prometheus/exemplars_response.qtpl:28
+			qw422016.N().S(`}`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:30
+			if j+1 < len(se.Exemplars) {
+//line app/vmselect/prometheus/exemplars_response.qtpl:30
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:30
+			}
+//line app/vmselect/prometheus/exemplars_response.qtpl:31
+		}
+//line app/vmselect/prometheus/exemplars_response.qtpl:31
+		qw422016.N().S(`]}`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:34
+		if i+1 < len(result) {
+//line app/vmselect/prometheus/exemplars_response.qtpl:34
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:34
+		}
+//line app/vmselect/prometheus/exemplars_response.qtpl:35
+	}
+//line app/vmselect/prometheus/exemplars_response.qtpl:35
+	qw422016.N().S(`]`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:38
+	qt.Printf("generate response: series=%d", len(result))
+	qt.Done()
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:41
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/exemplars_response.qtpl:41
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+}
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+func WriteExemplarsResponse(qq422016 qtio422016.Writer, result []storage.SeriesExemplars, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	StreamExemplarsResponse(qw422016, result, qt)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+}
+
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+func ExemplarsResponse(result []storage.SeriesExemplars, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	WriteExemplarsResponse(qb422016, result, qt)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+	return qs422016
+//line app/vmselect/prometheus/exemplars_response.qtpl:43
+}
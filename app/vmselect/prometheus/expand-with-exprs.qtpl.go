@@ -1,196 +1,198 @@
 // Code generated by qtc from "expand-with-exprs.qtpl". DO NOT EDIT.
 // See https://github.com/valyala/quicktemplate for details.
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:1
+//line expand-with-exprs.qtpl:1
 package prometheus
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:1
+//line expand-with-exprs.qtpl:1
 import (
 	"fmt"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
 	"github.com/VictoriaMetrics/metricsql"
 )
 
 // ExpandWithExprsResponse returns a webpage, which expands with templates in q MetricsQL.
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:9
+//line expand-with-exprs.qtpl:11
 import (
 	qtio422016 "io"
 
 	qt422016 "github.com/valyala/quicktemplate"
 )
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:9
+//line expand-with-exprs.qtpl:11
 var (
 	_ = qtio422016.Copy
 	_ = qt422016.AcquireByteBuffer
 )
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:9
+//line expand-with-exprs.qtpl:11
 func StreamExpandWithExprsResponse(qw422016 *qt422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:9
+//line expand-with-exprs.qtpl:11
 	qw422016.N().S(`<html><head><title>Expand WITH expressions</title><style>p { font-weight: bold }textarea { margin: 1em }</style></head><body><div><form method="get"><div><p><a href="https://docs.victoriametrics.com/metricsql/">MetricsQL</a> query with optional WITH expressions:</p><textarea name="query" style="height: 15em; width: 90%">`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:26
+//line expand-with-exprs.qtpl:28
 	qw422016.E().S(q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:26
+//line expand-with-exprs.qtpl:28
 	qw422016.N().S(`</textarea><br/><input type="submit" value="Expand" /><p><a href="https://docs.victoriametrics.com/metricsql/">MetricsQL</a> query after expanding WITH expressions and applying other optimizations:</p><textarea style="height: 5em; width: 90%" readonly="readonly">`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:32
+//line expand-with-exprs.qtpl:34
 	streamexpandWithExprs(qw422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:32
+//line expand-with-exprs.qtpl:34
 	qw422016.N().S(`</textarea></div></form></div><div>`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:37
+//line expand-with-exprs.qtpl:39
 	streamwithExprsTutorial(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:37
+//line expand-with-exprs.qtpl:39
 	qw422016.N().S(`</div></body></html>`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 func WriteExpandWithExprsResponse(qq422016 qtio422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	StreamExpandWithExprsResponse(qw422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 func ExpandWithExprsResponse(q string) string {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	WriteExpandWithExprsResponse(qb422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 	return qs422016
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:41
+//line expand-with-exprs.qtpl:43
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:43
+//line expand-with-exprs.qtpl:45
 func streamexpandWithExprs(qw422016 *qt422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:44
+//line expand-with-exprs.qtpl:46
 	if len(q) == 0 {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:45
+//line expand-with-exprs.qtpl:47
 		return
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:46
+//line expand-with-exprs.qtpl:48
 	}
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:48
-	expr, err := metricsql.Parse(q)
+//line expand-with-exprs.qtpl:50
+	expr, err := promql.Parse(q)
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:49
+//line expand-with-exprs.qtpl:51
 	if err != nil {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:49
+//line expand-with-exprs.qtpl:51
 		qw422016.N().S(`Cannot parse query:`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:50
+//line expand-with-exprs.qtpl:52
 		qw422016.E().V(err)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:51
+//line expand-with-exprs.qtpl:53
 	} else {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:52
+//line expand-with-exprs.qtpl:54
 		expr = metricsql.Optimize(expr)
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:53
+//line expand-with-exprs.qtpl:55
 		qw422016.E().Z(expr.AppendString(nil))
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:54
+//line expand-with-exprs.qtpl:56
 	}
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 func writeexpandWithExprs(qq422016 qtio422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	streamexpandWithExprs(qw422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 func expandWithExprs(q string) string {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	writeexpandWithExprs(qb422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 	return qs422016
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:55
+//line expand-with-exprs.qtpl:57
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:57
+//line expand-with-exprs.qtpl:59
 func StreamExpandWithExprsJSONResponse(qw422016 *qt422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:58
+//line expand-with-exprs.qtpl:60
 	if len(q) == 0 {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:58
+//line expand-with-exprs.qtpl:60
 		qw422016.N().S(`{"status": "error","error": "query string cannot be empty"}`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:63
+//line expand-with-exprs.qtpl:65
 		return
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:64
+//line expand-with-exprs.qtpl:66
 	}
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:64
+//line expand-with-exprs.qtpl:66
 	qw422016.N().S(`{`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:67
-	expr, err := metricsql.Parse(q)
+//line expand-with-exprs.qtpl:69
+	expr, err := promql.Parse(q)
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:68
+//line expand-with-exprs.qtpl:70
 	if err != nil {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:68
+//line expand-with-exprs.qtpl:70
 		qw422016.N().S(`"status": "error","error":`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:70
+//line expand-with-exprs.qtpl:72
 		qw422016.N().Q(fmt.Sprintf("Cannot parse query: %s", err))
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:71
+//line expand-with-exprs.qtpl:73
 	} else {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:72
+//line expand-with-exprs.qtpl:74
 		expr = metricsql.Optimize(expr)
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:72
+//line expand-with-exprs.qtpl:74
 		qw422016.N().S(`"status": "success","expr":`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:74
+//line expand-with-exprs.qtpl:76
 		qw422016.N().QZ(expr.AppendString(nil))
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:75
+//line expand-with-exprs.qtpl:77
 	}
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:75
+//line expand-with-exprs.qtpl:77
 	qw422016.N().S(`}`)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 func WriteExpandWithExprsJSONResponse(qq422016 qtio422016.Writer, q string) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	StreamExpandWithExprsJSONResponse(qw422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 func ExpandWithExprsJSONResponse(q string) string {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	WriteExpandWithExprsJSONResponse(qb422016, q)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 	return qs422016
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:77
+//line expand-with-exprs.qtpl:79
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:81
+//line expand-with-exprs.qtpl:83
 func streamwithExprsTutorial(qw422016 *qt422016.Writer) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:81
+//line expand-with-exprs.qtpl:83
 	qw422016.N().S(`
 <h3>Tutorial for WITH expressions in <a href="https://docs.victoriametrics.com/metricsql/">MetricsQL</a></h3>
 
@@ -379,31 +381,31 @@ WITH (
 </pre>
 
 `)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 func writewithExprsTutorial(qq422016 qtio422016.Writer) {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	streamwithExprsTutorial(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 }
 
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 func withExprsTutorial() string {
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	writewithExprsTutorial(qb422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 	return qs422016
-//line app/vmselect/prometheus/expand-with-exprs.qtpl:268
+//line expand-with-exprs.qtpl:270
 }
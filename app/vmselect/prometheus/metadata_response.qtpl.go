@@ -0,0 +1,114 @@
+// Code generated by qtc from "metadata_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/metadata_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/metadata_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// MetadataResponse generates response for /api/v1/metadata.See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+
+//line app/vmselect/prometheus/metadata_response.qtpl:9
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/metadata_response.qtpl:9
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/metadata_response.qtpl:9
+func StreamMetadataResponse(qw422016 *qt422016.Writer, mms []storage.MetricMetadata, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metadata_response.qtpl:9
+	qw422016.N().S(`{"status":"success","data":{`)
+//line app/vmselect/prometheus/metadata_response.qtpl:13
+	for i := range mms {
+//line app/vmselect/prometheus/metadata_response.qtpl:14
+		mm := &mms[i]
+
+//line app/vmselect/prometheus/metadata_response.qtpl:15
+		if i == 0 || mm.MetricFamilyName != mms[i-1].MetricFamilyName {
+//line app/vmselect/prometheus/metadata_response.qtpl:16
+			if i > 0 {
+//line app/vmselect/prometheus/metadata_response.qtpl:16
+				qw422016.N().S(`],`)
+//line app/vmselect/prometheus/metadata_response.qtpl:16
+			}
+//line app/vmselect/prometheus/metadata_response.qtpl:17
+			qw422016.N().Q(mm.MetricFamilyName)
+//line app/vmselect/prometheus/metadata_response.qtpl:17
+			qw422016.N().S(`:[`)
+//line app/vmselect/prometheus/metadata_response.qtpl:18
+		} else {
+//line app/vmselect/prometheus/metadata_response.qtpl:18
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/metadata_response.qtpl:20
+		}
+//line app/vmselect/prometheus/metadata_response.qtpl:20
+		qw422016.N().S(`{"type":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:22
+		qw422016.N().Q(mm.Type)
+//line app/vmselect/prometheus/metadata_response.qtpl:22
+		qw422016.N().S(`,"help":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:23
+		qw422016.N().Q(mm.Help)
+//line app/vmselect/prometheus/metadata_response.qtpl:23
+		qw422016.N().S(`,"unit":`)
+//line app/vmselect/prometheus/metadata_response.qtpl:24
+		qw422016.N().Q(mm.Unit)
+//line app/vmselect/prometheus/metadata_response.qtpl:24
+		qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:26
+	}
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+	if len(mms) > 0 {
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+	}
+//line app/vmselect/prometheus/metadata_response.qtpl:27
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:30
+	qt.Printf("generate response: entries=%d", len(mms))
+	qt.Done()
+
+//line app/vmselect/prometheus/metadata_response.qtpl:33
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:33
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+}
+
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+func WriteMetadataResponse(qq422016 qtio422016.Writer, mms []storage.MetricMetadata, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	StreamMetadataResponse(qw422016, mms, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+}
+
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+func MetadataResponse(mms []storage.MetricMetadata, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	WriteMetadataResponse(qb422016, mms, qt)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+	return qs422016
+//line app/vmselect/prometheus/metadata_response.qtpl:35
+}
@@ -0,0 +1,108 @@
+// Code generated by qtc from "metric_names_stats_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+// MetricNamesStatsResponse generates response for /api/v1/status/metric_names_stats .
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:8
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:8
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:8
+func StreamMetricNamesStatsResponse(qw422016 *qt422016.Writer, result *storage.MetricNamesStatsResult, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:8
+	qw422016.N().S(`{"status":"success","statsCollectedSince":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:11
+	qw422016.N().DUL(result.CollectedSinceTimestamp)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:11
+	qw422016.N().S(`,"statsCollectedRecordsTotal":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:12
+	qw422016.N().DUL(result.TotalRecords)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:12
+	qw422016.N().S(`,"maxRecords":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:13
+	qw422016.N().DUL(result.MaxRecords)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:13
+	qw422016.N().S(`,"records":[`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:15
+	for i := range result.Records {
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:16
+		r := &result.Records[i]
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:16
+		qw422016.N().S(`{"metricName":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:18
+		qw422016.N().Q(r.MetricName)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:18
+		qw422016.N().S(`,"queryRequestsCount":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:19
+		qw422016.N().DUL(r.QueryRequestsCount)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:19
+		qw422016.N().S(`,"lastRequestTimestamp":`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:20
+		qw422016.N().DUL(r.LastRequestTimestamp)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:20
+		qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:22
+		if i+1 < len(result.Records) {
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:22
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:22
+		}
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:23
+	}
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:23
+	qw422016.N().S(`]`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:26
+	qt.Printf("generate response: records=%d", len(result.Records))
+	qt.Done()
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:29
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:29
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+}
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+func WriteMetricNamesStatsResponse(qq422016 qtio422016.Writer, result *storage.MetricNamesStatsResult, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	StreamMetricNamesStatsResponse(qw422016, result, qt)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+}
+
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+func MetricNamesStatsResponse(result *storage.MetricNamesStatsResult, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	WriteMetricNamesStatsResponse(qb422016, result, qt)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+	return qs422016
+//line app/vmselect/prometheus/metric_names_stats_response.qtpl:31
+}
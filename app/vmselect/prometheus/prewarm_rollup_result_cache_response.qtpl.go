@@ -0,0 +1,117 @@
+// Code generated by qtc from "prewarm_rollup_result_cache_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+)
+
+// PrewarmRollupResultCacheResponse generates response for /internal/prewarmRollupResultCache .
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:7
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:7
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:7
+func StreamPrewarmRollupResultCacheResponse(qw422016 *qt422016.Writer, start, end, step int64, results []prewarmRollupResultCacheResult, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:7
+	qw422016.N().S(`{"status":"success","data":{"start":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:11
+	qw422016.N().DL(start)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:11
+	qw422016.N().S(`,"end":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:12
+	qw422016.N().DL(end)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:12
+	qw422016.N().S(`,"step":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:13
+	qw422016.N().DL(step)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:13
+	qw422016.N().S(`,"queries":[`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:15
+	for i := range results {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:16
+		r := &results[i]
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:16
+		qw422016.N().S(`{"query":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:18
+		qw422016.N().Q(r.Query)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:18
+		qw422016.N().S(`,"durationSeconds":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:19
+		qw422016.N().F(r.Duration.Seconds())
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:20
+		if r.Err != nil {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:20
+			qw422016.N().S(`,"error":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:21
+			qw422016.N().Q(r.Err.Error())
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:22
+		} else {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:22
+			qw422016.N().S(`,"series":`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:23
+			qw422016.N().D(r.Series)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:24
+		}
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:24
+		qw422016.N().S(`}`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:26
+		if i+1 < len(results) {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:26
+			qw422016.N().S(`,`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:26
+		}
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:27
+	}
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:27
+	qw422016.N().S(`]}`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:31
+	qt.Printf("generate response: queries=%d", len(results))
+	qt.Done()
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:34
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:34
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+}
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+func WritePrewarmRollupResultCacheResponse(qq422016 qtio422016.Writer, start, end, step int64, results []prewarmRollupResultCacheResult, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	StreamPrewarmRollupResultCacheResponse(qw422016, start, end, step, results, qt)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+}
+
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+func PrewarmRollupResultCacheResponse(start, end, step int64, results []prewarmRollupResultCacheResult, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	WritePrewarmRollupResultCacheResponse(qb422016, start, end, step, results, qt)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+	return qs422016
+//line app/vmselect/prometheus/prewarm_rollup_result_cache_response.qtpl:36
+}
@@ -1,8 +1,10 @@
 package prometheus
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"runtime"
@@ -29,6 +31,8 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httputils"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/memory"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/arrow"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/parquet"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
@@ -54,6 +58,8 @@ var (
 		"When set to zero, the limit is automatically calculated based on -search.maxConcurrentRequests (inversely proportional) and memory available to the process (proportional).")
 	maxFederateSeries   = flag.Int("search.maxFederateSeries", 1e6, "The maximum number of time series, which can be returned from /federate. This option allows limiting memory usage")
 	maxExportSeries     = flag.Int("search.maxExportSeries", 10e6, "The maximum number of time series, which can be returned from /api/v1/export* APIs. This option allows limiting memory usage")
+	maxRemoteReadSeries = flag.Int("search.maxRemoteReadSeries", 1e6, "The maximum number of time series, which can be returned per each query from Prometheus remote read API at /api/v1/read. "+
+		"This option allows limiting memory usage")
 	maxTSDBStatusSeries = flag.Int("search.maxTSDBStatusSeries", 10e6, "The maximum number of time series, which can be processed during the call to /api/v1/status/tsdb. This option allows limiting memory usage")
 	maxSeriesLimit      = flag.Int("search.maxSeries", 30e3, "The maximum number of time series, which can be returned from /api/v1/series. This option allows limiting memory usage")
 	maxDeleteSeries     = flag.Int("search.maxDeleteSeries", 1e6, "The maximum number of time series, which can be deleted using /api/v1/admin/tsdb/delete_series. This option allows limiting memory usage")
@@ -64,6 +70,14 @@ var (
 		"This option doesn't limit the number of scanned raw samples in the database. The main purpose of this option is to limit the number of per-series points "+
 		"returned to graphing UI such as VMUI or Grafana. There is no sense in setting this limit to values bigger than the horizontal resolution of the graph. "+
 		"See also -search.maxResponseSeries")
+	maxEstimatedSeries = flagutil.NewDictInt("search.maxEstimatedSeries", 0, "The maximum number of time series a single query to /api/v1/query and /api/v1/query_range may select. "+
+		"Queries exceeding the limit are stopped before reading the data for the selected series. Per-user limits can be set via username:limit entries, "+
+		"where username is the HTTP Basic Auth username of the request. The limit can be lowered on a per-query basis via max_estimated_series query arg. "+
+		"Zero means no limit. See https://docs.victoriametrics.com/#query-cost-estimation")
+	maxEstimatedSamples = flagutil.NewDictInt("search.maxEstimatedSamples", 0, "The maximum estimated number of raw samples a single query to /api/v1/query and /api/v1/query_range may read. "+
+		"Queries exceeding the limit are stopped before reading the data for the selected series. Per-user limits can be set via username:limit entries, "+
+		"where username is the HTTP Basic Auth username of the request. The limit can be lowered on a per-query basis via max_estimated_samples query arg. "+
+		"Zero means no limit. See https://docs.victoriametrics.com/#query-cost-estimation and -search.estimatedScrapeInterval")
 	ignoreExtraFiltersAtLabelsAPI = flag.Bool("search.ignoreExtraFiltersAtLabelsAPI", false, "Whether to ignore match[], extra_filters[] and extra_label query args at "+
 		"/api/v1/labels and /api/v1/label/.../values . This may be useful for decreasing load on VictoriaMetrics when extra filters "+
 		"match too many time series. The downside is that superfluous labels or series could be returned, which do not match the extra filters. "+
@@ -352,6 +366,13 @@ func exportHandler(qt *querytracer.Tracer, w http.ResponseWriter, cp *commonPara
 			return sw.maybeFlushBuffer(bb)
 		}
 	}
+	var cw columnarWriter
+	if ct, ok := columnarContentTypes[format]; ok {
+		contentType = ct
+		writeLineFunc = func(xb *exportBlock, workerID uint) error {
+			return cw.WriteSeries(workerID, xb.mn, xb.timestamps, xb.values)
+		}
+	}
 	if maxRowsPerLine > 0 {
 		writeLineFuncOrig := writeLineFunc
 		writeLineFunc = func(xb *exportBlock, workerID uint) error {
@@ -386,7 +407,13 @@ func exportHandler(qt *querytracer.Tracer, w http.ResponseWriter, cp *commonPara
 	}
 
 	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxExportSeries)
+	sq.TrackMetricNamesStats = cp.trackMetricNamesStats
 	w.Header().Set("Content-Type", contentType)
+	if _, ok := columnarContentTypes[format]; ok {
+		// Columnar formats need the full list of columns before writing the data.
+		// The list is built from the fetched series, so reduce_mem_usage isn't supported for these formats.
+		reduceMemUsage = false
+	}
 
 	doneCh := make(chan error, 1)
 	if !reduceMemUsage {
@@ -394,6 +421,13 @@ func exportHandler(qt *querytracer.Tracer, w http.ResponseWriter, cp *commonPara
 		if err != nil {
 			return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
 		}
+		if _, ok := columnarContentTypes[format]; ok {
+			cw, err = newColumnarWriter(bw, rss, format)
+			if err != nil {
+				rss.Cancel()
+				return err
+			}
+		}
 		qtChild := qt.NewChild("background export format=%s", format)
 		go func() {
 			err := rss.RunParallel(qtChild, func(rs *netstorage.Result, workerID uint) error {
@@ -447,12 +481,54 @@ func exportHandler(qt *querytracer.Tracer, w http.ResponseWriter, cp *commonPara
 	if err := sw.flush(); err != nil {
 		return fmt.Errorf("cannot send data to remote client: %w", err)
 	}
+	if cw != nil {
+		if err := cw.Close(); err != nil {
+			return fmt.Errorf("cannot send data to remote client: %w", err)
+		}
+	}
 	if format == "promapi" {
 		WriteExportPromAPIFooter(bw, qt)
 	}
 	return bw.Flush()
 }
 
+// columnarContentTypes contains Content-Type values for columnar export formats.
+var columnarContentTypes = map[string]string{
+	"parquet": "application/vnd.apache.parquet",
+	"arrow":   "application/vnd.apache.arrow.stream",
+}
+
+// columnarWriter writes series in columnar export formats.
+type columnarWriter interface {
+	WriteSeries(workerID uint, mn *storage.MetricName, timestamps []int64, values []float64) error
+	Close() error
+}
+
+// newColumnarWriter returns columnarWriter for the given format, which writes series from rss to w.
+func newColumnarWriter(w io.Writer, rss *netstorage.Results, format string) (columnarWriter, error) {
+	labelNames, err := rss.LabelNames()
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain label names for the exported series: %w", err)
+	}
+	switch format {
+	case "parquet":
+		pw, err := parquet.NewWriter(w, labelNames)
+		if err != nil {
+			return nil, err
+		}
+		return pw, nil
+	case "arrow":
+		aw, err := arrow.NewWriter(w, labelNames)
+		if err != nil {
+			return nil, err
+		}
+		return aw, nil
+	default:
+		logger.Panicf("BUG: unexpected columnar format %q", format)
+		return nil, nil
+	}
+}
+
 type exportBlock struct {
 	mn         *storage.MetricName
 	timestamps []int64
@@ -481,13 +557,19 @@ func DeleteHandler(startTime time.Time, r *http.Request) error {
 	if err != nil {
 		return err
 	}
-	if !cp.IsDefaultTimeRange() {
-		return fmt.Errorf("start=%d and end=%d args aren't supported. Remove these args from the query in order to delete all the matching metrics", cp.start, cp.end)
-	}
 	sq := storage.NewSearchQuery(cp.start, cp.end, cp.filterss, *maxDeleteSeries)
-	deletedCount, err := netstorage.DeleteSeries(nil, sq, cp.deadline)
-	if err != nil {
-		return fmt.Errorf("cannot delete time series: %w", err)
+	var deletedCount int
+	if cp.IsDefaultTimeRange() {
+		deletedCount, err = netstorage.DeleteSeries(nil, sq, cp.deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete time series: %w", err)
+		}
+	} else {
+		// Delete only samples on the given time range, while leaving the matching series in place.
+		deletedCount, err = netstorage.DeleteSeriesOnTimeRange(nil, sq, cp.deadline)
+		if err != nil {
+			return fmt.Errorf("cannot delete samples on the time range [%d..%d]: %w", cp.start, cp.end, err)
+		}
 	}
 	if deletedCount > 0 {
 		promql.ResetRollupResultCache()
@@ -531,11 +613,15 @@ var labelValuesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="
 
 const secsPerDay = 3600 * 24
 
+var maxTSDBStatusDays = flag.Int("search.maxTSDBStatusDays", 100, "The maximum number of days within the retention, which can be processed during the call to /api/v1/status/tsdb "+
+	"with start and end query args. This option allows limiting CPU and disk IO usage. The number of days isn't limited if the flag is set to 0")
+
 // TSDBStatusHandler processes /api/v1/status/tsdb request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
 //
 // It can accept `match[]` filters in order to narrow down the search.
+// It can accept `start` and `end` args instead of `date` in order to collect stats over multiple days.
 func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
 	defer tsdbStatusDuration.UpdateDuration(startTime)
 
@@ -576,8 +662,13 @@ func TSDBStatusHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	}
 	start := int64(date*secsPerDay) * 1000
 	end := int64((date+1)*secsPerDay)*1000 - 1
+	if len(dateStr) == 0 && (r.FormValue("start") != "" || r.FormValue("end") != "") {
+		// Collect stats over all the days on the [start..end] time range.
+		start = cp.start
+		end = cp.end
+	}
 	sq := storage.NewSearchQuery(start, end, cp.filterss, *maxTSDBStatusSeries)
-	status, err := netstorage.TSDBStatus(qt, sq, focusLabel, topN, cp.deadline)
+	status, err := netstorage.TSDBStatus(qt, sq, focusLabel, topN, *maxTSDBStatusDays, cp.deadline)
 	if err != nil {
 		return fmt.Errorf("cannot obtain tsdb stats: %w", err)
 	}
@@ -687,6 +778,136 @@ func SeriesHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseW
 
 var seriesDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/series"}`)
 
+// QueryExemplarsHandler processes /api/v1/query_exemplars request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-exemplars
+func QueryExemplarsHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer queryExemplarsDuration.UpdateDuration(startTime)
+
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	cp, err := getCommonParams(r, startTime, false)
+	if err != nil {
+		return err
+	}
+	filterss, err := getTagFilterssFromQuery(query)
+	if err != nil {
+		return err
+	}
+	if len(filterss) == 0 {
+		return fmt.Errorf("cannot find series selectors in `query` arg: %q", query)
+	}
+	etfs, err := searchutils.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	filterss = searchutils.JoinTagFilterss(filterss, etfs)
+
+	sq := storage.NewSearchQuery(cp.start, cp.end, filterss, *maxSeriesLimit)
+	result, err := netstorage.SearchExemplars(qt, sq, cp.deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch exemplars for %q: %w", sq, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteExemplarsResponse(bw, result, qt)
+	return bw.Flush()
+}
+
+var queryExemplarsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query_exemplars"}`)
+
+// getTagFilterssFromQuery returns tag filters for all the series selectors in the given MetricsQL query.
+func getTagFilterssFromQuery(query string) ([][]storage.TagFilter, error) {
+	e, err := promql.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse query %q: %w", query, err)
+	}
+	var filterss [][]storage.TagFilter
+	metricsql.VisitAll(e, func(expr metricsql.Expr) {
+		me, ok := expr.(*metricsql.MetricExpr)
+		if !ok || me.IsEmpty() {
+			return
+		}
+		filterss = append(filterss, searchutils.ToTagFilterss(me.LabelFilterss)...)
+	})
+	return filterss, nil
+}
+
+// MetadataHandler processes /api/v1/metadata request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+func MetadataHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer metadataDuration.UpdateDuration(startTime)
+
+	deadline := searchutils.GetDeadlineForStatusRequest(r, startTime)
+	metric := r.FormValue("metric")
+	limit, err := httputils.GetInt(r, "limit")
+	if err != nil {
+		return err
+	}
+	limitPerMetric, err := httputils.GetInt(r, "limit_per_metric")
+	if err != nil {
+		return err
+	}
+	mms, err := netstorage.SearchMetricMetadata(qt, metric, limit, limitPerMetric, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot obtain metric metadata: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteMetadataResponse(bw, mms, qt)
+	return bw.Flush()
+}
+
+var metadataDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/metadata"}`)
+
+// MetricNamesStatsHandler processes /api/v1/status/metric_names_stats request.
+//
+// It returns the number of query requests and the last query time per each metric name.
+// See https://docs.victoriametrics.com/#track-ingested-metrics-usage
+func MetricNamesStatsHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer metricNamesStatsDuration.UpdateDuration(startTime)
+
+	deadline := searchutils.GetDeadlineForStatusRequest(r, startTime)
+	limit := 1000
+	if r.FormValue("limit") != "" {
+		n, err := httputils.GetInt(r, "limit")
+		if err != nil {
+			return err
+		}
+		limit = n
+	}
+	le := -1
+	if r.FormValue("le") != "" {
+		n, err := httputils.GetInt(r, "le")
+		if err != nil {
+			return err
+		}
+		le = n
+	}
+	matchPattern := r.FormValue("match_pattern")
+	result, err := netstorage.GetMetricNamesStats(qt, limit, le, matchPattern, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot obtain metric names stats: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteMetricNamesStatsResponse(bw, result, qt)
+	return bw.Flush()
+}
+
+var metricNamesStatsDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/status/metric_names_stats"}`)
+
+// ResetMetricNamesStatsHandler processes /api/v1/admin/status/metric_names_stats/reset request.
+func ResetMetricNamesStatsHandler(qt *querytracer.Tracer) error {
+	return netstorage.ResetMetricNamesStats(qt)
+}
+
 // QueryHandler processes /api/v1/query request.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#instant-queries
@@ -745,10 +966,11 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 		filterss := searchutils.JoinTagFilterss(tagFilterss, etfs)
 
 		cp := &commonParams{
-			deadline: deadline,
-			start:    start,
-			end:      end,
-			filterss: filterss,
+			deadline:              deadline,
+			start:                 start,
+			end:                   end,
+			filterss:              filterss,
+			trackMetricNamesStats: true,
 		}
 		if err := exportHandler(qt, w, cp, "promapi", 0, false); err != nil {
 			return fmt.Errorf("error when exporting data for query=%q on the time range (start=%d, end=%d): %w", childQuery, start, end, err)
@@ -790,19 +1012,26 @@ func QueryHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWr
 	} else {
 		queryOffset = 0
 	}
+	maxSeries, maxSamples, err := getMaxEstimatedCosts(r)
+	if err != nil {
+		return err
+	}
 	qs := &promql.QueryStats{}
 	ec := &promql.EvalConfig{
-		Start:               start,
-		End:                 start,
-		Step:                step,
-		MaxPointsPerSeries:  *maxPointsPerTimeseries,
-		MaxSeries:           GetMaxUniqueTimeSeries(),
-		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
-		Deadline:            deadline,
-		MayCache:            mayCache,
-		LookbackDelta:       lookbackDelta,
-		RoundDigits:         getRoundDigits(r),
-		EnforcedTagFilterss: etfs,
+		Start:                 start,
+		End:                   start,
+		Step:                  step,
+		MaxPointsPerSeries:    *maxPointsPerTimeseries,
+		MaxSeries:             GetMaxUniqueTimeSeries(),
+		MaxEstimatedSeries:    maxSeries,
+		MaxEstimatedSamples:   maxSamples,
+		QuotedRemoteAddr:      httpserver.GetQuotedRemoteAddr(r),
+		Deadline:              deadline,
+		MayCache:              mayCache,
+		TrackMetricNamesStats: true,
+		LookbackDelta:         lookbackDelta,
+		RoundDigits:           getRoundDigits(r),
+		EnforcedTagFilterss:   etfs,
 		GetRequestURI: func() string {
 			return httpserver.GetRequestURI(r)
 		},
@@ -898,25 +1127,39 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 		start, end = promql.AdjustStartEnd(start, end, step)
 	}
 
+	maxSeries, maxSamples, err := getMaxEstimatedCosts(r)
+	if err != nil {
+		return err
+	}
 	qs := &promql.QueryStats{}
 	ec := &promql.EvalConfig{
-		Start:               start,
-		End:                 end,
-		Step:                step,
-		MaxPointsPerSeries:  *maxPointsPerTimeseries,
-		MaxSeries:           GetMaxUniqueTimeSeries(),
-		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
-		Deadline:            deadline,
-		MayCache:            mayCache,
-		LookbackDelta:       lookbackDelta,
-		RoundDigits:         getRoundDigits(r),
-		EnforcedTagFilterss: etfs,
+		Start:                 start,
+		End:                   end,
+		Step:                  step,
+		MaxPointsPerSeries:    *maxPointsPerTimeseries,
+		MaxSeries:             GetMaxUniqueTimeSeries(),
+		MaxEstimatedSeries:    maxSeries,
+		MaxEstimatedSamples:   maxSamples,
+		QuotedRemoteAddr:      httpserver.GetQuotedRemoteAddr(r),
+		Deadline:              deadline,
+		MayCache:              mayCache,
+		TrackMetricNamesStats: true,
+		LookbackDelta:         lookbackDelta,
+		RoundDigits:           getRoundDigits(r),
+		EnforcedTagFilterss:   etfs,
 		GetRequestURI: func() string {
 			return httpserver.GetRequestURI(r)
 		},
 
 		QueryStats: qs,
 	}
+	if httputils.GetBool(r, "stream") {
+		err := queryRangeStreamHandler(qt, w, ec, query, r, ct)
+		if !errors.Is(err, promql.ErrNotStreamable) {
+			return err
+		}
+		qt.Printf("fall back to non-streaming mode, since the query cannot be evaluated in streaming mode")
+	}
 	result, err := promql.Exec(qt, ec, query, false)
 	if err != nil {
 		return err
@@ -948,6 +1191,110 @@ func queryRangeHandler(qt *querytracer.Tracer, startTime time.Time, w http.Respo
 	return nil
 }
 
+// queryRangeStreamHandler evaluates query with ec and sends the resulting series to w as soon as they are evaluated.
+//
+// This allows returning responses with big number of series without holding all of them in memory.
+// promql.ErrNotStreamable is returned without writing anything to w if the query cannot be evaluated in streaming mode.
+//
+// Errors, which occur before sending the first series, are returned to the caller, so they are sent with the proper status code.
+// Errors, which occur after that, are sent in the end of the response via QueryRangeStreamErrorFooter,
+// since the status code has been already sent. The response remains valid JSON in this case.
+func queryRangeStreamHandler(qt *querytracer.Tracer, w http.ResponseWriter, ec *promql.EvalConfig, query string, r *http.Request, ct int64) error {
+	adjustLastPointsStart := int64(math.MaxInt64)
+	if ec.Step < maxStepForPointsAdjustment.Milliseconds() {
+		queryOffset, err := getLatencyOffsetMilliseconds(r)
+		if err != nil {
+			return err
+		}
+		adjustLastPointsStart = ct - queryOffset
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	sw := newScalableWriter(bw)
+	var firstLineOnce atomic.Bool
+	var firstLineSent atomic.Bool
+	var seriesCount atomic.Uint64
+	var pointsCount atomic.Uint64
+	err := promql.ExecStream(qt, ec, query, func(rs *netstorage.Result, workerID uint) error {
+		if err := bw.Error(); err != nil {
+			return err
+		}
+		tss := []netstorage.Result{*rs}
+		if adjustLastPointsStart < ec.End {
+			tss = adjustLastPoints(tss, adjustLastPointsStart, ct+ec.Step)
+		}
+		// Remove NaN values as Prometheus does.
+		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/153
+		tss = removeEmptyValuesAndTimeseries(tss)
+		if len(tss) == 0 {
+			return nil
+		}
+		seriesCount.Add(1)
+		pointsCount.Add(uint64(len(tss[0].Values)))
+
+		bb := sw.getBuffer(workerID)
+		// Use Load() in front of CompareAndSwap() in order to avoid slow inter-CPU synchronization
+		// in fast path after the first line has been already sent.
+		if !firstLineOnce.Load() && firstLineOnce.CompareAndSwap(false, true) {
+			// Send the response header together with the first line to sw.bw
+			WriteQueryRangeStreamHeader(bb)
+			WriteQueryRangeStreamLine(bb, &tss[0])
+			_, err := sw.bw.Write(bb.B)
+			bb.Reset()
+			firstLineSent.Store(true)
+			return err
+		}
+		for !firstLineSent.Load() {
+			// Busy wait until the first line is sent to sw.bw
+			runtime.Gosched()
+		}
+		bb.B = append(bb.B, ',')
+		WriteQueryRangeStreamLine(bb, &tss[0])
+		return sw.maybeFlushBuffer(bb)
+	})
+	if err != nil {
+		if errors.Is(err, promql.ErrNotStreamable) || !firstLineOnce.Load() {
+			// Nothing has been written to bw yet.
+			return err
+		}
+		logger.Warnf("error in %q after sending %d series in streaming mode: %s", httpserver.GetRequestURI(r), seriesCount.Load(), err)
+		queryRangeStreamErrors.Inc()
+		if err := sw.flush(); err != nil {
+			return fmt.Errorf("cannot send query range response to remote client: %w", err)
+		}
+		var ure *httpserver.UserReadableError
+		if errors.As(err, &ure) {
+			err = ure
+		}
+		WriteQueryRangeStreamErrorFooter(bw, err)
+		if err := bw.Flush(); err != nil {
+			return fmt.Errorf("cannot send query range response to remote client: %w", err)
+		}
+		return nil
+	}
+	if !firstLineOnce.Load() {
+		WriteQueryRangeStreamHeader(bw)
+	}
+	if err := sw.flush(); err != nil {
+		return fmt.Errorf("cannot send query range response to remote client: %w", err)
+	}
+	qtDone := func() {
+		qt.Donef("start=%d, end=%d, step=%d, query=%q: series=%d", ec.Start, ec.End, ec.Step, query, seriesCount.Load())
+	}
+	WriteQueryRangeStreamFooter(bw, int(seriesCount.Load()), int(pointsCount.Load()), qt, qtDone, ec.QueryStats)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send query range response to remote client: %w", err)
+	}
+	return nil
+}
+
+// queryRangeStreamErrors counts errors, which occurred after sending the first series in streaming mode.
+//
+// Such errors aren't counted by vm_http_request_errors_total, since they are sent in the response body with 200 status code.
+var queryRangeStreamErrors = metrics.NewCounter(`vm_http_request_stream_errors_total{path="/api/v1/query_range"}`)
+
 func removeEmptyValuesAndTimeseries(tss []netstorage.Result) []netstorage.Result {
 	dst := tss[:0]
 	for i := range tss {
@@ -991,6 +1338,228 @@ func removeEmptyValuesAndTimeseries(tss []netstorage.Result) []netstorage.Result
 
 var queryRangeDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query_range"}`)
 
+// QueryExplainHandler processes /api/v1/query_explain request.
+//
+// It returns the evaluation plan for the query with the estimated number of series, raw samples and index lookups
+// per each subexpression. Data blocks aren't read during the estimation.
+func QueryExplainHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer queryExplainDuration.UpdateDuration(startTime)
+
+	ct := startTime.UnixNano() / 1e6
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	if len(query) > maxQueryLen.IntN() {
+		return fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(query), maxQueryLen.N)
+	}
+	var start, end int64
+	if r.FormValue("time") != "" {
+		// Instant query.
+		t, err := httputils.GetTime(r, "time", ct)
+		if err != nil {
+			return err
+		}
+		start, end = t, t
+	} else {
+		var err error
+		start, err = httputils.GetTime(r, "start", ct-defaultStep)
+		if err != nil {
+			return err
+		}
+		end, err = httputils.GetTime(r, "end", ct)
+		if err != nil {
+			return err
+		}
+	}
+	step, err := httputils.GetDuration(r, "step", defaultStep)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		end = start
+	}
+	etfs, err := searchutils.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	lookbackDelta, err := getMaxLookback(r)
+	if err != nil {
+		return err
+	}
+	maxSeries, maxSamples, err := getMaxEstimatedCosts(r)
+	if err != nil {
+		return err
+	}
+	ec := &promql.EvalConfig{
+		Start:               start,
+		End:                 end,
+		Step:                step,
+		MaxPointsPerSeries:  *maxPointsPerTimeseries,
+		MaxSeries:           GetMaxUniqueTimeSeries(),
+		QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
+		Deadline:            searchutils.GetDeadlineForQuery(r, startTime),
+		LookbackDelta:       lookbackDelta,
+		EnforcedTagFilterss: etfs,
+		GetRequestURI: func() string {
+			return httpserver.GetRequestURI(r)
+		},
+	}
+	en, err := promql.Explain(qt, ec, query)
+	if err != nil {
+		return fmt.Errorf("cannot explain query=%q on the time range (start=%d, end=%d, step=%d): %w", query, start, end, step, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WriteQueryExplainResponse(bw, en, maxSeries, maxSamples, qt)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send query explain response to remote client: %w", err)
+	}
+	return nil
+}
+
+var queryExplainDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/query_explain"}`)
+
+// getMaxEstimatedCosts returns the limits on the number of series and the estimated number of raw samples for the query in r.
+//
+// The limits are set via -search.maxEstimatedSeries and -search.maxEstimatedSamples command-line flags
+// for the user identified by HTTP Basic Auth username in r.
+// They can be lowered via max_estimated_series and max_estimated_samples query args.
+// The minimum value is used if the query arg is passed multiple times, so the limit set by a proxy
+// such as vmauth cannot be overridden by the client.
+func getMaxEstimatedCosts(r *http.Request) (int, int64, error) {
+	username, _, _ := r.BasicAuth()
+	maxSeries, err := getMinLimit(r, "max_estimated_series", int64(maxEstimatedSeries.Get(username)))
+	if err != nil {
+		return 0, 0, err
+	}
+	maxSamples, err := getMinLimit(r, "max_estimated_samples", int64(maxEstimatedSamples.Get(username)))
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(maxSeries), maxSamples, nil
+}
+
+func getMinLimit(r *http.Request, argKey string, limit int64) (int64, error) {
+	if err := r.ParseForm(); err != nil {
+		return 0, fmt.Errorf("cannot parse request form values: %w", err)
+	}
+	for _, s := range r.Form[argKey] {
+		if s == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse %s=%q: %w", argKey, s, err)
+		}
+		if n > 0 && (limit <= 0 || n < limit) {
+			limit = n
+		}
+	}
+	return limit, nil
+}
+
+// PrewarmRollupResultCacheHandler processes /internal/prewarmRollupResultCache request.
+//
+// It executes range queries passed via `query` args on the [start..end] time range with the given step,
+// so their results are stored in the rollup result cache.
+// Queries are executed sequentially in order to limit the load on the storage.
+func PrewarmRollupResultCacheHandler(qt *querytracer.Tracer, startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer prewarmRollupResultCacheDuration.UpdateDuration(startTime)
+
+	ct := startTime.UnixNano() / 1e6
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("cannot parse request form values: %w", err)
+	}
+	queries := r.Form["query"]
+	if len(queries) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	start, err := httputils.GetTime(r, "start", ct-defaultStep)
+	if err != nil {
+		return err
+	}
+	end, err := httputils.GetTime(r, "end", ct)
+	if err != nil {
+		return err
+	}
+	step, err := httputils.GetDuration(r, "step", defaultStep)
+	if err != nil {
+		return err
+	}
+	if start > end {
+		end = start + defaultStep
+	}
+	if err := promql.ValidateMaxPointsPerSeries(start, end, step, *maxPointsPerTimeseries); err != nil {
+		return fmt.Errorf("%w; (see -search.maxPointsPerTimeseries command-line flag)", err)
+	}
+	start, end = promql.AdjustStartEnd(start, end, step)
+	etfs, err := searchutils.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+	lookbackDelta, err := getMaxLookback(r)
+	if err != nil {
+		return err
+	}
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+
+	results := make([]prewarmRollupResultCacheResult, len(queries))
+	for i, query := range queries {
+		queryStartTime := time.Now()
+		result := &results[i]
+		result.Query = query
+		if len(query) > maxQueryLen.IntN() {
+			result.Err = fmt.Errorf("too long query; got %d bytes; mustn't exceed `-search.maxQueryLen=%d` bytes", len(query), maxQueryLen.N)
+			continue
+		}
+		ec := &promql.EvalConfig{
+			Start:               start,
+			End:                 end,
+			Step:                step,
+			MaxPointsPerSeries:  *maxPointsPerTimeseries,
+			MaxSeries:           GetMaxUniqueTimeSeries(),
+			QuotedRemoteAddr:    httpserver.GetQuotedRemoteAddr(r),
+			Deadline:            deadline,
+			MayCache:            true,
+			LookbackDelta:       lookbackDelta,
+			EnforcedTagFilterss: etfs,
+			GetRequestURI: func() string {
+				return httpserver.GetRequestURI(r)
+			},
+
+			QueryStats: &promql.QueryStats{},
+		}
+		rs, err := promql.Exec(qt, ec, query, false)
+		result.Duration = time.Since(queryStartTime)
+		if err != nil {
+			result.Err = err
+			continue
+		}
+		result.Series = len(rs)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	WritePrewarmRollupResultCacheResponse(bw, start, end, step, results, qt)
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("cannot send rollup result cache prewarm response to remote client: %w", err)
+	}
+	return nil
+}
+
+type prewarmRollupResultCacheResult struct {
+	Query    string
+	Series   int
+	Duration time.Duration
+	Err      error
+}
+
+var prewarmRollupResultCacheDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/internal/prewarmRollupResultCache"}`)
+
 var nan = math.NaN()
 
 // adjustLastPoints substitutes the last point values on the time range (start..end]
@@ -1112,6 +1681,9 @@ type commonParams struct {
 	end              int64
 	currentTimestamp int64
 	filterss         [][]storage.TagFilter
+
+	// trackMetricNamesStats is set to true if the queried metric names must be registered in metric names usage stats.
+	trackMetricNamesStats bool
 }
 
 func (cp *commonParams) IsDefaultTimeRange() bool {
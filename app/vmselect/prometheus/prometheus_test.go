@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
 
 func TestRemoveEmptyValuesAndTimeseries(t *testing.T) {
@@ -231,6 +234,103 @@ func TestGetLatencyOffsetMillisecondsFailure(t *testing.T) {
 	f("http://localhost?latency_offset=foobar")
 }
 
+func TestGetMinLimitSuccess(t *testing.T) {
+	f := func(url string, limit, limitExpected int64) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in NewRequest(%q): %s", url, err)
+		}
+		result, err := getMinLimit(r, "max_estimated_series", limit)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != limitExpected {
+			t.Fatalf("unexpected limit; got %d; want %d", result, limitExpected)
+		}
+	}
+
+	// no query args
+	f("http://localhost", 0, 0)
+	f("http://localhost", 100, 100)
+
+	// the query arg cannot increase the limit
+	f("http://localhost?max_estimated_series=10", 0, 10)
+	f("http://localhost?max_estimated_series=10", 100, 10)
+	f("http://localhost?max_estimated_series=1000", 100, 100)
+	f("http://localhost?max_estimated_series=0", 100, 100)
+
+	// the minimum limit is used
+	f("http://localhost?max_estimated_series=20&max_estimated_series=10&max_estimated_series=30", 0, 10)
+}
+
+func TestGetMinLimitFailure(t *testing.T) {
+	f := func(url string) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in NewRequest(%q): %s", url, err)
+		}
+		if _, err := getMinLimit(r, "max_estimated_series", 0); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+	f("http://localhost?max_estimated_series=foobar")
+	f("http://localhost?max_estimated_series=1.5")
+}
+
+func TestGetMaxEstimatedCosts(t *testing.T) {
+	maxEstimatedSeriesOrig := *maxEstimatedSeries
+	maxEstimatedSamplesOrig := *maxEstimatedSamples
+	defer func() {
+		*maxEstimatedSeries = maxEstimatedSeriesOrig
+		*maxEstimatedSamples = maxEstimatedSamplesOrig
+	}()
+	*maxEstimatedSeries = flagutil.DictInt{}
+	*maxEstimatedSamples = flagutil.DictInt{}
+	for _, v := range []string{"100", "alice:10,bob:0"} {
+		if err := maxEstimatedSeries.Set(v); err != nil {
+			t.Fatalf("cannot set -search.maxEstimatedSeries=%q: %s", v, err)
+		}
+	}
+	if err := maxEstimatedSamples.Set("alice:1000"); err != nil {
+		t.Fatalf("cannot set -search.maxEstimatedSamples: %s", err)
+	}
+
+	f := func(url, username string, maxSeriesExpected int, maxSamplesExpected int64) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			t.Fatalf("unexpected error in NewRequest(%q): %s", url, err)
+		}
+		if username != "" {
+			r.SetBasicAuth(username, "password")
+		}
+		maxSeries, maxSamples, err := getMaxEstimatedCosts(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if maxSeries != maxSeriesExpected {
+			t.Fatalf("unexpected maxSeries; got %d; want %d", maxSeries, maxSeriesExpected)
+		}
+		if maxSamples != maxSamplesExpected {
+			t.Fatalf("unexpected maxSamples; got %d; want %d", maxSamples, maxSamplesExpected)
+		}
+	}
+
+	// default limits
+	f("http://localhost", "", 100, 0)
+	f("http://localhost", "unknown", 100, 0)
+
+	// per-user limits
+	f("http://localhost", "alice", 10, 1000)
+	f("http://localhost", "bob", 0, 0)
+
+	// per-user limits can be lowered via query args
+	f("http://localhost?max_estimated_series=5&max_estimated_samples=2000", "alice", 5, 1000)
+	f("http://localhost?max_estimated_series=5&max_estimated_samples=2000", "bob", 5, 2000)
+}
+
 func TestCalculateMaxMetricsLimitByResource(t *testing.T) {
 	f := func(maxConcurrentRequest, remainingMemory, expect int) {
 		t.Helper()
@@ -256,3 +356,61 @@ func TestCalculateMaxMetricsLimitByResource(t *testing.T) {
 	f(4, 0, 0)
 
 }
+
+func TestGetTagFilterssFromQuery(t *testing.T) {
+	f := func(query string, resultExpected []string) {
+		t.Helper()
+		filterss, err := getTagFilterssFromQuery(query)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var result []string
+		for _, filters := range filterss {
+			var a []string
+			for i := range filters {
+				a = append(a, filters[i].String())
+			}
+			result = append(result, strings.Join(a, ","))
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+	f(`foo`, []string{`__name__="foo"`})
+	f(`rate(foo{job="bar"}[5m])`, []string{`__name__="foo",job="bar"`})
+	f(`sum(foo) / sum(bar{x=~"y|z"})`, []string{`__name__="foo"`, `__name__="bar",x=~"y|z"`})
+	f(`1 + 2`, nil)
+
+	if _, err := getTagFilterssFromQuery(`foo(`); err == nil {
+		t.Fatalf("expecting non-nil error for invalid query")
+	}
+}
+
+func TestMetadataResponse(t *testing.T) {
+	f := func(mms []storage.MetricMetadata, resultExpected string) {
+		t.Helper()
+		result := MetadataResponse(mms, nil)
+		if result != resultExpected {
+			t.Fatalf("unexpected response;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(nil, `{"status":"success","data":{}}`)
+	f([]storage.MetricMetadata{
+		{
+			MetricFamilyName: "bar",
+			Type:             "gauge",
+			Unit:             "seconds",
+		},
+		{
+			MetricFamilyName: "foo",
+			Type:             "counter",
+			Help:             `new "foo" help`,
+		},
+		{
+			MetricFamilyName: "foo",
+			Type:             "counter",
+			Help:             "old foo help",
+		},
+	}, `{"status":"success","data":{"bar":[{"type":"gauge","help":"","unit":"seconds"}],`+
+		`"foo":[{"type":"counter","help":"new \"foo\" help","unit":""},{"type":"counter","help":"old foo help","unit":""}]}}`)
+}
@@ -0,0 +1,629 @@
+package prometheus
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bufferedwriter"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
+)
+
+// FormatQueryHandler processes /api/v1/format_query request.
+//
+// It returns prettified query in the same way as Prometheus does.
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#formatting-query-expressions
+func FormatQueryHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	prettyQuery, err := metricsql.Prettify(query)
+	if err != nil {
+		return fmt.Errorf("cannot format query %q: %w", query, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	fmt.Fprintf(bw, `{"status":"success","data":%s}`, stringsutil.JSONString(prettyQuery))
+	return bw.Flush()
+}
+
+// ParseQueryHandler processes /api/v1/parse_query request.
+//
+// It returns JSON representation for the parsed query. WITH templates and calls to user-defined functions are preserved in the returned AST.
+// Every AST node contains start and end byte offsets of the node in the original query.
+func ParseQueryHandler(w http.ResponseWriter, r *http.Request) error {
+	query := r.FormValue("query")
+	if len(query) == 0 {
+		return fmt.Errorf("missing `query` arg")
+	}
+	if _, err := promql.Parse(query); err != nil {
+		return fmt.Errorf("cannot parse query %q: %w", query, err)
+	}
+	n, err := newQueryAST(query)
+	if err != nil {
+		return fmt.Errorf("cannot build AST for query %q: %w", query, err)
+	}
+	data, err := marshalQueryAST(n)
+	if err != nil {
+		return fmt.Errorf("cannot marshal AST for query %q: %w", query, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	fmt.Fprintf(bw, `{"status":"success","data":{"query":%s,"ast":%s}}`, stringsutil.JSONString(query), data)
+	return bw.Flush()
+}
+
+// FormatASTHandler processes /api/v1/format_ast request.
+//
+// It converts AST in the format returned by /api/v1/parse_query back into MetricsQL query.
+func FormatASTHandler(w http.ResponseWriter, r *http.Request) error {
+	data := r.FormValue("ast")
+	if len(data) == 0 {
+		return fmt.Errorf("missing `ast` arg")
+	}
+	q, err := formatQueryAST([]byte(data))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	bw := bufferedwriter.Get(w)
+	defer bufferedwriter.Put(bw)
+	fmt.Fprintf(bw, `{"status":"success","data":%s}`, stringsutil.JSONString(q))
+	return bw.Flush()
+}
+
+// marshalQueryAST returns JSON for n.
+//
+// Unlike json.Marshal, it doesn't escape `<`, `>` and `&` chars, which are frequently used in queries.
+func marshalQueryAST(n *queryASTNode) ([]byte, error) {
+	var bb bytes.Buffer
+	enc := json.NewEncoder(&bb)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(n); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(bb.Bytes(), []byte("\n")), nil
+}
+
+func formatQueryAST(data []byte) (string, error) {
+	var n queryASTNode
+	if err := json.Unmarshal(data, &n); err != nil {
+		return "", fmt.Errorf("cannot unmarshal AST: %w", err)
+	}
+	e, err := n.toExpr()
+	if err != nil {
+		return "", fmt.Errorf("invalid AST: %w", err)
+	}
+
+	// Verify the resulting query, since AST may contain arbitrary values,
+	// which cannot be represented in MetricsQL.
+	// The query isn't replaced with the parsed one, since this would expand WITH templates.
+	q := string(e.AppendString(nil))
+	if _, err := promql.Parse(q); err != nil {
+		return "", fmt.Errorf("cannot parse query %q obtained from AST: %w", q, err)
+	}
+	return q, nil
+}
+
+// queryASTNode is JSON representation for metricsql.Expr.
+type queryASTNode struct {
+	// Type is the node type. It may be `metric`, `rollup`, `func`, `aggr`, `binaryOp`, `number`, `string`, `duration`,
+	// `with`, `template` or `raw`.
+	Type string `json:"type"`
+
+	// Start and End contain byte offsets for the node in the query passed to /api/v1/parse_query.
+	//
+	// They are ignored by /api/v1/format_ast.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	// Value contains the value for `number`, `string` and `duration` nodes.
+	//
+	// It contains MetricsQL expression for `raw` node, which cannot be represented in structured form,
+	// since it depends on WITH templates. For example, `foo{filters}` or `"prefix_" + name`.
+	Value *string `json:"value,omitempty"`
+
+	// Templates contains `template` nodes for `with` node, while Expr contains the expression for it.
+	Templates []*queryASTNode `json:"templates,omitempty"`
+
+	// Params contains optional param names for `template` node, while Name and Expr contain its name and body.
+	Params []string `json:"params,omitempty"`
+
+	// Filters contains or-delimited groups of label filters for `metric` node.
+	Filters [][]queryASTLabelFilter `json:"filters,omitempty"`
+
+	// Name and Args contain function name and function args for `func` and `aggr` nodes.
+	Name string          `json:"name,omitempty"`
+	Args []*queryASTNode `json:"args,omitempty"`
+
+	// Modifier and Limit contain optional `by (...)` or `without (...)` modifier and `limit N` suffix for `aggr` node.
+	Modifier *queryASTModifier `json:"modifier,omitempty"`
+	Limit    int               `json:"limit,omitempty"`
+
+	// The following fields are set for `binaryOp` node.
+	Op            string            `json:"op,omitempty"`
+	Bool          bool              `json:"bool,omitempty"`
+	GroupModifier *queryASTModifier `json:"groupModifier,omitempty"`
+	JoinModifier  *queryASTModifier `json:"joinModifier,omitempty"`
+	JoinPrefix    *string           `json:"joinPrefix,omitempty"`
+	Left          *queryASTNode     `json:"left,omitempty"`
+	Right         *queryASTNode     `json:"right,omitempty"`
+
+	// The following fields are set for `rollup` node such as `expr[window:step] offset offset @ at`.
+	Expr        *queryASTNode `json:"expr,omitempty"`
+	Window      string        `json:"window,omitempty"`
+	Step        string        `json:"step,omitempty"`
+	InheritStep bool          `json:"inheritStep,omitempty"`
+	Offset      string        `json:"offset,omitempty"`
+	At          *queryASTNode `json:"at,omitempty"`
+
+	// KeepMetricNames is set for `func` and `binaryOp` nodes with `keep_metric_names` modifier.
+	KeepMetricNames bool `json:"keepMetricNames,omitempty"`
+}
+
+type queryASTLabelFilter struct {
+	Label string `json:"label"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+type queryASTModifier struct {
+	Op   string   `json:"op"`
+	Args []string `json:"args"`
+}
+
+// newQueryAST returns AST for the given query.
+//
+// WITH templates aren't expanded in the returned AST.
+func newQueryAST(query string) (*queryASTNode, error) {
+	e, spans, err := metricsql.ParseRaw(query)
+	if err != nil {
+		return nil, err
+	}
+	return newQueryASTNode(e, query, spans)
+}
+
+// newQueryASTNode returns AST node for e obtained from metricsql.ParseRaw(q) with the given spans.
+func newQueryASTNode(e metricsql.Expr, q string, spans map[metricsql.Expr]metricsql.Span) (*queryASTNode, error) {
+	if pe, ok := e.(*metricsql.ParensExpr); ok && len(*pe) == 1 {
+		// Single expression in parens, e.g. `(foo + bar)`.
+		return newQueryASTNode((*pe)[0], q, spans)
+	}
+	span, ok := spans[e]
+	if !ok {
+		return nil, fmt.Errorf("BUG: missing span for %q", e.AppendString(nil))
+	}
+	n := &queryASTNode{
+		Start: span.Start,
+		End:   span.End,
+	}
+	var err error
+	switch t := e.(type) {
+	case *metricsql.WithExpr:
+		n.Type = "with"
+		n.Templates = make([]*queryASTNode, len(t.Was))
+		for i, wa := range t.Was {
+			tn, err := newQueryASTNode(wa, q, spans)
+			if err != nil {
+				return nil, err
+			}
+			n.Templates[i] = tn
+		}
+		n.Expr, err = newQueryASTNode(t.Expr, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.WithArgExpr:
+		n.Type = "template"
+		n.Name = t.Name
+		n.Params = t.Args
+		n.Expr, err = newQueryASTNode(t.Expr, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.ParensExpr:
+		// Multiple expressions in parens are equivalent to union() function call.
+		n.Type = "func"
+		n.Args, err = newQueryASTArgs(*t, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.MetricExpr:
+		me, ok := parseExpandedExpr(q[n.Start:n.End]).(*metricsql.MetricExpr)
+		if !ok {
+			setQueryASTRawValue(n, q)
+			break
+		}
+		n.Type = "metric"
+		n.Filters = make([][]queryASTLabelFilter, len(me.LabelFilterss))
+		for i, lfs := range me.LabelFilterss {
+			filters := make([]queryASTLabelFilter, len(lfs))
+			for j := range lfs {
+				lf := &lfs[j]
+				filters[j] = queryASTLabelFilter{
+					Label: lf.Label,
+					Op:    getLabelFilterOp(lf),
+					Value: lf.Value,
+				}
+			}
+			n.Filters[i] = filters
+		}
+	case *metricsql.RollupExpr:
+		n.Type = "rollup"
+		n.Window = string(t.Window.AppendString(nil))
+		n.Step = string(t.Step.AppendString(nil))
+		n.InheritStep = t.InheritStep
+		n.Offset = string(t.Offset.AppendString(nil))
+		n.Expr, err = newQueryASTNode(t.Expr, q, spans)
+		if err != nil {
+			return nil, err
+		}
+		if t.At != nil {
+			n.At, err = newQueryASTNode(t.At, q, spans)
+			if err != nil {
+				return nil, err
+			}
+		}
+	case *metricsql.FuncExpr:
+		n.Type = "func"
+		n.Name = t.Name
+		n.KeepMetricNames = t.KeepMetricNames
+		n.Args, err = newQueryASTArgs(t.Args, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.AggrFuncExpr:
+		n.Type = "aggr"
+		n.Name = t.Name
+		n.Limit = t.Limit
+		if t.Modifier.Op != "" {
+			n.Modifier = newQueryASTModifier(&t.Modifier)
+		}
+		n.Args, err = newQueryASTArgs(t.Args, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.BinaryOpExpr:
+		if t.JoinFill != nil || len(t.MatchTransforms) > 0 {
+			// There is no structured representation for fill() and transform() modifiers.
+			setQueryASTRawValue(n, q)
+			break
+		}
+		if t.JoinModifierPrefix != nil {
+			se, ok := parseExpandedExpr(string(t.JoinModifierPrefix.AppendString(nil))).(*metricsql.StringExpr)
+			if !ok {
+				setQueryASTRawValue(n, q)
+				break
+			}
+			prefix := se.S
+			n.JoinPrefix = &prefix
+		}
+		n.Type = "binaryOp"
+		n.Op = t.Op
+		n.Bool = t.Bool
+		n.KeepMetricNames = t.KeepMetricNames
+		if t.GroupModifier.Op != "" {
+			n.GroupModifier = newQueryASTModifier(&t.GroupModifier)
+		}
+		if t.JoinModifier.Op != "" {
+			n.JoinModifier = newQueryASTModifier(&t.JoinModifier)
+		}
+		n.Left, err = newQueryASTNode(t.Left, q, spans)
+		if err != nil {
+			return nil, err
+		}
+		n.Right, err = newQueryASTNode(t.Right, q, spans)
+		if err != nil {
+			return nil, err
+		}
+	case *metricsql.NumberExpr:
+		n.Type = "number"
+		v := string(t.AppendString(nil))
+		n.Value = &v
+	case *metricsql.StringExpr:
+		se, ok := parseExpandedExpr(q[n.Start:n.End]).(*metricsql.StringExpr)
+		if !ok {
+			setQueryASTRawValue(n, q)
+			break
+		}
+		n.Type = "string"
+		v := se.S
+		n.Value = &v
+	case *metricsql.DurationExpr:
+		n.Type = "duration"
+		v := string(t.AppendString(nil))
+		n.Value = &v
+	default:
+		return nil, fmt.Errorf("unexpected expression type %T for %q", e, e.AppendString(nil))
+	}
+	return n, nil
+}
+
+// parseExpandedExpr returns expanded expression for s or nil if s cannot be expanded, since it depends on WITH templates.
+func parseExpandedExpr(s string) metricsql.Expr {
+	e, err := metricsql.Parse(s)
+	if err != nil {
+		return nil
+	}
+	return e
+}
+
+// setQueryASTRawValue converts n to `raw` node with the MetricsQL expression from q at the n location.
+func setQueryASTRawValue(n *queryASTNode, q string) {
+	v := q[n.Start:n.End]
+	n.Type = "raw"
+	n.Value = &v
+}
+
+// newQueryASTArgs returns AST nodes for the given args obtained from metricsql.ParseRaw(q) with the given spans.
+func newQueryASTArgs(args []metricsql.Expr, q string, spans map[metricsql.Expr]metricsql.Span) ([]*queryASTNode, error) {
+	nodes := make([]*queryASTNode, len(args))
+	for i, arg := range args {
+		n, err := newQueryASTNode(arg, q, spans)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+func newQueryASTModifier(me *metricsql.ModifierExpr) *queryASTModifier {
+	args := me.Args
+	if args == nil {
+		args = []string{}
+	}
+	return &queryASTModifier{
+		Op:   me.Op,
+		Args: args,
+	}
+}
+
+func getLabelFilterOp(lf *metricsql.LabelFilter) string {
+	switch {
+	case lf.IsNegative && lf.IsRegexp:
+		return "!~"
+	case lf.IsNegative:
+		return "!="
+	case lf.IsRegexp:
+		return "=~"
+	default:
+		return "="
+	}
+}
+
+// toExpr converts n to metricsql.Expr.
+func (n *queryASTNode) toExpr() (metricsql.Expr, error) {
+	switch n.Type {
+	case "metric":
+		lfss := make([][]metricsql.LabelFilter, len(n.Filters))
+		for i, filters := range n.Filters {
+			lfs := make([]metricsql.LabelFilter, len(filters))
+			for j, f := range filters {
+				lf := &lfs[j]
+				lf.Label = f.Label
+				lf.Value = f.Value
+				switch f.Op {
+				case "=":
+				case "!=":
+					lf.IsNegative = true
+				case "=~":
+					lf.IsRegexp = true
+				case "!~":
+					lf.IsNegative = true
+					lf.IsRegexp = true
+				default:
+					return nil, fmt.Errorf("unexpected label filter op %q; supported values: =, !=, =~, !~", f.Op)
+				}
+			}
+			lfss[i] = lfs
+		}
+		return &metricsql.MetricExpr{
+			LabelFilterss: lfss,
+		}, nil
+	case "rollup":
+		if n.Expr == nil {
+			return nil, fmt.Errorf("missing `expr` for %q node", n.Type)
+		}
+		e, err := n.Expr.toExpr()
+		if err != nil {
+			return nil, err
+		}
+		re := &metricsql.RollupExpr{
+			Expr:        e,
+			InheritStep: n.InheritStep,
+		}
+		if re.Window, err = parseQueryASTDuration(n.Window); err != nil {
+			return nil, fmt.Errorf("invalid `window`: %w", err)
+		}
+		if re.Step, err = parseQueryASTDuration(n.Step); err != nil {
+			return nil, fmt.Errorf("invalid `step`: %w", err)
+		}
+		if re.Offset, err = parseQueryASTDuration(n.Offset); err != nil {
+			return nil, fmt.Errorf("invalid `offset`: %w", err)
+		}
+		if n.At != nil {
+			if re.At, err = n.At.toExpr(); err != nil {
+				return nil, err
+			}
+		}
+		return re, nil
+	case "func":
+		args, err := queryASTArgsToExprs(n.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &metricsql.FuncExpr{
+			Name:            n.Name,
+			Args:            args,
+			KeepMetricNames: n.KeepMetricNames,
+		}, nil
+	case "aggr":
+		args, err := queryASTArgsToExprs(n.Args)
+		if err != nil {
+			return nil, err
+		}
+		ae := &metricsql.AggrFuncExpr{
+			Name:  n.Name,
+			Args:  args,
+			Limit: n.Limit,
+		}
+		if n.Modifier != nil {
+			ae.Modifier = n.Modifier.toModifierExpr()
+		}
+		return ae, nil
+	case "binaryOp":
+		if n.Left == nil || n.Right == nil {
+			return nil, fmt.Errorf("missing `left` or `right` for %q node", n.Type)
+		}
+		left, err := n.Left.toExpr()
+		if err != nil {
+			return nil, err
+		}
+		right, err := n.Right.toExpr()
+		if err != nil {
+			return nil, err
+		}
+		be := &metricsql.BinaryOpExpr{
+			Op:              n.Op,
+			Bool:            n.Bool,
+			KeepMetricNames: n.KeepMetricNames,
+			Left:            left,
+			Right:           right,
+		}
+		if n.GroupModifier != nil {
+			be.GroupModifier = n.GroupModifier.toModifierExpr()
+		}
+		if n.JoinModifier != nil {
+			be.JoinModifier = n.JoinModifier.toModifierExpr()
+		}
+		if n.JoinPrefix != nil {
+			be.JoinModifierPrefix = &metricsql.StringExpr{
+				S: *n.JoinPrefix,
+			}
+		}
+		return be, nil
+	case "with":
+		if n.Expr == nil {
+			return nil, fmt.Errorf("missing `expr` for %q node", n.Type)
+		}
+		we := &metricsql.WithExpr{
+			Was: make([]*metricsql.WithArgExpr, len(n.Templates)),
+		}
+		for i, tn := range n.Templates {
+			if tn == nil || tn.Type != "template" {
+				return nil, fmt.Errorf("unexpected template #%d for %q node; want %q node", i+1, n.Type, "template")
+			}
+			if tn.Expr == nil {
+				return nil, fmt.Errorf("missing `expr` for %q node", tn.Type)
+			}
+			e, err := tn.Expr.toExpr()
+			if err != nil {
+				return nil, err
+			}
+			we.Was[i] = &metricsql.WithArgExpr{
+				Name: tn.Name,
+				Args: tn.Params,
+				Expr: e,
+			}
+		}
+		e, err := n.Expr.toExpr()
+		if err != nil {
+			return nil, err
+		}
+		we.Expr = e
+		return we, nil
+	case "raw":
+		if n.Value == nil {
+			return nil, fmt.Errorf("missing `value` for %q node", n.Type)
+		}
+		e, _, err := metricsql.ParseRaw(*n.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %w", *n.Value, err)
+		}
+		return e, nil
+	case "number":
+		if n.Value == nil {
+			return nil, fmt.Errorf("missing `value` for %q node", n.Type)
+		}
+		e, err := metricsql.Parse(*n.Value)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse number %q: %w", *n.Value, err)
+		}
+		ne, ok := e.(*metricsql.NumberExpr)
+		if !ok {
+			return nil, fmt.Errorf("unexpected number %q", *n.Value)
+		}
+		return ne, nil
+	case "string":
+		if n.Value == nil {
+			return nil, fmt.Errorf("missing `value` for %q node", n.Type)
+		}
+		return &metricsql.StringExpr{
+			S: *n.Value,
+		}, nil
+	case "duration":
+		if n.Value == nil {
+			return nil, fmt.Errorf("missing `value` for %q node", n.Type)
+		}
+		de, err := parseQueryASTDuration(*n.Value)
+		if err != nil {
+			return nil, err
+		}
+		if de == nil {
+			return nil, fmt.Errorf("duration cannot be empty")
+		}
+		return de, nil
+	default:
+		return nil, fmt.Errorf("unexpected node type %q", n.Type)
+	}
+}
+
+func queryASTArgsToExprs(args []*queryASTNode) ([]metricsql.Expr, error) {
+	exprs := make([]metricsql.Expr, len(args))
+	for i, arg := range args {
+		if arg == nil {
+			return nil, fmt.Errorf("missing arg #%d", i+1)
+		}
+		e, err := arg.toExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = e
+	}
+	return exprs, nil
+}
+
+func (m *queryASTModifier) toModifierExpr() metricsql.ModifierExpr {
+	return metricsql.ModifierExpr{
+		Op:   m.Op,
+		Args: m.Args,
+	}
+}
+
+// parseQueryASTDuration parses duration s.
+//
+// It returns nil for empty s.
+func parseQueryASTDuration(s string) (*metricsql.DurationExpr, error) {
+	if s == "" {
+		return nil, nil
+	}
+	// metricsql.DurationExpr cannot be created outside metricsql package, so obtain it from the parsed `offset`.
+	// The duration may refer WITH template, so it is parsed without expanding.
+	e, _, err := metricsql.ParseRaw("x offset " + s)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse duration %q: %w", s, err)
+	}
+	re, ok := e.(*metricsql.RollupExpr)
+	if !ok || re.Offset == nil || string(re.Offset.AppendString(nil)) != s {
+		return nil, fmt.Errorf("unexpected duration %q", s)
+	}
+	return re.Offset, nil
+}
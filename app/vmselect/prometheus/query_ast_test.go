@@ -0,0 +1,220 @@
+package prometheus
+
+import (
+	"testing"
+)
+
+func TestQueryASTRoundTrip(t *testing.T) {
+	f := func(query, resultExpected string) {
+		t.Helper()
+
+		n, err := newQueryAST(query)
+		if err != nil {
+			t.Fatalf("cannot build AST for %q: %s", query, err)
+		}
+		data, err := marshalQueryAST(n)
+		if err != nil {
+			t.Fatalf("cannot marshal AST for %q: %s", query, err)
+		}
+		result, err := formatQueryAST(data)
+		if err != nil {
+			t.Fatalf("cannot format AST %s: %s", data, err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected query for AST %s\ngot\n%s\nwant\n%s", data, result, resultExpected)
+		}
+	}
+	same := func(query string) {
+		t.Helper()
+		f(query, query)
+	}
+
+	same(`foo`)
+	same(`{}`)
+	same(`{__name__=~"foo|bar"}`)
+	same(`foo{bar="baz",x!="y",a=~"b.+",c!~"d" or z="w"}`)
+	f(`foo\-bar{x\.y="z"}`, `foo\-bar{x.y="z"}`)
+	same(`123`)
+	f(`-1.5e3`, `0 - 1.5e3`)
+	same(`NaN`)
+	same(`"foo\"bar"`)
+	same(`rate(foo[5m])`)
+	same(`rate(foo[5m] offset -1h)`)
+	same(`rate(foo[5m:1m] @ end())`)
+	same(`rate((foo + bar)[5m:])`)
+	same(`foo @ 123`)
+	same(`max_over_time(rate(foo[5m])[1h:1m] offset 1d)`)
+	same(`time()`)
+	same(`label_replace(foo, "dst", "$1", "src", "(.+)")`)
+	same(`abs(foo) keep_metric_names`)
+	same(`round(foo, 0.1)`)
+	same(`(foo, bar)`)
+	f(`sum(rate(foo[5m])) by (job, instance) limit 10`, `sum(rate(foo[5m])) by(job,instance) limit 10`)
+	f(`topk(3, foo) without (x)`, `topk(3, foo) without(x)`)
+	same(`quantile(0.9, foo)`)
+	same(`count_values("x", foo)`)
+	same(`foo + bar`)
+	f(`foo + bar * baz`, `foo + (bar * baz)`)
+	same(`(foo + bar) * baz`)
+	f(`foo > bool 10`, `foo >bool 10`)
+	f(`foo < 1 and bar >= 2`, `(foo < 1) and (bar >= 2)`)
+	f(`foo / on (job) group_left (instance, x) prefix "bar_" bar`, `foo / on(job) group_left(instance,x) prefix "bar_" bar`)
+	f(`foo * ignoring (x) group_right () bar`, `foo * ignoring(x) group_right() bar`)
+	same(`(foo + bar) keep_metric_names`)
+	f(`foo or bar unless baz`, `foo or (bar unless baz)`)
+	same(`foo default 0`)
+	f(`up left_join on (instance) group_left (version) build_info`, `up left_join on(instance) group_left(version) build_info`)
+	f(`sum(foo) - on () group_left sum(bar) by (job)`, `sum(foo) - on() group_left() sum(bar) by(job)`)
+	f(`a + abs(b) keep_metric_names`, `a + (abs(b) keep_metric_names)`)
+
+	// WITH templates are preserved
+	f(`WITH (w = 5m, f(x, y) = x + rate(y[w])) f(foo, bar)`, `WITH (w = 5m, f(x,y) = x + rate(y[w])) f(foo, bar)`)
+	f(`with (x = foo{job="a"}) sum(x) by (instance)`, `WITH (x = foo{job="a"}) sum(x) by(instance)`)
+
+	// expressions depending on WITH templates
+	f(`WITH (commonFilters = {job="a"}, prefix(s) = "x_" + s) foo{commonFilters, instance="b"} + prefix("y")`,
+		`WITH (commonFilters = {job="a"}, prefix(s) = "x_"+s) foo{commonFilters,instance="b"} + (prefix("y"))`)
+
+	// fill() modifier
+	same(`foo left_join on(instance) group_left(x) fill("0") bar`)
+}
+
+func TestQueryASTPositions(t *testing.T) {
+	f := func(query string, positionsExpected []string) {
+		t.Helper()
+
+		n, err := newQueryAST(query)
+		if err != nil {
+			t.Fatalf("cannot build AST for %q: %s", query, err)
+		}
+		var positions []string
+		var visit func(n *queryASTNode)
+		visit = func(n *queryASTNode) {
+			if n == nil {
+				return
+			}
+			positions = append(positions, query[n.Start:n.End])
+			for _, tn := range n.Templates {
+				visit(tn)
+			}
+			visit(n.Expr)
+			visit(n.Left)
+			visit(n.Right)
+			for _, arg := range n.Args {
+				visit(arg)
+			}
+			visit(n.At)
+		}
+		visit(n)
+		if len(positions) != len(positionsExpected) {
+			t.Fatalf("unexpected number of nodes for %q; got %d; want %d; nodes: %q", query, len(positions), len(positionsExpected), positions)
+		}
+		for i, s := range positions {
+			if s != positionsExpected[i] {
+				t.Fatalf("unexpected node #%d for %q\ngot\n%s\nwant\n%s", i, query, s, positionsExpected[i])
+			}
+		}
+	}
+
+	f(`foo`, []string{`foo`})
+	f(`sum(rate(foo[5m])) by (job) / on (job) group_left sum(bar)`, []string{
+		`sum(rate(foo[5m])) by (job) / on (job) group_left sum(bar)`,
+		`sum(rate(foo[5m])) by (job)`,
+		`rate(foo[5m])`,
+		`foo[5m]`,
+		`foo`,
+		`sum(bar)`,
+		`bar`,
+	})
+	f(`(a + b) keep_metric_names`, []string{`(a + b) keep_metric_names`, `a`, `b`})
+	f(`a * (b - c)`, []string{`a * (b - c)`, `a`, `b - c`, `b`, `c`})
+	f(`label_join(a, "x", ",", "y") keep_metric_names`, []string{
+		`label_join(a, "x", ",", "y") keep_metric_names`,
+		`a`,
+		`"x"`,
+		`","`,
+		`"y"`,
+	})
+	f(`(a + b)[5m:] @ (end() - 1h)`, []string{`(a + b)[5m:] @ (end() - 1h)`, `a + b`, `a`, `b`, `end() - 1h`, `end()`, `1h`})
+	f(`WITH (f(x) = x * 2)  f( foo )  # comment`, []string{`WITH (f(x) = x * 2)  f( foo )`, `f(x) = x * 2`, `x * 2`, `x`, `2`, `f( foo )`, `foo`})
+	f(`WITH (filters = {job="a"}) foo{filters}`, []string{`WITH (filters = {job="a"}) foo{filters}`, `filters = {job="a"}`, `{job="a"}`, `foo{filters}`})
+}
+
+func TestQueryASTMarshal(t *testing.T) {
+	f := func(query, resultExpected string) {
+		t.Helper()
+
+		n, err := newQueryAST(query)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		data, err := marshalQueryAST(n)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(data) != resultExpected {
+			t.Fatalf("unexpected AST for %q\ngot\n%s\nwant\n%s", query, data, resultExpected)
+		}
+	}
+
+	f(`sum(rate(foo{job="x"}[5m])) by (instance)`, `{"type":"aggr","start":0,"end":41,"name":"sum","args":[{"type":"func","start":4,"end":26,"name":"rate",`+
+		`"args":[{"type":"rollup","start":9,"end":25,"expr":{"type":"metric","start":9,"end":21,`+
+		`"filters":[[{"label":"__name__","op":"=","value":"foo"},{"label":"job","op":"=","value":"x"}]]},"window":"5m"}]}],`+
+		`"modifier":{"op":"by","args":["instance"]}}`)
+
+	// `<`, `>` and `&` chars mustn't be escaped
+	f(`a > 1`, `{"type":"binaryOp","start":0,"end":5,"op":">",`+
+		`"left":{"type":"metric","start":0,"end":1,"filters":[[{"label":"__name__","op":"=","value":"a"}]]},`+
+		`"right":{"type":"number","start":4,"end":5,"value":"1"}}`)
+
+	// WITH template and raw expression
+	f(`WITH (f(s) = "a_" + s) f("b")`, `{"type":"with","start":0,"end":29,"templates":[{"type":"template","start":6,"end":21,`+
+		`"params":["s"],"name":"f","expr":{"type":"raw","start":13,"end":21,"value":"\"a_\" + s"}}],`+
+		`"expr":{"type":"func","start":23,"end":29,"name":"f","args":[{"type":"string","start":25,"end":28,"value":"b"}]}}`)
+}
+
+func TestFormatQueryASTFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		q, err := formatQueryAST([]byte(data))
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %s; got query %q", data, q)
+		}
+	}
+
+	// invalid json
+	f(`foo`)
+
+	// unknown node type
+	f(`{"type":"foo"}`)
+
+	// invalid label filter op
+	f(`{"type":"metric","filters":[[{"label":"foo","op":"==","value":"bar"}]]}`)
+
+	// missing rollup expr
+	f(`{"type":"rollup","window":"5m"}`)
+
+	// invalid duration
+	f(`{"type":"rollup","expr":{"type":"metric","filters":[[{"label":"__name__","op":"=","value":"foo"}]]},"window":"5m] + bar["}`)
+
+	// unknown function
+	f(`{"type":"func","name":"unknown_func","args":[]}`)
+
+	// missing binary op args
+	f(`{"type":"binaryOp","op":"+","left":{"type":"number","value":"1"}}`)
+
+	// invalid binary op
+	f(`{"type":"binaryOp","op":"foo","left":{"type":"number","value":"1"},"right":{"type":"number","value":"2"}}`)
+
+	// invalid number
+	f(`{"type":"number","value":"foo"}`)
+	f(`{"type":"number"}`)
+
+	// invalid templates
+	f(`{"type":"with","templates":[{"type":"number","value":"1"}],"expr":{"type":"number","value":"1"}}`)
+	f(`{"type":"with","templates":[{"type":"template","name":"x"}],"expr":{"type":"number","value":"1"}}`)
+
+	// invalid raw expression
+	f(`{"type":"raw","value":"foo{"}`)
+}
@@ -0,0 +1,212 @@
+// Code generated by qtc from "query_explain_response.qtpl". DO NOT EDIT.
+// See https://github.com/valyala/quicktemplate for details.
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:1
+package prometheus
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:1
+import (
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/promql"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+)
+
+// QueryExplainResponse generates response for /api/v1/query_explain .
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+import (
+	qtio422016 "io"
+
+	qt422016 "github.com/valyala/quicktemplate"
+)
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+var (
+	_ = qtio422016.Copy
+	_ = qt422016.AcquireByteBuffer
+)
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+func StreamQueryExplainResponse(qw422016 *qt422016.Writer, en *promql.ExplainNode, maxEstimatedSeries int, maxEstimatedSamples int64, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:8
+	qw422016.N().S(`{"status":"success","data":{"estimatedSeries":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:12
+	qw422016.N().D(en.Series)
+//line app/vmselect/prometheus/query_explain_response.qtpl:12
+	qw422016.N().S(`,"estimatedSamples":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:13
+	qw422016.N().DL(en.Samples)
+//line app/vmselect/prometheus/query_explain_response.qtpl:13
+	qw422016.N().S(`,"estimatedIndexLookups":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:14
+	qw422016.N().D(en.IndexLookups)
+//line app/vmselect/prometheus/query_explain_response.qtpl:14
+	qw422016.N().S(`,"maxEstimatedSeries":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:15
+	qw422016.N().D(maxEstimatedSeries)
+//line app/vmselect/prometheus/query_explain_response.qtpl:15
+	qw422016.N().S(`,"maxEstimatedSamples":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:16
+	qw422016.N().DL(maxEstimatedSamples)
+//line app/vmselect/prometheus/query_explain_response.qtpl:16
+	qw422016.N().S(`,"limitExceeded":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+	if (maxEstimatedSeries > 0 && en.Series > maxEstimatedSeries) || (maxEstimatedSamples > 0 && en.Samples > maxEstimatedSamples) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+		qw422016.N().S(`true`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+	} else {
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+		qw422016.N().S(`false`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:17
+	qw422016.N().S(`,"plan":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:18
+	streamexplainNode(qw422016, en)
+//line app/vmselect/prometheus/query_explain_response.qtpl:18
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:21
+	qt.Printf("generate response: estimatedSeries=%d, estimatedSamples=%d", en.Series, en.Samples)
+	qt.Done()
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:24
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:24
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+func WriteQueryExplainResponse(qq422016 qtio422016.Writer, en *promql.ExplainNode, maxEstimatedSeries int, maxEstimatedSamples int64, qt *querytracer.Tracer) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	StreamQueryExplainResponse(qw422016, en, maxEstimatedSeries, maxEstimatedSamples, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+func QueryExplainResponse(en *promql.ExplainNode, maxEstimatedSeries int, maxEstimatedSamples int64, qt *querytracer.Tracer) string {
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	WriteQueryExplainResponse(qb422016, en, maxEstimatedSeries, maxEstimatedSamples, qt)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+	return qs422016
+//line app/vmselect/prometheus/query_explain_response.qtpl:26
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:28
+func streamexplainNode(qw422016 *qt422016.Writer, en *promql.ExplainNode) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:28
+	qw422016.N().S(`{"expr":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:30
+	qw422016.N().Q(en.Expr)
+//line app/vmselect/prometheus/query_explain_response.qtpl:30
+	qw422016.N().S(`,"type":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:31
+	qw422016.N().Q(en.Type)
+//line app/vmselect/prometheus/query_explain_response.qtpl:31
+	qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:32
+	if en.Func != "" {
+//line app/vmselect/prometheus/query_explain_response.qtpl:32
+		qw422016.N().S(`"func":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:33
+		qw422016.N().Q(en.Func)
+//line app/vmselect/prometheus/query_explain_response.qtpl:33
+		qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:34
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:34
+	qw422016.N().S(`"start":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:35
+	qw422016.N().DL(en.Start)
+//line app/vmselect/prometheus/query_explain_response.qtpl:35
+	qw422016.N().S(`,"end":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:36
+	qw422016.N().DL(en.End)
+//line app/vmselect/prometheus/query_explain_response.qtpl:36
+	qw422016.N().S(`,"step":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:37
+	qw422016.N().DL(en.Step)
+//line app/vmselect/prometheus/query_explain_response.qtpl:37
+	qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:38
+	if en.Window > 0 {
+//line app/vmselect/prometheus/query_explain_response.qtpl:38
+		qw422016.N().S(`"window":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:39
+		qw422016.N().DL(en.Window)
+//line app/vmselect/prometheus/query_explain_response.qtpl:39
+		qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:40
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:40
+	qw422016.N().S(`"estimatedSeries":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:41
+	qw422016.N().D(en.Series)
+//line app/vmselect/prometheus/query_explain_response.qtpl:41
+	qw422016.N().S(`,"estimatedSamples":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:42
+	qw422016.N().DL(en.Samples)
+//line app/vmselect/prometheus/query_explain_response.qtpl:42
+	qw422016.N().S(`,"estimatedIndexLookups":`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:43
+	qw422016.N().D(en.IndexLookups)
+//line app/vmselect/prometheus/query_explain_response.qtpl:44
+	if len(en.Children) > 0 {
+//line app/vmselect/prometheus/query_explain_response.qtpl:44
+		qw422016.N().S(`,"children":[`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:46
+		for i, child := range en.Children {
+//line app/vmselect/prometheus/query_explain_response.qtpl:47
+			streamexplainNode(qw422016, child)
+//line app/vmselect/prometheus/query_explain_response.qtpl:48
+			if i+1 < len(en.Children) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:48
+				qw422016.N().S(`,`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:48
+			}
+//line app/vmselect/prometheus/query_explain_response.qtpl:49
+		}
+//line app/vmselect/prometheus/query_explain_response.qtpl:49
+		qw422016.N().S(`]`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:51
+	}
+//line app/vmselect/prometheus/query_explain_response.qtpl:51
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+func writeexplainNode(qq422016 qtio422016.Writer, en *promql.ExplainNode) {
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	streamexplainNode(qw422016, en)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+}
+
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+func explainNode(en *promql.ExplainNode) string {
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	writeexplainNode(qb422016, en)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+	return qs422016
+//line app/vmselect/prometheus/query_explain_response.qtpl:53
+}
@@ -112,43 +112,203 @@ func QueryRangeResponse(rs []netstorage.Result, qt *querytracer.Tracer, qtDone f
 //line app/vmselect/prometheus/query_range_response.qtpl:45
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+// QueryRangeStreamHeader generates the beginning of the streamed response for /api/v1/query_range.It must be followed by comma-separated QueryRangeStreamLine items and either QueryRangeStreamFooter or QueryRangeStreamErrorFooter.The status field is written in the footer, since the outcome of the query isn't known when the header is sent.
+
+//line app/vmselect/prometheus/query_range_response.qtpl:51
+func StreamQueryRangeStreamHeader(qw422016 *qt422016.Writer) {
+//line app/vmselect/prometheus/query_range_response.qtpl:51
+	qw422016.N().S(`{"data":{"resultType":"matrix","result":[`)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+func WriteQueryRangeStreamHeader(qq422016 qtio422016.Writer) {
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	StreamQueryRangeStreamHeader(qw422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+func QueryRangeStreamHeader() string {
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	WriteQueryRangeStreamHeader(qb422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+	return qs422016
+//line app/vmselect/prometheus/query_range_response.qtpl:56
+}
+
+// QueryRangeStreamLine generates a single series for the streamed response for /api/v1/query_range.
+
+//line app/vmselect/prometheus/query_range_response.qtpl:59
+func StreamQueryRangeStreamLine(qw422016 *qt422016.Writer, r *netstorage.Result) {
+//line app/vmselect/prometheus/query_range_response.qtpl:60
+	streamqueryRangeLine(qw422016, r)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+func WriteQueryRangeStreamLine(qq422016 qtio422016.Writer, r *netstorage.Result) {
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	StreamQueryRangeStreamLine(qw422016, r)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+func QueryRangeStreamLine(r *netstorage.Result) string {
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	WriteQueryRangeStreamLine(qb422016, r)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+	return qs422016
+//line app/vmselect/prometheus/query_range_response.qtpl:61
+}
+
+// QueryRangeStreamFooter generates the end of the successful streamed response for /api/v1/query_rangewith seriesCount series and pointsCount points.
+
+//line app/vmselect/prometheus/query_range_response.qtpl:65
+func StreamQueryRangeStreamFooter(qw422016 *qt422016.Writer, seriesCount, pointsCount int, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) {
+//line app/vmselect/prometheus/query_range_response.qtpl:65
+	qw422016.N().S(`]},"status":"success","stats":{"seriesFetched": "`)
+//line app/vmselect/prometheus/query_range_response.qtpl:70
+	qw422016.N().DL(qs.SeriesFetched.Load())
+//line app/vmselect/prometheus/query_range_response.qtpl:70
+	qw422016.N().S(`","executionTimeMsec":`)
+//line app/vmselect/prometheus/query_range_response.qtpl:71
+	qw422016.N().DL(qs.ExecutionTimeMsec.Load())
+//line app/vmselect/prometheus/query_range_response.qtpl:71
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_range_response.qtpl:74
+	qt.Printf("generate /api/v1/query_range response for series=%d, points=%d", seriesCount, pointsCount)
+	qtDone()
+
+//line app/vmselect/prometheus/query_range_response.qtpl:77
+	streamdumpQueryTrace(qw422016, qt)
+//line app/vmselect/prometheus/query_range_response.qtpl:77
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+func WriteQueryRangeStreamFooter(qq422016 qtio422016.Writer, seriesCount, pointsCount int, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) {
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	StreamQueryRangeStreamFooter(qw422016, seriesCount, pointsCount, qt, qtDone, qs)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+func QueryRangeStreamFooter(seriesCount, pointsCount int, qt *querytracer.Tracer, qtDone func(), qs *promql.QueryStats) string {
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	WriteQueryRangeStreamFooter(qb422016, seriesCount, pointsCount, qt, qtDone, qs)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+	return qs422016
+//line app/vmselect/prometheus/query_range_response.qtpl:79
+}
+
+// QueryRangeStreamErrorFooter generates the end of the streamed response for /api/v1/query_range,which failed with the given err after the header has been sent.The already sent series are left in the response, while the status field is set to error.
+
+//line app/vmselect/prometheus/query_range_response.qtpl:84
+func StreamQueryRangeStreamErrorFooter(qw422016 *qt422016.Writer, err error) {
+//line app/vmselect/prometheus/query_range_response.qtpl:84
+	qw422016.N().S(`]},"status":"error","errorType":"execution","error":`)
+//line app/vmselect/prometheus/query_range_response.qtpl:89
+	qw422016.N().Q(err.Error())
+//line app/vmselect/prometheus/query_range_response.qtpl:89
+	qw422016.N().S(`}`)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+func WriteQueryRangeStreamErrorFooter(qq422016 qtio422016.Writer, err error) {
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	qw422016 := qt422016.AcquireWriter(qq422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	StreamQueryRangeStreamErrorFooter(qw422016, err)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	qt422016.ReleaseWriter(qw422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+func QueryRangeStreamErrorFooter(err error) string {
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	qb422016 := qt422016.AcquireByteBuffer()
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	WriteQueryRangeStreamErrorFooter(qb422016, err)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	qs422016 := string(qb422016.B)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	qt422016.ReleaseByteBuffer(qb422016)
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+	return qs422016
+//line app/vmselect/prometheus/query_range_response.qtpl:91
+}
+
+//line app/vmselect/prometheus/query_range_response.qtpl:93
 func streamqueryRangeLine(qw422016 *qt422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:47
+//line app/vmselect/prometheus/query_range_response.qtpl:93
 	qw422016.N().S(`{"metric":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:49
+//line app/vmselect/prometheus/query_range_response.qtpl:95
 	streammetricNameObject(qw422016, &r.MetricName)
-//line app/vmselect/prometheus/query_range_response.qtpl:49
+//line app/vmselect/prometheus/query_range_response.qtpl:95
 	qw422016.N().S(`,"values":`)
-//line app/vmselect/prometheus/query_range_response.qtpl:50
+//line app/vmselect/prometheus/query_range_response.qtpl:96
 	streamvaluesWithTimestamps(qw422016, r.Values, r.Timestamps)
-//line app/vmselect/prometheus/query_range_response.qtpl:50
+//line app/vmselect/prometheus/query_range_response.qtpl:96
 	qw422016.N().S(`}`)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 func writequeryRangeLine(qq422016 qtio422016.Writer, r *netstorage.Result) {
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	qw422016 := qt422016.AcquireWriter(qq422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	streamqueryRangeLine(qw422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	qt422016.ReleaseWriter(qw422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 }
 
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 func queryRangeLine(r *netstorage.Result) string {
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	qb422016 := qt422016.AcquireByteBuffer()
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	writequeryRangeLine(qb422016, r)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	qs422016 := string(qb422016.B)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	qt422016.ReleaseByteBuffer(qb422016)
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 	return qs422016
-//line app/vmselect/prometheus/query_range_response.qtpl:52
+//line app/vmselect/prometheus/query_range_response.qtpl:98
 }
@@ -0,0 +1,369 @@
+package prometheus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+const (
+	// maxRemoteReadRequestSize is the maximum size of the decompressed remote read request.
+	maxRemoteReadRequestSize = 32 * 1024 * 1024
+
+	// remoteReadMaxSamplesPerChunk is the maximum number of samples per XOR chunk in streamed response.
+	//
+	// It is the same as the number of samples per chunk in Prometheus TSDB.
+	remoteReadMaxSamplesPerChunk = 120
+
+	// remoteReadMaxFrameSize is the maximum size of a single frame in streamed response.
+	//
+	// Frames may exceed this size if they contain a single big series.
+	remoteReadMaxFrameSize = 1024 * 1024
+)
+
+// RemoteReadHandler processes /api/v1/read request.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/remote_read_api/
+func RemoteReadHandler(startTime time.Time, w http.ResponseWriter, r *http.Request) error {
+	defer remoteReadDuration.UpdateDuration(startTime)
+
+	req, err := readRemoteReadRequest(r)
+	if err != nil {
+		return err
+	}
+	deadline := searchutils.GetDeadlineForQuery(r, startTime)
+	etfs, err := searchutils.GetExtraTagFilters(r)
+	if err != nil {
+		return err
+	}
+
+	if isStreamedRemoteReadRequest(req) {
+		w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+		fw := &remoteReadFrameWriter{
+			w: w,
+		}
+		for i, q := range req.Queries {
+			if err := writeRemoteReadChunkedSeries(fw, q, int64(i), etfs, deadline); err != nil {
+				return fmt.Errorf("cannot process query #%d: %w", i, err)
+			}
+		}
+		return nil
+	}
+
+	resp := &prompb.ReadResponse{
+		Results: make([]*prompb.QueryResult, len(req.Queries)),
+	}
+	for i, q := range req.Queries {
+		tss, err := getRemoteReadTimeSeries(q, etfs, deadline)
+		if err != nil {
+			return fmt.Errorf("cannot process query #%d: %w", i, err)
+		}
+		resp.Results[i] = &prompb.QueryResult{
+			Timeseries: tss,
+		}
+	}
+	data, err := resp.Marshal()
+	if err != nil {
+		return fmt.Errorf("cannot marshal remote read response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Header().Set("Content-Encoding", "snappy")
+	if _, err := w.Write(snappy.Encode(nil, data)); err != nil {
+		return fmt.Errorf("cannot send response to remote client: %w", err)
+	}
+	return nil
+}
+
+var remoteReadDuration = metrics.NewSummary(`vm_request_duration_seconds{path="/api/v1/read"}`)
+
+func readRemoteReadRequest(r *http.Request) (*prompb.ReadRequest, error) {
+	compressed, err := io.ReadAll(io.LimitReader(r.Body, maxRemoteReadRequestSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read remote read request: %w", err)
+	}
+	if len(compressed) > maxRemoteReadRequestSize {
+		return nil, fmt.Errorf("too big remote read request; it mustn't exceed %d bytes", maxRemoteReadRequestSize)
+	}
+	n, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress snappy-encoded remote read request: %w", err)
+	}
+	if n > maxRemoteReadRequestSize {
+		return nil, fmt.Errorf("too big decompressed remote read request; it mustn't exceed %d bytes; got %d bytes", maxRemoteReadRequestSize, n)
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress snappy-encoded remote read request: %w", err)
+	}
+	var req prompb.ReadRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal remote read request: %w", err)
+	}
+	return &req, nil
+}
+
+// isStreamedRemoteReadRequest returns true if the client accepts streamed XOR chunks response for req.
+//
+// The client must receive samples response if it doesn't specify accepted response types.
+func isStreamedRemoteReadRequest(req *prompb.ReadRequest) bool {
+	for _, rt := range req.AcceptedResponseTypes {
+		switch rt {
+		case prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			return true
+		case prompb.ReadRequest_SAMPLES:
+			return false
+		}
+	}
+	return false
+}
+
+func getRemoteReadSearchQuery(q *prompb.Query, etfs [][]storage.TagFilter) (*storage.SearchQuery, error) {
+	tfs := make([]storage.TagFilter, 0, len(q.Matchers))
+	for _, m := range q.Matchers {
+		tf := storage.TagFilter{
+			Key:   []byte(m.Name),
+			Value: []byte(m.Value),
+		}
+		if m.Name == "__name__" {
+			// This is required for storage.Search.
+			tf.Key = nil
+		}
+		switch m.Type {
+		case prompb.LabelMatcher_EQ:
+		case prompb.LabelMatcher_NEQ:
+			tf.IsNegative = true
+		case prompb.LabelMatcher_RE:
+			tf.IsRegexp = true
+		case prompb.LabelMatcher_NRE:
+			tf.IsNegative = true
+			tf.IsRegexp = true
+		default:
+			return nil, fmt.Errorf("unsupported matcher type %d for label %q", m.Type, m.Name)
+		}
+		tfs = append(tfs, tf)
+	}
+	tfss := searchutils.JoinTagFilterss([][]storage.TagFilter{tfs}, etfs)
+	return storage.NewSearchQuery(q.StartTimestampMs, q.EndTimestampMs, tfss, *maxRemoteReadSeries), nil
+}
+
+// processRemoteReadQuery calls f for every series matching q.
+//
+// f may be called from concurrently running goroutines.
+func processRemoteReadQuery(q *prompb.Query, etfs [][]storage.TagFilter, deadline searchutils.Deadline, f func(rs *netstorage.Result) error) error {
+	sq, err := getRemoteReadSearchQuery(q, etfs)
+	if err != nil {
+		return err
+	}
+	rss, err := netstorage.ProcessSearchQuery(nil, sq, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+	}
+	return rss.RunParallel(nil, func(rs *netstorage.Result, _ uint) error {
+		if len(rs.Timestamps) == 0 {
+			return nil
+		}
+		return f(rs)
+	})
+}
+
+func getRemoteReadTimeSeries(q *prompb.Query, etfs [][]storage.TagFilter, deadline searchutils.Deadline) ([]*prompb.TimeSeries, error) {
+	var tssLock sync.Mutex
+	var tss []*prompb.TimeSeries
+	err := processRemoteReadQuery(q, etfs, deadline, func(rs *netstorage.Result) error {
+		samples := make([]prompb.Sample, len(rs.Timestamps))
+		for i, ts := range rs.Timestamps {
+			samples[i] = prompb.Sample{
+				Timestamp: ts,
+				Value:     rs.Values[i],
+			}
+		}
+		ts := &prompb.TimeSeries{
+			Labels:  getRemoteReadLabels(&rs.MetricName),
+			Samples: samples,
+		}
+		tssLock.Lock()
+		tss = append(tss, ts)
+		tssLock.Unlock()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(tss, func(i, j int) bool {
+		return lessRemoteReadLabels(tss[i].Labels, tss[j].Labels)
+	})
+	return tss, nil
+}
+
+// writeRemoteReadChunkedSeries writes series matching q to fw.
+//
+// Series are written in the order they are stored in the storage as soon as they are read,
+// so the response isn't buffered in memory. Processing is stopped on the first error.
+func writeRemoteReadChunkedSeries(fw *remoteReadFrameWriter, q *prompb.Query, queryIndex int64, etfs [][]storage.TagFilter, deadline searchutils.Deadline) error {
+	sq, err := getRemoteReadSearchQuery(q, etfs)
+	if err != nil {
+		return err
+	}
+	rss, err := netstorage.ProcessSearchQuery(nil, sq, deadline)
+	if err != nil {
+		return fmt.Errorf("cannot fetch data for %q: %w", sq, err)
+	}
+	fw.reset(queryIndex)
+	err = rss.RunSequential(nil, func(rs *netstorage.Result) error {
+		chunks, err := marshalRemoteReadXORChunks(rs.Timestamps, rs.Values)
+		if err != nil {
+			return err
+		}
+		cs := &prompb.ChunkedSeries{
+			Labels: getRemoteReadLabels(&rs.MetricName),
+			Chunks: chunks,
+		}
+		return fw.addChunkedSeries(cs)
+	})
+	if err != nil {
+		return err
+	}
+	return fw.flush()
+}
+
+// marshalRemoteReadXORChunks returns XOR chunks for the given samples.
+func marshalRemoteReadXORChunks(timestamps []int64, values []float64) ([]prompb.Chunk, error) {
+	chunks := make([]prompb.Chunk, 0, (len(timestamps)+remoteReadMaxSamplesPerChunk-1)/remoteReadMaxSamplesPerChunk)
+	for len(timestamps) > 0 {
+		n := remoteReadMaxSamplesPerChunk
+		if n > len(timestamps) {
+			n = len(timestamps)
+		}
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		if err != nil {
+			return nil, fmt.Errorf("cannot create XOR chunk appender: %w", err)
+		}
+		for i := 0; i < n; i++ {
+			app.Append(timestamps[i], values[i])
+		}
+		chunks = append(chunks, prompb.Chunk{
+			MinTimeMs: timestamps[0],
+			MaxTimeMs: timestamps[n-1],
+			Type:      prompb.Chunk_XOR,
+			Data:      c.Bytes(),
+		})
+		timestamps = timestamps[n:]
+		values = values[n:]
+	}
+	return chunks, nil
+}
+
+// getRemoteReadLabels returns labels for mn sorted by name.
+func getRemoteReadLabels(mn *storage.MetricName) []prompb.Label {
+	labels := make([]prompb.Label, 0, len(mn.Tags)+1)
+	if len(mn.MetricGroup) > 0 {
+		labels = append(labels, prompb.Label{
+			Name:  "__name__",
+			Value: string(mn.MetricGroup),
+		})
+	}
+	for _, tag := range mn.Tags {
+		labels = append(labels, prompb.Label{
+			Name:  string(tag.Key),
+			Value: string(tag.Value),
+		})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+	return labels
+}
+
+func lessRemoteReadLabels(a, b []prompb.Label) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if n := strings.Compare(a[i].Name, b[i].Name); n != 0 {
+			return n < 0
+		}
+		if n := strings.Compare(a[i].Value, b[i].Value); n != 0 {
+			return n < 0
+		}
+	}
+	return len(a) < len(b)
+}
+
+// remoteReadFrameWriter writes ChunkedReadResponse messages in the framed format expected by Prometheus remote read clients.
+//
+// Every frame contains uvarint-encoded message size, followed by big-endian CRC32 Castagnoli checksum of the message and the message itself.
+type remoteReadFrameWriter struct {
+	w   http.ResponseWriter
+	buf []byte
+
+	// resp contains series, which weren't written to w yet.
+	resp      prompb.ChunkedReadResponse
+	frameSize int
+}
+
+// reset prepares fw for writing series for the query with the given queryIndex.
+func (fw *remoteReadFrameWriter) reset(queryIndex int64) {
+	clear(fw.resp.ChunkedSeries)
+	fw.resp.ChunkedSeries = fw.resp.ChunkedSeries[:0]
+	fw.resp.QueryIndex = queryIndex
+	fw.frameSize = 0
+}
+
+// addChunkedSeries adds cs to the current frame.
+//
+// The current frame is written to the client if cs doesn't fit it.
+func (fw *remoteReadFrameWriter) addChunkedSeries(cs *prompb.ChunkedSeries) error {
+	size := cs.Size()
+	if len(fw.resp.ChunkedSeries) > 0 && fw.frameSize+size > remoteReadMaxFrameSize {
+		if err := fw.flush(); err != nil {
+			return err
+		}
+	}
+	fw.resp.ChunkedSeries = append(fw.resp.ChunkedSeries, cs)
+	fw.frameSize += size
+	return nil
+}
+
+// flush writes the current frame to the client.
+func (fw *remoteReadFrameWriter) flush() error {
+	if len(fw.resp.ChunkedSeries) == 0 {
+		return nil
+	}
+	if err := fw.writeFrame(&fw.resp); err != nil {
+		return fmt.Errorf("cannot send response to remote client: %w", err)
+	}
+	fw.reset(fw.resp.QueryIndex)
+	return nil
+}
+
+func (fw *remoteReadFrameWriter) writeFrame(resp *prompb.ChunkedReadResponse) error {
+	data, err := resp.Marshal()
+	if err != nil {
+		return fmt.Errorf("cannot marshal ChunkedReadResponse: %w", err)
+	}
+	fw.buf = binary.AppendUvarint(fw.buf[:0], uint64(len(data)))
+	fw.buf = binary.BigEndian.AppendUint32(fw.buf, crc32.Checksum(data, castagnoliTable))
+	fw.buf = append(fw.buf, data...)
+	if _, err := fw.w.Write(fw.buf); err != nil {
+		return err
+	}
+	if f, ok := fw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
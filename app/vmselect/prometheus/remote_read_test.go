@@ -0,0 +1,210 @@
+package prometheus
+
+import (
+	"bytes"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+func TestIsStreamedRemoteReadRequest(t *testing.T) {
+	f := func(rts []prompb.ReadRequest_ResponseType, resultExpected bool) {
+		t.Helper()
+		req := &prompb.ReadRequest{
+			AcceptedResponseTypes: rts,
+		}
+		if result := isStreamedRemoteReadRequest(req); result != resultExpected {
+			t.Fatalf("unexpected result for %v; got %v; want %v", rts, result, resultExpected)
+		}
+	}
+
+	f(nil, false)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES}, false)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS}, true)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS, prompb.ReadRequest_SAMPLES}, true)
+	f([]prompb.ReadRequest_ResponseType{prompb.ReadRequest_SAMPLES, prompb.ReadRequest_STREAMED_XOR_CHUNKS}, false)
+}
+
+func TestGetRemoteReadSearchQuery(t *testing.T) {
+	q := &prompb.Query{
+		StartTimestampMs: 1000,
+		EndTimestampMs:   2000,
+		Matchers: []*prompb.LabelMatcher{
+			{Type: prompb.LabelMatcher_EQ, Name: "__name__", Value: "foo"},
+			{Type: prompb.LabelMatcher_NEQ, Name: "job", Value: "bar"},
+			{Type: prompb.LabelMatcher_RE, Name: "instance", Value: "host-.+"},
+			{Type: prompb.LabelMatcher_NRE, Name: "env", Value: "dev|test"},
+		},
+	}
+	sq, err := getRemoteReadSearchQuery(q, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if sq.MinTimestamp != 1000 || sq.MaxTimestamp != 2000 {
+		t.Fatalf("unexpected time range; got [%d..%d]; want [1000..2000]", sq.MinTimestamp, sq.MaxTimestamp)
+	}
+	tfssExpected := [][]storage.TagFilter{{
+		{Key: nil, Value: []byte("foo")},
+		{Key: []byte("job"), Value: []byte("bar"), IsNegative: true},
+		{Key: []byte("instance"), Value: []byte("host-.+"), IsRegexp: true},
+		{Key: []byte("env"), Value: []byte("dev|test"), IsNegative: true, IsRegexp: true},
+	}}
+	if !reflect.DeepEqual(sq.TagFilterss, tfssExpected) {
+		t.Fatalf("unexpected tag filters;\ngot\n%v\nwant\n%v", sq.TagFilterss, tfssExpected)
+	}
+
+	// Unsupported matcher type
+	q.Matchers = append(q.Matchers, &prompb.LabelMatcher{Type: 123, Name: "foo", Value: "bar"})
+	if _, err := getRemoteReadSearchQuery(q, nil); err == nil {
+		t.Fatalf("expecting non-nil error for unsupported matcher type")
+	}
+}
+
+func TestGetRemoteReadLabels(t *testing.T) {
+	var mn storage.MetricName
+	mn.MetricGroup = []byte("foo")
+	mn.AddTag("job", "bar")
+	mn.AddTag("Zone", "a")
+	labels := getRemoteReadLabels(&mn)
+	labelsExpected := []prompb.Label{
+		{Name: "Zone", Value: "a"},
+		{Name: "__name__", Value: "foo"},
+		{Name: "job", Value: "bar"},
+	}
+	if !reflect.DeepEqual(labels, labelsExpected) {
+		t.Fatalf("unexpected labels;\ngot\n%v\nwant\n%v", labels, labelsExpected)
+	}
+}
+
+func TestRemoteReadXORChunks(t *testing.T) {
+	var timestamps []int64
+	var values []float64
+	for i := 0; i < 250; i++ {
+		timestamps = append(timestamps, int64(i)*15000)
+		values = append(values, float64(i)*1.5)
+	}
+	chunks, err := marshalRemoteReadXORChunks(timestamps, values)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cs := &prompb.ChunkedSeries{
+		Labels: []prompb.Label{{Name: "__name__", Value: "foo"}},
+		Chunks: chunks,
+	}
+
+	// Verify that the written frames can be read by Prometheus remote read client.
+	w := httptest.NewRecorder()
+	fw := &remoteReadFrameWriter{
+		w: w,
+	}
+	fw.reset(3)
+	if err := fw.addChunkedSeries(cs); err != nil {
+		t.Fatalf("cannot add chunked series: %s", err)
+	}
+	if err := fw.flush(); err != nil {
+		t.Fatalf("cannot write chunked series: %s", err)
+	}
+	cr := remote.NewChunkedReader(bytes.NewReader(w.Body.Bytes()), remoteReadMaxFrameSize, nil)
+	var resp prompb.ChunkedReadResponse
+	if err := cr.NextProto(&resp); err != nil {
+		t.Fatalf("cannot read frame: %s", err)
+	}
+	if resp.QueryIndex != 3 {
+		t.Fatalf("unexpected QueryIndex; got %d; want %d", resp.QueryIndex, 3)
+	}
+	if len(resp.ChunkedSeries) != 1 {
+		t.Fatalf("unexpected number of series; got %d; want %d", len(resp.ChunkedSeries), 1)
+	}
+	chunks = resp.ChunkedSeries[0].Chunks
+	if len(chunks) != 3 {
+		t.Fatalf("unexpected number of chunks; got %d; want %d", len(chunks), 3)
+	}
+
+	var timestampsGot []int64
+	var valuesGot []float64
+	for _, c := range chunks {
+		if c.Type != prompb.Chunk_XOR {
+			t.Fatalf("unexpected chunk type; got %s; want %s", c.Type, prompb.Chunk_XOR)
+		}
+		xc, err := chunkenc.FromData(chunkenc.EncXOR, c.Data)
+		if err != nil {
+			t.Fatalf("cannot decode chunk: %s", err)
+		}
+		it := xc.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			ts, v := it.At()
+			timestampsGot = append(timestampsGot, ts)
+			valuesGot = append(valuesGot, v)
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("cannot iterate over chunk samples: %s", err)
+		}
+		if c.MinTimeMs != timestampsGot[len(timestampsGot)-xc.NumSamples()] || c.MaxTimeMs != timestampsGot[len(timestampsGot)-1] {
+			t.Fatalf("unexpected chunk time range [%d..%d]", c.MinTimeMs, c.MaxTimeMs)
+		}
+	}
+	if !reflect.DeepEqual(timestampsGot, timestamps) {
+		t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", timestampsGot, timestamps)
+	}
+	if !reflect.DeepEqual(valuesGot, values) {
+		t.Fatalf("unexpected values;\ngot\n%v\nwant\n%v", valuesGot, values)
+	}
+}
+
+func TestRemoteReadFrameWriter(t *testing.T) {
+	// Every series occupies more than a half of the max frame size, so it must be written in a separate frame.
+	data := make([]byte, remoteReadMaxFrameSize/2+1)
+	w := httptest.NewRecorder()
+	fw := &remoteReadFrameWriter{
+		w: w,
+	}
+	fw.reset(1)
+	var namesExpected []string
+	for _, name := range []string{"foo", "bar", "baz"} {
+		cs := &prompb.ChunkedSeries{
+			Labels: []prompb.Label{{Name: "__name__", Value: name}},
+			Chunks: []prompb.Chunk{{Type: prompb.Chunk_XOR, Data: data}},
+		}
+		if err := fw.addChunkedSeries(cs); err != nil {
+			t.Fatalf("cannot add chunked series: %s", err)
+		}
+		namesExpected = append(namesExpected, name)
+	}
+	if err := fw.flush(); err != nil {
+		t.Fatalf("cannot write chunked series: %s", err)
+	}
+
+	// Verify that series are written in the order they were added.
+	cr := remote.NewChunkedReader(bytes.NewReader(w.Body.Bytes()), 2*remoteReadMaxFrameSize, nil)
+	var names []string
+	frames := 0
+	for {
+		var resp prompb.ChunkedReadResponse
+		if err := cr.NextProto(&resp); err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("cannot read frame: %s", err)
+		}
+		frames++
+		if resp.QueryIndex != 1 {
+			t.Fatalf("unexpected QueryIndex; got %d; want %d", resp.QueryIndex, 1)
+		}
+		for _, cs := range resp.ChunkedSeries {
+			names = append(names, cs.Labels[0].Value)
+		}
+	}
+	if frames != len(namesExpected) {
+		t.Fatalf("unexpected number of frames; got %d; want %d", frames, len(namesExpected))
+	}
+	if !reflect.DeepEqual(names, namesExpected) {
+		t.Fatalf("unexpected series order; got %q; want %q", names, namesExpected)
+	}
+}
@@ -3,6 +3,8 @@ package promql
 import (
 	"fmt"
 	"math"
+	"regexp"
+	"slices"
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
@@ -39,6 +41,10 @@ var binaryOpFuncs = map[string]binaryOpFunc{
 	"if":      binaryOpIf,
 	"ifnot":   binaryOpIfnot,
 	"default": binaryOpDefault,
+
+	// join ops
+	"left_join":  binaryOpLeftJoin,
+	"outer_join": binaryOpOuterJoin,
 }
 
 func getBinaryOpFunc(op string) binaryOpFunc {
@@ -571,6 +577,179 @@ func addLeftNaNsIfNoRightNaNs(tssLeft, tssRight []*timeseries) []*timeseries {
 	return removeEmptySeries(tssLeft)
 }
 
+func binaryOpLeftJoin(bfa *binaryOpFuncArg) ([]*timeseries, error) {
+	return binaryOpJoin(bfa, false)
+}
+
+func binaryOpOuterJoin(bfa *binaryOpFuncArg) ([]*timeseries, error) {
+	return binaryOpJoin(bfa, true)
+}
+
+// binaryOpJoin returns all the series from the left side of `left_join` or `outer_join`.
+//
+// Labels enumerated in group_left() are copied to the left series from the matching right series.
+// Series are matched after applying transform() modifiers to label values on both sides.
+// Left series without matching right series are returned as is, or with group_left() labels set to fill() value.
+// Right series without matching left series are returned only if isOuter is set.
+func binaryOpJoin(bfa *binaryOpFuncArg, isOuter bool) ([]*timeseries, error) {
+	be := bfa.be
+	if joinOp := strings.ToLower(be.JoinModifier.Op); joinOp != "" && joinOp != "group_left" {
+		return nil, fmt.Errorf("%s doesn't support %s modifier; use group_left() for copying labels from the right side", be.Op, joinOp)
+	}
+	if be.JoinFill != nil {
+		joinTags := be.JoinModifier.Args
+		if len(joinTags) == 0 {
+			return nil, fmt.Errorf("%s: fill() modifier requires group_left() with the list of labels to fill", be.Op)
+		}
+		if len(joinTags) == 1 && joinTags[0] == "*" {
+			return nil, fmt.Errorf("%s: fill() modifier cannot be used with group_left(*), since labels to fill are unknown", be.Op)
+		}
+	}
+	mts, err := compileMatchTransforms(be.MatchTransforms)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", be.Op, err)
+	}
+	mLeft, mRight := createTimeseriesMapByTagSetWithTransforms(be, bfa.left, bfa.right, mts)
+	var rvs []*timeseries
+	for k, tssLeft := range mLeft {
+		tssRight := mRight[k]
+		if len(tssRight) == 0 || len(be.JoinModifier.Op) == 0 {
+			if len(tssRight) == 0 && be.JoinFill != nil {
+				for _, tsLeft := range tssLeft {
+					fillJoinTags(be, tsLeft)
+				}
+			}
+			rvs = append(rvs, tssLeft...)
+			continue
+		}
+		for _, tsLeft := range tssLeft {
+			rvs = joinTimeseries(rvs, be, tsLeft, tssRight)
+		}
+	}
+	// Sort left-hand-side series by metric name in the same way as `or` does.
+	sortSeriesByMetricName(rvs)
+	if !isOuter {
+		return rvs, nil
+	}
+
+	rvsLen := len(rvs)
+	for k, tssRight := range mRight {
+		if _, ok := mLeft[k]; !ok {
+			rvs = append(rvs, tssRight...)
+		}
+	}
+	sortSeriesByMetricName(rvs[rvsLen:])
+	return rvs, nil
+}
+
+// fillJoinTags sets labels enumerated in group_left() to the fill() value at tsLeft without matching right series.
+func fillJoinTags(be *metricsql.BinaryOpExpr, tsLeft *timeseries) {
+	var skipTags []string
+	if strings.EqualFold(be.GroupModifier.Op, "on") {
+		skipTags = be.GroupModifier.Args
+	}
+	joinPrefix := ""
+	if be.JoinModifierPrefix != nil {
+		joinPrefix = be.JoinModifierPrefix.S
+	}
+	fillValue := be.JoinFill.S
+	mn := &tsLeft.MetricName
+	for _, tagName := range be.JoinModifier.Args {
+		if slices.Contains(skipTags, tagName) {
+			continue
+		}
+		if tagName == "__name__" {
+			mn.MetricGroup = append(mn.MetricGroup[:0], fillValue...)
+			continue
+		}
+		mn.RemoveTag(joinPrefix + tagName)
+		if fillValue != "" {
+			mn.AddTag(joinPrefix+tagName, fillValue)
+		}
+	}
+}
+
+// matchTransform is a compiled metricsql.MatchTransform.
+type matchTransform struct {
+	label       string
+	re          *regexp.Regexp
+	replacement []byte
+}
+
+func compileMatchTransforms(a []*metricsql.MatchTransform) ([]matchTransform, error) {
+	if len(a) == 0 {
+		return nil, nil
+	}
+	mts := make([]matchTransform, len(a))
+	for i, mt := range a {
+		re, err := metricsql.CompileRegexpAnchored(mt.Regex.S)
+		if err != nil {
+			return nil, fmt.Errorf("cannot compile transform() regex %q for label %q: %w", mt.Regex.S, mt.Label, err)
+		}
+		mts[i] = matchTransform{
+			label:       mt.Label,
+			re:          re,
+			replacement: []byte(mt.Replacement.S),
+		}
+	}
+	return mts, nil
+}
+
+// applyMatchTransforms applies mts to mn in the same way as label_replace(mn, label, replacement, label, regex) does.
+func applyMatchTransforms(mn *storage.MetricName, mts []matchTransform) {
+	for i := range mts {
+		mt := &mts[i]
+		srcValue := mn.GetTagValue(mt.label)
+		if !mt.re.Match(srcValue) {
+			continue
+		}
+		b := mt.re.ReplaceAll(srcValue, mt.replacement)
+		dstValue := getDstValue(mn, mt.label)
+		*dstValue = append((*dstValue)[:0], b...)
+		if len(b) == 0 {
+			mn.RemoveTag(mt.label)
+		}
+	}
+}
+
+// joinTimeseries appends tsLeft with the labels from group_left() copied from tssRight to dst.
+//
+// tsLeft is appended multiple times if tssRight contain distinct values for the copied labels.
+func joinTimeseries(dst []*timeseries, be *metricsql.BinaryOpExpr, tsLeft *timeseries, tssRight []*timeseries) []*timeseries {
+	joinTags := be.JoinModifier.Args
+	var skipTags []string
+	if strings.EqualFold(be.GroupModifier.Op, "on") {
+		skipTags = be.GroupModifier.Args
+	}
+	joinPrefix := ""
+	if be.JoinModifierPrefix != nil {
+		joinPrefix = be.JoinModifierPrefix.S
+	}
+	if len(tssRight) == 1 {
+		// Easy case - right part contains only a single matching time series.
+		tsLeft.MetricName.SetTags(joinTags, joinPrefix, skipTags, &tssRight[0].MetricName)
+		return append(dst, tsLeft)
+	}
+
+	// Hard case - right part contains multiple matching time series.
+	// Values are always taken from tsLeft, so right series resulting in the same labels are collapsed into a single series.
+	m := make(map[string]struct{}, len(tssRight))
+	bb := bbPool.Get()
+	for _, tsRight := range tssRight {
+		var tsCopy timeseries
+		tsCopy.CopyFromShallowTimestamps(tsLeft)
+		tsCopy.MetricName.SetTags(joinTags, joinPrefix, skipTags, &tsRight.MetricName)
+		bb.B = marshalMetricNameSorted(bb.B[:0], &tsCopy.MetricName)
+		if _, ok := m[string(bb.B)]; ok {
+			continue
+		}
+		m[string(bb.B)] = struct{}{}
+		dst = append(dst, &tsCopy)
+	}
+	bbPool.Put(bb)
+	return dst
+}
+
 func seriesByKey(m map[string][]*timeseries, key string) []*timeseries {
 	tss := m[key]
 	if tss != nil {
@@ -589,6 +768,15 @@ func seriesByKey(m map[string][]*timeseries, key string) []*timeseries {
 }
 
 func createTimeseriesMapByTagSet(be *metricsql.BinaryOpExpr, left, right []*timeseries) (map[string][]*timeseries, map[string][]*timeseries) {
+	return createTimeseriesMapByTagSetWithTransforms(be, left, right, nil)
+}
+
+// createTimeseriesMapByTagSetWithTransforms works in the same way as createTimeseriesMapByTagSet,
+// but it applies mts to label values before calculating the matching keys.
+//
+// The original labels of the series are left untouched.
+func createTimeseriesMapByTagSetWithTransforms(be *metricsql.BinaryOpExpr, left, right []*timeseries,
+	mts []matchTransform) (map[string][]*timeseries, map[string][]*timeseries) {
 	groupTags := be.GroupModifier.Args
 	groupOp := strings.ToLower(be.GroupModifier.Op)
 	if len(groupOp) == 0 {
@@ -603,6 +791,7 @@ func createTimeseriesMapByTagSet(be *metricsql.BinaryOpExpr, left, right []*time
 			if !be.KeepMetricNames {
 				mn.ResetMetricGroup()
 			}
+			applyMatchTransforms(mn, mts)
 			switch groupOp {
 			case "on":
 				mn.RemoveTagsOn(groupTags)
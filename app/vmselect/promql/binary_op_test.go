@@ -0,0 +1,150 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+func TestBinaryOpJoin(t *testing.T) {
+	f := func(q, left, right, resultExpected string) {
+		t.Helper()
+
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		be, ok := e.(*metricsql.BinaryOpExpr)
+		if !ok {
+			t.Fatalf("expecting binary op; got %T", e)
+		}
+		bf := getBinaryOpFunc(be.Op)
+		if bf == nil {
+			t.Fatalf("missing binary op func for %q", be.Op)
+		}
+		bfa := &binaryOpFuncArg{
+			be:    be,
+			left:  newTestJoinSeries(t, left),
+			right: newTestJoinSeries(t, right),
+		}
+		tss, err := bf(bfa)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var a []string
+		for _, ts := range tss {
+			a = append(a, fmt.Sprintf("%s %g", stringMetricName(&ts.MetricName), ts.Values[0]))
+		}
+		result := strings.Join(a, "\n")
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q\ngot\n%s\nwant\n%s", q, result, resultExpected)
+		}
+	}
+
+	// left_join without matching series
+	f(`a left_join on(instance) group_left(version) b`, `up{instance="a"} 1`, ``, `up{instance="a"} 1`)
+	f(`a left_join on(instance) group_left(version) b`, `up{instance="a"} 1`, `info{instance="b", version="v1"} 1`, `up{instance="a"} 1`)
+
+	// left_join with matching series
+	f(`a left_join on(instance) group_left(version) b`, `up{instance="a"} 1
+up{instance="b"} 0`, `info{instance="b", version="v1"} 5`, `up{instance="a"} 1
+up{instance="b", version="v1"} 0`)
+	f(`a left_join on(instance) group_left(*) b`, `up{instance="a"} 1`, `info{instance="a", version="v1", os="linux"} 5`,
+		`up{instance="a", os="linux", version="v1"} 1`)
+	f(`a left_join on(instance) group_left(version) prefix "build_" b`, `up{instance="a"} 1`, `info{instance="a", version="v1"} 5`,
+		`up{build_version="v1", instance="a"} 1`)
+
+	// left_join without group_left() doesn't copy labels
+	f(`a left_join on(instance) b`, `up{instance="a"} 1`, `info{instance="a", version="v1"} 5`, `up{instance="a"} 1`)
+
+	// left_join with multiple matching series
+	f(`a left_join on(instance) group_left(version) b`, `up{instance="a"} 1`, `info{instance="a", version="v1", os="linux"} 5
+info{instance="a", version="v1", os="windows"} 6
+info{instance="a", version="v2"} 7`, `up{instance="a", version="v1"} 1
+up{instance="a", version="v2"} 1`)
+
+	// outer_join
+	f(`a outer_join on(instance) group_left(version) b`, ``, `info{instance="b", version="v1"} 5`, `info{instance="b", version="v1"} 5`)
+	f(`a outer_join on(instance) group_left(version) b`, `up{instance="a"} 1
+up{instance="b"} 0`, `info{instance="b", version="v1"} 5
+info{instance="c", version="v2"} 6`, `up{instance="a"} 1
+up{instance="b", version="v1"} 0
+info{instance="c", version="v2"} 6`)
+
+	// left_join with fill()
+	f(`a left_join on(instance) group_left(version) fill("unknown") b`, `up{instance="a"} 1
+up{instance="b"} 0`, `info{instance="b", version="v1"} 5`, `up{instance="a", version="unknown"} 1
+up{instance="b", version="v1"} 0`)
+	f(`a left_join on(instance) group_left(version) prefix "build_" fill("none") b`, `up{instance="a"} 1`, ``,
+		`up{build_version="none", instance="a"} 1`)
+	f(`a left_join on(instance) group_left(version) fill("") b`, `up{instance="a", version="v0"} 1`, ``, `up{instance="a"} 1`)
+
+	// left_join with transform()
+	f(`a left_join on(host) transform(host, "(.+):\\d+", "$1") group_left(version) b`, `up{host="a:9100"} 1
+up{host="b:9100"} 0`, `info{host="a", version="v1"} 5`, `up{host="a:9100", version="v1"} 1
+up{host="b:9100"} 0`)
+	f(`a left_join on(host) transform(host, "(.+):\\d+", "$1") transform(host, "(.+)\\.local", "$1") group_left(version) fill("-") b`,
+		`up{host="a:9100"} 1
+up{host="b:9100"} 0`, `info{host="a.local", version="v1"} 5`, `up{host="a:9100", version="v1"} 1
+up{host="b:9100", version="-"} 0`)
+
+	// outer_join with transform()
+	f(`a outer_join on(host) transform(host, "(.+):\\d+", "$1") group_left(version) b`, `up{host="a:9100"} 1`,
+		`info{host="a", version="v1"} 5
+info{host="c", version="v2"} 6`, `up{host="a:9100", version="v1"} 1
+info{host="c", version="v2"} 6`)
+}
+
+func TestBinaryOpJoinFailure(t *testing.T) {
+	f := func(q string) {
+		t.Helper()
+
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		be := e.(*metricsql.BinaryOpExpr)
+		bfa := &binaryOpFuncArg{
+			be:    be,
+			left:  newTestJoinSeries(t, `up{instance="a"} 1`),
+			right: newTestJoinSeries(t, `info{instance="a",version="v1"} 1`),
+		}
+		if _, err := getBinaryOpFunc(be.Op)(bfa); err == nil {
+			t.Fatalf("expecting non-nil error for %q", q)
+		}
+	}
+
+	f(`a left_join on(instance) group_right(version) b`)
+	f(`a outer_join on(instance) group_right(version) b`)
+
+	// fill() without labels to fill
+	f(`a left_join on(instance) group_left fill("x") b`)
+	f(`a left_join on(instance) group_left(*) fill("x") b`)
+
+	// invalid transform() regex
+	f(`a left_join on(instance) transform(instance, "(", "$1") group_left(version) b`)
+}
+
+func newTestJoinSeries(t *testing.T, s string) []*timeseries {
+	t.Helper()
+
+	var tss []*timeseries
+	var rows prometheus.Rows
+	rows.UnmarshalWithErrLogger(s, func(errStr string) {
+		t.Fatalf("unexpected error when parsing %s: %s", s, errStr)
+	})
+	for _, row := range rows.Rows {
+		var ts timeseries
+		ts.MetricName.MetricGroup = []byte(row.Metric)
+		for _, tag := range row.Tags {
+			ts.MetricName.AddTag(tag.Key, tag.Value)
+		}
+		ts.Values = []float64{row.Value}
+		ts.Timestamps = []int64{0}
+		tss = append(tss, &ts)
+	}
+	return tss
+}
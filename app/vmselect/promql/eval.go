@@ -35,7 +35,8 @@ var (
 	maxPointsSubqueryPerTimeseries = flag.Int("search.maxPointsSubqueryPerTimeseries", 100e3, "The maximum number of points per series, which can be generated by subquery. "+
 		"See https://valyala.medium.com/prometheus-subqueries-in-victoriametrics-9b1492b720b3")
 	maxMemoryPerQuery = flagutil.NewBytes("search.maxMemoryPerQuery", 0, "The maximum amounts of memory a single query may consume. "+
-		"Queries requiring more memory are rejected. The total memory limit for concurrently executed queries can be estimated "+
+		"Queries requiring more memory are rejected. The memory usage is tracked across all the query evaluation stages, "+
+		"so the query is aborted as soon as it exceeds the limit. The total memory limit for concurrently executed queries can be estimated "+
 		"as -search.maxMemoryPerQuery multiplied by -search.maxConcurrentRequests . "+
 		"See also -search.logQueryMemoryUsage")
 	logQueryMemoryUsage = flagutil.NewBytes("search.logQueryMemoryUsage", 0, "Log query and increment vm_memory_intensive_queries_total metric each time "+
@@ -119,6 +120,14 @@ type EvalConfig struct {
 	// MaxPointsPerSeries is the limit on the number of points, which can be generated per each returned time series.
 	MaxPointsPerSeries int
 
+	// MaxEstimatedSeries is the limit on the estimated number of series the query may select.
+	// Zero means 'no limit'. See Explain.
+	MaxEstimatedSeries int
+
+	// MaxEstimatedSamples is the limit on the estimated number of raw samples the query may read.
+	// Zero means 'no limit'. See Explain.
+	MaxEstimatedSamples int64
+
 	// QuotedRemoteAddr contains quoted remote address.
 	QuotedRemoteAddr string
 
@@ -127,6 +136,11 @@ type EvalConfig struct {
 	// Whether the response can be cached.
 	MayCache bool
 
+	// Whether to register the queried metric names in metric names usage stats.
+	//
+	// It must be set only for /api/v1/query and /api/v1/query_range requests.
+	TrackMetricNamesStats bool
+
 	// LookbackDelta is analog to `-query.lookback-delta` from Prometheus.
 	LookbackDelta int64
 
@@ -145,6 +159,12 @@ type EvalConfig struct {
 	// The caller must initialize QueryStats, otherwise it isn't collected.
 	QueryStats *QueryStats
 
+	// memoryTracker tracks the memory usage for the query. It is initialized by Exec and ExecStream.
+	memoryTracker *queryMemoryTracker
+
+	// costsTracker tracks the number of series and raw samples selected by the query. It is initialized by Exec and ExecStream.
+	costsTracker *queryCostsTracker
+
 	timestamps     []int64
 	timestampsOnce sync.Once
 }
@@ -157,13 +177,18 @@ func copyEvalConfig(src *EvalConfig) *EvalConfig {
 	ec.Step = src.Step
 	ec.MaxSeries = src.MaxSeries
 	ec.MaxPointsPerSeries = src.MaxPointsPerSeries
+	ec.MaxEstimatedSeries = src.MaxEstimatedSeries
+	ec.MaxEstimatedSamples = src.MaxEstimatedSamples
 	ec.Deadline = src.Deadline
 	ec.MayCache = src.MayCache
+	ec.TrackMetricNamesStats = src.TrackMetricNamesStats
 	ec.LookbackDelta = src.LookbackDelta
 	ec.RoundDigits = src.RoundDigits
 	ec.EnforcedTagFilterss = src.EnforcedTagFilterss
 	ec.GetRequestURI = src.GetRequestURI
 	ec.QueryStats = src.QueryStats
+	ec.memoryTracker = src.memoryTracker
+	ec.costsTracker = src.costsTracker
 
 	// do not copy src.timestamps - they must be generated again.
 	return &ec
@@ -261,6 +286,10 @@ func getTimestamps(start, end, step int64, maxPointsPerSeries int) []int64 {
 }
 
 func evalExpr(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) ([]*timeseries, error) {
+	if err := ec.memoryTracker.Err(); err != nil {
+		// Do not evaluate e, since the query has been already aborted because of too big memory usage.
+		return nil, err
+	}
 	if qt.Enabled() {
 		query := string(e.AppendString(nil))
 		query = stringsutil.LimitStringLen(query, 300)
@@ -360,16 +389,18 @@ func evalTransformFunc(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.Fun
 		}
 	}
 	var args [][]*timeseries
+	var argsMemorySize int64
 	var err error
 	switch fe.Name {
 	case "", "union":
-		args, err = evalExprsInParallel(qt, ec, fe.Args)
+		args, argsMemorySize, err = evalExprsInParallel(qt, ec, fe.Args)
 	default:
-		args, err = evalExprsSequentially(qt, ec, fe.Args)
+		args, argsMemorySize, err = evalExprsSequentially(qt, ec, fe.Args)
 	}
 	if err != nil {
 		return nil, err
 	}
+	defer ec.memoryTracker.Sub(argsMemorySize)
 	tfa := &transformFuncArg{
 		ec:   ec,
 		fe:   fe,
@@ -381,6 +412,11 @@ func evalTransformFunc(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.Fun
 			Err: fmt.Errorf(`cannot evaluate %q: %w`, fe.AppendString(nil), err),
 		}
 	}
+	rvMemorySize, err := trackResultMemory(ec, rv, args...)
+	defer ec.memoryTracker.Sub(rvMemorySize)
+	if err != nil {
+		return nil, err
+	}
 	return rv, nil
 }
 
@@ -402,10 +438,11 @@ func evalAggrFunc(qt *querytracer.Tracer, ec *EvalConfig, ae *metricsql.AggrFunc
 			return evalRollupFunc(qt, ec, fe.Name, rf, ae, re, iafc)
 		}
 	}
-	args, err := evalExprsInParallel(qt, ec, ae.Args)
+	args, argsMemorySize, err := evalExprsInParallel(qt, ec, ae.Args)
 	if err != nil {
 		return nil, err
 	}
+	defer ec.memoryTracker.Sub(argsMemorySize)
 	af := getAggrFunc(ae.Name)
 	if af == nil {
 		return nil, &httpserver.UserReadableError{
@@ -423,6 +460,11 @@ func evalAggrFunc(qt *querytracer.Tracer, ec *EvalConfig, ae *metricsql.AggrFunc
 	if err != nil {
 		return nil, fmt.Errorf(`cannot evaluate %q: %w`, ae.AppendString(nil), err)
 	}
+	rvMemorySize, err := trackResultMemory(ec, rv, args...)
+	defer ec.memoryTracker.Sub(rvMemorySize)
+	if err != nil {
+		return nil, err
+	}
 	return rv, nil
 }
 
@@ -433,19 +475,21 @@ func evalBinaryOp(qt *querytracer.Tracer, ec *EvalConfig, be *metricsql.BinaryOp
 	}
 	var err error
 	var tssLeft, tssRight []*timeseries
+	var argsMemorySize int64
 	switch strings.ToLower(be.Op) {
 	case "and", "if":
 		// Fetch right-side series at first, since it usually contains
 		// lower number of time series for `and` and `if` operator.
 		// This should produce more specific label filters for the left side of the query.
 		// This, in turn, should reduce the time to select series for the left side of the query.
-		tssRight, tssLeft, err = execBinaryOpArgs(qt, ec, be.Right, be.Left, be)
+		tssRight, tssLeft, argsMemorySize, err = execBinaryOpArgs(qt, ec, be.Right, be.Left, be)
 	default:
-		tssLeft, tssRight, err = execBinaryOpArgs(qt, ec, be.Left, be.Right, be)
+		tssLeft, tssRight, argsMemorySize, err = execBinaryOpArgs(qt, ec, be.Left, be.Right, be)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("cannot execute %q: %w", be.AppendString(nil), err)
 	}
+	defer ec.memoryTracker.Sub(argsMemorySize)
 	bfa := &binaryOpFuncArg{
 		be:    be,
 		left:  tssLeft,
@@ -455,12 +499,17 @@ func evalBinaryOp(qt *querytracer.Tracer, ec *EvalConfig, be *metricsql.BinaryOp
 	if err != nil {
 		return nil, fmt.Errorf(`cannot evaluate %q: %w`, be.AppendString(nil), err)
 	}
+	rvMemorySize, err := trackResultMemory(ec, rv, tssLeft, tssRight)
+	defer ec.memoryTracker.Sub(rvMemorySize)
+	if err != nil {
+		return nil, err
+	}
 	return rv, nil
 }
 
 func canPushdownCommonFilters(be *metricsql.BinaryOpExpr) bool {
 	switch strings.ToLower(be.Op) {
-	case "or", "default":
+	case "or", "default", "outer_join":
 		return false
 	}
 	if isAggrFuncWithoutGrouping(be.Left) || isAggrFuncWithoutGrouping(be.Right) {
@@ -477,7 +526,11 @@ func isAggrFuncWithoutGrouping(e metricsql.Expr) bool {
 	return len(afe.Modifier.Args) == 0
 }
 
-func execBinaryOpArgs(qt *querytracer.Tracer, ec *EvalConfig, exprFirst, exprSecond metricsql.Expr, be *metricsql.BinaryOpExpr) ([]*timeseries, []*timeseries, error) {
+// execBinaryOpArgs evaluates exprFirst and exprSecond args for the binary operation be.
+//
+// The memory occupied by the returned series is registered at ec.memoryTracker as soon as every arg is evaluated.
+// The returned memory size must be released via ec.memoryTracker.Sub() when the returned series are no longer used.
+func execBinaryOpArgs(qt *querytracer.Tracer, ec *EvalConfig, exprFirst, exprSecond metricsql.Expr, be *metricsql.BinaryOpExpr) ([]*timeseries, []*timeseries, int64, error) {
 	if !canPushdownCommonFilters(be) {
 		// Execute exprFirst and exprSecond in parallel, since it is impossible to pushdown common filters
 		// from exprFirst to exprSecond.
@@ -487,33 +540,38 @@ func execBinaryOpArgs(qt *querytracer.Tracer, ec *EvalConfig, exprFirst, exprSec
 		var wg sync.WaitGroup
 
 		var tssFirst []*timeseries
+		var memorySizeFirst int64
 		var errFirst error
 		qtFirst := qt.NewChild("expr1")
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			tssFirst, errFirst = evalExpr(qtFirst, ec, exprFirst)
+			tssFirst, memorySizeFirst, errFirst = evalExprWithMemoryTracking(qtFirst, ec, exprFirst)
 			qtFirst.Done()
 		}()
 
 		var tssSecond []*timeseries
+		var memorySizeSecond int64
 		var errSecond error
 		qtSecond := qt.NewChild("expr2")
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			tssSecond, errSecond = evalExpr(qtSecond, ec, exprSecond)
+			tssSecond, memorySizeSecond, errSecond = evalExprWithMemoryTracking(qtSecond, ec, exprSecond)
 			qtSecond.Done()
 		}()
 
 		wg.Wait()
+		memorySize := memorySizeFirst + memorySizeSecond
 		if errFirst != nil {
-			return nil, nil, errFirst
+			ec.memoryTracker.Sub(memorySize)
+			return nil, nil, 0, errFirst
 		}
 		if errSecond != nil {
-			return nil, nil, errSecond
+			ec.memoryTracker.Sub(memorySize)
+			return nil, nil, 0, errSecond
 		}
-		return tssFirst, tssSecond, nil
+		return tssFirst, tssSecond, memorySize, nil
 	}
 
 	// Execute binary operation in the following way:
@@ -539,24 +597,26 @@ func execBinaryOpArgs(qt *querytracer.Tracer, ec *EvalConfig, exprFirst, exprSec
 	//
 	// - Queries, which get additional labels from `info` metrics.
 	//   See https://www.robustperception.io/exposing-the-software-version-to-prometheus
-	tssFirst, err := evalExpr(qt, ec, exprFirst)
+	tssFirst, memorySizeFirst, err := evalExprWithMemoryTracking(qt, ec, exprFirst)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, 0, err
 	}
 	if len(tssFirst) == 0 && !strings.EqualFold(be.Op, "or") {
 		// Fast path: there is no sense in executing the exprSecond when exprFirst returns an empty result,
 		// since the "exprFirst op exprSecond" would return an empty result in any case.
 		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/3349
-		return nil, nil, nil
+		ec.memoryTracker.Sub(memorySizeFirst)
+		return nil, nil, 0, nil
 	}
 	lfs := getCommonLabelFilters(tssFirst)
 	lfs = metricsql.TrimFiltersByGroupModifier(lfs, be)
 	exprSecond = metricsql.PushdownBinaryOpFilters(exprSecond, lfs)
-	tssSecond, err := evalExpr(qt, ec, exprSecond)
+	tssSecond, memorySizeSecond, err := evalExprWithMemoryTracking(qt, ec, exprSecond)
 	if err != nil {
-		return nil, nil, err
+		ec.memoryTracker.Sub(memorySizeFirst)
+		return nil, nil, 0, err
 	}
-	return tssFirst, tssSecond, nil
+	return tssFirst, tssSecond, memorySizeFirst + memorySizeSecond, nil
 }
 
 func getCommonLabelFilters(tss []*timeseries) []metricsql.LabelFilter {
@@ -701,23 +761,51 @@ func tryGetArgRollupFuncWithMetricExpr(ae *metricsql.AggrFuncExpr) (*metricsql.F
 	return nil, nil
 }
 
-func evalExprsSequentially(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
+// evalExprWithMemoryTracking evaluates e and registers the memory occupied by the returned series at ec.memoryTracker.
+//
+// The returned memory size must be released via ec.memoryTracker.Sub() when the returned series are no longer used.
+func evalExprWithMemoryTracking(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) ([]*timeseries, int64, error) {
+	rv, err := evalExpr(qt, ec, e)
+	if err != nil {
+		return nil, 0, err
+	}
+	memorySize, err := trackSeriesMemory(ec, rv)
+	if err != nil {
+		ec.memoryTracker.Sub(memorySize)
+		return nil, 0, err
+	}
+	return rv, memorySize, nil
+}
+
+// evalExprsSequentially evaluates es one by one.
+//
+// The memory occupied by the returned series is registered at ec.memoryTracker as soon as every expression is evaluated,
+// so the remaining expressions aren't evaluated if the query exceeds -search.maxMemoryPerQuery.
+// The returned memory size must be released via ec.memoryTracker.Sub() when the returned series are no longer used.
+func evalExprsSequentially(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, int64, error) {
 	var rvs [][]*timeseries
+	memorySize := int64(0)
 	for _, e := range es {
-		rv, err := evalExpr(qt, ec, e)
+		rv, n, err := evalExprWithMemoryTracking(qt, ec, e)
 		if err != nil {
-			return nil, err
+			ec.memoryTracker.Sub(memorySize)
+			return nil, 0, err
 		}
+		memorySize += n
 		rvs = append(rvs, rv)
 	}
-	return rvs, nil
+	return rvs, memorySize, nil
 }
 
-func evalExprsInParallel(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, error) {
+// evalExprsInParallel evaluates es in parallel.
+//
+// See evalExprsSequentially for details on the returned memory size.
+func evalExprsInParallel(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.Expr) ([][]*timeseries, int64, error) {
 	if len(es) < 2 {
 		return evalExprsSequentially(qt, ec, es)
 	}
 	rvs := make([][]*timeseries, len(es))
+	memorySizes := make([]int64, len(es))
 	errs := make([]error, len(es))
 	qt.Printf("eval function args in parallel")
 	var wg sync.WaitGroup
@@ -729,18 +817,24 @@ func evalExprsInParallel(qt *querytracer.Tracer, ec *EvalConfig, es []metricsql.
 				qtChild.Done()
 				wg.Done()
 			}()
-			rv, err := evalExpr(qtChild, ec, e)
+			rv, n, err := evalExprWithMemoryTracking(qtChild, ec, e)
 			rvs[i] = rv
+			memorySizes[i] = n
 			errs[i] = err
 		}(e, i)
 	}
 	wg.Wait()
+	memorySize := int64(0)
+	for _, n := range memorySizes {
+		memorySize += n
+	}
 	for _, err := range errs {
 		if err != nil {
-			return nil, err
+			ec.memoryTracker.Sub(memorySize)
+			return nil, 0, err
 		}
 	}
-	return rvs, nil
+	return rvs, memorySize, nil
 }
 
 func evalRollupFuncArgs(qt *querytracer.Tracer, ec *EvalConfig, fe *metricsql.FuncExpr) ([]any, *metricsql.RollupExpr, error) {
@@ -951,18 +1045,34 @@ func evalRollupFuncWithSubquery(qt *querytracer.Tracer, ec *EvalConfig, funcName
 		return nil, err
 	}
 
+	// Take into account the memory occupied by subquery results and by rollup results calculated over them.
+	subqueryMemorySize := getSeriesMemorySize(tssSQ)
+	subqueryMemorySize = sumNoOverflow(subqueryMemorySize, mulNoOverflow(int64(len(tssSQ)*len(rcs)), int64(8*len(sharedTimestamps))))
+	defer ec.memoryTracker.Sub(subqueryMemorySize)
+	if err := ec.memoryTracker.Add(subqueryMemorySize); err != nil {
+		return nil, err
+	}
+
 	var samplesScannedTotal atomic.Uint64
+	var tsmMemorySize atomic.Int64
+	defer func() {
+		ec.memoryTracker.Sub(tsmMemorySize.Load())
+	}()
 	keepMetricNames := getKeepMetricNames(expr)
 	tsw := getTimeseriesByWorkerID()
 	seriesByWorkerID := tsw.byWorkerID
 	doParallel(tssSQ, func(tsSQ *timeseries, values []float64, timestamps []int64, workerID uint) ([]float64, []int64) {
+		if ec.memoryTracker.Err() != nil {
+			// Skip the remaining series, since the query has been aborted because of too big memory usage.
+			return values, timestamps
+		}
 		values, timestamps = removeNanValues(values[:0], timestamps[:0], tsSQ.Values, tsSQ.Timestamps)
 		preFunc(values, timestamps)
 		for _, rc := range rcs {
 			if tsm := newTimeseriesMap(funcName, keepMetricNames, sharedTimestamps, &tsSQ.MetricName); tsm != nil {
 				samplesScanned := rc.DoTimeseriesMap(tsm, values, timestamps)
 				samplesScannedTotal.Add(samplesScanned)
-				seriesByWorkerID[workerID].tss = tsm.AppendTimeseriesTo(seriesByWorkerID[workerID].tss)
+				seriesByWorkerID[workerID].tss = trackTimeseriesMap(ec, &tsmMemorySize, seriesByWorkerID[workerID].tss, tsm)
 				continue
 			}
 			var ts timeseries
@@ -972,6 +1082,10 @@ func evalRollupFuncWithSubquery(qt *querytracer.Tracer, ec *EvalConfig, funcName
 		}
 		return values, timestamps
 	})
+	if err := ec.memoryTracker.Err(); err != nil {
+		putTimeseriesByWorkerID(tsw)
+		return nil, err
+	}
 	tss := make([]*timeseries, 0, len(tssSQ)*len(rcs))
 	for i := range seriesByWorkerID {
 		tss = append(tss, seriesByWorkerID[i].tss...)
@@ -1702,7 +1816,10 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 		minTimestamp -= ec.Step
 	}
 	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
-	rss, err := netstorage.ProcessSearchQuery(qt, sq, ec.Deadline)
+	sq.TrackMetricNamesStats = ec.TrackMetricNamesStats
+	rss, err := netstorage.ProcessSearchQueryWithSeriesCheck(qt, sq, ec.Deadline, func(seriesCount int) error {
+		return ec.costsTracker.Add(seriesCount, minTimestamp, ec.End)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -1733,7 +1850,33 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 			timeseriesLen = rssLen
 		}
 	}
-	rollupPoints := mulNoOverflow(pointsPerSeries, int64(timeseriesLen*len(rcs)))
+	rollupMemorySize, err := reserveRollupMemory(qt, ec, expr, timeseriesLen, len(rcs), pointsPerSeries)
+	if err != nil {
+		rss.Cancel()
+		return nil, err
+	}
+	defer getRollupMemoryLimiter().Put(rollupMemorySize)
+	queryMemorySize := int64(rollupMemorySize) + int64(rss.MemorySize())
+	defer ec.memoryTracker.Sub(queryMemorySize)
+	if err := ec.memoryTracker.Add(queryMemorySize); err != nil {
+		rss.Cancel()
+		return nil, err
+	}
+
+	// Evaluate rollup
+	keepMetricNames := getKeepMetricNames(expr)
+	if iafc != nil {
+		return evalRollupWithIncrementalAggregate(qt, ec, funcName, keepMetricNames, iafc, rss, rcs, preFunc, sharedTimestamps)
+	}
+	return evalRollupNoIncrementalAggregate(qt, ec, funcName, keepMetricNames, rss, rcs, preFunc, sharedTimestamps)
+}
+
+// reserveRollupMemory verifies whether the rollup calculations for expr over timeseriesLen series with rcsLen rollup configs
+// fit memory limits and reserves the needed memory at the rollup memory limiter.
+//
+// The returned memory size must be released via getRollupMemoryLimiter().Put() after the rollup calculations.
+func reserveRollupMemory(qt *querytracer.Tracer, ec *EvalConfig, expr metricsql.Expr, timeseriesLen, rcsLen int, pointsPerSeries int64) (uint64, error) {
+	rollupPoints := mulNoOverflow(pointsPerSeries, int64(timeseriesLen*rcsLen))
 	rollupMemorySize := sumNoOverflow(mulNoOverflow(int64(timeseriesLen), 1000), mulNoOverflow(rollupPoints, 16))
 	if maxMemory := int64(logQueryMemoryUsage.N); maxMemory > 0 && rollupMemorySize > maxMemory {
 		memoryIntensiveQueries.Inc()
@@ -1741,37 +1884,26 @@ func evalRollupFuncNoCache(qt *querytracer.Tracer, ec *EvalConfig, funcName stri
 		logger.Warnf("remoteAddr=%s, requestURI=%s: the %s requires %d bytes of memory for processing; "+
 			"logging this query, since it exceeds the -search.logQueryMemoryUsage=%d; "+
 			"the query selects %d time series and generates %d points across all the time series; try reducing the number of selected time series",
-			ec.QuotedRemoteAddr, requestURI, expr.AppendString(nil), rollupMemorySize, maxMemory, timeseriesLen*len(rcs), rollupPoints)
+			ec.QuotedRemoteAddr, requestURI, expr.AppendString(nil), rollupMemorySize, maxMemory, timeseriesLen*rcsLen, rollupPoints)
 	}
 	if maxMemory := int64(maxMemoryPerQuery.N); maxMemory > 0 && rollupMemorySize > maxMemory {
-		rss.Cancel()
-		err := fmt.Errorf("not enough memory for processing %s, which returns %d data points across %d time series with %d points in each time series "+
+		return 0, fmt.Errorf("not enough memory for processing %s, which returns %d data points across %d time series with %d points in each time series "+
 			"according to -search.maxMemoryPerQuery=%d; requested memory: %d bytes; "+
 			"possible solutions are: reducing the number of matching time series; increasing `step` query arg (step=%gs); "+
 			"increasing -search.maxMemoryPerQuery",
-			expr.AppendString(nil), rollupPoints, timeseriesLen*len(rcs), pointsPerSeries, maxMemory, rollupMemorySize, float64(ec.Step)/1e3)
-		return nil, err
+			expr.AppendString(nil), rollupPoints, timeseriesLen*rcsLen, pointsPerSeries, maxMemory, rollupMemorySize, float64(ec.Step)/1e3)
 	}
 	rml := getRollupMemoryLimiter()
 	if !rml.Get(uint64(rollupMemorySize)) {
-		rss.Cancel()
-		err := fmt.Errorf("not enough memory for processing %s, which returns %d data points across %d time series with %d points in each time series; "+
+		return 0, fmt.Errorf("not enough memory for processing %s, which returns %d data points across %d time series with %d points in each time series; "+
 			"total available memory for concurrent requests: %d bytes; requested memory: %d bytes; "+
 			"possible solutions are: reducing the number of matching time series; increasing `step` query arg (step=%gs); "+
 			"switching to node with more RAM; increasing -memory.allowedPercent",
-			expr.AppendString(nil), rollupPoints, timeseriesLen*len(rcs), pointsPerSeries, rml.MaxSize, uint64(rollupMemorySize), float64(ec.Step)/1e3)
-		return nil, err
+			expr.AppendString(nil), rollupPoints, timeseriesLen*rcsLen, pointsPerSeries, rml.MaxSize, uint64(rollupMemorySize), float64(ec.Step)/1e3)
 	}
-	defer rml.Put(uint64(rollupMemorySize))
 	qt.Printf("the rollup evaluation needs an estimated %d bytes of RAM for %d series and %d points per series (summary %d points)",
 		rollupMemorySize, timeseriesLen, pointsPerSeries, rollupPoints)
-
-	// Evaluate rollup
-	keepMetricNames := getKeepMetricNames(expr)
-	if iafc != nil {
-		return evalRollupWithIncrementalAggregate(qt, funcName, keepMetricNames, iafc, rss, rcs, preFunc, sharedTimestamps)
-	}
-	return evalRollupNoIncrementalAggregate(qt, funcName, keepMetricNames, rss, rcs, preFunc, sharedTimestamps)
+	return uint64(rollupMemorySize), nil
 }
 
 var (
@@ -1794,13 +1926,17 @@ func maxSilenceInterval() int64 {
 	return d
 }
 
-func evalRollupWithIncrementalAggregate(qt *querytracer.Tracer, funcName string, keepMetricNames bool,
+func evalRollupWithIncrementalAggregate(qt *querytracer.Tracer, ec *EvalConfig, funcName string, keepMetricNames bool,
 	iafc *incrementalAggrFuncContext, rss *netstorage.Results, rcs []*rollupConfig,
 	preFunc func(values []float64, timestamps []int64), sharedTimestamps []int64) ([]*timeseries, error) {
 	qt = qt.NewChild("rollup %s() with incremental aggregation %s() over %d series; rollupConfigs=%s", funcName, iafc.ae.Name, rss.Len(), rcs)
 	defer qt.Done()
 	var samplesScannedTotal atomic.Uint64
 	err := rss.RunParallel(qt, func(rs *netstorage.Result, workerID uint) error {
+		if err := ec.memoryTracker.Err(); err != nil {
+			// Stop processing the remaining series, since the query has been aborted because of too big memory usage.
+			return err
+		}
 		rs.Values, rs.Timestamps = dropStaleNaNs(funcName, rs.Values, rs.Timestamps)
 		preFunc(rs.Values, rs.Timestamps)
 		ts := getTimeseries()
@@ -1834,23 +1970,31 @@ func evalRollupWithIncrementalAggregate(qt *querytracer.Tracer, funcName string,
 	return tss, nil
 }
 
-func evalRollupNoIncrementalAggregate(qt *querytracer.Tracer, funcName string, keepMetricNames bool, rss *netstorage.Results, rcs []*rollupConfig,
+func evalRollupNoIncrementalAggregate(qt *querytracer.Tracer, ec *EvalConfig, funcName string, keepMetricNames bool, rss *netstorage.Results, rcs []*rollupConfig,
 	preFunc func(values []float64, timestamps []int64), sharedTimestamps []int64) ([]*timeseries, error) {
 	qt = qt.NewChild("rollup %s() over %d series; rollupConfigs=%s", funcName, rss.Len(), rcs)
 	defer qt.Done()
 
 	var samplesScannedTotal atomic.Uint64
+	var tsmMemorySize atomic.Int64
+	defer func() {
+		ec.memoryTracker.Sub(tsmMemorySize.Load())
+	}()
 	tsw := getTimeseriesByWorkerID()
 	seriesByWorkerID := tsw.byWorkerID
 	seriesLen := rss.Len()
 	err := rss.RunParallel(qt, func(rs *netstorage.Result, workerID uint) error {
+		if err := ec.memoryTracker.Err(); err != nil {
+			// Stop processing the remaining series, since the query has been aborted because of too big memory usage.
+			return err
+		}
 		rs.Values, rs.Timestamps = dropStaleNaNs(funcName, rs.Values, rs.Timestamps)
 		preFunc(rs.Values, rs.Timestamps)
 		for _, rc := range rcs {
 			if tsm := newTimeseriesMap(funcName, keepMetricNames, sharedTimestamps, &rs.MetricName); tsm != nil {
 				samplesScanned := rc.DoTimeseriesMap(tsm, rs.Values, rs.Timestamps)
 				samplesScannedTotal.Add(samplesScanned)
-				seriesByWorkerID[workerID].tss = tsm.AppendTimeseriesTo(seriesByWorkerID[workerID].tss)
+				seriesByWorkerID[workerID].tss = trackTimeseriesMap(ec, &tsmMemorySize, seriesByWorkerID[workerID].tss, tsm)
 				continue
 			}
 			var ts timeseries
@@ -1861,6 +2005,11 @@ func evalRollupNoIncrementalAggregate(qt *querytracer.Tracer, funcName string, k
 		return nil
 	})
 	if err != nil {
+		putTimeseriesByWorkerID(tsw)
+		return nil, err
+	}
+	if err := ec.memoryTracker.Err(); err != nil {
+		putTimeseriesByWorkerID(tsw)
 		return nil, err
 	}
 	tss := make([]*timeseries, 0, seriesLen*len(rcs))
@@ -37,10 +37,12 @@ var (
 
 // Exec executes q for the given ec.
 func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly bool) ([]netstorage.Result, error) {
+	ec.memoryTracker = newQueryMemoryTracker(int64(maxMemoryPerQuery.N))
+	ec.costsTracker = newQueryCostsTracker(ec.MaxEstimatedSeries, ec.MaxEstimatedSamples)
 	if querystats.Enabled() {
 		startTime := time.Now()
 		defer func() {
-			querystats.RegisterQuery(q, ec.End-ec.Start, startTime)
+			querystats.RegisterQuery(q, ec.End-ec.Start, startTime, ec.memoryTracker.Peak())
 			ec.QueryStats.addExecutionTimeMsec(startTime)
 		}()
 	}
@@ -52,16 +54,8 @@ func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly boo
 		return nil, err
 	}
 
-	if *disableImplicitConversion || *logImplicitConversion {
-		isInvalid := metricsql.IsLikelyInvalid(e)
-		if isInvalid && *disableImplicitConversion {
-			// we don't add query=%q to err message as it will be added by the caller
-			return nil, fmt.Errorf("query requires implicit conversion and is rejected according to -search.disableImplicitConversion command-line flag. " +
-				"See https://docs.victoriametrics.com/metricsql/#implicit-query-conversions for details")
-		}
-		if isInvalid && *logImplicitConversion {
-			logger.Warnf("query=%q requires implicit conversion, see https://docs.victoriametrics.com/metricsql/#implicit-query-conversions for details", e.AppendString(nil))
-		}
+	if err := checkImplicitConversion(e); err != nil {
+		return nil, err
 	}
 
 	qid := activeQueriesV.Add(ec, q)
@@ -70,6 +64,13 @@ func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly boo
 	if err != nil {
 		return nil, err
 	}
+	// Take into account the memory occupied by the query result.
+	resultMemorySize, err := trackSeriesMemory(ec, rv)
+	defer ec.memoryTracker.Sub(resultMemorySize)
+	if err != nil {
+		return nil, err
+	}
+	qt.Printf("peak memory usage: %d bytes", ec.memoryTracker.Peak())
 	if isFirstPointOnly {
 		// Remove all the points except the first one from every time series.
 		for _, ts := range rv {
@@ -104,6 +105,23 @@ func Exec(qt *querytracer.Tracer, ec *EvalConfig, q string, isFirstPointOnly boo
 	return result, nil
 }
 
+// checkImplicitConversion returns an error if e relies on implicit subquery conversions and -search.disableImplicitConversion is set.
+func checkImplicitConversion(e metricsql.Expr) error {
+	if !*disableImplicitConversion && !*logImplicitConversion {
+		return nil
+	}
+	isInvalid := metricsql.IsLikelyInvalid(e)
+	if isInvalid && *disableImplicitConversion {
+		// we don't add query=%q to err message as it will be added by the caller
+		return fmt.Errorf("query requires implicit conversion and is rejected according to -search.disableImplicitConversion command-line flag. " +
+			"See https://docs.victoriametrics.com/metricsql/#implicit-query-conversions for details")
+	}
+	if isInvalid && *logImplicitConversion {
+		logger.Warnf("query=%q requires implicit conversion, see https://docs.victoriametrics.com/metricsql/#implicit-query-conversions for details", e.AppendString(nil))
+	}
+	return nil
+}
+
 func maySortResults(e metricsql.Expr) bool {
 	switch v := e.(type) {
 	case *metricsql.FuncExpr:
@@ -272,7 +290,7 @@ func getReverseCmpOp(op string) string {
 func parsePromQLWithCache(q string) (metricsql.Expr, error) {
 	pcv := parseCacheV.Get(q)
 	if pcv == nil {
-		e, err := metricsql.Parse(q)
+		e, err := Parse(q)
 		if err == nil {
 			e = metricsql.Optimize(e)
 			e = adjustCmpOps(e)
@@ -405,3 +423,9 @@ func (pc *parseCache) Put(q string, pcv *parseCacheValue) {
 	pc.m[q] = pcv
 	pc.mu.Unlock()
 }
+
+func (pc *parseCache) Reset() {
+	pc.mu.Lock()
+	clear(pc.m)
+	pc.mu.Unlock()
+}
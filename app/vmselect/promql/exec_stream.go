@@ -0,0 +1,370 @@
+package promql
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/querystats"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metricsql"
+	"github.com/cespare/xxhash/v2"
+)
+
+// ErrNotStreamable is returned from ExecStream if the query cannot be evaluated in streaming mode.
+//
+// The caller should fall back to Exec in this case.
+var ErrNotStreamable = errors.New("the query cannot be evaluated in streaming mode")
+
+// ExecStream executes q for the given ec and calls f for every output series as soon as it is evaluated.
+//
+// Only range queries, which select raw series or apply rollup functions to raw series such as `rate(m[5m])`,
+// can be evaluated in streaming mode. Such queries need to hold in memory only the series,
+// which are processed by concurrently running workers, instead of the whole response.
+// ErrNotStreamable is returned without calling f for other queries.
+//
+// f may be called concurrently from up to netstorage.MaxWorkers() goroutines. workerID is in the range [0 .. netstorage.MaxWorkers()).
+// Series are passed to f in arbitrary order. Series without non-NaN values aren't passed to f.
+// rs contents must not be used after returning from f.
+//
+// ErrNotStreamable is also returned without calling f if the response may contain more than -search.maxResponseSeries series,
+// so the limit is enforced before sending the response to the client.
+//
+// Streaming evaluation doesn't use the rollup result cache.
+func ExecStream(qt *querytracer.Tracer, ec *EvalConfig, q string, f func(rs *netstorage.Result, workerID uint) error) error {
+	ec.memoryTracker = newQueryMemoryTracker(int64(maxMemoryPerQuery.N))
+	ec.costsTracker = newQueryCostsTracker(ec.MaxEstimatedSeries, ec.MaxEstimatedSamples)
+	if querystats.Enabled() {
+		startTime := time.Now()
+		defer func() {
+			querystats.RegisterQuery(q, ec.End-ec.Start, startTime, ec.memoryTracker.Peak())
+			ec.QueryStats.addExecutionTimeMsec(startTime)
+		}()
+	}
+
+	ec.validate()
+
+	e, err := parsePromQLWithCache(q)
+	if err != nil {
+		return err
+	}
+	sr, ok := getStreamableRollup(e)
+	if !ok || ec.Start == ec.End {
+		return ErrNotStreamable
+	}
+
+	if err := checkImplicitConversion(e); err != nil {
+		return err
+	}
+
+	qid := activeQueriesV.Add(ec, q)
+	defer activeQueriesV.Remove(qid)
+
+	rf := rollupDefault
+	if sr.fe != nil {
+		nrf := getRollupFunc(sr.fe.Name)
+		args, _, err := evalRollupFuncArgs(qt, ec, sr.fe)
+		if err != nil {
+			return err
+		}
+		rf, err = nrf(args)
+		if err != nil {
+			return fmt.Errorf("cannot evaluate args for %q: %w", sr.fe.AppendString(nil), err)
+		}
+	}
+
+	// Adjust the time range in the same way as evalRollupFuncWithoutAt does.
+	ecNew := ec
+	var offset int64
+	if sr.re.Offset != nil {
+		offset = sr.re.Offset.Duration(ec.Step)
+		ecNew = copyEvalConfig(ecNew)
+		ecNew.Start -= offset
+		ecNew.End -= offset
+	}
+	if sr.funcName == "rollup_candlestick" {
+		step := ecNew.Step
+		ecNew = copyEvalConfig(ecNew)
+		ecNew.Start += step
+		ecNew.End += step
+		offset -= step
+	}
+	window, err := sr.re.Window.NonNegativeDuration(ecNew.Step)
+	if err != nil {
+		return fmt.Errorf("cannot parse lookbehind window in square brackets at %s: %w", e.AppendString(nil), err)
+	}
+	return evalRollupFuncStream(qt, ecNew, sr.funcName, rf, e, sr.me, window, offset, f)
+}
+
+// streamableRollup describes the query, which can be evaluated by ExecStream.
+type streamableRollup struct {
+	// funcName is the lowercase name of the rollup function.
+	funcName string
+
+	// fe is the rollup function call. It is nil for `m` and `m[d]` queries, which are evaluated with default_rollup.
+	fe *metricsql.FuncExpr
+
+	// re is the rollup function arg.
+	re *metricsql.RollupExpr
+
+	// me is the series selector in re.
+	me *metricsql.MetricExpr
+}
+
+// getStreamableRollup returns streamableRollup for e if it can be evaluated in streaming mode.
+//
+// Streaming mode is supported for `m`, `m[d] offset x` and `rollup_func(m[d] offset x, ...)` queries.
+// Aggregate functions, transform functions, binary operations, subqueries and `@` modifiers aren't supported,
+// since they require the whole set of series selected by m for calculating the result.
+func getStreamableRollup(e metricsql.Expr) (*streamableRollup, bool) {
+	sr := &streamableRollup{
+		funcName: "default_rollup",
+	}
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		sr.re = &metricsql.RollupExpr{
+			Expr: t,
+		}
+	case *metricsql.RollupExpr:
+		sr.re = t
+	case *metricsql.FuncExpr:
+		if getRollupFunc(t.Name) == nil {
+			return nil, false
+		}
+		funcName := strings.ToLower(t.Name)
+		if funcName == "absent_over_time" {
+			// absent_over_time() aggregates all the selected series into a single series.
+			return nil, false
+		}
+		rollupArgIdx := metricsql.GetRollupArgIdx(t)
+		if len(t.Args) <= rollupArgIdx {
+			return nil, false
+		}
+		sr.funcName = funcName
+		sr.fe = t
+		sr.re = getRollupExprArg(t.Args[rollupArgIdx])
+	default:
+		return nil, false
+	}
+	if sr.re.At != nil || sr.re.ForSubquery() {
+		return nil, false
+	}
+	me, ok := sr.re.Expr.(*metricsql.MetricExpr)
+	if !ok || me.IsEmpty() {
+		return nil, false
+	}
+	sr.me = me
+	return sr, true
+}
+
+// evalRollupFuncStream calculates the given rf with the given lookbehind window over series matching me
+// and passes the calculated series to f.
+//
+// offset is added to timestamps of the calculated series.
+func evalRollupFuncStream(qt *querytracer.Tracer, ec *EvalConfig, funcName string, rf rollupFunc, expr metricsql.Expr,
+	me *metricsql.MetricExpr, window, offset int64, f func(rs *netstorage.Result, workerID uint) error) error {
+	if qt.Enabled() {
+		qt = qt.NewChild("stream rollup %s: timeRange=%s, step=%d, window=%d", expr.AppendString(nil), ec.timeRangeString(), ec.Step, window)
+		defer qt.Done()
+	}
+	// Obtain rollup configs before fetching data from db, so type errors could be caught earlier.
+	sharedTimestamps := getTimestamps(ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries)
+	preFunc, rcs, err := getRollupConfigs(funcName, rf, expr, ec.Start, ec.End, ec.Step, ec.MaxPointsPerSeries, window, ec.LookbackDelta, sharedTimestamps)
+	if err != nil {
+		return err
+	}
+	outputTimestamps := sharedTimestamps
+	if offset != 0 {
+		outputTimestamps = make([]int64, len(sharedTimestamps))
+		for i, ts := range sharedTimestamps {
+			outputTimestamps[i] = ts + offset
+		}
+	}
+
+	// Fetch the result.
+	tfss := searchutils.ToTagFilterss(me.LabelFilterss)
+	tfss = searchutils.JoinTagFilterss(tfss, ec.EnforcedTagFilterss)
+	minTimestamp := ec.Start
+	if needSilenceIntervalForRollupFunc[funcName] {
+		minTimestamp -= maxSilenceInterval()
+	}
+	if window > ec.Step {
+		minTimestamp -= window
+	} else {
+		minTimestamp -= ec.Step
+	}
+	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
+	sq.TrackMetricNamesStats = ec.TrackMetricNamesStats
+	rss, err := netstorage.ProcessSearchQueryWithSeriesCheck(qt, sq, ec.Deadline, func(seriesCount int) error {
+		return ec.costsTracker.Add(seriesCount, minTimestamp, ec.End)
+	})
+	if err != nil {
+		return &httpserver.UserReadableError{
+			Err: err,
+		}
+	}
+	rssLen := rss.Len()
+	if rssLen == 0 {
+		rss.Cancel()
+		return nil
+	}
+	if !isTimeseriesMapFunc(funcName) && *maxResponseSeries > 0 && rssLen*len(rcs) > *maxResponseSeries {
+		// Every input series results in up to len(rcs) output series, so the response may exceed -search.maxResponseSeries.
+		// Fall back to non-streaming evaluation, which detects this before sending the response,
+		// since series with NaN values only are dropped from the response.
+		rss.Cancel()
+		return ErrNotStreamable
+	}
+	ec.QueryStats.addSeriesFetched(rssLen)
+
+	// Every worker holds only a single series in memory at a time.
+	timeseriesLen := netstorage.MaxWorkers()
+	if timeseriesLen > rssLen {
+		timeseriesLen = rssLen
+	}
+	pointsPerSeries := 1 + (ec.End-ec.Start)/ec.Step
+	rollupMemorySize, err := reserveRollupMemory(qt, ec, expr, timeseriesLen, len(rcs), pointsPerSeries)
+	if err != nil {
+		rss.Cancel()
+		return &httpserver.UserReadableError{
+			Err: err,
+		}
+	}
+	defer getRollupMemoryLimiter().Put(rollupMemorySize)
+	queryMemorySize := int64(rollupMemorySize) + int64(rss.MemorySize())
+	defer ec.memoryTracker.Sub(queryMemorySize)
+	if err := ec.memoryTracker.Add(queryMemorySize); err != nil {
+		rss.Cancel()
+		return err
+	}
+
+	// Evaluate rollup and stream the results.
+	keepMetricNames := getKeepMetricNames(expr)
+	ss := newStreamSeriesSet(!keepMetricNames && !rollupFuncsKeepMetricName[funcName])
+	var samplesScannedTotal atomic.Uint64
+	emit := func(ts *timeseries, workerID uint) error {
+		if isAllNaNs(ts.Values) {
+			return nil
+		}
+		if err := ss.register(&ts.MetricName); err != nil {
+			return err
+		}
+		if n := ec.RoundDigits; n < 100 {
+			for i, v := range ts.Values {
+				ts.Values[i] = decimal.RoundToDecimalDigits(v, n)
+			}
+		}
+		rs := &netstorage.Result{
+			Values:     ts.Values,
+			Timestamps: outputTimestamps,
+		}
+		rs.MetricName.MoveFrom(&ts.MetricName)
+		return f(rs, workerID)
+	}
+	err = rss.RunParallel(qt, func(rs *netstorage.Result, workerID uint) error {
+		if err := ec.memoryTracker.Err(); err != nil {
+			// Stop processing the remaining series, since the query has been aborted because of too big memory usage.
+			return err
+		}
+		rs.Values, rs.Timestamps = dropStaleNaNs(funcName, rs.Values, rs.Timestamps)
+		preFunc(rs.Values, rs.Timestamps)
+		ts := getTimeseries()
+		defer putTimeseries(ts)
+		for _, rc := range rcs {
+			if tsm := newTimeseriesMap(funcName, keepMetricNames, sharedTimestamps, &rs.MetricName); tsm != nil {
+				samplesScanned := rc.DoTimeseriesMap(tsm, rs.Values, rs.Timestamps)
+				samplesScannedTotal.Add(samplesScanned)
+				for _, ts := range tsm.m {
+					if err := emit(ts, workerID); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			ts.Reset()
+			samplesScanned := doRollupForTimeseries(funcName, keepMetricNames, rc, ts, &rs.MetricName, rs.Values, rs.Timestamps, sharedTimestamps)
+			samplesScannedTotal.Add(samplesScanned)
+			err := emit(ts, workerID)
+
+			// ts.Timestamps points to sharedTimestamps. Zero it, so it can be re-used.
+			ts.Timestamps = nil
+			ts.denyReuse = false
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rowsScannedPerQuery.Update(float64(samplesScannedTotal.Load()))
+	qt.Printf("samplesScanned=%d, series=%d, peak memory usage: %d bytes", samplesScannedTotal.Load(), ss.n.Load(), ec.memoryTracker.Peak())
+	return nil
+}
+
+// streamSeriesSet tracks series emitted by evalRollupFuncStream in order to enforce -search.maxResponseSeries limit
+// and to detect duplicate series.
+//
+// Input series are unique, so output series may clash only if metric names are removed from them,
+// e.g. `rate({__name__=~"foo|bar"})` over foo{x="y"} and bar{x="y"}. Only hashes of output series are tracked in this case,
+// so the memory usage stays low for queries returning big number of series.
+type streamSeriesSet struct {
+	n atomic.Int64
+
+	// m contains hashes for the emitted series. It is nil if duplicate series are impossible.
+	mu sync.Mutex
+	m  map[uint64]struct{}
+}
+
+func newStreamSeriesSet(mayContainDuplicates bool) *streamSeriesSet {
+	var ss streamSeriesSet
+	if mayContainDuplicates {
+		ss.m = make(map[uint64]struct{})
+	}
+	return &ss
+}
+
+func (ss *streamSeriesSet) register(mn *storage.MetricName) error {
+	if ss.m != nil {
+		bb := bbPool.Get()
+		bb.B = marshalMetricNameSorted(bb.B[:0], mn)
+		h := xxhash.Sum64(bb.B)
+		bbPool.Put(bb)
+
+		ss.mu.Lock()
+		_, isDuplicate := ss.m[h]
+		if !isDuplicate {
+			ss.m[h] = struct{}{}
+		}
+		ss.mu.Unlock()
+
+		if isDuplicate {
+			return fmt.Errorf(`duplicate output timeseries: %s`, stringMetricName(mn))
+		}
+	}
+	n := ss.n.Add(1)
+	if *maxResponseSeries > 0 && n > int64(*maxResponseSeries) {
+		return fmt.Errorf("the response contains more than -search.maxResponseSeries=%d time series; either increase -search.maxResponseSeries "+
+			"or change the query in order to return smaller number of series", *maxResponseSeries)
+	}
+	return nil
+}
+
+func isAllNaNs(values []float64) bool {
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			return false
+		}
+	}
+	return true
+}
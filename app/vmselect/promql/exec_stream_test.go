@@ -0,0 +1,55 @@
+package promql
+
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+func TestGetStreamableRollup(t *testing.T) {
+	f := func(q string, okExpected bool, funcNameExpected string) {
+		t.Helper()
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		sr, ok := getStreamableRollup(e)
+		if ok != okExpected {
+			t.Fatalf("unexpected result for %q; got %v; want %v", q, ok, okExpected)
+		}
+		if !ok {
+			return
+		}
+		if sr.funcName != funcNameExpected {
+			t.Fatalf("unexpected funcName for %q; got %q; want %q", q, sr.funcName, funcNameExpected)
+		}
+		if sr.me == nil {
+			t.Fatalf("missing series selector for %q", q)
+		}
+	}
+
+	// streamable queries
+	f(`foo`, true, "default_rollup")
+	f(`foo{bar="baz"}[5m]`, true, "default_rollup")
+	f(`foo offset 1h`, true, "default_rollup")
+	f(`rate(foo[5m])`, true, "rate")
+	f(`Rate(foo)`, true, "rate")
+	f(`rate(foo[5m] offset 10m)`, true, "rate")
+	f(`quantile_over_time(0.9, foo[5m])`, true, "quantile_over_time")
+	f(`quantiles_over_time("phi", 0.5, 0.9, foo[5m])`, true, "quantiles_over_time")
+	f(`rollup_candlestick(foo[5m])`, true, "rollup_candlestick")
+	f(`rate(foo[5m]) keep_metric_names`, true, "rate")
+
+	// non-streamable queries
+	f(`1`, false, "")
+	f(`"foo"`, false, "")
+	f(`{}`, false, "")
+	f(`sum(rate(foo[5m]))`, false, "")
+	f(`abs(foo)`, false, "")
+	f(`foo + bar`, false, "")
+	f(`foo @ end()`, false, "")
+	f(`rate(foo[5m] @ 123)`, false, "")
+	f(`foo[1h:5m]`, false, "")
+	f(`max_over_time(rate(foo[5m])[1h:])`, false, "")
+	f(`absent_over_time(foo[5m])`, false, "")
+}
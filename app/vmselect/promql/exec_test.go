@@ -3951,6 +3951,166 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r1, r2}
 		f(q, resultExpected)
 	})
+	t.Run(`vector left_join on group_left`, func(t *testing.T) {
+		t.Parallel()
+		q := `(label_set(time(), "__name__", "up", "instance", "a"), label_set(10, "instance", "b"))
+			left_join on (instance) group_left (version)
+			(label_set(1, "instance", "a", "version", "v1"), label_set(2, "instance", "c", "version", "v2"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.MetricGroup = []byte("up")
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("instance"),
+				Value: []byte("a"),
+			},
+			{
+				Key:   []byte("version"),
+				Value: []byte("v1"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{10, 10, 10, 10, 10, 10},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("instance"),
+			Value: []byte("b"),
+		}}
+		resultExpected := []netstorage.Result{r2, r1}
+		f(q, resultExpected)
+	})
+	t.Run(`vector left_join on group_left multiple matches`, func(t *testing.T) {
+		t.Parallel()
+		q := `label_set(time(), "instance", "a")
+			left_join on (instance) group_left (version) prefix "build_"
+			(label_set(1, "instance", "a", "version", "v1"), label_set(2, "instance", "a", "version", "v2", "foo", "bar"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("build_version"),
+				Value: []byte("v1"),
+			},
+			{
+				Key:   []byte("instance"),
+				Value: []byte("a"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("build_version"),
+				Value: []byte("v2"),
+			},
+			{
+				Key:   []byte("instance"),
+				Value: []byte("a"),
+			},
+		}
+		resultExpected := []netstorage.Result{r1, r2}
+		f(q, resultExpected)
+	})
+	t.Run(`vector left_join on label_replace`, func(t *testing.T) {
+		t.Parallel()
+		q := `label_replace(label_set(time(), "instance", "host1:9100"), "host", "$1", "instance", "(.+):.+")
+			left_join on (host) group_left (version)
+			label_set(1, "host", "host1", "version", "v1")`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("host"),
+				Value: []byte("host1"),
+			},
+			{
+				Key:   []byte("instance"),
+				Value: []byte("host1:9100"),
+			},
+			{
+				Key:   []byte("version"),
+				Value: []byte("v1"),
+			},
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`vector outer_join on group_left`, func(t *testing.T) {
+		t.Parallel()
+		q := `(label_set(time(), "instance", "a"), label_set(10, "instance", "b"))
+			outer_join on (instance) group_left (version)
+			(label_set(1, "instance", "a", "version", "v1"), label_set(2, "instance", "c", "version", "v2"))`
+		r1 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1000, 1200, 1400, 1600, 1800, 2000},
+			Timestamps: timestampsExpected,
+		}
+		r1.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("instance"),
+				Value: []byte("a"),
+			},
+			{
+				Key:   []byte("version"),
+				Value: []byte("v1"),
+			},
+		}
+		r2 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{10, 10, 10, 10, 10, 10},
+			Timestamps: timestampsExpected,
+		}
+		r2.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("instance"),
+			Value: []byte("b"),
+		}}
+		r3 := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r3.MetricName.Tags = []storage.Tag{
+			{
+				Key:   []byte("instance"),
+				Value: []byte("c"),
+			},
+			{
+				Key:   []byte("version"),
+				Value: []byte("v2"),
+			},
+		}
+		resultExpected := []netstorage.Result{r1, r2, r3}
+		f(q, resultExpected)
+	})
+	t.Run(`vector outer_join empty left`, func(t *testing.T) {
+		t.Parallel()
+		q := `label_set(time(), "instance", "a") > 1e9 outer_join on (instance) label_set(2, "instance", "c")`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{2, 2, 2, 2, 2, 2},
+			Timestamps: timestampsExpected,
+		}
+		r.MetricName.Tags = []storage.Tag{{
+			Key:   []byte("instance"),
+			Value: []byte("c"),
+		}}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run(`vector + vector ignoring matching`, func(t *testing.T) {
 		t.Parallel()
 		q := `sort_desc(
@@ -4150,6 +4310,93 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run(`histogram_count(vmrange)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_count(union(
+			label_set(3, "vmrange", "0...1"),
+			label_set(5, "vmrange", "1...2"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{8, 8, 8, 8, 8, 8},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`histogram_count(le)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_count(union(
+			label_set(3, "le", "1"),
+			label_set(8, "le", "2"),
+			label_set(10, "le", "+Inf"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{10, 10, 10, 10, 10, 10},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`histogram_sum(vmrange)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_sum(union(
+			label_set(3, "vmrange", "0...1"),
+			label_set(5, "vmrange", "1...2"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{9, 9, 9, 9, 9, 9},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`histogram_sum(negative-le)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_sum(union(
+			label_set(2, "le", "-1"),
+			label_set(2, "le", "1"),
+			label_set(6, "le", "2"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{4, 4, 4, 4, 4, 4},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`histogram_sum(negative-vmrange)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_sum(union(
+			label_set(2, "vmrange", "-2...-1"),
+			label_set(4, "vmrange", "1...2"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{3, 3, 3, 3, 3, 3},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`histogram_sum(broken-buckets)`, func(t *testing.T) {
+		t.Parallel()
+		q := `histogram_sum(union(
+			label_set(3, "le", "1"),
+			label_set(2, "le", "2"),
+			label_set(5, "le", "3"),
+		))`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{6.5, 6.5, 6.5, 6.5, 6.5, 6.5},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
 	t.Run(`histogram_share(single-value-valid-le)`, func(t *testing.T) {
 		t.Parallel()
 		q := `histogram_share(80, label_set(100, "le", "200"))`
@@ -7548,6 +7795,45 @@ func TestExecSuccess(t *testing.T) {
 		resultExpected := []netstorage.Result{r}
 		f(q, resultExpected)
 	})
+	t.Run(`seasonal_forecast(const)`, func(t *testing.T) {
+		t.Parallel()
+		q := `seasonal_forecast(1[300s:10s], 100s)`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{1, 1, 1, 1, 1, 1},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`seasonal_forecast(time)`, func(t *testing.T) {
+		t.Parallel()
+		q := `seasonal_forecast(time()[300s:10s], 100s)`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{800, 1000, 1200, 1400, 1600, 1800},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`anomaly_score_over_time(const)`, func(t *testing.T) {
+		t.Parallel()
+		q := `anomaly_score_over_time(1[300s:10s], 100s)`
+		r := netstorage.Result{
+			MetricName: metricNameExpected,
+			Values:     []float64{0, 0, 0, 0, 0, 0},
+			Timestamps: timestampsExpected,
+		}
+		resultExpected := []netstorage.Result{r}
+		f(q, resultExpected)
+	})
+	t.Run(`changepoint_over_time(const)`, func(t *testing.T) {
+		t.Parallel()
+		q := `changepoint_over_time(1[300s:10s])`
+		resultExpected := []netstorage.Result{}
+		f(q, resultExpected)
+	})
 	t.Run(`integrate(1)`, func(t *testing.T) {
 		t.Parallel()
 		q := `integrate(1)`
@@ -9562,6 +9848,11 @@ func TestExecError(t *testing.T) {
 	f(`mode_over_time()`)
 	f(`rate_over_sum()`)
 	f(`zscore_over_time()`)
+	f(`seasonal_forecast()`)
+	f(`seasonal_forecast(1)`)
+	f(`anomaly_score_over_time()`)
+	f(`anomaly_score_over_time(1, 2, 3)`)
+	f(`changepoint_over_time()`)
 	f(`mode()`)
 	f(`share()`)
 	f(`zscore()`)
@@ -9649,6 +9940,8 @@ func TestExecError(t *testing.T) {
 	f(`1 + on() group_left() (label_set(1, "foo", bar"), label_set(2, "foo", "baz"))`)
 	f(`1 + on(a) group_left(b) (label_set(1, "foo", bar"), label_set(2, "foo", "baz"))`)
 	f(`label_set(1, "foo", "bar") + on(foo) group_left() (label_set(1, "foo", "bar", "a", "b"), label_set(1, "foo", "bar", "a", "c"))`)
+	f(`label_set(1, "foo", "bar") left_join on(foo) group_right(a) label_set(1, "foo", "bar", "a", "b")`)
+	f(`label_set(1, "foo", "bar") outer_join on(foo) group_right(a) label_set(1, "foo", "bar", "a", "b")`)
 	f(`(label_set(1, "foo", bar"), label_set(2, "foo", "baz")) + group_right 1`)
 	f(`(label_set(1, "foo", bar"), label_set(2, "foo", "baz")) + on() group_right 1`)
 	f(`(label_set(1, "foo", bar"), label_set(2, "foo", "baz")) + on(a) group_right(b,c) 1`)
@@ -0,0 +1,297 @@
+package promql
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/netstorage"
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+var estimatedScrapeInterval = flag.Duration("search.estimatedScrapeInterval", 15*time.Second, "The interval between raw samples, which is used for estimating "+
+	"the number of raw samples per series at /api/v1/query_explain and for -search.maxEstimatedSamples limit")
+
+// ExplainNode is a node of the query evaluation plan returned by Explain.
+type ExplainNode struct {
+	// Expr is the subexpression evaluated at the node.
+	Expr string
+
+	// Type is the node type. It can be selector, rollup, subquery, aggregate, transform, binaryOp or constant.
+	Type string
+
+	// Func is the function name for rollup, subquery, aggregate and transform nodes and the operator for binaryOp nodes.
+	Func string
+
+	// Start, End and Step define the time range the node is evaluated on.
+	Start int64
+	End   int64
+	Step  int64
+
+	// Window is the lookbehind window for rollup and subquery nodes.
+	Window int64
+
+	// Series is the estimated number of series read from the storage by the node and its children.
+	Series int
+
+	// Samples is the estimated number of raw samples read from the storage by the node and its children.
+	Samples int64
+
+	// IndexLookups is the estimated number of inverted index lookups made by the node and its children.
+	IndexLookups int
+
+	Children []*ExplainNode
+}
+
+func (en *ExplainNode) addChild(child *ExplainNode) {
+	en.Children = append(en.Children, child)
+	en.Series += child.Series
+	en.Samples += child.Samples
+	en.IndexLookups += child.IndexLookups
+}
+
+// Explain returns the evaluation plan with the estimated costs for the query q evaluated with the given ec.
+//
+// The number of series is estimated with the indexdb without reading data blocks,
+// while the number of raw samples is estimated from -search.estimatedScrapeInterval.
+func Explain(qt *querytracer.Tracer, ec *EvalConfig, q string) (*ExplainNode, error) {
+	ec.validate()
+
+	e, err := parsePromQLWithCache(q)
+	if err != nil {
+		return nil, err
+	}
+	return explainExpr(qt, ec, e)
+}
+
+// queryCostsTracker verifies that the query doesn't exceed the limits on the number of selected series and the estimated number of raw samples.
+//
+// The number of series is obtained from the index search, which is performed for every series selector during the query evaluation,
+// so the index isn't searched twice. The query is stopped before reading the data for the selector, which exceeds the limits.
+//
+// It is shared among all the EvalConfig copies for the query, so it tracks the costs across all the series selectors in the query.
+type queryCostsTracker struct {
+	maxSeries  int
+	maxSamples int64
+
+	series  atomic.Int64
+	samples atomic.Int64
+}
+
+// newQueryCostsTracker returns queryCostsTracker for the given limits.
+//
+// nil is returned if there are no limits.
+func newQueryCostsTracker(maxSeries int, maxSamples int64) *queryCostsTracker {
+	if maxSeries <= 0 && maxSamples <= 0 {
+		return nil
+	}
+	return &queryCostsTracker{
+		maxSeries:  maxSeries,
+		maxSamples: maxSamples,
+	}
+}
+
+// Add registers seriesCount series selected on the time range [start..end].
+//
+// It returns an error if the query exceeds the limits.
+func (qct *queryCostsTracker) Add(seriesCount int, start, end int64) error {
+	if qct == nil {
+		return nil
+	}
+	series := qct.series.Add(int64(seriesCount))
+	samples := qct.samples.Add(int64(seriesCount) * estimateSamplesPerSeries(start, end))
+	if qct.maxSeries > 0 && series > int64(qct.maxSeries) {
+		return &httpserver.UserReadableError{
+			Err: fmt.Errorf("the query selects at least %d series, which exceeds the limit of %d series; "+
+				"see -search.maxEstimatedSeries command-line flag and max_estimated_series query arg; "+
+				"see https://docs.victoriametrics.com/#query-cost-estimation", series, qct.maxSeries),
+		}
+	}
+	if qct.maxSamples > 0 && samples > qct.maxSamples {
+		return &httpserver.UserReadableError{
+			Err: fmt.Errorf("the query is estimated to read at least %d raw samples, which exceeds the limit of %d samples; "+
+				"see -search.maxEstimatedSamples command-line flag and max_estimated_samples query arg; "+
+				"see https://docs.victoriametrics.com/#query-cost-estimation", samples, qct.maxSamples),
+		}
+	}
+	return nil
+}
+
+func explainExpr(qt *querytracer.Tracer, ec *EvalConfig, e metricsql.Expr) (*ExplainNode, error) {
+	switch t := e.(type) {
+	case *metricsql.MetricExpr:
+		re := &metricsql.RollupExpr{
+			Expr: t,
+		}
+		return explainRollup(qt, ec, "default_rollup", e, re)
+	case *metricsql.RollupExpr:
+		return explainRollup(qt, ec, "default_rollup", e, getRollupExprArg(t))
+	case *metricsql.FuncExpr:
+		if getRollupFunc(t.Name) == nil {
+			en := newExplainNode(ec, e, "transform", t.Name)
+			if err := explainArgs(qt, ec, en, t.Args); err != nil {
+				return nil, err
+			}
+			return en, nil
+		}
+		rollupArgIdx := metricsql.GetRollupArgIdx(t)
+		if len(t.Args) <= rollupArgIdx {
+			return nil, fmt.Errorf("expecting at least %d args to %q; got %d args; expr: %q", rollupArgIdx+1, t.Name, len(t.Args), t.AppendString(nil))
+		}
+		en, err := explainRollup(qt, ec, t.Name, e, getRollupExprArg(t.Args[rollupArgIdx]))
+		if err != nil {
+			return nil, err
+		}
+		for i, arg := range t.Args {
+			if i == rollupArgIdx {
+				continue
+			}
+			child, err := explainExpr(qt, ec, arg)
+			if err != nil {
+				return nil, err
+			}
+			en.addChild(child)
+		}
+		return en, nil
+	case *metricsql.AggrFuncExpr:
+		en := newExplainNode(ec, e, "aggregate", t.Name)
+		if err := explainArgs(qt, ec, en, t.Args); err != nil {
+			return nil, err
+		}
+		return en, nil
+	case *metricsql.BinaryOpExpr:
+		en := newExplainNode(ec, e, "binaryOp", t.Op)
+		if err := explainArgs(qt, ec, en, []metricsql.Expr{t.Left, t.Right}); err != nil {
+			return nil, err
+		}
+		return en, nil
+	default:
+		return newExplainNode(ec, e, "constant", ""), nil
+	}
+}
+
+func explainArgs(qt *querytracer.Tracer, ec *EvalConfig, en *ExplainNode, args []metricsql.Expr) error {
+	for _, arg := range args {
+		child, err := explainExpr(qt, ec, arg)
+		if err != nil {
+			return err
+		}
+		en.addChild(child)
+	}
+	return nil
+}
+
+// explainRollup returns the plan for funcName applied to re in the same way as evalRollupFunc evaluates it.
+func explainRollup(qt *querytracer.Tracer, ec *EvalConfig, funcName string, expr metricsql.Expr, re *metricsql.RollupExpr) (*ExplainNode, error) {
+	funcName = strings.ToLower(funcName)
+	var atNode *ExplainNode
+	if re.At != nil {
+		// The `@` modifier is evaluated separately. Its' value is unknown until the evaluation,
+		// so the estimation is made for the original time range.
+		en, err := explainExpr(qt, ec, re.At)
+		if err != nil {
+			return nil, err
+		}
+		atNode = en
+	}
+	ecNew := ec
+	if re.Offset != nil {
+		offset := re.Offset.Duration(ec.Step)
+		ecNew = copyEvalConfig(ecNew)
+		ecNew.Start -= offset
+		ecNew.End -= offset
+	}
+	window, err := re.Window.NonNegativeDuration(ecNew.Step)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse lookbehind window in square brackets at %s: %w", expr.AppendString(nil), err)
+	}
+
+	var en *ExplainNode
+	if me, ok := re.Expr.(*metricsql.MetricExpr); ok {
+		en = newExplainNode(ecNew, expr, "rollup", funcName)
+		en.Window = window
+		child, err := explainMetricExpr(qt, ecNew, funcName, me, window)
+		if err != nil {
+			return nil, err
+		}
+		en.addChild(child)
+	} else {
+		step, err := re.Step.NonNegativeDuration(ecNew.Step)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse step in square brackets at %s: %w", expr.AppendString(nil), err)
+		}
+		if step == 0 {
+			step = ecNew.Step
+		}
+		en = newExplainNode(ecNew, expr, "subquery", funcName)
+		en.Window = window
+		ecSQ := copyEvalConfig(ecNew)
+		ecSQ.Start -= window + step + maxSilenceInterval()
+		ecSQ.End += step
+		ecSQ.Step = step
+		ecSQ.Start, ecSQ.End = alignStartEnd(ecSQ.Start, ecSQ.End, ecSQ.Step)
+		child, err := explainExpr(qt, ecSQ, re.Expr)
+		if err != nil {
+			return nil, err
+		}
+		en.addChild(child)
+	}
+	if atNode != nil {
+		en.addChild(atNode)
+	}
+	return en, nil
+}
+
+// explainMetricExpr returns the plan for reading series matching me in the same way as evalRollupFuncWithMetricExpr reads them.
+func explainMetricExpr(qt *querytracer.Tracer, ec *EvalConfig, funcName string, me *metricsql.MetricExpr, window int64) (*ExplainNode, error) {
+	tfss := searchutils.ToTagFilterss(me.LabelFilterss)
+	tfss = searchutils.JoinTagFilterss(tfss, ec.EnforcedTagFilterss)
+	minTimestamp := ec.Start
+	if needSilenceIntervalForRollupFunc[funcName] {
+		minTimestamp -= maxSilenceInterval()
+	}
+	if window > ec.Step {
+		minTimestamp -= window
+	} else {
+		minTimestamp -= ec.Step
+	}
+	en := newExplainNode(ec, me, "selector", "")
+	en.Start = minTimestamp
+	if me.IsEmpty() {
+		return en, nil
+	}
+	sq := storage.NewSearchQuery(minTimestamp, ec.End, tfss, ec.MaxSeries)
+	se, err := netstorage.EstimateSeries(qt, sq, ec.Deadline)
+	if err != nil {
+		return nil, err
+	}
+	en.Series = se.SeriesCount
+	en.IndexLookups = se.IndexLookups
+	en.Samples = int64(se.SeriesCount) * estimateSamplesPerSeries(minTimestamp, ec.End)
+	return en, nil
+}
+
+func estimateSamplesPerSeries(start, end int64) int64 {
+	interval := estimatedScrapeInterval.Milliseconds()
+	if interval <= 0 {
+		interval = 1
+	}
+	return (end-start)/interval + 1
+}
+
+func newExplainNode(ec *EvalConfig, e metricsql.Expr, typ, funcName string) *ExplainNode {
+	return &ExplainNode{
+		Expr:  string(e.AppendString(nil)),
+		Type:  typ,
+		Func:  funcName,
+		Start: ec.Start,
+		End:   ec.End,
+		Step:  ec.Step,
+	}
+}
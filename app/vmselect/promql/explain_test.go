@@ -0,0 +1,132 @@
+package promql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/app/vmselect/searchutils"
+)
+
+func TestExplainSuccess(t *testing.T) {
+	f := func(q, planExpected string) {
+		t.Helper()
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               200e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutils.NewDeadline(time.Now(), time.Minute, ""),
+		}
+		en, err := Explain(nil, ec, q)
+		if err != nil {
+			t.Fatalf("unexpected error when explaining %q: %s", q, err)
+		}
+		var b strings.Builder
+		writeExplainNodeForTest(&b, en, 0)
+		if plan := b.String(); plan != planExpected {
+			t.Fatalf("unexpected plan for %q;\ngot\n%s\nwant\n%s", q, plan, planExpected)
+		}
+	}
+
+	f(`123`, "constant 123 [1000000..2000000:200000]\n")
+	f(`abs(1) + 2`, `binaryOp(+) abs(1) + 2 [1000000..2000000:200000]
+  transform(abs) abs(1) [1000000..2000000:200000]
+    constant 1 [1000000..2000000:200000]
+  constant 2 [1000000..2000000:200000]
+`)
+	f(`sum(rate(time()[600s:100s] offset 200s)) by (x)`, `aggregate(sum) sum(rate(time()[600s:100s] offset 200s)) by(x) [1000000..2000000:200000]
+  subquery(rate) rate(time()[600s:100s] offset 200s) [800000..1800000:200000] window=600000
+    transform(time) time() [-200000..1900000:100000]
+`)
+	f(`quantile_over_time(0.5, time()[300s:100s])`, `subquery(quantile_over_time) quantile_over_time(0.5, time()[300s:100s]) [1000000..2000000:200000] window=300000
+  transform(time) time() [300000..2100000:100000]
+  constant 0.5 [1000000..2000000:200000]
+`)
+}
+
+func TestExplainFailure(t *testing.T) {
+	f := func(q string) {
+		t.Helper()
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               200e3,
+			MaxPointsPerSeries: 1e4,
+			MaxSeries:          1000,
+			Deadline:           searchutils.NewDeadline(time.Now(), time.Minute, ""),
+		}
+		if _, err := Explain(nil, ec, q); err == nil {
+			t.Fatalf("expecting non-nil error when explaining %q", q)
+		}
+	}
+
+	f(``)
+	f(`sum(`)
+	f(`rate(time()[-5m:1m])`)
+}
+
+func TestEstimateSamplesPerSeries(t *testing.T) {
+	f := func(start, end, resultExpected int64) {
+		t.Helper()
+		result := estimateSamplesPerSeries(start, end)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for estimateSamplesPerSeries(%d, %d); got %d; want %d", start, end, result, resultExpected)
+		}
+	}
+
+	// -search.estimatedScrapeInterval=15s
+	f(0, 0, 1)
+	f(0, 14999, 1)
+	f(0, 15000, 2)
+	f(1000, 3600*1000+1000, 241)
+}
+
+func writeExplainNodeForTest(b *strings.Builder, en *ExplainNode, depth int) {
+	fmt.Fprintf(b, "%s%s", strings.Repeat("  ", depth), en.Type)
+	if en.Func != "" {
+		fmt.Fprintf(b, "(%s)", en.Func)
+	}
+	fmt.Fprintf(b, " %s [%d..%d:%d]", en.Expr, en.Start, en.End, en.Step)
+	if en.Window > 0 {
+		fmt.Fprintf(b, " window=%d", en.Window)
+	}
+	b.WriteString("\n")
+	for _, child := range en.Children {
+		writeExplainNodeForTest(b, child, depth+1)
+	}
+}
+
+func TestQueryCostsTracker(t *testing.T) {
+	if qct := newQueryCostsTracker(0, 0); qct != nil {
+		t.Fatalf("expecting nil tracker without limits")
+	}
+	var qctNil *queryCostsTracker
+	if err := qctNil.Add(1e9, 0, 1e9); err != nil {
+		t.Fatalf("unexpected error for nil tracker: %s", err)
+	}
+
+	// The limit on the number of series is checked across all the registered series.
+	qct := newQueryCostsTracker(10, 0)
+	if err := qct.Add(6, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := qct.Add(4, 0, 0); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := qct.Add(1, 0, 0); err == nil {
+		t.Fatalf("expecting non-nil error when the number of series exceeds the limit")
+	}
+
+	// The number of samples is estimated from -search.estimatedScrapeInterval.
+	samplesPerSeries := estimateSamplesPerSeries(0, 3600e3)
+	qct = newQueryCostsTracker(0, 2*samplesPerSeries)
+	if err := qct.Add(2, 0, 3600e3); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := qct.Add(1, 0, 3600e3); err == nil {
+		t.Fatalf("expecting non-nil error when the number of samples exceeds the limit")
+	}
+}
@@ -1,9 +1,14 @@
 package promql
 
 import (
+	"fmt"
 	"sync"
+	"sync/atomic"
+	"unsafe"
 
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/httpserver"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
 )
 
 type memoryLimiter struct {
@@ -31,3 +36,147 @@ func (ml *memoryLimiter) Put(n uint64) {
 	ml.usage -= n
 	ml.mu.Unlock()
 }
+
+// queryMemoryTracker tracks the estimated memory usage for a single query.
+//
+// It is shared among all the EvalConfig copies for the query, so it tracks memory usage
+// across all the concurrently evaluated subexpressions of the query.
+type queryMemoryTracker struct {
+	// maxSize is the maximum memory size in bytes, which can be used by the query. Zero means no limit.
+	maxSize int64
+
+	usage atomic.Int64
+	peak  atomic.Int64
+
+	// exceeded is set to true when the memory usage exceeds maxSize.
+	// It is used for cancelling the evaluation of the remaining subexpressions.
+	exceeded atomic.Bool
+}
+
+func newQueryMemoryTracker(maxSize int64) *queryMemoryTracker {
+	return &queryMemoryTracker{
+		maxSize: maxSize,
+	}
+}
+
+// Add registers n bytes of memory used by the query.
+//
+// It returns an error if the query memory usage exceeds maxSize. The query evaluation must be stopped in this case.
+// The registered memory must be released via Sub call when it is no longer used, even if Add returns an error.
+func (qmt *queryMemoryTracker) Add(n int64) error {
+	if qmt == nil {
+		return nil
+	}
+	usage := qmt.usage.Add(n)
+	for {
+		peak := qmt.peak.Load()
+		if usage <= peak || qmt.peak.CompareAndSwap(peak, usage) {
+			break
+		}
+	}
+	if qmt.maxSize > 0 && usage > qmt.maxSize {
+		if !qmt.exceeded.Swap(true) {
+			queryMemoryLimitExceeded.Inc()
+		}
+		return qmt.Err()
+	}
+	return nil
+}
+
+// Sub releases n bytes of memory previously registered via Add.
+func (qmt *queryMemoryTracker) Sub(n int64) {
+	if qmt == nil {
+		return
+	}
+	if usage := qmt.usage.Add(-n); usage < 0 {
+		logger.Panicf("BUG: query memory usage cannot be negative; got %d bytes after releasing %d bytes", usage, n)
+	}
+}
+
+// Err returns an error if the query memory usage has exceeded maxSize.
+func (qmt *queryMemoryTracker) Err() error {
+	if qmt == nil || !qmt.exceeded.Load() {
+		return nil
+	}
+	return &httpserver.UserReadableError{
+		Err: fmt.Errorf("the query has been aborted because it needs more than -search.maxMemoryPerQuery=%d bytes of memory; peak memory usage: %d bytes; "+
+			"possible solutions are: reducing the number of matching time series; reducing the time range for the query; increasing `step` query arg; "+
+			"increasing -search.maxMemoryPerQuery", qmt.maxSize, qmt.peak.Load()),
+	}
+}
+
+// Peak returns the peak memory usage in bytes for the query.
+func (qmt *queryMemoryTracker) Peak() int64 {
+	if qmt == nil {
+		return 0
+	}
+	return qmt.peak.Load()
+}
+
+var queryMemoryLimitExceeded = metrics.NewCounter(`vm_per_query_memory_limit_exceeded_total`)
+
+// getSeriesMemorySize returns the estimated memory size in bytes occupied by tss.
+func getSeriesMemorySize(tss []*timeseries) int64 {
+	n := 0
+	for _, ts := range tss {
+		n += int(unsafe.Sizeof(*ts)) + 8*cap(ts.Values) + len(ts.MetricName.MetricGroup)
+		for _, tag := range ts.MetricName.Tags {
+			n += int(unsafe.Sizeof(tag)) + len(tag.Key) + len(tag.Value)
+		}
+	}
+	if len(tss) > 0 {
+		// Timestamps are usually shared among all the series, so take them into account only once.
+		n += 8 * len(tss[0].Timestamps)
+	}
+	return int64(n)
+}
+
+// trackSeriesMemory registers the memory occupied by the series in tsss at ec memory tracker.
+//
+// The returned memory size must be released via ec.memoryTracker.Sub() when tsss are no longer used.
+func trackSeriesMemory(ec *EvalConfig, tsss ...[]*timeseries) (int64, error) {
+	n := int64(0)
+	for _, tss := range tsss {
+		n += getSeriesMemorySize(tss)
+	}
+	err := ec.memoryTracker.Add(n)
+	return n, err
+}
+
+// trackResultMemory registers the memory occupied by the series in rv at ec memory tracker.
+//
+// Series from rv, which are also present in args, aren't taken into account, since many functions and operators
+// modify the series in args in place and return them. Such series are already registered by the caller.
+//
+// The returned memory size must be released via ec.memoryTracker.Sub() when rv is no longer used.
+func trackResultMemory(ec *EvalConfig, rv []*timeseries, args ...[]*timeseries) (int64, error) {
+	if ec.memoryTracker == nil || len(rv) == 0 {
+		return 0, nil
+	}
+	m := make(map[*timeseries]struct{})
+	for _, tss := range args {
+		for _, ts := range tss {
+			m[ts] = struct{}{}
+		}
+	}
+	var tssNew []*timeseries
+	for _, ts := range rv {
+		if _, ok := m[ts]; !ok {
+			tssNew = append(tssNew, ts)
+		}
+	}
+	return trackSeriesMemory(ec, tssNew)
+}
+
+// trackTimeseriesMap appends the series from tsm to dst and registers the memory occupied by them at ec memory tracker.
+//
+// The number of series generated by tsm isn't known beforehand, so they aren't taken into account by reserveRollupMemory.
+// The registered memory size is added to memorySize, which must be released via ec.memoryTracker.Sub()
+// when the returned series are no longer used. The caller must check ec.memoryTracker.Err() afterwards.
+func trackTimeseriesMap(ec *EvalConfig, memorySize *atomic.Int64, dst []*timeseries, tsm *timeseriesMap) []*timeseries {
+	dstLen := len(dst)
+	dst = tsm.AppendTimeseriesTo(dst)
+	n, _ := trackSeriesMemory(ec, dst[dstLen:])
+	memorySize.Add(n)
+	return dst
+}
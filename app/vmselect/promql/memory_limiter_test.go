@@ -2,6 +2,8 @@ package promql
 
 import (
 	"testing"
+
+	"github.com/VictoriaMetrics/metricsql"
 )
 
 func TestMemoryLimiter(t *testing.T) {
@@ -54,3 +56,135 @@ func TestMemoryLimiter(t *testing.T) {
 		t.Fatalf("unexpected usage; got %d; want %d", ml.usage, 0)
 	}
 }
+
+func TestQueryMemoryTracker(t *testing.T) {
+	qmt := newQueryMemoryTracker(100)
+
+	if err := qmt.Add(60); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := qmt.Add(30); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	qmt.Sub(50)
+	if err := qmt.Add(40); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := qmt.Peak(); n != 90 {
+		t.Fatalf("unexpected peak; got %d; want %d", n, 90)
+	}
+	if err := qmt.Err(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Exceed the limit
+	if err := qmt.Add(21); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+	if err := qmt.Err(); err == nil {
+		t.Fatalf("expecting non-nil error after exceeding the limit")
+	}
+	qmt.Sub(101)
+	if n := qmt.usage.Load(); n != 0 {
+		t.Fatalf("unexpected usage; got %d; want %d", n, 0)
+	}
+	if n := qmt.Peak(); n != 101 {
+		t.Fatalf("unexpected peak; got %d; want %d", n, 101)
+	}
+
+	// nil tracker must work without limits
+	var qmtNil *queryMemoryTracker
+	if err := qmtNil.Add(1e12); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	qmtNil.Sub(1e12)
+	if n := qmtNil.Peak(); n != 0 {
+		t.Fatalf("unexpected peak; got %d; want %d", n, 0)
+	}
+}
+
+func TestQueryMemoryTrackerEvalExpr(t *testing.T) {
+	f := func(q string, maxSize int64, errExpected bool) {
+		t.Helper()
+		e, err := metricsql.Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		ec := &EvalConfig{
+			Start:              1000e3,
+			End:                2000e3,
+			Step:               100e3,
+			MaxPointsPerSeries: 1e4,
+			RoundDigits:        100,
+			memoryTracker:      newQueryMemoryTracker(maxSize),
+		}
+		_, err = evalExpr(nil, ec, e)
+		if errExpected != (err != nil) {
+			t.Fatalf("unexpected error for %q with maxSize=%d: %v", q, maxSize, err)
+		}
+		if n := ec.memoryTracker.usage.Load(); n != 0 {
+			t.Fatalf("the memory must be released after evaluating %q; got %d bytes", q, n)
+		}
+		if ec.memoryTracker.Peak() == 0 {
+			t.Fatalf("expecting non-zero peak memory usage for %q", q)
+		}
+	}
+
+	f(`time() + time()`, 0, false)
+	f(`time() + time()`, 100, true)
+	f(`sum(union(1, 2, 3))`, 0, false)
+	f(`sum(union(1, 2, 3))`, 100, true)
+	f(`abs(time())`, 1e6, false)
+	f(`abs(time())`, 10, true)
+	f(`label_set(time(), "foo", "bar") + 1`, 1e6, false)
+	f(`count_values("foo", time())`, 1e6, false)
+	f(`count_values("foo", time())`, 1000, true)
+}
+
+func TestTrackResultMemory(t *testing.T) {
+	newSeries := func() *timeseries {
+		return &timeseries{
+			Values:     []float64{1, 2, 3},
+			Timestamps: []int64{1, 2, 3},
+		}
+	}
+	ts1 := newSeries()
+	ts2 := newSeries()
+	ts3 := newSeries()
+
+	f := func(rv []*timeseries, args [][]*timeseries, sizeExpected int64) {
+		t.Helper()
+		ec := &EvalConfig{
+			memoryTracker: newQueryMemoryTracker(0),
+		}
+		n, err := trackResultMemory(ec, rv, args...)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != sizeExpected {
+			t.Fatalf("unexpected memory size; got %d; want %d", n, sizeExpected)
+		}
+		if usage := ec.memoryTracker.usage.Load(); usage != n {
+			t.Fatalf("unexpected memory usage; got %d; want %d", usage, n)
+		}
+	}
+
+	// empty result
+	f(nil, [][]*timeseries{{ts1}}, 0)
+
+	// the result is modified in place
+	f([]*timeseries{ts1, ts2}, [][]*timeseries{{ts1}, {ts2}}, 0)
+
+	// the result contains new series
+	f([]*timeseries{ts3}, [][]*timeseries{{ts1, ts2}}, getSeriesMemorySize([]*timeseries{ts3}))
+	f([]*timeseries{ts1, ts3}, [][]*timeseries{{ts1}}, getSeriesMemorySize([]*timeseries{ts3}))
+
+	// nil memory tracker
+	n, err := trackResultMemory(&EvalConfig{}, []*timeseries{ts1}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 0 {
+		t.Fatalf("unexpected memory size for nil memory tracker; got %d; want 0", n)
+	}
+}
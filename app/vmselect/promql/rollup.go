@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"math"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
 )
@@ -24,10 +26,12 @@ var minStalenessInterval = flag.Duration("search.minStalenessInterval", 0, "The
 var rollupFuncs = map[string]newRollupFunc{
 	"absent_over_time":        newRollupFuncOneArg(rollupAbsent),
 	"aggr_over_time":          newRollupFuncTwoArgs(rollupFake),
+	"anomaly_score_over_time": newRollupAnomalyScore,
 	"ascent_over_time":        newRollupFuncOneArg(rollupAscentOverTime),
 	"avg_over_time":           newRollupFuncOneArg(rollupAvg),
 	"changes":                 newRollupFuncOneArg(rollupChanges),
 	"changes_prometheus":      newRollupFuncOneArg(rollupChangesPrometheus),
+	"changepoint_over_time":   newRollupFuncOneArg(rollupChangepoint),
 	"count_eq_over_time":      newRollupCountEQ,
 	"count_gt_over_time":      newRollupCountGT,
 	"count_le_over_time":      newRollupCountLE,
@@ -82,6 +86,7 @@ var rollupFuncs = map[string]newRollupFunc{
 	"rollup_rate":             newRollupFuncOneOrTwoArgs(rollupFake), // + rollupFuncsRemoveCounterResets
 	"rollup_scrape_interval":  newRollupFuncOneOrTwoArgs(rollupFake),
 	"scrape_interval":         newRollupFuncOneArg(rollupScrapeInterval),
+	"seasonal_forecast":       newRollupSeasonalForecast,
 	"share_eq_over_time":      newRollupShareEQ,
 	"share_gt_over_time":      newRollupShareGT,
 	"share_le_over_time":      newRollupShareLE,
@@ -148,6 +153,7 @@ var rollupAggrFuncs = map[string]rollupFunc{
 	"ascent_over_time":        rollupAscentOverTime,
 	"avg_over_time":           rollupAvg,
 	"changes":                 rollupChanges,
+	"changepoint_over_time":   rollupChangepoint,
 	"count_over_time":         rollupCount,
 	"decreases_over_time":     rollupDecreases,
 	"default_rollup":          rollupDefault,
@@ -280,6 +286,7 @@ var rollupFuncsKeepMetricName = map[string]bool{
 	"quantiles_over_time":   true,
 	"rollup":                true,
 	"rollup_candlestick":    true,
+	"seasonal_forecast":     true,
 	"timestamp_with_name":   true,
 }
 
@@ -609,11 +616,20 @@ type timeseriesMap struct {
 	m      map[string]*timeseries
 }
 
-func newTimeseriesMap(funcName string, keepMetricNames bool, sharedTimestamps []int64, mnSrc *storage.MetricName) *timeseriesMap {
-	funcName = strings.ToLower(funcName)
+// isTimeseriesMapFunc returns true if the rollup function with the given lowercase funcName
+// may return arbitrary number of output series per each input series.
+func isTimeseriesMapFunc(funcName string) bool {
 	switch funcName {
 	case "histogram_over_time", "quantiles_over_time", "count_values_over_time":
+		return true
 	default:
+		return false
+	}
+}
+
+func newTimeseriesMap(funcName string, keepMetricNames bool, sharedTimestamps []int64, mnSrc *storage.MetricName) *timeseriesMap {
+	funcName = strings.ToLower(funcName)
+	if !isTimeseriesMapFunc(funcName) {
 		return nil
 	}
 
@@ -701,8 +717,17 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 
 	scrapeInterval := getScrapeInterval(timestamps, rc.Step)
 	maxPrevInterval := getMaxPrevInterval(scrapeInterval)
-	if rc.LookbackDelta > 0 && maxPrevInterval > rc.LookbackDelta {
-		maxPrevInterval = rc.LookbackDelta
+	lookbackDelta := rc.LookbackDelta
+	if dsi := storage.GetDownsamplingInterval(rc.Start, int64(fasttime.UnixTimestamp())*1000); dsi > 0 {
+		// Samples at rc.Start may be downsampled to dsi interval.
+		// Do not limit the lookbehind window to values smaller than dsi then,
+		// since this would result in gaps on the graph.
+		if lookbackDelta > 0 && lookbackDelta < dsi {
+			lookbackDelta = getMaxPrevInterval(dsi)
+		}
+	}
+	if lookbackDelta > 0 && maxPrevInterval > lookbackDelta {
+		maxPrevInterval = lookbackDelta
 	}
 	if *minStalenessInterval > 0 {
 		if msi := minStalenessInterval.Milliseconds(); msi > 0 && maxPrevInterval < msi {
@@ -723,10 +748,10 @@ func (rc *rollupConfig) doInternal(dstValues []float64, tsm *timeseriesMap, valu
 			// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/3483
 			window = maxPrevInterval
 		}
-		if rc.isDefaultRollup && rc.LookbackDelta > 0 && window > rc.LookbackDelta {
+		if rc.isDefaultRollup && lookbackDelta > 0 && window > lookbackDelta {
 			// Implicit window exceeds -search.maxStalenessInterval, so limit it to -search.maxStalenessInterval
 			// according to https://github.com/VictoriaMetrics/VictoriaMetrics/issues/784
-			window = rc.LookbackDelta
+			window = lookbackDelta
 		}
 	}
 	rfa := getRollupFuncArg()
@@ -2312,6 +2337,228 @@ func rollupZScoreOverTime(rfa *rollupFuncArg) float64 {
 	return d / rollupStddev(rfa)
 }
 
+func newRollupSeasonalForecast(args []any) (rollupFunc, error) {
+	if err := expectRollupArgsNum(args, 2); err != nil {
+		return nil, err
+	}
+	periods, err := getScalar(args[1], 1)
+	if err != nil {
+		return nil, err
+	}
+	rf := func(rfa *rollupFuncArg) float64 {
+		// There is no need in handling NaNs here, since they must be cleaned up
+		// before calling rollup funcs.
+		period := int64(periods[rfa.idx] * 1e3)
+		if period <= 0 {
+			return nan
+		}
+		timestamps := rfa.timestamps
+		tolerance := getSeasonalTolerance(timestamps)
+		if tolerance <= 0 {
+			return nan
+		}
+
+		// Forecast the value at rfa.currTimestamp as the median of values at the same phase of the previous periods.
+		// The median is robust to anomalies in the previous periods.
+		a := getFloat64s()
+		vs := a.A[:0]
+		for ts := rfa.currTimestamp - period; ts >= timestamps[0]-tolerance; ts -= period {
+			if i := getNearestSampleIdx(timestamps, ts, tolerance); i >= 0 {
+				vs = append(vs, rfa.values[i])
+			}
+		}
+		v := nan
+		if len(vs) > 0 {
+			v = quantile(0.5, vs)
+		}
+		a.A = vs
+		putFloat64s(a)
+		return v
+	}
+	return rf, nil
+}
+
+func newRollupAnomalyScore(args []any) (rollupFunc, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("unexpected number of args; got %d; want 1...2", len(args))
+	}
+	var periods []float64
+	if len(args) == 2 {
+		var err error
+		periods, err = getScalar(args[1], 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	rf := func(rfa *rollupFuncArg) float64 {
+		// There is no need in handling NaNs here, since they must be cleaned up
+		// before calling rollup funcs.
+		scrapeInterval := rollupScrapeInterval(rfa)
+		lag := rollupLag(rfa)
+		if math.IsNaN(scrapeInterval) || math.IsNaN(lag) || lag > scrapeInterval {
+			// Do not score stale series in the same way as zscore_over_time does.
+			return nan
+		}
+		period := int64(0)
+		if periods != nil {
+			period = int64(periods[rfa.idx] * 1e3)
+			if period <= 0 {
+				return nan
+			}
+		}
+		values := rfa.values
+		if period == 0 {
+			return robustZScore(values[len(values)-1], values)
+		}
+
+		// Remove seasonality by subtracting the value from the previous period from every value.
+		// See https://otexts.com/fpp3/stationarity.html#seasonal-differencing
+		timestamps := rfa.timestamps
+		tolerance := getSeasonalTolerance(timestamps)
+		a := getFloat64s()
+		ds := a.A[:0]
+		lastIdx := -1
+		for i, ts := range timestamps {
+			if j := getNearestSampleIdx(timestamps, ts-period, tolerance); j >= 0 {
+				ds = append(ds, values[i]-values[j])
+				lastIdx = i
+			}
+		}
+		v := nan
+		if lastIdx == len(values)-1 && len(ds) >= 2 {
+			v = robustZScore(ds[len(ds)-1], ds)
+		}
+		a.A = ds
+		putFloat64s(a)
+		return v
+	}
+	return rf, nil
+}
+
+// robustZScore returns the robust z-score for v relative to values.
+//
+// See https://en.wikipedia.org/wiki/Median_absolute_deviation#Relation_to_standard_deviation
+//
+// The mean absolute deviation around the median is used as a fallback scale if the median absolute deviation is zero,
+// e.g. when the majority of values are equal.
+func robustZScore(v float64, values []float64) float64 {
+	median := quantile(0.5, values)
+	d := v - median
+	if d == 0 {
+		return 0
+	}
+	if m := mad(values); m > 0 {
+		return d / (1.4826 * m)
+	}
+	sum := float64(0)
+	for _, x := range values {
+		sum += math.Abs(x - median)
+	}
+	meanAD := sum / float64(len(values))
+	if meanAD == 0 {
+		return nan
+	}
+	// 1.2533 is sqrt(pi/2), which converts the mean absolute deviation to the standard deviation for normally distributed values.
+	return d / (1.2533 * meanAD)
+}
+
+// getSeasonalTolerance returns the maximum distance in milliseconds from the given timestamp
+// for the sample to be considered at the same phase of the period.
+//
+// It equals to the average interval between samples.
+func getSeasonalTolerance(timestamps []int64) int64 {
+	if len(timestamps) < 2 {
+		return 0
+	}
+	return (timestamps[len(timestamps)-1] - timestamps[0]) / int64(len(timestamps)-1)
+}
+
+// getNearestSampleIdx returns the index of the timestamp nearest to ts among sorted timestamps.
+//
+// -1 is returned if there are no timestamps in the range [ts-tolerance ... ts+tolerance].
+func getNearestSampleIdx(timestamps []int64, ts, tolerance int64) int {
+	i := sort.Search(len(timestamps), func(i int) bool {
+		return timestamps[i] >= ts
+	})
+	if i > 0 && (i == len(timestamps) || ts-timestamps[i-1] <= timestamps[i]-ts) {
+		i--
+	}
+	if i == len(timestamps) || timestamps[i] < ts-tolerance || timestamps[i] > ts+tolerance {
+		return -1
+	}
+	return i
+}
+
+// changepointMinShift is the minimum shift of the mean value in pooled standard deviations,
+// which is detected by changepoint_over_time.
+const changepointMinShift = 3
+
+func rollupChangepoint(rfa *rollupFuncArg) float64 {
+	// There is no need in handling NaNs here, since they must be cleaned up
+	// before calling rollup funcs.
+	values := rfa.values
+	n := len(values)
+	if n < 4 {
+		return nan
+	}
+
+	// Find the split, which maximizes the between-segment sum of squares n1*n2/n*(avg1-avg2)^2.
+	// This is equivalent to finding the maximum of CUSUM statistic.
+	// See https://en.wikipedia.org/wiki/CUSUM
+	// Values are shifted by values[0] in order to reduce precision loss for big values.
+	v0 := values[0]
+	total := float64(0)
+	for _, v := range values {
+		total += v - v0
+	}
+	splitIdx := -1
+	maxScore := float64(0)
+	prefix := float64(0)
+	for i := 1; i < n; i++ {
+		prefix += values[i-1] - v0
+		if i < 2 || n-i < 2 {
+			// Every segment must contain at least two samples.
+			continue
+		}
+		n1 := float64(i)
+		n2 := float64(n - i)
+		d := prefix/n1 - (total-prefix)/n2
+		score := n1 * n2 * d * d / float64(n)
+		if score > maxScore {
+			maxScore = score
+			splitIdx = i
+		}
+	}
+	if splitIdx < 0 {
+		return nan
+	}
+
+	// Verify whether the shift is significant comparing to the pooled standard deviation for both segments.
+	avg1 := rollupAvgValues(values[:splitIdx])
+	avg2 := rollupAvgValues(values[splitIdx:])
+	ss := float64(0)
+	for i, v := range values {
+		avg := avg1
+		if i >= splitIdx {
+			avg = avg2
+		}
+		ss += (v - avg) * (v - avg)
+	}
+	stddev := math.Sqrt(ss / float64(n-2))
+	if math.Abs(avg2-avg1) <= changepointMinShift*stddev {
+		return nan
+	}
+	return float64(rfa.timestamps[splitIdx]) / 1e3
+}
+
+func rollupAvgValues(values []float64) float64 {
+	sum := float64(0)
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
 func rollupFirst(rfa *rollupFuncArg) float64 {
 	// There is no need in handling NaNs here, since they must be cleaned up
 	// before calling rollup funcs.
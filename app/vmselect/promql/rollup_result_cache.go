@@ -136,6 +136,21 @@ func InitRollupResultCache(cachePath string) {
 		c.Reset()
 	}
 
+	var dc *rollupResultDiskCache
+	if len(*rollupResultDiskCachePath) > 0 {
+		logger.Infof("opening on-disk rollupResult cache at %q...", *rollupResultDiskCachePath)
+		dc = mustOpenRollupResultDiskCache(*rollupResultDiskCachePath, rollupResultDiskCacheMaxSize.N, rollupResultDiskCacheMinEntrySize.N)
+		if *resetRollupResultCacheOnStartup {
+			dc.Reset()
+		}
+		// Use the key prefix shared among all the processes, which use the on-disk cache.
+		mustLoadRollupResultCacheKeyPrefix(dc.keyPrefixPath())
+		mustSaveRollupResultCacheKeyPrefix(dc.keyPrefixPath())
+		dc.registerMetrics()
+		logger.Infof("opened on-disk rollupResult cache at %q in %.3f seconds; entriesCount: %d, sizeBytes: %d",
+			dc.path, time.Since(startTime).Seconds(), dc.entriesCount(), dc.size())
+	}
+
 	stats := &fastcache.Stats{}
 	var statsLock sync.Mutex
 	var statsLastUpdate uint64
@@ -177,12 +192,18 @@ func InitRollupResultCache(cachePath string) {
 	})
 
 	rollupResultCacheV = &rollupResultCache{
-		c: c,
+		c:  c,
+		dc: dc,
 	}
 }
 
 // StopRollupResultCache closes the rollupResult cache.
 func StopRollupResultCache() {
+	if dc := rollupResultCacheV.dc; dc != nil {
+		dc.MustStop()
+		mustSaveRollupResultCacheKeyPrefix(dc.keyPrefixPath())
+		rollupResultCacheV.dc = nil
+	}
 	if len(rollupResultCachePath) == 0 {
 		rollupResultCacheV.c.Stop()
 		rollupResultCacheV.c = nil
@@ -205,6 +226,39 @@ func StopRollupResultCache() {
 
 type rollupResultCache struct {
 	c *workingsetcache.Cache
+
+	// dc is an optional on-disk cache, which is used when the big entry is missing in c.
+	//
+	// Small metainfo entries stored via set() aren't stored in dc, since they are updated on every query.
+	dc *rollupResultDiskCache
+}
+
+func (rrc *rollupResultCache) get(dst, key []byte) []byte {
+	return rrc.c.Get(dst, key)
+}
+
+func (rrc *rollupResultCache) set(key, value []byte) {
+	rrc.c.Set(key, value)
+}
+
+func (rrc *rollupResultCache) getBig(dst, key []byte) []byte {
+	dstLen := len(dst)
+	dst = rrc.c.GetBig(dst, key)
+	if len(dst) > dstLen || rrc.dc == nil {
+		return dst
+	}
+	dst, ok := rrc.dc.Get(dst, key)
+	if ok {
+		rrc.c.SetBig(key, dst[dstLen:])
+	}
+	return dst
+}
+
+func (rrc *rollupResultCache) setBig(key, value []byte) {
+	rrc.c.SetBig(key, value)
+	if rrc.dc != nil {
+		rrc.dc.Set(key, value)
+	}
 }
 
 var rollupResultCacheResets = metrics.NewCounter(`vm_cache_resets_total{type="promql/rollupResult"}`)
@@ -213,6 +267,10 @@ var rollupResultCacheResets = metrics.NewCounter(`vm_cache_resets_total{type="pr
 func ResetRollupResultCache() {
 	rollupResultCacheResets.Inc()
 	rollupResultCacheKeyPrefix.Add(1)
+	if dc := rollupResultCacheV.dc; dc != nil {
+		dc.Reset()
+		mustSaveRollupResultCacheKeyPrefix(dc.keyPrefixPath())
+	}
 	logger.Infof("rollupResult cache has been cleared")
 }
 
@@ -292,7 +350,7 @@ func (rrc *rollupResultCache) GetSeries(qt *querytracer.Tracer, ec *EvalConfig,
 	defer bbPool.Put(bb)
 
 	bb.B = marshalRollupResultCacheKeyForSeries(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilterss)
-	metainfoBuf := rrc.c.Get(nil, bb.B)
+	metainfoBuf := rrc.get(nil, bb.B)
 	if len(metainfoBuf) == 0 {
 		qt.Printf("nothing found")
 		return nil, ec.Start
@@ -314,7 +372,7 @@ func (rrc *rollupResultCache) GetSeries(qt *querytracer.Tracer, ec *EvalConfig,
 		mi.RemoveKey(key)
 		metainfoBuf = mi.Marshal(metainfoBuf[:0])
 		bb.B = marshalRollupResultCacheKeyForSeries(bb.B[:0], expr, window, ec.Step, ec.EnforcedTagFilterss)
-		rrc.c.Set(bb.B, metainfoBuf)
+		rrc.set(bb.B, metainfoBuf)
 		return nil, ec.Start
 	}
 
@@ -420,7 +478,7 @@ func (rrc *rollupResultCache) PutSeries(qt *querytracer.Tracer, ec *EvalConfig,
 	defer bbPool.Put(metainfoBuf)
 
 	metainfoKey.B = marshalRollupResultCacheKeyForSeries(metainfoKey.B[:0], expr, window, ec.Step, ec.EnforcedTagFilterss)
-	metainfoBuf.B = rrc.c.Get(metainfoBuf.B[:0], metainfoKey.B)
+	metainfoBuf.B = rrc.get(metainfoBuf.B[:0], metainfoKey.B)
 	var mi rollupResultCacheMetainfo
 	if len(metainfoBuf.B) > 0 {
 		if err := mi.Unmarshal(metainfoBuf.B); err != nil {
@@ -452,7 +510,7 @@ func (rrc *rollupResultCache) PutSeries(qt *querytracer.Tracer, ec *EvalConfig,
 
 	mi.AddKey(key, timestamps[0], timestamps[len(timestamps)-1])
 	metainfoBuf.B = mi.Marshal(metainfoBuf.B[:0])
-	rrc.c.Set(metainfoKey.B, metainfoBuf.B)
+	rrc.set(metainfoKey.B, metainfoBuf.B)
 }
 
 var (
@@ -466,7 +524,7 @@ var (
 
 func (rrc *rollupResultCache) getSeriesFromCache(qt *querytracer.Tracer, key []byte) ([]*timeseries, bool) {
 	compressedResultBuf := resultBufPool.Get()
-	compressedResultBuf.B = rrc.c.GetBig(compressedResultBuf.B[:0], key)
+	compressedResultBuf.B = rrc.getBig(compressedResultBuf.B[:0], key)
 	if len(compressedResultBuf.B) == 0 {
 		qt.Printf("nothing found in the cache")
 		resultBufPool.Put(compressedResultBuf)
@@ -505,7 +563,7 @@ func (rrc *rollupResultCache) putSeriesToCache(qt *querytracer.Tracer, key []byt
 	compressedResultBuf.B = encoding.CompressZSTDLevel(compressedResultBuf.B[:0], resultBuf.B, 1)
 	qt.Printf("compress %d bytes into %d bytes", len(resultBuf.B), len(compressedResultBuf.B))
 
-	rrc.c.SetBig(key, compressedResultBuf.B)
+	rrc.setBig(key, compressedResultBuf.B)
 	qt.Printf("store %d bytes in the cache", len(compressedResultBuf.B))
 	return true
 }
@@ -0,0 +1,452 @@
+package promql
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/cespare/xxhash/v2"
+)
+
+var (
+	rollupResultDiskCachePath = flag.String("search.rollupResultDiskCachePath", "", "Optional path to the directory for the on-disk second-tier rollup result cache. "+
+		"Big entries evicted from the in-memory cache are read from this directory. "+
+		"The on-disk cache is disabled if the path is empty. See https://docs.victoriametrics.com/#rollup-result-cache . "+
+		"See also -search.rollupResultDiskCacheMaxSize and -search.rollupResultDiskCacheMinEntrySize")
+	rollupResultDiskCacheMaxSize = flagutil.NewBytes("search.rollupResultDiskCacheMaxSize", 10*1024*1024*1024, "The maximum size of the on-disk rollup result cache "+
+		"at -search.rollupResultDiskCachePath. The least recently used entries are removed when the cache exceeds this size")
+	rollupResultDiskCacheMinEntrySize = flagutil.NewBytes("search.rollupResultDiskCacheMinEntrySize", 16*1024, "The minimum size of rollup result cache entry "+
+		"for storing it in the on-disk cache at -search.rollupResultDiskCachePath. Smaller entries are stored only in the in-memory cache, "+
+		"since they are cheap to re-calculate")
+)
+
+// rollupResultDiskCache is an optional on-disk second tier for rollupResultCache.
+//
+// Every entry is stored in a separate file together with its key and checksum,
+// so corrupted entries are detected and dropped on read.
+// Entries are written to files by a background writer, so Set doesn't slow down queries.
+// Entries are dropped if the writer cannot keep up with the incoming entries.
+type rollupResultDiskCache struct {
+	path         string
+	maxSize      int64
+	minEntrySize int64
+
+	// writeCh is the bounded queue for entries to write.
+	writeCh chan *rollupResultDiskCacheWrite
+
+	// pendingWrites tracks entries, which are queued for writing.
+	pendingWrites sync.WaitGroup
+
+	// writerWG tracks the background writer.
+	writerWG sync.WaitGroup
+
+	// mu protects the fields below.
+	mu        sync.Mutex
+	m         map[uint64]*list.Element
+	lru       *list.List
+	sizeBytes int64
+
+	requests      atomic.Uint64
+	misses        atomic.Uint64
+	corrupted     atomic.Uint64
+	evictions     atomic.Uint64
+	writeErrors   atomic.Uint64
+	droppedWrites atomic.Uint64
+}
+
+type rollupResultDiskCacheEntry struct {
+	h    uint64
+	size int64
+}
+
+type rollupResultDiskCacheWrite struct {
+	key   []byte
+	value []byte
+}
+
+// rollupResultDiskCacheWriteQueueSize is the maximum number of entries, which may wait for writing to the on-disk cache.
+//
+// The queue is kept small, since it holds copies of big cache entries in memory.
+const rollupResultDiskCacheWriteQueueSize = 16
+
+// Increment this value every time the format of on-disk cache entries changes.
+const rollupResultDiskCacheEntryVersion = 1
+
+var errRollupResultDiskCacheKeyMismatch = errors.New("entry key mismatch")
+
+// rollupResultDiskCacheTmpSuffix is initialized with a random value in order to avoid collisions
+// for temporary files among processes sharing the cache directory.
+var rollupResultDiskCacheTmpSuffix = func() *atomic.Uint64 {
+	var x atomic.Uint64
+	x.Store(newRollupResultCacheKeyPrefix())
+	return &x
+}()
+
+var rollupResultDiskCacheErrorLogger = logger.WithThrottler("rollupResultDiskCache", 5*time.Second)
+
+// mustOpenRollupResultDiskCache opens on-disk cache at the given path.
+//
+// Entries smaller than minEntrySize aren't stored in the cache.
+// The returned cache must be stopped via MustStop when no longer needed.
+func mustOpenRollupResultDiskCache(path string, maxSize, minEntrySize int64) *rollupResultDiskCache {
+	path = filepath.Clean(path)
+	fs.MustMkdirIfNotExist(path)
+	dc := &rollupResultDiskCache{
+		path:         path,
+		maxSize:      maxSize,
+		minEntrySize: minEntrySize,
+		writeCh:      make(chan *rollupResultDiskCacheWrite, rollupResultDiskCacheWriteQueueSize),
+		m:            make(map[uint64]*list.Element),
+		lru:          list.New(),
+	}
+
+	type entryInfo struct {
+		h     uint64
+		size  int64
+		mtime time.Time
+	}
+	var eis []entryInfo
+	for _, de := range fs.MustReadDir(path) {
+		if !de.IsDir() || len(de.Name()) != 2 {
+			continue
+		}
+		dir := filepath.Join(path, de.Name())
+		for _, fe := range fs.MustReadDir(dir) {
+			name := fe.Name()
+			if strings.Contains(name, ".tmp.") {
+				// Remove temporary file left after unclean shutdown.
+				fs.MustRemoveAll(filepath.Join(dir, name))
+				continue
+			}
+			h, err := strconv.ParseUint(name, 16, 64)
+			if err != nil || len(name) != 16 || fe.IsDir() {
+				continue
+			}
+			fi, err := fe.Info()
+			if err != nil {
+				// The entry may be removed by another process in the meantime.
+				continue
+			}
+			eis = append(eis, entryInfo{
+				h:     h,
+				size:  fi.Size(),
+				mtime: fi.ModTime(),
+			})
+		}
+	}
+
+	// Register entries in the order of their modification time, so the oldest entries are evicted first.
+	sort.Slice(eis, func(i, j int) bool {
+		return eis[i].mtime.Before(eis[j].mtime)
+	})
+	for _, ei := range eis {
+		dc.touchLocked(ei.h, ei.size)
+	}
+	dc.removeEntries(dc.evictLocked())
+
+	dc.writerWG.Add(1)
+	go func() {
+		defer dc.writerWG.Done()
+		dc.runWriter()
+	}()
+	return dc
+}
+
+// MustStop writes the queued entries and stops the background writer for dc.
+func (dc *rollupResultDiskCache) MustStop() {
+	close(dc.writeCh)
+	dc.writerWG.Wait()
+}
+
+func (dc *rollupResultDiskCache) runWriter() {
+	for w := range dc.writeCh {
+		dc.writeEntry(w.key, w.value)
+		dc.pendingWrites.Done()
+	}
+}
+
+// waitForPendingWrites waits until all the queued entries are written.
+func (dc *rollupResultDiskCache) waitForPendingWrites() {
+	dc.pendingWrites.Wait()
+}
+
+// keyPrefixPath returns the path prefix for storing rollupResultCacheKeyPrefix,
+// so processes sharing the cache directory use the same cache keys.
+func (dc *rollupResultDiskCache) keyPrefixPath() string {
+	return filepath.Join(dc.path, "rollupResult")
+}
+
+func (dc *rollupResultDiskCache) entryPath(h uint64) string {
+	name := fmt.Sprintf("%016X", h)
+	return filepath.Join(dc.path, name[:2], name)
+}
+
+// Get appends the value for the given key to dst and returns the result.
+//
+// false is returned if the entry for the given key is missing or corrupted.
+func (dc *rollupResultDiskCache) Get(dst, key []byte) ([]byte, bool) {
+	dc.requests.Add(1)
+	h := xxhash.Sum64(key)
+	path := dc.entryPath(h)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			rollupResultDiskCacheErrorLogger.Errorf("cannot read rollup result cache entry: %s", err)
+		}
+		dc.misses.Add(1)
+		dc.forget(h)
+		return dst, false
+	}
+	value, err := unmarshalRollupResultDiskCacheEntry(data, key)
+	if err != nil {
+		dc.misses.Add(1)
+		if errors.Is(err, errRollupResultDiskCacheKeyMismatch) {
+			// Hash collision. Leave the entry for the other key.
+			return dst, false
+		}
+		dc.corrupted.Add(1)
+		rollupResultDiskCacheErrorLogger.Errorf("removing corrupted rollup result cache entry %q: %s", path, err)
+		dc.forget(h)
+		dc.removeEntries([]string{path})
+		return dst, false
+	}
+
+	dc.mu.Lock()
+	dc.touchLocked(h, int64(len(data)))
+	paths := dc.evictLocked()
+	dc.mu.Unlock()
+	dc.removeEntries(paths)
+
+	return append(dst, value...), true
+}
+
+// Set queues the given value for storing under the given key.
+//
+// The value isn't stored if it is smaller than dc.minEntrySize or if the write queue is full.
+func (dc *rollupResultDiskCache) Set(key, value []byte) {
+	if int64(len(value)) < dc.minEntrySize {
+		return
+	}
+	w := &rollupResultDiskCacheWrite{
+		key:   append([]byte{}, key...),
+		value: append([]byte{}, value...),
+	}
+	dc.pendingWrites.Add(1)
+	select {
+	case dc.writeCh <- w:
+	default:
+		dc.pendingWrites.Done()
+		dc.droppedWrites.Add(1)
+	}
+}
+
+func (dc *rollupResultDiskCache) writeEntry(key, value []byte) {
+	h := xxhash.Sum64(key)
+	path := dc.entryPath(h)
+	data := marshalRollupResultDiskCacheEntry(nil, key, value)
+	if int64(len(data)) > dc.maxSize {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		dc.writeErrors.Add(1)
+		rollupResultDiskCacheErrorLogger.Errorf("cannot create directory for rollup result cache entry: %s", err)
+		return
+	}
+	// Write the entry to a temporary file at first and then atomically rename it,
+	// so concurrent readers never see partially written entries.
+	tmpPath := fmt.Sprintf("%s.tmp.%d", path, rollupResultDiskCacheTmpSuffix.Add(1))
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		dc.writeErrors.Add(1)
+		rollupResultDiskCacheErrorLogger.Errorf("cannot write rollup result cache entry: %s", err)
+		_ = os.Remove(tmpPath)
+		return
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		dc.writeErrors.Add(1)
+		rollupResultDiskCacheErrorLogger.Errorf("cannot store rollup result cache entry: %s", err)
+		_ = os.Remove(tmpPath)
+		return
+	}
+
+	dc.mu.Lock()
+	dc.touchLocked(h, int64(len(data)))
+	paths := dc.evictLocked()
+	dc.mu.Unlock()
+	dc.removeEntries(paths)
+}
+
+// Reset removes all the entries from dc.
+func (dc *rollupResultDiskCache) Reset() {
+	dc.mu.Lock()
+	dc.m = make(map[uint64]*list.Element)
+	dc.lru.Init()
+	dc.sizeBytes = 0
+	dc.mu.Unlock()
+
+	for _, de := range fs.MustReadDir(dc.path) {
+		if !de.IsDir() || len(de.Name()) != 2 {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dc.path, de.Name())); err != nil {
+			rollupResultDiskCacheErrorLogger.Errorf("cannot remove rollup result cache entries: %s", err)
+		}
+	}
+}
+
+func (dc *rollupResultDiskCache) forget(h uint64) {
+	dc.mu.Lock()
+	if e := dc.m[h]; e != nil {
+		dc.sizeBytes -= e.Value.(*rollupResultDiskCacheEntry).size
+		dc.lru.Remove(e)
+		delete(dc.m, h)
+	}
+	dc.mu.Unlock()
+}
+
+func (dc *rollupResultDiskCache) touchLocked(h uint64, size int64) {
+	if e := dc.m[h]; e != nil {
+		rde := e.Value.(*rollupResultDiskCacheEntry)
+		dc.sizeBytes += size - rde.size
+		rde.size = size
+		dc.lru.MoveToFront(e)
+		return
+	}
+	dc.m[h] = dc.lru.PushFront(&rollupResultDiskCacheEntry{
+		h:    h,
+		size: size,
+	})
+	dc.sizeBytes += size
+}
+
+// evictLocked removes the least recently used entries from dc until it fits maxSize.
+//
+// It returns paths for the evicted entries, which must be removed via removeEntries.
+func (dc *rollupResultDiskCache) evictLocked() []string {
+	var paths []string
+	for dc.sizeBytes > dc.maxSize {
+		e := dc.lru.Back()
+		rde := e.Value.(*rollupResultDiskCacheEntry)
+		dc.sizeBytes -= rde.size
+		dc.lru.Remove(e)
+		delete(dc.m, rde.h)
+		paths = append(paths, dc.entryPath(rde.h))
+		dc.evictions.Add(1)
+	}
+	return paths
+}
+
+func (dc *rollupResultDiskCache) removeEntries(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			rollupResultDiskCacheErrorLogger.Errorf("cannot remove rollup result cache entry: %s", err)
+		}
+	}
+}
+
+func (dc *rollupResultDiskCache) entriesCount() int {
+	dc.mu.Lock()
+	n := len(dc.m)
+	dc.mu.Unlock()
+	return n
+}
+
+func (dc *rollupResultDiskCache) size() int64 {
+	dc.mu.Lock()
+	n := dc.sizeBytes
+	dc.mu.Unlock()
+	return n
+}
+
+func (dc *rollupResultDiskCache) registerMetrics() {
+	// Use metrics.GetOrCreate* instead of metrics.New*,
+	// so InitRollupResultCache+StopRollupResultCache could be called multiple times in tests.
+	metrics.GetOrCreateGauge(`vm_cache_entries{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.entriesCount())
+	})
+	metrics.GetOrCreateGauge(`vm_cache_size_bytes{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.size())
+	})
+	metrics.GetOrCreateGauge(`vm_cache_size_max_bytes{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.maxSize)
+	})
+	metrics.GetOrCreateGauge(`vm_cache_requests_total{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.requests.Load())
+	})
+	metrics.GetOrCreateGauge(`vm_cache_misses_total{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.misses.Load())
+	})
+	metrics.GetOrCreateGauge(`vm_cache_evictions_total{type="promql/rollupResultDisk"}`, func() float64 {
+		return float64(dc.evictions.Load())
+	})
+	metrics.GetOrCreateGauge(`vm_rollup_result_disk_cache_corrupted_entries_total`, func() float64 {
+		return float64(dc.corrupted.Load())
+	})
+	metrics.GetOrCreateGauge(`vm_rollup_result_disk_cache_write_errors_total`, func() float64 {
+		return float64(dc.writeErrors.Load())
+	})
+	metrics.GetOrCreateGauge(`vm_rollup_result_disk_cache_dropped_writes_total`, func() float64 {
+		return float64(dc.droppedWrites.Load())
+	})
+}
+
+// marshalRollupResultDiskCacheEntry appends the on-disk representation of the entry with the given key and value to dst.
+//
+// The entry has the following format:
+//
+//	version (1 byte) | xxhash64 checksum of the remaining data (8 bytes) | key length (varuint64) | key | value
+func marshalRollupResultDiskCacheEntry(dst, key, value []byte) []byte {
+	dst = append(dst, rollupResultDiskCacheEntryVersion)
+	checksumOffset := len(dst)
+	dst = encoding.MarshalUint64(dst, 0)
+	dataOffset := len(dst)
+	dst = encoding.MarshalVarUint64(dst, uint64(len(key)))
+	dst = append(dst, key...)
+	dst = append(dst, value...)
+	checksum := xxhash.Sum64(dst[dataOffset:])
+	binary.BigEndian.PutUint64(dst[checksumOffset:], checksum)
+	return dst
+}
+
+// unmarshalRollupResultDiskCacheEntry returns the value from the on-disk entry src for the given key.
+//
+// The returned value refers to src.
+func unmarshalRollupResultDiskCacheEntry(src, key []byte) ([]byte, error) {
+	if len(src) < 9 {
+		return nil, fmt.Errorf("too short entry; got %d bytes; want at least 9 bytes", len(src))
+	}
+	if src[0] != rollupResultDiskCacheEntryVersion {
+		return nil, fmt.Errorf("unexpected entry version; got %d; want %d", src[0], rollupResultDiskCacheEntryVersion)
+	}
+	checksum := encoding.UnmarshalUint64(src[1:])
+	src = src[9:]
+	if h := xxhash.Sum64(src); h != checksum {
+		return nil, fmt.Errorf("checksum mismatch; got %016X; want %016X", h, checksum)
+	}
+	keyLen, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return nil, fmt.Errorf("cannot unmarshal key length")
+	}
+	src = src[nSize:]
+	if uint64(len(src)) < keyLen {
+		return nil, fmt.Errorf("too short entry for the key with length %d bytes; got %d bytes", keyLen, len(src))
+	}
+	if string(src[:keyLen]) != string(key) {
+		return nil, errRollupResultDiskCacheKeyMismatch
+	}
+	return src[keyLen:], nil
+}
@@ -0,0 +1,181 @@
+package promql
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/workingsetcache"
+	"github.com/cespare/xxhash/v2"
+)
+
+func TestRollupResultDiskCacheGetSet(t *testing.T) {
+	path := t.Name()
+	defer fs.MustRemoveAll(path)
+
+	dc := mustOpenRollupResultDiskCache(path, 1024*1024, 0)
+	if _, ok := dc.Get(nil, []byte("foo")); ok {
+		t.Fatalf("unexpected entry found in empty cache")
+	}
+	dc.Set([]byte("foo"), []byte("bar"))
+	dc.Set([]byte("baz"), nil)
+	dc.waitForPendingWrites()
+
+	f := func(key, valueExpected string) {
+		t.Helper()
+		value, ok := dc.Get([]byte("prefix"), []byte(key))
+		if !ok {
+			t.Fatalf("cannot find entry for key %q", key)
+		}
+		if string(value) != "prefix"+valueExpected {
+			t.Fatalf("unexpected value for key %q; got %q; want %q", key, value, "prefix"+valueExpected)
+		}
+	}
+	f("foo", "bar")
+	f("baz", "")
+	if n := dc.entriesCount(); n != 2 {
+		t.Fatalf("unexpected number of entries; got %d; want 2", n)
+	}
+
+	// Entries must survive re-opening the cache.
+	dc.MustStop()
+	dc = mustOpenRollupResultDiskCache(path, 1024*1024, 0)
+	defer dc.MustStop()
+	if n := dc.entriesCount(); n != 2 {
+		t.Fatalf("unexpected number of entries after re-opening the cache; got %d; want 2", n)
+	}
+	f("foo", "bar")
+	f("baz", "")
+
+	dc.Reset()
+	if n := dc.entriesCount(); n != 0 {
+		t.Fatalf("unexpected number of entries after reset; got %d; want 0", n)
+	}
+	if _, ok := dc.Get(nil, []byte("foo")); ok {
+		t.Fatalf("unexpected entry found after reset")
+	}
+}
+
+func TestRollupResultDiskCacheCorruptedEntry(t *testing.T) {
+	path := t.Name()
+	defer fs.MustRemoveAll(path)
+
+	dc := mustOpenRollupResultDiskCache(path, 1024*1024, 0)
+	defer dc.MustStop()
+	key := []byte("foo")
+	dc.Set(key, []byte("bar"))
+	dc.waitForPendingWrites()
+
+	entryPath := dc.entryPath(xxhash.Sum64(key))
+	data, err := os.ReadFile(entryPath)
+	if err != nil {
+		t.Fatalf("cannot read entry: %s", err)
+	}
+	data[len(data)-1]++
+	if err := os.WriteFile(entryPath, data, 0644); err != nil {
+		t.Fatalf("cannot write entry: %s", err)
+	}
+
+	if _, ok := dc.Get(nil, key); ok {
+		t.Fatalf("unexpected corrupted entry returned")
+	}
+	if n := dc.corrupted.Load(); n != 1 {
+		t.Fatalf("unexpected number of corrupted entries; got %d; want 1", n)
+	}
+	if fs.IsPathExist(entryPath) {
+		t.Fatalf("corrupted entry must be removed")
+	}
+	if n := dc.entriesCount(); n != 0 {
+		t.Fatalf("unexpected number of entries; got %d; want 0", n)
+	}
+}
+
+func TestRollupResultDiskCacheEviction(t *testing.T) {
+	path := t.Name()
+	defer fs.MustRemoveAll(path)
+
+	value := make([]byte, 100)
+	entrySize := int64(len(marshalRollupResultDiskCacheEntry(nil, []byte("key_0"), value)))
+	dc := mustOpenRollupResultDiskCache(path, 3*entrySize, 0)
+	defer dc.MustStop()
+	for i := 0; i < 3; i++ {
+		dc.Set([]byte(fmt.Sprintf("key_%d", i)), value)
+		dc.waitForPendingWrites()
+	}
+
+	// Access key_0, so key_1 becomes the least recently used entry.
+	if _, ok := dc.Get(nil, []byte("key_0")); !ok {
+		t.Fatalf("cannot find key_0")
+	}
+	dc.Set([]byte("key_3"), value)
+	dc.waitForPendingWrites()
+
+	if n := dc.entriesCount(); n != 3 {
+		t.Fatalf("unexpected number of entries; got %d; want 3", n)
+	}
+	if n := dc.size(); n != 3*entrySize {
+		t.Fatalf("unexpected cache size; got %d; want %d", n, 3*entrySize)
+	}
+	if n := dc.evictions.Load(); n != 1 {
+		t.Fatalf("unexpected number of evictions; got %d; want 1", n)
+	}
+	if _, ok := dc.Get(nil, []byte("key_1")); ok {
+		t.Fatalf("key_1 must be evicted")
+	}
+	for _, key := range []string{"key_0", "key_2", "key_3"} {
+		if _, ok := dc.Get(nil, []byte(key)); !ok {
+			t.Fatalf("cannot find %s", key)
+		}
+	}
+}
+
+func TestRollupResultDiskCacheMinEntrySize(t *testing.T) {
+	path := t.Name()
+	defer fs.MustRemoveAll(path)
+
+	dc := mustOpenRollupResultDiskCache(path, 1024*1024, 4)
+	defer dc.MustStop()
+	dc.Set([]byte("small"), []byte("bar"))
+	dc.Set([]byte("big"), []byte("barbaz"))
+	dc.waitForPendingWrites()
+
+	if _, ok := dc.Get(nil, []byte("small")); ok {
+		t.Fatalf("the entry smaller than minEntrySize mustn't be stored")
+	}
+	if value, ok := dc.Get(nil, []byte("big")); !ok || string(value) != "barbaz" {
+		t.Fatalf("unexpected value; got %q; want %q", value, "barbaz")
+	}
+}
+
+func TestRollupResultCacheWithDiskCache(t *testing.T) {
+	path := t.Name()
+	defer fs.MustRemoveAll(path)
+
+	rrc := &rollupResultCache{
+		c:  workingsetcache.New(1024 * 1024),
+		dc: mustOpenRollupResultDiskCache(path, 1024*1024, 0),
+	}
+	defer rrc.c.Stop()
+	defer rrc.dc.MustStop()
+
+	rrc.set([]byte("foo"), []byte("bar"))
+	rrc.setBig([]byte("big"), []byte("value"))
+	rrc.dc.waitForPendingWrites()
+
+	// Big entries must be loaded from the on-disk cache after the in-memory cache is reset,
+	// while metainfo entries mustn't be stored in the on-disk cache.
+	rrc.c.Reset()
+	if value := rrc.get(nil, []byte("foo")); len(value) > 0 {
+		t.Fatalf("unexpected metainfo value found in the on-disk cache: %q", value)
+	}
+	if value := rrc.getBig(nil, []byte("big")); string(value) != "value" {
+		t.Fatalf("unexpected value; got %q; want %q", value, "value")
+	}
+
+	// The entries loaded from the on-disk cache must be stored in the in-memory cache.
+	rrc.dc.Reset()
+	if value := rrc.getBig(nil, []byte("big")); string(value) != "value" {
+		t.Fatalf("unexpected value from the in-memory cache; got %q; want %q", value, "value")
+	}
+}
@@ -607,6 +607,177 @@ func TestRollupHoeffdingBoundUpper(t *testing.T) {
 	f(0.9, 81.46486358019433)
 }
 
+// newSeasonalRollupFuncArg returns rollupFuncArg for 50 samples with 10ms interval and the period of 100ms.
+//
+// Every sample value equals to its phase within the period plus small noise.
+func newSeasonalRollupFuncArg(currTimestamp int64) *rollupFuncArg {
+	var rfa rollupFuncArg
+	rfa.prevValue = nan
+	for i := 0; i < 50; i++ {
+		ts := int64(i * 10)
+		noise := float64(i%3-1) * 0.1
+		rfa.values = append(rfa.values, float64(ts%100)/10+noise)
+		rfa.timestamps = append(rfa.timestamps, ts)
+	}
+	rfa.currTimestamp = currTimestamp
+	rfa.window = currTimestamp
+	return &rfa
+}
+
+func newScalarArg(v float64) []*timeseries {
+	return []*timeseries{{
+		Values:     []float64{v},
+		Timestamps: []int64{123},
+	}}
+}
+
+func TestRollupSeasonalForecast(t *testing.T) {
+	f := func(rfa *rollupFuncArg, period, vExpected float64) {
+		t.Helper()
+		var me metricsql.MetricExpr
+		args := []any{&metricsql.RollupExpr{Expr: &me}, newScalarArg(period)}
+		rf, err := newRollupSeasonalForecast(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		v := rf(rfa)
+		if err := compareValues([]float64{v}, []float64{vExpected}); err != nil {
+			t.Fatalf("unexpected value: %s", err)
+		}
+	}
+
+	// invalid period
+	f(newSeasonalRollupFuncArg(500), 0, nan)
+	f(newSeasonalRollupFuncArg(500), -0.1, nan)
+
+	// the forecast for the phase 0 and 3
+	f(newSeasonalRollupFuncArg(500), 0.1, 0)
+	f(newSeasonalRollupFuncArg(530), 0.1, 3)
+
+	// anomalies in the previous periods do not affect the forecast
+	rfa := newSeasonalRollupFuncArg(530)
+	rfa.values[3] = 1000
+	rfa.values[13] = -1000
+	f(rfa, 0.1, 3)
+
+	// the period exceeds the window
+	f(newSeasonalRollupFuncArg(530), 1, nan)
+
+	// too small number of samples
+	rfa = newSeasonalRollupFuncArg(530)
+	rfa.values = rfa.values[:1]
+	rfa.timestamps = rfa.timestamps[:1]
+	f(rfa, 0.1, nan)
+}
+
+func TestRollupAnomalyScore(t *testing.T) {
+	f := func(rfa *rollupFuncArg, period float64, scoreMin, scoreMax float64) {
+		t.Helper()
+		var me metricsql.MetricExpr
+		args := []any{&metricsql.RollupExpr{Expr: &me}}
+		if !math.IsNaN(period) {
+			args = append(args, newScalarArg(period))
+		}
+		rf, err := newRollupAnomalyScore(args)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		v := rf(rfa)
+		if math.IsNaN(scoreMin) {
+			if !math.IsNaN(v) {
+				t.Fatalf("unexpected score; got %v; want NaN", v)
+			}
+			return
+		}
+		if !(v >= scoreMin && v <= scoreMax) {
+			t.Fatalf("unexpected score; got %v; want in the range [%v ... %v]", v, scoreMin, scoreMax)
+		}
+	}
+
+	withSpike := func(delta float64) *rollupFuncArg {
+		rfa := newSeasonalRollupFuncArg(495)
+		rfa.values[len(rfa.values)-1] += delta
+		return rfa
+	}
+
+	// regular value
+	f(newSeasonalRollupFuncArg(495), 0.1, -3, 3)
+
+	// the spike, which looks regular without seasonality
+	f(withSpike(-5), nan, -3, 3)
+	f(withSpike(-5), 0.1, -inf, -10)
+	f(withSpike(5), 0.1, 10, inf)
+
+	// invalid period
+	f(newSeasonalRollupFuncArg(495), 0, nan, nan)
+
+	// stale series
+	f(newSeasonalRollupFuncArg(1000), 0.1, nan, nan)
+	f(newSeasonalRollupFuncArg(1000), nan, nan, nan)
+
+	// invalid number of args
+	var me metricsql.MetricExpr
+	args := []any{&metricsql.RollupExpr{Expr: &me}, newScalarArg(1), newScalarArg(2)}
+	if _, err := newRollupAnomalyScore(args); err == nil {
+		t.Fatalf("expecting non-nil error")
+	}
+}
+
+func TestRobustZScore(t *testing.T) {
+	f := func(v float64, values []float64, scoreExpected float64) {
+		t.Helper()
+		score := robustZScore(v, values)
+		if math.Abs(score-scoreExpected) > 1e-3 {
+			t.Fatalf("unexpected score for %v relative to %v; got %v; want %v", v, values, score, scoreExpected)
+		}
+	}
+
+	// the value equals to the median
+	f(1, []float64{1, 1, 1, 1}, 0)
+	f(2, []float64{1, 2, 3}, 0)
+
+	// non-zero median absolute deviation
+	f(5, []float64{1, 2, 3, 5}, 1.6862)
+
+	// zero median absolute deviation
+	f(5, []float64{1, 1, 1, 1, 1, 5}, 4.7874)
+	f(-3, []float64{1, 1, 1, 1, 1, -3}, -4.7874)
+}
+
+func TestRollupChangepoint(t *testing.T) {
+	f := func(values []float64, vExpected float64) {
+		t.Helper()
+		var rfa rollupFuncArg
+		rfa.prevValue = nan
+		rfa.values = values
+		for i := range values {
+			rfa.timestamps = append(rfa.timestamps, int64(i+1)*1000)
+		}
+		v := rollupChangepoint(&rfa)
+		if err := compareValues([]float64{v}, []float64{vExpected}); err != nil {
+			t.Fatalf("unexpected value: %s", err)
+		}
+	}
+
+	// too small number of samples
+	f(nil, nan)
+	f([]float64{1, 2, 3}, nan)
+
+	// constant values
+	f([]float64{1, 1, 1, 1, 1}, nan)
+
+	// step change
+	f([]float64{1, 1, 1, 5, 5}, 4)
+	f([]float64{1, 1.1, 0.9, 1, 1.05, 5, 5.1, 4.9, 5, 5.05}, 6)
+	f([]float64{1e12 + 5, 1e12 + 5.1, 1e12 + 4.9, 1e12 + 1, 1e12 + 1.1, 1e12 + 0.9}, 4)
+
+	// noise without level shift
+	f([]float64{1, 2, 1, 2, 1, 2, 1, 2}, nan)
+
+	// a single outlier isn't a level shift
+	f([]float64{1, 1.1, 0.9, 1, 10, 1.05, 0.95, 1}, nan)
+}
+
 func TestRollupNewRollupFuncSuccess(t *testing.T) {
 	f := func(funcName string, vExpected float64) {
 		t.Helper()
@@ -657,6 +828,7 @@ func TestRollupNewRollupFuncSuccess(t *testing.T) {
 	f("ascent_over_time", 142)
 	f("descent_over_time", 231)
 	f("zscore_over_time", -0.4254336383156416)
+	f("changepoint_over_time", nan)
 	f("timestamp", 0.13)
 	f("timestamp_with_name", 0.13)
 	f("mode_over_time", 34)
@@ -51,11 +51,13 @@ var transformFuncs = map[string]transformFunc{
 	"exp":                        newTransformFuncOneArg(transformExp),
 	"floor":                      newTransformFuncOneArg(transformFloor),
 	"histogram_avg":              transformHistogramAvg,
+	"histogram_count":            transformHistogramCount,
 	"histogram_quantile":         transformHistogramQuantile,
 	"histogram_quantiles":        transformHistogramQuantiles,
 	"histogram_share":            transformHistogramShare,
 	"histogram_stddev":           transformHistogramStddev,
 	"histogram_stdvar":           transformHistogramStdvar,
+	"histogram_sum":              transformHistogramSum,
 	"hour":                       newTransformFuncDateTime(transformHour),
 	"interpolate":                transformInterpolate,
 	"keep_last_value":            transformKeepLastValue,
@@ -750,6 +752,53 @@ func transformHistogramAvg(tfa *transformFuncArg) ([]*timeseries, error) {
 	return rvs, nil
 }
 
+func transformHistogramCount(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if err := expectTransformArgsNum(args, 1); err != nil {
+		return nil, err
+	}
+	tss := vmrangeBucketsToLE(args[0])
+	m := groupLeTimeseries(tss)
+	rvs := make([]*timeseries, 0, len(m))
+	for _, xss := range m {
+		sort.Slice(xss, func(i, j int) bool {
+			return xss[i].le < xss[j].le
+		})
+		xss = mergeSameLE(xss)
+		dst := xss[0].ts
+		for i := range dst.Values {
+			fixBrokenBuckets(i, xss)
+			// The bucket with the biggest le contains all the observations.
+			dst.Values[i] = xss[len(xss)-1].ts.Values[i]
+		}
+		rvs = append(rvs, dst)
+	}
+	return rvs, nil
+}
+
+func transformHistogramSum(tfa *transformFuncArg) ([]*timeseries, error) {
+	args := tfa.args
+	if err := expectTransformArgsNum(args, 1); err != nil {
+		return nil, err
+	}
+	tss := vmrangeBucketsToLE(args[0])
+	m := groupLeTimeseries(tss)
+	rvs := make([]*timeseries, 0, len(m))
+	for _, xss := range m {
+		sort.Slice(xss, func(i, j int) bool {
+			return xss[i].le < xss[j].le
+		})
+		xss = mergeSameLE(xss)
+		dst := xss[0].ts
+		for i := range dst.Values {
+			fixBrokenBuckets(i, xss)
+			dst.Values[i] = sumForLeTimeseries(i, xss)
+		}
+		rvs = append(rvs, dst)
+	}
+	return rvs, nil
+}
+
 func transformHistogramStddev(tfa *transformFuncArg) ([]*timeseries, error) {
 	args := tfa.args
 	if err := expectTransformArgsNum(args, 1); err != nil {
@@ -817,6 +866,40 @@ func avgForLeTimeseries(i int, xss []leTimeseries) float64 {
 	return sum / weightTotal
 }
 
+// sumForLeTimeseries estimates the sum of observations for the given buckets at the point i.
+//
+// Every observation is assumed to be located in the middle of its bucket. The lower bound for the first bucket is assumed to be 0
+// if the bucket has positive upper bound. Otherwise observations in the first bucket are assumed to be equal to its upper bound,
+// since the lower bound is unknown. Observations in the +Inf bucket are ignored.
+func sumForLeTimeseries(i int, xss []leTimeseries) float64 {
+	lePrev := nan
+	vPrev := float64(0)
+	sum := float64(0)
+	weightTotal := float64(0)
+	for _, xs := range xss {
+		if math.IsInf(xs.le, 0) {
+			continue
+		}
+		le := xs.le
+		n := le
+		if !math.IsNaN(lePrev) {
+			n = (le + lePrev) / 2
+		} else if le > 0 {
+			n = le / 2
+		}
+		v := xs.ts.Values[i]
+		weight := v - vPrev
+		sum += n * weight
+		weightTotal += weight
+		lePrev = le
+		vPrev = v
+	}
+	if weightTotal == 0 {
+		return nan
+	}
+	return sum
+}
+
 func stdvarForLeTimeseries(i int, xss []leTimeseries) float64 {
 	lePrev := float64(0)
 	vPrev := float64(0)
@@ -0,0 +1,221 @@
+package promql
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/envtemplate"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs/fscore"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/procutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/stringsutil"
+	"github.com/VictoriaMetrics/metrics"
+	"github.com/VictoriaMetrics/metricsql"
+	"gopkg.in/yaml.v2"
+)
+
+var udfConfig = flag.String("search.udfConfig", "", "Optional path to a file with MetricsQL user-defined functions, which can be used in all the queries. "+
+	"The path can point either to local file or to http url. "+
+	"See https://docs.victoriametrics.com/#user-defined-functions for details. The config is reloaded on SIGHUP signal")
+
+// InitUDF must be called after flag.Parse and before executing queries.
+//
+// It loads user-defined functions from -search.udfConfig and re-loads them on SIGHUP.
+func InitUDF() {
+	// Register SIGHUP handler for config re-read just before loadUDFConfig call.
+	// This guarantees that the config will be re-read if the signal arrives during loadUDFConfig call.
+	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1240
+	sighupCh := procutil.NewSighupChan()
+
+	uc, err := loadUDFConfig()
+	if err != nil {
+		logger.Fatalf("cannot load -search.udfConfig: %s", err)
+	}
+	udfConfigGlobal.Store(uc)
+	udfConfigSuccess.Set(1)
+	udfConfigTimestamp.Set(fasttime.UnixTimestamp())
+
+	if len(*udfConfig) == 0 {
+		return
+	}
+	go func() {
+		for range sighupCh {
+			udfConfigReloads.Inc()
+			logger.Infof("received SIGHUP; reloading -search.udfConfig=%q...", *udfConfig)
+			uc, err := loadUDFConfig()
+			if err != nil {
+				udfConfigReloadErrors.Inc()
+				udfConfigSuccess.Set(0)
+				logger.Errorf("cannot load the updated -search.udfConfig: %s; preserving the previous config", err)
+				continue
+			}
+			udfConfigGlobal.Store(uc)
+			// Drop the cached queries, since they may be expanded with the previous functions.
+			parseCacheV.Reset()
+			udfConfigSuccess.Set(1)
+			udfConfigTimestamp.Set(fasttime.UnixTimestamp())
+			logger.Infof("successfully reloaded %d user-defined functions from -search.udfConfig=%q", len(uc.Functions), *udfConfig)
+		}
+	}()
+}
+
+var (
+	udfConfigReloads      = metrics.NewCounter(`vm_udf_config_reloads_total`)
+	udfConfigReloadErrors = metrics.NewCounter(`vm_udf_config_reloads_errors_total`)
+	udfConfigSuccess      = metrics.NewGauge(`vm_udf_config_last_reload_successful`, nil)
+	udfConfigTimestamp    = metrics.NewCounter(`vm_udf_config_last_reload_success_timestamp_seconds`)
+)
+
+var udfConfigGlobal atomic.Pointer[udfConfigData]
+
+// CheckUDFConfig checks config pointed by -search.udfConfig
+func CheckUDFConfig() error {
+	_, err := loadUDFConfig()
+	return err
+}
+
+func loadUDFConfig() (*udfConfigData, error) {
+	if len(*udfConfig) == 0 {
+		return &udfConfigData{}, nil
+	}
+	data, err := fscore.ReadFileOrHTTP(*udfConfig)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read -search.udfConfig=%q: %w", *udfConfig, err)
+	}
+	data, err = envtemplate.ReplaceBytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot expand environment vars at -search.udfConfig=%q: %w", *udfConfig, err)
+	}
+	uc, err := parseUDFConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse -search.udfConfig=%q: %w", *udfConfig, err)
+	}
+	return uc, nil
+}
+
+// udfConfigData represents -search.udfConfig contents.
+type udfConfigData struct {
+	Functions []udf `yaml:"functions"`
+
+	// withPrefix contains `WITH (...)` template definitions for all the Functions.
+	//
+	// It is prepended to queries, which refer the Functions, so they are expanded by metricsql.Parse.
+	withPrefix string
+}
+
+// udf is a user-defined MetricsQL function.
+type udf struct {
+	// Name is the function name.
+	Name string `yaml:"name"`
+
+	// Args contains the names of function args, which can be referred in Expr.
+	Args []string `yaml:"args"`
+
+	// Expr is MetricsQL expression for the function.
+	Expr string `yaml:"expr"`
+
+	// Description is an optional human-readable description for the function.
+	Description string `yaml:"description,omitempty"`
+}
+
+func (f *udf) withArg() string {
+	return fmt.Sprintf("%s(%s) = %s", f.Name, strings.Join(f.Args, ", "), strings.TrimSpace(f.Expr))
+}
+
+func parseUDFConfig(data []byte) (*udfConfigData, error) {
+	var uc udfConfigData
+	if err := yaml.UnmarshalStrict(data, &uc); err != nil {
+		return nil, err
+	}
+	if len(uc.Functions) == 0 {
+		return &uc, nil
+	}
+	names := make(map[string]struct{}, len(uc.Functions))
+	withArgs := make([]string, 0, len(uc.Functions))
+	for i := range uc.Functions {
+		f := &uc.Functions[i]
+		if f.Name == "" {
+			return nil, fmt.Errorf("missing `name` for the function #%d", i+1)
+		}
+		if metricsql.IsSupportedFunction(f.Name) {
+			return nil, fmt.Errorf("function name %q clashes with builtin MetricsQL function", f.Name)
+		}
+		if _, ok := names[f.Name]; ok {
+			return nil, fmt.Errorf("duplicate function name %q", f.Name)
+		}
+		names[f.Name] = struct{}{}
+		// Functions without args would replace the metrics with the same name in all the queries.
+		if len(f.Args) == 0 {
+			return nil, fmt.Errorf("function %q must have at least a single arg", f.Name)
+		}
+		if strings.TrimSpace(f.Expr) == "" {
+			return nil, fmt.Errorf("missing `expr` for the function %q", f.Name)
+		}
+		withArgs = append(withArgs, f.withArg())
+
+		// Functions may refer the previously defined functions, so verify the function together with them.
+		if _, err := metricsql.Parse(buildWithPrefix(withArgs) + "0"); err != nil {
+			return nil, fmt.Errorf("cannot parse function %q: %w", f.Name, err)
+		}
+	}
+	uc.withPrefix = buildWithPrefix(withArgs)
+	return &uc, nil
+}
+
+func buildWithPrefix(withArgs []string) string {
+	return "WITH (\n" + strings.Join(withArgs, ",\n") + "\n)\n"
+}
+
+// Parse parses MetricsQL query q.
+//
+// User-defined functions from -search.udfConfig are expanded in the returned expression.
+func Parse(q string) (metricsql.Expr, error) {
+	e, err := metricsql.Parse(q)
+	if err == nil {
+		// Fast path - q doesn't call user-defined functions, since metricsql.Parse rejects calls to unknown functions.
+		return e, nil
+	}
+	uc := udfConfigGlobal.Load()
+	if uc == nil || len(uc.Functions) == 0 {
+		return nil, err
+	}
+	if _, errSyntax := metricsql.Prettify(q); errSyntax != nil {
+		// Return the original error for syntactically invalid q, since it doesn't depend on user-defined functions.
+		return nil, err
+	}
+
+	// Slow path - q is syntactically valid, but it cannot be expanded, e.g. since it calls user-defined functions.
+	// Parse it together with the definitions for user-defined functions.
+	// The definitions are verified when loading -search.udfConfig, so the returned error may relate only to q.
+	return metricsql.Parse(uc.withPrefix + q)
+}
+
+// UDFHandler returns response to /api/v1/status/udf
+//
+// It writes a JSON with the user-defined functions loaded from -search.udfConfig to w.
+func UDFHandler(w http.ResponseWriter, _ *http.Request) {
+	var fs []udf
+	if uc := udfConfigGlobal.Load(); uc != nil {
+		fs = uc.Functions
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"success","data":[`)
+	for i := range fs {
+		f := &fs[i]
+		args := make([]string, len(f.Args))
+		for j, arg := range f.Args {
+			args[j] = stringsutil.JSONString(arg)
+		}
+		fmt.Fprintf(w, `{"name":%s,"args":[%s],"expr":%s,"description":%s}`,
+			stringsutil.JSONString(f.Name), strings.Join(args, ","), stringsutil.JSONString(f.Expr), stringsutil.JSONString(f.Description))
+		if i+1 < len(fs) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]}`)
+}
@@ -0,0 +1,218 @@
+package promql
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/VictoriaMetrics/metricsql"
+)
+
+func TestParseUDFConfigFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+
+		uc, err := parseUDFConfig([]byte(data))
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+		if uc != nil {
+			t.Fatalf("expecting nil config; got %v", uc)
+		}
+	}
+
+	// invalid yaml
+	f(`foo`)
+
+	// unknown field
+	f(`
+functions:
+- name: foo
+  args: [x]
+  expr: x
+  bar: baz
+`)
+
+	// missing name
+	f(`
+functions:
+- args: [x]
+  expr: x
+`)
+
+	// builtin function name
+	f(`
+functions:
+- name: rate
+  args: [x]
+  expr: x
+`)
+
+	// duplicate name
+	f(`
+functions:
+- name: foo
+  args: [x]
+  expr: x
+- name: foo
+  args: [y]
+  expr: y
+`)
+
+	// missing args
+	f(`
+functions:
+- name: foo
+  expr: up
+`)
+
+	// duplicate args
+	f(`
+functions:
+- name: foo
+  args: [x, x]
+  expr: x
+`)
+
+	// invalid arg name
+	f(`
+functions:
+- name: foo
+  args: ["1"]
+  expr: up
+`)
+
+	// missing expr
+	f(`
+functions:
+- name: foo
+  args: [x]
+`)
+
+	// invalid expr
+	f(`
+functions:
+- name: foo
+  args: [x]
+  expr: sum(x
+`)
+
+	// invalid name
+	f(`
+functions:
+- name: foo bar
+  args: [x]
+  expr: x
+`)
+}
+
+func TestParseWithUDFs(t *testing.T) {
+	uc, err := parseUDFConfig([]byte(`
+functions:
+- name: slo_burn_rate
+  args: [job, window]
+  expr: |
+    sum(rate(errors_total{job=job}[window]))
+      /
+    sum(rate(requests_total{job=job}[window]))
+  description: SLO burn rate for the given job
+- name: slo_burn_rate_5m
+  args: [job]
+  expr: slo_burn_rate(job, 5m) > 1
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	ucOrig := udfConfigGlobal.Load()
+	udfConfigGlobal.Store(uc)
+	defer udfConfigGlobal.Store(ucOrig)
+
+	f := func(q, resultExpected string) {
+		t.Helper()
+
+		e, err := Parse(q)
+		if err != nil {
+			t.Fatalf("cannot parse %q: %s", q, err)
+		}
+		result := string(e.AppendString(nil))
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %q\ngot\n%s\nwant\n%s", q, result, resultExpected)
+		}
+	}
+
+	// queries without functions
+	f(`up`, `up`)
+	f(`sum(rate(foo[5m])) by (job)`, `sum(rate(foo[5m])) by(job)`)
+
+	// function calls
+	f(`slo_burn_rate("api", 1h)`, `sum(rate(errors_total{job="api"}[1h])) / sum(rate(requests_total{job="api"}[1h]))`)
+	f(`slo_burn_rate_5m("db")`, `(sum(rate(errors_total{job="db"}[5m])) / sum(rate(requests_total{job="db"}[5m]))) > 1`)
+	f(`max_over_time(slo_burn_rate("api", 1h)[1d:1h])`, `max_over_time((sum(rate(errors_total{job="api"}[1h])) / sum(rate(requests_total{job="api"}[1h])))[1d:1h])`)
+
+	// metric with the function name
+	f(`slo_burn_rate`, `slo_burn_rate`)
+	f(`slo_burn_rate{job="foo"}`, `slo_burn_rate{job="foo"}`)
+	f(`up{job="slo_burn_rate(x)"}`, `up{job="slo_burn_rate(x)"}`)
+
+	// WITH templates in the query override the functions
+	f(`WITH (slo_burn_rate(a, b) = a + b) slo_burn_rate(1, 2)`, `3`)
+	f(`WITH (w = 10m) slo_burn_rate("x", w)`, `sum(rate(errors_total{job="x"}[10m])) / sum(rate(requests_total{job="x"}[10m]))`)
+
+	// invalid number of args
+	if _, err := Parse(`slo_burn_rate("api")`); err == nil {
+		t.Fatalf("expecting non-nil error for invalid number of args")
+	}
+	// Syntax errors must refer the original query
+	fError := func(q string) {
+		t.Helper()
+
+		_, errExpected := metricsql.Parse(q)
+		if errExpected == nil {
+			t.Fatalf("expecting non-nil error for %q", q)
+		}
+		_, err := Parse(q)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %q", q)
+		}
+		if err.Error() != errExpected.Error() {
+			t.Fatalf("unexpected error for %q\ngot\n%s\nwant\n%s", q, err, errExpected)
+		}
+	}
+	fError(`slo_burn_rate("api", 1h) +`)
+	fError(`sum(slo_burn_rate_5m("db")`)
+	fError(`foo{`)
+	fError(`unknown_func(foo)`)
+}
+
+func TestUDFHandler(t *testing.T) {
+	f := func(data, responseExpected string) {
+		t.Helper()
+
+		uc, err := parseUDFConfig([]byte(data))
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		ucOrig := udfConfigGlobal.Load()
+		udfConfigGlobal.Store(uc)
+		defer udfConfigGlobal.Store(ucOrig)
+
+		w := httptest.NewRecorder()
+		UDFHandler(w, nil)
+		response := w.Body.String()
+		if response != responseExpected {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	f(``, `{"status":"success","data":[]}`)
+	f(`
+functions:
+- name: foo
+  args: [x, y]
+  expr: x + y
+  description: "Sum of \"x\" and y"
+- name: bar
+  args: [x]
+  expr: foo(x, 1)
+`, `{"status":"success","data":[{"name":"foo","args":["x","y"],"expr":"x + y","description":"Sum of \"x\" and y"},`+
+		`{"name":"bar","args":["x"],"expr":"foo(x, 1)","description":""}]}`)
+}
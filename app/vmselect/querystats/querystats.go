@@ -30,10 +30,12 @@ func Enabled() bool {
 
 // RegisterQuery registers the query on the given timeRangeMsecs, which has been started at startTime.
 //
+// memoryPeakBytes is the peak memory usage in bytes during the query execution.
+//
 // RegisterQuery must be called when the query is finished.
-func RegisterQuery(query string, timeRangeMsecs int64, startTime time.Time) {
+func RegisterQuery(query string, timeRangeMsecs int64, startTime time.Time, memoryPeakBytes int64) {
 	initOnce.Do(initQueryStats)
-	qsTracker.registerQuery(query, timeRangeMsecs, startTime)
+	qsTracker.registerQuery(query, timeRangeMsecs, startTime, memoryPeakBytes)
 }
 
 // WriteJSONQueryStats writes query stats to given writer in json format.
@@ -50,10 +52,11 @@ type queryStatsTracker struct {
 }
 
 type queryStatRecord struct {
-	query         string
-	timeRangeSecs int64
-	registerTime  time.Time
-	duration      time.Duration
+	query           string
+	timeRangeSecs   int64
+	registerTime    time.Time
+	duration        time.Duration
+	memoryPeakBytes int64
 }
 
 type queryStatKey struct {
@@ -102,10 +105,18 @@ func (qst *queryStatsTracker) writeJSONQueryStats(w io.Writer, topN int, maxLife
 			fmt.Fprintf(w, `,`)
 		}
 	}
+	fmt.Fprintf(w, `],"topByMemoryPeak":[`)
+	topByMemoryPeak := qst.getTopByMemoryPeak(topN, maxLifetime)
+	for i, r := range topByMemoryPeak {
+		fmt.Fprintf(w, `{"query":%s,"timeRangeSeconds":%d,"maxMemoryPeakBytes":%d,"count":%d}`, stringsutil.JSONString(r.query), r.timeRangeSecs, r.memoryPeakBytes, r.count)
+		if i+1 < len(topByMemoryPeak) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
 	fmt.Fprintf(w, `]}`)
 }
 
-func (qst *queryStatsTracker) registerQuery(query string, timeRangeMsecs int64, startTime time.Time) {
+func (qst *queryStatsTracker) registerQuery(query string, timeRangeMsecs int64, startTime time.Time, memoryPeakBytes int64) {
 	registerTime := time.Now()
 	duration := registerTime.Sub(startTime)
 	if duration < *minQueryDuration {
@@ -126,6 +137,7 @@ func (qst *queryStatsTracker) registerQuery(query string, timeRangeMsecs int64,
 	r.timeRangeSecs = timeRangeMsecs / 1000
 	r.registerTime = registerTime
 	r.duration = duration
+	r.memoryPeakBytes = memoryPeakBytes
 }
 
 func (r *queryStatRecord) matches(currentTime time.Time, maxLifetime time.Duration) bool {
@@ -257,3 +269,49 @@ func (qst *queryStatsTracker) getTopBySumDuration(topN int, maxLifetime time.Dur
 	}
 	return a
 }
+
+func (qst *queryStatsTracker) getTopByMemoryPeak(topN int, maxLifetime time.Duration) []queryStatByMemoryPeak {
+	currentTime := time.Now()
+	qst.mu.Lock()
+	type countMemoryPeak struct {
+		count           int
+		memoryPeakBytes int64
+	}
+	m := make(map[queryStatKey]countMemoryPeak)
+	for _, r := range qst.a {
+		if r.matches(currentTime, maxLifetime) {
+			k := r.key()
+			km := m[k]
+			km.count++
+			if r.memoryPeakBytes > km.memoryPeakBytes {
+				km.memoryPeakBytes = r.memoryPeakBytes
+			}
+			m[k] = km
+		}
+	}
+	qst.mu.Unlock()
+
+	var a []queryStatByMemoryPeak
+	for k, km := range m {
+		a = append(a, queryStatByMemoryPeak{
+			query:           k.query,
+			timeRangeSecs:   k.timeRangeSecs,
+			memoryPeakBytes: km.memoryPeakBytes,
+			count:           km.count,
+		})
+	}
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].memoryPeakBytes > a[j].memoryPeakBytes
+	})
+	if len(a) > topN {
+		a = a[:topN]
+	}
+	return a
+}
+
+type queryStatByMemoryPeak struct {
+	query           string
+	timeRangeSecs   int64
+	memoryPeakBytes int64
+	count           int
+}
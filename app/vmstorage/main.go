@@ -34,6 +34,13 @@ var (
 	snapshotsMaxAge   = flagutil.NewRetentionDuration("snapshotsMaxAge", "0", "Automatically delete snapshots older than -snapshotsMaxAge if it is set to non-zero duration. Make sure that backup process has enough time to finish the backup before the corresponding snapshot is automatically deleted")
 	_                 = flag.Duration("snapshotCreateTimeout", 0, "Deprecated: this flag does nothing")
 
+	retentionFilters = flagutil.NewArrayString("retentionFilter", "Retention filter in the format 'filter:retention'. For example, '{env=\"dev\"}:3d' configures "+
+		"the retention for time series with env=\"dev\" label to 3 days. See https://docs.victoriametrics.com/#retention-filters for details")
+	downsamplingPeriods = flagutil.NewArrayString("downsampling.period", "Comma-separated downsampling periods in the format 'offset:period'. "+
+		"For example, '30d:10m' instructs to leave a single sample per 10 minutes for samples older than 30 days. "+
+		"When setting multiple downsampling periods, it is necessary for the periods to be multiples of each other. "+
+		"See https://docs.victoriametrics.com/#downsampling for details")
+
 	precisionBits = flag.Int("precisionBits", 64, "The number of precision bits to store per each value. Lower precision bits improves data compression at the cost of precision loss")
 
 	// DataPath is a path to storage data.
@@ -59,6 +66,21 @@ var (
 		"Excess series are logged and dropped. This can be useful for limiting series churn rate. See https://docs.victoriametrics.com/#cardinality-limiter . "+
 		"See also -storage.maxHourlySeries")
 
+	maxExemplars = flag.Int("storage.maxExemplars", 100000, "The maximum number of exemplars to store. The oldest exemplars are overwritten by new exemplars "+
+		"when the limit is reached. Set to 0 for disabling exemplars storage. See https://docs.victoriametrics.com/#exemplars")
+	maxMetricMetadata = flag.Int("storage.maxMetricMetadata", 100000, "The maximum number of metric metadata entries (HELP, TYPE and UNIT per metric family) to store. "+
+		"New entries are dropped when the limit is reached. Set to 0 for disabling metric metadata storage. See https://docs.victoriametrics.com/#metric-metadata")
+	trackMetricNamesStats = flag.Bool("storage.trackMetricNamesStats", false, "Whether to track the number of query requests and the last query time per each metric name. "+
+		"The stats can be obtained via /api/v1/status/metric_names_stats . See https://docs.victoriametrics.com/#track-ingested-metrics-usage . See also -storage.maxMetricNamesStats")
+	maxMetricNamesStats = flag.Int("storage.maxMetricNamesStats", 100000, "The maximum number of metric names to track usage stats for when -storage.trackMetricNamesStats is set. "+
+		"New metric names aren't tracked when the limit is reached. See https://docs.victoriametrics.com/#track-ingested-metrics-usage")
+
+	checkParts = flag.Bool("storage.check", false, "Whether to verify the integrity of all the data and indexdb parts at -storageDataPath before opening the storage. "+
+		"VictoriaMetrics exits if corrupted parts are found and -storage.quarantinePath isn't set. See https://docs.victoriametrics.com/#storage-integrity-check")
+	quarantinePath = flag.String("storage.quarantinePath", "", "Path to move corrupted parts found by -storage.check to. "+
+		"If set, then VictoriaMetrics starts with the remaining data after moving corrupted parts out of -storageDataPath. "+
+		"The path must be located on the same filesystem as -storageDataPath. See https://docs.victoriametrics.com/#storage-integrity-check")
+
 	minFreeDiskSpaceBytes = flagutil.NewBytes("storage.minFreeDiskSpaceBytes", 10e6, "The minimum free disk space at -storageDataPath after which the storage stops accepting new data")
 
 	cacheSizeStorageTSID = flagutil.NewBytes("storage.cacheSizeStorageTSID", 0, "Overrides max size for storage/tsid cache. "+
@@ -92,10 +114,29 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 		logger.Fatalf("invalid `-precisionBits`: %s", err)
 	}
 
+	dps, err := storage.ParseDownsamplingPeriods(*downsamplingPeriods)
+	if err != nil {
+		logger.Fatalf("invalid -downsampling.period: %s", err)
+	}
+	if err := storage.SetDownsamplingPeriods(dps); err != nil {
+		logger.Fatalf("invalid -downsampling.period: %s", err)
+	}
+
+	rfs, err := parseRetentionFilters(*retentionFilters)
+	if err != nil {
+		logger.Fatalf("invalid -retentionFilter: %s", err)
+	}
+	storage.SetRetentionFilters(rfs)
+
 	resetResponseCacheIfNeeded = resetCacheIfNeeded
 	storage.SetLogNewSeries(*logNewSeries)
 	storage.SetRetentionTimezoneOffset(*retentionTimezoneOffset)
 	storage.SetFreeDiskSpaceLimit(minFreeDiskSpaceBytes.N)
+	storage.SetMaxExemplars(*maxExemplars)
+	storage.SetMaxMetricMetadata(*maxMetricMetadata)
+	if *trackMetricNamesStats {
+		storage.SetMaxMetricNamesStats(*maxMetricNamesStats)
+	}
 	storage.SetTSIDCacheSize(cacheSizeStorageTSID.IntN())
 	storage.SetTagFiltersCacheSize(cacheSizeIndexDBTagFilters.IntN())
 	mergeset.SetIndexBlocksCacheSize(cacheSizeIndexDBIndexBlocks.IntN())
@@ -104,6 +145,9 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 	if retentionPeriod.Duration() < 24*time.Hour {
 		logger.Fatalf("-retentionPeriod cannot be smaller than a day; got %s", retentionPeriod)
 	}
+	if *checkParts {
+		mustCheckDataDir(*DataPath, *quarantinePath)
+	}
 	logger.Infof("opening storage at %q with -retentionPeriod=%s", *DataPath, retentionPeriod)
 	startTime := time.Now()
 	WG = syncwg.WaitGroup{}
@@ -129,8 +173,46 @@ func Init(resetCacheIfNeeded func(mrs []storage.MetricRow)) {
 	metrics.RegisterSet(storageMetrics)
 }
 
+func parseRetentionFilters(ss []string) ([]*storage.RetentionFilter, error) {
+	var rfs []*storage.RetentionFilter
+	for _, s := range ss {
+		rf, err := storage.ParseRetentionFilter(s)
+		if err != nil {
+			return nil, err
+		}
+		if rf.Retention > retentionPeriod.Milliseconds() {
+			return nil, fmt.Errorf("retention in %q cannot exceed -retentionPeriod=%s", s, retentionPeriod)
+		}
+		rfs = append(rfs, rf)
+	}
+	return rfs, nil
+}
+
 var storageMetrics *metrics.Set
 
+func mustCheckDataDir(path, quarantinePath string) {
+	logger.Infof("checking the integrity of parts at %q...", path)
+	startTime := time.Now()
+	var corruptedParts int
+	partsChecked, err := storage.CheckDataDir(path, quarantinePath, func(partPath string, err error) {
+		logger.Errorf("found corrupted part %q: %s", partPath, err)
+		corruptedParts++
+	})
+	if err != nil {
+		logger.Fatalf("cannot check the integrity of parts at %q: %s", path, err)
+	}
+	if corruptedParts == 0 {
+		logger.Infof("successfully checked %d parts at %q in %.3f seconds", partsChecked, path, time.Since(startTime).Seconds())
+		return
+	}
+	if quarantinePath == "" {
+		logger.Fatalf("found %d corrupted parts out of %d parts at %q; see the errors above; "+
+			"set -storage.quarantinePath in order to move the corrupted parts out of -storageDataPath and start with the remaining data", corruptedParts, partsChecked, path)
+	}
+	logger.Warnf("moved %d corrupted parts out of %d parts at %q to -storage.quarantinePath=%q in %.3f seconds",
+		corruptedParts, partsChecked, path, quarantinePath, time.Since(startTime).Seconds())
+}
+
 // Storage is a storage.
 //
 // Every storage call must be wrapped into WG.Add(1) ... WG.Done()
@@ -161,6 +243,28 @@ func AddRows(mrs []storage.MetricRow) error {
 
 var errReadOnly = errors.New("the storage is in read-only mode; check -storage.minFreeDiskSpaceBytes command-line flag value")
 
+// AddExemplars adds ers to the storage.
+func AddExemplars(ers []storage.ExemplarRow) error {
+	if Storage.IsReadOnly() {
+		return errReadOnly
+	}
+	WG.Add(1)
+	Storage.AddExemplars(ers)
+	WG.Done()
+	return nil
+}
+
+// AddMetricMetadata adds mms to the storage.
+func AddMetricMetadata(mms []storage.MetricMetadata) error {
+	if Storage.IsReadOnly() {
+		return errReadOnly
+	}
+	WG.Add(1)
+	Storage.AddMetricMetadata(mms)
+	WG.Done()
+	return nil
+}
+
 // RegisterMetricNames registers all the metrics from mrs in the storage.
 func RegisterMetricNames(qt *querytracer.Tracer, mrs []storage.MetricRow) {
 	WG.Add(1)
@@ -178,6 +282,16 @@ func DeleteSeries(qt *querytracer.Tracer, tfss []*storage.TagFilters, maxMetrics
 	return n, err
 }
 
+// DeleteSeriesOnTimeRange deletes samples on the given tr for series matching tfss.
+//
+// Returns the number of series with deleted samples.
+func DeleteSeriesOnTimeRange(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int) (int, error) {
+	WG.Add(1)
+	n, err := Storage.DeleteSeriesOnTimeRange(qt, tfss, tr, maxMetrics)
+	WG.Done()
+	return n, err
+}
+
 // SearchMetricNames returns metric names for the given tfss on the given tr.
 func SearchMetricNames(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) ([]string, error) {
 	WG.Add(1)
@@ -186,6 +300,52 @@ func SearchMetricNames(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr st
 	return metricNames, err
 }
 
+// EstimateSeries returns the estimated costs for searching series matching tfss on the given tr.
+func EstimateSeries(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxMetrics int, deadline uint64) (*storage.SeriesEstimate, error) {
+	WG.Add(1)
+	se, err := Storage.EstimateSeries(qt, tfss, tr, maxMetrics, deadline)
+	WG.Done()
+	return se, err
+}
+
+// SearchExemplars returns exemplars on the given tr for series matching tfss.
+func SearchExemplars(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxSeries int) ([]storage.SeriesExemplars, error) {
+	WG.Add(1)
+	result, err := Storage.SearchExemplars(qt, tfss, tr, maxSeries)
+	WG.Done()
+	return result, err
+}
+
+// SearchMetricMetadata returns metadata for the given metricFamilyName.
+func SearchMetricMetadata(qt *querytracer.Tracer, metricFamilyName string, limit, limitPerMetric int) []storage.MetricMetadata {
+	WG.Add(1)
+	result := Storage.SearchMetricMetadata(qt, metricFamilyName, limit, limitPerMetric)
+	WG.Done()
+	return result
+}
+
+// GetMetricNamesStats returns usage stats for metric names.
+func GetMetricNamesStats(qt *querytracer.Tracer, limit, le int, matchPattern string) (*storage.MetricNamesStatsResult, error) {
+	if !*trackMetricNamesStats {
+		return nil, fmt.Errorf("metric names stats tracking is disabled; pass -storage.trackMetricNamesStats command-line flag for enabling it")
+	}
+	WG.Add(1)
+	result := Storage.GetMetricNamesStats(qt, limit, le, matchPattern)
+	WG.Done()
+	return result, nil
+}
+
+// ResetMetricNamesStats resets usage stats for metric names.
+func ResetMetricNamesStats(qt *querytracer.Tracer) error {
+	if !*trackMetricNamesStats {
+		return fmt.Errorf("metric names stats tracking is disabled; pass -storage.trackMetricNamesStats command-line flag for enabling it")
+	}
+	WG.Add(1)
+	Storage.ResetMetricNamesStats(qt)
+	WG.Done()
+	return nil
+}
+
 // SearchLabelNamesWithFiltersOnTimeRange searches for tag keys matching the given tfss on tr.
 func SearchLabelNamesWithFiltersOnTimeRange(qt *querytracer.Tracer, tfss []*storage.TagFilters, tr storage.TimeRange, maxTagKeys, maxMetrics int, deadline uint64) ([]string, error) {
 	WG.Add(1)
@@ -222,10 +382,15 @@ func SearchGraphitePaths(qt *querytracer.Tracer, tr storage.TimeRange, query []b
 	return paths, err
 }
 
-// GetTSDBStatus returns TSDB status for given filters on the given date.
-func GetTSDBStatus(qt *querytracer.Tracer, tfss []*storage.TagFilters, date uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*storage.TSDBStatus, error) {
+// GetMinRetentionDate returns the first date, which may contain data according to -retentionPeriod.
+func GetMinRetentionDate() uint64 {
+	return Storage.GetMinRetentionDate()
+}
+
+// GetTSDBStatusForDateRange returns TSDB status for given filters on the [minDate..maxDate] date range.
+func GetTSDBStatusForDateRange(qt *querytracer.Tracer, tfss []*storage.TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*storage.TSDBStatus, error) {
 	WG.Add(1)
-	status, err := Storage.GetTSDBStatus(qt, tfss, date, focusLabel, topN, maxMetrics, deadline)
+	status, err := Storage.GetTSDBStatusForDateRange(qt, tfss, minDate, maxDate, focusLabel, topN, maxMetrics, deadline)
 	WG.Done()
 	return status, err
 }
@@ -524,6 +689,7 @@ func writeStorageMetrics(w io.Writer, strg *storage.Storage) {
 	metrics.WriteCounterUint64(w, `vm_rows_received_by_storage_total`, m.RowsReceivedTotal)
 	metrics.WriteCounterUint64(w, `vm_rows_added_to_storage_total`, m.RowsAddedTotal)
 	metrics.WriteCounterUint64(w, `vm_deduplicated_samples_total{type="merge"}`, m.DedupsDuringMerge)
+	metrics.WriteCounterUint64(w, `vm_downsampled_samples_total{type="merge"}`, m.DownsampledSamplesDuringMerge)
 	metrics.WriteGaugeUint64(w, `vm_snapshots`, m.SnapshotsCount)
 
 	metrics.WriteCounterUint64(w, `vm_rows_ignored_total{reason="big_timestamp"}`, m.TooBigTimestampRows)
@@ -638,6 +804,22 @@ func writeStorageMetrics(w io.Writer, strg *storage.Storage) {
 
 	metrics.WriteGaugeUint64(w, `vm_next_retention_seconds`, m.NextRetentionSeconds)
 
+	metrics.WriteGaugeUint64(w, `vm_exemplars`, m.ExemplarsCount)
+	metrics.WriteGaugeUint64(w, `vm_exemplar_series`, m.ExemplarSeriesCount)
+	metrics.WriteGaugeUint64(w, `vm_exemplars_max`, m.ExemplarsMaxCount)
+	metrics.WriteCounterUint64(w, `vm_exemplars_added_total`, m.ExemplarsAddedTotal)
+	metrics.WriteCounterUint64(w, `vm_exemplars_ignored_total{reason="out_of_order"}`, m.ExemplarsOutOfOrderTotal)
+	metrics.WriteCounterUint64(w, `vm_exemplars_ignored_total{reason="duplicate"}`, m.ExemplarsDuplicatesTotal)
+
+	metrics.WriteGaugeUint64(w, `vm_metric_metadata`, m.MetricMetadataCount)
+	metrics.WriteGaugeUint64(w, `vm_metric_metadata_max`, m.MetricMetadataMaxCount)
+	metrics.WriteCounterUint64(w, `vm_metric_metadata_added_total`, m.MetricMetadataAddedTotal)
+	metrics.WriteCounterUint64(w, `vm_metric_metadata_dropped_total`, m.MetricMetadataDroppedTotal)
+
+	metrics.WriteGaugeUint64(w, `vm_metric_names_stats`, m.MetricNamesStatsCount)
+	metrics.WriteGaugeUint64(w, `vm_metric_names_stats_max`, m.MetricNamesStatsMaxCount)
+	metrics.WriteCounterUint64(w, `vm_metric_names_stats_dropped_total`, m.MetricNamesStatsDroppedTotal)
+
 	metrics.WriteGaugeUint64(w, `vm_downsampling_partitions_scheduled`, tm.ScheduledDownsamplingPartitions)
 	metrics.WriteGaugeUint64(w, `vm_downsampling_partitions_scheduled_size_bytes`, tm.ScheduledDownsamplingPartitionsSize)
 }
@@ -0,0 +1,257 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+// Config contains settings for connecting to Kafka brokers.
+type Config struct {
+	// Brokers contains addresses of Kafka bootstrap brokers in the form host:port.
+	Brokers []string
+
+	// ClientID is an optional client id, which is sent to Kafka brokers with every request.
+	ClientID string
+
+	// DialTimeout is the timeout for establishing connections to Kafka brokers.
+	//
+	// 5 seconds is used if DialTimeout isn't set.
+	DialTimeout time.Duration
+
+	// RequestTimeout is the timeout for producing a single message and for committing offsets.
+	//
+	// 30 seconds is used if RequestTimeout isn't set.
+	RequestTimeout time.Duration
+
+	// SecurityProtocol is the protocol for communicating with Kafka brokers.
+	//
+	// Supported values: PLAINTEXT, SSL, SASL_PLAINTEXT and SASL_SSL. PLAINTEXT is used if SecurityProtocol isn't set.
+	SecurityProtocol string
+
+	// TLS contains TLS settings, which are used if SecurityProtocol is SSL or SASL_SSL.
+	TLS promauth.TLSConfig
+
+	// SASLMechanism is the SASL mechanism, which is used if SecurityProtocol is SASL_PLAINTEXT or SASL_SSL.
+	//
+	// Supported values: PLAIN, SCRAM-SHA-256 and SCRAM-SHA-512. PLAIN is used if SASLMechanism isn't set.
+	SASLMechanism string
+
+	// SASLUsername and SASLPassword are credentials for SASL authentication.
+	SASLUsername string
+	SASLPassword string
+
+	// Compression is the compression codec for produced messages.
+	//
+	// Supported values: none, gzip, snappy, lz4 and zstd. Messages aren't compressed if Compression isn't set.
+	// Consumers decompress messages with any of these codecs automatically.
+	Compression string
+
+	// MaxMessageBytes is the maximum size of a message batch sent to Kafka.
+	//
+	// The default value of the Kafka client is used if MaxMessageBytes isn't set.
+	MaxMessageBytes int
+
+	// FromBeginning instructs consumers to read partitions without committed offsets from the beginning.
+	//
+	// By default only new messages are read from such partitions.
+	FromBeginning bool
+}
+
+// SetOptions sets options from s to cfg.
+//
+// s must contain `key=value` pairs delimited by `;`. See SetOption for the supported keys.
+func (cfg *Config) SetOptions(s string) error {
+	for _, kv := range strings.Split(s, ";") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		n := strings.IndexByte(kv, '=')
+		if n < 0 {
+			return fmt.Errorf("missing `=` in %q; options must be set in the form key1=value1;...;keyN=valueN", kv)
+		}
+		if err := cfg.SetOption(strings.TrimSpace(kv[:n]), strings.TrimSpace(kv[n+1:])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetOption sets the option with the given key to the given value at cfg.
+//
+// Keys are named in the same way as in librdkafka - see https://github.com/edenhill/librdkafka/blob/master/CONFIGURATION.md .
+// Only a subset of librdkafka options is supported.
+func (cfg *Config) SetOption(key, value string) error {
+	switch key {
+	case "client.id":
+		cfg.ClientID = value
+	case "security.protocol":
+		switch strings.ToUpper(value) {
+		case "PLAINTEXT", "SSL", "SASL_PLAINTEXT", "SASL_SSL":
+			cfg.SecurityProtocol = strings.ToUpper(value)
+		default:
+			return fmt.Errorf("unsupported %s=%q; supported values: PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL", key, value)
+		}
+	case "sasl.mechanisms", "sasl.mechanism":
+		switch strings.ToUpper(value) {
+		case "PLAIN", "SCRAM-SHA-256", "SCRAM-SHA-512":
+			cfg.SASLMechanism = strings.ToUpper(value)
+		default:
+			return fmt.Errorf("unsupported %s=%q; supported values: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512", key, value)
+		}
+	case "sasl.username":
+		cfg.SASLUsername = value
+	case "sasl.password":
+		cfg.SASLPassword = value
+	case "ssl.ca.location":
+		cfg.TLS.CAFile = value
+	case "ssl.certificate.location":
+		cfg.TLS.CertFile = value
+	case "ssl.key.location":
+		cfg.TLS.KeyFile = value
+	case "enable.ssl.certificate.verification":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("cannot parse %s=%q: %w", key, value, err)
+		}
+		cfg.TLS.InsecureSkipVerify = !b
+	case "compression.type", "compression.codec":
+		if _, err := getCompressionCodec(value); err != nil {
+			return fmt.Errorf("unsupported %s=%q: %w", key, value, err)
+		}
+		cfg.Compression = value
+	case "message.max.bytes":
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("%s must be a positive integer; got %q", key, value)
+		}
+		cfg.MaxMessageBytes = n
+	case "auto.offset.reset":
+		switch value {
+		case "earliest", "smallest", "beginning":
+			cfg.FromBeginning = true
+		case "latest", "largest", "end":
+			cfg.FromBeginning = false
+		default:
+			return fmt.Errorf("unsupported %s=%q; supported values: earliest, latest", key, value)
+		}
+	default:
+		return fmt.Errorf("unsupported option %q; supported options: client.id, security.protocol, sasl.mechanisms, sasl.username, sasl.password, "+
+			"ssl.ca.location, ssl.certificate.location, ssl.key.location, enable.ssl.certificate.verification, "+
+			"compression.type, message.max.bytes, auto.offset.reset", key)
+	}
+	return nil
+}
+
+func (cfg *Config) getRequestTimeout() time.Duration {
+	if cfg.RequestTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return cfg.RequestTimeout
+}
+
+// newClientOpts returns options for Kafka client, which are common for producers and consumers.
+func (cfg *Config) newClientOpts() ([]kgo.Opt, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, fmt.Errorf("missing Kafka brokers")
+	}
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = 5 * time.Second
+	}
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DialTimeout(dialTimeout),
+	}
+	if cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(cfg.ClientID))
+	}
+
+	var useTLS, useSASL bool
+	switch cfg.SecurityProtocol {
+	case "", "PLAINTEXT":
+	case "SSL":
+		useTLS = true
+	case "SASL_PLAINTEXT":
+		useSASL = true
+	case "SASL_SSL":
+		useTLS = true
+		useSASL = true
+	default:
+		return nil, fmt.Errorf("unsupported security protocol %q; supported protocols: PLAINTEXT, SSL, SASL_PLAINTEXT, SASL_SSL", cfg.SecurityProtocol)
+	}
+	if useTLS {
+		tlsCfg := cfg.TLS
+		ac, err := (&promauth.Options{TLSConfig: &tlsCfg}).NewConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize TLS config: %w", err)
+		}
+		tc, err := ac.GetTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("cannot initialize TLS config: %w", err)
+		}
+		opts = append(opts, kgo.DialTLSConfig(tc))
+	}
+	if useSASL {
+		m, err := cfg.getSASLMechanism()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, kgo.SASL(m))
+	}
+	return opts, nil
+}
+
+func (cfg *Config) getSASLMechanism() (sasl.Mechanism, error) {
+	if cfg.SASLUsername == "" {
+		return nil, fmt.Errorf("missing SASL username for security protocol %s", cfg.SecurityProtocol)
+	}
+	switch cfg.SASLMechanism {
+	case "", "PLAIN":
+		a := plain.Auth{
+			User: cfg.SASLUsername,
+			Pass: cfg.SASLPassword,
+		}
+		return a.AsMechanism(), nil
+	case "SCRAM-SHA-256":
+		a := scram.Auth{
+			User: cfg.SASLUsername,
+			Pass: cfg.SASLPassword,
+		}
+		return a.AsSha256Mechanism(), nil
+	case "SCRAM-SHA-512":
+		a := scram.Auth{
+			User: cfg.SASLUsername,
+			Pass: cfg.SASLPassword,
+		}
+		return a.AsSha512Mechanism(), nil
+	default:
+		return nil, fmt.Errorf("unsupported SASL mechanism %q; supported mechanisms: PLAIN, SCRAM-SHA-256, SCRAM-SHA-512", cfg.SASLMechanism)
+	}
+}
+
+func getCompressionCodec(s string) (kgo.CompressionCodec, error) {
+	switch s {
+	case "", "none":
+		return kgo.NoCompression(), nil
+	case "gzip":
+		return kgo.GzipCompression(), nil
+	case "snappy":
+		return kgo.SnappyCompression(), nil
+	case "lz4":
+		return kgo.Lz4Compression(), nil
+	case "zstd":
+		return kgo.ZstdCompression(), nil
+	default:
+		return kgo.CompressionCodec{}, fmt.Errorf("unsupported compression %q; supported values: none, gzip, snappy, lz4, zstd", s)
+	}
+}
@@ -0,0 +1,125 @@
+package kafka
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+func TestConfigSetOptionsSuccess(t *testing.T) {
+	f := func(s string, cfgExpected *Config) {
+		t.Helper()
+
+		var cfg Config
+		if err := cfg.SetOptions(s); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(&cfg, cfgExpected) {
+			t.Fatalf("unexpected config\ngot\n%#v\nwant\n%#v", &cfg, cfgExpected)
+		}
+	}
+
+	f("", &Config{})
+	f("client.id=foo;", &Config{
+		ClientID: "foo",
+	})
+	f("security.protocol=sasl_ssl; sasl.mechanisms=SCRAM-SHA-512; sasl.username=user; sasl.password=pass", &Config{
+		SecurityProtocol: "SASL_SSL",
+		SASLMechanism:    "SCRAM-SHA-512",
+		SASLUsername:     "user",
+		SASLPassword:     "pass",
+	})
+	f("security.protocol=SSL;ssl.ca.location=/ca.pem;ssl.certificate.location=/cert.pem;ssl.key.location=/key.pem;enable.ssl.certificate.verification=false", &Config{
+		SecurityProtocol: "SSL",
+		TLS: promauth.TLSConfig{
+			CAFile:             "/ca.pem",
+			CertFile:           "/cert.pem",
+			KeyFile:            "/key.pem",
+			InsecureSkipVerify: true,
+		},
+	})
+	f("compression.type=lz4;message.max.bytes=1000000;auto.offset.reset=earliest", &Config{
+		Compression:     "lz4",
+		MaxMessageBytes: 1000000,
+		FromBeginning:   true,
+	})
+}
+
+func TestConfigSetOptionsFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+
+		var cfg Config
+		if err := cfg.SetOptions(s); err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	// missing value
+	f("client.id")
+
+	// unsupported option
+	f("acks=0")
+
+	// invalid values
+	f("security.protocol=foo")
+	f("sasl.mechanisms=GSSAPI")
+	f("enable.ssl.certificate.verification=foo")
+	f("compression.type=brotli")
+	f("message.max.bytes=-1")
+	f("auto.offset.reset=foo")
+}
+
+func TestConfigNewClientOptsFailure(t *testing.T) {
+	f := func(cfg *Config) {
+		t.Helper()
+
+		if _, err := cfg.newClientOpts(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing brokers
+	f(&Config{})
+
+	// missing SASL username
+	f(&Config{
+		Brokers:          []string{"localhost:9092"},
+		SecurityProtocol: "SASL_PLAINTEXT",
+	})
+
+	// missing TLS files
+	f(&Config{
+		Brokers:          []string{"localhost:9092"},
+		SecurityProtocol: "SSL",
+		TLS: promauth.TLSConfig{
+			CAFile: "/non-existing-file",
+		},
+	})
+}
+
+func TestConfigNewClientOptsSuccess(t *testing.T) {
+	f := func(cfg *Config, optsLenExpected int) {
+		t.Helper()
+
+		opts, err := cfg.newClientOpts()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(opts) != optsLenExpected {
+			t.Fatalf("unexpected number of options; got %d; want %d", len(opts), optsLenExpected)
+		}
+	}
+
+	f(&Config{
+		Brokers: []string{"localhost:9092"},
+	}, 2)
+	f(&Config{
+		Brokers:          []string{"localhost:9092"},
+		ClientID:         "vmagent",
+		SecurityProtocol: "SASL_SSL",
+		SASLMechanism:    "SCRAM-SHA-256",
+		SASLUsername:     "user",
+	}, 5)
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Header is a Kafka record header.
+type Header struct {
+	Key   string
+	Value []byte
+}
+
+// Message is a Kafka record.
+type Message struct {
+	// Partition is the topic partition the message belongs to.
+	Partition int32
+
+	// Offset is the message offset in the Partition.
+	Offset int64
+
+	// Timestamp is the message timestamp in milliseconds.
+	Timestamp int64
+
+	Key     []byte
+	Value   []byte
+	Headers []Header
+}
+
+// GetHeader returns the value for the header with the given key.
+//
+// nil is returned if m has no such header.
+func (m *Message) GetHeader(key string) []byte {
+	for _, h := range m.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return nil
+}
+
+// Consumer reads messages from a Kafka topic.
+//
+// Consumer isn't safe for concurrent use.
+type Consumer struct {
+	c              *kgo.Client
+	groupID        string
+	requestTimeout time.Duration
+}
+
+// NewConsumer returns a consumer for reading messages from the given topic at Kafka brokers from cfg.
+//
+// If groupID is set, then the consumer joins the given consumer group, so topic partitions are balanced among group members.
+// Partitions are read starting from the offsets committed in groupID, while Commit stores the offsets for the read messages in groupID.
+// If groupID isn't set, then the consumer reads all the partitions of the topic and Commit does nothing.
+//
+// If there are no committed offsets for a partition, then the partition is read from the earliest offset if cfg.FromBeginning is set.
+// Otherwise it is read starting from new messages.
+//
+// Connections to Kafka brokers are established in background.
+// MustClose must be called when the consumer is no longer needed.
+func NewConsumer(cfg *Config, topic, groupID string) (*Consumer, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("missing Kafka topic")
+	}
+	opts, err := cfg.newClientOpts()
+	if err != nil {
+		return nil, err
+	}
+	resetOffset := kgo.NewOffset().AtEnd()
+	if cfg.FromBeginning {
+		resetOffset = kgo.NewOffset().AtStart()
+	}
+	opts = append(opts,
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(resetOffset),
+	)
+	if groupID != "" {
+		// Offsets are committed explicitly via Commit after the fetched messages are processed.
+		// Rebalancing is blocked until then, so partitions cannot be re-assigned to other group members
+		// in the middle of processing.
+		opts = append(opts,
+			kgo.ConsumerGroup(groupID),
+			kgo.DisableAutoCommit(),
+			kgo.BlockRebalanceOnPoll(),
+		)
+	}
+	c, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create Kafka client: %w", err)
+	}
+	cr := &Consumer{
+		c:              c,
+		groupID:        groupID,
+		requestTimeout: cfg.getRequestTimeout(),
+	}
+	return cr, nil
+}
+
+// Fetch returns the next messages from the topic.
+//
+// It waits for up to maxWait for new messages. It returns empty result if there are no new messages.
+// Messages are sorted by offset per every partition.
+//
+// Messages returned from the previous call must be processed and committed before the next call,
+// since Fetch allows re-assigning partitions among consumer group members.
+//
+// Fetch may return non-empty messages together with non-nil error if some of partitions cannot be read.
+// The returned messages must be processed in this case.
+func (cr *Consumer) Fetch(maxWait time.Duration) ([]Message, error) {
+	cr.c.AllowRebalance()
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+	fs := cr.c.PollFetches(ctx)
+
+	var errs []error
+	for _, fe := range fs.Errors() {
+		if errors.Is(fe.Err, context.DeadlineExceeded) {
+			// There are no new messages during maxWait.
+			continue
+		}
+		errs = append(errs, fmt.Errorf("cannot read partition %d: %w", fe.Partition, fe.Err))
+	}
+	msgs := make([]Message, 0, fs.NumRecords())
+	fs.EachRecord(func(r *kgo.Record) {
+		m := Message{
+			Partition: r.Partition,
+			Offset:    r.Offset,
+			Timestamp: r.Timestamp.UnixMilli(),
+			Key:       r.Key,
+			Value:     r.Value,
+		}
+		if len(r.Headers) > 0 {
+			m.Headers = make([]Header, len(r.Headers))
+			for i, h := range r.Headers {
+				m.Headers[i] = Header(h)
+			}
+		}
+		msgs = append(msgs, m)
+	})
+	return msgs, errors.Join(errs...)
+}
+
+// Commit stores offsets for the messages returned by Fetch in the consumer group.
+//
+// It does nothing if the consumer group isn't set.
+func (cr *Consumer) Commit() error {
+	if cr.groupID == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cr.requestTimeout)
+	defer cancel()
+	return cr.c.CommitUncommittedOffsets(ctx)
+}
+
+// MustClose leaves the consumer group and closes connections to Kafka brokers.
+func (cr *Consumer) MustClose() {
+	// Leaving the group triggers rebalancing, which is blocked after Fetch call until it is allowed explicitly.
+	cr.c.CloseAllowingRebalance()
+}
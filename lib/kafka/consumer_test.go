@@ -0,0 +1,329 @@
+package kafka
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kfake"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+)
+
+func TestProducerConsumer(t *testing.T) {
+	f := func(compression string) {
+		t.Helper()
+
+		c := newTestCluster(t, 3)
+		defer c.Close()
+		cfg := &Config{
+			Brokers:       c.ListenAddrs(),
+			Compression:   compression,
+			FromBeginning: true,
+		}
+		values := mustProduceTestMessages(t, cfg, 0, 10)
+
+		cr, err := NewConsumer(cfg, "topic", "")
+		if err != nil {
+			t.Fatalf("cannot create consumer: %s", err)
+		}
+		defer cr.MustClose()
+		msgs := mustFetchTestMessages(t, cr, len(values))
+		checkTestMessages(t, msgs, values)
+	}
+
+	f("")
+	f("none")
+	f("gzip")
+	f("snappy")
+	f("lz4")
+	f("zstd")
+}
+
+func TestConsumerCommit(t *testing.T) {
+	c := newTestCluster(t, 2)
+	defer c.Close()
+	cfg := &Config{
+		Brokers:       c.ListenAddrs(),
+		FromBeginning: true,
+	}
+	values := mustProduceTestMessages(t, cfg, 0, 10)
+
+	// Read all the messages and commit offsets for them.
+	cr, err := NewConsumer(cfg, "topic", "group")
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	msgs := mustFetchTestMessages(t, cr, len(values))
+	checkTestMessages(t, msgs, values)
+	if err := cr.Commit(); err != nil {
+		t.Fatalf("cannot commit offsets: %s", err)
+	}
+	cr.MustClose()
+
+	// The consumer in the same group must read only messages written after the commit.
+	values = mustProduceTestMessages(t, cfg, 10, 5)
+	cr, err = NewConsumer(cfg, "topic", "group")
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	msgs = mustFetchTestMessages(t, cr, len(values))
+	checkTestMessages(t, msgs, values)
+
+	// Messages without commit must be read again by the next consumer in the same group.
+	cr.MustClose()
+	cr, err = NewConsumer(cfg, "topic", "group")
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	defer cr.MustClose()
+	msgs = mustFetchTestMessages(t, cr, len(values))
+	checkTestMessages(t, msgs, values)
+}
+
+func TestConsumerGroupRebalance(t *testing.T) {
+	c := newTestCluster(t, 4)
+	defer c.Close()
+	cfg := &Config{
+		Brokers:       c.ListenAddrs(),
+		FromBeginning: true,
+	}
+	values := mustProduceTestMessages(t, cfg, 0, 20)
+
+	var crs []*Consumer
+	for i := 0; i < 2; i++ {
+		cr, err := NewConsumer(cfg, "topic", "group")
+		if err != nil {
+			t.Fatalf("cannot create consumer: %s", err)
+		}
+		defer cr.MustClose()
+		crs = append(crs, cr)
+	}
+
+	// Every message must be read by exactly one consumer in the group.
+	var mu sync.Mutex
+	var msgs []Message
+	partitions := make(map[int32]int)
+	var wg sync.WaitGroup
+	deadline := time.Now().Add(30 * time.Second)
+	for i, cr := range crs {
+		wg.Add(1)
+		go func(consumerIdx int, cr *Consumer) {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				a, err := cr.Fetch(100 * time.Millisecond)
+				if err != nil {
+					t.Errorf("cannot fetch messages: %s", err)
+					return
+				}
+				if err := cr.Commit(); err != nil {
+					t.Errorf("cannot commit offsets: %s", err)
+					return
+				}
+				mu.Lock()
+				for _, m := range a {
+					if idx, ok := partitions[m.Partition]; ok && idx != consumerIdx {
+						t.Errorf("partition %d is read by multiple consumers", m.Partition)
+					}
+					partitions[m.Partition] = consumerIdx
+				}
+				msgs = append(msgs, a...)
+				n := len(msgs)
+				mu.Unlock()
+				if n >= len(values) {
+					return
+				}
+			}
+		}(i, cr)
+	}
+	wg.Wait()
+	checkTestMessages(t, msgs, values)
+}
+
+func TestConsumerSASL(t *testing.T) {
+	f := func(mechanism string) {
+		t.Helper()
+
+		c := newTestCluster(t, 1, kfake.EnableSASL(), kfake.Superuser(mechanism, "user", "pass"))
+		defer c.Close()
+		cfg := &Config{
+			Brokers:          c.ListenAddrs(),
+			SecurityProtocol: "SASL_PLAINTEXT",
+			SASLMechanism:    mechanism,
+			SASLUsername:     "user",
+			SASLPassword:     "pass",
+			FromBeginning:    true,
+		}
+		values := mustProduceTestMessages(t, cfg, 0, 3)
+		cr, err := NewConsumer(cfg, "topic", "group")
+		if err != nil {
+			t.Fatalf("cannot create consumer: %s", err)
+		}
+		defer cr.MustClose()
+		msgs := mustFetchTestMessages(t, cr, len(values))
+		checkTestMessages(t, msgs, values)
+
+		// Invalid credentials must be rejected.
+		cfgInvalid := *cfg
+		cfgInvalid.SASLPassword = "invalid"
+		cfgInvalid.RequestTimeout = time.Second
+		p, err := NewProducer(&cfgInvalid, "topic")
+		if err != nil {
+			t.Fatalf("cannot create producer: %s", err)
+		}
+		defer p.MustClose()
+		if err := p.Produce([]byte("foo"), nil); err == nil {
+			t.Fatalf("expecting non-nil error for invalid SASL credentials")
+		}
+	}
+
+	f("PLAIN")
+	f("SCRAM-SHA-256")
+	f("SCRAM-SHA-512")
+}
+
+func TestConsumerTLS(t *testing.T) {
+	caPEM, serverCert := newTestCertificate(t)
+	serverTLSConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+	}
+	c := newTestCluster(t, 1, kfake.TLS(serverTLSConfig))
+	defer c.Close()
+	cfg := &Config{
+		Brokers:          c.ListenAddrs(),
+		SecurityProtocol: "SSL",
+		TLS: promauth.TLSConfig{
+			CA: caPEM,
+		},
+		FromBeginning: true,
+	}
+	values := mustProduceTestMessages(t, cfg, 0, 3)
+	cr, err := NewConsumer(cfg, "topic", "")
+	if err != nil {
+		t.Fatalf("cannot create consumer: %s", err)
+	}
+	defer cr.MustClose()
+	msgs := mustFetchTestMessages(t, cr, len(values))
+	checkTestMessages(t, msgs, values)
+}
+
+// newTestCluster returns in-process Kafka cluster with "topic" containing the given number of partitions.
+func newTestCluster(t *testing.T, partitions int32, opts ...kfake.Opt) *kfake.Cluster {
+	t.Helper()
+	opts = append(opts, kfake.NumBrokers(1), kfake.SeedTopics(partitions, "topic"))
+	c, err := kfake.NewCluster(opts...)
+	if err != nil {
+		t.Fatalf("cannot start Kafka cluster: %s", err)
+	}
+	return c
+}
+
+// mustProduceTestMessages writes n messages to "topic" at cfg.Brokers and returns their values.
+//
+// Values for the messages are generated from the sequence number starting from start.
+func mustProduceTestMessages(t *testing.T, cfg *Config, start, n int) []string {
+	t.Helper()
+	p, err := NewProducer(cfg, "topic")
+	if err != nil {
+		t.Fatalf("cannot create producer: %s", err)
+	}
+	defer p.MustClose()
+	var values []string
+	for i := start; i < start+n; i++ {
+		value := fmt.Sprintf("message_%d", i)
+		headers := []Header{{
+			Key:   "Content-Encoding",
+			Value: []byte("snappy"),
+		}}
+		if err := p.Produce([]byte(value), headers); err != nil {
+			t.Fatalf("cannot produce message: %s", err)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// mustFetchTestMessages fetches n messages from cr.
+func mustFetchTestMessages(t *testing.T, cr *Consumer, n int) []Message {
+	t.Helper()
+	var msgs []Message
+	deadline := time.Now().Add(30 * time.Second)
+	for len(msgs) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout when fetching messages; got %d messages; want %d messages", len(msgs), n)
+		}
+		a, err := cr.Fetch(100 * time.Millisecond)
+		if err != nil {
+			t.Fatalf("cannot fetch messages: %s", err)
+		}
+		msgs = append(msgs, a...)
+	}
+	// Make sure there are no unexpected messages.
+	a, err := cr.Fetch(100 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("cannot fetch messages: %s", err)
+	}
+	return append(msgs, a...)
+}
+
+func checkTestMessages(t *testing.T, msgs []Message, valuesExpected []string) {
+	t.Helper()
+	var values []string
+	for _, m := range msgs {
+		if v := string(m.GetHeader("Content-Encoding")); v != "snappy" {
+			t.Fatalf("unexpected Content-Encoding header for message %q; got %q; want %q", m.Value, v, "snappy")
+		}
+		values = append(values, string(m.Value))
+	}
+	sort.Strings(values)
+	valuesExpected = append([]string{}, valuesExpected...)
+	sort.Strings(valuesExpected)
+	if fmt.Sprint(values) != fmt.Sprint(valuesExpected) {
+		t.Fatalf("unexpected messages\ngot\n%q\nwant\n%q", values, valuesExpected)
+	}
+}
+
+// newTestCertificate returns PEM-encoded self-signed CA certificate and server certificate signed by it.
+func newTestCertificate(t *testing.T) (string, tls.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %s", err)
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}
+	caPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE",
+		Bytes: der,
+	})
+	return string(caPEM), cert
+}
@@ -0,0 +1,88 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+// Producer writes messages to a Kafka topic.
+//
+// Producer is safe for concurrent use.
+type Producer struct {
+	c              *kgo.Client
+	requestTimeout time.Duration
+}
+
+// NewProducer returns a producer for writing messages to the given topic at Kafka brokers from cfg.
+//
+// Connections to Kafka brokers are established in background.
+// MustClose must be called when the producer is no longer needed.
+func NewProducer(cfg *Config, topic string) (*Producer, error) {
+	if topic == "" {
+		return nil, fmt.Errorf("missing Kafka topic")
+	}
+	opts, err := cfg.newClientOpts()
+	if err != nil {
+		return nil, err
+	}
+	codec, err := getCompressionCodec(cfg.Compression)
+	if err != nil {
+		return nil, err
+	}
+	requestTimeout := cfg.getRequestTimeout()
+	opts = append(opts,
+		kgo.DefaultProduceTopic(topic),
+		kgo.RequiredAcks(kgo.AllISRAcks()),
+		kgo.RecordPartitioner(kgo.RoundRobinPartitioner()),
+		kgo.ProducerBatchCompression(codec),
+		kgo.RecordDeliveryTimeout(requestTimeout),
+	)
+	if cfg.MaxMessageBytes > 0 {
+		opts = append(opts, kgo.ProducerBatchMaxBytes(int32(cfg.MaxMessageBytes)))
+	}
+	c, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create Kafka client: %w", err)
+	}
+	p := &Producer{
+		c:              c,
+		requestTimeout: requestTimeout,
+	}
+	return p, nil
+}
+
+// Produce writes a message with the given value and headers to the topic.
+//
+// Messages are spread evenly among topic partitions.
+// The message is written when it is acknowledged by all the in-sync replicas of the partition.
+// The message may be retried on error unless IsMessageRejected returns true for the error.
+func (p *Producer) Produce(value []byte, headers []Header) error {
+	r := &kgo.Record{
+		Value: value,
+	}
+	if len(headers) > 0 {
+		r.Headers = make([]kgo.RecordHeader, len(headers))
+		for i, h := range headers {
+			r.Headers[i] = kgo.RecordHeader(h)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.requestTimeout)
+	defer cancel()
+	return p.c.ProduceSync(ctx, r).FirstErr()
+}
+
+// MustClose closes connections to Kafka brokers.
+func (p *Producer) MustClose() {
+	p.c.Close()
+}
+
+// IsMessageRejected returns true if err means that Kafka brokers never accept the produced message.
+func IsMessageRejected(err error) bool {
+	return errors.Is(err, kerr.MessageTooLarge) || errors.Is(err, kerr.RecordListTooLarge) || errors.Is(err, kerr.CorruptMessage) ||
+		errors.Is(err, kerr.InvalidRecord) || errors.Is(err, kerr.InvalidTopicException)
+}
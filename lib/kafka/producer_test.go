@@ -0,0 +1,49 @@
+package kafka
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/twmb/franz-go/pkg/kerr"
+)
+
+func TestIsMessageRejected(t *testing.T) {
+	f := func(err error, resultExpected bool) {
+		t.Helper()
+
+		result := IsMessageRejected(err)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %v; got %v; want %v", err, result, resultExpected)
+		}
+	}
+
+	f(nil, false)
+	f(fmt.Errorf("some error"), false)
+	f(kerr.NotEnoughReplicas, false)
+	f(kerr.MessageTooLarge, true)
+	f(fmt.Errorf("cannot produce: %w", kerr.RecordListTooLarge), true)
+}
+
+func TestNewProducerFailure(t *testing.T) {
+	f := func(cfg *Config, topic string) {
+		t.Helper()
+
+		if _, err := NewProducer(cfg, topic); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing topic
+	f(&Config{
+		Brokers: []string{"localhost:9092"},
+	}, "")
+
+	// missing brokers
+	f(&Config{}, "topic")
+
+	// unsupported compression
+	f(&Config{
+		Brokers:     []string{"localhost:9092"},
+		Compression: "brotli",
+	}, "topic")
+}
@@ -0,0 +1,196 @@
+package mergeset
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+)
+
+// CheckTableParts verifies the integrity of all the parts for the table at the given path.
+//
+// reportCorruptedPart is called for every corrupted part.
+// Corrupted parts are moved to quarantinePath and are removed from the list of table parts if quarantinePath isn't empty,
+// so the table can be opened with the remaining parts.
+//
+// The table mustn't be opened during the call.
+//
+// The number of checked parts is returned.
+func CheckTableParts(path, quarantinePath string, reportCorruptedPart func(partPath string, err error)) (int, error) {
+	partsFile := filepath.Join(path, partsFilename)
+	partNames, err := readPartNames(partsFile, path)
+	if err != nil {
+		return 0, err
+	}
+
+	var goodPartNames, corruptedPartNames []string
+	for _, partName := range partNames {
+		partPath := filepath.Join(path, partName)
+		if err := checkPart(partPath); err != nil {
+			reportCorruptedPart(partPath, err)
+			corruptedPartNames = append(corruptedPartNames, partName)
+			continue
+		}
+		goodPartNames = append(goodPartNames, partName)
+	}
+	if len(corruptedPartNames) == 0 || quarantinePath == "" {
+		return len(partNames), nil
+	}
+
+	fs.MustMkdirIfNotExist(quarantinePath)
+	for _, partName := range corruptedPartNames {
+		partPath := filepath.Join(path, partName)
+		if !fs.IsPathExist(partPath) {
+			continue
+		}
+		dstPath := filepath.Join(quarantinePath, partName)
+		if err := os.Rename(partPath, dstPath); err != nil {
+			return len(partNames), fmt.Errorf("cannot move corrupted part %q to quarantine: %w", partPath, err)
+		}
+	}
+	fs.MustSyncPath(quarantinePath)
+	fs.MustSyncPath(path)
+
+	data, err := json.Marshal(goodPartNames)
+	if err != nil {
+		return len(partNames), fmt.Errorf("cannot marshal part names: %w", err)
+	}
+	fs.MustWriteAtomic(partsFile, data, true)
+	return len(partNames), nil
+}
+
+func readPartNames(partsFile, srcDir string) ([]string, error) {
+	if !fs.IsPathExist(partsFile) {
+		// The partsFilename is missing. This is the table created by versions previous to v1.90.0.
+		return mustReadPartNames(partsFile, srcDir), nil
+	}
+	data, err := os.ReadFile(partsFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %q: %w", partsFile, err)
+	}
+	var partNames []string
+	if err := json.Unmarshal(data, &partNames); err != nil {
+		return nil, fmt.Errorf("cannot parse %q: %w", partsFile, err)
+	}
+	return partNames, nil
+}
+
+// checkPart verifies the integrity of the file-based part at the given path.
+//
+// It verifies that metaindex, index and data blocks can be decoded, that their offsets and sizes
+// match the sizes of part files and that items are sorted across the part.
+func checkPart(path string) error {
+	var ph partHeader
+	if err := ph.readMetadata(path); err != nil {
+		return err
+	}
+
+	var sizes [3]uint64
+	for i, filename := range []string{indexFilename, itemsFilename, lensFilename} {
+		fi, err := os.Stat(filepath.Join(path, filename))
+		if err != nil {
+			return fmt.Errorf("cannot access part file: %w", err)
+		}
+		sizes[i] = uint64(fi.Size())
+	}
+	indexSize, itemsSize, lensSize := sizes[0], sizes[1], sizes[2]
+
+	metaindexPath := filepath.Join(path, metaindexFilename)
+	metaindexData, err := os.ReadFile(metaindexPath)
+	if err != nil {
+		return fmt.Errorf("cannot read metaindex: %w", err)
+	}
+	mrs, err := unmarshalMetaindexRows(nil, bytes.NewReader(metaindexData))
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal metaindex rows from %q: %w", metaindexPath, err)
+	}
+
+	indexPath := filepath.Join(path, indexFilename)
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return fmt.Errorf("cannot open index file: %w", err)
+	}
+	defer indexFile.Close()
+
+	// Verify index blocks and block headers, so the data blocks could be safely read afterwards.
+	var indexOffset, itemsOffset, lensOffset, blocksCount, itemsCount uint64
+	var packedBuf, unpackedBuf []byte
+	var bhs []blockHeader
+	for i := range mrs {
+		mr := &mrs[i]
+		if mr.indexBlockOffset != indexOffset {
+			return fmt.Errorf("invalid indexBlockOffset in metaindex row #%d; got %d; want %d", i, mr.indexBlockOffset, indexOffset)
+		}
+		if mr.indexBlockOffset+uint64(mr.indexBlockSize) > indexSize {
+			return fmt.Errorf("index block #%d at offset %d with size %d exceeds %q size %d", i, mr.indexBlockOffset, mr.indexBlockSize, indexPath, indexSize)
+		}
+		packedBuf = bytesutil.ResizeNoCopyMayOverallocate(packedBuf, int(mr.indexBlockSize))
+		if _, err := indexFile.ReadAt(packedBuf, int64(mr.indexBlockOffset)); err != nil {
+			return fmt.Errorf("cannot read index block #%d: %w", i, err)
+		}
+		unpackedBuf, err = encoding.DecompressZSTD(unpackedBuf[:0], packedBuf)
+		if err != nil {
+			return fmt.Errorf("cannot decompress index block #%d: %w", i, err)
+		}
+		bhs, err = unmarshalBlockHeadersNoCopy(bhs[:0], unpackedBuf, int(mr.blockHeadersCount))
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal block headers in the index block #%d: %w", i, err)
+		}
+		if string(bhs[0].firstItem) != string(mr.firstItem) {
+			return fmt.Errorf("unexpected firstItem in metaindex row #%d; got %X; want %X", i, mr.firstItem, bhs[0].firstItem)
+		}
+		for j := range bhs {
+			bh := &bhs[j]
+			if bh.itemsBlockOffset != itemsOffset {
+				return fmt.Errorf("invalid itemsBlockOffset in block header #%d of the index block #%d; got %d; want %d", j, i, bh.itemsBlockOffset, itemsOffset)
+			}
+			if bh.lensBlockOffset != lensOffset {
+				return fmt.Errorf("invalid lensBlockOffset in block header #%d of the index block #%d; got %d; want %d", j, i, bh.lensBlockOffset, lensOffset)
+			}
+			itemsOffset += uint64(bh.itemsBlockSize)
+			lensOffset += uint64(bh.lensBlockSize)
+			itemsCount += uint64(bh.itemsCount)
+			blocksCount++
+		}
+		indexOffset += uint64(mr.indexBlockSize)
+	}
+	if indexOffset != indexSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; index blocks occupy %d bytes", indexPath, indexSize, indexOffset)
+	}
+	if itemsOffset != itemsSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; block headers refer to %d bytes", filepath.Join(path, itemsFilename), itemsSize, itemsOffset)
+	}
+	if lensOffset != lensSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; block headers refer to %d bytes", filepath.Join(path, lensFilename), lensSize, lensOffset)
+	}
+	if blocksCount != ph.blocksCount {
+		return fmt.Errorf("unexpected number of blocks; got %d; want %d", blocksCount, ph.blocksCount)
+	}
+	if itemsCount != ph.itemsCount {
+		return fmt.Errorf("unexpected number of items; got %d; want %d", itemsCount, ph.itemsCount)
+	}
+
+	// Verify data blocks.
+	bsr := getBlockStreamReader()
+	defer putBlockStreamReader(bsr)
+	bsr.MustInitFromFilePart(path)
+	var prevItem []byte
+	for bsr.Next() {
+		b := &bsr.Block
+		firstItem := b.items[0].Bytes(b.data)
+		if string(firstItem) < string(prevItem) {
+			return fmt.Errorf("items aren't sorted across blocks; the first item %X in the block #%d is smaller than the last item %X in the previous block",
+				firstItem, bsr.blocksRead, prevItem)
+		}
+		prevItem = append(prevItem[:0], b.items[len(b.items)-1].Bytes(b.data)...)
+	}
+	if err := bsr.Error(); err != nil {
+		return fmt.Errorf("cannot read data blocks: %w", err)
+	}
+	return nil
+}
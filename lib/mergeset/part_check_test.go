@@ -0,0 +1,96 @@
+package mergeset
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCheckTableParts(t *testing.T) {
+	const path = "TestCheckTableParts"
+	const quarantinePath = "TestCheckTableParts-quarantine"
+	for _, p := range []string{path, quarantinePath} {
+		if err := os.RemoveAll(p); err != nil {
+			t.Fatalf("cannot remove %q: %s", p, err)
+		}
+	}
+	defer func() {
+		_ = os.RemoveAll(path)
+		_ = os.RemoveAll(quarantinePath)
+	}()
+
+	// Create a table with two file parts.
+	var isReadOnly atomic.Bool
+	for i := 0; i < 2; i++ {
+		tb := MustOpenTable(path, nil, nil, &isReadOnly)
+		for j := 0; j < 1000; j++ {
+			tb.AddItems([][]byte{[]byte(fmt.Sprintf("item_%d_%d", i, j))})
+		}
+		tb.MustClose()
+	}
+
+	checkParts := func(quarantinePath string) []string {
+		t.Helper()
+		var corruptedParts []string
+		n, err := CheckTableParts(path, quarantinePath, func(partPath string, _ error) {
+			corruptedParts = append(corruptedParts, partPath)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != 2 {
+			t.Fatalf("unexpected number of checked parts; got %d; want %d", n, 2)
+		}
+		return corruptedParts
+	}
+	if corruptedParts := checkParts(""); len(corruptedParts) != 0 {
+		t.Fatalf("unexpected corrupted parts: %q", corruptedParts)
+	}
+
+	// Corrupt the first part by truncating its items file.
+	data, err := os.ReadFile(filepath.Join(path, partsFilename))
+	if err != nil {
+		t.Fatalf("cannot read parts file: %s", err)
+	}
+	var partNames []string
+	if err := json.Unmarshal(data, &partNames); err != nil {
+		t.Fatalf("cannot parse parts file: %s", err)
+	}
+	corruptedPartPath := filepath.Join(path, partNames[0])
+	itemsPath := filepath.Join(corruptedPartPath, itemsFilename)
+	fi, err := os.Stat(itemsPath)
+	if err != nil {
+		t.Fatalf("cannot stat items file: %s", err)
+	}
+	if err := os.Truncate(itemsPath, fi.Size()-1); err != nil {
+		t.Fatalf("cannot truncate items file: %s", err)
+	}
+
+	// The corrupted part must be reported without quarantine.
+	if corruptedParts := checkParts(""); len(corruptedParts) != 1 || corruptedParts[0] != corruptedPartPath {
+		t.Fatalf("unexpected corrupted parts; got %q; want %q", corruptedParts, []string{corruptedPartPath})
+	}
+	if _, err := os.Stat(corruptedPartPath); err != nil {
+		t.Fatalf("the corrupted part mustn't be moved without quarantine path: %s", err)
+	}
+
+	// The corrupted part must be moved to quarantine.
+	if corruptedParts := checkParts(quarantinePath); len(corruptedParts) != 1 {
+		t.Fatalf("unexpected corrupted parts; got %q; want 1 part", corruptedParts)
+	}
+	if _, err := os.Stat(filepath.Join(quarantinePath, partNames[0])); err != nil {
+		t.Fatalf("cannot find the corrupted part in quarantine: %s", err)
+	}
+
+	// The table must be opened with the remaining part.
+	tb := MustOpenTable(path, nil, nil, &isReadOnly)
+	var m TableMetrics
+	tb.UpdateMetrics(&m)
+	tb.MustClose()
+	if n := m.TotalItemsCount(); n != 1000 {
+		t.Fatalf("unexpected number of items after quarantine; got %d; want %d", n, 1000)
+	}
+}
@@ -79,36 +79,43 @@ func (ph *partHeader) CopyFrom(src *partHeader) {
 }
 
 func (ph *partHeader) MustReadMetadata(partPath string) {
+	if err := ph.readMetadata(partPath); err != nil {
+		logger.Panicf("FATAL: %s", err)
+	}
+}
+
+func (ph *partHeader) readMetadata(partPath string) error {
 	ph.Reset()
 
 	// Read ph fields from metadata.
 	metadataPath := filepath.Join(partPath, metadataFilename)
 	metadata, err := os.ReadFile(metadataPath)
 	if err != nil {
-		logger.Panicf("FATAL: cannot read %q: %s", metadataPath, err)
+		return fmt.Errorf("cannot read %q: %w", metadataPath, err)
 	}
 
 	var phj partHeaderJSON
 	if err := json.Unmarshal(metadata, &phj); err != nil {
-		logger.Panicf("FATAL: cannot parse %q: %s", metadataPath, err)
+		return fmt.Errorf("cannot parse %q: %w", metadataPath, err)
 	}
 
 	if phj.ItemsCount <= 0 {
-		logger.Panicf("FATAL: part %q cannot contain zero items", partPath)
+		return fmt.Errorf("part %q cannot contain zero items", partPath)
 	}
 	ph.itemsCount = phj.ItemsCount
 
 	if phj.BlocksCount <= 0 {
-		logger.Panicf("FATAL: part %q cannot contain zero blocks", partPath)
+		return fmt.Errorf("part %q cannot contain zero blocks", partPath)
 	}
 	if phj.BlocksCount > phj.ItemsCount {
-		logger.Panicf("FATAL: the number of blocks cannot exceed the number of items in the part %q; got blocksCount=%d, itemsCount=%d",
+		return fmt.Errorf("the number of blocks cannot exceed the number of items in the part %q; got blocksCount=%d, itemsCount=%d",
 			partPath, phj.BlocksCount, phj.ItemsCount)
 	}
 	ph.blocksCount = phj.BlocksCount
 
 	ph.firstItem = append(ph.firstItem[:0], phj.FirstItem...)
 	ph.lastItem = append(ph.lastItem[:0], phj.LastItem...)
+	return nil
 }
 
 func (ph *partHeader) MustWriteMetadata(partPath string) {
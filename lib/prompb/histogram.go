@@ -0,0 +1,374 @@
+package prompb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/decimal"
+)
+
+// Histogram is a native Prometheus histogram sample.
+//
+// See https://prometheus.io/docs/specs/native_histograms/
+type Histogram struct {
+	// Count is the total number of observations.
+	Count float64
+
+	// Sum is the sum of observations.
+	Sum float64
+
+	// Schema defines bucket boundaries.
+	//
+	// Bucket boundaries are exponential for schemas in the range [-4 ... 8]. Bucket boundaries are defined by CustomValues for the schema -53.
+	Schema int32
+
+	// ZeroThreshold is the width of the zero bucket.
+	ZeroThreshold float64
+
+	// ZeroCount is the number of observations in the zero bucket.
+	ZeroCount float64
+
+	// NegativeSpans contains spans for NegativeBuckets.
+	NegativeSpans []BucketSpan
+
+	// NegativeBuckets contains absolute counts for buckets with negative observations.
+	NegativeBuckets []float64
+
+	// PositiveSpans contains spans for PositiveBuckets.
+	PositiveSpans []BucketSpan
+
+	// PositiveBuckets contains absolute counts for buckets with positive observations.
+	PositiveBuckets []float64
+
+	// CustomValues contains upper bounds for buckets if Schema equals to customBucketsSchema.
+	CustomValues []float64
+
+	// Timestamp is unix timestamp for the histogram in milliseconds.
+	Timestamp int64
+}
+
+// BucketSpan defines a span of consecutive buckets in Histogram.
+type BucketSpan struct {
+	// Offset is the gap between the previous span and the current span.
+	//
+	// Offset for the first span is the index of the first bucket.
+	Offset int32
+
+	// Length is the number of consecutive buckets in the span.
+	Length uint32
+}
+
+// customBucketsSchema is the schema for histograms with custom bucket boundaries.
+const customBucketsSchema = -53
+
+func (h *Histogram) unmarshalProtobuf(src []byte, spansPool []BucketSpan, floatsPool []float64, deltasBuf []int64) ([]BucketSpan, []float64, []int64, error) {
+	// message Histogram {
+	//   oneof count {
+	//     uint64 count_int   = 1;
+	//     double count_float = 2;
+	//   }
+	//   double sum            = 3;
+	//   sint32 schema         = 4;
+	//   double zero_threshold = 5;
+	//   oneof zero_count {
+	//     uint64 zero_count_int   = 6;
+	//     double zero_count_float = 7;
+	//   }
+	//   repeated BucketSpan negative_spans  = 8;
+	//   repeated sint64     negative_deltas = 9;
+	//   repeated double     negative_counts = 10;
+	//   repeated BucketSpan positive_spans  = 11;
+	//   repeated sint64     positive_deltas = 12;
+	//   repeated double     positive_counts = 13;
+	//   ResetHint           reset_hint      = 14;
+	//   int64               timestamp       = 15;
+	//   repeated double     custom_values   = 16;
+	// }
+	var negativeSpans, positiveSpans []BucketSpan
+	var negativeBuckets, positiveBuckets, customValues []float64
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return spansPool, floatsPool, deltasBuf, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			var n uint64
+			n, ok = fc.Uint64()
+			h.Count = float64(n)
+		case 2:
+			h.Count, ok = fc.Double()
+		case 3:
+			h.Sum, ok = fc.Double()
+		case 4:
+			h.Schema, ok = fc.Sint32()
+		case 5:
+			h.ZeroThreshold, ok = fc.Double()
+		case 6:
+			var n uint64
+			n, ok = fc.Uint64()
+			h.ZeroCount = float64(n)
+		case 7:
+			h.ZeroCount, ok = fc.Double()
+		case 8, 11:
+			var span BucketSpan
+			span, ok = unmarshalBucketSpan(&fc)
+			if fc.FieldNum == 8 {
+				negativeSpans = append(negativeSpans, span)
+			} else {
+				positiveSpans = append(positiveSpans, span)
+			}
+		case 9:
+			deltasLen := len(deltasBuf)
+			deltasBuf, ok = fc.UnpackSint64s(deltasBuf)
+			negativeBuckets = AppendBucketsFromDeltas(negativeBuckets, deltasBuf[deltasLen:])
+		case 10:
+			negativeBuckets, ok = fc.UnpackDoubles(negativeBuckets)
+		case 12:
+			deltasLen := len(deltasBuf)
+			deltasBuf, ok = fc.UnpackSint64s(deltasBuf)
+			positiveBuckets = AppendBucketsFromDeltas(positiveBuckets, deltasBuf[deltasLen:])
+		case 13:
+			positiveBuckets, ok = fc.UnpackDoubles(positiveBuckets)
+		case 15:
+			h.Timestamp, ok = fc.Int64()
+		case 16:
+			customValues, ok = fc.UnpackDoubles(customValues)
+		default:
+			ok = true
+		}
+		if !ok {
+			return spansPool, floatsPool, deltasBuf, fmt.Errorf("cannot read histogram field #%d", fc.FieldNum)
+		}
+	}
+
+	// Copy the parsed data to pools, so it can be re-used for subsequent requests.
+	spansPoolLen := len(spansPool)
+	spansPool = append(spansPool, negativeSpans...)
+	spansPool = append(spansPool, positiveSpans...)
+	h.NegativeSpans = spansPool[spansPoolLen : spansPoolLen+len(negativeSpans)]
+	h.PositiveSpans = spansPool[spansPoolLen+len(negativeSpans):]
+
+	floatsPoolLen := len(floatsPool)
+	floatsPool = append(floatsPool, negativeBuckets...)
+	floatsPool = append(floatsPool, positiveBuckets...)
+	floatsPool = append(floatsPool, customValues...)
+	a := floatsPool[floatsPoolLen:]
+	h.NegativeBuckets = a[:len(negativeBuckets)]
+	h.PositiveBuckets = a[len(negativeBuckets) : len(negativeBuckets)+len(positiveBuckets)]
+	h.CustomValues = a[len(negativeBuckets)+len(positiveBuckets):]
+
+	return spansPool, floatsPool, deltasBuf, nil
+}
+
+// AppendBucketsFromDeltas converts delta-encoded bucket counts to absolute counts and appends them to dst.
+//
+// The first delta in deltas is relative to the last bucket in dst.
+func AppendBucketsFromDeltas(dst []float64, deltas []int64) []float64 {
+	var count int64
+	if len(dst) > 0 {
+		count = int64(dst[len(dst)-1])
+	}
+	for _, delta := range deltas {
+		count += delta
+		dst = append(dst, float64(count))
+	}
+	return dst
+}
+
+func unmarshalBucketSpan(fc *easyproto.FieldContext) (BucketSpan, bool) {
+	// message BucketSpan {
+	//   sint32 offset = 1;
+	//   uint32 length = 2;
+	// }
+	var span BucketSpan
+	src, ok := fc.MessageData()
+	if !ok {
+		return span, false
+	}
+	var fcSpan easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fcSpan.NextField(src)
+		if err != nil {
+			return span, false
+		}
+		switch fcSpan.FieldNum {
+		case 1:
+			span.Offset, ok = fcSpan.Sint32()
+		case 2:
+			span.Length, ok = fcSpan.Uint32()
+		}
+		if !ok {
+			return span, false
+		}
+	}
+	return span, true
+}
+
+// ConvertHistogramsToBuckets converts native histograms in wr.Timeseries to `<name>_bucket` time series with `vmrange` labels,
+// `<name>_count` and `<name>_sum` time series and appends them to wr.Timeseries.
+//
+// The `vmrange` buckets can be queried with histogram_quantile(), histogram_count(), histogram_sum() and other histogram_* functions.
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+func (wr *WriteRequest) ConvertHistogramsToBuckets() {
+	tss := wr.Timeseries
+	tssLen := len(tss)
+	for i := 0; i < tssLen; i++ {
+		if len(tss[i].Histograms) == 0 {
+			continue
+		}
+		// Copy labels, since tss may be re-allocated below.
+		labels := tss[i].Labels
+		for j := range tss[i].Histograms {
+			h := &tss[i].Histograms[j]
+			tss = wr.appendHistogramSeries(tss, labels, h)
+		}
+	}
+	wr.Timeseries = tss
+}
+
+func (wr *WriteRequest) appendHistogramSeries(tss []TimeSeries, labels []Label, h *Histogram) []TimeSeries {
+	metricName := ""
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			metricName = label.Value
+			break
+		}
+	}
+
+	if decimal.IsStaleNaN(h.Sum) {
+		// Propagate staleness marker to `_count` and `_sum` series.
+		tss = wr.appendHistogramSample(tss, labels, metricName, "_count", "", "", h.Timestamp, decimal.StaleNaN)
+		tss = wr.appendHistogramSample(tss, labels, metricName, "_sum", "", "", h.Timestamp, decimal.StaleNaN)
+		return tss
+	}
+
+	tss = wr.appendHistogramSample(tss, labels, metricName, "_count", "", "", h.Timestamp, h.Count)
+	tss = wr.appendHistogramSample(tss, labels, metricName, "_sum", "", "", h.Timestamp, h.Sum)
+	h.ForEachBucket(func(lower, upper, count float64) {
+		vmrange := wr.formatVMRange(lower, upper)
+		tss = wr.appendHistogramSample(tss, labels, metricName, "_bucket", "vmrange", vmrange, h.Timestamp, count)
+	})
+	return tss
+}
+
+// ForEachBucket calls f for each bucket in h with (lower, upper] bucket bounds and the number of observations in the bucket.
+//
+// The zero bucket is passed to f with (-ZeroThreshold, ZeroThreshold] bounds.
+// Buckets with unsupported bounds are skipped.
+func (h *Histogram) ForEachBucket(f func(lower, upper, count float64)) {
+	if h.ZeroCount > 0 || h.ZeroThreshold > 0 {
+		f(-h.ZeroThreshold, h.ZeroThreshold, h.ZeroCount)
+	}
+	h.forEachSpanBucket(h.PositiveSpans, h.PositiveBuckets, false, f)
+	h.forEachSpanBucket(h.NegativeSpans, h.NegativeBuckets, true, f)
+}
+
+func (h *Histogram) forEachSpanBucket(spans []BucketSpan, buckets []float64, isNegative bool, f func(lower, upper, count float64)) {
+	bucketIdx := int32(0)
+	i := 0
+	for _, span := range spans {
+		bucketIdx += span.Offset
+		for j := uint32(0); j < span.Length; j++ {
+			if i >= len(buckets) {
+				// Invalid histogram - the number of buckets doesn't match spans.
+				return
+			}
+			count := buckets[i]
+			i++
+			lower, upper, ok := h.getBucketBounds(bucketIdx)
+			bucketIdx++
+			if !ok {
+				continue
+			}
+			if isNegative {
+				lower, upper = -upper, -lower
+			}
+			f(lower, upper, count)
+		}
+	}
+}
+
+// getBucketBounds returns (lower, upper] bounds for the bucket with the given idx.
+func (h *Histogram) getBucketBounds(idx int32) (float64, float64, bool) {
+	if h.Schema == customBucketsSchema {
+		if idx < 0 || int(idx) > len(h.CustomValues) {
+			return 0, 0, false
+		}
+		lower := math.Inf(-1)
+		if idx > 0 {
+			lower = h.CustomValues[idx-1]
+		}
+		upper := math.Inf(1)
+		if int(idx) < len(h.CustomValues) {
+			upper = h.CustomValues[idx]
+		}
+		return lower, upper, true
+	}
+	if h.Schema < -4 || h.Schema > 8 {
+		return 0, 0, false
+	}
+	return getExponentialBucketBound(idx-1, h.Schema), getExponentialBucketBound(idx, h.Schema), true
+}
+
+// getExponentialBucketBound returns the upper bound for the bucket with the given idx for the given schema.
+//
+// The upper bound equals to base^idx, where base = 2^(2^-schema).
+func getExponentialBucketBound(idx, schema int32) float64 {
+	if schema <= 0 {
+		return math.Ldexp(1, int(idx)<<(-schema))
+	}
+	return math.Exp2(float64(idx) / float64(int32(1)<<schema))
+}
+
+func (wr *WriteRequest) formatVMRange(lower, upper float64) string {
+	bufLen := len(wr.histogramBuf)
+	wr.histogramBuf = strconv.AppendFloat(wr.histogramBuf, lower, 'g', -1, 64)
+	wr.histogramBuf = append(wr.histogramBuf, "..."...)
+	wr.histogramBuf = strconv.AppendFloat(wr.histogramBuf, upper, 'g', -1, 64)
+	return bytesutil.ToUnsafeString(wr.histogramBuf[bufLen:])
+}
+
+func (wr *WriteRequest) appendHistogramSample(tss []TimeSeries, labels []Label, metricName, suffix, extraLabelName, extraLabelValue string, timestamp int64, value float64) []TimeSeries {
+	bufLen := len(wr.histogramBuf)
+	wr.histogramBuf = append(wr.histogramBuf, metricName...)
+	wr.histogramBuf = append(wr.histogramBuf, suffix...)
+	name := bytesutil.ToUnsafeString(wr.histogramBuf[bufLen:])
+
+	labelsPoolLen := len(wr.labelsPool)
+	wr.labelsPool = append(wr.labelsPool, Label{
+		Name:  "__name__",
+		Value: name,
+	})
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			continue
+		}
+		wr.labelsPool = append(wr.labelsPool, label)
+	}
+	if extraLabelName != "" {
+		wr.labelsPool = append(wr.labelsPool, Label{
+			Name:  extraLabelName,
+			Value: extraLabelValue,
+		})
+	}
+
+	samplesPoolLen := len(wr.samplesPool)
+	wr.samplesPool = append(wr.samplesPool, Sample{
+		Value:     value,
+		Timestamp: timestamp,
+	})
+
+	return append(tss, TimeSeries{
+		Labels:  wr.labelsPool[labelsPoolLen:],
+		Samples: wr.samplesPool[samplesPoolLen:],
+	})
+}
@@ -0,0 +1,116 @@
+package prompb
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+func TestGetExponentialBucketBound(t *testing.T) {
+	f := func(idx, schema int32, boundExpected float64) {
+		t.Helper()
+		bound := getExponentialBucketBound(idx, schema)
+		if math.Abs(bound-boundExpected) > 1e-12 {
+			t.Fatalf("unexpected bound for idx=%d, schema=%d; got %v; want %v", idx, schema, bound, boundExpected)
+		}
+	}
+	f(0, 0, 1)
+	f(1, 0, 2)
+	f(-1, 0, 0.5)
+	f(3, -1, 64)
+	f(-2, -2, 1.0/256)
+	f(1, 1, math.Sqrt2)
+	f(2, 1, 2)
+	f(1, 3, math.Pow(2, 1.0/8))
+}
+
+func TestWriteRequestConvertHistogramsToBuckets(t *testing.T) {
+	f := func(data []byte, resultExpected string) {
+		t.Helper()
+		var wr WriteRequest
+		if err := wr.UnmarshalProtobuf(data); err != nil {
+			t.Fatalf("cannot unmarshal protobuf: %s", err)
+		}
+		wr.ConvertHistogramsToBuckets()
+		var a []string
+		for _, ts := range wr.Timeseries {
+			if len(ts.Samples) == 0 {
+				continue
+			}
+			var labels []string
+			for _, label := range ts.Labels {
+				labels = append(labels, fmt.Sprintf("%s=%q", label.Name, label.Value))
+			}
+			for _, sample := range ts.Samples {
+				a = append(a, fmt.Sprintf("{%s} %v %d", strings.Join(labels, ","), sample.Value, sample.Timestamp))
+			}
+		}
+		result := strings.Join(a, "\n")
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	marshalWriteRequest := func(fn func(mm *easyproto.MessageMarshaler)) []byte {
+		var m easyproto.Marshaler
+		mm := m.MessageMarshaler()
+		tsm := mm.AppendMessage(1)
+		lm := tsm.AppendMessage(1)
+		lm.AppendString(1, "__name__")
+		lm.AppendString(2, "foo")
+		lm = tsm.AppendMessage(1)
+		lm.AppendString(1, "job")
+		lm.AppendString(2, "bar")
+		fn(tsm.AppendMessage(4))
+		return m.Marshal(nil)
+	}
+
+	// integer histogram with exponential buckets
+	data := marshalWriteRequest(func(hm *easyproto.MessageMarshaler) {
+		hm.AppendUint64(1, 8)
+		hm.AppendDouble(3, 10.5)
+		hm.AppendSint32(4, 0)
+		hm.AppendDouble(5, 0.001)
+		hm.AppendUint64(6, 1)
+		sm := hm.AppendMessage(8)
+		sm.AppendSint32(1, 1)
+		sm.AppendUint32(2, 1)
+		hm.AppendSint64s(9, []int64{1})
+		sm = hm.AppendMessage(11)
+		sm.AppendSint32(1, 0)
+		sm.AppendUint32(2, 2)
+		sm = hm.AppendMessage(11)
+		sm.AppendSint32(1, 1)
+		sm.AppendUint32(2, 1)
+		hm.AppendSint64s(12, []int64{2, 1, -2})
+		hm.AppendInt64(15, 123)
+	})
+	f(data, `{__name__="foo_count",job="bar"} 8 123
+{__name__="foo_sum",job="bar"} 10.5 123
+{__name__="foo_bucket",job="bar",vmrange="-0.001...0.001"} 1 123
+{__name__="foo_bucket",job="bar",vmrange="0.5...1"} 2 123
+{__name__="foo_bucket",job="bar",vmrange="1...2"} 3 123
+{__name__="foo_bucket",job="bar",vmrange="4...8"} 1 123
+{__name__="foo_bucket",job="bar",vmrange="-2...-1"} 1 123`)
+
+	// float histogram with custom buckets
+	data = marshalWriteRequest(func(hm *easyproto.MessageMarshaler) {
+		hm.AppendDouble(2, 6)
+		hm.AppendDouble(3, 20)
+		hm.AppendSint32(4, customBucketsSchema)
+		sm := hm.AppendMessage(11)
+		sm.AppendSint32(1, 0)
+		sm.AppendUint32(2, 3)
+		hm.AppendDoubles(13, []float64{1, 2, 3})
+		hm.AppendInt64(15, 456)
+		hm.AppendDoubles(16, []float64{1, 5})
+	})
+	f(data, `{__name__="foo_count",job="bar"} 6 456
+{__name__="foo_sum",job="bar"} 20 456
+{__name__="foo_bucket",job="bar",vmrange="-Inf...1"} 1 456
+{__name__="foo_bucket",job="bar",vmrange="1...5"} 2 456
+{__name__="foo_bucket",job="bar",vmrange="5...+Inf"} 3 456`)
+}
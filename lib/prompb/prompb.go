@@ -11,8 +11,55 @@ type WriteRequest struct {
 	// Timeseries is a list of time series in the given WriteRequest
 	Timeseries []TimeSeries
 
+	// Metadata is a list of metric metadata in the given WriteRequest
+	Metadata []MetricMetadata
+
 	labelsPool  []Label
 	samplesPool []Sample
+
+	hp histogramsPool
+	ep exemplarsPool
+
+	// histogramBuf holds label values for time series obtained via ConvertHistogramsToBuckets.
+	histogramBuf []byte
+
+	// symbolsPool holds symbols for Remote Write 2.0 request obtained via UnmarshalProtobufV2.
+	symbolsPool []string
+
+	// refsPool is a buffer for labels refs in Remote Write 2.0 request.
+	refsPool []uint32
+}
+
+// histogramsPool holds pools for native histograms.
+type histogramsPool struct {
+	histograms []Histogram
+	spans      []BucketSpan
+	floats     []float64
+	deltas     []int64
+}
+
+func (hp *histogramsPool) reset() {
+	histograms := hp.histograms
+	for i := range histograms {
+		histograms[i] = Histogram{}
+	}
+	hp.histograms = histograms[:0]
+	hp.spans = hp.spans[:0]
+	hp.floats = hp.floats[:0]
+	hp.deltas = hp.deltas[:0]
+}
+
+// exemplarsPool holds pools for exemplars.
+type exemplarsPool struct {
+	exemplars []Exemplar
+	labels    []Label
+}
+
+func (ep *exemplarsPool) reset() {
+	clear(ep.exemplars)
+	ep.exemplars = ep.exemplars[:0]
+	clear(ep.labels)
+	ep.labels = ep.labels[:0]
 }
 
 // Reset resets wr for subsequent re-use.
@@ -23,6 +70,9 @@ func (wr *WriteRequest) Reset() {
 	}
 	wr.Timeseries = tss[:0]
 
+	clear(wr.Metadata)
+	wr.Metadata = wr.Metadata[:0]
+
 	labelsPool := wr.labelsPool
 	for i := range labelsPool {
 		labelsPool[i] = Label{}
@@ -34,6 +84,14 @@ func (wr *WriteRequest) Reset() {
 		samplesPool[i] = Sample{}
 	}
 	wr.samplesPool = samplesPool[:0]
+
+	wr.hp.reset()
+	wr.ep.reset()
+	wr.histogramBuf = wr.histogramBuf[:0]
+
+	clear(wr.symbolsPool)
+	wr.symbolsPool = wr.symbolsPool[:0]
+	wr.refsPool = wr.refsPool[:0]
 }
 
 // TimeSeries is a timeseries.
@@ -43,6 +101,46 @@ type TimeSeries struct {
 
 	// Samples is a list of samples for the given TimeSeries
 	Samples []Sample
+
+	// Exemplars is a list of exemplars for the given TimeSeries
+	Exemplars []Exemplar
+
+	// Histograms is a list of native histogram samples for the given TimeSeries.
+	//
+	// Use WriteRequest.ConvertHistogramsToBuckets for converting them to ordinary samples.
+	Histograms []Histogram
+
+	// CreatedTimestamp is unix timestamp in milliseconds when the counter, histogram or summary was created.
+	//
+	// It is set only for Remote Write 2.0 requests. It is zero if the timestamp is unknown.
+	CreatedTimestamp int64
+}
+
+// Exemplar is an exemplar for the sample, such as trace_id for the request with the sampled latency.
+type Exemplar struct {
+	// Labels is a list of exemplar labels such as trace_id
+	Labels []Label
+
+	// Value is exemplar value.
+	Value float64
+
+	// Timestamp is unix timestamp for the exemplar in milliseconds.
+	Timestamp int64
+}
+
+// MetricMetadata is metadata for the metric family.
+type MetricMetadata struct {
+	// Type is the metric type. See prompbmarshal.MetricMetadataType for possible values.
+	Type uint32
+
+	// MetricFamilyName is the name of the metric family, such as http_requests_total.
+	MetricFamilyName string
+
+	// Help is the description for the metric family.
+	Help string
+
+	// Unit is the unit for the metric family.
+	Unit string
 }
 
 // Sample is a timeseries sample.
@@ -71,8 +169,10 @@ func (wr *WriteRequest) UnmarshalProtobuf(src []byte) (err error) {
 
 	// message WriteRequest {
 	//    repeated TimeSeries timeseries = 1;
+	//    repeated MetricMetadata metadata = 3;
 	// }
 	tss := wr.Timeseries
+	mms := wr.Metadata
 	labelsPool := wr.labelsPool
 	samplesPool := wr.samplesPool
 	var fc easyproto.FieldContext
@@ -93,25 +193,44 @@ func (wr *WriteRequest) UnmarshalProtobuf(src []byte) (err error) {
 				tss = append(tss, TimeSeries{})
 			}
 			ts := &tss[len(tss)-1]
-			labelsPool, samplesPool, err = ts.unmarshalProtobuf(data, labelsPool, samplesPool)
+			labelsPool, samplesPool, err = ts.unmarshalProtobuf(data, labelsPool, samplesPool, &wr.hp, &wr.ep)
 			if err != nil {
 				return fmt.Errorf("cannot unmarshal timeseries: %w", err)
 			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return fmt.Errorf("cannot read metadata data")
+			}
+			if len(mms) < cap(mms) {
+				mms = mms[:len(mms)+1]
+			} else {
+				mms = append(mms, MetricMetadata{})
+			}
+			mm := &mms[len(mms)-1]
+			if err := mm.unmarshalProtobuf(data); err != nil {
+				return fmt.Errorf("cannot unmarshal metadata: %w", err)
+			}
 		}
 	}
 	wr.Timeseries = tss
+	wr.Metadata = mms
 	wr.labelsPool = labelsPool
 	wr.samplesPool = samplesPool
 	return nil
 }
 
-func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesPool []Sample) ([]Label, []Sample, error) {
+func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesPool []Sample, hp *histogramsPool, ep *exemplarsPool) ([]Label, []Sample, error) {
 	// message TimeSeries {
-	//   repeated Label labels   = 1;
-	//   repeated Sample samples = 2;
+	//   repeated Label labels         = 1;
+	//   repeated Sample samples       = 2;
+	//   repeated Exemplar exemplars   = 3;
+	//   repeated Histogram histograms = 4;
 	// }
 	labelsPoolLen := len(labelsPool)
 	samplesPoolLen := len(samplesPool)
+	exemplarsPoolLen := len(ep.exemplars)
+	histogramsPoolLen := len(hp.histograms)
 	var fc easyproto.FieldContext
 	for len(src) > 0 {
 		var err error
@@ -148,13 +267,135 @@ func (ts *TimeSeries) unmarshalProtobuf(src []byte, labelsPool []Label, samplesP
 			if err := sample.unmarshalProtobuf(data); err != nil {
 				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal sample: %w", err)
 			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the exemplar data")
+			}
+			if len(ep.exemplars) < cap(ep.exemplars) {
+				ep.exemplars = ep.exemplars[:len(ep.exemplars)+1]
+			} else {
+				ep.exemplars = append(ep.exemplars, Exemplar{})
+			}
+			e := &ep.exemplars[len(ep.exemplars)-1]
+			ep.labels, err = e.unmarshalProtobuf(data, ep.labels)
+			if err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal exemplar: %w", err)
+			}
+		case 4:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the histogram data")
+			}
+			if len(hp.histograms) < cap(hp.histograms) {
+				hp.histograms = hp.histograms[:len(hp.histograms)+1]
+			} else {
+				hp.histograms = append(hp.histograms, Histogram{})
+			}
+			h := &hp.histograms[len(hp.histograms)-1]
+			hp.spans, hp.floats, hp.deltas, err = h.unmarshalProtobuf(data, hp.spans, hp.floats, hp.deltas[:0])
+			if err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal histogram: %w", err)
+			}
 		}
 	}
 	ts.Labels = labelsPool[labelsPoolLen:]
 	ts.Samples = samplesPool[samplesPoolLen:]
+	ts.Exemplars = ep.exemplars[exemplarsPoolLen:]
+	ts.Histograms = hp.histograms[histogramsPoolLen:]
 	return labelsPool, samplesPool, nil
 }
 
+func (e *Exemplar) unmarshalProtobuf(src []byte, labelsPool []Label) ([]Label, error) {
+	// message Exemplar {
+	//   repeated Label labels = 1;
+	//   double value          = 2;
+	//   int64 timestamp       = 3;
+	// }
+	labelsPoolLen := len(labelsPool)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return labelsPool, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read label data")
+			}
+			if len(labelsPool) < cap(labelsPool) {
+				labelsPool = labelsPool[:len(labelsPool)+1]
+			} else {
+				labelsPool = append(labelsPool, Label{})
+			}
+			label := &labelsPool[len(labelsPool)-1]
+			if err := label.unmarshalProtobuf(data); err != nil {
+				return labelsPool, fmt.Errorf("cannot unmarshal label: %w", err)
+			}
+		case 2:
+			value, ok := fc.Double()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar value")
+			}
+			e.Value = value
+		case 3:
+			timestamp, ok := fc.Int64()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar timestamp")
+			}
+			e.Timestamp = timestamp
+		}
+	}
+	e.Labels = labelsPool[labelsPoolLen:]
+	return labelsPool, nil
+}
+
+func (mm *MetricMetadata) unmarshalProtobuf(src []byte) (err error) {
+	// message MetricMetadata {
+	//   MetricType type           = 1;
+	//   string metric_family_name = 2;
+	//   string help               = 4;
+	//   string unit               = 5;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			typ, ok := fc.Uint32()
+			if !ok {
+				return fmt.Errorf("cannot read metric type")
+			}
+			mm.Type = typ
+		case 2:
+			name, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read metric family name")
+			}
+			mm.MetricFamilyName = name
+		case 4:
+			help, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read metric help")
+			}
+			mm.Help = help
+		case 5:
+			unit, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read metric unit")
+			}
+			mm.Unit = unit
+		}
+	}
+	return nil
+}
+
 func (lbl *Label) unmarshalProtobuf(src []byte) (err error) {
 	// message Label {
 	//   string name  = 1;
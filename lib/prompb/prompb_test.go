@@ -36,9 +36,33 @@ func TestWriteRequestUnmarshalProtobuf(t *testing.T) {
 					Timestamp: sample.Timestamp,
 				})
 			}
+			var exemplars []prompbmarshal.Exemplar
+			for _, exemplar := range ts.Exemplars {
+				var exemplarLabels []prompbmarshal.Label
+				for _, label := range exemplar.Labels {
+					exemplarLabels = append(exemplarLabels, prompbmarshal.Label{
+						Name:  label.Name,
+						Value: label.Value,
+					})
+				}
+				exemplars = append(exemplars, prompbmarshal.Exemplar{
+					Labels:    exemplarLabels,
+					Value:     exemplar.Value,
+					Timestamp: exemplar.Timestamp,
+				})
+			}
 			wrm.Timeseries = append(wrm.Timeseries, prompbmarshal.TimeSeries{
-				Labels:  labels,
-				Samples: samples,
+				Labels:    labels,
+				Samples:   samples,
+				Exemplars: exemplars,
+			})
+		}
+		for _, mm := range wr.Metadata {
+			wrm.Metadata = append(wrm.Metadata, prompbmarshal.MetricMetadata{
+				Type:             prompbmarshal.MetricMetadataType(mm.Type),
+				MetricFamilyName: mm.MetricFamilyName,
+				Help:             mm.Help,
+				Unit:             mm.Unit,
 			})
 		}
 		dataResult := wrm.MarshalProtobuf(nil)
@@ -170,4 +194,88 @@ func TestWriteRequestUnmarshalProtobuf(t *testing.T) {
 	}
 	data = wrm.MarshalProtobuf(data[:0])
 	f(data)
+
+	wrm.Reset()
+	wrm.Timeseries = []prompbmarshal.TimeSeries{
+		{
+			Labels: []prompbmarshal.Label{
+				{
+					Name:  "__name__",
+					Value: "http_request_duration_seconds_bucket",
+				},
+				{
+					Name:  "le",
+					Value: "0.5",
+				},
+			},
+			Samples: []prompbmarshal.Sample{
+				{
+					Value:     34,
+					Timestamp: 8939432423,
+				},
+			},
+			Exemplars: []prompbmarshal.Exemplar{
+				{
+					Labels: []prompbmarshal.Label{
+						{
+							Name:  "trace_id",
+							Value: "KOO5S4vxi0o",
+						},
+					},
+					Value:     0.42,
+					Timestamp: 8939432000,
+				},
+				{
+					Value: 0.12,
+				},
+			},
+		},
+		{
+			Labels: []prompbmarshal.Label{
+				{
+					Name:  "foo",
+					Value: "bar",
+				},
+			},
+			Samples: []prompbmarshal.Sample{
+				{
+					Value: 9873,
+				},
+			},
+		},
+	}
+	data = wrm.MarshalProtobuf(data[:0])
+	f(data)
+
+	wrm.Reset()
+	wrm.Timeseries = []prompbmarshal.TimeSeries{
+		{
+			Labels: []prompbmarshal.Label{
+				{
+					Name:  "__name__",
+					Value: "http_requests_total",
+				},
+			},
+			Samples: []prompbmarshal.Sample{
+				{
+					Value:     123,
+					Timestamp: 8939432423,
+				},
+			},
+		},
+	}
+	wrm.Metadata = []prompbmarshal.MetricMetadata{
+		{
+			Type:             prompbmarshal.MetricMetadataCOUNTER,
+			MetricFamilyName: "http_requests_total",
+			Help:             "The total number of requests",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataGAUGE,
+			MetricFamilyName: "process_resident_memory_bytes",
+			Unit:             "bytes",
+		},
+	}
+	data = wrm.MarshalProtobuf(data[:0])
+	f(data)
 }
@@ -0,0 +1,309 @@
+package prompb
+
+import (
+	"fmt"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// UnmarshalProtobufV2 unmarshals wr from src encoded as Prometheus Remote Write 2.0 request.
+//
+// Interned label names and values are resolved from the request symbols, while per-series metadata
+// is converted to wr.Metadata, so wr can be processed in the same way as Remote Write 1.0 request.
+//
+// src mustn't change while wr is in use, since wr points to src.
+//
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/
+func (wr *WriteRequest) UnmarshalProtobufV2(src []byte) (err error) {
+	wr.Reset()
+
+	// message Request {
+	//   repeated string symbols        = 4;
+	//   repeated TimeSeries timeseries = 5;
+	// }
+	//
+	// Symbols are read at first, since they may be located after timeseries in src.
+	symbols := wr.symbolsPool
+	var fc easyproto.FieldContext
+	for tail := src; len(tail) > 0; {
+		tail, err = fc.NextField(tail)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum == 4 {
+			symbol, ok := fc.String()
+			if !ok {
+				return fmt.Errorf("cannot read symbol")
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	wr.symbolsPool = symbols
+	if len(symbols) > 0 && symbols[0] != "" {
+		return fmt.Errorf("the first symbol must be empty; got %q", symbols[0])
+	}
+
+	tss := wr.Timeseries
+	mms := wr.Metadata
+	labelsPool := wr.labelsPool
+	samplesPool := wr.samplesPool
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum != 5 {
+			continue
+		}
+		data, ok := fc.MessageData()
+		if !ok {
+			return fmt.Errorf("cannot read timeseries data")
+		}
+		if len(tss) < cap(tss) {
+			tss = tss[:len(tss)+1]
+		} else {
+			tss = append(tss, TimeSeries{})
+		}
+		ts := &tss[len(tss)-1]
+		var md metadataV2
+		labelsPool, samplesPool, err = ts.unmarshalProtobufV2(data, symbols, labelsPool, samplesPool, &wr.hp, &wr.ep, &wr.refsPool, &md)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal timeseries: %w", err)
+		}
+		mms, err = md.appendMetricMetadata(mms, symbols, ts.Labels)
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal timeseries metadata: %w", err)
+		}
+	}
+	wr.Timeseries = tss
+	wr.Metadata = mms
+	wr.labelsPool = labelsPool
+	wr.samplesPool = samplesPool
+	return nil
+}
+
+func (ts *TimeSeries) unmarshalProtobufV2(src []byte, symbols []string, labelsPool []Label, samplesPool []Sample, hp *histogramsPool, ep *exemplarsPool,
+	refsPool *[]uint32, md *metadataV2) ([]Label, []Sample, error) {
+	// message TimeSeries {
+	//   repeated uint32 labels_refs   = 1;
+	//   repeated Sample samples       = 2;
+	//   repeated Histogram histograms = 3;
+	//   repeated Exemplar exemplars   = 4;
+	//   Metadata metadata             = 5;
+	//   int64 created_timestamp       = 6;
+	// }
+	labelsPoolLen := len(labelsPool)
+	samplesPoolLen := len(samplesPool)
+	exemplarsPoolLen := len(ep.exemplars)
+	histogramsPoolLen := len(hp.histograms)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return labelsPool, samplesPool, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			refs, ok := fc.UnpackUint32s((*refsPool)[:0])
+			*refsPool = refs
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read labels refs")
+			}
+			labelsPool, err = appendLabelsFromRefs(labelsPool, symbols, refs)
+			if err != nil {
+				return labelsPool, samplesPool, err
+			}
+		case 2:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the sample data")
+			}
+			if len(samplesPool) < cap(samplesPool) {
+				samplesPool = samplesPool[:len(samplesPool)+1]
+			} else {
+				samplesPool = append(samplesPool, Sample{})
+			}
+			sample := &samplesPool[len(samplesPool)-1]
+			if err := sample.unmarshalProtobuf(data); err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal sample: %w", err)
+			}
+		case 3:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the histogram data")
+			}
+			if len(hp.histograms) < cap(hp.histograms) {
+				hp.histograms = hp.histograms[:len(hp.histograms)+1]
+			} else {
+				hp.histograms = append(hp.histograms, Histogram{})
+			}
+			h := &hp.histograms[len(hp.histograms)-1]
+			hp.spans, hp.floats, hp.deltas, err = h.unmarshalProtobuf(data, hp.spans, hp.floats, hp.deltas[:0])
+			if err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal histogram: %w", err)
+			}
+		case 4:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the exemplar data")
+			}
+			if len(ep.exemplars) < cap(ep.exemplars) {
+				ep.exemplars = ep.exemplars[:len(ep.exemplars)+1]
+			} else {
+				ep.exemplars = append(ep.exemplars, Exemplar{})
+			}
+			e := &ep.exemplars[len(ep.exemplars)-1]
+			ep.labels, err = e.unmarshalProtobufV2(data, symbols, ep.labels, refsPool)
+			if err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal exemplar: %w", err)
+			}
+		case 5:
+			data, ok := fc.MessageData()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read the metadata")
+			}
+			if err := md.unmarshalProtobuf(data); err != nil {
+				return labelsPool, samplesPool, fmt.Errorf("cannot unmarshal metadata: %w", err)
+			}
+		case 6:
+			createdTimestamp, ok := fc.Int64()
+			if !ok {
+				return labelsPool, samplesPool, fmt.Errorf("cannot read created timestamp")
+			}
+			ts.CreatedTimestamp = createdTimestamp
+		}
+	}
+	ts.Labels = labelsPool[labelsPoolLen:]
+	ts.Samples = samplesPool[samplesPoolLen:]
+	ts.Exemplars = ep.exemplars[exemplarsPoolLen:]
+	ts.Histograms = hp.histograms[histogramsPoolLen:]
+	return labelsPool, samplesPool, nil
+}
+
+func (e *Exemplar) unmarshalProtobufV2(src []byte, symbols []string, labelsPool []Label, refsPool *[]uint32) ([]Label, error) {
+	// message Exemplar {
+	//   repeated uint32 labels_refs = 1;
+	//   double value                = 2;
+	//   int64 timestamp             = 3;
+	// }
+	labelsPoolLen := len(labelsPool)
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return labelsPool, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		switch fc.FieldNum {
+		case 1:
+			refs, ok := fc.UnpackUint32s((*refsPool)[:0])
+			*refsPool = refs
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read labels refs")
+			}
+			labelsPool, err = appendLabelsFromRefs(labelsPool, symbols, refs)
+			if err != nil {
+				return labelsPool, err
+			}
+		case 2:
+			value, ok := fc.Double()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar value")
+			}
+			e.Value = value
+		case 3:
+			timestamp, ok := fc.Int64()
+			if !ok {
+				return labelsPool, fmt.Errorf("cannot read exemplar timestamp")
+			}
+			e.Timestamp = timestamp
+		}
+	}
+	e.Labels = labelsPool[labelsPoolLen:]
+	return labelsPool, nil
+}
+
+func appendLabelsFromRefs(dst []Label, symbols []string, refs []uint32) ([]Label, error) {
+	if len(refs)%2 != 0 {
+		return dst, fmt.Errorf("the number of labels refs must be even; got %d refs", len(refs))
+	}
+	for i := 0; i < len(refs); i += 2 {
+		nameRef, valueRef := refs[i], refs[i+1]
+		if uint64(nameRef) >= uint64(len(symbols)) || uint64(valueRef) >= uint64(len(symbols)) {
+			return dst, fmt.Errorf("labels refs (%d, %d) exceed the number of symbols %d", nameRef, valueRef, len(symbols))
+		}
+		dst = append(dst, Label{
+			Name:  symbols[nameRef],
+			Value: symbols[valueRef],
+		})
+	}
+	return dst, nil
+}
+
+// metadataV2 is per-series metadata from Remote Write 2.0 request.
+type metadataV2 struct {
+	typ     uint32
+	helpRef uint32
+	unitRef uint32
+}
+
+func (md *metadataV2) unmarshalProtobuf(src []byte) (err error) {
+	// message Metadata {
+	//   MetricType type = 1;
+	//   uint32 help_ref = 3;
+	//   uint32 unit_ref = 4;
+	// }
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		src, err = fc.NextField(src)
+		if err != nil {
+			return fmt.Errorf("cannot read the next field: %w", err)
+		}
+		var ok bool
+		switch fc.FieldNum {
+		case 1:
+			md.typ, ok = fc.Uint32()
+		case 3:
+			md.helpRef, ok = fc.Uint32()
+		case 4:
+			md.unitRef, ok = fc.Uint32()
+		default:
+			ok = true
+		}
+		if !ok {
+			return fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	return nil
+}
+
+// appendMetricMetadata appends md for the series with the given labels to dst.
+//
+// Metadata is skipped if it is empty or if it equals to the previously appended metadata for the same metric family,
+// since Remote Write 2.0 senders repeat metadata for every series of the metric family.
+func (md *metadataV2) appendMetricMetadata(dst []MetricMetadata, symbols []string, labels []Label) ([]MetricMetadata, error) {
+	if *md == (metadataV2{}) {
+		return dst, nil
+	}
+	if uint64(md.helpRef) >= uint64(len(symbols)) || uint64(md.unitRef) >= uint64(len(symbols)) {
+		return dst, fmt.Errorf("metadata refs (help=%d, unit=%d) exceed the number of symbols %d", md.helpRef, md.unitRef, len(symbols))
+	}
+	metricName := ""
+	for _, label := range labels {
+		if label.Name == "__name__" {
+			metricName = label.Value
+			break
+		}
+	}
+	mm := MetricMetadata{
+		Type:             md.typ,
+		MetricFamilyName: metricName,
+		Help:             symbols[md.helpRef],
+		Unit:             symbols[md.unitRef],
+	}
+	if len(dst) > 0 && dst[len(dst)-1] == mm {
+		return dst, nil
+	}
+	return append(dst, mm), nil
+}
@@ -0,0 +1,229 @@
+package prompb_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestWriteRequestUnmarshalProtobufV2(t *testing.T) {
+	var wr prompb.WriteRequest
+
+	f := func(wrm *prompbmarshal.WriteRequest) {
+		t.Helper()
+
+		data := wrm.MarshalProtobufV2(nil)
+		if err := wr.UnmarshalProtobufV2(data); err != nil {
+			t.Fatalf("cannot unmarshal protobuf: %s", err)
+		}
+
+		// Compare the unmarshaled wr with the original wrm.
+		var tss []prompbmarshal.TimeSeries
+		for _, ts := range wr.Timeseries {
+			var labels []prompbmarshal.Label
+			for _, label := range ts.Labels {
+				labels = append(labels, prompbmarshal.Label{
+					Name:  label.Name,
+					Value: label.Value,
+				})
+			}
+			var samples []prompbmarshal.Sample
+			for _, sample := range ts.Samples {
+				samples = append(samples, prompbmarshal.Sample{
+					Value:     sample.Value,
+					Timestamp: sample.Timestamp,
+				})
+			}
+			var exemplars []prompbmarshal.Exemplar
+			for _, exemplar := range ts.Exemplars {
+				var exemplarLabels []prompbmarshal.Label
+				for _, label := range exemplar.Labels {
+					exemplarLabels = append(exemplarLabels, prompbmarshal.Label{
+						Name:  label.Name,
+						Value: label.Value,
+					})
+				}
+				exemplars = append(exemplars, prompbmarshal.Exemplar{
+					Labels:    exemplarLabels,
+					Value:     exemplar.Value,
+					Timestamp: exemplar.Timestamp,
+				})
+			}
+			tss = append(tss, prompbmarshal.TimeSeries{
+				Labels:    labels,
+				Samples:   samples,
+				Exemplars: exemplars,
+			})
+		}
+		if !reflect.DeepEqual(tss, wrm.Timeseries) {
+			t.Fatalf("unexpected timeseries\ngot\n%v\nwant\n%v", tss, wrm.Timeseries)
+		}
+	}
+
+	f(&prompbmarshal.WriteRequest{})
+	f(&prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "http_requests_total",
+					},
+					{
+						Name:  "job",
+						Value: "foo",
+					},
+					{
+						Name: "empty",
+					},
+				},
+				Samples: []prompbmarshal.Sample{
+					{
+						Value:     1,
+						Timestamp: 1700000000000,
+					},
+					{
+						Value:     2.5,
+						Timestamp: 1700000015000,
+					},
+				},
+				Exemplars: []prompbmarshal.Exemplar{
+					{
+						Labels: []prompbmarshal.Label{{
+							Name:  "trace_id",
+							Value: "abc",
+						}},
+						Value:     1,
+						Timestamp: 1700000000001,
+					},
+					{
+						Value:     2,
+						Timestamp: 1700000000002,
+					},
+				},
+			},
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "http_requests_total",
+					},
+					{
+						Name:  "job",
+						Value: "bar",
+					},
+				},
+				Samples: []prompbmarshal.Sample{{
+					Value:     -1e10,
+					Timestamp: -1,
+				}},
+			},
+		},
+	})
+}
+
+func TestWriteRequestUnmarshalProtobufV2Metadata(t *testing.T) {
+	// Construct the request manually, since prompbmarshal doesn't support metadata, histograms and created timestamps for Remote Write 2.0.
+	var mp easyproto.MarshalerPool
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	for _, s := range []string{"", "__name__", "foo_seconds", "job", "a", "b", "help for foo", "seconds"} {
+		mm.AppendString(4, s)
+	}
+	for _, job := range []uint32{4, 5} {
+		tsm := mm.AppendMessage(5)
+		tsm.AppendUint32s(1, []uint32{1, 2, 3, job})
+		hm := tsm.AppendMessage(3)
+		hm.AppendUint64(1, 10)
+		hm.AppendDouble(3, 12.5)
+		hm.AppendInt64(15, 1700000000000)
+		mdm := tsm.AppendMessage(5)
+		mdm.AppendUint32(1, uint32(prompbmarshal.MetricMetadataHISTOGRAM))
+		mdm.AppendUint32(3, 6)
+		mdm.AppendUint32(4, 7)
+		tsm.AppendInt64(6, 1699999999000)
+	}
+	data := m.Marshal(nil)
+	mp.Put(m)
+
+	var wr prompb.WriteRequest
+	if err := wr.UnmarshalProtobufV2(data); err != nil {
+		t.Fatalf("cannot unmarshal protobuf: %s", err)
+	}
+	if len(wr.Timeseries) != 2 {
+		t.Fatalf("unexpected number of timeseries; got %d; want 2", len(wr.Timeseries))
+	}
+	for i, job := range []string{"a", "b"} {
+		ts := &wr.Timeseries[i]
+		labelsExpected := []prompb.Label{
+			{
+				Name:  "__name__",
+				Value: "foo_seconds",
+			},
+			{
+				Name:  "job",
+				Value: job,
+			},
+		}
+		if !reflect.DeepEqual(ts.Labels, labelsExpected) {
+			t.Fatalf("unexpected labels; got %v; want %v", ts.Labels, labelsExpected)
+		}
+		if len(ts.Histograms) != 1 {
+			t.Fatalf("unexpected number of histograms; got %d; want 1", len(ts.Histograms))
+		}
+		h := &ts.Histograms[0]
+		if h.Count != 10 || h.Sum != 12.5 || h.Timestamp != 1700000000000 {
+			t.Fatalf("unexpected histogram: %#v", h)
+		}
+		if ts.CreatedTimestamp != 1699999999000 {
+			t.Fatalf("unexpected created timestamp; got %d; want %d", ts.CreatedTimestamp, 1699999999000)
+		}
+	}
+
+	// Metadata must be de-duplicated among series of the same metric family.
+	metadataExpected := []prompb.MetricMetadata{{
+		Type:             uint32(prompbmarshal.MetricMetadataHISTOGRAM),
+		MetricFamilyName: "foo_seconds",
+		Help:             "help for foo",
+		Unit:             "seconds",
+	}}
+	if !reflect.DeepEqual(wr.Metadata, metadataExpected) {
+		t.Fatalf("unexpected metadata; got %v; want %v", wr.Metadata, metadataExpected)
+	}
+}
+
+func TestWriteRequestUnmarshalProtobufV2Failure(t *testing.T) {
+	f := func(symbols []string, refs []uint32) {
+		t.Helper()
+
+		var mp easyproto.MarshalerPool
+		m := mp.Get()
+		mm := m.MessageMarshaler()
+		for _, s := range symbols {
+			mm.AppendString(4, s)
+		}
+		tsm := mm.AppendMessage(5)
+		tsm.AppendUint32s(1, refs)
+		data := m.Marshal(nil)
+		mp.Put(m)
+
+		var wr prompb.WriteRequest
+		if err := wr.UnmarshalProtobufV2(data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// non-empty first symbol
+	f([]string{"foo", "bar"}, []uint32{0, 1})
+
+	// odd number of refs
+	f([]string{"", "foo", "bar"}, []uint32{1, 2, 1})
+
+	// refs out of symbols range
+	f([]string{"", "foo", "bar"}, []uint32{1, 3})
+	f(nil, []uint32{1, 2})
+}
@@ -0,0 +1,139 @@
+package prompbmarshal
+
+import (
+	"sync"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// MarshalProtobufV2 marshals wr to dst as Prometheus Remote Write 2.0 request and returns the result.
+//
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/
+func (wr *WriteRequest) MarshalProtobufV2(dst []byte) []byte {
+	st := getSymbolsTable()
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		st.addLabels(ts.Labels)
+		for j := range ts.Exemplars {
+			st.addLabels(ts.Exemplars[j].Labels)
+		}
+	}
+
+	// message Request {
+	//   repeated string symbols       = 4;
+	//   repeated TimeSeries timeseries = 5;
+	// }
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	for _, s := range st.symbols {
+		mm.AppendString(4, s)
+	}
+	refs := st.refs
+	for i := range wr.Timeseries {
+		refs = wr.Timeseries[i].marshalProtobufV2(mm.AppendMessage(5), refs)
+	}
+	dst = m.Marshal(dst)
+	mp.Put(m)
+	putSymbolsTable(st)
+	return dst
+}
+
+var mp easyproto.MarshalerPool
+
+func (ts *TimeSeries) marshalProtobufV2(mm *easyproto.MessageMarshaler, refs []uint32) []uint32 {
+	// message TimeSeries {
+	//   repeated uint32 labels_refs = 1;
+	//   repeated Sample samples     = 2;
+	//   repeated Exemplar exemplars = 4;
+	// }
+	refs = appendRefs(mm, 1, refs, len(ts.Labels))
+	for i := range ts.Samples {
+		s := &ts.Samples[i]
+		// message Sample {
+		//   double value    = 1;
+		//   int64 timestamp = 2;
+		// }
+		smm := mm.AppendMessage(2)
+		smm.AppendDouble(1, s.Value)
+		smm.AppendInt64(2, s.Timestamp)
+	}
+	for i := range ts.Exemplars {
+		e := &ts.Exemplars[i]
+		// message Exemplar {
+		//   repeated uint32 labels_refs = 1;
+		//   double value                = 2;
+		//   int64 timestamp             = 3;
+		// }
+		emm := mm.AppendMessage(4)
+		refs = appendRefs(emm, 1, refs, len(e.Labels))
+		emm.AppendDouble(2, e.Value)
+		emm.AppendInt64(3, e.Timestamp)
+	}
+	return refs
+}
+
+// appendRefs appends references for labelsLen labels from refs to mm under the given fieldNum and returns the remaining refs.
+func appendRefs(mm *easyproto.MessageMarshaler, fieldNum uint32, refs []uint32, labelsLen int) []uint32 {
+	n := 2 * labelsLen
+	if n > 0 {
+		mm.AppendUint32s(fieldNum, refs[:n])
+	}
+	return refs[n:]
+}
+
+// symbolsTable holds interned strings for Remote Write 2.0 request.
+type symbolsTable struct {
+	// m maps strings to their indexes in symbols.
+	m map[string]uint32
+
+	// symbols contains the interned strings. The first string is always empty according to the spec.
+	symbols []string
+
+	// refs contains references to symbols for label names and values in the order they were added.
+	refs []uint32
+}
+
+func (st *symbolsTable) reset() {
+	clear(st.m)
+	clear(st.symbols)
+	st.symbols = append(st.symbols[:0], "")
+	st.refs = st.refs[:0]
+}
+
+func (st *symbolsTable) addLabels(labels []Label) {
+	for i := range labels {
+		label := &labels[i]
+		st.refs = append(st.refs, st.getRef(label.Name), st.getRef(label.Value))
+	}
+}
+
+func (st *symbolsTable) getRef(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	ref, ok := st.m[s]
+	if !ok {
+		ref = uint32(len(st.symbols))
+		st.m[s] = ref
+		st.symbols = append(st.symbols, s)
+	}
+	return ref
+}
+
+func getSymbolsTable() *symbolsTable {
+	v := symbolsTablePool.Get()
+	if v == nil {
+		v = &symbolsTable{
+			m: make(map[string]uint32),
+		}
+	}
+	st := v.(*symbolsTable)
+	st.reset()
+	return st
+}
+
+func putSymbolsTable(st *symbolsTable) {
+	symbolsTablePool.Put(st)
+}
+
+var symbolsTablePool sync.Pool
@@ -15,8 +15,17 @@ type Sample struct {
 
 // TimeSeries represents samples and labels for a single time series.
 type TimeSeries struct {
-	Labels  []Label
-	Samples []Sample
+	Labels    []Label
+	Samples   []Sample
+	Exemplars []Exemplar
+}
+
+// Exemplar is an exemplar for the sample, such as trace_id for the request with the sampled latency.
+type Exemplar struct {
+	// Optional, can be empty.
+	Labels    []Label
+	Value     float64
+	Timestamp int64
 }
 
 type Label struct {
@@ -24,6 +33,32 @@ type Label struct {
 	Value string
 }
 
+// MetricMetadataType is the type of the metric family.
+type MetricMetadataType uint32
+
+const (
+	MetricMetadataUNKNOWN        MetricMetadataType = 0
+	MetricMetadataCOUNTER        MetricMetadataType = 1
+	MetricMetadataGAUGE          MetricMetadataType = 2
+	MetricMetadataHISTOGRAM      MetricMetadataType = 3
+	MetricMetadataGAUGEHISTOGRAM MetricMetadataType = 4
+	MetricMetadataSUMMARY        MetricMetadataType = 5
+	MetricMetadataINFO           MetricMetadataType = 6
+	MetricMetadataSTATESET       MetricMetadataType = 7
+)
+
+// MetricMetadata is metadata for the metric family.
+type MetricMetadata struct {
+	// Type is the metric type.
+	Type MetricMetadataType
+	// MetricFamilyName is the name of the metric family, such as http_requests_total.
+	MetricFamilyName string
+	// Help is the description for the metric family.
+	Help string
+	// Unit is the unit for the metric family.
+	Unit string
+}
+
 func (m *Sample) MarshalToSizedBuffer(dst []byte) (int, error) {
 	i := len(dst)
 	if m.Timestamp != 0 {
@@ -40,8 +75,44 @@ func (m *Sample) MarshalToSizedBuffer(dst []byte) (int, error) {
 	return len(dst) - i, nil
 }
 
+func (m *Exemplar) MarshalToSizedBuffer(dst []byte) (int, error) {
+	i := len(dst)
+	if m.Timestamp != 0 {
+		i = encodeVarint(dst, i, uint64(m.Timestamp))
+		i--
+		dst[i] = 0x18
+	}
+	if m.Value != 0 {
+		i -= 8
+		binary.LittleEndian.PutUint64(dst[i:], uint64(math.Float64bits(float64(m.Value))))
+		i--
+		dst[i] = 0x11
+	}
+	for j := len(m.Labels) - 1; j >= 0; j-- {
+		size, err := m.Labels[j].MarshalToSizedBuffer(dst[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarint(dst, i, uint64(size))
+		i--
+		dst[i] = 0xa
+	}
+	return len(dst) - i, nil
+}
+
 func (m *TimeSeries) MarshalToSizedBuffer(dst []byte) (int, error) {
 	i := len(dst)
+	for j := len(m.Exemplars) - 1; j >= 0; j-- {
+		size, err := m.Exemplars[j].MarshalToSizedBuffer(dst[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarint(dst, i, uint64(size))
+		i--
+		dst[i] = 0x1a
+	}
 	for j := len(m.Samples) - 1; j >= 0; j-- {
 		size, err := m.Samples[j].MarshalToSizedBuffer(dst[:i])
 		if err != nil {
@@ -84,6 +155,37 @@ func (m *Label) MarshalToSizedBuffer(dst []byte) (int, error) {
 	return len(dst) - i, nil
 }
 
+func (m *MetricMetadata) MarshalToSizedBuffer(dst []byte) (int, error) {
+	i := len(dst)
+	if len(m.Unit) > 0 {
+		i -= len(m.Unit)
+		copy(dst[i:], m.Unit)
+		i = encodeVarint(dst, i, uint64(len(m.Unit)))
+		i--
+		dst[i] = 0x2a
+	}
+	if len(m.Help) > 0 {
+		i -= len(m.Help)
+		copy(dst[i:], m.Help)
+		i = encodeVarint(dst, i, uint64(len(m.Help)))
+		i--
+		dst[i] = 0x22
+	}
+	if len(m.MetricFamilyName) > 0 {
+		i -= len(m.MetricFamilyName)
+		copy(dst[i:], m.MetricFamilyName)
+		i = encodeVarint(dst, i, uint64(len(m.MetricFamilyName)))
+		i--
+		dst[i] = 0x12
+	}
+	if m.Type != 0 {
+		i = encodeVarint(dst, i, uint64(m.Type))
+		i--
+		dst[i] = 0x8
+	}
+	return len(dst) - i, nil
+}
+
 func (m *Sample) Size() (n int) {
 	if m == nil {
 		return 0
@@ -97,6 +199,23 @@ func (m *Sample) Size() (n int) {
 	return n
 }
 
+func (m *Exemplar) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	for _, e := range m.Labels {
+		l := e.Size()
+		n += 1 + l + sov(uint64(l))
+	}
+	if m.Value != 0 {
+		n += 9
+	}
+	if m.Timestamp != 0 {
+		n += 1 + sov(uint64(m.Timestamp))
+	}
+	return n
+}
+
 func (m *TimeSeries) Size() (n int) {
 	if m == nil {
 		return 0
@@ -109,6 +228,10 @@ func (m *TimeSeries) Size() (n int) {
 		l := e.Size()
 		n += 1 + l + sov(uint64(l))
 	}
+	for _, e := range m.Exemplars {
+		l := e.Size()
+		n += 1 + l + sov(uint64(l))
+	}
 	return n
 }
 
@@ -124,3 +247,22 @@ func (m *Label) Size() (n int) {
 	}
 	return n
 }
+
+func (m *MetricMetadata) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	if m.Type != 0 {
+		n += 1 + sov(uint64(m.Type))
+	}
+	if l := len(m.MetricFamilyName); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
+	if l := len(m.Help); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
+	if l := len(m.Unit); l > 0 {
+		n += 1 + l + sov(uint64(l))
+	}
+	return n
+}
@@ -22,6 +22,55 @@ func (wr *WriteRequest) MarshalProtobuf(dst []byte) []byte {
 // Reset resets wr.
 func (wr *WriteRequest) Reset() {
 	wr.Timeseries = ResetTimeSeries(wr.Timeseries)
+
+	clear(wr.Metadata)
+	wr.Metadata = wr.Metadata[:0]
+}
+
+// String returns the name for t in the format used by Prometheus querying API, such as "counter" or "gauge".
+func (t MetricMetadataType) String() string {
+	switch t {
+	case MetricMetadataCOUNTER:
+		return "counter"
+	case MetricMetadataGAUGE:
+		return "gauge"
+	case MetricMetadataHISTOGRAM:
+		return "histogram"
+	case MetricMetadataGAUGEHISTOGRAM:
+		return "gaugehistogram"
+	case MetricMetadataSUMMARY:
+		return "summary"
+	case MetricMetadataINFO:
+		return "info"
+	case MetricMetadataSTATESET:
+		return "stateset"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseMetricMetadataType returns MetricMetadataType for the given name such as "counter" or "gauge".
+//
+// MetricMetadataUNKNOWN is returned for unsupported names.
+func ParseMetricMetadataType(name string) MetricMetadataType {
+	switch name {
+	case "counter":
+		return MetricMetadataCOUNTER
+	case "gauge":
+		return MetricMetadataGAUGE
+	case "histogram":
+		return MetricMetadataHISTOGRAM
+	case "gaugehistogram":
+		return MetricMetadataGAUGEHISTOGRAM
+	case "summary":
+		return MetricMetadataSUMMARY
+	case "info":
+		return MetricMetadataINFO
+	case "stateset":
+		return MetricMetadataSTATESET
+	default:
+		return MetricMetadataUNKNOWN
+	}
 }
 
 // ResetTimeSeries clears all the GC references from tss and returns an empty tss ready for further use.
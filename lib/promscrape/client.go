@@ -39,6 +39,7 @@ type client struct {
 	setHeaders              func(req *http.Request) error
 	setProxyHeaders         func(req *http.Request) error
 	maxScrapeSize           int64
+	acceptHeader            string
 }
 
 func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
@@ -104,6 +105,7 @@ func newClient(ctx context.Context, sw *ScrapeWork) (*client, error) {
 		setHeaders:              setHeaders,
 		setProxyHeaders:         setProxyHeaders,
 		maxScrapeSize:           sw.MaxScrapeSize,
+		acceptHeader:            getAcceptHeader(sw.ScrapeProtocols),
 	}
 	return c, nil
 }
@@ -116,12 +118,7 @@ func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
 		cancel()
 		return fmt.Errorf("cannot create request for %q: %w", c.scrapeURL, err)
 	}
-	// The following `Accept` header has been copied from Prometheus sources.
-	// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
-	// This is needed as a workaround for scraping stupid Java-based servers such as Spring Boot.
-	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/608 for details.
-	// Do not bloat the `Accept` header with OpenMetrics shit, since it looks like dead standard now.
-	req.Header.Set("Accept", "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	req.Header.Set("Accept", c.acceptHeader)
 	// Set X-Prometheus-Scrape-Timeout-Seconds like Prometheus does, since it is used by some exporters such as PushProx.
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/1179#issuecomment-813117162
 	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", c.scrapeTimeoutSecondsStr)
@@ -154,6 +151,7 @@ func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
 	scrapesOK.Inc()
 
 	// Read the data from resp.Body
+	bodyStart := len(dst.B)
 	r := &io.LimitedReader{
 		R: resp.Body,
 		N: c.maxScrapeSize,
@@ -173,6 +171,9 @@ func (c *client) ReadData(dst *bytesutil.ByteBuffer) error {
 			"Possible solutions are: reduce the response size for the target, increase -promscrape.maxScrapeSize command-line flag, "+
 			"increase max_scrape_size value in scrape config for the given target", c.scrapeURL, c.maxScrapeSize)
 	}
+	if err := convertResponseToText(dst, bodyStart, resp.Header.Get("Content-Type")); err != nil {
+		return fmt.Errorf("cannot process response from %q: %w", c.scrapeURL, err)
+	}
 	return nil
 }
 
@@ -330,6 +330,8 @@ type ScrapeConfig struct {
 	ScrapeOffset        *promutils.Duration        `yaml:"scrape_offset,omitempty"`
 	SeriesLimit         *int                       `yaml:"series_limit,omitempty"`
 	NoStaleMarkers      *bool                      `yaml:"no_stale_markers,omitempty"`
+	ProbeConfig         *ProbeConfig               `yaml:"probe_config,omitempty"`
+	ScrapeProtocols     []string                   `yaml:"scrape_protocols,omitempty"`
 	ProxyClientConfig   promauth.ProxyClientConfig `yaml:",inline"`
 
 	// This is set in loadConfig
@@ -953,6 +955,14 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 	if sc.EnableCompression != nil {
 		disableCompression = !*sc.EnableCompression
 	}
+	if sc.ProbeConfig != nil {
+		if err := sc.ProbeConfig.validate(); err != nil {
+			return nil, fmt.Errorf("cannot parse `probe_config` for `job_name` %q: %w", jobName, err)
+		}
+	}
+	if err := validateScrapeProtocols(sc.ScrapeProtocols); err != nil {
+		return nil, fmt.Errorf("cannot parse `scrape_protocols` for `job_name` %q: %w", jobName, err)
+	}
 	swc := &scrapeWorkConfig{
 		scrapeInterval:       scrapeInterval,
 		scrapeIntervalString: scrapeInterval.String(),
@@ -980,6 +990,8 @@ func getScrapeWorkConfig(sc *ScrapeConfig, baseDir string, globalCfg *GlobalConf
 		scrapeOffset:         sc.ScrapeOffset.Duration(),
 		seriesLimit:          seriesLimit,
 		noStaleMarkers:       noStaleTracking,
+		probeConfig:          sc.ProbeConfig,
+		scrapeProtocols:      sc.ScrapeProtocols,
 	}
 	return swc, nil
 }
@@ -1011,6 +1023,8 @@ type scrapeWorkConfig struct {
 	scrapeOffset         time.Duration
 	seriesLimit          int
 	noStaleMarkers       bool
+	probeConfig          *ProbeConfig
+	scrapeProtocols      []string
 }
 
 func appendScrapeWorkForTargetLabels(dst []*ScrapeWork, swc *scrapeWorkConfig, targetLabels []*promutils.Labels, discoveryType string) []*ScrapeWork {
@@ -1294,6 +1308,8 @@ func (swc *scrapeWorkConfig) getScrapeWork(target string, extraLabels, metaLabel
 		ScrapeOffset:         swc.scrapeOffset,
 		SeriesLimit:          seriesLimit,
 		NoStaleMarkers:       swc.noStaleMarkers,
+		ProbeConfig:          swc.probeConfig,
+		ScrapeProtocols:      swc.scrapeProtocols,
 		AuthToken:            at,
 
 		jobNameOriginal: swc.jobName,
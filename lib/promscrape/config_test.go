@@ -1260,6 +1260,83 @@ scrape_configs:
 		},
 	})
 	*seriesLimitPerTarget = defaultSeriesLimitPerTarget
+
+	// probe_config
+	f(`
+scrape_configs:
+- job_name: foo
+  probe_config:
+    prober: dns
+    query_name: example.com
+    query_type: MX
+  static_configs:
+  - targets: ["8.8.8.8:53"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "http://8.8.8.8:53/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "foo",
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"instance": "8.8.8.8:53",
+				"job":      "foo",
+			}),
+			ProbeConfig: &ProbeConfig{
+				Prober:    "dns",
+				QueryName: "example.com",
+				QueryType: "MX",
+			},
+		},
+	})
+
+	// Scrape config with invalid probe_config must be skipped
+	f(`
+scrape_configs:
+- job_name: foo
+  probe_config:
+    prober: icmp
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{})
+
+	// scrape_protocols
+	f(`
+scrape_configs:
+- job_name: foo
+  scrape_protocols: [PrometheusProto, OpenMetricsText1.0.0, PrometheusText0.0.4]
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{
+		{
+			ScrapeURL:       "http://foo.bar:1234/metrics",
+			ScrapeInterval:  defaultScrapeInterval,
+			ScrapeTimeout:   defaultScrapeTimeout,
+			MaxScrapeSize:   maxScrapeSize.N,
+			jobNameOriginal: "foo",
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"instance": "foo.bar:1234",
+				"job":      "foo",
+			}),
+			ScrapeProtocols: []string{"PrometheusProto", "OpenMetricsText1.0.0", "PrometheusText0.0.4"},
+		},
+	})
+
+	// Scrape config with invalid scrape_protocols must be skipped
+	f(`
+scrape_configs:
+- job_name: foo
+  scrape_protocols: [PrometheusText1.0.0]
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{})
+	f(`
+scrape_configs:
+- job_name: foo
+  scrape_protocols: [PrometheusProto, PrometheusProto]
+  static_configs:
+  - targets: ["foo.bar:1234"]
+`, []*ScrapeWork{})
 }
 
 func equalStaticConfigForScrapeWorks(a, b []*ScrapeWork) bool {
@@ -0,0 +1,386 @@
+package promscrape
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/VictoriaMetrics/metrics"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+)
+
+var probeDebug = flag.Bool("promscrape.probeDebug", false, "Whether to log the reason of every failed probe for scrape targets with `probe_config`. "+
+	"This may be useful for debugging failed probes. The reason of the failed probe is always exposed via probe_failure_info metric. "+
+	"See https://docs.victoriametrics.com/vmagent/#probing-targets")
+
+// ProbeConfig represents `probe_config` section of `scrape_config`.
+//
+// When it is set, the target is probed with the configured prober instead of scraping its metrics.
+// The probe results are exposed as `probe_*` metrics, which are processed in the same way as scraped metrics.
+// This is similar to https://github.com/prometheus/blackbox_exporter .
+type ProbeConfig struct {
+	// Prober is the probe type. Supported values: http, tcp, tls and dns.
+	Prober string `yaml:"prober"`
+
+	// Method is the HTTP method to use for http prober. GET is used by default.
+	Method string `yaml:"method,omitempty"`
+
+	// ValidStatusCodes is the list of valid HTTP status codes for http prober. 2xx status codes are valid by default.
+	ValidStatusCodes []int `yaml:"valid_status_codes,omitempty"`
+
+	// QueryName is the name to resolve for dns prober. The target is used as DNS server address.
+	QueryName string `yaml:"query_name,omitempty"`
+
+	// QueryType is the DNS record type to resolve for dns prober. Supported values: A, AAAA, CNAME, MX, NS and TXT. A is used by default.
+	QueryType string `yaml:"query_type,omitempty"`
+}
+
+func (pc *ProbeConfig) validate() error {
+	switch pc.Prober {
+	case "http":
+		if pc.QueryName != "" || pc.QueryType != "" {
+			return fmt.Errorf("`query_name` and `query_type` options cannot be used with `prober: http`")
+		}
+		for _, code := range pc.ValidStatusCodes {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("invalid status code in `valid_status_codes`: %d; it must be in the range [100..599]", code)
+			}
+		}
+	case "tcp", "tls":
+		if pc.Method != "" || len(pc.ValidStatusCodes) > 0 || pc.QueryName != "" || pc.QueryType != "" {
+			return fmt.Errorf("`method`, `valid_status_codes`, `query_name` and `query_type` options cannot be used with `prober: %s`", pc.Prober)
+		}
+	case "dns":
+		if pc.Method != "" || len(pc.ValidStatusCodes) > 0 {
+			return fmt.Errorf("`method` and `valid_status_codes` options cannot be used with `prober: dns`")
+		}
+		if pc.QueryName == "" {
+			return fmt.Errorf("missing `query_name` option for `prober: dns`")
+		}
+		switch strings.ToUpper(pc.QueryType) {
+		case "", "A", "AAAA", "CNAME", "MX", "NS", "TXT":
+		default:
+			return fmt.Errorf("unsupported `query_type`: %q; supported values: A, AAAA, CNAME, MX, NS, TXT", pc.QueryType)
+		}
+	default:
+		return fmt.Errorf("unsupported `prober`: %q; supported values: http, tcp, tls, dns", pc.Prober)
+	}
+	return nil
+}
+
+// String returns human-readable representation for pc.
+func (pc *ProbeConfig) String() string {
+	if pc == nil {
+		return ""
+	}
+	return fmt.Sprintf("prober=%s, method=%s, valid_status_codes=%v, query_name=%s, query_type=%s",
+		pc.Prober, pc.Method, pc.ValidStatusCodes, pc.QueryName, pc.QueryType)
+}
+
+// prober probes the target according to ProbeConfig and returns the probe results in Prometheus text exposition format.
+type prober struct {
+	ctx     context.Context
+	pc      *ProbeConfig
+	timeout time.Duration
+
+	// c is used by http prober.
+	c *client
+
+	// address is the host:port used by tcp, tls and dns probers.
+	address string
+
+	// tlsConfig is used by tls prober.
+	tlsConfig *tls.Config
+
+	// target is the target url used in logs.
+	target string
+
+	probesSuccess *metrics.Counter
+	probesFailure *metrics.Counter
+}
+
+// probeError is returned from probers on probe failure.
+type probeError struct {
+	// reason is the short reason of the failure. It is exposed via `reason` label of probe_failure_info metric.
+	reason string
+
+	err error
+}
+
+func newProbeError(reason string, err error) *probeError {
+	return &probeError{
+		reason: reason,
+		err:    err,
+	}
+}
+
+// Error implements error interface.
+func (pe *probeError) Error() string {
+	return fmt.Sprintf("%s: %s", pe.reason, pe.err)
+}
+
+func newProber(ctx context.Context, sw *ScrapeWork) (*prober, error) {
+	pc := sw.ProbeConfig
+	p := &prober{
+		ctx:     ctx,
+		pc:      pc,
+		timeout: sw.ScrapeTimeout,
+		target:  sw.ScrapeURL,
+
+		probesSuccess: metrics.GetOrCreateCounter(fmt.Sprintf(`vm_promscrape_probes_total{prober=%q,success="true"}`, pc.Prober)),
+		probesFailure: metrics.GetOrCreateCounter(fmt.Sprintf(`vm_promscrape_probes_total{prober=%q,success="false"}`, pc.Prober)),
+	}
+	if pc.Prober == "http" {
+		c, err := newClient(ctx, sw)
+		if err != nil {
+			return nil, err
+		}
+		p.c = c
+		return p, nil
+	}
+
+	u, err := url.Parse(sw.ScrapeURL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse target url %q: %w", sw.ScrapeURL, err)
+	}
+	p.address = u.Host
+	if u.Port() == "" {
+		defaultPort := "80"
+		switch {
+		case pc.Prober == "dns":
+			defaultPort = "53"
+		case pc.Prober == "tls" || u.Scheme == "https":
+			defaultPort = "443"
+		}
+		p.address = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	if pc.Prober == "tls" {
+		var tlsConfig *tls.Config
+		if sw.AuthConfig != nil {
+			tlsConfig, err = sw.AuthConfig.GetTLSConfig()
+			if err != nil {
+				return nil, fmt.Errorf("cannot initialize tls config for %q: %w", p.address, err)
+			}
+		}
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		} else {
+			tlsConfig = tlsConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = u.Hostname()
+		}
+		p.tlsConfig = tlsConfig
+	}
+	return p, nil
+}
+
+// ReadData performs the probe and writes the probe results in Prometheus text exposition format to dst.
+//
+// Probe failures are reported via probe_success and probe_failure_info metrics,
+// so ReadData returns error only if the probe cannot be performed.
+func (p *prober) ReadData(dst *bytesutil.ByteBuffer) error {
+	ctx, cancel := context.WithTimeout(p.ctx, p.timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	var b []byte
+	var pe *probeError
+	switch p.pc.Prober {
+	case "http":
+		b, pe = p.probeHTTP(ctx, dst.B)
+	case "tcp":
+		b, pe = p.probeTCP(ctx, dst.B)
+	case "tls":
+		b, pe = p.probeTLS(ctx, dst.B)
+	case "dns":
+		b, pe = p.probeDNS(ctx, dst.B)
+	default:
+		return fmt.Errorf("BUG: unexpected prober: %q", p.pc.Prober)
+	}
+	duration := time.Since(startTime).Seconds()
+	if pe != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		pe.reason = "timeout"
+	}
+	b = appendProbeMetric(b, "probe_duration_seconds", duration)
+	b = appendProbeMetric(b, "probe_success", boolToFloat64(pe == nil))
+	if pe != nil {
+		b = fmt.Appendf(b, "probe_failure_info{reason=%q} 1\n", pe.reason)
+	}
+	dst.B = b
+
+	if pe != nil {
+		p.probesFailure.Inc()
+		if *probeDebug {
+			logger.Infof("%s probe failed for target %q after %.3f seconds: %s", p.pc.Prober, p.target, duration, pe)
+		}
+		return nil
+	}
+	p.probesSuccess.Inc()
+	return nil
+}
+
+func (p *prober) probeHTTP(ctx context.Context, dst []byte) ([]byte, *probeError) {
+	c := p.c
+	method := p.pc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.scrapeURL, nil)
+	if err != nil {
+		return dst, newProbeError("request_error", fmt.Errorf("cannot create request: %w", err))
+	}
+	req.Header.Set("User-Agent", "vm_promscrape")
+	if err := c.setHeaders(req); err != nil {
+		return dst, newProbeError("request_error", fmt.Errorf("cannot set request headers: %w", err))
+	}
+	if err := c.setProxyHeaders(req); err != nil {
+		return dst, newProbeError("request_error", fmt.Errorf("cannot set proxy request headers: %w", err))
+	}
+	resp, err := c.c.Do(req)
+	if err != nil {
+		return dst, newProbeError("connection_error", fmt.Errorf("cannot perform request: %w", err))
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(resp.Body, c.maxScrapeSize))
+	_ = resp.Body.Close()
+	if err != nil {
+		return dst, newProbeError("read_error", fmt.Errorf("cannot read response body: %w", err))
+	}
+
+	dst = appendProbeMetric(dst, "probe_http_status_code", float64(resp.StatusCode))
+	if resp.ContentLength >= 0 {
+		// Content-Length header is missing in chunked and automatically decompressed responses.
+		dst = appendProbeMetric(dst, "probe_http_content_length", float64(resp.ContentLength))
+	}
+	dst = appendProbeMetric(dst, "probe_http_uncompressed_body_length", float64(n))
+	dst = appendProbeMetric(dst, "probe_http_ssl", boolToFloat64(resp.TLS != nil))
+	if resp.TLS != nil {
+		dst = appendTLSProbeMetrics(dst, resp.TLS)
+	}
+	if !isValidProbeStatusCode(resp.StatusCode, p.pc.ValidStatusCodes) {
+		return dst, newProbeError("invalid_status_code", fmt.Errorf("unexpected response status code: %d", resp.StatusCode))
+	}
+	return dst, nil
+}
+
+func (p *prober) probeTCP(ctx context.Context, dst []byte) ([]byte, *probeError) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return dst, newProbeError("connection_error", fmt.Errorf("cannot connect to %q: %w", p.address, err))
+	}
+	_ = conn.Close()
+	return dst, nil
+}
+
+func (p *prober) probeTLS(ctx context.Context, dst []byte) ([]byte, *probeError) {
+	d := &tls.Dialer{
+		Config: p.tlsConfig,
+	}
+	conn, err := d.DialContext(ctx, "tcp", p.address)
+	if err != nil {
+		return dst, newProbeError("connection_error", fmt.Errorf("cannot establish tls connection to %q: %w", p.address, err))
+	}
+	cs := conn.(*tls.Conn).ConnectionState()
+	_ = conn.Close()
+	dst = appendTLSProbeMetrics(dst, &cs)
+	return dst, nil
+}
+
+func (p *prober) probeDNS(ctx context.Context, dst []byte) ([]byte, *probeError) {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, p.address)
+		},
+	}
+	name := p.pc.QueryName
+	startTime := time.Now()
+	var answers int
+	var err error
+	queryType := strings.ToUpper(p.pc.QueryType)
+	if queryType == "" {
+		queryType = "A"
+	}
+	switch queryType {
+	case "A":
+		var ips []net.IP
+		ips, err = r.LookupIP(ctx, "ip4", name)
+		answers = len(ips)
+	case "AAAA":
+		var ips []net.IP
+		ips, err = r.LookupIP(ctx, "ip6", name)
+		answers = len(ips)
+	case "CNAME":
+		var cname string
+		cname, err = r.LookupCNAME(ctx, name)
+		if cname != "" {
+			answers = 1
+		}
+	case "MX":
+		var mxs []*net.MX
+		mxs, err = r.LookupMX(ctx, name)
+		answers = len(mxs)
+	case "NS":
+		var nss []*net.NS
+		nss, err = r.LookupNS(ctx, name)
+		answers = len(nss)
+	case "TXT":
+		var txts []string
+		txts, err = r.LookupTXT(ctx, name)
+		answers = len(txts)
+	}
+	dst = appendProbeMetric(dst, "probe_dns_lookup_time_seconds", time.Since(startTime).Seconds())
+	if err != nil {
+		return dst, newProbeError("dns_lookup_error", fmt.Errorf("cannot resolve %s record for %q via %q: %w", queryType, name, p.address, err))
+	}
+	dst = appendProbeMetric(dst, "probe_dns_answer_rrs", float64(answers))
+	if answers == 0 {
+		return dst, newProbeError("no_answers", fmt.Errorf("missing %s records for %q at %q", queryType, name, p.address))
+	}
+	return dst, nil
+}
+
+func appendTLSProbeMetrics(dst []byte, cs *tls.ConnectionState) []byte {
+	if len(cs.PeerCertificates) > 0 {
+		earliestExpiry := cs.PeerCertificates[0].NotAfter
+		for _, cert := range cs.PeerCertificates[1:] {
+			if cert.NotAfter.Before(earliestExpiry) {
+				earliestExpiry = cert.NotAfter
+			}
+		}
+		dst = appendProbeMetric(dst, "probe_ssl_earliest_cert_expiry", float64(earliestExpiry.Unix()))
+	}
+	dst = fmt.Appendf(dst, "probe_tls_version_info{version=%q} 1\n", tls.VersionName(cs.Version))
+	return dst
+}
+
+func isValidProbeStatusCode(statusCode int, validStatusCodes []int) bool {
+	if len(validStatusCodes) == 0 {
+		return statusCode >= 200 && statusCode < 300
+	}
+	return slices.Contains(validStatusCodes, statusCode)
+}
+
+func appendProbeMetric(dst []byte, name string, value float64) []byte {
+	return fmt.Appendf(dst, "%s %g\n", name, value)
+}
+
+func boolToFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
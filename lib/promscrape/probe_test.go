@@ -0,0 +1,241 @@
+package promscrape
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promauth"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+)
+
+func TestProbeConfigValidateSuccess(t *testing.T) {
+	f := func(pc *ProbeConfig) {
+		t.Helper()
+
+		if err := pc.validate(); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	f(&ProbeConfig{Prober: "http"})
+	f(&ProbeConfig{Prober: "http", Method: "HEAD", ValidStatusCodes: []int{200, 301}})
+	f(&ProbeConfig{Prober: "tcp"})
+	f(&ProbeConfig{Prober: "tls"})
+	f(&ProbeConfig{Prober: "dns", QueryName: "example.com"})
+	f(&ProbeConfig{Prober: "dns", QueryName: "example.com", QueryType: "mx"})
+}
+
+func TestProbeConfigValidateFailure(t *testing.T) {
+	f := func(pc *ProbeConfig) {
+		t.Helper()
+
+		if err := pc.validate(); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing prober
+	f(&ProbeConfig{})
+
+	// unsupported prober
+	f(&ProbeConfig{Prober: "icmp"})
+
+	// invalid status code
+	f(&ProbeConfig{Prober: "http", ValidStatusCodes: []int{1000}})
+
+	// options for another prober
+	f(&ProbeConfig{Prober: "http", QueryName: "example.com"})
+	f(&ProbeConfig{Prober: "tcp", Method: "GET"})
+	f(&ProbeConfig{Prober: "dns", QueryName: "example.com", ValidStatusCodes: []int{200}})
+
+	// missing query_name
+	f(&ProbeConfig{Prober: "dns"})
+
+	// unsupported query_type
+	f(&ProbeConfig{Prober: "dns", QueryName: "example.com", QueryType: "SOA"})
+}
+
+func TestProberReadData(t *testing.T) {
+	f := func(sw *ScrapeWork, valuesExpected map[string]float64, namesExpected []string, failureReasonExpected string) {
+		t.Helper()
+
+		if sw.ScrapeTimeout == 0 {
+			sw.ScrapeTimeout = 5 * time.Second
+		}
+		if sw.MaxScrapeSize == 0 {
+			sw.MaxScrapeSize = 16000
+		}
+		p, err := newProber(context.Background(), sw)
+		if err != nil {
+			t.Fatalf("cannot create prober: %s", err)
+		}
+		var bb bytesutil.ByteBuffer
+		if err := p.ReadData(&bb); err != nil {
+			t.Fatalf("unexpected error at ReadData: %s", err)
+		}
+
+		var rows parser.Rows
+		rows.UnmarshalWithErrLogger(string(bb.B), func(s string) {
+			t.Fatalf("unexpected error when parsing probe results: %s", s)
+		})
+		values := make(map[string]float64)
+		failureReason := ""
+		for _, r := range rows.Rows {
+			values[r.Metric] = r.Value
+			if r.Metric == "probe_failure_info" && len(r.Tags) == 1 && r.Tags[0].Key == "reason" {
+				failureReason = r.Tags[0].Value
+			}
+		}
+		if failureReason != failureReasonExpected {
+			t.Fatalf("unexpected failure reason; got %q; want %q", failureReason, failureReasonExpected)
+		}
+		for name, valueExpected := range valuesExpected {
+			value, ok := values[name]
+			if !ok {
+				t.Fatalf("missing %s metric in probe results:\n%s", name, bb.B)
+			}
+			if value != valueExpected {
+				t.Fatalf("unexpected %s value; got %v; want %v", name, value, valueExpected)
+			}
+		}
+		for _, name := range namesExpected {
+			if _, ok := values[name]; !ok {
+				t.Fatalf("missing %s metric in probe results:\n%s", name, bb.B)
+			}
+		}
+	}
+
+	statusCode := http.StatusOK
+	s := newClientTestServer(false, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(statusCode)
+		fmt.Fprintf(w, "hello")
+	}))
+	defer s.Close()
+	tlsServer := newClientTestServer(true, http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {}))
+	defer tlsServer.Close()
+
+	// http prober
+	f(&ScrapeWork{
+		ScrapeURL:   s.URL,
+		AuthConfig:  newTestAuthConfig(t, false, nil),
+		ProbeConfig: &ProbeConfig{Prober: "http"},
+	}, map[string]float64{
+		"probe_success":                       1,
+		"probe_http_status_code":              200,
+		"probe_http_content_length":           5,
+		"probe_http_uncompressed_body_length": 5,
+		"probe_http_ssl":                      0,
+	}, []string{"probe_duration_seconds"}, "")
+
+	// http prober with invalid status code
+	statusCode = http.StatusInternalServerError
+	f(&ScrapeWork{
+		ScrapeURL:   s.URL,
+		AuthConfig:  newTestAuthConfig(t, false, nil),
+		ProbeConfig: &ProbeConfig{Prober: "http"},
+	}, map[string]float64{
+		"probe_success":          0,
+		"probe_http_status_code": 500,
+	}, nil, "invalid_status_code")
+
+	// http prober with valid_status_codes
+	f(&ScrapeWork{
+		ScrapeURL:   s.URL,
+		AuthConfig:  newTestAuthConfig(t, false, nil),
+		ProbeConfig: &ProbeConfig{Prober: "http", ValidStatusCodes: []int{500}},
+	}, map[string]float64{
+		"probe_success":          1,
+		"probe_http_status_code": 500,
+	}, nil, "")
+
+	// https prober
+	f(&ScrapeWork{
+		ScrapeURL:   tlsServer.URL,
+		AuthConfig:  newTestAuthConfig(t, true, nil),
+		ProbeConfig: &ProbeConfig{Prober: "http"},
+	}, map[string]float64{
+		"probe_success":  1,
+		"probe_http_ssl": 1,
+	}, []string{"probe_ssl_earliest_cert_expiry", "probe_tls_version_info"}, "")
+
+	// tcp prober
+	f(&ScrapeWork{
+		ScrapeURL:   s.URL + "/metrics",
+		ProbeConfig: &ProbeConfig{Prober: "tcp"},
+	}, map[string]float64{
+		"probe_success": 1,
+	}, []string{"probe_duration_seconds"}, "")
+
+	// tls prober
+	f(&ScrapeWork{
+		ScrapeURL:   tlsServer.URL + "/metrics",
+		AuthConfig:  newTestAuthConfig(t, true, nil),
+		ProbeConfig: &ProbeConfig{Prober: "tls"},
+	}, map[string]float64{
+		"probe_success": 1,
+	}, []string{"probe_ssl_earliest_cert_expiry", "probe_tls_version_info"}, "")
+
+	// tls prober with untrusted certificate
+	f(&ScrapeWork{
+		ScrapeURL:   tlsServer.URL + "/metrics",
+		AuthConfig:  newTestAuthConfig(t, false, nil),
+		ProbeConfig: &ProbeConfig{Prober: "tls"},
+	}, map[string]float64{
+		"probe_success": 0,
+	}, nil, "connection_error")
+
+	// tcp and dns probers for unavailable target
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cannot create listener: %s", err)
+	}
+	unavailableAddr := ln.Addr().String()
+	_ = ln.Close()
+	f(&ScrapeWork{
+		ScrapeURL:   "http://" + unavailableAddr + "/metrics",
+		ProbeConfig: &ProbeConfig{Prober: "tcp"},
+	}, map[string]float64{
+		"probe_success": 0,
+	}, nil, "connection_error")
+	f(&ScrapeWork{
+		ScrapeURL:     "http://" + unavailableAddr + "/metrics",
+		ScrapeTimeout: time.Second,
+		ProbeConfig:   &ProbeConfig{Prober: "dns", QueryName: "example.com"},
+	}, map[string]float64{
+		"probe_success": 0,
+	}, []string{"probe_dns_lookup_time_seconds"}, "dns_lookup_error")
+}
+
+func TestNewProberAddress(t *testing.T) {
+	f := func(scrapeURL, prober, addressExpected string) {
+		t.Helper()
+
+		sw := &ScrapeWork{
+			ScrapeURL:   scrapeURL,
+			AuthConfig:  &promauth.Config{},
+			ProbeConfig: &ProbeConfig{Prober: prober},
+		}
+		if prober == "tls" {
+			sw.AuthConfig = newTestAuthConfig(t, false, nil)
+		}
+		p, err := newProber(context.Background(), sw)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if p.address != addressExpected {
+			t.Fatalf("unexpected address; got %q; want %q", p.address, addressExpected)
+		}
+	}
+
+	f("http://foo:1234/metrics", "tcp", "foo:1234")
+	f("http://foo/metrics", "tcp", "foo:80")
+	f("https://foo/metrics", "tcp", "foo:443")
+	f("http://foo/metrics", "tls", "foo:443")
+	f("http://8.8.8.8/metrics", "dns", "8.8.8.8:53")
+	f("http://[::1]/metrics", "dns", "[::1]:53")
+}
@@ -0,0 +1,761 @@
+package promscrape
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+)
+
+// appendTextFromProtobuf converts metrics in Prometheus protobuf exposition format at src to Prometheus text exposition format
+// and appends the result to dst.
+//
+// src must contain length-delimited io.prometheus.client.MetricFamily messages.
+// See https://github.com/prometheus/client_model/blob/master/io/prometheus/client/metrics.proto
+//
+// Native histograms are converted to `<name>_bucket` series with `vmrange` labels, `<name>_count` and `<name>_sum` series.
+// See https://valyala.medium.com/improving-histogram-usability-for-prometheus-and-grafana-bc7e5df0e350
+func appendTextFromProtobuf(dst, src []byte) ([]byte, error) {
+	pc := getProtobufConverter()
+	defer putProtobufConverter(pc)
+
+	for len(src) > 0 {
+		n, nSize := binary.Uvarint(src)
+		if nSize <= 0 {
+			return dst, fmt.Errorf("cannot read MetricFamily message length")
+		}
+		src = src[nSize:]
+		if uint64(len(src)) < n {
+			return dst, fmt.Errorf("too short data for MetricFamily message; got %d bytes; want %d bytes", len(src), n)
+		}
+		var err error
+		dst, err = pc.appendMetricFamily(dst, src[:n])
+		if err != nil {
+			return dst, fmt.Errorf("cannot unmarshal MetricFamily: %w", err)
+		}
+		src = src[n:]
+	}
+	return dst, nil
+}
+
+type protobufConverter struct {
+	metrics [][]byte
+	labels  []protobufLabel
+	deltas  []int64
+
+	negativeBuckets []float64
+	positiveBuckets []float64
+}
+
+type protobufLabel struct {
+	name  string
+	value string
+}
+
+type protobufExemplar struct {
+	labels    []protobufLabel
+	value     float64
+	timestamp int64
+}
+
+func (pc *protobufConverter) reset() {
+	clear(pc.metrics)
+	pc.metrics = pc.metrics[:0]
+	clear(pc.labels)
+	pc.labels = pc.labels[:0]
+	pc.deltas = pc.deltas[:0]
+	pc.negativeBuckets = pc.negativeBuckets[:0]
+	pc.positiveBuckets = pc.positiveBuckets[:0]
+}
+
+// Metric types from io.prometheus.client.MetricType
+const (
+	protobufMetricTypeCounter        = 0
+	protobufMetricTypeGauge          = 1
+	protobufMetricTypeSummary        = 2
+	protobufMetricTypeUntyped        = 3
+	protobufMetricTypeHistogram      = 4
+	protobufMetricTypeGaugeHistogram = 5
+)
+
+func (pc *protobufConverter) appendMetricFamily(dst, src []byte) ([]byte, error) {
+	// message MetricFamily {
+	//   string name = 1;
+	//   string help = 2;
+	//   MetricType type = 3;
+	//   repeated Metric metric = 4;
+	//   string unit = 5;
+	// }
+	var name, help, unit string
+	metricType := int32(protobufMetricTypeCounter)
+	metrics := pc.metrics[:0]
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			name, ok = fc.String()
+		case 2:
+			help, ok = fc.String()
+		case 3:
+			metricType, ok = fc.Int32()
+		case 4:
+			var data []byte
+			data, ok = fc.MessageData()
+			metrics = append(metrics, data)
+		case 5:
+			unit, ok = fc.String()
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	pc.metrics = metrics
+	if name == "" {
+		return dst, fmt.Errorf("missing metric family name")
+	}
+
+	if help != "" {
+		dst = append(dst, "# HELP "...)
+		dst = append(dst, name...)
+		dst = append(dst, ' ')
+		dst = appendEscapedHelp(dst, help)
+		dst = append(dst, '\n')
+	}
+	dst = append(dst, "# TYPE "...)
+	dst = append(dst, name...)
+	dst = append(dst, ' ')
+	dst = append(dst, getProtobufMetricTypeName(metricType)...)
+	dst = append(dst, '\n')
+	if unit != "" {
+		dst = append(dst, "# UNIT "...)
+		dst = append(dst, name...)
+		dst = append(dst, ' ')
+		dst = append(dst, unit...)
+		dst = append(dst, '\n')
+	}
+	for _, data := range metrics {
+		var err error
+		dst, err = pc.appendMetric(dst, name, data)
+		if err != nil {
+			return dst, fmt.Errorf("cannot unmarshal Metric for %q: %w", name, err)
+		}
+	}
+	return dst, nil
+}
+
+func getProtobufMetricTypeName(metricType int32) string {
+	switch metricType {
+	case protobufMetricTypeCounter:
+		return "counter"
+	case protobufMetricTypeGauge:
+		return "gauge"
+	case protobufMetricTypeSummary:
+		return "summary"
+	case protobufMetricTypeHistogram, protobufMetricTypeGaugeHistogram:
+		return "histogram"
+	default:
+		return "untyped"
+	}
+}
+
+func (pc *protobufConverter) appendMetric(dst []byte, name string, src []byte) ([]byte, error) {
+	// message Metric {
+	//   repeated LabelPair label = 1;
+	//   Gauge gauge = 2;
+	//   Counter counter = 3;
+	//   Summary summary = 4;
+	//   Untyped untyped = 5;
+	//   Histogram histogram = 7;
+	//   int64 timestamp_ms = 6;
+	// }
+	labels := pc.labels[:0]
+	var valueFieldNum uint32
+	var valueData []byte
+	var timestamp int64
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				labels, err = appendProtobufLabel(labels, data)
+				if err != nil {
+					return dst, fmt.Errorf("cannot unmarshal LabelPair: %w", err)
+				}
+			}
+		case 2, 3, 4, 5, 7:
+			valueFieldNum = fc.FieldNum
+			valueData, ok = fc.MessageData()
+		case 6:
+			timestamp, ok = fc.Int64()
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	pc.labels = labels
+
+	var err error
+	switch valueFieldNum {
+	case 2, 5:
+		// message Gauge {
+		//   double value = 1;
+		// }
+		// message Untyped {
+		//   double value = 1;
+		// }
+		var value float64
+		value, err = getProtobufDouble(valueData, 1)
+		if err == nil {
+			dst = appendProtobufSample(dst, name, "", labels, "", "", value, timestamp, nil)
+		}
+	case 3:
+		dst, err = pc.appendCounter(dst, name, labels, timestamp, valueData)
+	case 4:
+		dst, err = appendSummary(dst, name, labels, timestamp, valueData)
+	case 7:
+		dst, err = pc.appendHistogram(dst, name, labels, timestamp, valueData)
+	}
+	return dst, err
+}
+
+func (pc *protobufConverter) appendCounter(dst []byte, name string, labels []protobufLabel, timestamp int64, src []byte) ([]byte, error) {
+	// message Counter {
+	//   double value = 1;
+	//   Exemplar exemplar = 2;
+	//   google.protobuf.Timestamp created_timestamp = 3;
+	// }
+	var value float64
+	var e *protobufExemplar
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field in Counter: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			value, ok = fc.Double()
+		case 2:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				e, err = pc.unmarshalExemplar(labels, data)
+				if err != nil {
+					return dst, err
+				}
+			}
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d in Counter", fc.FieldNum)
+		}
+	}
+	dst = appendProtobufSample(dst, name, "", labels, "", "", value, timestamp, e)
+	return dst, nil
+}
+
+func appendSummary(dst []byte, name string, labels []protobufLabel, timestamp int64, src []byte) ([]byte, error) {
+	// message Summary {
+	//   uint64 sample_count = 1;
+	//   double sample_sum = 2;
+	//   repeated Quantile quantile = 3;
+	//   google.protobuf.Timestamp created_timestamp = 4;
+	// }
+	// message Quantile {
+	//   double quantile = 1;
+	//   double value = 2;
+	// }
+	var count uint64
+	var sum float64
+	var fc easyproto.FieldContext
+	var buf []byte
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field in Summary: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			count, ok = fc.Uint64()
+		case 2:
+			sum, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				phi, err := getProtobufDouble(data, 1)
+				if err != nil {
+					return dst, fmt.Errorf("cannot read quantile: %w", err)
+				}
+				value, err := getProtobufDouble(data, 2)
+				if err != nil {
+					return dst, fmt.Errorf("cannot read quantile value: %w", err)
+				}
+				buf = strconv.AppendFloat(buf[:0], phi, 'g', -1, 64)
+				dst = appendProtobufSample(dst, name, "", labels, "quantile", string(buf), value, timestamp, nil)
+			}
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d in Summary", fc.FieldNum)
+		}
+	}
+	dst = appendProtobufSample(dst, name, "_sum", labels, "", "", sum, timestamp, nil)
+	dst = appendProtobufSample(dst, name, "_count", labels, "", "", float64(count), timestamp, nil)
+	return dst, nil
+}
+
+func (pc *protobufConverter) appendHistogram(dst []byte, name string, labels []protobufLabel, timestamp int64, src []byte) ([]byte, error) {
+	// message Histogram {
+	//   uint64 sample_count = 1;
+	//   double sample_count_float = 4;
+	//   double sample_sum = 2;
+	//   repeated Bucket bucket = 3;
+	//   google.protobuf.Timestamp created_timestamp = 15;
+	//   sint32 schema = 5;
+	//   double zero_threshold = 6;
+	//   uint64 zero_count = 7;
+	//   double zero_count_float = 8;
+	//   repeated BucketSpan negative_span = 9;
+	//   repeated sint64 negative_delta = 10;
+	//   repeated double negative_count = 11;
+	//   repeated BucketSpan positive_span = 12;
+	//   repeated sint64 positive_delta = 13;
+	//   repeated double positive_count = 14;
+	//   repeated Exemplar exemplars = 16;
+	// }
+	var h prompb.Histogram
+	var bucketsData [][]byte
+	deltas := pc.deltas[:0]
+	negativeBuckets := pc.negativeBuckets[:0]
+	positiveBuckets := pc.positiveBuckets[:0]
+	var negativeSpans, positiveSpans []prompb.BucketSpan
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field in Histogram: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var count uint64
+			count, ok = fc.Uint64()
+			h.Count = float64(count)
+		case 4:
+			h.Count, ok = fc.Double()
+		case 2:
+			h.Sum, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			bucketsData = append(bucketsData, data)
+		case 5:
+			h.Schema, ok = fc.Sint32()
+		case 6:
+			h.ZeroThreshold, ok = fc.Double()
+		case 7:
+			var count uint64
+			count, ok = fc.Uint64()
+			h.ZeroCount = float64(count)
+		case 8:
+			h.ZeroCount, ok = fc.Double()
+		case 9, 12:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				span, err := unmarshalProtobufBucketSpan(data)
+				if err != nil {
+					return dst, err
+				}
+				if fc.FieldNum == 9 {
+					negativeSpans = append(negativeSpans, span)
+				} else {
+					positiveSpans = append(positiveSpans, span)
+				}
+			}
+		case 10, 13:
+			// Deltas may be split into multiple packed fields, so the first delta in every field
+			// is relative to the last bucket obtained from the previous fields with the same sign.
+			deltasLen := len(deltas)
+			deltas, ok = fc.UnpackSint64s(deltas)
+			if ok {
+				if fc.FieldNum == 10 {
+					negativeBuckets = prompb.AppendBucketsFromDeltas(negativeBuckets, deltas[deltasLen:])
+				} else {
+					positiveBuckets = prompb.AppendBucketsFromDeltas(positiveBuckets, deltas[deltasLen:])
+				}
+			}
+		case 11:
+			negativeBuckets, ok = fc.UnpackDoubles(negativeBuckets)
+		case 14:
+			positiveBuckets, ok = fc.UnpackDoubles(positiveBuckets)
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d in Histogram", fc.FieldNum)
+		}
+	}
+	pc.deltas = deltas
+	pc.negativeBuckets = negativeBuckets
+	pc.positiveBuckets = positiveBuckets
+
+	isNative := len(negativeSpans) > 0 || len(positiveSpans) > 0 || h.ZeroThreshold > 0 || h.ZeroCount > 0
+	if isNative {
+		// Native histogram. Convert it to vmrange buckets.
+		h.NegativeSpans = negativeSpans
+		h.NegativeBuckets = negativeBuckets
+		h.PositiveSpans = positiveSpans
+		h.PositiveBuckets = positiveBuckets
+		var buf []byte
+		h.ForEachBucket(func(lower, upper, count float64) {
+			buf = strconv.AppendFloat(buf[:0], lower, 'g', -1, 64)
+			buf = append(buf, "..."...)
+			buf = strconv.AppendFloat(buf, upper, 'g', -1, 64)
+			dst = appendProtobufSample(dst, name, "_bucket", labels, "vmrange", string(buf), count, timestamp, nil)
+		})
+	} else {
+		// Classic histogram.
+		//
+		// message Bucket {
+		//   uint64 cumulative_count = 1;
+		//   double cumulative_count_float = 4;
+		//   double upper_bound = 2;
+		//   Exemplar exemplar = 3;
+		// }
+		hasInfBucket := false
+		var buf []byte
+		for _, data := range bucketsData {
+			var count, upperBound float64
+			var e *protobufExemplar
+			for len(data) > 0 {
+				var err error
+				data, err = fc.NextField(data)
+				if err != nil {
+					return dst, fmt.Errorf("cannot read the next field in Bucket: %w", err)
+				}
+				ok := true
+				switch fc.FieldNum {
+				case 1:
+					var n uint64
+					n, ok = fc.Uint64()
+					count = float64(n)
+				case 4:
+					count, ok = fc.Double()
+				case 2:
+					upperBound, ok = fc.Double()
+				case 3:
+					var exemplarData []byte
+					exemplarData, ok = fc.MessageData()
+					if ok {
+						e, err = pc.unmarshalExemplar(labels, exemplarData)
+						if err != nil {
+							return dst, err
+						}
+					}
+				}
+				if !ok {
+					return dst, fmt.Errorf("cannot read field #%d in Bucket", fc.FieldNum)
+				}
+			}
+			if math.IsInf(upperBound, 1) {
+				hasInfBucket = true
+			}
+			buf = strconv.AppendFloat(buf[:0], upperBound, 'g', -1, 64)
+			dst = appendProtobufSample(dst, name, "_bucket", labels, "le", string(buf), count, timestamp, e)
+		}
+		if !hasInfBucket {
+			dst = appendProtobufSample(dst, name, "_bucket", labels, "le", "+Inf", h.Count, timestamp, nil)
+		}
+	}
+	dst = appendProtobufSample(dst, name, "_sum", labels, "", "", h.Sum, timestamp, nil)
+	dst = appendProtobufSample(dst, name, "_count", labels, "", "", h.Count, timestamp, nil)
+	return dst, nil
+}
+
+// unmarshalExemplar unmarshals io.prometheus.client.Exemplar from src.
+//
+// The returned exemplar labels are stored in pc.labels after the given metricLabels.
+func (pc *protobufConverter) unmarshalExemplar(metricLabels []protobufLabel, src []byte) (*protobufExemplar, error) {
+	// message Exemplar {
+	//   repeated LabelPair label = 1;
+	//   double value = 2;
+	//   google.protobuf.Timestamp timestamp = 3;
+	// }
+	labels := pc.labels[:len(metricLabels)]
+	var e protobufExemplar
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read the next field in Exemplar: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				labels, err = appendProtobufLabel(labels, data)
+				if err != nil {
+					return nil, fmt.Errorf("cannot unmarshal exemplar LabelPair: %w", err)
+				}
+			}
+		case 2:
+			e.value, ok = fc.Double()
+		case 3:
+			var data []byte
+			data, ok = fc.MessageData()
+			if ok {
+				e.timestamp, err = unmarshalProtobufTimestamp(data)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("cannot read field #%d in Exemplar", fc.FieldNum)
+		}
+	}
+	pc.labels = labels
+	e.labels = labels[len(metricLabels):]
+	if len(e.labels) == 0 {
+		return nil, nil
+	}
+	return &e, nil
+}
+
+// unmarshalProtobufTimestamp returns timestamp in milliseconds from google.protobuf.Timestamp message at src.
+func unmarshalProtobufTimestamp(src []byte) (int64, error) {
+	// message Timestamp {
+	//   int64 seconds = 1;
+	//   int32 nanos = 2;
+	// }
+	var secs int64
+	var nsecs int32
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return 0, fmt.Errorf("cannot read the next field in Timestamp: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			secs, ok = fc.Int64()
+		case 2:
+			nsecs, ok = fc.Int32()
+		}
+		if !ok {
+			return 0, fmt.Errorf("cannot read field #%d in Timestamp", fc.FieldNum)
+		}
+	}
+	return secs*1000 + int64(nsecs)/1e6, nil
+}
+
+func unmarshalProtobufBucketSpan(src []byte) (prompb.BucketSpan, error) {
+	// message BucketSpan {
+	//   sint32 offset = 1;
+	//   uint32 length = 2;
+	// }
+	var span prompb.BucketSpan
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return span, fmt.Errorf("cannot read the next field in BucketSpan: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			span.Offset, ok = fc.Sint32()
+		case 2:
+			span.Length, ok = fc.Uint32()
+		}
+		if !ok {
+			return span, fmt.Errorf("cannot read field #%d in BucketSpan", fc.FieldNum)
+		}
+	}
+	return span, nil
+}
+
+func appendProtobufLabel(dst []protobufLabel, src []byte) ([]protobufLabel, error) {
+	// message LabelPair {
+	//   string name = 1;
+	//   string value = 2;
+	// }
+	var label protobufLabel
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		ok := true
+		switch fc.FieldNum {
+		case 1:
+			label.name, ok = fc.String()
+		case 2:
+			label.value, ok = fc.String()
+		}
+		if !ok {
+			return dst, fmt.Errorf("cannot read field #%d", fc.FieldNum)
+		}
+	}
+	if label.name == "" {
+		return dst, fmt.Errorf("label name cannot be empty")
+	}
+	return append(dst, label), nil
+}
+
+// getProtobufDouble returns double value for the given fieldNum from protobuf message at src.
+func getProtobufDouble(src []byte, fieldNum uint32) (float64, error) {
+	var value float64
+	var fc easyproto.FieldContext
+	for len(src) > 0 {
+		var err error
+		src, err = fc.NextField(src)
+		if err != nil {
+			return 0, fmt.Errorf("cannot read the next field: %w", err)
+		}
+		if fc.FieldNum == fieldNum {
+			v, ok := fc.Double()
+			if !ok {
+				return 0, fmt.Errorf("cannot read field #%d", fieldNum)
+			}
+			value = v
+		}
+	}
+	return value, nil
+}
+
+// appendProtobufSample appends a sample line in Prometheus text exposition format to dst.
+func appendProtobufSample(dst []byte, name, suffix string, labels []protobufLabel, extraName, extraValue string, value float64, timestamp int64, e *protobufExemplar) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, suffix...)
+	dst = appendProtobufLabels(dst, labels, extraName, extraValue)
+	dst = append(dst, ' ')
+	dst = strconv.AppendFloat(dst, value, 'g', -1, 64)
+	if timestamp != 0 {
+		dst = append(dst, ' ')
+		dst = strconv.AppendInt(dst, timestamp, 10)
+	}
+	if e != nil {
+		dst = append(dst, " # "...)
+		dst = appendProtobufLabels(dst, e.labels, "", "")
+		dst = append(dst, ' ')
+		dst = strconv.AppendFloat(dst, e.value, 'g', -1, 64)
+		if e.timestamp != 0 {
+			dst = append(dst, ' ')
+			dst = strconv.AppendInt(dst, e.timestamp, 10)
+		}
+	}
+	return append(dst, '\n')
+}
+
+func appendProtobufLabels(dst []byte, labels []protobufLabel, extraName, extraValue string) []byte {
+	if len(labels) == 0 && extraName == "" {
+		return dst
+	}
+	dst = append(dst, '{')
+	for i, label := range labels {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendProtobufLabelPair(dst, label.name, label.value)
+	}
+	if extraName != "" {
+		if len(labels) > 0 {
+			dst = append(dst, ',')
+		}
+		dst = appendProtobufLabelPair(dst, extraName, extraValue)
+	}
+	return append(dst, '}')
+}
+
+func appendProtobufLabelPair(dst []byte, name, value string) []byte {
+	dst = append(dst, name...)
+	dst = append(dst, `="`...)
+	dst = appendEscapedLabelValue(dst, value)
+	return append(dst, '"')
+}
+
+// appendEscapedLabelValue escapes `\`, `"` and line feed chars in s according to
+// https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#text-format-details
+func appendEscapedLabelValue(dst []byte, s string) []byte {
+	for {
+		n := strings.IndexAny(s, "\\\"\n")
+		if n < 0 {
+			return append(dst, s...)
+		}
+		dst = append(dst, s[:n]...)
+		switch s[n] {
+		case '\\':
+			dst = append(dst, `\\`...)
+		case '"':
+			dst = append(dst, `\"`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		}
+		s = s[n+1:]
+	}
+}
+
+// appendEscapedHelp escapes `\` and line feed chars in s according to
+// https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#comments-help-text-and-type-information
+func appendEscapedHelp(dst []byte, s string) []byte {
+	for {
+		n := strings.IndexAny(s, "\\\n")
+		if n < 0 {
+			return append(dst, s...)
+		}
+		dst = append(dst, s[:n]...)
+		if s[n] == '\\' {
+			dst = append(dst, `\\`...)
+		} else {
+			dst = append(dst, `\n`...)
+		}
+		s = s[n+1:]
+	}
+}
+
+func getProtobufConverter() *protobufConverter {
+	v := protobufConverterPool.Get()
+	if v == nil {
+		return &protobufConverter{}
+	}
+	return v.(*protobufConverter)
+}
+
+func putProtobufConverter(pc *protobufConverter) {
+	pc.reset()
+	protobufConverterPool.Put(pc)
+}
+
+var protobufConverterPool sync.Pool
@@ -0,0 +1,232 @@
+package promscrape
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+)
+
+func marshalMetricFamilies(mfs ...func(mm *easyproto.MessageMarshaler)) []byte {
+	var mp easyproto.MarshalerPool
+	var dst []byte
+	for _, mf := range mfs {
+		m := mp.Get()
+		mf(m.MessageMarshaler())
+		data := m.Marshal(nil)
+		mp.Put(m)
+		dst = binary.AppendUvarint(dst, uint64(len(data)))
+		dst = append(dst, data...)
+	}
+	return dst
+}
+
+func appendTestLabel(mm *easyproto.MessageMarshaler, fieldNum uint32, name, value string) {
+	label := mm.AppendMessage(fieldNum)
+	label.AppendString(1, name)
+	label.AppendString(2, value)
+}
+
+func TestAppendTextFromProtobufSuccess(t *testing.T) {
+	f := func(data []byte, resultExpected string) {
+		t.Helper()
+
+		result, err := appendTextFromProtobuf(nil, data)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+
+		// Verify that the result can be parsed by Prometheus text parser.
+		var rows parser.Rows
+		rows.UnmarshalWithErrLogger(string(result), func(s string) {
+			t.Fatalf("cannot parse the result: %s", s)
+		})
+	}
+
+	// empty data
+	f(nil, "")
+
+	// gauge with labels and timestamp
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		mm.AppendString(2, "Some \\ help\nline")
+		mm.AppendInt32(3, protobufMetricTypeGauge)
+		m := mm.AppendMessage(4)
+		appendTestLabel(m, 1, "job", "bar")
+		appendTestLabel(m, 1, "path", "a\"b\\c\nd")
+		m.AppendMessage(2).AppendDouble(1, 1.5)
+		m.AppendInt64(6, 1700000000123)
+	}), `# HELP foo Some \\ help\nline
+# TYPE foo gauge
+foo{job="bar",path="a\"b\\c\nd"} 1.5 1700000000123
+`)
+
+	// counter with exemplar and unit, untyped
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "requests_total")
+		mm.AppendInt32(3, protobufMetricTypeCounter)
+		mm.AppendString(5, "requests")
+		m := mm.AppendMessage(4)
+		c := m.AppendMessage(3)
+		c.AppendDouble(1, 42)
+		e := c.AppendMessage(2)
+		appendTestLabel(e, 1, "trace_id", "abc")
+		e.AppendDouble(2, 1)
+		ts := e.AppendMessage(3)
+		ts.AppendInt64(1, 1700000000)
+		ts.AppendInt32(2, 500000000)
+	}, func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "bar")
+		mm.AppendInt32(3, protobufMetricTypeUntyped)
+		mm.AppendMessage(4).AppendMessage(5).AppendDouble(1, math.Inf(-1))
+	}), `# TYPE requests_total counter
+# UNIT requests_total requests
+requests_total 42 # {trace_id="abc"} 1 1700000000500
+# TYPE bar untyped
+bar -Inf
+`)
+
+	// summary
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "rpc_duration_seconds")
+		mm.AppendInt32(3, protobufMetricTypeSummary)
+		m := mm.AppendMessage(4)
+		appendTestLabel(m, 1, "service", "a")
+		s := m.AppendMessage(4)
+		s.AppendUint64(1, 10)
+		s.AppendDouble(2, 1.25)
+		q := s.AppendMessage(3)
+		q.AppendDouble(1, 0.5)
+		q.AppendDouble(2, 0.1)
+		q = s.AppendMessage(3)
+		q.AppendDouble(1, 0.99)
+		q.AppendDouble(2, 0.3)
+	}), `# TYPE rpc_duration_seconds summary
+rpc_duration_seconds{service="a",quantile="0.5"} 0.1
+rpc_duration_seconds{service="a",quantile="0.99"} 0.3
+rpc_duration_seconds_sum{service="a"} 1.25
+rpc_duration_seconds_count{service="a"} 10
+`)
+
+	// classic histogram without +Inf bucket
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "latency_seconds")
+		mm.AppendInt32(3, protobufMetricTypeHistogram)
+		m := mm.AppendMessage(4)
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 5)
+		h.AppendDouble(2, 2.5)
+		b := h.AppendMessage(3)
+		b.AppendUint64(1, 2)
+		b.AppendDouble(2, 0.1)
+		b = h.AppendMessage(3)
+		b.AppendUint64(1, 4)
+		b.AppendDouble(2, 1)
+		e := b.AppendMessage(3)
+		appendTestLabel(e, 1, "trace_id", "xyz")
+		e.AppendDouble(2, 0.7)
+	}), `# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 4 # {trace_id="xyz"} 0.7
+latency_seconds_bucket{le="+Inf"} 5
+latency_seconds_sum 2.5
+latency_seconds_count 5
+`)
+
+	// native histogram
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "native_seconds")
+		mm.AppendInt32(3, protobufMetricTypeHistogram)
+		m := mm.AppendMessage(4)
+		appendTestLabel(m, 1, "job", "foo")
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 6)
+		h.AppendDouble(2, 5.5)
+		h.AppendSint32(5, 0)
+		h.AppendDouble(6, 0.001)
+		h.AppendUint64(7, 1)
+		span := h.AppendMessage(12)
+		span.AppendSint32(1, 1)
+		span.AppendUint32(2, 2)
+		h.AppendSint64s(13, []int64{3, -1})
+		span = h.AppendMessage(9)
+		span.AppendSint32(1, 0)
+		span.AppendUint32(2, 1)
+		h.AppendDoubles(11, []float64{1})
+	}), `# TYPE native_seconds histogram
+native_seconds_bucket{job="foo",vmrange="-0.001...0.001"} 1
+native_seconds_bucket{job="foo",vmrange="1...2"} 3
+native_seconds_bucket{job="foo",vmrange="2...4"} 2
+native_seconds_bucket{job="foo",vmrange="-1...-0.5"} 1
+native_seconds_sum{job="foo"} 5.5
+native_seconds_count{job="foo"} 6
+`)
+
+	// native histogram with negative deltas and positive deltas split into multiple fields
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "native_seconds")
+		mm.AppendInt32(3, protobufMetricTypeHistogram)
+		m := mm.AppendMessage(4)
+		h := m.AppendMessage(7)
+		h.AppendUint64(1, 12)
+		h.AppendDouble(2, 20)
+		h.AppendSint32(5, 0)
+		h.AppendDouble(6, 0.001)
+		h.AppendUint64(7, 1)
+		span := h.AppendMessage(9)
+		span.AppendSint32(1, 0)
+		span.AppendUint32(2, 1)
+		h.AppendSint64s(10, []int64{2})
+		span = h.AppendMessage(12)
+		span.AppendSint32(1, 1)
+		span.AppendUint32(2, 3)
+		h.AppendSint64s(13, []int64{3})
+		h.AppendSint64s(13, []int64{-1, 2})
+	}), `# TYPE native_seconds histogram
+native_seconds_bucket{vmrange="-0.001...0.001"} 1
+native_seconds_bucket{vmrange="1...2"} 3
+native_seconds_bucket{vmrange="2...4"} 2
+native_seconds_bucket{vmrange="4...8"} 4
+native_seconds_bucket{vmrange="-1...-0.5"} 2
+native_seconds_sum 20
+native_seconds_count 12
+`)
+}
+
+func TestAppendTextFromProtobufFailure(t *testing.T) {
+	f := func(data []byte) {
+		t.Helper()
+
+		if _, err := appendTextFromProtobuf(nil, data); err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// invalid message length
+	f([]byte{0xff})
+
+	// too short message
+	f([]byte{10, 1, 2})
+
+	// missing metric family name
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendInt32(3, protobufMetricTypeGauge)
+	}))
+
+	// missing label name
+	f(marshalMetricFamilies(func(mm *easyproto.MessageMarshaler) {
+		mm.AppendString(1, "foo")
+		m := mm.AppendMessage(4)
+		appendTestLabel(m, 1, "", "bar")
+		m.AppendMessage(2).AppendDouble(1, 1)
+	}))
+
+	// invalid protobuf message
+	f([]byte{3, 0xff, 0xff, 0xff})
+}
@@ -0,0 +1,97 @@
+package promscrape
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	parser "github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/prometheus"
+)
+
+// scrapeProtocolMediaTypes contains media types for the supported values of `scrape_protocols` option.
+//
+// See https://prometheus.io/docs/prometheus/latest/configuration/configuration/#scrape_config
+var scrapeProtocolMediaTypes = map[string]string{
+	"PrometheusProto":      "application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited",
+	"PrometheusText0.0.4":  "text/plain;version=0.0.4",
+	"OpenMetricsText0.0.1": "application/openmetrics-text;version=0.0.1",
+	"OpenMetricsText1.0.0": "application/openmetrics-text;version=1.0.0",
+}
+
+func validateScrapeProtocols(scrapeProtocols []string) error {
+	for i, sp := range scrapeProtocols {
+		if _, ok := scrapeProtocolMediaTypes[sp]; !ok {
+			return fmt.Errorf("unsupported scrape protocol: %q; supported values: PrometheusProto, PrometheusText0.0.4, OpenMetricsText0.0.1, OpenMetricsText1.0.0", sp)
+		}
+		for _, prevSP := range scrapeProtocols[:i] {
+			if prevSP == sp {
+				return fmt.Errorf("duplicate scrape protocol: %q", sp)
+			}
+		}
+	}
+	return nil
+}
+
+// getAcceptHeader returns `Accept` request header value for the given scrapeProtocols.
+//
+// The preference for every protocol is set via q parameter in the same way as Prometheus does.
+// See https://github.com/prometheus/prometheus/blob/main/scrape/scrape.go
+func getAcceptHeader(scrapeProtocols []string) string {
+	if len(scrapeProtocols) == 0 {
+		// The following `Accept` header has been copied from Prometheus sources.
+		// See https://github.com/prometheus/prometheus/blob/f9d21f10ecd2a343a381044f131ea4e46381ce09/scrape/scrape.go#L532 .
+		// This is needed as a workaround for scraping stupid Java-based servers such as Spring Boot.
+		// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/608 for details.
+		// Do not bloat the `Accept` header with OpenMetrics shit, since it looks like dead standard now.
+		// OpenMetrics and protobuf formats can be requested via `scrape_protocols` option if needed.
+		return "text/plain;version=0.0.4;q=1,*/*;q=0.1"
+	}
+	var b []byte
+	weight := len(scrapeProtocolMediaTypes) + 1
+	for _, sp := range scrapeProtocols {
+		b = append(b, scrapeProtocolMediaTypes[sp]...)
+		b = fmt.Appendf(b, ";q=0.%d,", weight)
+		weight--
+	}
+	b = fmt.Appendf(b, "*/*;q=0.%d", weight)
+	return string(b)
+}
+
+// convertResponseToText converts the response body at dst.B[bodyStart:] to Prometheus text exposition format
+// according to the given contentType of the response.
+func convertResponseToText(dst *bytesutil.ByteBuffer, bodyStart int, contentType string) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Unknown or missing Content-Type. Assume Prometheus text exposition format like Prometheus does.
+		return nil
+	}
+	switch mediaType {
+	case "application/vnd.google.protobuf":
+		if proto := params["proto"]; proto != "io.prometheus.client.MetricFamily" {
+			return fmt.Errorf("unsupported protobuf message in the response: %q; want io.prometheus.client.MetricFamily", proto)
+		}
+		if encoding := params["encoding"]; encoding != "delimited" {
+			return fmt.Errorf("unsupported protobuf encoding in the response: %q; want delimited", encoding)
+		}
+		bb := responseBufPool.Get()
+		defer responseBufPool.Put(bb)
+		bb.B = append(bb.B[:0], dst.B[bodyStart:]...)
+		dst.B, err = appendTextFromProtobuf(dst.B[:bodyStart], bb.B)
+		if err != nil {
+			return fmt.Errorf("cannot parse Prometheus protobuf response: %w", err)
+		}
+	case "application/openmetrics-text":
+		bb := responseBufPool.Get()
+		defer responseBufPool.Put(bb)
+		bb.B = append(bb.B[:0], dst.B[bodyStart:]...)
+		dst.B = parser.AppendOpenMetricsWithoutCreated(dst.B[:bodyStart], bytesutil.ToUnsafeString(bb.B))
+	default:
+		if strings.HasPrefix(mediaType, "application/") && strings.Contains(mediaType, "protobuf") {
+			return fmt.Errorf("unsupported Content-Type in the response: %q", contentType)
+		}
+	}
+	return nil
+}
+
+var responseBufPool bytesutil.ByteBufferPool
@@ -0,0 +1,129 @@
+package promscrape
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/easyproto"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+)
+
+func TestGetAcceptHeader(t *testing.T) {
+	f := func(scrapeProtocols []string, resultExpected string) {
+		t.Helper()
+
+		result := getAcceptHeader(scrapeProtocols)
+		if result != resultExpected {
+			t.Fatalf("unexpected Accept header\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f(nil, "text/plain;version=0.0.4;q=1,*/*;q=0.1")
+	f([]string{"OpenMetricsText1.0.0"}, "application/openmetrics-text;version=1.0.0;q=0.5,*/*;q=0.4")
+	f([]string{"PrometheusProto", "OpenMetricsText1.0.0", "PrometheusText0.0.4"},
+		"application/vnd.google.protobuf;proto=io.prometheus.client.MetricFamily;encoding=delimited;q=0.5,"+
+			"application/openmetrics-text;version=1.0.0;q=0.4,text/plain;version=0.0.4;q=0.3,*/*;q=0.2")
+}
+
+func TestValidateScrapeProtocols(t *testing.T) {
+	f := func(scrapeProtocols []string, isValidExpected bool) {
+		t.Helper()
+
+		err := validateScrapeProtocols(scrapeProtocols)
+		if isValid := err == nil; isValid != isValidExpected {
+			t.Fatalf("unexpected validation result for %q; got %v; want %v; err: %v", scrapeProtocols, isValid, isValidExpected, err)
+		}
+	}
+
+	f(nil, true)
+	f([]string{"PrometheusProto", "PrometheusText0.0.4", "OpenMetricsText0.0.1", "OpenMetricsText1.0.0"}, true)
+
+	// unsupported protocol
+	f([]string{"foobar"}, false)
+	f([]string{"prometheusproto"}, false)
+
+	// duplicate protocol
+	f([]string{"PrometheusProto", "OpenMetricsText1.0.0", "PrometheusProto"}, false)
+}
+
+func TestClientReadDataContentNegotiation(t *testing.T) {
+	var mp easyproto.MarshalerPool
+	m := mp.Get()
+	mm := m.MessageMarshaler()
+	mm.AppendString(1, "foo")
+	mm.AppendInt32(3, protobufMetricTypeGauge)
+	mm.AppendMessage(4).AppendMessage(2).AppendDouble(1, 123)
+	mf := m.Marshal(nil)
+	mp.Put(m)
+	protobufData := binary.AppendUvarint(nil, uint64(len(mf)))
+	protobufData = append(protobufData, mf...)
+
+	f := func(scrapeProtocols []string, contentType string, body []byte, resultExpected string) {
+		t.Helper()
+
+		acceptHeaderExpected := getAcceptHeader(scrapeProtocols)
+		s := newClientTestServer(false, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if accept := r.Header.Get("Accept"); accept != acceptHeaderExpected {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprintf(w, "unexpected Accept header: %q; want %q", accept, acceptHeaderExpected)
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write(body)
+		}))
+		defer s.Close()
+
+		c, err := newClient(context.Background(), &ScrapeWork{
+			ScrapeURL:       s.URL,
+			ScrapeInterval:  time.Second,
+			ScrapeTimeout:   time.Second,
+			MaxScrapeSize:   16000,
+			AuthConfig:      newTestAuthConfig(t, false, nil),
+			ScrapeProtocols: scrapeProtocols,
+		})
+		if err != nil {
+			t.Fatalf("cannot create client: %s", err)
+		}
+		var bb bytesutil.ByteBuffer
+		bb.B = append(bb.B, "prefix\n"...)
+		if err := c.ReadData(&bb); err != nil {
+			t.Fatalf("unexpected error at ReadData: %s", err)
+		}
+		if result := string(bb.B); result != "prefix\n"+resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, "prefix\n"+resultExpected)
+		}
+	}
+
+	// Prometheus text format
+	f(nil, "text/plain; version=0.0.4", []byte("foo 123\n"), "foo 123\n")
+	f(nil, "", []byte("foo 123\n"), "foo 123\n")
+
+	// OpenMetrics format
+	f([]string{"OpenMetricsText1.0.0"}, "application/openmetrics-text; version=1.0.0; charset=utf-8",
+		[]byte("# TYPE foo counter\nfoo_total 123\nfoo_created 1700000000\n# EOF\n"), "# TYPE foo counter\nfoo_total 123\n# EOF\n")
+
+	// Prometheus protobuf format
+	f([]string{"PrometheusProto", "PrometheusText0.0.4"}, "application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited",
+		protobufData, "# TYPE foo gauge\nfoo 123\n")
+}
+
+func TestConvertResponseToTextFailure(t *testing.T) {
+	f := func(contentType string) {
+		t.Helper()
+
+		var bb bytesutil.ByteBuffer
+		bb.B = append(bb.B, "foo"...)
+		if err := convertResponseToText(&bb, 0, contentType); err == nil {
+			t.Fatalf("expecting non-nil error for Content-Type %q", contentType)
+		}
+	}
+
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.Metric; encoding=delimited")
+	f("application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=text")
+	f("application/x-protobuf")
+}
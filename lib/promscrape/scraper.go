@@ -454,13 +454,21 @@ func newScraper(sw *ScrapeWork, group string, pushData func(at *auth.Token, wr *
 		cancel:    cancel,
 		stoppedCh: make(chan struct{}),
 	}
-	c, err := newClient(ctx, sw)
-	if err != nil {
-		return nil, err
-	}
 	sc.sw.Config = sw
 	sc.sw.ScrapeGroup = group
-	sc.sw.ReadData = c.ReadData
+	if sw.ProbeConfig != nil {
+		p, err := newProber(ctx, sw)
+		if err != nil {
+			return nil, err
+		}
+		sc.sw.ReadData = p.ReadData
+	} else {
+		c, err := newClient(ctx, sw)
+		if err != nil {
+			return nil, err
+		}
+		sc.sw.ReadData = c.ReadData
+	}
 	sc.sw.PushData = pushData
 	return sc, nil
 }
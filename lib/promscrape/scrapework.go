@@ -37,6 +37,8 @@ var (
 		"See also -promscrape.suppressScrapeErrorsDelay")
 	suppressScrapeErrorsDelay = flag.Duration("promscrape.suppressScrapeErrorsDelay", 0, "The delay for suppressing repeated scrape errors logging per each scrape targets. "+
 		"This may be used for reducing the number of log lines related to scrape errors. See also -promscrape.suppressScrapeErrors")
+	disableMetadata = flag.Bool("promscrape.disableMetadata", false, "Whether to disable sending metric metadata obtained from '# HELP', '# TYPE' and '# UNIT' comments "+
+		"in scrape responses to remote storage. See https://docs.victoriametrics.com/#metric-metadata")
 	minResponseSizeForStreamParse = flagutil.NewBytes("promscrape.minResponseSizeForStreamParse", 1e6, "The minimum target response size for automatic switching to stream parsing mode, which can reduce memory usage. See https://docs.victoriametrics.com/vmagent/#stream-parsing-mode")
 )
 
@@ -143,6 +145,16 @@ type ScrapeWork struct {
 	// See https://docs.victoriametrics.com/vmagent/#prometheus-staleness-markers
 	NoStaleMarkers bool
 
+	// Optional `probe_config`.
+	//
+	// If it is set, then the target is probed instead of scraping its metrics.
+	ProbeConfig *ProbeConfig
+
+	// Optional `scrape_protocols` in the order of preference.
+	//
+	// It is used for building `Accept` request header when scraping the target.
+	ScrapeProtocols []string
+
 	// The Tenant Info
 	AuthToken *auth.Token
 
@@ -167,12 +179,12 @@ func (sw *ScrapeWork) key() string {
 		"HonorTimestamps=%v, DenyRedirects=%v, Labels=%s, ExternalLabels=%s, MaxScrapeSize=%d, "+
 		"ProxyURL=%s, ProxyAuthConfig=%s, AuthConfig=%s, MetricRelabelConfigs=%q, "+
 		"SampleLimit=%d, DisableCompression=%v, DisableKeepAlive=%v, StreamParse=%v, "+
-		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v",
+		"ScrapeAlignInterval=%s, ScrapeOffset=%s, SeriesLimit=%d, NoStaleMarkers=%v, ProbeConfig=%s, ScrapeProtocols=%s",
 		sw.jobNameOriginal, sw.ScrapeURL, sw.ScrapeInterval, sw.ScrapeTimeout, sw.HonorLabels,
 		sw.HonorTimestamps, sw.DenyRedirects, sw.Labels.String(), sw.ExternalLabels.String(), sw.MaxScrapeSize,
 		sw.ProxyURL.String(), sw.ProxyAuthConfig.String(), sw.AuthConfig.String(), sw.MetricRelabelConfigs.String(),
 		sw.SampleLimit, sw.DisableCompression, sw.DisableKeepAlive, sw.StreamParse,
-		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers)
+		sw.ScrapeAlignInterval, sw.ScrapeOffset, sw.SeriesLimit, sw.NoStaleMarkers, sw.ProbeConfig.String(), sw.ScrapeProtocols)
 	return key
 }
 
@@ -232,6 +244,9 @@ type scrapeWork struct {
 
 	// successRequestsCount is the number of success requests during the last suppressScrapeErrorsDelay
 	successRequestsCount int
+
+	// lastMetadataPushTime is the timestamp in milliseconds when metric metadata has been pushed for the last time.
+	lastMetadataPushTime int64
 }
 
 func (sw *scrapeWork) loadLastScrape() string {
@@ -510,6 +525,9 @@ func (sw *scrapeWork) processDataOneShot(scrapeTimestamp, realTimestamp int64, b
 	}
 	sw.addAutoMetrics(am, wc, scrapeTimestamp)
 	sw.pushData(sw.Config.AuthToken, &wc.writeRequest)
+	if up == 1 {
+		sw.pushMetadata(bodyString, realTimestamp)
+	}
 	sw.prevLabelsLen = len(wc.labels)
 	sw.prevBodyLen = responseSize
 	wc.reset()
@@ -592,6 +610,9 @@ func (sw *scrapeWork) processDataInStreamMode(scrapeTimestamp, realTimestamp int
 	}
 	sw.addAutoMetrics(am, wc, scrapeTimestamp)
 	sw.pushData(sw.Config.AuthToken, &wc.writeRequest)
+	if up == 1 {
+		sw.pushMetadata(bodyString, realTimestamp)
+	}
 	sw.prevLabelsLen = len(wc.labels)
 	sw.prevBodyLen = responseSize
 	wc.reset()
@@ -615,6 +636,65 @@ func (sw *scrapeWork) pushData(at *auth.Token, wr *prompbmarshal.WriteRequest) {
 	pushDataDuration.UpdateDuration(startTime)
 }
 
+// metadataPushInterval is the interval for pushing metric metadata per each scrape target.
+const metadataPushInterval = time.Minute
+
+// pushMetadata pushes metric metadata obtained from the given scrape response body.
+//
+// Metadata is pushed at most once per metadataPushInterval, since it rarely changes.
+// metric_relabel_configs are applied to metric family names in the same way as to the scraped metrics,
+// so metadata isn't pushed for dropped metrics, while it is pushed under the new name for renamed metrics.
+func (sw *scrapeWork) pushMetadata(body string, realTimestamp int64) {
+	if *disableMetadata || realTimestamp-sw.lastMetadataPushTime < metadataPushInterval.Milliseconds() {
+		return
+	}
+	sw.lastMetadataPushTime = realTimestamp
+
+	mds := parser.AppendMetadata(nil, body)
+	if len(mds) == 0 {
+		return
+	}
+	mms := make([]prompbmarshal.MetricMetadata, 0, len(mds))
+	var labels []prompbmarshal.Label
+	for i := range mds {
+		md := &mds[i]
+		metricFamilyName := md.Metric
+		if sw.Config.MetricRelabelConfigs.Len() > 0 {
+			labels = sw.relabelMetricFamilyName(labels[:0], metricFamilyName)
+			nameLabel := promrelabel.GetLabelByName(labels, "__name__")
+			if nameLabel == nil || nameLabel.Value == "" {
+				// The metric is dropped by metric_relabel_configs.
+				continue
+			}
+			metricFamilyName = nameLabel.Value
+		}
+		mms = append(mms, prompbmarshal.MetricMetadata{
+			Type:             prompbmarshal.ParseMetricMetadataType(md.Type),
+			MetricFamilyName: metricFamilyName,
+			Help:             md.Help,
+			Unit:             md.Unit,
+		})
+	}
+	if len(mms) == 0 {
+		return
+	}
+	wr := &prompbmarshal.WriteRequest{
+		Metadata: mms,
+	}
+	sw.pushData(sw.Config.AuthToken, wr)
+}
+
+// relabelMetricFamilyName appends labels for the metric with the given metricFamilyName name after applying metric_relabel_configs to dst.
+//
+// Target labels are taken into account during the relabeling, since metric_relabel_configs may refer to them.
+func (sw *scrapeWork) relabelMetricFamilyName(dst []prompbmarshal.Label, metricFamilyName string) []prompbmarshal.Label {
+	dstLen := len(dst)
+	targetLabels := sw.Config.Labels.GetLabels()
+	dst = appendLabels(dst, metricFamilyName, nil, targetLabels, sw.Config.HonorLabels)
+	dst = sw.Config.MetricRelabelConfigs.Apply(dst, dstLen)
+	return promrelabel.FinalizeLabels(dst[:dstLen], dst[dstLen:])
+}
+
 func (sw *scrapeWork) areIdenticalSeries(prevData, currData string) bool {
 	if sw.Config.NoStaleMarkers && sw.Config.SeriesLimit <= 0 {
 		// Do not spend CPU time on tracking the changes in series if stale markers are disabled.
@@ -675,6 +755,9 @@ type writeRequestCtx struct {
 	writeRequest prompbmarshal.WriteRequest
 	labels       []prompbmarshal.Label
 	samples      []prompbmarshal.Sample
+
+	exemplars      []prompbmarshal.Exemplar
+	exemplarLabels []prompbmarshal.Label
 }
 
 func (wc *writeRequestCtx) reset() {
@@ -689,6 +772,36 @@ func (wc *writeRequestCtx) resetNoRows() {
 	wc.labels = wc.labels[:0]
 
 	wc.samples = wc.samples[:0]
+
+	clear(wc.exemplars)
+	wc.exemplars = wc.exemplars[:0]
+
+	clear(wc.exemplarLabels)
+	wc.exemplarLabels = wc.exemplarLabels[:0]
+}
+
+// appendExemplar appends e to wc and returns a slice with the appended exemplar.
+//
+// defaultTimestamp is used if e has no timestamp.
+func (wc *writeRequestCtx) appendExemplar(e *parser.Exemplar, defaultTimestamp int64) []prompbmarshal.Exemplar {
+	labelsLen := len(wc.exemplarLabels)
+	for i := range e.Tags {
+		tag := &e.Tags[i]
+		wc.exemplarLabels = append(wc.exemplarLabels, prompbmarshal.Label{
+			Name:  tag.Key,
+			Value: tag.Value,
+		})
+	}
+	timestamp := e.Timestamp
+	if timestamp == 0 {
+		timestamp = defaultTimestamp
+	}
+	wc.exemplars = append(wc.exemplars, prompbmarshal.Exemplar{
+		Labels:    wc.exemplarLabels[labelsLen:],
+		Value:     e.Value,
+		Timestamp: timestamp,
+	})
+	return wc.exemplars[len(wc.exemplars)-1:]
 }
 
 var writeRequestCtxPool leveledWriteRequestCtxPool
@@ -921,10 +1034,15 @@ func (sw *scrapeWork) addRowToTimeseries(wc *writeRequestCtx, r *parser.Row, tim
 		Value:     r.Value,
 		Timestamp: sampleTimestamp,
 	})
+	var exemplars []prompbmarshal.Exemplar
+	if r.HasExemplar() {
+		exemplars = wc.appendExemplar(&r.Exemplar, sampleTimestamp)
+	}
 	wr := &wc.writeRequest
 	wr.Timeseries = append(wr.Timeseries, prompbmarshal.TimeSeries{
-		Labels:  wc.labels[labelsLen:],
-		Samples: wc.samples[len(wc.samples)-1:],
+		Labels:    wc.labels[labelsLen:],
+		Samples:   wc.samples[len(wc.samples)-1:],
+		Exemplars: exemplars,
 	})
 }
 
@@ -2,6 +2,7 @@ package promscrape
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -490,6 +491,89 @@ func TestScrapeWorkScrapeInternalSuccess(t *testing.T) {
 	`)
 }
 
+func TestScrapeWorkPushMetadata(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{}
+	var mms []prompbmarshal.MetricMetadata
+	sw.PushData = func(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+		if len(wr.Timeseries) > 0 {
+			t.Fatalf("unexpected time series pushed with metadata: %v", wr.Timeseries)
+		}
+		mms = append(mms, wr.Metadata...)
+	}
+	body := `# HELP foo Foo help
+# TYPE foo counter
+foo 1
+# TYPE bar gauge
+bar 2
+`
+	sw.pushMetadata(body, 120000)
+	mmsExpected := []prompbmarshal.MetricMetadata{
+		{
+			Type:             prompbmarshal.MetricMetadataCOUNTER,
+			MetricFamilyName: "foo",
+			Help:             "Foo help",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataGAUGE,
+			MetricFamilyName: "bar",
+		},
+	}
+	if !reflect.DeepEqual(mms, mmsExpected) {
+		t.Fatalf("unexpected metadata pushed;\ngot\n%+v\nwant\n%+v", mms, mmsExpected)
+	}
+
+	// Metadata mustn't be pushed more frequently than metadataPushInterval.
+	mms = nil
+	sw.pushMetadata(body, 150000)
+	if len(mms) > 0 {
+		t.Fatalf("unexpected metadata pushed before metadataPushInterval: %+v", mms)
+	}
+	sw.pushMetadata(body, 180000)
+	if !reflect.DeepEqual(mms, mmsExpected) {
+		t.Fatalf("unexpected metadata pushed after metadataPushInterval;\ngot\n%+v\nwant\n%+v", mms, mmsExpected)
+	}
+}
+
+func TestScrapeWorkPushMetadataRelabeling(t *testing.T) {
+	var sw scrapeWork
+	sw.Config = &ScrapeWork{
+		Labels: promutils.NewLabelsFromMap(map[string]string{
+			"job": "foo",
+		}),
+		MetricRelabelConfigs: mustParseRelabelConfigs(`
+- action: drop
+  source_labels: [__name__]
+  regex: "bar"
+- source_labels: [job, __name__]
+  regex: "foo;(.+)"
+  target_label: __name__
+  replacement: "job_foo:$1"
+`),
+	}
+	var mms []prompbmarshal.MetricMetadata
+	sw.PushData = func(_ *auth.Token, wr *prompbmarshal.WriteRequest) {
+		mms = append(mms, wr.Metadata...)
+	}
+	body := `# HELP foo Foo help
+# TYPE foo counter
+foo 1
+# TYPE bar gauge
+bar 2
+`
+	sw.pushMetadata(body, 120000)
+	mmsExpected := []prompbmarshal.MetricMetadata{
+		{
+			Type:             prompbmarshal.MetricMetadataCOUNTER,
+			MetricFamilyName: "job_foo:foo",
+			Help:             "Foo help",
+		},
+	}
+	if !reflect.DeepEqual(mms, mmsExpected) {
+		t.Fatalf("unexpected metadata pushed;\ngot\n%+v\nwant\n%+v", mms, mmsExpected)
+	}
+}
+
 func TestAddRowToTimeseriesNoRelabeling(t *testing.T) {
 	f := func(row string, cfg *ScrapeWork, dataExpected string) {
 		t.Helper()
@@ -26,6 +26,10 @@ var maxDroppedTargets = flag.Int("promscrape.maxDroppedTargets", 10000, "The max
 	"Increase this value if your setup drops more scrape targets during relabeling and you need investigating labels for all the dropped targets. "+
 	"Note that the increased number of tracked dropped targets may result in increased memory usage")
 
+var targetHistorySize = flag.Int("promscrape.targetHistorySize", 10, "The number of recent scrape results to keep per each scrape target. "+
+	"The scrape history is shown at /targets, /api/v1/targets and /target_history pages. It may help investigating flapping targets. "+
+	"Note that the increased number of tracked scrape results may result in increased memory usage. Set it to 0 for disabling scrape history")
+
 var tsmGlobal = newTargetStatusMap()
 
 // WriteTargetResponse serves requests to /target_response?id=<id>
@@ -46,6 +50,38 @@ func WriteTargetResponse(w http.ResponseWriter, r *http.Request) error {
 	return err
 }
 
+// WriteTargetHistory serves requests to /target_history?target=<target>
+//
+// The target may contain target id, scrape url or the value of `instance` label.
+// It writes the recent scrape results for all the matching targets in JSON.
+func WriteTargetHistory(w http.ResponseWriter, r *http.Request) error {
+	target := r.FormValue("target")
+	if target == "" {
+		return fmt.Errorf("missing `target` query arg")
+	}
+	tss := tsmGlobal.getTargetStatusesByTarget(target)
+	if len(tss) == 0 {
+		return fmt.Errorf("cannot find target %q", target)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"status":"success","data":[`)
+	for i := range tss {
+		ts := &tss[i]
+		fmt.Fprintf(w, `{"labels":`)
+		writeLabelsJSON(w, ts.sw.Config.Labels)
+		fmt.Fprintf(w, `,"scrapePool":%s`, stringsutil.JSONString(ts.sw.Config.Job()))
+		fmt.Fprintf(w, `,"scrapeUrl":%s`, stringsutil.JSONString(ts.sw.Config.ScrapeURL))
+		fmt.Fprintf(w, `,"health":%s`, stringsutil.JSONString(ts.getHealth()))
+		writeScrapeHistoryJSON(w, ts)
+		fmt.Fprintf(w, `}`)
+		if i+1 < len(tss) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]}`)
+	return nil
+}
+
 // WriteHumanReadableTargetsStatus writes human-readable status for all the scrape targets to w according to r.
 func WriteHumanReadableTargetsStatus(w http.ResponseWriter, r *http.Request) {
 	filter := getRequestFilter(r)
@@ -204,9 +240,38 @@ func (tsm *targetStatusMap) Update(sw *scrapeWork, up bool, scrapeTime, scrapeDu
 		ts.scrapesFailed++
 	}
 	ts.err = err
+	if *targetHistorySize > 0 {
+		ts.history.add(scrapeHistoryEntry{
+			up:                 up,
+			scrapeTime:         scrapeTime,
+			scrapeDuration:     scrapeDuration,
+			scrapeResponseSize: scrapeResponseSize,
+			samplesScraped:     samplesScraped,
+			err:                errorString(err),
+		}, *targetHistorySize)
+	}
 	tsm.mu.Unlock()
 }
 
+// getTargetStatusesByTarget returns statuses for targets with the given target id, scrape url or `instance` label value.
+func (tsm *targetStatusMap) getTargetStatusesByTarget(target string) []targetStatus {
+	tsm.mu.Lock()
+	var tss []targetStatus
+	for sw, ts := range tsm.m {
+		if getLabelsID(sw.Config.OriginalLabels) == target || sw.Config.ScrapeURL == target || sw.Config.Labels.Get("instance") == target {
+			tss = append(tss, ts.snapshot())
+		}
+	}
+	tsm.mu.Unlock()
+	sort.Slice(tss, func(i, j int) bool {
+		if tss[i].sw.Config.jobNameOriginal != tss[j].sw.Config.jobNameOriginal {
+			return tss[i].sw.Config.jobNameOriginal < tss[j].sw.Config.jobNameOriginal
+		}
+		return tss[i].sw.Config.ScrapeURL < tss[j].sw.Config.ScrapeURL
+	})
+	return tss
+}
+
 func (tsm *targetStatusMap) getScrapeWorkByTargetID(targetID string) *scrapeWork {
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
@@ -241,7 +306,7 @@ func (tsm *targetStatusMap) getActiveTargetStatuses() []targetStatus {
 	tsm.mu.Lock()
 	tss := make([]targetStatus, 0, len(tsm.m))
 	for _, ts := range tsm.m {
-		tss = append(tss, *ts)
+		tss = append(tss, ts.snapshot())
 	}
 	tsm.mu.Unlock()
 	// Sort discovered targets by __address__ label, so they stay in consistent order across calls
@@ -272,11 +337,8 @@ func (tsm *targetStatusMap) WriteActiveTargetsJSON(w io.Writer) {
 		fmt.Fprintf(w, `,"lastScrape":"%s"`, time.Unix(ts.scrapeTime/1000, (ts.scrapeTime%1000)*1e6).Format(time.RFC3339Nano))
 		fmt.Fprintf(w, `,"lastScrapeDuration":%g`, (time.Millisecond * time.Duration(ts.scrapeDuration)).Seconds())
 		fmt.Fprintf(w, `,"lastSamplesScraped":%d`, ts.samplesScraped)
-		state := "up"
-		if !ts.up {
-			state = "down"
-		}
-		fmt.Fprintf(w, `,"health":%s}`, stringsutil.JSONString(state))
+		writeScrapeHistoryJSON(w, &ts)
+		fmt.Fprintf(w, `,"health":%s}`, stringsutil.JSONString(ts.getHealth()))
 		if i+1 < len(tss) {
 			fmt.Fprintf(w, `,`)
 		}
@@ -296,6 +358,27 @@ func writeLabelsJSON(w io.Writer, labels *promutils.Labels) {
 	fmt.Fprintf(w, `}`)
 }
 
+// writeScrapeHistoryJSON writes scrape history fields for ts to w.
+func writeScrapeHistoryJSON(w io.Writer, ts *targetStatus) {
+	fmt.Fprintf(w, `,"scrapeHistory":[`)
+	entries := ts.history.entries
+	for i := range entries {
+		e := &entries[i]
+		fmt.Fprintf(w, `{"timestamp":"%s"`, time.UnixMilli(e.scrapeTime).UTC().Format(time.RFC3339Nano))
+		fmt.Fprintf(w, `,"health":%s`, stringsutil.JSONString(e.getHealth()))
+		fmt.Fprintf(w, `,"duration":%g`, (time.Millisecond * time.Duration(e.scrapeDuration)).Seconds())
+		fmt.Fprintf(w, `,"samplesScraped":%d`, e.samplesScraped)
+		fmt.Fprintf(w, `,"responseSize":%d`, e.scrapeResponseSize)
+		fmt.Fprintf(w, `,"error":%s}`, stringsutil.JSONString(e.err))
+		if i+1 < len(entries) {
+			fmt.Fprintf(w, `,`)
+		}
+	}
+	fmt.Fprintf(w, `]`)
+	fmt.Fprintf(w, `,"stateChanges":%d`, ts.history.getStateChanges())
+	fmt.Fprintf(w, `,"flapping":%v`, ts.isFlapping())
+}
+
 type targetStatus struct {
 	sw                 *scrapeWork
 	up                 bool
@@ -306,6 +389,31 @@ type targetStatus struct {
 	scrapesTotal       int
 	scrapesFailed      int
 	err                error
+
+	// history contains the recent scrape results for the target.
+	history scrapeHistory
+}
+
+// snapshot returns a copy of ts, which can be used without holding targetStatusMap.mu.
+func (ts *targetStatus) snapshot() targetStatus {
+	tsCopy := *ts
+	tsCopy.history = ts.history.clone()
+	return tsCopy
+}
+
+func (ts *targetStatus) getHealth() string {
+	if ts.up {
+		return "up"
+	}
+	return "down"
+}
+
+// flappingStateChanges is the minimum number of up/down state changes in the scrape history for the flapping target.
+const flappingStateChanges = 3
+
+// isFlapping returns true if the target changed its state at least flappingStateChanges times during the recent scrapes.
+func (ts *targetStatus) isFlapping() bool {
+	return ts.history.getStateChanges() >= flappingStateChanges
 }
 
 func (ts *targetStatus) getDurationFromLastScrape() string {
@@ -323,6 +431,82 @@ func (ts *targetStatus) getSizeFromLastScrape() string {
 	return fmt.Sprintf("%.3fKiB", float64(ts.scrapeResponseSize)/1024)
 }
 
+// scrapeHistory is a ring buffer with the recent scrape results for the target.
+type scrapeHistory struct {
+	// entries contains scrape results. The oldest entry is located at entries[next] if entries is full.
+	entries []scrapeHistoryEntry
+
+	// next is the position in entries for the next scrape result if entries is full.
+	next int
+}
+
+type scrapeHistoryEntry struct {
+	up                 bool
+	scrapeTime         int64
+	scrapeDuration     int64
+	scrapeResponseSize int
+	samplesScraped     int
+	err                string
+}
+
+func (e *scrapeHistoryEntry) getHealth() string {
+	if e.up {
+		return "up"
+	}
+	return "down"
+}
+
+func (e *scrapeHistoryEntry) getTitle() string {
+	s := fmt.Sprintf("%s: %s, duration=%dms, samples=%d, size=%.3fKiB", time.UnixMilli(e.scrapeTime).UTC().Format(time.RFC3339),
+		e.getHealth(), e.scrapeDuration, e.samplesScraped, float64(e.scrapeResponseSize)/1024)
+	if e.err != "" {
+		s += ", error=" + e.err
+	}
+	return s
+}
+
+// add adds e to sh. The oldest entry is dropped if sh already contains maxEntries entries.
+func (sh *scrapeHistory) add(e scrapeHistoryEntry, maxEntries int) {
+	if len(sh.entries) < maxEntries {
+		sh.entries = append(sh.entries, e)
+		return
+	}
+	sh.entries[sh.next] = e
+	sh.next++
+	if sh.next >= len(sh.entries) {
+		sh.next = 0
+	}
+}
+
+// clone returns a copy of sh with entries sorted from the oldest to the newest.
+func (sh *scrapeHistory) clone() scrapeHistory {
+	entries := make([]scrapeHistoryEntry, 0, len(sh.entries))
+	entries = append(entries, sh.entries[sh.next:]...)
+	entries = append(entries, sh.entries[:sh.next]...)
+	return scrapeHistory{
+		entries: entries,
+	}
+}
+
+// getStateChanges returns the number of up/down state changes in sh.
+func (sh *scrapeHistory) getStateChanges() int {
+	n := 0
+	entries := sh.entries
+	for i := 1; i < len(entries); i++ {
+		if entries[(sh.next+i)%len(entries)].up != entries[(sh.next+i-1)%len(entries)].up {
+			n++
+		}
+	}
+	return n
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
 type droppedTargets struct {
 	mu sync.Mutex
 	m  map[uint64]droppedTarget
@@ -454,7 +638,7 @@ func (tsm *targetStatusMap) getTargetsStatusByJob(filter *requestFilter) *target
 		if filter.originalJobName != "" && jobName != filter.originalJobName {
 			continue
 		}
-		byJob[jobName] = append(byJob[jobName], *ts)
+		byJob[jobName] = append(byJob[jobName], ts.snapshot())
 	}
 	jobNames := append([]string{}, tsm.jobNames...)
 	tsm.mu.Unlock()
@@ -0,0 +1,127 @@
+package promscrape
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+func TestScrapeHistory(t *testing.T) {
+	f := func(states []bool, maxEntries int, statesExpected []bool, stateChangesExpected int) {
+		t.Helper()
+
+		var sh scrapeHistory
+		for i, up := range states {
+			sh.add(scrapeHistoryEntry{
+				up:         up,
+				scrapeTime: int64(i),
+			}, maxEntries)
+		}
+		if n := sh.getStateChanges(); n != stateChangesExpected {
+			t.Fatalf("unexpected number of state changes; got %d; want %d", n, stateChangesExpected)
+		}
+
+		// Verify that clone returns entries from the oldest to the newest.
+		shCopy := sh.clone()
+		var result []bool
+		for i, e := range shCopy.entries {
+			if i > 0 && e.scrapeTime <= shCopy.entries[i-1].scrapeTime {
+				t.Fatalf("unexpected order of entries: %v", shCopy.entries)
+			}
+			result = append(result, e.up)
+		}
+		if !reflect.DeepEqual(result, statesExpected) {
+			t.Fatalf("unexpected states; got %v; want %v", result, statesExpected)
+		}
+		if n := shCopy.getStateChanges(); n != stateChangesExpected {
+			t.Fatalf("unexpected number of state changes for the cloned history; got %d; want %d", n, stateChangesExpected)
+		}
+	}
+
+	f(nil, 3, nil, 0)
+	f([]bool{true}, 3, []bool{true}, 0)
+	f([]bool{true, false}, 3, []bool{true, false}, 1)
+	f([]bool{true, false, true}, 3, []bool{true, false, true}, 2)
+
+	// The oldest entries must be dropped
+	f([]bool{false, false, true, true, true}, 3, []bool{true, true, true}, 0)
+	f([]bool{true, true, false, true, false, true}, 4, []bool{false, true, false, true}, 3)
+	f([]bool{true, false, true, false, true, false, true}, 3, []bool{true, false, true}, 2)
+}
+
+func TestTargetStatusIsFlapping(t *testing.T) {
+	f := func(states []bool, resultExpected bool) {
+		t.Helper()
+
+		var ts targetStatus
+		for _, up := range states {
+			ts.history.add(scrapeHistoryEntry{
+				up: up,
+			}, 10)
+		}
+		if result := ts.isFlapping(); result != resultExpected {
+			t.Fatalf("unexpected isFlapping result for states %v; got %v; want %v", states, result, resultExpected)
+		}
+	}
+
+	f(nil, false)
+	f([]bool{true, true, true}, false)
+	f([]bool{true, false, false, false}, false)
+	f([]bool{true, false, true}, false)
+	f([]bool{true, false, true, false}, true)
+	f([]bool{false, true, true, false, false, true}, true)
+}
+
+func TestWriteTargetHistory(t *testing.T) {
+	originalLabels := promutils.NewLabelsFromMap(map[string]string{
+		"__address__": "foo:1234",
+	})
+	sw := &scrapeWork{
+		Config: &ScrapeWork{
+			ScrapeURL:      "http://foo:1234/metrics",
+			OriginalLabels: originalLabels,
+			Labels: promutils.NewLabelsFromMap(map[string]string{
+				"instance": "foo:1234",
+				"job":      "bar",
+			}),
+			jobNameOriginal: "bar",
+		},
+	}
+	tsmGlobal.Register(sw)
+	defer tsmGlobal.Unregister(sw)
+
+	tsmGlobal.Update(sw, true, 1700000000000, 120, 2048, 10, nil)
+	tsmGlobal.Update(sw, false, 1700000010000, 5000, 0, 0, fmt.Errorf("timeout"))
+
+	f := func(target, responseExpected string) {
+		t.Helper()
+
+		r := httptest.NewRequest("GET", "/target_history?target="+target, nil)
+		w := httptest.NewRecorder()
+		if err := WriteTargetHistory(w, r); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if response := w.Body.String(); response != responseExpected {
+			t.Fatalf("unexpected response\ngot\n%s\nwant\n%s", response, responseExpected)
+		}
+	}
+
+	responseExpected := `{"status":"success","data":[{"labels":{"instance":"foo:1234","job":"bar"},"scrapePool":"bar","scrapeUrl":"http://foo:1234/metrics","health":"down",` +
+		`"scrapeHistory":[` +
+		`{"timestamp":"2023-11-14T22:13:20Z","health":"up","duration":0.12,"samplesScraped":10,"responseSize":2048,"error":""},` +
+		`{"timestamp":"2023-11-14T22:13:30Z","health":"down","duration":5,"samplesScraped":0,"responseSize":0,"error":"timeout"}` +
+		`],"stateChanges":1,"flapping":false}]}`
+	f(getLabelsID(originalLabels), responseExpected)
+	f("http://foo:1234/metrics", responseExpected)
+	f("foo:1234", responseExpected)
+
+	// missing target
+	r := httptest.NewRequest("GET", "/target_history?target=missing", nil)
+	w := httptest.NewRecorder()
+	if err := WriteTargetHistory(w, r); err == nil {
+		t.Fatalf("expecting non-nil error for missing target")
+	}
+}
@@ -0,0 +1,304 @@
+package arrow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+type testSeries struct {
+	labels     string
+	timestamps []int64
+	values     []float64
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	f := func(labelNames []string, series []testSeries) {
+		t.Helper()
+
+		var bb bytes.Buffer
+		pw, err := NewWriter(&bb, labelNames)
+		if err != nil {
+			t.Fatalf("cannot create writer: %s", err)
+		}
+		for i, s := range series {
+			mn := newTestMetricName(s.labels)
+			if err := pw.WriteSeries(uint(i%3), mn, s.timestamps, s.values); err != nil {
+				t.Fatalf("cannot write series %s: %s", s.labels, err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatalf("cannot close writer: %s", err)
+		}
+
+		sr, err := NewStreamReader(bytes.NewReader(bb.Bytes()))
+		if err != nil {
+			t.Fatalf("cannot read stream schema: %s", err)
+		}
+		var result []testSeries
+		for {
+			rb, err := sr.NextRecordBatch()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("cannot read record batch: %s", err)
+			}
+			err = rb.ForEachBlock(func(block *Block) error {
+				var a []string
+				for _, label := range block.Labels {
+					a = append(a, fmt.Sprintf("%s=%s", label.Name, label.Value))
+				}
+				sort.Strings(a)
+				result = append(result, testSeries{
+					labels:     strings.Join(a, ","),
+					timestamps: append([]int64{}, block.Timestamps...),
+					values:     append([]float64{}, block.Values...),
+				})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("cannot read blocks: %s", err)
+			}
+		}
+
+		var expected []testSeries
+		for _, s := range series {
+			if len(s.timestamps) > 0 {
+				expected = append(expected, s)
+			}
+		}
+		sortTestSeries(expected)
+		sortTestSeries(result)
+		if len(result) != len(expected) {
+			t.Fatalf("unexpected number of series; got %d; want %d", len(result), len(expected))
+		}
+		for i := range expected {
+			s := &result[i]
+			sExpected := &expected[i]
+			if s.labels != sExpected.labels {
+				t.Fatalf("unexpected labels for series #%d; got %s; want %s", i, s.labels, sExpected.labels)
+			}
+			if !reflect.DeepEqual(s.timestamps, sExpected.timestamps) {
+				t.Fatalf("unexpected timestamps for series %s; got %v; want %v", s.labels, s.timestamps, sExpected.timestamps)
+			}
+			if !equalValues(s.values, sExpected.values) {
+				t.Fatalf("unexpected values for series %s; got %v; want %v", s.labels, s.values, sExpected.values)
+			}
+		}
+	}
+
+	// empty file
+	f([]string{"__name__"}, nil)
+
+	// single series
+	f([]string{"__name__", "job"}, []testSeries{
+		{
+			labels:     "__name__=foo,job=bar",
+			timestamps: []int64{1000, 2000, 3000},
+			values:     []float64{1, 2.5, math.Inf(-1)},
+		},
+	})
+
+	// multiple series with missing labels
+	f([]string{"job", "__name__", "instance", "unused"}, []testSeries{
+		{
+			labels:     "__name__=foo,job=bar",
+			timestamps: []int64{1000, 2000},
+			values:     []float64{1, 2},
+		},
+		{
+			labels:     "__name__=foo,instance=host1,job=bar",
+			timestamps: []int64{1000},
+			values:     []float64{3},
+		},
+		{
+			labels:     "instance=host2",
+			timestamps: []int64{-1000, 0, 1000},
+			values:     []float64{4, math.NaN(), -5},
+		},
+		{
+			labels:     "__name__=baz,job=bar",
+			timestamps: nil,
+			values:     nil,
+		},
+		{
+			labels:     "__name__=baz,job=bar",
+			timestamps: []int64{5000},
+			values:     []float64{6},
+		},
+	})
+
+	// multiple row groups
+	var series []testSeries
+	for i := 0; i < 100; i++ {
+		s := testSeries{
+			labels: fmt.Sprintf("__name__=metric_%d,instance=host_%d", i, i%7),
+		}
+		for j := 0; j < maxRecordBatchRows/30; j++ {
+			s.timestamps = append(s.timestamps, int64(j)*1000)
+			s.values = append(s.values, float64(i*j))
+		}
+		series = append(series, s)
+	}
+	f([]string{"__name__", "instance"}, series)
+}
+
+func TestNewWriterFailure(t *testing.T) {
+	f := func(labelNames []string) {
+		t.Helper()
+		if _, err := NewWriter(&bytes.Buffer{}, labelNames); err == nil {
+			t.Fatalf("expecting non-nil error for labelNames=%q", labelNames)
+		}
+	}
+	f([]string{"__name__", TimestampColumn})
+	f([]string{ValueColumn})
+}
+
+func TestWriterUnknownLabel(t *testing.T) {
+	pw, err := NewWriter(&bytes.Buffer{}, []string{"__name__"})
+	if err != nil {
+		t.Fatalf("cannot create writer: %s", err)
+	}
+	mn := newTestMetricName("__name__=foo,job=bar")
+	if err := pw.WriteSeries(0, mn, []int64{1}, []float64{2}); err == nil {
+		t.Fatalf("expecting non-nil error for series with unknown label")
+	}
+}
+
+func TestNewStreamReaderFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := NewStreamReader(strings.NewReader(data)); err == nil {
+			t.Fatalf("expecting non-nil error for data=%q", data)
+		}
+	}
+	f("")
+	f("\xff\xff\xff\xff\x00\x00\x00\x00")
+	f("\xff\xff\xff\xff\x08\x00\x00\x00foobar")
+	f("\xff\xff\xff\xff\x08\x00\x00\x00\xff\xff\x00\x00\x00\x00\x00\x00")
+
+	// schema without value column
+	var fbb fbBuilder
+	fields := fbTables{
+		newField(TimestampColumn, false, typeInt, &fbTable{}, nil),
+	}
+	schema := &fbTable{}
+	schema.addObject(1, fields)
+	f(string(appendMessage(nil, &fbb, messageHeaderSchema, schema, nil)))
+}
+
+func TestStreamReaderPlainStrings(t *testing.T) {
+	// Build a stream with non-dictionary-encoded label column, Int64 timestamps in seconds and Float32 values.
+	var fbb fbBuilder
+	timestampType := &fbTable{}
+	timestampType.addInt16(0, timeUnitSecond)
+	valueType := &fbTable{}
+	valueType.addInt16(0, precisionSingle)
+	schema := &fbTable{}
+	schema.addObject(1, fbTables{
+		newField("job", true, typeUtf8, &fbTable{}, nil),
+		newField(TimestampColumn, false, typeTimestamp, timestampType, nil),
+		newField(ValueColumn, false, typeFloatingPoint, valueType, nil),
+	})
+	data := appendMessage(nil, &fbb, messageHeaderSchema, schema, nil)
+
+	var body bodyBuilder
+	body.addNode(3, 1)
+	body.buf = append(body.buf, 0b101)
+	body.addBuffer(0)
+	start := len(body.buf)
+	for _, offset := range []uint32{0, 3, 3, 6} {
+		body.buf = binary.LittleEndian.AppendUint32(body.buf, offset)
+	}
+	body.addBuffer(start)
+	start = len(body.buf)
+	body.buf = append(body.buf, "foobar"...)
+	body.addBuffer(start)
+	body.addNode(3, 0)
+	body.addBuffer(len(body.buf))
+	start = len(body.buf)
+	for _, ts := range []int64{1, 2, 3} {
+		body.buf = binary.LittleEndian.AppendUint64(body.buf, uint64(ts))
+	}
+	body.addBuffer(start)
+	body.addNode(3, 0)
+	body.addBuffer(len(body.buf))
+	start = len(body.buf)
+	for _, v := range []float32{1.5, 2.5, 3.5} {
+		body.buf = binary.LittleEndian.AppendUint32(body.buf, math.Float32bits(v))
+	}
+	body.addBuffer(start)
+	data = appendMessage(data, &fbb, messageHeaderRecordBatch, body.recordBatch(3), body.buf)
+	data = appendEOS(data)
+
+	sr, err := NewStreamReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("cannot read stream schema: %s", err)
+	}
+	rb, err := sr.NextRecordBatch()
+	if err != nil {
+		t.Fatalf("cannot read record batch: %s", err)
+	}
+	var result []string
+	err = rb.ForEachBlock(func(block *Block) error {
+		result = append(result, fmt.Sprintf("%v %v %v", block.Labels, block.Timestamps, block.Values))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot read blocks: %s", err)
+	}
+	resultExpected := []string{
+		"[{job foo}] [1000] [1.5]",
+		"[] [2000] [2.5]",
+		"[{job bar}] [3000] [3.5]",
+	}
+	if !reflect.DeepEqual(result, resultExpected) {
+		t.Fatalf("unexpected result\ngot\n%q\nwant\n%q", result, resultExpected)
+	}
+	if _, err := sr.NextRecordBatch(); err != io.EOF {
+		t.Fatalf("expecting io.EOF; got %v", err)
+	}
+}
+
+func newTestMetricName(s string) *storage.MetricName {
+	var mn storage.MetricName
+	for _, kv := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(kv, "=")
+		if name == "__name__" {
+			mn.MetricGroup = []byte(value)
+		} else {
+			mn.AddTag(name, value)
+		}
+	}
+	return &mn
+}
+
+func sortTestSeries(series []testSeries) {
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].labels < series[j].labels
+	})
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) && math.IsNaN(b[i]) {
+			continue
+		}
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
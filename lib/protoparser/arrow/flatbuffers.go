@@ -0,0 +1,354 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// fbBuilder marshals Arrow IPC metadata with flatbuffers encoding.
+//
+// Unlike the official flatbuffers builder, it writes objects from the start to the end of the buffer,
+// so referenced objects are always located after the referencing object as required by flatbuffers format.
+//
+// See https://flatbuffers.dev/flatbuffers_internals.html
+type fbBuilder struct {
+	buf []byte
+}
+
+// fbObject is an object, which can be referenced by offset from flatbuffers table.
+//
+// It can be *fbTable, fbString, fbTables or fbStructs.
+type fbObject any
+
+// fbTable is a flatbuffers table under construction.
+type fbTable struct {
+	fields []fbField
+}
+
+type fbField struct {
+	// size is the size of scalar or offset field in bytes. Zero size means missing field.
+	size   int
+	scalar uint64
+	child  fbObject
+}
+
+// fbString is a flatbuffers string.
+type fbString string
+
+// fbTables is a flatbuffers vector of tables.
+type fbTables []*fbTable
+
+// fbStructs is a flatbuffers vector of structs with 8-byte alignment.
+type fbStructs struct {
+	n    int
+	data []byte
+}
+
+func (t *fbTable) setField(id int, f fbField) {
+	for len(t.fields) <= id {
+		t.fields = append(t.fields, fbField{})
+	}
+	t.fields[id] = f
+}
+
+func (t *fbTable) addBool(id int, v bool) {
+	n := uint64(0)
+	if v {
+		n = 1
+	}
+	t.setField(id, fbField{size: 1, scalar: n})
+}
+
+func (t *fbTable) addUint8(id int, v uint8) {
+	t.setField(id, fbField{size: 1, scalar: uint64(v)})
+}
+
+func (t *fbTable) addInt16(id int, v int16) {
+	t.setField(id, fbField{size: 2, scalar: uint64(v)})
+}
+
+func (t *fbTable) addInt32(id int, v int32) {
+	t.setField(id, fbField{size: 4, scalar: uint64(v)})
+}
+
+func (t *fbTable) addInt64(id int, v int64) {
+	t.setField(id, fbField{size: 8, scalar: uint64(v)})
+}
+
+func (t *fbTable) addObject(id int, o fbObject) {
+	t.setField(id, fbField{size: 4, child: o})
+}
+
+func (s *fbStructs) add(a, b int64) {
+	s.data = binary.LittleEndian.AppendUint64(s.data, uint64(a))
+	s.data = binary.LittleEndian.AppendUint64(s.data, uint64(b))
+	s.n++
+}
+
+// finish marshals flatbuffers with the given root table and appends the result to dst.
+//
+// The result is padded to 8 bytes.
+func (b *fbBuilder) finish(dst []byte, root *fbTable) []byte {
+	b.buf = append(b.buf[:0], 0, 0, 0, 0)
+	rootPos := b.writeObject(root)
+	binary.LittleEndian.PutUint32(b.buf, uint32(rootPos))
+	b.align(8)
+	return append(dst, b.buf...)
+}
+
+func (b *fbBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+func (b *fbBuilder) writeObject(o fbObject) int {
+	switch t := o.(type) {
+	case *fbTable:
+		return b.writeTable(t)
+	case fbString:
+		b.align(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(t)))
+		b.buf = append(b.buf, t...)
+		b.buf = append(b.buf, 0)
+		return pos
+	case fbTables:
+		b.align(4)
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(t)))
+		offsetsPos := len(b.buf)
+		b.buf = append(b.buf, make([]byte, 4*len(t))...)
+		for i, child := range t {
+			childPos := b.writeTable(child)
+			offsetPos := offsetsPos + 4*i
+			binary.LittleEndian.PutUint32(b.buf[offsetPos:], uint32(childPos-offsetPos))
+		}
+		return pos
+	case *fbStructs:
+		// Structs must be aligned to 8 bytes, while they are preceded by 4-byte vector length.
+		b.align(4)
+		if len(b.buf)%8 == 0 {
+			b.buf = append(b.buf, 0, 0, 0, 0)
+		}
+		pos := len(b.buf)
+		b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(t.n))
+		b.buf = append(b.buf, t.data...)
+		return pos
+	default:
+		panic(fmt.Errorf("BUG: unexpected flatbuffers object type %T", o))
+	}
+}
+
+func (b *fbBuilder) writeTable(t *fbTable) int {
+	// Write vtable. It is located in front of the table.
+	b.align(2)
+	vtablePos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(t.fields)))
+	b.buf = append(b.buf, make([]byte, 2+2*len(t.fields))...)
+
+	// Write table.
+	b.align(4)
+	tablePos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(int32(tablePos-vtablePos)))
+	var childOffsets []int
+	for id := range t.fields {
+		f := &t.fields[id]
+		if f.size == 0 {
+			continue
+		}
+		b.align(f.size)
+		binary.LittleEndian.PutUint16(b.buf[vtablePos+4+2*id:], uint16(len(b.buf)-tablePos))
+		if f.child != nil {
+			childOffsets = append(childOffsets, len(b.buf))
+		}
+		switch f.size {
+		case 1:
+			b.buf = append(b.buf, byte(f.scalar))
+		case 2:
+			b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(f.scalar))
+		case 4:
+			b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(f.scalar))
+		case 8:
+			b.buf = binary.LittleEndian.AppendUint64(b.buf, f.scalar)
+		}
+	}
+	binary.LittleEndian.PutUint16(b.buf[vtablePos+2:], uint16(len(b.buf)-tablePos))
+
+	// Write the referenced objects after the table.
+	n := 0
+	for id := range t.fields {
+		f := &t.fields[id]
+		if f.size == 0 || f.child == nil {
+			continue
+		}
+		offsetPos := childOffsets[n]
+		n++
+		childPos := b.writeObject(f.child)
+		binary.LittleEndian.PutUint32(b.buf[offsetPos:], uint32(childPos-offsetPos))
+	}
+	return tablePos
+}
+
+// fbReader reads flatbuffers-encoded Arrow IPC metadata.
+//
+// Out of bounds reads are registered in err, so the caller must check it after reading the needed fields.
+type fbReader struct {
+	buf []byte
+	err error
+}
+
+func (r *fbReader) checkBounds(pos, size int) bool {
+	if pos < 0 || pos+size > len(r.buf) || pos+size < pos {
+		if r.err == nil {
+			r.err = fmt.Errorf("out of bounds read at offset %d with size %d; flatbuffers size: %d bytes", pos, size, len(r.buf))
+		}
+		return false
+	}
+	return true
+}
+
+func (r *fbReader) uint8(pos int) uint8 {
+	if !r.checkBounds(pos, 1) {
+		return 0
+	}
+	return r.buf[pos]
+}
+
+func (r *fbReader) uint16(pos int) uint16 {
+	if !r.checkBounds(pos, 2) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(r.buf[pos:])
+}
+
+func (r *fbReader) uint32(pos int) uint32 {
+	if !r.checkBounds(pos, 4) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(r.buf[pos:])
+}
+
+func (r *fbReader) uint64(pos int) uint64 {
+	if !r.checkBounds(pos, 8) {
+		return 0
+	}
+	return binary.LittleEndian.Uint64(r.buf[pos:])
+}
+
+// root returns the root table.
+func (r *fbReader) root() fbTableReader {
+	return fbTableReader{
+		r:   r,
+		pos: int(r.uint32(0)),
+	}
+}
+
+// fbTableReader reads fields from flatbuffers table.
+type fbTableReader struct {
+	r   *fbReader
+	pos int
+}
+
+// fieldPos returns the position of the field with the given id. It returns 0 if the field is missing.
+func (t fbTableReader) fieldPos(id int) int {
+	vtablePos := t.pos - int(int32(t.r.uint32(t.pos)))
+	vtableSize := int(t.r.uint16(vtablePos))
+	if 4+2*id+2 > vtableSize {
+		return 0
+	}
+	offset := int(t.r.uint16(vtablePos + 4 + 2*id))
+	if offset == 0 {
+		return 0
+	}
+	return t.pos + offset
+}
+
+func (t fbTableReader) bool(id int) bool {
+	return t.uint8(id, 0) != 0
+}
+
+func (t fbTableReader) uint8(id int, defaultValue uint8) uint8 {
+	pos := t.fieldPos(id)
+	if pos == 0 {
+		return defaultValue
+	}
+	return t.r.uint8(pos)
+}
+
+func (t fbTableReader) int16(id int, defaultValue int16) int16 {
+	pos := t.fieldPos(id)
+	if pos == 0 {
+		return defaultValue
+	}
+	return int16(t.r.uint16(pos))
+}
+
+func (t fbTableReader) int32(id int, defaultValue int32) int32 {
+	pos := t.fieldPos(id)
+	if pos == 0 {
+		return defaultValue
+	}
+	return int32(t.r.uint32(pos))
+}
+
+func (t fbTableReader) int64(id int, defaultValue int64) int64 {
+	pos := t.fieldPos(id)
+	if pos == 0 {
+		return defaultValue
+	}
+	return int64(t.r.uint64(pos))
+}
+
+// deref returns the position of the object referenced by the field with the given id.
+//
+// It returns 0 if the field is missing.
+func (t fbTableReader) deref(id int) int {
+	pos := t.fieldPos(id)
+	if pos == 0 {
+		return 0
+	}
+	return pos + int(t.r.uint32(pos))
+}
+
+// table returns the table referenced by the field with the given id.
+func (t fbTableReader) table(id int) (fbTableReader, bool) {
+	pos := t.deref(id)
+	if pos == 0 {
+		return fbTableReader{}, false
+	}
+	return fbTableReader{
+		r:   t.r,
+		pos: pos,
+	}, true
+}
+
+func (t fbTableReader) string(id int) string {
+	pos := t.deref(id)
+	if pos == 0 {
+		return ""
+	}
+	n := int(t.r.uint32(pos))
+	if !t.r.checkBounds(pos+4, n) {
+		return ""
+	}
+	return string(t.r.buf[pos+4 : pos+4+n])
+}
+
+// vectorLen returns the length of the vector referenced by the field with the given id and the position of its first item.
+func (t fbTableReader) vector(id int) (int, int) {
+	pos := t.deref(id)
+	if pos == 0 {
+		return 0, 0
+	}
+	n := int(t.r.uint32(pos))
+	return n, pos + 4
+}
+
+// tableAt returns the table at the vector item position.
+func (t fbTableReader) tableAt(itemPos int) fbTableReader {
+	return fbTableReader{
+		r:   t.r,
+		pos: itemPos + int(t.r.uint32(itemPos)),
+	}
+}
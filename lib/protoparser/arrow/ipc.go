@@ -0,0 +1,174 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Arrow IPC constants.
+//
+// See https://github.com/apache/arrow/blob/main/format/Message.fbs and https://github.com/apache/arrow/blob/main/format/Schema.fbs
+const (
+	metadataVersionV4 = 3
+	metadataVersionV5 = 4
+
+	messageHeaderSchema          = 1
+	messageHeaderDictionaryBatch = 2
+	messageHeaderRecordBatch     = 3
+
+	typeInt           = 2
+	typeFloatingPoint = 3
+	typeUtf8          = 5
+	typeTimestamp     = 10
+	typeLargeUtf8     = 20
+
+	precisionSingle = 1
+	precisionDouble = 2
+
+	timeUnitSecond      = 0
+	timeUnitMillisecond = 1
+	timeUnitMicrosecond = 2
+	timeUnitNanosecond  = 3
+
+	compressionLZ4Frame = 0
+	compressionZstd     = 1
+)
+
+// continuationMarker precedes every encapsulated message in Arrow IPC stream.
+const continuationMarker = 0xffffffff
+
+// appendMessage appends encapsulated Arrow IPC message with the given header and body to dst.
+//
+// See https://arrow.apache.org/docs/format/Columnar.html#encapsulated-message-format
+func appendMessage(dst []byte, fbb *fbBuilder, headerType uint8, header *fbTable, body []byte) []byte {
+	msg := &fbTable{}
+	msg.addInt16(0, metadataVersionV5)
+	msg.addUint8(1, headerType)
+	msg.addObject(2, header)
+	msg.addInt64(3, int64(len(body)))
+
+	dst = binary.LittleEndian.AppendUint32(dst, continuationMarker)
+	dstLen := len(dst)
+	dst = append(dst, 0, 0, 0, 0)
+	dst = fbb.finish(dst, msg)
+	binary.LittleEndian.PutUint32(dst[dstLen:], uint32(len(dst)-dstLen-4))
+	return append(dst, body...)
+}
+
+// appendEOS appends Arrow IPC end-of-stream marker to dst.
+func appendEOS(dst []byte) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, continuationMarker)
+	return binary.LittleEndian.AppendUint32(dst, 0)
+}
+
+// message is Arrow IPC message read by readMessage.
+type message struct {
+	fbr        fbReader
+	headerType uint8
+	header     fbTableReader
+	body       []byte
+}
+
+// maxMessageSize is the maximum size of a single Arrow IPC message accepted by readMessage.
+const maxMessageSize = 512 * 1024 * 1024
+
+// readMessage reads the next encapsulated Arrow IPC message from r into m.
+//
+// It returns io.EOF at the end of the stream.
+func readMessage(r io.Reader, m *message) error {
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+		return err
+	}
+	size := binary.LittleEndian.Uint32(sizeBuf[:])
+	if size == continuationMarker {
+		if _, err := io.ReadFull(r, sizeBuf[:]); err != nil {
+			return fmt.Errorf("cannot read message metadata size: %w", err)
+		}
+		size = binary.LittleEndian.Uint32(sizeBuf[:])
+	}
+	if size == 0 {
+		// End of stream
+		return io.EOF
+	}
+	if size > maxMessageSize {
+		return fmt.Errorf("too big message metadata size: %d bytes; mustn't exceed %d bytes", size, maxMessageSize)
+	}
+	m.fbr.buf = resizeNoCopy(m.fbr.buf, int(size))
+	m.fbr.err = nil
+	if _, err := io.ReadFull(r, m.fbr.buf); err != nil {
+		return fmt.Errorf("cannot read message metadata with size %d bytes: %w", size, err)
+	}
+
+	root := m.fbr.root()
+	version := root.int16(0, 0)
+	if version < metadataVersionV4 {
+		return fmt.Errorf("unsupported metadata version: %d; supported versions: V4, V5", version)
+	}
+	m.headerType = root.uint8(1, 0)
+	header, ok := root.table(2)
+	if !ok {
+		return fmt.Errorf("missing message header")
+	}
+	m.header = header
+	bodyLength := root.int64(3, 0)
+	if err := m.fbr.err; err != nil {
+		return fmt.Errorf("cannot parse message metadata: %w", err)
+	}
+	if bodyLength < 0 || bodyLength > maxMessageSize {
+		return fmt.Errorf("invalid message body length: %d bytes; it must be in the range [0..%d]", bodyLength, maxMessageSize)
+	}
+	m.body = resizeNoCopy(m.body, int(bodyLength))
+	if _, err := io.ReadFull(r, m.body); err != nil {
+		return fmt.Errorf("cannot read message body with size %d bytes: %w", bodyLength, err)
+	}
+	return nil
+}
+
+func resizeNoCopy(b []byte, n int) []byte {
+	if cap(b) >= n {
+		return b[:n]
+	}
+	return make([]byte, n)
+}
+
+// bodyBuilder builds Arrow IPC message body.
+type bodyBuilder struct {
+	buf     []byte
+	buffers fbStructs
+	nodes   fbStructs
+}
+
+func (bb *bodyBuilder) reset() {
+	bb.buf = bb.buf[:0]
+	bb.buffers = fbStructs{
+		data: bb.buffers.data[:0],
+	}
+	bb.nodes = fbStructs{
+		data: bb.nodes.data[:0],
+	}
+}
+
+func (bb *bodyBuilder) addNode(length, nullCount int) {
+	bb.nodes.add(int64(length), int64(nullCount))
+}
+
+// addBuffer registers the buffer, which has been appended to bb.buf starting from the given start position.
+//
+// The buffer is padded to 8 bytes as required by Arrow IPC format.
+func (bb *bodyBuilder) addBuffer(start int) {
+	bb.buffers.add(int64(start), int64(len(bb.buf)-start))
+	for len(bb.buf)%8 != 0 {
+		bb.buf = append(bb.buf, 0)
+	}
+}
+
+// recordBatch returns RecordBatch table with the given length for the body built by bb.
+func (bb *bodyBuilder) recordBatch(length int) *fbTable {
+	rb := &fbTable{}
+	rb.addInt64(0, int64(length))
+	rb.addObject(1, &bb.nodes)
+	rb.addObject(2, &bb.buffers)
+	return rb
+}
@@ -0,0 +1,651 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+// Block is a block of samples for a single time series read from Arrow IPC stream.
+type Block struct {
+	Labels     []prompbmarshal.Label
+	Timestamps []int64
+	Values     []float64
+}
+
+func (b *Block) reset() {
+	clear(b.Labels)
+	b.Labels = b.Labels[:0]
+	b.Timestamps = b.Timestamps[:0]
+	b.Values = b.Values[:0]
+}
+
+// StreamReader reads record batches from Arrow IPC stream.
+//
+// The stream must contain TimestampColumn and ValueColumn columns with sample timestamps and values.
+// All the other columns must contain strings. They are treated as series labels.
+type StreamReader struct {
+	r   io.Reader
+	msg message
+
+	fields             []field
+	timestampColumnIdx int
+	valueColumnIdx     int
+
+	// dicts contains dictionaries for dictionary-encoded columns keyed by dictionary id.
+	dicts map[int64][]string
+}
+
+type field struct {
+	name string
+	typ  uint8
+
+	// bitWidth is set for Int type
+	bitWidth int32
+
+	// precision is set for FloatingPoint type
+	precision int16
+
+	// timeUnit is set for Timestamp type
+	timeUnit int16
+
+	// The following fields are set for dictionary-encoded fields
+	isDictionary  bool
+	dictID        int64
+	indexBitWidth int32
+}
+
+// NewStreamReader returns new StreamReader for Arrow IPC stream from r.
+//
+// It reads the stream schema from r.
+func NewStreamReader(r io.Reader) (*StreamReader, error) {
+	sr := &StreamReader{
+		r:                  r,
+		timestampColumnIdx: -1,
+		valueColumnIdx:     -1,
+		dicts:              make(map[int64][]string),
+	}
+	if err := readMessage(r, &sr.msg); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("missing schema in Arrow stream")
+		}
+		return nil, fmt.Errorf("cannot read Arrow stream schema: %w", err)
+	}
+	if sr.msg.headerType != messageHeaderSchema {
+		return nil, fmt.Errorf("unexpected first message type in Arrow stream; got %d; want Schema", sr.msg.headerType)
+	}
+	if err := sr.readSchema(sr.msg.header); err != nil {
+		return nil, fmt.Errorf("cannot parse Arrow stream schema: %w", err)
+	}
+	return sr, nil
+}
+
+func (sr *StreamReader) readSchema(schema fbTableReader) error {
+	if endianness := schema.int16(0, 0); endianness != 0 {
+		return fmt.Errorf("big endian Arrow streams aren't supported")
+	}
+	n, pos := schema.vector(1)
+	if !schema.r.checkBounds(pos, 4*n) {
+		return schema.r.err
+	}
+	for i := 0; i < n; i++ {
+		t := schema.tableAt(pos + 4*i)
+		f := field{
+			name: t.string(0),
+			typ:  t.uint8(2, 0),
+		}
+		typeTable, hasType := t.table(3)
+		if hasType {
+			switch f.typ {
+			case typeInt:
+				f.bitWidth = typeTable.int32(0, 0)
+			case typeFloatingPoint:
+				f.precision = typeTable.int16(0, 0)
+			case typeTimestamp:
+				f.timeUnit = typeTable.int16(0, 0)
+			}
+		}
+		if dict, ok := t.table(4); ok {
+			f.isDictionary = true
+			f.dictID = dict.int64(0, 0)
+			f.indexBitWidth = 32
+			if indexType, ok := dict.table(1); ok {
+				f.indexBitWidth = indexType.int32(0, 32)
+			}
+		}
+		if childrenLen, _ := t.vector(5); childrenLen > 0 {
+			return fmt.Errorf("nested field %q isn't supported", f.name)
+		}
+		if err := schema.r.err; err != nil {
+			return err
+		}
+		if err := sr.validateField(i, &f); err != nil {
+			return err
+		}
+		sr.fields = append(sr.fields, f)
+	}
+	if sr.timestampColumnIdx < 0 {
+		return fmt.Errorf("missing %q column", TimestampColumn)
+	}
+	if sr.valueColumnIdx < 0 {
+		return fmt.Errorf("missing %q column", ValueColumn)
+	}
+	return nil
+}
+
+func (sr *StreamReader) validateField(idx int, f *field) error {
+	if f.isDictionary {
+		switch f.indexBitWidth {
+		case 8, 16, 32, 64:
+		default:
+			return fmt.Errorf("unsupported dictionary index bit width for %q column: %d", f.name, f.indexBitWidth)
+		}
+	}
+	switch f.name {
+	case TimestampColumn:
+		if f.isDictionary || (f.typ != typeTimestamp && !(f.typ == typeInt && (f.bitWidth == 64 || f.bitWidth == 32))) {
+			return fmt.Errorf("unsupported type for %q column: %d; want Timestamp, Int64 or Int32", f.name, f.typ)
+		}
+		sr.timestampColumnIdx = idx
+	case ValueColumn:
+		switch {
+		case f.isDictionary:
+			return fmt.Errorf("dictionary-encoded %q column isn't supported", f.name)
+		case f.typ == typeFloatingPoint && (f.precision == precisionDouble || f.precision == precisionSingle):
+		case f.typ == typeInt && (f.bitWidth == 64 || f.bitWidth == 32):
+		default:
+			return fmt.Errorf("unsupported type for %q column: %d; want Float64, Float32, Int64 or Int32", f.name, f.typ)
+		}
+		sr.valueColumnIdx = idx
+	default:
+		if f.typ != typeUtf8 && f.typ != typeLargeUtf8 {
+			return fmt.Errorf("unsupported type for label column %q: %d; want Utf8 or LargeUtf8", f.name, f.typ)
+		}
+	}
+	return nil
+}
+
+// RecordBatch is a record batch read from Arrow IPC stream.
+type RecordBatch struct {
+	fields             []field
+	timestampColumnIdx int
+	valueColumnIdx     int
+
+	// dicts contains dictionaries for dictionary-encoded fields at the time the record batch has been read.
+	dicts [][]string
+
+	rb recordBatchMeta
+}
+
+// NextRecordBatch returns the next record batch from sr.
+//
+// It returns io.EOF at the end of the stream.
+// The returned RecordBatch doesn't refer to sr, so it can be processed concurrently with reading the next record batches.
+func (sr *StreamReader) NextRecordBatch() (*RecordBatch, error) {
+	for {
+		m := &sr.msg
+		if err := readMessage(sr.r, m); err != nil {
+			return nil, err
+		}
+		switch m.headerType {
+		case messageHeaderDictionaryBatch:
+			if err := sr.readDictionaryBatch(m); err != nil {
+				return nil, fmt.Errorf("cannot read dictionary batch: %w", err)
+			}
+		case messageHeaderRecordBatch:
+			rb := &RecordBatch{
+				fields:             sr.fields,
+				timestampColumnIdx: sr.timestampColumnIdx,
+				valueColumnIdx:     sr.valueColumnIdx,
+				dicts:              make([][]string, len(sr.fields)),
+			}
+			if err := rb.rb.init(m.header, append([]byte{}, m.body...)); err != nil {
+				return nil, fmt.Errorf("cannot read record batch: %w", err)
+			}
+			for i := range sr.fields {
+				f := &sr.fields[i]
+				if f.isDictionary {
+					dict, ok := sr.dicts[f.dictID]
+					if !ok {
+						return nil, fmt.Errorf("missing dictionary with id=%d for %q column", f.dictID, f.name)
+					}
+					rb.dicts[i] = dict
+				}
+			}
+			return rb, nil
+		default:
+			return nil, fmt.Errorf("unexpected message type: %d", m.headerType)
+		}
+	}
+}
+
+func (sr *StreamReader) readDictionaryBatch(m *message) error {
+	db := m.header
+	id := db.int64(0, 0)
+	data, ok := db.table(1)
+	isDelta := db.bool(2)
+	if err := m.fbr.err; err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("missing dictionary data")
+	}
+	var f *field
+	for i := range sr.fields {
+		if sr.fields[i].isDictionary && sr.fields[i].dictID == id {
+			f = &sr.fields[i]
+			break
+		}
+	}
+	if f == nil {
+		return fmt.Errorf("unexpected dictionary id=%d", id)
+	}
+	var rbm recordBatchMeta
+	if err := rbm.init(data, m.body); err != nil {
+		return err
+	}
+	valueField := *f
+	valueField.isDictionary = false
+	var values []string
+	if err := rbm.readStrings(&values, 0, 0, &valueField, nil); err != nil {
+		return err
+	}
+	if isDelta {
+		sr.dicts[id] = append(sr.dicts[id], values...)
+	} else {
+		sr.dicts[id] = values
+	}
+	return nil
+}
+
+// ForEachBlock calls callback for every series block in rb.
+//
+// Consecutive rows with identical labels are passed to callback in a single block.
+// callback mustn't hold block after returning.
+func (rb *RecordBatch) ForEachBlock(callback func(block *Block) error) error {
+	m := &rb.rb
+	if len(m.nodes) < len(rb.fields) {
+		return fmt.Errorf("unexpected number of field nodes in record batch; got %d; want %d", len(m.nodes), len(rb.fields))
+	}
+	labels := make([][]string, len(rb.fields))
+	var timestamps []int64
+	var values []float64
+	bufIdx := 0
+	for i := range rb.fields {
+		f := &rb.fields[i]
+		var err error
+		switch i {
+		case rb.timestampColumnIdx:
+			timestamps, err = m.readTimestamps(i, bufIdx, f)
+		case rb.valueColumnIdx:
+			values, err = m.readValues(i, bufIdx, f)
+		default:
+			err = m.readStrings(&labels[i], i, bufIdx, f, rb.dicts[i])
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read %q column: %w", f.name, err)
+		}
+		bufIdx += f.buffersCount()
+	}
+
+	var block Block
+	rowsCount := len(timestamps)
+	start := 0
+	for i := 1; i <= rowsCount; i++ {
+		if i < rowsCount && rb.haveEqualLabels(labels, i-1, i) {
+			continue
+		}
+		block.reset()
+		for j := range rb.fields {
+			if j == rb.timestampColumnIdx || j == rb.valueColumnIdx {
+				continue
+			}
+			value := labels[j][start]
+			if value == "" {
+				continue
+			}
+			block.Labels = append(block.Labels, prompbmarshal.Label{
+				Name:  rb.fields[j].name,
+				Value: value,
+			})
+		}
+		block.Timestamps = append(block.Timestamps, timestamps[start:i]...)
+		block.Values = append(block.Values, values[start:i]...)
+		if err := callback(&block); err != nil {
+			return err
+		}
+		start = i
+	}
+	return nil
+}
+
+func (rb *RecordBatch) haveEqualLabels(labels [][]string, i, j int) bool {
+	for k := range rb.fields {
+		if k == rb.timestampColumnIdx || k == rb.valueColumnIdx {
+			continue
+		}
+		if labels[k][i] != labels[k][j] {
+			return false
+		}
+	}
+	return true
+}
+
+// buffersCount returns the number of body buffers occupied by f in record batch.
+func (f *field) buffersCount() int {
+	if !f.isDictionary && (f.typ == typeUtf8 || f.typ == typeLargeUtf8) {
+		// validity bitmap, offsets and data
+		return 3
+	}
+	// validity bitmap and values
+	return 2
+}
+
+// recordBatchMeta holds record batch metadata and body.
+type recordBatchMeta struct {
+	length  int
+	nodes   []fieldNode
+	buffers []bufferRef
+	codec   int8
+	body    []byte
+}
+
+type fieldNode struct {
+	length    int
+	nullCount int
+}
+
+type bufferRef struct {
+	offset int64
+	length int64
+}
+
+func (rbm *recordBatchMeta) init(t fbTableReader, body []byte) error {
+	rbm.length = int(t.int64(0, 0))
+	rbm.codec = -1
+	n, pos := t.vector(1)
+	if !t.r.checkBounds(pos, 16*n) {
+		return t.r.err
+	}
+	for i := 0; i < n; i++ {
+		rbm.nodes = append(rbm.nodes, fieldNode{
+			length:    int(int64(t.r.uint64(pos + 16*i))),
+			nullCount: int(int64(t.r.uint64(pos + 16*i + 8))),
+		})
+	}
+	n, pos = t.vector(2)
+	if !t.r.checkBounds(pos, 16*n) {
+		return t.r.err
+	}
+	for i := 0; i < n; i++ {
+		rbm.buffers = append(rbm.buffers, bufferRef{
+			offset: int64(t.r.uint64(pos + 16*i)),
+			length: int64(t.r.uint64(pos + 16*i + 8)),
+		})
+	}
+	if compression, ok := t.table(3); ok {
+		rbm.codec = int8(compression.uint8(0, 0))
+		if method := compression.uint8(1, 0); method != 0 {
+			return fmt.Errorf("unsupported body compression method: %d", method)
+		}
+		if rbm.codec != compressionZstd {
+			return fmt.Errorf("unsupported body compression codec: %d; only ZSTD is supported", rbm.codec)
+		}
+	}
+	if err := t.r.err; err != nil {
+		return err
+	}
+	if rbm.length < 0 {
+		return fmt.Errorf("invalid record batch length: %d", rbm.length)
+	}
+	for _, node := range rbm.nodes {
+		if node.length != rbm.length {
+			return fmt.Errorf("field node length %d mismatches record batch length %d", node.length, rbm.length)
+		}
+		if node.nullCount < 0 || node.nullCount > node.length {
+			return fmt.Errorf("invalid null count %d for field node with length %d", node.nullCount, node.length)
+		}
+	}
+	rbm.body = body
+	return nil
+}
+
+// buffer returns the body buffer with the given idx.
+func (rbm *recordBatchMeta) buffer(idx int) ([]byte, error) {
+	if idx >= len(rbm.buffers) {
+		return nil, fmt.Errorf("missing buffer #%d; record batch contains %d buffers", idx, len(rbm.buffers))
+	}
+	br := rbm.buffers[idx]
+	if br.offset < 0 || br.length < 0 || br.offset+br.length > int64(len(rbm.body)) {
+		return nil, fmt.Errorf("buffer #%d with offset=%d and length=%d is out of body bounds; body size: %d bytes", idx, br.offset, br.length, len(rbm.body))
+	}
+	data := rbm.body[br.offset : br.offset+br.length]
+	if rbm.codec < 0 || len(data) == 0 {
+		return data, nil
+	}
+	if len(data) < 8 {
+		return nil, fmt.Errorf("missing uncompressed length for compressed buffer #%d", idx)
+	}
+	uncompressedLen := int64(binary.LittleEndian.Uint64(data))
+	data = data[8:]
+	if uncompressedLen == -1 {
+		// The buffer isn't compressed.
+		return data, nil
+	}
+	if uncompressedLen < 0 || uncompressedLen > maxMessageSize {
+		return nil, fmt.Errorf("invalid uncompressed length for buffer #%d: %d", idx, uncompressedLen)
+	}
+	dst, err := zstd.Decompress(make([]byte, 0, uncompressedLen), data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decompress buffer #%d: %w", idx, err)
+	}
+	return dst, nil
+}
+
+// isValid returns true if the value at row i isn't null according to the given validity bitmap.
+func isValid(validity []byte, i int) bool {
+	if len(validity) == 0 {
+		return true
+	}
+	return validity[i/8]&(1<<(i%8)) != 0
+}
+
+func (rbm *recordBatchMeta) validity(fieldIdx, bufIdx int) ([]byte, error) {
+	if fieldIdx >= len(rbm.nodes) {
+		return nil, fmt.Errorf("missing field node #%d; record batch contains %d field nodes", fieldIdx, len(rbm.nodes))
+	}
+	node := rbm.nodes[fieldIdx]
+	if node.nullCount == 0 {
+		return nil, nil
+	}
+	validity, err := rbm.buffer(bufIdx)
+	if err != nil {
+		return nil, err
+	}
+	if len(validity) == 0 {
+		return nil, fmt.Errorf("missing validity bitmap for field with %d nulls", node.nullCount)
+	}
+	if len(validity)*8 < node.length {
+		return nil, fmt.Errorf("too short validity bitmap: %d bytes; want at least %d bits", len(validity), node.length)
+	}
+	return validity, nil
+}
+
+// readStrings reads string values for the field f with the given fieldIdx into dst.
+//
+// bufIdx must point to the first body buffer for the field. dict must contain the dictionary for dictionary-encoded field.
+// Null values are returned as empty strings.
+func (rbm *recordBatchMeta) readStrings(dst *[]string, fieldIdx, bufIdx int, f *field, dict []string) error {
+	validity, err := rbm.validity(fieldIdx, bufIdx)
+	if err != nil {
+		return err
+	}
+	n := rbm.length
+	a := make([]string, n)
+	if f.isDictionary {
+		data, err := rbm.buffer(bufIdx + 1)
+		if err != nil {
+			return err
+		}
+		indexes, err := decodeInts(data, n, f.indexBitWidth)
+		if err != nil {
+			return fmt.Errorf("cannot read dictionary indexes: %w", err)
+		}
+		for i, idx := range indexes {
+			if !isValid(validity, i) {
+				continue
+			}
+			if idx < 0 || idx >= int64(len(dict)) {
+				return fmt.Errorf("dictionary index %d at row %d is out of range [0..%d)", idx, i, len(dict))
+			}
+			a[i] = dict[idx]
+		}
+		*dst = a
+		return nil
+	}
+
+	offsetsData, err := rbm.buffer(bufIdx + 1)
+	if err != nil {
+		return err
+	}
+	offsetBitWidth := int32(32)
+	if f.typ == typeLargeUtf8 {
+		offsetBitWidth = 64
+	}
+	var offsets []int64
+	if n > 0 {
+		offsets, err = decodeInts(offsetsData, n+1, offsetBitWidth)
+		if err != nil {
+			return fmt.Errorf("cannot read string offsets: %w", err)
+		}
+	}
+	data, err := rbm.buffer(bufIdx + 2)
+	if err != nil {
+		return err
+	}
+	for i := range a {
+		if !isValid(validity, i) {
+			continue
+		}
+		start, end := offsets[i], offsets[i+1]
+		if start < 0 || end < start || end > int64(len(data)) {
+			return fmt.Errorf("invalid string offsets [%d..%d] at row %d; data size: %d bytes", start, end, i, len(data))
+		}
+		a[i] = string(data[start:end])
+	}
+	*dst = a
+	return nil
+}
+
+func (rbm *recordBatchMeta) readTimestamps(fieldIdx, bufIdx int, f *field) ([]int64, error) {
+	ints, err := rbm.readInts(fieldIdx, bufIdx, f.bitWidth, f)
+	if err != nil {
+		return nil, err
+	}
+	if f.typ == typeTimestamp {
+		switch f.timeUnit {
+		case timeUnitSecond:
+			for i := range ints {
+				ints[i] *= 1e3
+			}
+		case timeUnitMicrosecond:
+			for i := range ints {
+				ints[i] /= 1e3
+			}
+		case timeUnitNanosecond:
+			for i := range ints {
+				ints[i] /= 1e6
+			}
+		}
+	}
+	return ints, nil
+}
+
+func (rbm *recordBatchMeta) readValues(fieldIdx, bufIdx int, f *field) ([]float64, error) {
+	if f.typ == typeInt {
+		ints, err := rbm.readInts(fieldIdx, bufIdx, f.bitWidth, f)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]float64, len(ints))
+		for i, v := range ints {
+			values[i] = float64(v)
+		}
+		return values, nil
+	}
+	if err := rbm.checkNoNulls(fieldIdx, f); err != nil {
+		return nil, err
+	}
+	data, err := rbm.buffer(bufIdx + 1)
+	if err != nil {
+		return nil, err
+	}
+	n := rbm.length
+	values := make([]float64, n)
+	if f.precision == precisionSingle {
+		if len(data) < 4*n {
+			return nil, fmt.Errorf("too short buffer for %d Float32 values: %d bytes", n, len(data))
+		}
+		for i := range values {
+			values[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:])))
+		}
+		return values, nil
+	}
+	if len(data) < 8*n {
+		return nil, fmt.Errorf("too short buffer for %d Float64 values: %d bytes", n, len(data))
+	}
+	for i := range values {
+		values[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return values, nil
+}
+
+func (rbm *recordBatchMeta) checkNoNulls(fieldIdx int, f *field) error {
+	if n := rbm.nodes[fieldIdx].nullCount; n > 0 {
+		return fmt.Errorf("%q column mustn't contain null values; found %d null values", f.name, n)
+	}
+	return nil
+}
+
+// readInts reads signed integers with the given bitWidth for the field with the given fieldIdx.
+func (rbm *recordBatchMeta) readInts(fieldIdx, bufIdx int, bitWidth int32, f *field) ([]int64, error) {
+	if err := rbm.checkNoNulls(fieldIdx, f); err != nil {
+		return nil, err
+	}
+	data, err := rbm.buffer(bufIdx + 1)
+	if err != nil {
+		return nil, err
+	}
+	return decodeInts(data, rbm.length, bitWidth)
+}
+
+func decodeInts(data []byte, n int, bitWidth int32) ([]int64, error) {
+	if bitWidth == 0 {
+		// Timestamp values are always 64-bit
+		bitWidth = 64
+	}
+	size := int(bitWidth / 8)
+	if len(data) < size*n {
+		return nil, fmt.Errorf("too short buffer for %d Int%d values: %d bytes", n, bitWidth, len(data))
+	}
+	a := make([]int64, n)
+	for i := range a {
+		switch size {
+		case 1:
+			a[i] = int64(int8(data[i]))
+		case 2:
+			a[i] = int64(int16(binary.LittleEndian.Uint16(data[2*i:])))
+		case 4:
+			a[i] = int64(int32(binary.LittleEndian.Uint32(data[4*i:])))
+		case 8:
+			a[i] = int64(binary.LittleEndian.Uint64(data[8*i:]))
+		default:
+			return nil, fmt.Errorf("unsupported integer bit width: %d", bitWidth)
+		}
+	}
+	return a, nil
+}
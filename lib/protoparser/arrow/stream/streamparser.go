@@ -0,0 +1,129 @@
+package stream
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/arrow"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+// Parse parses Arrow IPC stream from r and calls callback for the parsed blocks.
+//
+// The callback can be called concurrently multiple times for distinct record batches from the stream.
+//
+// callback shouldn't hold block after returning.
+func Parse(r io.Reader, isGzip bool, callback func(block *arrow.Block) error) error {
+	wcr := writeconcurrencylimiter.GetReader(r)
+	defer writeconcurrencylimiter.PutReader(wcr)
+	r = wcr
+
+	if isGzip {
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped Arrow data: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		r = zr
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+
+	sr, err := arrow.NewStreamReader(br)
+	if err != nil {
+		parseErrors.Inc()
+		return err
+	}
+	ctx := &streamContext{}
+	for {
+		rb, err := sr.NextRecordBatch()
+		if err != nil {
+			ctx.wg.Wait()
+			if err == io.EOF {
+				return ctx.err
+			}
+			readErrors.Inc()
+			return fmt.Errorf("cannot read Arrow record batch: %w", err)
+		}
+		readCalls.Inc()
+
+		uw := getUnmarshalWork()
+		uw.rb = rb
+		uw.ctx = ctx
+		uw.callback = callback
+		ctx.wg.Add(1)
+		common.ScheduleUnmarshalWork(uw)
+		wcr.DecConcurrency()
+	}
+}
+
+type streamContext struct {
+	wg      sync.WaitGroup
+	errLock sync.Mutex
+	err     error
+}
+
+var (
+	readCalls  = metrics.NewCounter(`vm_protoparser_read_calls_total{type="arrow"}`)
+	readErrors = metrics.NewCounter(`vm_protoparser_read_errors_total{type="arrow"}`)
+	rowsRead   = metrics.NewCounter(`vm_protoparser_rows_read_total{type="arrow"}`)
+	blocksRead = metrics.NewCounter(`vm_protoparser_blocks_read_total{type="arrow"}`)
+
+	parseErrors   = metrics.NewCounter(`vm_protoparser_parse_errors_total{type="arrow"}`)
+	processErrors = metrics.NewCounter(`vm_protoparser_process_errors_total{type="arrow"}`)
+)
+
+type unmarshalWork struct {
+	rb       *arrow.RecordBatch
+	ctx      *streamContext
+	callback func(block *arrow.Block) error
+}
+
+func (uw *unmarshalWork) reset() {
+	uw.rb = nil
+	uw.ctx = nil
+	uw.callback = nil
+}
+
+// Unmarshal implements common.UnmarshalWork
+func (uw *unmarshalWork) Unmarshal() {
+	var callbackErr error
+	err := uw.rb.ForEachBlock(func(block *arrow.Block) error {
+		blocksRead.Inc()
+		rowsRead.Add(len(block.Timestamps))
+		callbackErr = uw.callback(block)
+		return callbackErr
+	})
+	if err != nil && callbackErr == nil {
+		parseErrors.Inc()
+	}
+	ctx := uw.ctx
+	if err != nil {
+		processErrors.Inc()
+		ctx.errLock.Lock()
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("error when processing Arrow record batch: %w", err)
+		}
+		ctx.errLock.Unlock()
+	}
+	ctx.wg.Done()
+	putUnmarshalWork(uw)
+}
+
+func getUnmarshalWork() *unmarshalWork {
+	v := unmarshalWorkPool.Get()
+	if v == nil {
+		return &unmarshalWork{}
+	}
+	return v.(*unmarshalWork)
+}
+
+func putUnmarshalWork(uw *unmarshalWork) {
+	uw.reset()
+	unmarshalWorkPool.Put(uw)
+}
+
+var unmarshalWorkPool sync.Pool
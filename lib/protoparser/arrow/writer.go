@@ -0,0 +1,368 @@
+package arrow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+const (
+	// TimestampColumn is the name of the column with sample timestamps in milliseconds.
+	TimestampColumn = "timestamp"
+
+	// ValueColumn is the name of the column with sample values.
+	ValueColumn = "value"
+)
+
+// maxRecordBatchRows is the maximum number of rows the Writer puts into a single record batch.
+const maxRecordBatchRows = 64 * 1024
+
+// Writer writes time series in Apache Arrow IPC streaming format.
+//
+// Every label is stored in a separate nullable dictionary-encoded string column, while sample timestamps and values
+// are stored in TimestampColumn and ValueColumn columns. Every record batch is preceded by dictionary batches
+// for label columns, which replace the previously sent dictionaries.
+//
+// See https://arrow.apache.org/docs/format/Columnar.html#ipc-streaming-format
+type Writer struct {
+	// labelNames contains the names of label columns in the order they are stored in the stream.
+	labelNames []string
+
+	// labelIdxs maps label name to its column index.
+	labelIdxs map[string]int
+
+	// builders contains per-worker batchBuilder items.
+	builders sync.Map
+
+	// mu protects the fields below.
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns new Writer for writing series with the given labelNames to w.
+//
+// All the labels of the written series must be listed in labelNames.
+// Call Close when all the series are written.
+func NewWriter(w io.Writer, labelNames []string) (*Writer, error) {
+	labelNames = common.NormalizeLabelNames(labelNames)
+	labelIdxs := make(map[string]int, len(labelNames))
+	for i, name := range labelNames {
+		if name == TimestampColumn || name == ValueColumn {
+			return nil, fmt.Errorf("label name %q clashes with the name of Arrow column for samples", name)
+		}
+		labelIdxs[name] = i
+	}
+	aw := &Writer{
+		labelNames: labelNames,
+		labelIdxs:  labelIdxs,
+		w:          w,
+	}
+	var fbb fbBuilder
+	data := appendMessage(nil, &fbb, messageHeaderSchema, aw.schema(), nil)
+	if err := aw.write(data); err != nil {
+		return nil, err
+	}
+	return aw, nil
+}
+
+func (aw *Writer) schema() *fbTable {
+	fields := make(fbTables, 0, len(aw.labelNames)+2)
+	for i, name := range aw.labelNames {
+		indexType := &fbTable{}
+		indexType.addInt32(0, 32)
+		indexType.addBool(1, true)
+		dictionary := &fbTable{}
+		dictionary.addInt64(0, int64(i))
+		dictionary.addObject(1, indexType)
+		fields = append(fields, newField(name, true, typeUtf8, &fbTable{}, dictionary))
+	}
+
+	timestampType := &fbTable{}
+	timestampType.addInt16(0, timeUnitMillisecond)
+	timestampType.addObject(1, fbString("UTC"))
+	fields = append(fields, newField(TimestampColumn, false, typeTimestamp, timestampType, nil))
+
+	valueType := &fbTable{}
+	valueType.addInt16(0, precisionDouble)
+	fields = append(fields, newField(ValueColumn, false, typeFloatingPoint, valueType, nil))
+
+	schema := &fbTable{}
+	// Little endian
+	schema.addInt16(0, 0)
+	schema.addObject(1, fields)
+	return schema
+}
+
+func newField(name string, nullable bool, typ uint8, typeTable, dictionary *fbTable) *fbTable {
+	field := &fbTable{}
+	field.addObject(0, fbString(name))
+	field.addBool(1, nullable)
+	field.addUint8(2, typ)
+	field.addObject(3, typeTable)
+	if dictionary != nil {
+		field.addObject(4, dictionary)
+	}
+	// Some Arrow implementations require non-nil children.
+	field.addObject(5, fbTables{})
+	return field
+}
+
+// WriteSeries writes the series with the given mn, timestamps and values to aw.
+//
+// It is safe calling WriteSeries concurrently with distinct workerID values.
+func (aw *Writer) WriteSeries(workerID uint, mn *storage.MetricName, timestamps []int64, values []float64) error {
+	v, ok := aw.builders.Load(workerID)
+	if !ok {
+		v = aw.newBatchBuilder()
+		aw.builders.Store(workerID, v)
+	}
+	bb := v.(*batchBuilder)
+	if err := bb.addSeries(mn, timestamps, values); err != nil {
+		return err
+	}
+	if len(bb.timestamps) < maxRecordBatchRows {
+		return nil
+	}
+	return aw.writeBatch(bb)
+}
+
+// Close writes the remaining record batches and the end-of-stream marker to the underlying writer.
+//
+// Close doesn't close the underlying writer.
+func (aw *Writer) Close() error {
+	var err error
+	aw.builders.Range(func(_, v any) bool {
+		bb := v.(*batchBuilder)
+		if len(bb.timestamps) > 0 {
+			err = aw.writeBatch(bb)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return aw.write(appendEOS(nil))
+}
+
+func (aw *Writer) write(data []byte) error {
+	aw.mu.Lock()
+	defer aw.mu.Unlock()
+
+	if aw.err != nil {
+		return aw.err
+	}
+	if _, err := aw.w.Write(data); err != nil {
+		aw.err = fmt.Errorf("cannot write Arrow data: %w", err)
+	}
+	return aw.err
+}
+
+func (aw *Writer) writeBatch(bb *batchBuilder) error {
+	// Encode the batch outside the lock, so multiple batches could be encoded in parallel.
+	// Dictionary batches and the record batch must be written atomically,
+	// since the record batch refers to the dictionaries sent right before it.
+	bb.encode()
+	bb.reset()
+	return aw.write(bb.buf)
+}
+
+// batchBuilder accumulates series for a single record batch.
+type batchBuilder struct {
+	aw *Writer
+
+	// seriesRows contains the number of rows per each series in the batch.
+	seriesRows []int
+
+	// labelValues contains per-series dictionary indexes for every label column.
+	// Missing labels are marked with -1.
+	labelValues [][]int32
+	dicts       []dictionary
+
+	timestamps []int64
+	values     []float64
+
+	// buf contains the encoded batch.
+	buf []byte
+
+	body bodyBuilder
+	fbb  fbBuilder
+}
+
+type dictionary struct {
+	m      map[string]int32
+	values []string
+}
+
+func (aw *Writer) newBatchBuilder() *batchBuilder {
+	bb := &batchBuilder{
+		aw:          aw,
+		labelValues: make([][]int32, len(aw.labelNames)),
+		dicts:       make([]dictionary, len(aw.labelNames)),
+	}
+	for i := range bb.dicts {
+		bb.dicts[i].m = make(map[string]int32)
+	}
+	return bb
+}
+
+func (bb *batchBuilder) reset() {
+	bb.seriesRows = bb.seriesRows[:0]
+	for i := range bb.labelValues {
+		bb.labelValues[i] = bb.labelValues[i][:0]
+	}
+	for i := range bb.dicts {
+		d := &bb.dicts[i]
+		clear(d.m)
+		clear(d.values)
+		d.values = d.values[:0]
+	}
+	bb.timestamps = bb.timestamps[:0]
+	bb.values = bb.values[:0]
+}
+
+func (bb *batchBuilder) addSeries(mn *storage.MetricName, timestamps []int64, values []float64) error {
+	if len(timestamps) != len(values) {
+		return fmt.Errorf("BUG: len(timestamps)=%d must match len(values)=%d", len(timestamps), len(values))
+	}
+	if len(timestamps) == 0 {
+		return nil
+	}
+	seriesIdx := len(bb.seriesRows)
+	for i := range bb.labelValues {
+		bb.labelValues[i] = append(bb.labelValues[i], -1)
+	}
+	if len(mn.MetricGroup) > 0 {
+		if err := bb.setLabelValue(seriesIdx, []byte("__name__"), mn.MetricGroup); err != nil {
+			return err
+		}
+	}
+	for i := range mn.Tags {
+		tag := &mn.Tags[i]
+		if err := bb.setLabelValue(seriesIdx, tag.Key, tag.Value); err != nil {
+			return err
+		}
+	}
+	bb.seriesRows = append(bb.seriesRows, len(timestamps))
+	bb.timestamps = append(bb.timestamps, timestamps...)
+	bb.values = append(bb.values, values...)
+	return nil
+}
+
+func (bb *batchBuilder) setLabelValue(seriesIdx int, name, value []byte) error {
+	columnIdx, ok := bb.aw.labelIdxs[string(name)]
+	if !ok {
+		// Roll back the partially added series.
+		for i := range bb.labelValues {
+			bb.labelValues[i] = bb.labelValues[i][:seriesIdx]
+		}
+		return fmt.Errorf("unexpected label %q, which is missing in the Arrow schema", name)
+	}
+	d := &bb.dicts[columnIdx]
+	id, ok := d.m[string(value)]
+	if !ok {
+		s := string(value)
+		id = int32(len(d.values))
+		d.m[s] = id
+		d.values = append(d.values, s)
+	}
+	bb.labelValues[columnIdx][seriesIdx] = id
+	return nil
+}
+
+// encode encodes the accumulated series into bb.buf as a sequence of dictionary batches followed by a record batch.
+func (bb *batchBuilder) encode() {
+	bb.buf = bb.buf[:0]
+	body := &bb.body
+
+	for i := range bb.labelValues {
+		d := &bb.dicts[i]
+		body.reset()
+		body.addNode(len(d.values), 0)
+		body.addBuffer(len(body.buf))
+		start := len(body.buf)
+		offset := 0
+		body.buf = binary.LittleEndian.AppendUint32(body.buf, 0)
+		for _, v := range d.values {
+			offset += len(v)
+			body.buf = binary.LittleEndian.AppendUint32(body.buf, uint32(offset))
+		}
+		body.addBuffer(start)
+		start = len(body.buf)
+		for _, v := range d.values {
+			body.buf = append(body.buf, v...)
+		}
+		body.addBuffer(start)
+
+		db := &fbTable{}
+		db.addInt64(0, int64(i))
+		db.addObject(1, body.recordBatch(len(d.values)))
+		db.addBool(2, false)
+		bb.buf = appendMessage(bb.buf, &bb.fbb, messageHeaderDictionaryBatch, db, body.buf)
+	}
+
+	rowsCount := len(bb.timestamps)
+	body.reset()
+	for _, labelValues := range bb.labelValues {
+		nullCount := 0
+		for j, id := range labelValues {
+			if id < 0 {
+				nullCount += bb.seriesRows[j]
+			}
+		}
+		body.addNode(rowsCount, nullCount)
+
+		// validity bitmap
+		start := len(body.buf)
+		if nullCount > 0 {
+			bitmap := make([]byte, (rowsCount+7)/8)
+			row := 0
+			for j, id := range labelValues {
+				n := bb.seriesRows[j]
+				if id >= 0 {
+					for k := row; k < row+n; k++ {
+						bitmap[k/8] |= 1 << (k % 8)
+					}
+				}
+				row += n
+			}
+			body.buf = append(body.buf, bitmap...)
+		}
+		body.addBuffer(start)
+
+		// dictionary indexes
+		start = len(body.buf)
+		for j, id := range labelValues {
+			if id < 0 {
+				id = 0
+			}
+			for k := 0; k < bb.seriesRows[j]; k++ {
+				body.buf = binary.LittleEndian.AppendUint32(body.buf, uint32(id))
+			}
+		}
+		body.addBuffer(start)
+	}
+
+	body.addNode(rowsCount, 0)
+	body.addBuffer(len(body.buf))
+	start := len(body.buf)
+	for _, ts := range bb.timestamps {
+		body.buf = binary.LittleEndian.AppendUint64(body.buf, uint64(ts))
+	}
+	body.addBuffer(start)
+
+	body.addNode(rowsCount, 0)
+	body.addBuffer(len(body.buf))
+	start = len(body.buf)
+	for _, v := range bb.values {
+		body.buf = binary.LittleEndian.AppendUint64(body.buf, math.Float64bits(v))
+	}
+	body.addBuffer(start)
+
+	bb.buf = appendMessage(bb.buf, &bb.fbb, messageHeaderRecordBatch, body.recordBatch(rowsCount), body.buf)
+}
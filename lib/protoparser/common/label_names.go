@@ -0,0 +1,30 @@
+package common
+
+import (
+	"sort"
+)
+
+// NormalizeLabelNames returns sorted unique labelNames with __name__ at the first place.
+//
+// Empty label name is treated as __name__.
+func NormalizeLabelNames(labelNames []string) []string {
+	m := make(map[string]struct{}, len(labelNames))
+	a := make([]string, 0, len(labelNames))
+	for _, name := range labelNames {
+		if name == "" {
+			name = "__name__"
+		}
+		if _, ok := m[name]; ok {
+			continue
+		}
+		m[name] = struct{}{}
+		a = append(a, name)
+	}
+	sort.Slice(a, func(i, j int) bool {
+		if a[i] == "__name__" || a[j] == "__name__" {
+			return a[i] == "__name__"
+		}
+		return a[i] < a[j]
+	})
+	return a
+}
@@ -63,3 +63,15 @@ func float64AsString(f float64) string {
 	}
 	return string(b)
 }
+
+// GetValue returns the value for e.
+func (e *Exemplar) GetValue() float64 {
+	switch {
+	case e.IntValue != nil:
+		return float64(*e.IntValue)
+	case e.DoubleValue != nil:
+		return *e.DoubleValue
+	default:
+		return 0
+	}
+}
@@ -1,9 +1,11 @@
 package stream
 
 import (
+	"encoding/hex"
 	"fmt"
 	"io"
 	"math"
+	"sort"
 	"strconv"
 	"sync"
 
@@ -19,12 +21,12 @@ import (
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
 )
 
-// ParseStream parses OpenTelemetry protobuf or json data from r and calls callback for the parsed rows.
+// ParseStream parses OpenTelemetry protobuf or json data from r and calls callback for the parsed rows and metric metadata.
 //
-// callback shouldn't hold tss items after returning.
+// callback shouldn't hold tss and mms items after returning.
 //
 // optional processBody can be used for pre-processing the read request body from r before parsing it in OpenTelemetry format.
-func ParseStream(r io.Reader, isGzipped bool, processBody func([]byte) ([]byte, error), callback func(tss []prompbmarshal.TimeSeries) error) error {
+func ParseStream(r io.Reader, isGzipped bool, processBody func([]byte) ([]byte, error), callback func(tss []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error) error {
 	wcr := writeconcurrencylimiter.GetReader(r)
 	defer writeconcurrencylimiter.PutReader(wcr)
 	r = wcr
@@ -46,7 +48,7 @@ func ParseStream(r io.Reader, isGzipped bool, processBody func([]byte) ([]byte,
 	}
 	wr.parseRequestToTss(req)
 
-	if err := callback(wr.tss); err != nil {
+	if err := callback(wr.tss, wr.mms); err != nil {
 		return fmt.Errorf("error when processing OpenTelemetry samples: %w", err)
 	}
 
@@ -60,6 +62,7 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 			continue
 		}
 		metricName := sanitizeMetricName(m)
+		wr.appendMetricMetadata(metricName, m)
 		switch {
 		case m.Gauge != nil:
 			for _, p := range m.Gauge.DataPoints {
@@ -100,6 +103,31 @@ func (wr *writeContext) appendSamplesFromScopeMetrics(sc *pb.ScopeMetrics) {
 	}
 }
 
+// appendMetricMetadata appends metadata for m with the given metricName to wr.mms
+func (wr *writeContext) appendMetricMetadata(metricName string, m *pb.Metric) {
+	typ := prompbmarshal.MetricMetadataUNKNOWN
+	switch {
+	case m.Gauge != nil:
+		typ = prompbmarshal.MetricMetadataGAUGE
+	case m.Sum != nil:
+		if m.Sum.IsMonotonic {
+			typ = prompbmarshal.MetricMetadataCOUNTER
+		} else {
+			typ = prompbmarshal.MetricMetadataGAUGE
+		}
+	case m.Summary != nil:
+		typ = prompbmarshal.MetricMetadataSUMMARY
+	case m.Histogram != nil, m.ExponentialHistogram != nil:
+		typ = prompbmarshal.MetricMetadataHISTOGRAM
+	}
+	wr.mms = append(wr.mms, prompbmarshal.MetricMetadata{
+		Type:             typ,
+		MetricFamilyName: metricName,
+		Help:             m.Description,
+		Unit:             m.Unit,
+	})
+}
+
 // appendSampleFromNumericPoint appends p to wr.tss
 func (wr *writeContext) appendSampleFromNumericPoint(metricName string, p *pb.NumberDataPoint) {
 	var v float64
@@ -115,6 +143,12 @@ func (wr *writeContext) appendSampleFromNumericPoint(metricName string, p *pb.Nu
 	wr.pointLabels = appendAttributesToPromLabels(wr.pointLabels[:0], p.Attributes)
 
 	wr.appendSample(metricName, t, v, isStale)
+	if !isStale {
+		ts := &wr.tss[len(wr.tss)-1]
+		for _, e := range p.Exemplars {
+			wr.appendExemplar(ts, e, t)
+		}
+	}
 }
 
 // appendSamplesFromSummary appends summary p to wr.tss
@@ -157,6 +191,7 @@ func (wr *writeContext) appendSamplesFromHistogram(metricName string, p *pb.Hist
 
 	wr.appendSample(metricName+"_sum", t, *p.Sum, isStale)
 
+	bucketsStart := len(wr.tss)
 	var cumulative uint64
 	for index, bound := range p.ExplicitBounds {
 		cumulative += p.BucketCounts[index]
@@ -165,6 +200,14 @@ func (wr *writeContext) appendSamplesFromHistogram(metricName string, p *pb.Hist
 	}
 	cumulative += p.BucketCounts[len(p.BucketCounts)-1]
 	wr.appendSampleWithExtraLabel(metricName+"_bucket", "le", "+Inf", t, float64(cumulative), isStale)
+
+	if !isStale {
+		// Attach exemplars to the buckets they belong to like Prometheus does.
+		for _, e := range p.Exemplars {
+			idx := sort.SearchFloat64s(p.ExplicitBounds, e.GetValue())
+			wr.appendExemplar(&wr.tss[bucketsStart+idx], e, t)
+		}
+	}
 }
 
 // appendSamplesFromExponentialHistogram appends histogram p to wr.tss
@@ -212,6 +255,39 @@ func (wr *writeContext) appendSamplesFromExponentialHistogram(metricName string,
 	}
 }
 
+// appendExemplar appends e to ts.Exemplars.
+//
+// defaultTimestamp is used if e has no timestamp.
+func (wr *writeContext) appendExemplar(ts *prompbmarshal.TimeSeries, e *pb.Exemplar, defaultTimestamp int64) {
+	labelsPool := wr.labelsPool
+	labelsLen := len(labelsPool)
+	if len(e.TraceID) > 0 {
+		labelsPool = append(labelsPool, prompbmarshal.Label{
+			Name:  "trace_id",
+			Value: hex.EncodeToString(e.TraceID),
+		})
+	}
+	if len(e.SpanID) > 0 {
+		labelsPool = append(labelsPool, prompbmarshal.Label{
+			Name:  "span_id",
+			Value: hex.EncodeToString(e.SpanID),
+		})
+	}
+	labelsPool = appendAttributesToPromLabels(labelsPool, e.FilteredAttributes)
+
+	t := int64(e.TimeUnixNano / 1e6)
+	if t <= 0 {
+		t = defaultTimestamp
+	}
+	ts.Exemplars = append(ts.Exemplars, prompbmarshal.Exemplar{
+		Labels:    labelsPool[labelsLen:],
+		Value:     e.GetValue(),
+		Timestamp: t,
+	})
+
+	wr.labelsPool = labelsPool
+}
+
 // appendSample appends sample with the given metricName to wr.tss
 func (wr *writeContext) appendSample(metricName string, t int64, v float64, isStale bool) {
 	wr.appendSampleWithExtraLabel(metricName, "", "", t, v, isStale)
@@ -278,6 +354,9 @@ type writeContext struct {
 	// tss holds parsed time series
 	tss []prompbmarshal.TimeSeries
 
+	// mms holds parsed metric metadata
+	mms []prompbmarshal.MetricMetadata
+
 	// baseLabels are labels, which must be added to all the ingested samples
 	baseLabels []prompbmarshal.Label
 
@@ -295,6 +374,9 @@ func (wr *writeContext) reset() {
 	clear(wr.tss)
 	wr.tss = wr.tss[:0]
 
+	clear(wr.mms)
+	wr.mms = wr.mms[:0]
+
 	wr.baseLabels = resetLabels(wr.baseLabels)
 	wr.pointLabels = resetLabels(wr.pointLabels)
 
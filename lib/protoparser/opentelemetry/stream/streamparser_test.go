@@ -209,9 +209,133 @@ func TestParseStream(t *testing.T) {
 	)
 }
 
+func TestParseStreamExemplars(t *testing.T) {
+	traceID := []byte{0x01, 0x02, 0xab}
+	spanID := []byte{0xcd}
+	exemplarValue := 0.3
+	m := generateHistogram("my-histogram", "")
+	m.Histogram.DataPoints[0].Exemplars = []*pb.Exemplar{{
+		FilteredAttributes: attributesFromKV("user", "foo"),
+		TimeUnixNano:       uint64(29 * time.Second),
+		DoubleValue:        &exemplarValue,
+		TraceID:            traceID,
+		SpanID:             spanID,
+	}}
+	req := &pb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*pb.ResourceMetrics{
+			generateOTLPSamples([]*pb.Metric{m}),
+		},
+	}
+	exemplarsExpected := []prompbmarshal.Exemplar{{
+		Labels: []prompbmarshal.Label{
+			{
+				Name:  "trace_id",
+				Value: "0102ab",
+			},
+			{
+				Name:  "span_id",
+				Value: "cd",
+			},
+			{
+				Name:  "user",
+				Value: "foo",
+			},
+		},
+		Value:     0.3,
+		Timestamp: 29000,
+	}}
+	checkSeries := func(tss []prompbmarshal.TimeSeries) error {
+		var seriesWithExemplars []prompbmarshal.TimeSeries
+		for _, ts := range tss {
+			if len(ts.Exemplars) > 0 {
+				seriesWithExemplars = append(seriesWithExemplars, ts)
+			}
+		}
+		if len(seriesWithExemplars) != 1 {
+			return fmt.Errorf("unexpected number of series with exemplars; got %d; want 1", len(seriesWithExemplars))
+		}
+		ts := seriesWithExemplars[0]
+		if le := getLabelValue(ts.Labels, "le"); le != "0.5" {
+			return fmt.Errorf("unexpected bucket for the exemplar; got le=%q; want le=%q", le, "0.5")
+		}
+		if !reflect.DeepEqual(ts.Exemplars, exemplarsExpected) {
+			return fmt.Errorf("unexpected exemplars;\ngot\n%+v\nwant\n%+v", ts.Exemplars, exemplarsExpected)
+		}
+		return nil
+	}
+	if err := checkParseStream(req.MarshalProtobuf(nil), checkSeries); err != nil {
+		t.Fatalf("cannot parse protobuf: %s", err)
+	}
+}
+
+func TestParseStreamMetricMetadata(t *testing.T) {
+	gauge := generateGauge("my-gauge", "")
+	gauge.Description = "gauge help"
+	counter := generateSum("my-counter", "ms", true)
+	counter.Description = "counter help"
+	req := &pb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*pb.ResourceMetrics{
+			generateOTLPSamples([]*pb.Metric{
+				gauge,
+				counter,
+				generateSum("my-updown-counter", "", false),
+				generateSummary("my-summary", ""),
+				generateHistogram("my-histogram", ""),
+			}),
+		},
+	}
+	mmsExpected := []prompbmarshal.MetricMetadata{
+		{
+			Type:             prompbmarshal.MetricMetadataGAUGE,
+			MetricFamilyName: "my-gauge",
+			Help:             "gauge help",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataCOUNTER,
+			MetricFamilyName: "my-counter",
+			Help:             "counter help",
+			Unit:             "ms",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataGAUGE,
+			MetricFamilyName: "my-updown-counter",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataSUMMARY,
+			MetricFamilyName: "my-summary",
+		},
+		{
+			Type:             prompbmarshal.MetricMetadataHISTOGRAM,
+			MetricFamilyName: "my-histogram",
+		},
+	}
+	err := ParseStream(bytes.NewBuffer(req.MarshalProtobuf(nil)), false, nil, func(_ []prompbmarshal.TimeSeries, mms []prompbmarshal.MetricMetadata) error {
+		if !reflect.DeepEqual(mms, mmsExpected) {
+			return fmt.Errorf("unexpected metric metadata;\ngot\n%+v\nwant\n%+v", mms, mmsExpected)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("cannot parse protobuf: %s", err)
+	}
+}
+
+func getLabelValue(labels []prompbmarshal.Label, name string) string {
+	for _, l := range labels {
+		if l.Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
 func checkParseStream(data []byte, checkSeries func(tss []prompbmarshal.TimeSeries) error) error {
+	callback := func(tss []prompbmarshal.TimeSeries, _ []prompbmarshal.MetricMetadata) error {
+		return checkSeries(tss)
+	}
+
 	// Verify parsing without compression
-	if err := ParseStream(bytes.NewBuffer(data), false, nil, checkSeries); err != nil {
+	if err := ParseStream(bytes.NewBuffer(data), false, nil, callback); err != nil {
 		return fmt.Errorf("error when parsing data: %w", err)
 	}
 
@@ -224,7 +348,7 @@ func checkParseStream(data []byte, checkSeries func(tss []prompbmarshal.TimeSeri
 	if err := zw.Close(); err != nil {
 		return fmt.Errorf("cannot close gzip writer: %w", err)
 	}
-	if err := ParseStream(&bb, true, nil, checkSeries); err != nil {
+	if err := ParseStream(&bb, true, nil, callback); err != nil {
 		return fmt.Errorf("error when parsing compressed data: %w", err)
 	}
 
@@ -0,0 +1,346 @@
+package parquet
+
+import (
+	"fmt"
+)
+
+// Parquet physical types.
+//
+// See https://github.com/apache/parquet-format/blob/master/src/main/thrift/parquet.thrift
+const (
+	typeBoolean   = 0
+	typeInt32     = 1
+	typeInt64     = 2
+	typeInt96     = 3
+	typeFloat     = 4
+	typeDouble    = 5
+	typeByteArray = 6
+)
+
+// Parquet field repetition types.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// Parquet converted types.
+const (
+	convertedTypeUTF8            = 0
+	convertedTypeTimestampMillis = 9
+	convertedTypeTimestampMicros = 10
+)
+
+// Parquet encodings.
+const (
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+	encodingRLEDictionary   = 8
+)
+
+// Parquet compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+)
+
+// Parquet page types.
+const (
+	pageTypeData       = 0
+	pageTypeDictionary = 2
+	pageTypeDataV2     = 3
+)
+
+// Time units for timestamp columns.
+const (
+	timeUnitMillis = 1
+	timeUnitMicros = 2
+	timeUnitNanos  = 3
+)
+
+type schemaElement struct {
+	typ            int32
+	repetitionType int32
+	name           string
+	numChildren    int32
+	convertedType  int32
+
+	// timeUnit is set for timestamp columns
+	timeUnit int
+}
+
+func (se *schemaElement) unmarshal(tr *thriftReader) error {
+	se.typ = -1
+	se.convertedType = -1
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 1:
+			se.typ, err = tr.readI32()
+		case 3:
+			se.repetitionType, err = tr.readI32()
+		case 4:
+			se.name, err = tr.readBinary()
+		case 5:
+			se.numChildren, err = tr.readI32()
+		case 6:
+			se.convertedType, err = tr.readI32()
+			switch se.convertedType {
+			case convertedTypeTimestampMillis:
+				se.timeUnit = timeUnitMillis
+			case convertedTypeTimestampMicros:
+				se.timeUnit = timeUnitMicros
+			}
+		case 10:
+			err = se.unmarshalLogicalType(tr)
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+func (se *schemaElement) unmarshalLogicalType(tr *thriftReader) error {
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		if fieldID != 8 {
+			return tr.skipField(fieldType)
+		}
+		// TimestampType
+		return tr.readStruct(func(fieldID int16, fieldType byte) error {
+			if fieldID != 2 {
+				return tr.skipField(fieldType)
+			}
+			// TimeUnit union
+			return tr.readStruct(func(fieldID int16, fieldType byte) error {
+				switch fieldID {
+				case 1:
+					se.timeUnit = timeUnitMillis
+				case 2:
+					se.timeUnit = timeUnitMicros
+				case 3:
+					se.timeUnit = timeUnitNanos
+				}
+				return tr.skipField(fieldType)
+			})
+		})
+	})
+}
+
+type columnMetaData struct {
+	typ                  int32
+	pathInSchema         []string
+	codec                int32
+	numValues            int64
+	totalCompressedSize  int64
+	dataPageOffset       int64
+	dictionaryPageOffset int64
+}
+
+func (cmd *columnMetaData) unmarshal(tr *thriftReader) error {
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 1:
+			cmd.typ, err = tr.readI32()
+		case 3:
+			err = tr.readList(func(_ byte) error {
+				s, err := tr.readBinary()
+				cmd.pathInSchema = append(cmd.pathInSchema, s)
+				return err
+			})
+		case 4:
+			cmd.codec, err = tr.readI32()
+		case 5:
+			cmd.numValues, err = tr.readI64()
+		case 7:
+			cmd.totalCompressedSize, err = tr.readI64()
+		case 9:
+			cmd.dataPageOffset, err = tr.readI64()
+		case 11:
+			cmd.dictionaryPageOffset, err = tr.readI64()
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+type columnChunk struct {
+	filePath string
+	metaData columnMetaData
+}
+
+func (cc *columnChunk) unmarshal(tr *thriftReader) error {
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 1:
+			cc.filePath, err = tr.readBinary()
+		case 3:
+			err = cc.metaData.unmarshal(tr)
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+type rowGroup struct {
+	columns []columnChunk
+	numRows int64
+}
+
+func (rg *rowGroup) unmarshal(tr *thriftReader) error {
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 1:
+			err = tr.readList(func(_ byte) error {
+				rg.columns = append(rg.columns, columnChunk{})
+				return rg.columns[len(rg.columns)-1].unmarshal(tr)
+			})
+		case 3:
+			rg.numRows, err = tr.readI64()
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+type fileMetaData struct {
+	schema    []schemaElement
+	numRows   int64
+	rowGroups []rowGroup
+}
+
+func (fmd *fileMetaData) unmarshal(data []byte) error {
+	tr := &thriftReader{
+		b: data,
+	}
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 2:
+			err = tr.readList(func(_ byte) error {
+				fmd.schema = append(fmd.schema, schemaElement{})
+				return fmd.schema[len(fmd.schema)-1].unmarshal(tr)
+			})
+		case 3:
+			fmd.numRows, err = tr.readI64()
+		case 4:
+			err = tr.readList(func(_ byte) error {
+				fmd.rowGroups = append(fmd.rowGroups, rowGroup{})
+				return fmd.rowGroups[len(fmd.rowGroups)-1].unmarshal(tr)
+			})
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+type pageHeader struct {
+	typ                  int32
+	uncompressedPageSize int32
+	compressedPageSize   int32
+
+	// numValues and encoding are set for all the page types.
+	numValues int32
+	encoding  int32
+
+	// The following fields are set only for data pages v2.
+	definitionLevelsByteLength int32
+	repetitionLevelsByteLength int32
+	isCompressed               bool
+}
+
+func (ph *pageHeader) unmarshal(tr *thriftReader) error {
+	ph.isCompressed = true
+	return tr.readStruct(func(fieldID int16, fieldType byte) error {
+		var err error
+		switch fieldID {
+		case 1:
+			ph.typ, err = tr.readI32()
+		case 2:
+			ph.uncompressedPageSize, err = tr.readI32()
+		case 3:
+			ph.compressedPageSize, err = tr.readI32()
+		case 5, 7:
+			// DataPageHeader and DictionaryPageHeader start with num_values and encoding fields.
+			err = tr.readStruct(func(fieldID int16, fieldType byte) error {
+				var err error
+				switch fieldID {
+				case 1:
+					ph.numValues, err = tr.readI32()
+				case 2:
+					ph.encoding, err = tr.readI32()
+				default:
+					err = tr.skipField(fieldType)
+				}
+				return err
+			})
+		case 8:
+			err = tr.readStruct(func(fieldID int16, fieldType byte) error {
+				var err error
+				switch fieldID {
+				case 1:
+					ph.numValues, err = tr.readI32()
+				case 4:
+					ph.encoding, err = tr.readI32()
+				case 5:
+					ph.definitionLevelsByteLength, err = tr.readI32()
+				case 6:
+					ph.repetitionLevelsByteLength, err = tr.readI32()
+				case 7:
+					ph.isCompressed = fieldType == thriftBoolTrue
+				default:
+					err = tr.skipField(fieldType)
+				}
+				return err
+			})
+		default:
+			err = tr.skipField(fieldType)
+		}
+		return err
+	})
+}
+
+func (ph *pageHeader) validate() error {
+	if ph.compressedPageSize < 0 || ph.uncompressedPageSize < 0 {
+		return fmt.Errorf("invalid page size; compressed=%d, uncompressed=%d", ph.compressedPageSize, ph.uncompressedPageSize)
+	}
+	if ph.numValues < 0 {
+		return fmt.Errorf("invalid number of values in the page: %d", ph.numValues)
+	}
+	if ph.definitionLevelsByteLength < 0 || ph.repetitionLevelsByteLength < 0 {
+		return fmt.Errorf("invalid levels length; definition=%d, repetition=%d", ph.definitionLevelsByteLength, ph.repetitionLevelsByteLength)
+	}
+	return nil
+}
+
+func marshalPageHeader(tw *thriftWriter, typ int32, uncompressedSize, compressedSize, numValues, encoding int) {
+	tw.structBegin()
+	tw.fieldI32(1, typ)
+	tw.fieldI32(2, int32(uncompressedSize))
+	tw.fieldI32(3, int32(compressedSize))
+	switch typ {
+	case pageTypeData:
+		tw.fieldStructBegin(5)
+		tw.fieldI32(1, int32(numValues))
+		tw.fieldI32(2, int32(encoding))
+		// Definition and repetition levels are always encoded with RLE.
+		tw.fieldI32(3, encodingRLE)
+		tw.fieldI32(4, encodingRLE)
+		tw.structEnd()
+	case pageTypeDictionary:
+		tw.fieldStructBegin(7)
+		tw.fieldI32(1, int32(numValues))
+		tw.fieldI32(2, int32(encoding))
+		tw.structEnd()
+	}
+	tw.structEnd()
+}
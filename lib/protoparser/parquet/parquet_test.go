@@ -0,0 +1,240 @@
+package parquet
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+)
+
+type testSeries struct {
+	labels     string
+	timestamps []int64
+	values     []float64
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	f := func(labelNames []string, series []testSeries) {
+		t.Helper()
+
+		var bb bytes.Buffer
+		pw, err := NewWriter(&bb, labelNames)
+		if err != nil {
+			t.Fatalf("cannot create writer: %s", err)
+		}
+		for i, s := range series {
+			mn := newTestMetricName(s.labels)
+			if err := pw.WriteSeries(uint(i%3), mn, s.timestamps, s.values); err != nil {
+				t.Fatalf("cannot write series %s: %s", s.labels, err)
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatalf("cannot close writer: %s", err)
+		}
+
+		pf, err := OpenFile(bytes.NewReader(bb.Bytes()), int64(bb.Len()))
+		if err != nil {
+			t.Fatalf("cannot open file: %s", err)
+		}
+		var result []testSeries
+		for i := 0; i < pf.RowGroupsCount(); i++ {
+			err := pf.ReadRowGroup(i, func(block *Block) error {
+				var a []string
+				for _, label := range block.Labels {
+					a = append(a, fmt.Sprintf("%s=%s", label.Name, label.Value))
+				}
+				sort.Strings(a)
+				result = append(result, testSeries{
+					labels:     strings.Join(a, ","),
+					timestamps: append([]int64{}, block.Timestamps...),
+					values:     append([]float64{}, block.Values...),
+				})
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("cannot read row group #%d: %s", i, err)
+			}
+		}
+
+		var expected []testSeries
+		for _, s := range series {
+			if len(s.timestamps) > 0 {
+				expected = append(expected, s)
+			}
+		}
+		sortTestSeries(expected)
+		sortTestSeries(result)
+		if len(result) != len(expected) {
+			t.Fatalf("unexpected number of series; got %d; want %d", len(result), len(expected))
+		}
+		for i := range expected {
+			s := &result[i]
+			sExpected := &expected[i]
+			if s.labels != sExpected.labels {
+				t.Fatalf("unexpected labels for series #%d; got %s; want %s", i, s.labels, sExpected.labels)
+			}
+			if !reflect.DeepEqual(s.timestamps, sExpected.timestamps) {
+				t.Fatalf("unexpected timestamps for series %s; got %v; want %v", s.labels, s.timestamps, sExpected.timestamps)
+			}
+			if !equalValues(s.values, sExpected.values) {
+				t.Fatalf("unexpected values for series %s; got %v; want %v", s.labels, s.values, sExpected.values)
+			}
+		}
+	}
+
+	// empty file
+	f([]string{"__name__"}, nil)
+
+	// single series
+	f([]string{"__name__", "job"}, []testSeries{
+		{
+			labels:     "__name__=foo,job=bar",
+			timestamps: []int64{1000, 2000, 3000},
+			values:     []float64{1, 2.5, math.Inf(-1)},
+		},
+	})
+
+	// multiple series with missing labels
+	f([]string{"job", "__name__", "instance", "unused"}, []testSeries{
+		{
+			labels:     "__name__=foo,job=bar",
+			timestamps: []int64{1000, 2000},
+			values:     []float64{1, 2},
+		},
+		{
+			labels:     "__name__=foo,instance=host1,job=bar",
+			timestamps: []int64{1000},
+			values:     []float64{3},
+		},
+		{
+			labels:     "instance=host2",
+			timestamps: []int64{-1000, 0, 1000},
+			values:     []float64{4, math.NaN(), -5},
+		},
+		{
+			labels:     "__name__=baz,job=bar",
+			timestamps: nil,
+			values:     nil,
+		},
+		{
+			labels:     "__name__=baz,job=bar",
+			timestamps: []int64{5000},
+			values:     []float64{6},
+		},
+	})
+
+	// multiple row groups
+	var series []testSeries
+	for i := 0; i < 100; i++ {
+		s := testSeries{
+			labels: fmt.Sprintf("__name__=metric_%d,instance=host_%d", i, i%7),
+		}
+		for j := 0; j < maxRowGroupRows/30; j++ {
+			s.timestamps = append(s.timestamps, int64(j)*1000)
+			s.values = append(s.values, float64(i*j))
+		}
+		series = append(series, s)
+	}
+	f([]string{"__name__", "instance"}, series)
+}
+
+func TestNewWriterFailure(t *testing.T) {
+	f := func(labelNames []string) {
+		t.Helper()
+		if _, err := NewWriter(&bytes.Buffer{}, labelNames); err == nil {
+			t.Fatalf("expecting non-nil error for labelNames=%q", labelNames)
+		}
+	}
+	f([]string{"__name__", TimestampColumn})
+	f([]string{ValueColumn})
+}
+
+func TestWriterUnknownLabel(t *testing.T) {
+	pw, err := NewWriter(&bytes.Buffer{}, []string{"__name__"})
+	if err != nil {
+		t.Fatalf("cannot create writer: %s", err)
+	}
+	mn := newTestMetricName("__name__=foo,job=bar")
+	if err := pw.WriteSeries(0, mn, []int64{1}, []float64{2}); err == nil {
+		t.Fatalf("expecting non-nil error for series with unknown label")
+	}
+}
+
+func TestOpenFileFailure(t *testing.T) {
+	f := func(data string) {
+		t.Helper()
+		if _, err := OpenFile(strings.NewReader(data), int64(len(data))); err == nil {
+			t.Fatalf("expecting non-nil error for data=%q", data)
+		}
+	}
+	f("")
+	f("PAR1")
+	f("PAR1foobarPAR1")
+	f("PAR1\x00\x00\x00\x00PAR1")
+	f("PAR1\xff\x00\x00\x00PAR1")
+}
+
+func TestDecodeRLEHybrid(t *testing.T) {
+	f := func(src []byte, bitWidth, n int, resultExpected []uint32) {
+		t.Helper()
+		result, err := decodeRLEHybrid(nil, src, bitWidth, n)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result; got %v; want %v", result, resultExpected)
+		}
+	}
+
+	// RLE run
+	f([]byte{0x06, 0x05}, 3, 3, []uint32{5, 5, 5})
+	f(appendRLERun(nil, 4, 300, 9), 9, 4, []uint32{300, 300, 300, 300})
+
+	// bit-packed run with values 0..7 with bit width 3
+	f([]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 8, []uint32{0, 1, 2, 3, 4, 5, 6, 7})
+
+	// truncated bit-packed run
+	f([]byte{0x03, 0x88, 0xc6, 0xfa}, 3, 5, []uint32{0, 1, 2, 3, 4})
+
+	// RLE run followed by bit-packed run
+	f([]byte{0x04, 0x01, 0x03, 0x88, 0xc6, 0xfa}, 3, 4, []uint32{1, 1, 0, 1})
+}
+
+func newTestMetricName(s string) *storage.MetricName {
+	var mn storage.MetricName
+	for _, kv := range strings.Split(s, ",") {
+		name, value, _ := strings.Cut(kv, "=")
+		if name == "__name__" {
+			mn.MetricGroup = []byte(value)
+		} else {
+			mn.AddTag(name, value)
+		}
+	}
+	return &mn
+}
+
+func sortTestSeries(series []testSeries) {
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].labels < series[j].labels
+	})
+}
+
+func equalValues(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.IsNaN(a[i]) && math.IsNaN(b[i]) {
+			continue
+		}
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
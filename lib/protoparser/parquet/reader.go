@@ -0,0 +1,636 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding/zstd"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+	"github.com/golang/snappy"
+)
+
+// Block is a block of samples for a single time series read from Parquet file.
+type Block struct {
+	Labels     []prompbmarshal.Label
+	Timestamps []int64
+	Values     []float64
+}
+
+func (b *Block) reset() {
+	clear(b.Labels)
+	b.Labels = b.Labels[:0]
+	b.Timestamps = b.Timestamps[:0]
+	b.Values = b.Values[:0]
+}
+
+// File is a Parquet file opened with OpenFile.
+type File struct {
+	r         io.ReaderAt
+	size      int64
+	columns   []column
+	rowGroups []rowGroup
+
+	timestampColumnIdx int
+	valueColumnIdx     int
+}
+
+type column struct {
+	name     string
+	typ      int32
+	optional bool
+	timeUnit int
+}
+
+// OpenFile opens Parquet file with the given size, which can be read via r.
+//
+// The file must contain TimestampColumn and ValueColumn columns with sample timestamps and values.
+// All the other columns must contain strings. They are treated as series labels.
+//
+// Only the file metadata is read by OpenFile. Row groups are read on demand by ReadRowGroup.
+// The file contents mustn't be modified while the returned File is in use.
+func OpenFile(r io.ReaderAt, size int64) (*File, error) {
+	if size < int64(2*len(magic)+4) {
+		return nil, fmt.Errorf("too short Parquet file; got %d bytes", size)
+	}
+	head := make([]byte, len(magic))
+	if err := readAt(r, head, 0); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file header: %w", err)
+	}
+	tail := make([]byte, 4+len(magic))
+	if err := readAt(r, tail, size-int64(len(tail))); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file footer length: %w", err)
+	}
+	if string(head) != magic || string(tail[4:]) != magic {
+		return nil, fmt.Errorf("missing %q magic at the start or at the end of Parquet file", magic)
+	}
+	footerEnd := size - int64(len(tail))
+	footerLen := binary.LittleEndian.Uint32(tail)
+	if int64(footerLen) > footerEnd-int64(len(magic)) {
+		return nil, fmt.Errorf("invalid Parquet footer length: %d bytes; file size: %d bytes", footerLen, size)
+	}
+	footer := make([]byte, footerLen)
+	if err := readAt(r, footer, footerEnd-int64(footerLen)); err != nil {
+		return nil, fmt.Errorf("cannot read Parquet file metadata: %w", err)
+	}
+	var fmd fileMetaData
+	if err := fmd.unmarshal(footer); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal Parquet file metadata: %w", err)
+	}
+
+	if len(fmd.schema) == 0 {
+		return nil, fmt.Errorf("missing schema in Parquet file")
+	}
+	if n := int(fmd.schema[0].numChildren); n != len(fmd.schema)-1 {
+		return nil, fmt.Errorf("nested Parquet schemas aren't supported; the root schema element must have %d children; got %d", len(fmd.schema)-1, n)
+	}
+	f := &File{
+		r:                  r,
+		size:               size,
+		rowGroups:          fmd.rowGroups,
+		timestampColumnIdx: -1,
+		valueColumnIdx:     -1,
+	}
+	for i := range fmd.schema[1:] {
+		se := &fmd.schema[i+1]
+		if se.numChildren > 0 {
+			return nil, fmt.Errorf("nested column %q isn't supported", se.name)
+		}
+		if se.repetitionType == repetitionRepeated {
+			return nil, fmt.Errorf("repeated column %q isn't supported", se.name)
+		}
+		switch se.name {
+		case TimestampColumn:
+			if se.typ != typeInt64 && se.typ != typeInt32 {
+				return nil, fmt.Errorf("unsupported type for %q column: %d; want INT64 or INT32", se.name, se.typ)
+			}
+			f.timestampColumnIdx = i
+		case ValueColumn:
+			if se.typ != typeDouble && se.typ != typeFloat && se.typ != typeInt64 && se.typ != typeInt32 {
+				return nil, fmt.Errorf("unsupported type for %q column: %d; want DOUBLE, FLOAT, INT64 or INT32", se.name, se.typ)
+			}
+			f.valueColumnIdx = i
+		default:
+			if se.typ != typeByteArray {
+				return nil, fmt.Errorf("unsupported type for label column %q: %d; want BYTE_ARRAY", se.name, se.typ)
+			}
+		}
+		f.columns = append(f.columns, column{
+			name:     se.name,
+			typ:      se.typ,
+			optional: se.repetitionType == repetitionOptional,
+			timeUnit: se.timeUnit,
+		})
+	}
+	if f.timestampColumnIdx < 0 {
+		return nil, fmt.Errorf("missing %q column in Parquet file", TimestampColumn)
+	}
+	if f.valueColumnIdx < 0 {
+		return nil, fmt.Errorf("missing %q column in Parquet file", ValueColumn)
+	}
+	for i := range f.rowGroups {
+		rg := &f.rowGroups[i]
+		if len(rg.columns) != len(f.columns) {
+			return nil, fmt.Errorf("unexpected number of columns in row group #%d; got %d; want %d", i, len(rg.columns), len(f.columns))
+		}
+		if rg.numRows < 0 || rg.numRows > size {
+			return nil, fmt.Errorf("invalid number of rows in row group #%d: %d", i, rg.numRows)
+		}
+	}
+	return f, nil
+}
+
+// RowGroupsCount returns the number of row groups in f.
+func (f *File) RowGroupsCount() int {
+	return len(f.rowGroups)
+}
+
+// ReadRowGroup reads the row group with the given idx from f and calls callback for every series block in it.
+//
+// Consecutive rows with identical labels are passed to callback in a single block.
+// callback mustn't hold block after returning.
+//
+// It is safe calling ReadRowGroup concurrently for distinct row groups.
+func (f *File) ReadRowGroup(idx int, callback func(block *Block) error) error {
+	rg := &f.rowGroups[idx]
+	numRows := int(rg.numRows)
+	cvs := make([]columnValues, len(f.columns))
+	for i := range f.columns {
+		col := &f.columns[i]
+		cmd := &rg.columns[i].metaData
+		if rg.columns[i].filePath != "" {
+			return fmt.Errorf("column %q in row group #%d is stored in external file %q; this isn't supported", col.name, idx, rg.columns[i].filePath)
+		}
+		cv := &cvs[i]
+		if err := cv.readColumnChunk(f, col, cmd, numRows); err != nil {
+			return fmt.Errorf("cannot read column %q in row group #%d: %w", col.name, idx, err)
+		}
+	}
+
+	timestamps, err := cvs[f.timestampColumnIdx].getTimestamps(&f.columns[f.timestampColumnIdx])
+	if err != nil {
+		return err
+	}
+	values, err := cvs[f.valueColumnIdx].getValues(&f.columns[f.valueColumnIdx])
+	if err != nil {
+		return err
+	}
+
+	var block Block
+	start := 0
+	for i := 1; i <= numRows; i++ {
+		if i < numRows && f.haveEqualLabels(cvs, i-1, i) {
+			continue
+		}
+		block.reset()
+		for j := range f.columns {
+			if j == f.timestampColumnIdx || j == f.valueColumnIdx {
+				continue
+			}
+			value := cvs[j].strs[start]
+			if value == "" {
+				continue
+			}
+			block.Labels = append(block.Labels, prompbmarshal.Label{
+				Name:  f.columns[j].name,
+				Value: value,
+			})
+		}
+		block.Timestamps = append(block.Timestamps, timestamps[start:i]...)
+		block.Values = append(block.Values, values[start:i]...)
+		if err := callback(&block); err != nil {
+			return err
+		}
+		start = i
+	}
+	return nil
+}
+
+func (f *File) haveEqualLabels(cvs []columnValues, i, j int) bool {
+	for k := range f.columns {
+		if k == f.timestampColumnIdx || k == f.valueColumnIdx {
+			continue
+		}
+		strs := cvs[k].strs
+		if strs[i] != strs[j] {
+			return false
+		}
+	}
+	return true
+}
+
+// columnValues holds the decoded values for a column chunk.
+//
+// BYTE_ARRAY values are stored in strs, INT32 and INT64 values are stored in ints, while FLOAT and DOUBLE values are stored in floats.
+// Missing values are stored as empty strings in strs and are counted in nulls for other types.
+type columnValues struct {
+	strs   []string
+	ints   []int64
+	floats []float64
+	nulls  int
+
+	dict *columnValues
+
+	defLevels []uint32
+	indexes   []uint32
+}
+
+func (cv *columnValues) len() int {
+	return len(cv.strs) + len(cv.ints) + len(cv.floats)
+}
+
+func (cv *columnValues) getTimestamps(col *column) ([]int64, error) {
+	if cv.nulls > 0 {
+		return nil, fmt.Errorf("%q column mustn't contain null values; found %d null values", col.name, cv.nulls)
+	}
+	timestamps := cv.ints
+	switch col.timeUnit {
+	case timeUnitMicros:
+		for i := range timestamps {
+			timestamps[i] /= 1e3
+		}
+	case timeUnitNanos:
+		for i := range timestamps {
+			timestamps[i] /= 1e6
+		}
+	}
+	return timestamps, nil
+}
+
+func (cv *columnValues) getValues(col *column) ([]float64, error) {
+	if cv.nulls > 0 {
+		return nil, fmt.Errorf("%q column mustn't contain null values; found %d null values", col.name, cv.nulls)
+	}
+	if col.typ == typeInt64 || col.typ == typeInt32 {
+		values := make([]float64, len(cv.ints))
+		for i, v := range cv.ints {
+			values[i] = float64(v)
+		}
+		return values, nil
+	}
+	return cv.floats, nil
+}
+
+// readAt reads len(dst) bytes at the given offset from r into dst.
+func readAt(r io.ReaderAt, dst []byte, offset int64) error {
+	n, err := r.ReadAt(dst, offset)
+	if n == len(dst) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (cv *columnValues) readColumnChunk(f *File, col *column, cmd *columnMetaData, numRows int) error {
+	if cmd.typ != col.typ {
+		return fmt.Errorf("column chunk type %d mismatches column type %d", cmd.typ, col.typ)
+	}
+	start := cmd.dataPageOffset
+	if cmd.dictionaryPageOffset > 0 && cmd.dictionaryPageOffset < start {
+		start = cmd.dictionaryPageOffset
+	}
+	end := start + cmd.totalCompressedSize
+	if start < 0 || end < start || end > f.size {
+		return fmt.Errorf("invalid column chunk bounds [%d..%d]; file size: %d bytes", start, end, f.size)
+	}
+	if cmd.numValues != int64(numRows) {
+		return fmt.Errorf("unexpected number of values in column chunk; got %d; want %d", cmd.numValues, numRows)
+	}
+	chunk := make([]byte, end-start)
+	if err := readAt(f.r, chunk, start); err != nil {
+		return fmt.Errorf("cannot read column chunk [%d..%d]: %w", start, end, err)
+	}
+	for cv.len()+cv.nulls < numRows {
+		if len(chunk) == 0 {
+			return fmt.Errorf("unexpected end of column chunk; read %d values out of %d", cv.len()+cv.nulls, numRows)
+		}
+		tr := &thriftReader{
+			b: chunk,
+		}
+		var ph pageHeader
+		if err := ph.unmarshal(tr); err != nil {
+			return fmt.Errorf("cannot unmarshal page header: %w", err)
+		}
+		if err := ph.validate(); err != nil {
+			return err
+		}
+		if int(ph.compressedPageSize) > len(tr.b) {
+			return fmt.Errorf("too big compressed page size: %d bytes; only %d bytes left in column chunk", ph.compressedPageSize, len(tr.b))
+		}
+		page := tr.b[:ph.compressedPageSize]
+		chunk = tr.b[ph.compressedPageSize:]
+		if err := cv.readPage(col, cmd.codec, &ph, page); err != nil {
+			return fmt.Errorf("cannot read page of type %d: %w", ph.typ, err)
+		}
+	}
+	if n := cv.len() + cv.nulls; n != numRows {
+		return fmt.Errorf("unexpected number of values read from column chunk; got %d; want %d", n, numRows)
+	}
+	return nil
+}
+
+func (cv *columnValues) readPage(col *column, codec int32, ph *pageHeader, page []byte) error {
+	switch ph.typ {
+	case pageTypeDictionary:
+		data, err := decompressPage(codec, page, int(ph.uncompressedPageSize))
+		if err != nil {
+			return err
+		}
+		if ph.encoding != encodingPlain && ph.encoding != encodingPlainDictionary {
+			return fmt.Errorf("unsupported dictionary page encoding: %d", ph.encoding)
+		}
+		cv.dict = &columnValues{}
+		return cv.dict.appendPlain(col.typ, data, int(ph.numValues))
+	case pageTypeData:
+		data, err := decompressPage(codec, page, int(ph.uncompressedPageSize))
+		if err != nil {
+			return err
+		}
+		numValues := int(ph.numValues)
+		cv.defLevels = cv.defLevels[:0]
+		if col.optional {
+			if len(data) < 4 {
+				return fmt.Errorf("missing definition levels length")
+			}
+			n := binary.LittleEndian.Uint32(data)
+			data = data[4:]
+			if uint64(n) > uint64(len(data)) {
+				return fmt.Errorf("too big definition levels length: %d bytes; only %d bytes left in the page", n, len(data))
+			}
+			cv.defLevels, err = decodeRLEHybrid(cv.defLevels, data[:n], 1, numValues)
+			if err != nil {
+				return fmt.Errorf("cannot decode definition levels: %w", err)
+			}
+			data = data[n:]
+		}
+		return cv.appendValues(col, ph.encoding, data, numValues)
+	case pageTypeDataV2:
+		levelsLen := int(ph.repetitionLevelsByteLength) + int(ph.definitionLevelsByteLength)
+		if levelsLen > len(page) {
+			return fmt.Errorf("too big levels length: %d bytes; page size: %d bytes", levelsLen, len(page))
+		}
+		numValues := int(ph.numValues)
+		cv.defLevels = cv.defLevels[:0]
+		if col.optional {
+			var err error
+			cv.defLevels, err = decodeRLEHybrid(cv.defLevels, page[ph.repetitionLevelsByteLength:levelsLen], 1, numValues)
+			if err != nil {
+				return fmt.Errorf("cannot decode definition levels: %w", err)
+			}
+		}
+		data := page[levelsLen:]
+		if ph.isCompressed {
+			var err error
+			data, err = decompressPage(codec, data, int(ph.uncompressedPageSize)-levelsLen)
+			if err != nil {
+				return err
+			}
+		}
+		return cv.appendValues(col, ph.encoding, data, numValues)
+	default:
+		// Skip index pages.
+		return nil
+	}
+}
+
+// appendValues appends numValues values from the data page with the given data to cv.
+//
+// Missing values are detected with cv.defLevels.
+func (cv *columnValues) appendValues(col *column, encoding int32, data []byte, numValues int) error {
+	valuesCount := numValues
+	if col.optional {
+		valuesCount = 0
+		for _, level := range cv.defLevels {
+			valuesCount += int(level)
+		}
+	}
+
+	tmp := &columnValues{}
+	switch encoding {
+	case encodingPlain:
+		if err := tmp.appendPlain(col.typ, data, valuesCount); err != nil {
+			return err
+		}
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if cv.dict == nil {
+			return fmt.Errorf("missing dictionary page")
+		}
+		if len(data) == 0 {
+			if valuesCount > 0 {
+				return fmt.Errorf("missing bit width for dictionary indexes")
+			}
+			break
+		}
+		bitWidth := int(data[0])
+		var err error
+		cv.indexes, err = decodeRLEHybrid(cv.indexes[:0], data[1:], bitWidth, valuesCount)
+		if err != nil {
+			return fmt.Errorf("cannot decode dictionary indexes: %w", err)
+		}
+		if err := tmp.appendFromDict(cv.dict, cv.indexes); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported data page encoding: %d", encoding)
+	}
+
+	if !col.optional {
+		cv.strs = append(cv.strs, tmp.strs...)
+		cv.ints = append(cv.ints, tmp.ints...)
+		cv.floats = append(cv.floats, tmp.floats...)
+		return nil
+	}
+	if col.typ != typeByteArray {
+		// Null values are forbidden for timestamps and values, so just count them.
+		cv.nulls += numValues - valuesCount
+		cv.ints = append(cv.ints, tmp.ints...)
+		cv.floats = append(cv.floats, tmp.floats...)
+		return nil
+	}
+	strs := tmp.strs
+	for _, level := range cv.defLevels {
+		if level == 0 {
+			cv.strs = append(cv.strs, "")
+			continue
+		}
+		cv.strs = append(cv.strs, strs[0])
+		strs = strs[1:]
+	}
+	return nil
+}
+
+func (cv *columnValues) appendPlain(typ int32, data []byte, n int) error {
+	switch typ {
+	case typeInt32:
+		if len(data) < 4*n {
+			return fmt.Errorf("too short data for %d INT32 values: %d bytes", n, len(data))
+		}
+		for i := 0; i < n; i++ {
+			cv.ints = append(cv.ints, int64(int32(binary.LittleEndian.Uint32(data[4*i:]))))
+		}
+	case typeInt64:
+		if len(data) < 8*n {
+			return fmt.Errorf("too short data for %d INT64 values: %d bytes", n, len(data))
+		}
+		for i := 0; i < n; i++ {
+			cv.ints = append(cv.ints, int64(binary.LittleEndian.Uint64(data[8*i:])))
+		}
+	case typeFloat:
+		if len(data) < 4*n {
+			return fmt.Errorf("too short data for %d FLOAT values: %d bytes", n, len(data))
+		}
+		for i := 0; i < n; i++ {
+			cv.floats = append(cv.floats, float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))))
+		}
+	case typeDouble:
+		if len(data) < 8*n {
+			return fmt.Errorf("too short data for %d DOUBLE values: %d bytes", n, len(data))
+		}
+		for i := 0; i < n; i++ {
+			cv.floats = append(cv.floats, math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:])))
+		}
+	case typeByteArray:
+		for i := 0; i < n; i++ {
+			if len(data) < 4 {
+				return fmt.Errorf("missing length for BYTE_ARRAY value #%d", i)
+			}
+			size := binary.LittleEndian.Uint32(data)
+			data = data[4:]
+			if uint64(size) > uint64(len(data)) {
+				return fmt.Errorf("too big BYTE_ARRAY value #%d length: %d bytes; only %d bytes left", i, size, len(data))
+			}
+			cv.strs = append(cv.strs, string(data[:size]))
+			data = data[size:]
+		}
+	default:
+		return fmt.Errorf("unsupported column type: %d", typ)
+	}
+	return nil
+}
+
+func (cv *columnValues) appendFromDict(dict *columnValues, indexes []uint32) error {
+	dictLen := uint32(dict.len())
+	for _, idx := range indexes {
+		if idx >= dictLen {
+			return fmt.Errorf("too big dictionary index: %d; dictionary size: %d", idx, dictLen)
+		}
+	}
+	switch {
+	case len(dict.strs) > 0:
+		for _, idx := range indexes {
+			cv.strs = append(cv.strs, dict.strs[idx])
+		}
+	case len(dict.ints) > 0:
+		for _, idx := range indexes {
+			cv.ints = append(cv.ints, dict.ints[idx])
+		}
+	case len(dict.floats) > 0:
+		for _, idx := range indexes {
+			cv.floats = append(cv.floats, dict.floats[idx])
+		}
+	}
+	return nil
+}
+
+func decompressPage(codec int32, src []byte, uncompressedSize int) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return src, nil
+	case codecSnappy:
+		dst, err := snappy.Decode(nil, src)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress snappy-compressed page: %w", err)
+		}
+		return dst, nil
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress gzip-compressed page: %w", err)
+		}
+		dst := make([]byte, 0, uncompressedSize)
+		bb := bytes.NewBuffer(dst)
+		if _, err := io.Copy(bb, zr); err != nil {
+			return nil, fmt.Errorf("cannot decompress gzip-compressed page: %w", err)
+		}
+		return bb.Bytes(), nil
+	case codecZstd:
+		dst, err := zstd.Decompress(make([]byte, 0, uncompressedSize), src)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decompress zstd-compressed page: %w", err)
+		}
+		return dst, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %d; supported codecs: UNCOMPRESSED, SNAPPY, GZIP, ZSTD", codec)
+	}
+}
+
+// decodeRLEHybrid appends n values encoded with RLE / bit-packing hybrid encoding from src to dst.
+//
+// See https://parquet.apache.org/docs/file-format/data-pages/encodings/#run-length-encoding--bit-packing-hybrid-rle--3
+func decodeRLEHybrid(dst []uint32, src []byte, bitWidth, n int) ([]uint32, error) {
+	if bitWidth < 0 || bitWidth > 32 {
+		return dst, fmt.Errorf("invalid bit width: %d", bitWidth)
+	}
+	dstLen := len(dst)
+	for len(dst)-dstLen < n {
+		header, size := binary.Uvarint(src)
+		if size <= 0 {
+			return dst, fmt.Errorf("cannot read run header; decoded %d values out of %d", len(dst)-dstLen, n)
+		}
+		src = src[size:]
+		remaining := n - (len(dst) - dstLen)
+		if header&1 == 0 {
+			// RLE run
+			runLen := header >> 1
+			valueSize := (bitWidth + 7) / 8
+			if len(src) < valueSize {
+				return dst, fmt.Errorf("missing value for RLE run")
+			}
+			var v uint32
+			for i := 0; i < valueSize; i++ {
+				v |= uint32(src[i]) << (8 * i)
+			}
+			src = src[valueSize:]
+			if runLen > uint64(remaining) {
+				runLen = uint64(remaining)
+			}
+			for i := uint64(0); i < runLen; i++ {
+				dst = append(dst, v)
+			}
+			continue
+		}
+
+		// Bit-packed run
+		groups := header >> 1
+		if groups > uint64(len(src)) {
+			return dst, fmt.Errorf("too big number of groups in bit-packed run: %d", groups)
+		}
+		valuesCount := int(groups) * 8
+		runSize := int(groups) * bitWidth
+		if runSize > len(src) {
+			// The last run may be truncated.
+			runSize = len(src)
+		}
+		packed := make([]byte, runSize+8)
+		copy(packed, src[:runSize])
+		src = src[runSize:]
+		if valuesCount > remaining {
+			valuesCount = remaining
+		}
+		if valuesCount*bitWidth > runSize*8 {
+			return dst, fmt.Errorf("too short bit-packed run; got %d bytes; want at least %d bits", runSize, valuesCount*bitWidth)
+		}
+		mask := uint64(1)<<bitWidth - 1
+		for i := 0; i < valuesCount; i++ {
+			bitPos := i * bitWidth
+			v := binary.LittleEndian.Uint64(packed[bitPos>>3:]) >> (bitPos & 7)
+			dst = append(dst, uint32(v&mask))
+		}
+	}
+	return dst, nil
+}
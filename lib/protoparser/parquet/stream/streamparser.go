@@ -0,0 +1,153 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/flagutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/parquet"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/writeconcurrencylimiter"
+	"github.com/VictoriaMetrics/metrics"
+)
+
+var maxFileSize = flagutil.NewBytes("import.maxParquetFileSize", 256*1024*1024, "The maximum size in bytes of a single Parquet file accepted by /api/v1/import/parquet. "+
+	"The file is stored in a temporary file at the system temporary directory during the import, since Parquet metadata is stored at the end of the file. "+
+	"Only the row groups, which are being processed, are loaded in memory")
+
+// Parse parses Parquet file from r and calls callback for the parsed blocks.
+//
+// The file is stored in a temporary file before parsing, since Parquet metadata is stored at the end of the file.
+// The callback can be called concurrently multiple times for distinct row groups of the file.
+//
+// callback shouldn't hold block after returning.
+func Parse(r io.Reader, isGzip bool, callback func(block *parquet.Block) error) error {
+	wcr := writeconcurrencylimiter.GetReader(r)
+	defer writeconcurrencylimiter.PutReader(wcr)
+	r = wcr
+
+	if isGzip {
+		zr, err := common.GetGzipReader(r)
+		if err != nil {
+			return fmt.Errorf("cannot read gzipped Parquet data: %w", err)
+		}
+		defer common.PutGzipReader(zr)
+		r = zr
+	}
+
+	tmpFile, err := os.CreateTemp("", "vm-parquet-import-")
+	if err != nil {
+		return fmt.Errorf("cannot create temporary file for Parquet data: %w", err)
+	}
+	defer func() {
+		fs.MustClose(tmpFile)
+		if err := os.Remove(tmpFile.Name()); err != nil {
+			logger.Errorf("cannot remove temporary file with Parquet data: %s", err)
+		}
+	}()
+
+	maxSize := maxFileSize.N
+	size, err := io.Copy(tmpFile, io.LimitReader(r, maxSize+1))
+	if err != nil {
+		readErrors.Inc()
+		return fmt.Errorf("cannot read Parquet file: %w", err)
+	}
+	readCalls.Inc()
+	if size > maxSize {
+		readErrors.Inc()
+		return fmt.Errorf("too big Parquet file; it mustn't exceed -import.maxParquetFileSize=%d bytes", maxSize)
+	}
+	f, err := parquet.OpenFile(tmpFile, size)
+	if err != nil {
+		parseErrors.Inc()
+		return fmt.Errorf("cannot open Parquet file: %w", err)
+	}
+	wcr.DecConcurrency()
+
+	ctx := &streamContext{}
+	for i := 0; i < f.RowGroupsCount(); i++ {
+		uw := getUnmarshalWork()
+		uw.f = f
+		uw.rowGroupIdx = i
+		uw.ctx = ctx
+		uw.callback = callback
+		ctx.wg.Add(1)
+		common.ScheduleUnmarshalWork(uw)
+	}
+	ctx.wg.Wait()
+	return ctx.err
+}
+
+type streamContext struct {
+	wg      sync.WaitGroup
+	errLock sync.Mutex
+	err     error
+}
+
+var (
+	readCalls  = metrics.NewCounter(`vm_protoparser_read_calls_total{type="parquet"}`)
+	readErrors = metrics.NewCounter(`vm_protoparser_read_errors_total{type="parquet"}`)
+	rowsRead   = metrics.NewCounter(`vm_protoparser_rows_read_total{type="parquet"}`)
+	blocksRead = metrics.NewCounter(`vm_protoparser_blocks_read_total{type="parquet"}`)
+
+	parseErrors   = metrics.NewCounter(`vm_protoparser_parse_errors_total{type="parquet"}`)
+	processErrors = metrics.NewCounter(`vm_protoparser_process_errors_total{type="parquet"}`)
+)
+
+type unmarshalWork struct {
+	f           *parquet.File
+	rowGroupIdx int
+	ctx         *streamContext
+	callback    func(block *parquet.Block) error
+}
+
+func (uw *unmarshalWork) reset() {
+	uw.f = nil
+	uw.rowGroupIdx = 0
+	uw.ctx = nil
+	uw.callback = nil
+}
+
+// Unmarshal implements common.UnmarshalWork
+func (uw *unmarshalWork) Unmarshal() {
+	var callbackErr error
+	err := uw.f.ReadRowGroup(uw.rowGroupIdx, func(block *parquet.Block) error {
+		blocksRead.Inc()
+		rowsRead.Add(len(block.Timestamps))
+		callbackErr = uw.callback(block)
+		return callbackErr
+	})
+	if err != nil && callbackErr == nil {
+		parseErrors.Inc()
+	}
+	ctx := uw.ctx
+	if err != nil {
+		processErrors.Inc()
+		ctx.errLock.Lock()
+		if ctx.err == nil {
+			ctx.err = fmt.Errorf("error when processing Parquet row group #%d: %w", uw.rowGroupIdx, err)
+		}
+		ctx.errLock.Unlock()
+	}
+	ctx.wg.Done()
+	putUnmarshalWork(uw)
+}
+
+func getUnmarshalWork() *unmarshalWork {
+	v := unmarshalWorkPool.Get()
+	if v == nil {
+		return &unmarshalWork{}
+	}
+	return v.(*unmarshalWork)
+}
+
+func putUnmarshalWork(uw *unmarshalWork) {
+	uw.reset()
+	unmarshalWorkPool.Put(uw)
+}
+
+var unmarshalWorkPool sync.Pool
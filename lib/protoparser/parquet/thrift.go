@@ -0,0 +1,298 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Thrift compact protocol types.
+//
+// See https://github.com/apache/thrift/blob/master/doc/specs/thrift-compact-protocol.md
+const (
+	thriftStop      = 0
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftByte      = 3
+	thriftI16       = 4
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftDouble    = 7
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftSet       = 10
+	thriftMap       = 11
+	thriftStruct    = 12
+)
+
+// thriftWriter marshals Parquet metadata with thrift compact protocol.
+type thriftWriter struct {
+	b []byte
+
+	lastFieldID  int16
+	fieldIDStack []int16
+}
+
+func (tw *thriftWriter) reset() {
+	tw.b = tw.b[:0]
+	tw.lastFieldID = 0
+	tw.fieldIDStack = tw.fieldIDStack[:0]
+}
+
+func (tw *thriftWriter) fieldHeader(id int16, typ byte) {
+	delta := id - tw.lastFieldID
+	if delta > 0 && delta <= 15 {
+		tw.b = append(tw.b, byte(delta<<4)|typ)
+	} else {
+		tw.b = append(tw.b, typ)
+		tw.b = binary.AppendVarint(tw.b, int64(id))
+	}
+	tw.lastFieldID = id
+}
+
+func (tw *thriftWriter) structBegin() {
+	tw.fieldIDStack = append(tw.fieldIDStack, tw.lastFieldID)
+	tw.lastFieldID = 0
+}
+
+func (tw *thriftWriter) structEnd() {
+	tw.b = append(tw.b, thriftStop)
+	n := len(tw.fieldIDStack) - 1
+	tw.lastFieldID = tw.fieldIDStack[n]
+	tw.fieldIDStack = tw.fieldIDStack[:n]
+}
+
+func (tw *thriftWriter) listBegin(elemType byte, size int) {
+	if size < 15 {
+		tw.b = append(tw.b, byte(size<<4)|elemType)
+	} else {
+		tw.b = append(tw.b, 0xf0|elemType)
+		tw.b = binary.AppendUvarint(tw.b, uint64(size))
+	}
+}
+
+func (tw *thriftWriter) i32(v int32) {
+	tw.b = binary.AppendVarint(tw.b, int64(v))
+}
+
+func (tw *thriftWriter) i64(v int64) {
+	tw.b = binary.AppendVarint(tw.b, v)
+}
+
+func (tw *thriftWriter) binary(s string) {
+	tw.b = binary.AppendUvarint(tw.b, uint64(len(s)))
+	tw.b = append(tw.b, s...)
+}
+
+func (tw *thriftWriter) fieldI32(id int16, v int32) {
+	tw.fieldHeader(id, thriftI32)
+	tw.i32(v)
+}
+
+func (tw *thriftWriter) fieldI64(id int16, v int64) {
+	tw.fieldHeader(id, thriftI64)
+	tw.i64(v)
+}
+
+func (tw *thriftWriter) fieldBinary(id int16, s string) {
+	tw.fieldHeader(id, thriftBinary)
+	tw.binary(s)
+}
+
+func (tw *thriftWriter) fieldBool(id int16, v bool) {
+	if v {
+		tw.fieldHeader(id, thriftBoolTrue)
+	} else {
+		tw.fieldHeader(id, thriftBoolFalse)
+	}
+}
+
+func (tw *thriftWriter) fieldStructBegin(id int16) {
+	tw.fieldHeader(id, thriftStruct)
+	tw.structBegin()
+}
+
+// fieldEmptyStruct writes an empty struct field, which is used for union members without fields.
+func (tw *thriftWriter) fieldEmptyStruct(id int16) {
+	tw.fieldStructBegin(id)
+	tw.structEnd()
+}
+
+// thriftReader unmarshals Parquet metadata marshaled with thrift compact protocol.
+type thriftReader struct {
+	b []byte
+}
+
+func (tr *thriftReader) readByte() (byte, error) {
+	if len(tr.b) == 0 {
+		return 0, fmt.Errorf("unexpected end of thrift data")
+	}
+	c := tr.b[0]
+	tr.b = tr.b[1:]
+	return c, nil
+}
+
+func (tr *thriftReader) readUvarint() (uint64, error) {
+	v, n := binary.Uvarint(tr.b)
+	if n <= 0 {
+		return 0, fmt.Errorf("cannot read varint from thrift data")
+	}
+	tr.b = tr.b[n:]
+	return v, nil
+}
+
+func (tr *thriftReader) readVarint() (int64, error) {
+	v, n := binary.Varint(tr.b)
+	if n <= 0 {
+		return 0, fmt.Errorf("cannot read zigzag varint from thrift data")
+	}
+	tr.b = tr.b[n:]
+	return v, nil
+}
+
+func (tr *thriftReader) readI32() (int32, error) {
+	v, err := tr.readVarint()
+	if err != nil {
+		return 0, err
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0, fmt.Errorf("too big i32 value: %d", v)
+	}
+	return int32(v), nil
+}
+
+func (tr *thriftReader) readI64() (int64, error) {
+	return tr.readVarint()
+}
+
+func (tr *thriftReader) readBinary() (string, error) {
+	n, err := tr.readUvarint()
+	if err != nil {
+		return "", err
+	}
+	if n > uint64(len(tr.b)) {
+		return "", fmt.Errorf("too big binary length in thrift data: %d bytes; only %d bytes left", n, len(tr.b))
+	}
+	s := string(tr.b[:n])
+	tr.b = tr.b[n:]
+	return s, nil
+}
+
+// readStruct reads struct fields and calls f for every field.
+//
+// f must read the field value or call tr.skip(fieldType).
+func (tr *thriftReader) readStruct(f func(fieldID int16, fieldType byte) error) error {
+	var lastFieldID int16
+	for {
+		c, err := tr.readByte()
+		if err != nil {
+			return err
+		}
+		fieldType := c & 0x0f
+		if fieldType == thriftStop {
+			return nil
+		}
+		fieldID := lastFieldID + int16(c>>4)
+		if c>>4 == 0 {
+			id, err := tr.readI32()
+			if err != nil {
+				return err
+			}
+			fieldID = int16(id)
+		}
+		lastFieldID = fieldID
+		if err := f(fieldID, fieldType); err != nil {
+			return fmt.Errorf("cannot read field #%d: %w", fieldID, err)
+		}
+	}
+}
+
+// readList reads list header and calls f for every list item.
+func (tr *thriftReader) readList(f func(elemType byte) error) error {
+	c, err := tr.readByte()
+	if err != nil {
+		return err
+	}
+	elemType := c & 0x0f
+	size := uint64(c >> 4)
+	if size == 15 {
+		size, err = tr.readUvarint()
+		if err != nil {
+			return err
+		}
+	}
+	if size > uint64(len(tr.b)) {
+		// Every list item occupies at least a single byte.
+		return fmt.Errorf("too big list size in thrift data: %d items; only %d bytes left", size, len(tr.b))
+	}
+	for i := uint64(0); i < size; i++ {
+		if err := f(elemType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skip skips the value of the given typ.
+func (tr *thriftReader) skip(typ byte) error {
+	switch typ {
+	case thriftBoolTrue, thriftBoolFalse:
+		// The value of bool struct field is stored in its type, while bool list items occupy a single byte.
+		// Struct fields are handled by the caller, so this is a list item.
+		_, err := tr.readByte()
+		return err
+	case thriftByte:
+		_, err := tr.readByte()
+		return err
+	case thriftI16, thriftI32, thriftI64:
+		_, err := tr.readVarint()
+		return err
+	case thriftDouble:
+		if len(tr.b) < 8 {
+			return fmt.Errorf("unexpected end of thrift data")
+		}
+		tr.b = tr.b[8:]
+		return nil
+	case thriftBinary:
+		_, err := tr.readBinary()
+		return err
+	case thriftList, thriftSet:
+		return tr.readList(tr.skip)
+	case thriftMap:
+		size, err := tr.readUvarint()
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			return nil
+		}
+		c, err := tr.readByte()
+		if err != nil {
+			return err
+		}
+		for i := uint64(0); i < size; i++ {
+			if err := tr.skip(c >> 4); err != nil {
+				return err
+			}
+			if err := tr.skip(c & 0x0f); err != nil {
+				return err
+			}
+		}
+		return nil
+	case thriftStruct:
+		return tr.readStruct(func(_ int16, fieldType byte) error {
+			return tr.skipField(fieldType)
+		})
+	default:
+		return fmt.Errorf("unsupported thrift type: %d", typ)
+	}
+}
+
+// skipField skips struct field value of the given fieldType.
+func (tr *thriftReader) skipField(fieldType byte) error {
+	if fieldType == thriftBoolTrue || fieldType == thriftBoolFalse {
+		// The value is stored in the field type.
+		return nil
+	}
+	return tr.skip(fieldType)
+}
@@ -0,0 +1,552 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/protoparser/common"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/storage"
+	"github.com/golang/snappy"
+)
+
+const (
+	// TimestampColumn is the name of the column with sample timestamps in milliseconds.
+	TimestampColumn = "timestamp"
+
+	// ValueColumn is the name of the column with sample values.
+	ValueColumn = "value"
+)
+
+const magic = "PAR1"
+
+// maxRowGroupRows is the maximum number of rows the Writer puts into a single row group.
+const maxRowGroupRows = 256 * 1024
+
+// Writer writes time series in Apache Parquet format.
+//
+// Every label is stored in a separate optional string column, while sample timestamps and values
+// are stored in TimestampColumn and ValueColumn columns.
+//
+// See https://parquet.apache.org/docs/file-format/
+type Writer struct {
+	// labelNames contains the names of label columns in the order they are stored in the file.
+	labelNames []string
+
+	// labelIdxs maps label name to its column index.
+	labelIdxs map[string]int
+
+	// builders contains per-worker rowGroupBuilder items.
+	builders sync.Map
+
+	// mu protects the fields below.
+	mu        sync.Mutex
+	w         io.Writer
+	offset    int64
+	rowGroups []rowGroupMeta
+	err       error
+}
+
+// NewWriter returns new Writer for writing series with the given labelNames to w.
+//
+// All the labels of the written series must be listed in labelNames.
+// Call Close when all the series are written.
+func NewWriter(w io.Writer, labelNames []string) (*Writer, error) {
+	labelNames = common.NormalizeLabelNames(labelNames)
+	labelIdxs := make(map[string]int, len(labelNames))
+	for i, name := range labelNames {
+		if name == TimestampColumn || name == ValueColumn {
+			return nil, fmt.Errorf("label name %q clashes with the name of Parquet column for samples", name)
+		}
+		labelIdxs[name] = i
+	}
+	pw := &Writer{
+		labelNames: labelNames,
+		labelIdxs:  labelIdxs,
+		w:          w,
+	}
+	if err := pw.write([]byte(magic)); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WriteSeries writes the series with the given mn, timestamps and values to pw.
+//
+// It is safe calling WriteSeries concurrently with distinct workerID values.
+func (pw *Writer) WriteSeries(workerID uint, mn *storage.MetricName, timestamps []int64, values []float64) error {
+	v, ok := pw.builders.Load(workerID)
+	if !ok {
+		v = pw.newRowGroupBuilder()
+		pw.builders.Store(workerID, v)
+	}
+	rgb := v.(*rowGroupBuilder)
+	if err := rgb.addSeries(mn, timestamps, values); err != nil {
+		return err
+	}
+	if len(rgb.timestamps) < maxRowGroupRows {
+		return nil
+	}
+	return pw.writeRowGroup(rgb)
+}
+
+// Close writes the remaining row groups and the file footer to the underlying writer.
+//
+// Close doesn't close the underlying writer.
+func (pw *Writer) Close() error {
+	var err error
+	pw.builders.Range(func(_, v any) bool {
+		rgb := v.(*rowGroupBuilder)
+		if len(rgb.timestamps) > 0 {
+			err = pw.writeRowGroup(rgb)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	tw := &thriftWriter{}
+	pw.marshalFileMetaData(tw)
+	footer := tw.b
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, magic...)
+	return pw.writeLocked(footer)
+}
+
+func (pw *Writer) write(data []byte) error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.writeLocked(data)
+}
+
+func (pw *Writer) writeLocked(data []byte) error {
+	if pw.err != nil {
+		return pw.err
+	}
+	n, err := pw.w.Write(data)
+	pw.offset += int64(n)
+	if err != nil {
+		pw.err = fmt.Errorf("cannot write Parquet data: %w", err)
+	}
+	return pw.err
+}
+
+func (pw *Writer) writeRowGroup(rgb *rowGroupBuilder) error {
+	// Encode the row group outside the lock, so multiple row groups could be encoded in parallel.
+	rgm := rgb.encode()
+	rgb.reset()
+
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	offset := pw.offset
+	if err := pw.writeLocked(rgb.buf); err != nil {
+		return err
+	}
+	for i := range rgm.columns {
+		cm := &rgm.columns[i]
+		cm.dataPageOffset += offset
+		if cm.dictionaryPageOffset >= 0 {
+			cm.dictionaryPageOffset += offset
+		}
+	}
+	pw.rowGroups = append(pw.rowGroups, rgm)
+	return nil
+}
+
+func (pw *Writer) marshalFileMetaData(tw *thriftWriter) {
+	var numRows int64
+	for i := range pw.rowGroups {
+		numRows += pw.rowGroups[i].numRows
+	}
+
+	tw.structBegin()
+	tw.fieldI32(1, 1)
+
+	// schema
+	tw.fieldHeader(2, thriftList)
+	tw.listBegin(thriftStruct, 1+len(pw.labelNames)+2)
+	tw.structBegin()
+	tw.fieldBinary(4, "schema")
+	tw.fieldI32(5, int32(len(pw.labelNames)+2))
+	tw.structEnd()
+	for _, name := range pw.labelNames {
+		tw.structBegin()
+		tw.fieldI32(1, typeByteArray)
+		tw.fieldI32(3, repetitionOptional)
+		tw.fieldBinary(4, name)
+		tw.fieldI32(6, convertedTypeUTF8)
+		tw.fieldStructBegin(10)
+		// StringType
+		tw.fieldEmptyStruct(1)
+		tw.structEnd()
+		tw.structEnd()
+	}
+	tw.structBegin()
+	tw.fieldI32(1, typeInt64)
+	tw.fieldI32(3, repetitionRequired)
+	tw.fieldBinary(4, TimestampColumn)
+	tw.fieldI32(6, convertedTypeTimestampMillis)
+	tw.fieldStructBegin(10)
+	// TimestampType
+	tw.fieldStructBegin(8)
+	tw.fieldBool(1, true)
+	tw.fieldStructBegin(2)
+	tw.fieldEmptyStruct(timeUnitMillis)
+	tw.structEnd()
+	tw.structEnd()
+	tw.structEnd()
+	tw.structEnd()
+	tw.structBegin()
+	tw.fieldI32(1, typeDouble)
+	tw.fieldI32(3, repetitionRequired)
+	tw.fieldBinary(4, ValueColumn)
+	tw.structEnd()
+
+	tw.fieldI64(3, numRows)
+
+	// row groups
+	tw.fieldHeader(4, thriftList)
+	tw.listBegin(thriftStruct, len(pw.rowGroups))
+	for i := range pw.rowGroups {
+		pw.rowGroups[i].marshal(tw)
+	}
+
+	tw.fieldBinary(6, "VictoriaMetrics")
+	tw.structEnd()
+}
+
+type rowGroupMeta struct {
+	columns       []columnChunkMeta
+	numRows       int64
+	totalByteSize int64
+}
+
+func (rgm *rowGroupMeta) marshal(tw *thriftWriter) {
+	tw.structBegin()
+	tw.fieldHeader(1, thriftList)
+	tw.listBegin(thriftStruct, len(rgm.columns))
+	for i := range rgm.columns {
+		rgm.columns[i].marshal(tw)
+	}
+	tw.fieldI64(2, rgm.totalByteSize)
+	tw.fieldI64(3, rgm.numRows)
+	tw.structEnd()
+}
+
+type columnChunkMeta struct {
+	typ       int32
+	name      string
+	encodings []int32
+	numValues int64
+
+	uncompressedSize int64
+	compressedSize   int64
+
+	dataPageOffset int64
+
+	// dictionaryPageOffset is negative if the column chunk has no dictionary page.
+	dictionaryPageOffset int64
+}
+
+func (cm *columnChunkMeta) marshal(tw *thriftWriter) {
+	fileOffset := cm.dataPageOffset
+	if cm.dictionaryPageOffset >= 0 {
+		fileOffset = cm.dictionaryPageOffset
+	}
+
+	tw.structBegin()
+	tw.fieldI64(2, fileOffset)
+	tw.fieldStructBegin(3)
+	tw.fieldI32(1, cm.typ)
+	tw.fieldHeader(2, thriftList)
+	tw.listBegin(thriftI32, len(cm.encodings))
+	for _, enc := range cm.encodings {
+		tw.i32(enc)
+	}
+	tw.fieldHeader(3, thriftList)
+	tw.listBegin(thriftBinary, 1)
+	tw.binary(cm.name)
+	tw.fieldI32(4, codecSnappy)
+	tw.fieldI64(5, cm.numValues)
+	tw.fieldI64(6, cm.uncompressedSize)
+	tw.fieldI64(7, cm.compressedSize)
+	tw.fieldI64(9, cm.dataPageOffset)
+	if cm.dictionaryPageOffset >= 0 {
+		tw.fieldI64(11, cm.dictionaryPageOffset)
+	}
+	tw.structEnd()
+	tw.structEnd()
+}
+
+// rowGroupBuilder accumulates series for a single row group.
+type rowGroupBuilder struct {
+	pw *Writer
+
+	// seriesRows contains the number of rows per each series in the row group.
+	seriesRows []int
+
+	// labelValues contains per-series dictionary indexes for every label column.
+	// Missing labels are marked with -1.
+	labelValues [][]int32
+	dicts       []dictionary
+
+	timestamps []int64
+	values     []float64
+
+	// buf contains the encoded row group.
+	buf []byte
+
+	pageBuf       []byte
+	compressedBuf []byte
+	tw            thriftWriter
+}
+
+type dictionary struct {
+	m      map[string]int32
+	values []string
+}
+
+func (pw *Writer) newRowGroupBuilder() *rowGroupBuilder {
+	rgb := &rowGroupBuilder{
+		pw:          pw,
+		labelValues: make([][]int32, len(pw.labelNames)),
+		dicts:       make([]dictionary, len(pw.labelNames)),
+	}
+	for i := range rgb.dicts {
+		rgb.dicts[i].m = make(map[string]int32)
+	}
+	return rgb
+}
+
+func (rgb *rowGroupBuilder) reset() {
+	rgb.seriesRows = rgb.seriesRows[:0]
+	for i := range rgb.labelValues {
+		rgb.labelValues[i] = rgb.labelValues[i][:0]
+	}
+	for i := range rgb.dicts {
+		d := &rgb.dicts[i]
+		clear(d.m)
+		clear(d.values)
+		d.values = d.values[:0]
+	}
+	rgb.timestamps = rgb.timestamps[:0]
+	rgb.values = rgb.values[:0]
+}
+
+func (rgb *rowGroupBuilder) addSeries(mn *storage.MetricName, timestamps []int64, values []float64) error {
+	if len(timestamps) != len(values) {
+		return fmt.Errorf("BUG: len(timestamps)=%d must match len(values)=%d", len(timestamps), len(values))
+	}
+	if len(timestamps) == 0 {
+		return nil
+	}
+	seriesIdx := len(rgb.seriesRows)
+	for i := range rgb.labelValues {
+		rgb.labelValues[i] = append(rgb.labelValues[i], -1)
+	}
+	if len(mn.MetricGroup) > 0 {
+		if err := rgb.setLabelValue(seriesIdx, []byte("__name__"), mn.MetricGroup); err != nil {
+			return err
+		}
+	}
+	for i := range mn.Tags {
+		tag := &mn.Tags[i]
+		if err := rgb.setLabelValue(seriesIdx, tag.Key, tag.Value); err != nil {
+			return err
+		}
+	}
+	rgb.seriesRows = append(rgb.seriesRows, len(timestamps))
+	rgb.timestamps = append(rgb.timestamps, timestamps...)
+	rgb.values = append(rgb.values, values...)
+	return nil
+}
+
+func (rgb *rowGroupBuilder) setLabelValue(seriesIdx int, name, value []byte) error {
+	columnIdx, ok := rgb.pw.labelIdxs[string(name)]
+	if !ok {
+		// Roll back the partially added series.
+		for i := range rgb.labelValues {
+			rgb.labelValues[i] = rgb.labelValues[i][:seriesIdx]
+		}
+		return fmt.Errorf("unexpected label %q, which is missing in the Parquet schema", name)
+	}
+	d := &rgb.dicts[columnIdx]
+	id, ok := d.m[string(value)]
+	if !ok {
+		s := string(value)
+		id = int32(len(d.values))
+		d.m[s] = id
+		d.values = append(d.values, s)
+	}
+	rgb.labelValues[columnIdx][seriesIdx] = id
+	return nil
+}
+
+// encode encodes the accumulated series into rgb.buf and returns the row group metadata with offsets relative to rgb.buf start.
+func (rgb *rowGroupBuilder) encode() rowGroupMeta {
+	rgb.buf = rgb.buf[:0]
+	numRows := int64(len(rgb.timestamps))
+	rgm := rowGroupMeta{
+		columns: make([]columnChunkMeta, 0, len(rgb.labelValues)+2),
+		numRows: numRows,
+	}
+
+	for i, name := range rgb.pw.labelNames {
+		cm := columnChunkMeta{
+			typ:                  typeByteArray,
+			name:                 name,
+			numValues:            numRows,
+			dictionaryPageOffset: -1,
+		}
+		d := &rgb.dicts[i]
+		if len(d.values) == 0 {
+			// All the values are missing - store only definition levels.
+			cm.encodings = []int32{encodingPlain, encodingRLE}
+			cm.dataPageOffset = int64(len(rgb.buf))
+			rgb.pageBuf = rgb.appendDefinitionLevels(rgb.pageBuf[:0], i)
+			rgb.writePage(&cm, pageTypeData, len(rgb.timestamps), encodingPlain)
+		} else {
+			cm.encodings = []int32{encodingPlain, encodingRLE, encodingRLEDictionary}
+			cm.dictionaryPageOffset = int64(len(rgb.buf))
+			pageBuf := rgb.pageBuf[:0]
+			for _, v := range d.values {
+				pageBuf = binary.LittleEndian.AppendUint32(pageBuf, uint32(len(v)))
+				pageBuf = append(pageBuf, v...)
+			}
+			rgb.pageBuf = pageBuf
+			rgb.writePage(&cm, pageTypeDictionary, len(d.values), encodingPlain)
+
+			cm.dataPageOffset = int64(len(rgb.buf))
+			rgb.pageBuf = rgb.appendDefinitionLevels(rgb.pageBuf[:0], i)
+			rgb.pageBuf = rgb.appendDictionaryIndexes(rgb.pageBuf, i)
+			rgb.writePage(&cm, pageTypeData, len(rgb.timestamps), encodingRLEDictionary)
+		}
+		rgm.columns = append(rgm.columns, cm)
+	}
+
+	cm := columnChunkMeta{
+		typ:                  typeInt64,
+		name:                 TimestampColumn,
+		encodings:            []int32{encodingPlain},
+		numValues:            numRows,
+		dataPageOffset:       int64(len(rgb.buf)),
+		dictionaryPageOffset: -1,
+	}
+	pageBuf := rgb.pageBuf[:0]
+	for _, ts := range rgb.timestamps {
+		pageBuf = binary.LittleEndian.AppendUint64(pageBuf, uint64(ts))
+	}
+	rgb.pageBuf = pageBuf
+	rgb.writePage(&cm, pageTypeData, len(rgb.timestamps), encodingPlain)
+	rgm.columns = append(rgm.columns, cm)
+
+	cm = columnChunkMeta{
+		typ:                  typeDouble,
+		name:                 ValueColumn,
+		encodings:            []int32{encodingPlain},
+		numValues:            numRows,
+		dataPageOffset:       int64(len(rgb.buf)),
+		dictionaryPageOffset: -1,
+	}
+	pageBuf = rgb.pageBuf[:0]
+	for _, v := range rgb.values {
+		pageBuf = binary.LittleEndian.AppendUint64(pageBuf, math.Float64bits(v))
+	}
+	rgb.pageBuf = pageBuf
+	rgb.writePage(&cm, pageTypeData, len(rgb.values), encodingPlain)
+	rgm.columns = append(rgm.columns, cm)
+
+	for i := range rgm.columns {
+		rgm.totalByteSize += rgm.columns[i].uncompressedSize
+	}
+	return rgm
+}
+
+// appendDefinitionLevels appends RLE-encoded definition levels for the label column with the given columnIdx to dst.
+//
+// The encoded levels are prefixed with their length as required by data pages v1.
+func (rgb *rowGroupBuilder) appendDefinitionLevels(dst []byte, columnIdx int) []byte {
+	dst = append(dst, 0, 0, 0, 0)
+	dstLen := len(dst)
+	labelValues := rgb.labelValues[columnIdx]
+	runLen := 0
+	prevLevel := int32(-1)
+	for i, id := range labelValues {
+		level := int32(1)
+		if id < 0 {
+			level = 0
+		}
+		if level != prevLevel && runLen > 0 {
+			dst = appendRLERun(dst, runLen, uint32(prevLevel), 1)
+			runLen = 0
+		}
+		prevLevel = level
+		runLen += rgb.seriesRows[i]
+	}
+	if runLen > 0 {
+		dst = appendRLERun(dst, runLen, uint32(prevLevel), 1)
+	}
+	binary.LittleEndian.PutUint32(dst[dstLen-4:], uint32(len(dst)-dstLen))
+	return dst
+}
+
+// appendDictionaryIndexes appends RLE-encoded dictionary indexes for the label column with the given columnIdx to dst.
+func (rgb *rowGroupBuilder) appendDictionaryIndexes(dst []byte, columnIdx int) []byte {
+	bitWidth := bits.Len32(uint32(len(rgb.dicts[columnIdx].values) - 1))
+	if bitWidth == 0 {
+		// Some Parquet readers do not support zero bit width.
+		bitWidth = 1
+	}
+	dst = append(dst, byte(bitWidth))
+	labelValues := rgb.labelValues[columnIdx]
+	runLen := 0
+	prevID := int32(-1)
+	for i, id := range labelValues {
+		if id < 0 {
+			// Missing values are skipped, since they are marked in definition levels.
+			continue
+		}
+		if id != prevID && runLen > 0 {
+			dst = appendRLERun(dst, runLen, uint32(prevID), bitWidth)
+			runLen = 0
+		}
+		prevID = id
+		runLen += rgb.seriesRows[i]
+	}
+	if runLen > 0 {
+		dst = appendRLERun(dst, runLen, uint32(prevID), bitWidth)
+	}
+	return dst
+}
+
+// appendRLERun appends RLE run of the given length for the given value to dst.
+//
+// See https://parquet.apache.org/docs/file-format/data-pages/encodings/#run-length-encoding--bit-packing-hybrid-rle--3
+func appendRLERun(dst []byte, runLen int, value uint32, bitWidth int) []byte {
+	dst = binary.AppendUvarint(dst, uint64(runLen)<<1)
+	for i := 0; i < (bitWidth+7)/8; i++ {
+		dst = append(dst, byte(value>>(8*i)))
+	}
+	return dst
+}
+
+// writePage compresses rgb.pageBuf and appends it to rgb.buf together with the page header.
+func (rgb *rowGroupBuilder) writePage(cm *columnChunkMeta, typ int32, numValues, encoding int) {
+	rgb.compressedBuf = snappy.Encode(rgb.compressedBuf[:cap(rgb.compressedBuf)], rgb.pageBuf)
+
+	rgb.tw.reset()
+	marshalPageHeader(&rgb.tw, typ, len(rgb.pageBuf), len(rgb.compressedBuf), numValues, encoding)
+	rgb.buf = append(rgb.buf, rgb.tw.b...)
+	rgb.buf = append(rgb.buf, rgb.compressedBuf...)
+
+	cm.uncompressedSize += int64(len(rgb.tw.b) + len(rgb.pageBuf))
+	cm.compressedSize += int64(len(rgb.tw.b) + len(rgb.compressedBuf))
+}
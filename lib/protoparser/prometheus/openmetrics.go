@@ -0,0 +1,51 @@
+package prometheus
+
+import (
+	"strings"
+)
+
+// AppendOpenMetricsWithoutCreated appends OpenMetrics text exposition data from src to dst
+// after removing `<family>_created` series for counter, histogram, gaugehistogram and summary metric families.
+//
+// `_created` series contain the creation timestamp for the metric family instead of a measurement,
+// so they are dropped in the same way as Prometheus does.
+// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#counter-1
+//
+// The rest of OpenMetrics features such as `# UNIT` and `# EOF` comments and exemplars are handled by Rows.Unmarshal.
+func AppendOpenMetricsWithoutCreated(dst []byte, src string) []byte {
+	var mds [1]Metadata
+	createdName := ""
+	for len(src) > 0 {
+		line := src
+		n := strings.IndexByte(src, '\n')
+		if n < 0 {
+			src = ""
+		} else {
+			line = src[:n+1]
+			src = src[n+1:]
+		}
+		s := skipLeadingWhitespace(line)
+		if len(s) > 0 && s[0] == '#' {
+			md := appendMetadataFromLine(mds[:0], strings.TrimSuffix(line, "\n"))
+			if len(md) > 0 && md[0].Type != "" {
+				createdName = ""
+				switch md[0].Type {
+				case "counter", "histogram", "gaugehistogram", "summary":
+					createdName = md[0].Metric + "_created"
+				}
+			}
+		} else if createdName != "" && isMetricWithName(s, createdName) {
+			continue
+		}
+		dst = append(dst, line...)
+	}
+	return dst
+}
+
+func isMetricWithName(s, name string) bool {
+	if !strings.HasPrefix(s, name) {
+		return false
+	}
+	s = s[len(name):]
+	return len(s) > 0 && (s[0] == '{' || s[0] == ' ' || s[0] == '\t')
+}
@@ -0,0 +1,60 @@
+package prometheus
+
+import (
+	"testing"
+)
+
+func TestAppendOpenMetricsWithoutCreated(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+
+		result := AppendOpenMetricsWithoutCreated(nil, s)
+		if string(result) != resultExpected {
+			t.Fatalf("unexpected result\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+
+	f("", "")
+	f("foo 1\n", "foo 1\n")
+
+	// _created series must be dropped for counters, histograms and summaries
+	f(`# TYPE foo counter
+# UNIT foo seconds
+foo_total{a="b"} 12 # {trace_id="abc"} 1 1520879607.789
+foo_created{a="b"} 1520430000.123
+# TYPE bar histogram
+bar_bucket{le="+Inf"} 3
+bar_count 3
+bar_sum 1.5
+bar_created 1520430000
+# TYPE baz summary
+baz_count 2
+baz_created	1520430000
+# EOF
+`, `# TYPE foo counter
+# UNIT foo seconds
+foo_total{a="b"} 12 # {trace_id="abc"} 1 1520879607.789
+# TYPE bar histogram
+bar_bucket{le="+Inf"} 3
+bar_count 3
+bar_sum 1.5
+# TYPE baz summary
+baz_count 2
+# EOF
+`)
+
+	// _created series must be preserved for gauges and for other families
+	f(`# TYPE foo gauge
+foo_created 123
+# TYPE bar counter
+bar_total 1
+bar_created_total 5
+foo_created 456
+# EOF`, `# TYPE foo gauge
+foo_created 123
+# TYPE bar counter
+bar_total 1
+bar_created_total 5
+foo_created 456
+# EOF`)
+}
@@ -63,6 +63,28 @@ type Row struct {
 	Tags      []Tag
 	Value     float64
 	Timestamp int64
+
+	// Exemplar is an optional OpenMetrics exemplar for the row.
+	//
+	// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#exemplars
+	Exemplar Exemplar
+}
+
+// Exemplar is an OpenMetrics exemplar.
+type Exemplar struct {
+	// Tags contains exemplar labels such as trace_id.
+	Tags []Tag
+
+	// Value is exemplar value.
+	Value float64
+
+	// Timestamp is exemplar timestamp in milliseconds. It is set to 0 if the exemplar has no timestamp.
+	Timestamp int64
+}
+
+// HasExemplar returns true if r contains an exemplar.
+func (r *Row) HasExemplar() bool {
+	return len(r.Exemplar.Tags) > 0
 }
 
 func (r *Row) reset() {
@@ -70,14 +92,7 @@ func (r *Row) reset() {
 	r.Tags = nil
 	r.Value = 0
 	r.Timestamp = 0
-}
-
-func skipTrailingComment(s string) string {
-	n := strings.IndexByte(s, '#')
-	if n < 0 {
-		return s
-	}
-	return s[:n]
+	r.Exemplar = Exemplar{}
 }
 
 func skipLeadingWhitespace(s string) string {
@@ -114,6 +129,10 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 	r.reset()
 	s = skipLeadingWhitespace(s)
 	n := strings.IndexByte(s, '{')
+	if n >= 0 && nextWhitespace(skipTrailingWhitespace(s[:n])) >= 0 {
+		// The '{' char belongs to the exemplar for the metric without tags.
+		n = -1
+	}
 	if n >= 0 {
 		// Tags found. Parse them.
 		r.Metric = skipTrailingWhitespace(s[:n])
@@ -143,7 +162,19 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 		return tagsPool, fmt.Errorf("metric cannot be empty")
 	}
 	s = skipLeadingWhitespace(s)
-	s = skipTrailingComment(s)
+	if n := strings.IndexByte(s, '#'); n >= 0 {
+		tagsPoolLen := len(tagsPool)
+		var err error
+		tagsPool, err = r.Exemplar.unmarshal(s[n+1:], tagsPool, noEscapes)
+		if err != nil {
+			// Ignore invalid exemplar instead of returning an error,
+			// since trailing comments may contain arbitrary text.
+			r.Exemplar = Exemplar{}
+			tagsPool = tagsPool[:tagsPoolLen]
+			invalidExemplars.Inc()
+		}
+		s = s[:n]
+	}
 	if len(s) == 0 {
 		return tagsPool, fmt.Errorf("value cannot be empty")
 	}
@@ -174,6 +205,11 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 	if err != nil {
 		return tagsPool, fmt.Errorf("cannot parse timestamp %q: %w", s, err)
 	}
+	r.Timestamp = parseTimestamp(ts)
+	return tagsPool, nil
+}
+
+func parseTimestamp(ts float64) int64 {
 	if ts >= -1<<31 && ts < 1<<31 {
 		// This looks like OpenMetrics timestamp in Unix seconds.
 		// Convert it to milliseconds.
@@ -181,10 +217,153 @@ func (r *Row) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error)
 		// See https://github.com/OpenObservability/OpenMetrics/blob/master/specification/OpenMetrics.md#timestamps
 		ts *= 1000
 	}
-	r.Timestamp = int64(ts)
+	return int64(ts)
+}
+
+// unmarshal unmarshals OpenMetrics exemplar from s.
+//
+// s must contain the exemplar after the '#' char, e.g. ` {trace_id="abc"} 0.5 1520879607.789`.
+// Comments, which do not look like exemplars, are ignored.
+func (e *Exemplar) unmarshal(s string, tagsPool []Tag, noEscapes bool) ([]Tag, error) {
+	s = skipLeadingWhitespace(s)
+	if len(s) == 0 || s[0] != '{' {
+		// This is an ordinary trailing comment.
+		return tagsPool, nil
+	}
+	tagsStart := len(tagsPool)
+	s, tagsPool, err := unmarshalTags(tagsPool, s[1:], noEscapes)
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot unmarshal tags: %w", err)
+	}
+	tags := tagsPool[tagsStart:]
+	s = skipTrailingWhitespace(skipLeadingWhitespace(s))
+	if len(s) == 0 {
+		return tagsPool, fmt.Errorf("value cannot be empty")
+	}
+	n := nextWhitespace(s)
+	if n < 0 {
+		n = len(s)
+	}
+	v, err := fastfloat.Parse(s[:n])
+	if err != nil {
+		return tagsPool, fmt.Errorf("cannot parse value %q: %w", s[:n], err)
+	}
+	e.Value = v
+	s = skipLeadingWhitespace(s[n:])
+	if len(s) > 0 {
+		ts, err := fastfloat.Parse(s)
+		if err != nil {
+			return tagsPool, fmt.Errorf("cannot parse timestamp %q: %w", s, err)
+		}
+		e.Timestamp = parseTimestamp(ts)
+	}
+	e.Tags = tags[:len(tags):len(tags)]
 	return tagsPool, nil
 }
 
+// Metadata is metadata for the metric family obtained from `# HELP`, `# TYPE` and `# UNIT` comments.
+type Metadata struct {
+	// Metric is the metric family name.
+	Metric string
+
+	// Type is the metric type such as counter or gauge.
+	Type string
+
+	// Help is the description for the metric family.
+	Help string
+
+	// Unit is the unit for the metric family.
+	Unit string
+}
+
+// AppendMetadata appends metadata obtained from `# HELP`, `# TYPE` and `# UNIT` comments in s to dst and returns the result.
+//
+// Adjacent comments for the same metric family are merged into a single Metadata entry.
+//
+// The appended entries may refer to s, so s shouldn't be modified while they are in use.
+func AppendMetadata(dst []Metadata, s string) []Metadata {
+	for len(s) > 0 {
+		n := strings.IndexByte(s, '\n')
+		if n < 0 {
+			// The last line.
+			return appendMetadataFromLine(dst, s)
+		}
+		dst = appendMetadataFromLine(dst, s[:n])
+		s = s[n+1:]
+	}
+	return dst
+}
+
+func appendMetadataFromLine(dst []Metadata, s string) []Metadata {
+	if len(s) > 0 && s[len(s)-1] == '\r' {
+		s = s[:len(s)-1]
+	}
+	s = skipLeadingWhitespace(s)
+	if len(s) == 0 || s[0] != '#' {
+		return dst
+	}
+	s = skipLeadingWhitespace(s[1:])
+	n := nextWhitespace(s)
+	if n < 0 {
+		return dst
+	}
+	kind := s[:n]
+	if kind != "HELP" && kind != "TYPE" && kind != "UNIT" {
+		// Skip ordinary comment
+		return dst
+	}
+	s = skipLeadingWhitespace(s[n:])
+	metric := s
+	value := ""
+	if n := nextWhitespace(s); n >= 0 {
+		metric = s[:n]
+		value = skipLeadingWhitespace(s[n:])
+	}
+	if len(metric) == 0 {
+		return dst
+	}
+	if len(dst) == 0 || dst[len(dst)-1].Metric != metric {
+		dst = append(dst, Metadata{
+			Metric: metric,
+		})
+	}
+	md := &dst[len(dst)-1]
+	switch kind {
+	case "HELP":
+		md.Help = unescapeHelp(value)
+	case "TYPE":
+		md.Type = skipTrailingWhitespace(value)
+	case "UNIT":
+		md.Unit = skipTrailingWhitespace(value)
+	}
+	return dst
+}
+
+// unescapeHelp unescapes `\\` and `\n` sequences in s according to
+// https://github.com/prometheus/docs/blob/master/content/docs/instrumenting/exposition_formats.md#comments-help-text-and-type-information
+func unescapeHelp(s string) string {
+	n := strings.IndexByte(s, '\\')
+	if n < 0 {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for n >= 0 && n+1 < len(s) {
+		b = append(b, s[:n]...)
+		switch s[n+1] {
+		case '\\':
+			b = append(b, '\\')
+		case 'n':
+			b = append(b, '\n')
+		default:
+			b = append(b, s[n:n+2]...)
+		}
+		s = s[n+2:]
+		n = strings.IndexByte(s, '\\')
+	}
+	b = append(b, s...)
+	return string(b)
+}
+
 var rowsReadScrape = metrics.NewCounter(`vm_protoparser_rows_read_total{type="promscrape"}`)
 
 func unmarshalRows(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger func(s string)) ([]Row, []Tag) {
@@ -237,6 +416,8 @@ func unmarshalRow(dst []Row, s string, tagsPool []Tag, noEscapes bool, errLogger
 
 var invalidLines = metrics.NewCounter(`vm_rows_invalid_total{type="prometheus"}`)
 
+var invalidExemplars = metrics.NewCounter(`vm_exemplars_invalid_total{type="prometheus"}`)
+
 func unmarshalTags(dst []Tag, s string, noEscapes bool) (string, []Tag, error) {
 	for {
 		s = skipLeadingWhitespace(s)
@@ -178,6 +178,56 @@ func TestAppendEscapedValue(t *testing.T) {
 	f("\"\n\t\\xyz", "\\\"\\n\t\\\\xyz")
 }
 
+func TestAppendMetadata(t *testing.T) {
+	f := func(s string, resultExpected []Metadata) {
+		t.Helper()
+		result := AppendMetadata(nil, s)
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result for AppendMetadata(%q);\ngot\n%+v\nwant\n%+v", s, result, resultExpected)
+		}
+	}
+
+	// No metadata
+	f("", nil)
+	f("foo 123\n# some comment\n#\n# HELP\n", nil)
+
+	// Single metric family
+	f("# HELP foo Foo help\n# TYPE foo counter\nfoo 123", []Metadata{{
+		Metric: "foo",
+		Type:   "counter",
+		Help:   "Foo help",
+	}})
+
+	// Metadata with escaped help, unit and Windows line endings
+	f("#  HELP\tfoo_seconds  Line1\\nLine2 \\\\ \\x\r\n# TYPE foo_seconds gauge \r\n# UNIT foo_seconds seconds\r\nfoo_seconds 1\r\n", []Metadata{{
+		Metric: "foo_seconds",
+		Type:   "gauge",
+		Help:   "Line1\nLine2 \\ \\x",
+		Unit:   "seconds",
+	}})
+
+	// Multiple metric families
+	f(`# TYPE foo counter
+foo{a="b"} 1
+# HELP bar
+# TYPE bar histogram
+bar_bucket{le="+Inf"} 3
+  # TYPE baz gauge`, []Metadata{
+		{
+			Metric: "foo",
+			Type:   "counter",
+		},
+		{
+			Metric: "bar",
+			Type:   "histogram",
+		},
+		{
+			Metric: "baz",
+			Type:   "gauge",
+		},
+	})
+}
+
 func TestRowsUnmarshalFailure(t *testing.T) {
 	f := func(s string) {
 		t.Helper()
@@ -381,6 +431,16 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 					},
 				},
 				Value: 17,
+				Exemplar: Exemplar{
+					Tags: []Tag{
+						{
+							Key:   "trace_id",
+							Value: "oHg5SJ#YRHA0",
+						},
+					},
+					Value:     9.8,
+					Timestamp: 1520879607789,
+				},
 			},
 			{
 				Metric:    "abc",
@@ -394,6 +454,55 @@ cassandra_token_ownership_ratio 78.9`, &Rows{
 		},
 	})
 
+	// Exemplar without timestamp
+	f(`foo_bucket{le="0.5"} 3 # {trace_id="abc",span_id="d"} 0.42`, &Rows{
+		Rows: []Row{{
+			Metric: "foo_bucket",
+			Tags: []Tag{{
+				Key:   "le",
+				Value: "0.5",
+			}},
+			Value: 3,
+			Exemplar: Exemplar{
+				Tags: []Tag{
+					{
+						Key:   "trace_id",
+						Value: "abc",
+					},
+					{
+						Key:   "span_id",
+						Value: "d",
+					},
+				},
+				Value: 0.42,
+			},
+		}},
+	})
+
+	// Exemplar for metric without tags
+	f(`foo_total 17 # {trace_id="abc"} 1`, &Rows{
+		Rows: []Row{{
+			Metric: "foo_total",
+			Value:  17,
+			Exemplar: Exemplar{
+				Tags: []Tag{{
+					Key:   "trace_id",
+					Value: "abc",
+				}},
+				Value: 1,
+			},
+		}},
+	})
+
+	// Invalid exemplar must be ignored
+	f(`foo 3 123 # {trace_id="abc"}`, &Rows{
+		Rows: []Row{{
+			Metric:    "foo",
+			Value:     3,
+			Timestamp: 123000,
+		}},
+	})
+
 	// "Infinity" word - this has been added in OpenMetrics.
 	// See https://github.com/OpenObservability/OpenMetrics/blob/master/OpenMetrics.md
 	// Checks for https://github.com/VictoriaMetrics/VictoriaMetrics/issues/924
@@ -0,0 +1,75 @@
+package stream
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/VictoriaMetrics/easyproto"
+)
+
+// RemoteWriteV2ContentType is the Content-Type for Prometheus Remote Write 2.0 requests.
+//
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/#protocol
+const RemoteWriteV2ContentType = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+
+// IsRemoteWriteV2 returns true if contentType corresponds to Prometheus Remote Write 2.0 request.
+//
+// An error is returned if contentType refers to unsupported protobuf message.
+// Such requests must be rejected with 415 Unsupported Media Type status code according to the spec.
+func IsRemoteWriteV2(contentType string) (bool, error) {
+	if contentType == "" {
+		return false, nil
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/x-protobuf" {
+		// Accept arbitrary Content-Type as Remote Write 1.0 for backwards compatibility with senders, which set invalid Content-Type.
+		return false, nil
+	}
+	switch proto := params["proto"]; proto {
+	case "", "prometheus.WriteRequest":
+		return false, nil
+	case "io.prometheus.write.v2.Request":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unsupported protobuf message %q in Content-Type %q; supported messages: prometheus.WriteRequest, io.prometheus.write.v2.Request", proto, contentType)
+	}
+}
+
+// isRemoteWriteV2Message returns true if data contains Remote Write 2.0 request.
+//
+// Remote Write 1.0 and 2.0 requests use distinct field numbers, so the version is detected by the first field in data.
+// false is returned if the version cannot be detected, e.g. for empty data.
+func isRemoteWriteV2Message(data []byte) bool {
+	var fc easyproto.FieldContext
+	if _, err := fc.NextField(data); err != nil {
+		return false
+	}
+	switch fc.FieldNum {
+	case 1, 3:
+		// WriteRequest.timeseries and WriteRequest.metadata
+		return false
+	case 4, 5:
+		// Request.symbols and Request.timeseries
+		return true
+	default:
+		return false
+	}
+}
+
+// WriteStats contains the number of written samples, histograms and exemplars for the parsed request.
+type WriteStats struct {
+	Samples    int
+	Histograms int
+	Exemplars  int
+}
+
+// SetResponseHeaders sets Remote Write 2.0 response headers with the number of written samples, histograms and exemplars to h.
+//
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/#required-written-response-headers
+func (ws *WriteStats) SetResponseHeaders(h http.Header) {
+	h.Set("X-Prometheus-Remote-Write-Samples-Written", strconv.Itoa(ws.Samples))
+	h.Set("X-Prometheus-Remote-Write-Histograms-Written", strconv.Itoa(ws.Histograms))
+	h.Set("X-Prometheus-Remote-Write-Exemplars-Written", strconv.Itoa(ws.Exemplars))
+}
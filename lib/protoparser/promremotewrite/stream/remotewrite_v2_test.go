@@ -0,0 +1,186 @@
+package stream
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/golang/snappy"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompb"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/prompbmarshal"
+)
+
+func TestIsRemoteWriteV2Success(t *testing.T) {
+	f := func(contentType string, resultExpected bool) {
+		t.Helper()
+
+		result, err := IsRemoteWriteV2(contentType)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result for Content-Type %q; got %v; want %v", contentType, result, resultExpected)
+		}
+	}
+
+	f("", false)
+	f("application/x-protobuf", false)
+	f("application/x-protobuf;proto=prometheus.WriteRequest", false)
+	f("application/octet-stream", false)
+	f("invalid;;", false)
+	f(RemoteWriteV2ContentType, true)
+	f("application/x-protobuf; proto=io.prometheus.write.v2.Request", true)
+}
+
+func TestIsRemoteWriteV2Failure(t *testing.T) {
+	f := func(contentType string) {
+		t.Helper()
+
+		if _, err := IsRemoteWriteV2(contentType); err == nil {
+			t.Fatalf("expecting non-nil error for Content-Type %q", contentType)
+		}
+	}
+
+	f("application/x-protobuf;proto=io.prometheus.write.v3.Request")
+	f("application/x-protobuf;proto=foo")
+}
+
+func TestParseRemoteWriteV2(t *testing.T) {
+	wrm := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{
+			{
+				Labels: []prompbmarshal.Label{
+					{
+						Name:  "__name__",
+						Value: "foo",
+					},
+					{
+						Name:  "job",
+						Value: "bar",
+					},
+				},
+				Samples: []prompbmarshal.Sample{
+					{
+						Value:     1,
+						Timestamp: 1000,
+					},
+					{
+						Value:     2,
+						Timestamp: 2000,
+					},
+				},
+				Exemplars: []prompbmarshal.Exemplar{{
+					Labels: []prompbmarshal.Label{{
+						Name:  "trace_id",
+						Value: "baz",
+					}},
+					Value:     1,
+					Timestamp: 1000,
+				}},
+			},
+		},
+	}
+	labelsExpected := []prompb.Label{
+		{
+			Name:  "__name__",
+			Value: "foo",
+		},
+		{
+			Name:  "job",
+			Value: "bar",
+		},
+	}
+	wsExpected := &WriteStats{
+		Samples:   2,
+		Exemplars: 1,
+	}
+
+	f := func(data []byte, contentType string) {
+		t.Helper()
+
+		var labels []prompb.Label
+		ws, err := Parse(bytes.NewReader(snappy.Encode(nil, data)), false, contentType, func(tss []prompb.TimeSeries, _ []prompb.MetricMetadata) error {
+			for _, ts := range tss {
+				for _, label := range ts.Labels {
+					labels = append(labels, prompb.Label{
+						Name:  label.Name,
+						Value: label.Value,
+					})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(labels, labelsExpected) {
+			t.Fatalf("unexpected labels; got %v; want %v", labels, labelsExpected)
+		}
+		if !reflect.DeepEqual(ws, wsExpected) {
+			t.Fatalf("unexpected write stats; got %+v; want %+v", ws, wsExpected)
+		}
+	}
+
+	dataV1 := wrm.MarshalProtobuf(nil)
+	dataV2 := wrm.MarshalProtobufV2(nil)
+
+	f(dataV1, "application/x-protobuf")
+	f(dataV2, RemoteWriteV2ContentType)
+
+	// The protocol version must be detected by the message contents if Content-Type is missing.
+	f(dataV1, "")
+	f(dataV2, "")
+}
+
+func TestParseRemoteWriteV2ContentTypeMismatch(t *testing.T) {
+	wrm := &prompbmarshal.WriteRequest{
+		Timeseries: []prompbmarshal.TimeSeries{{
+			Labels: []prompbmarshal.Label{{
+				Name:  "__name__",
+				Value: "foo",
+			}},
+			Samples: []prompbmarshal.Sample{{
+				Value:     1,
+				Timestamp: 1000,
+			}},
+		}},
+	}
+
+	f := func(data []byte, contentType string) {
+		t.Helper()
+
+		// The message must be parsed according to Content-Type, so it must be either rejected or parsed into empty result.
+		var rows int
+		_, err := Parse(bytes.NewReader(snappy.Encode(nil, data)), false, contentType, func(tss []prompb.TimeSeries, _ []prompb.MetricMetadata) error {
+			rows += len(tss)
+			return nil
+		})
+		if err == nil && rows > 0 {
+			t.Fatalf("unexpected %d rows parsed from the message with mismatched Content-Type %q", rows, contentType)
+		}
+	}
+
+	f(wrm.MarshalProtobuf(nil), RemoteWriteV2ContentType)
+	f(wrm.MarshalProtobufV2(nil), "application/x-protobuf")
+}
+
+func TestWriteStatsSetResponseHeaders(t *testing.T) {
+	ws := &WriteStats{
+		Samples:    10,
+		Histograms: 2,
+		Exemplars:  1,
+	}
+	h := make(http.Header)
+	ws.SetResponseHeaders(h)
+	for name, valueExpected := range map[string]string{
+		"X-Prometheus-Remote-Write-Samples-Written":    "10",
+		"X-Prometheus-Remote-Write-Histograms-Written": "2",
+		"X-Prometheus-Remote-Write-Exemplars-Written":  "1",
+	} {
+		if value := h.Get(name); value != valueExpected {
+			t.Fatalf("unexpected value for %s header; got %q; want %q", name, value, valueExpected)
+		}
+	}
+}
@@ -18,10 +18,19 @@ import (
 
 var maxInsertRequestSize = flagutil.NewBytes("maxInsertRequestSize", 32*1024*1024, "The maximum size in bytes of a single Prometheus remote_write API request")
 
-// Parse parses Prometheus remote_write message from reader and calls callback for the parsed timeseries.
+// Parse parses Prometheus remote_write message from reader and calls callback for the parsed timeseries and metric metadata.
 //
-// callback shouldn't hold tss after returning.
-func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSeries) error) error {
+// isVMRemoteWrite must be set if the message is compressed with zstd according to VictoriaMetrics remote write protocol.
+// contentType must contain Content-Type of the message. See IsRemoteWriteV2.
+// The protocol version is detected by the message contents if contentType is empty.
+//
+// callback shouldn't hold tss and mms after returning.
+func Parse(r io.Reader, isVMRemoteWrite bool, contentType string, callback func(tss []prompb.TimeSeries, mms []prompb.MetricMetadata) error) (*WriteStats, error) {
+	isRemoteWriteV2, err := IsRemoteWriteV2(contentType)
+	if err != nil {
+		return nil, err
+	}
+
 	wcr := writeconcurrencylimiter.GetReader(r)
 	defer writeconcurrencylimiter.PutReader(wcr)
 	r = wcr
@@ -29,7 +38,7 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 	ctx := getPushCtx(r)
 	defer putPushCtx(ctx)
 	if err := ctx.Read(); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Synchronously process the request in order to properly return errors to Parse caller,
@@ -37,7 +46,6 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 	// See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/896
 	bb := bodyBufferPool.Get()
 	defer bodyBufferPool.Put(bb)
-	var err error
 	if isVMRemoteWrite {
 		bb.B, err = zstd.Decompress(bb.B[:0], ctx.reqBuf.B)
 		if err != nil {
@@ -47,7 +55,7 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 			zstdErr := err
 			bb.B, err = snappy.Decode(bb.B[:cap(bb.B)], ctx.reqBuf.B)
 			if err != nil {
-				return fmt.Errorf("cannot decompress zstd-encoded request with length %d: %w", len(ctx.reqBuf.B), zstdErr)
+				return nil, fmt.Errorf("cannot decompress zstd-encoded request with length %d: %w", len(ctx.reqBuf.B), zstdErr)
 			}
 		}
 	} else {
@@ -59,20 +67,41 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 			snappyErr := err
 			bb.B, err = zstd.Decompress(bb.B[:0], ctx.reqBuf.B)
 			if err != nil {
-				return fmt.Errorf("cannot decompress snappy-encoded request with length %d: %w", len(ctx.reqBuf.B), snappyErr)
+				return nil, fmt.Errorf("cannot decompress snappy-encoded request with length %d: %w", len(ctx.reqBuf.B), snappyErr)
 			}
 		}
 	}
 	if int64(len(bb.B)) > maxInsertRequestSize.N {
-		return fmt.Errorf("too big unpacked request; mustn't exceed `-maxInsertRequestSize=%d` bytes; got %d bytes", maxInsertRequestSize.N, len(bb.B))
+		return nil, fmt.Errorf("too big unpacked request; mustn't exceed `-maxInsertRequestSize=%d` bytes; got %d bytes", maxInsertRequestSize.N, len(bb.B))
 	}
 	wr := getWriteRequest()
 	defer putWriteRequest(wr)
-	if err := wr.UnmarshalProtobuf(bb.B); err != nil {
+	if contentType == "" {
+		// Detect the protocol version by the message contents, since messages without Content-Type,
+		// such as Kafka messages written by third-party producers, may contain Remote Write 2.0 requests.
+		isRemoteWriteV2 = isRemoteWriteV2Message(bb.B)
+	}
+	if isRemoteWriteV2 {
+		if err := wr.UnmarshalProtobufV2(bb.B); err != nil {
+			unmarshalErrors.Inc()
+			return nil, fmt.Errorf("cannot unmarshal io.prometheus.write.v2.Request with size %d bytes: %w", len(bb.B), err)
+		}
+	} else if err := wr.UnmarshalProtobuf(bb.B); err != nil {
 		unmarshalErrors.Inc()
-		return fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(bb.B), err)
+		return nil, fmt.Errorf("cannot unmarshal prompb.WriteRequest with size %d bytes: %w", len(bb.B), err)
+	}
+
+	var ws WriteStats
+	for i := range wr.Timeseries {
+		ts := &wr.Timeseries[i]
+		ws.Samples += len(ts.Samples)
+		ws.Histograms += len(ts.Histograms)
+		ws.Exemplars += len(ts.Exemplars)
 	}
 
+	// Convert native histograms to `vmrange` buckets, since VictoriaMetrics doesn't support native histograms in the storage.
+	wr.ConvertHistogramsToBuckets()
+
 	rows := 0
 	tss := wr.Timeseries
 	for i := range tss {
@@ -80,10 +109,10 @@ func Parse(r io.Reader, isVMRemoteWrite bool, callback func(tss []prompb.TimeSer
 	}
 	rowsRead.Add(rows)
 
-	if err := callback(tss); err != nil {
-		return fmt.Errorf("error when processing imported data: %w", err)
+	if err := callback(tss, wr.Metadata); err != nil {
+		return nil, fmt.Errorf("error when processing imported data: %w", err)
 	}
-	return nil
+	return &ws, nil
 }
 
 var bodyBufferPool bytesutil.ByteBufferPool
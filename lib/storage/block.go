@@ -150,8 +150,8 @@ func (b *Block) tooBig() bool {
 }
 
 func (b *Block) deduplicateSamplesDuringMerge() {
-	if !isDedupEnabled() {
-		// Deduplication is disabled
+	if !isDedupEnabled() && !isDownsamplingEnabled() {
+		// Deduplication and downsampling are disabled
 		return
 	}
 	// Unmarshal block if it isn't unmarshaled yet in order to apply the de-duplication to unmarshaled samples.
@@ -163,20 +163,25 @@ func (b *Block) deduplicateSamplesDuringMerge() {
 		// Nothing to dedup.
 		return
 	}
-	dedupInterval := GetDedupInterval()
-	if dedupInterval <= 0 {
-		// Deduplication is disabled.
-		return
-	}
 	srcValues := b.values[b.nextIdx:]
-	timestamps, values := deduplicateSamplesDuringMerge(srcTimestamps, srcValues, dedupInterval)
-	dedups := len(srcTimestamps) - len(timestamps)
-	dedupsDuringMerge.Add(uint64(dedups))
+	timestamps, values := srcTimestamps, srcValues
+	if dedupInterval := GetDedupInterval(); dedupInterval > 0 {
+		timestamps, values = deduplicateSamplesDuringMerge(timestamps, values, dedupInterval)
+		dedupsDuringMerge.Add(uint64(len(srcTimestamps) - len(timestamps)))
+	}
+	if isDownsamplingEnabled() {
+		n := len(timestamps)
+		timestamps, values = downsampleSamplesDuringMerge(timestamps, values, getCurrentTimestampForDownsampling())
+		downsampledSamplesDuringMerge.Add(uint64(n - len(timestamps)))
+	}
 	b.timestamps = b.timestamps[:b.nextIdx+len(timestamps)]
 	b.values = b.values[:b.nextIdx+len(values)]
 }
 
-var dedupsDuringMerge atomic.Uint64
+var (
+	dedupsDuringMerge             atomic.Uint64
+	downsampledSamplesDuringMerge atomic.Uint64
+)
 
 func (b *Block) rowsCount() int {
 	if len(b.values) == 0 {
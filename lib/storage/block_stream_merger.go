@@ -4,6 +4,7 @@ import (
 	"container/heap"
 	"fmt"
 	"io"
+	"time"
 )
 
 // blockStreamMerger is used for merging block streams.
@@ -13,8 +14,9 @@ type blockStreamMerger struct {
 
 	bsrHeap blockStreamReaderHeap
 
+	// rfm returns retention deadlines for blocks.
 	// Blocks with smaller timestamps are removed because of retention.
-	retentionDeadline int64
+	rfm retentionFiltersMatcher
 
 	// Whether the call to NextBlock must be no-op.
 	nextBlockNoop bool
@@ -31,15 +33,17 @@ func (bsm *blockStreamMerger) reset() {
 	}
 	bsm.bsrHeap = bsm.bsrHeap[:0]
 
-	bsm.retentionDeadline = 0
+	bsm.rfm.reset()
 	bsm.nextBlockNoop = false
 	bsm.err = nil
 }
 
 // Init initializes bsm with the given bsrs.
-func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, retentionDeadline int64) {
+//
+// s is used for applying retention filters to the merged blocks.
+func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, s *Storage, retentionDeadline int64) {
 	bsm.reset()
-	bsm.retentionDeadline = retentionDeadline
+	bsm.rfm.init(s, retentionDeadline, timestampFromTime(time.Now()))
 	for _, bsr := range bsrs {
 		if bsr.NextBlock() {
 			bsm.bsrHeap = append(bsm.bsrHeap, bsr)
@@ -61,8 +65,13 @@ func (bsm *blockStreamMerger) Init(bsrs []*blockStreamReader, retentionDeadline
 	bsm.nextBlockNoop = true
 }
 
-func (bsm *blockStreamMerger) getRetentionDeadline(_ *blockHeader) int64 {
-	return bsm.retentionDeadline
+func (bsm *blockStreamMerger) getRetentionDeadline(bh *blockHeader) int64 {
+	return bsm.rfm.getRetentionDeadline(bh)
+}
+
+// getTombstonesGeneration returns partHeader.TombstonesGeneration for the part bsm.Block belongs to.
+func (bsm *blockStreamMerger) getTombstonesGeneration() uint64 {
+	return bsm.bsrHeap[0].ph.TombstonesGeneration
 }
 
 // NextBlock stores the next block in bsm.Block.
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/mergeset"
+)
+
+// CheckDataDir verifies the integrity of all the data and indexdb parts in the storage data directory at the given path.
+//
+// reportCorruptedPart is called for every corrupted part.
+// If quarantinePath isn't empty, then corrupted parts are moved into it and are removed from the lists of parts,
+// so the storage can be opened with the remaining data. The relative paths of the moved parts are preserved.
+//
+// The storage at the given path mustn't be opened during the call. The call panics if the storage is opened by another process.
+//
+// The number of checked parts is returned.
+func CheckDataDir(path, quarantinePath string, reportCorruptedPart func(partPath string, err error)) (int, error) {
+	path = filepath.Clean(path)
+	if !fs.IsPathExist(path) {
+		return 0, nil
+	}
+
+	// Protect from concurrent access to the storage data directory.
+	flockF := fs.MustCreateFlockFile(path)
+	defer fs.MustClose(flockF)
+
+	partsChecked := 0
+
+	// Check data parts.
+	smallPartitionsPath := filepath.Join(path, dataDirname, smallDirname)
+	bigPartitionsPath := filepath.Join(path, dataDirname, bigDirname)
+	ptNames := make(map[string]bool)
+	if fs.IsPathExist(smallPartitionsPath) {
+		mustPopulatePartitionNames(smallPartitionsPath, ptNames)
+	}
+	if fs.IsPathExist(bigPartitionsPath) {
+		mustPopulatePartitionNames(bigPartitionsPath, ptNames)
+	}
+	for _, ptName := range sortedKeys(ptNames) {
+		smallPartsPath := filepath.Join(smallPartitionsPath, ptName)
+		bigPartsPath := filepath.Join(bigPartitionsPath, ptName)
+		var quarantineSmallPath, quarantineBigPath string
+		if quarantinePath != "" {
+			quarantineSmallPath = filepath.Join(quarantinePath, dataDirname, smallDirname, ptName)
+			quarantineBigPath = filepath.Join(quarantinePath, dataDirname, bigDirname, ptName)
+		}
+		n, err := checkPartitionParts(smallPartsPath, bigPartsPath, quarantineSmallPath, quarantineBigPath, reportCorruptedPart)
+		partsChecked += n
+		if err != nil {
+			return partsChecked, fmt.Errorf("cannot check partition %q: %w", ptName, err)
+		}
+	}
+
+	// Check indexdb parts.
+	idbPath := filepath.Join(path, indexdbDirname)
+	if fs.IsPathExist(idbPath) {
+		for _, de := range fs.MustReadDir(idbPath) {
+			if !fs.IsDirOrSymlink(de) {
+				continue
+			}
+			tableName := de.Name()
+			if !indexDBTableNameRegexp.MatchString(tableName) {
+				continue
+			}
+			var quarantineTablePath string
+			if quarantinePath != "" {
+				quarantineTablePath = filepath.Join(quarantinePath, indexdbDirname, tableName)
+			}
+			n, err := mergeset.CheckTableParts(filepath.Join(idbPath, tableName), quarantineTablePath, reportCorruptedPart)
+			partsChecked += n
+			if err != nil {
+				return partsChecked, fmt.Errorf("cannot check indexdb table %q: %w", tableName, err)
+			}
+		}
+	}
+
+	return partsChecked, nil
+}
+
+func checkPartitionParts(smallPartsPath, bigPartsPath, quarantineSmallPath, quarantineBigPath string, reportCorruptedPart func(partPath string, err error)) (int, error) {
+	partsFile := filepath.Join(smallPartsPath, partsFilename)
+	var partNamesSmall, partNamesBig []string
+	if fs.IsPathExist(partsFile) {
+		data, err := os.ReadFile(partsFile)
+		if err != nil {
+			return 0, fmt.Errorf("cannot read %q: %w", partsFile, err)
+		}
+		var partNames partNamesJSON
+		if err := json.Unmarshal(data, &partNames); err != nil {
+			return 0, fmt.Errorf("cannot parse %q: %w", partsFile, err)
+		}
+		partNamesSmall, partNamesBig = partNames.Small, partNames.Big
+	} else {
+		// The partsFile is missing. This is the partition created by versions previous to v1.90.0.
+		partNamesSmall = mustReadPartNamesFromDir(smallPartsPath)
+		partNamesBig = mustReadPartNamesFromDir(bigPartsPath)
+	}
+
+	checkParts := func(partsPath string, partNames []string) (good, corrupted []string) {
+		for _, partName := range partNames {
+			partPath := filepath.Join(partsPath, partName)
+			if err := checkPart(partPath); err != nil {
+				reportCorruptedPart(partPath, err)
+				corrupted = append(corrupted, partName)
+				continue
+			}
+			good = append(good, partName)
+		}
+		return good, corrupted
+	}
+	goodSmall, corruptedSmall := checkParts(smallPartsPath, partNamesSmall)
+	goodBig, corruptedBig := checkParts(bigPartsPath, partNamesBig)
+	partsChecked := len(partNamesSmall) + len(partNamesBig)
+	if len(corruptedSmall)+len(corruptedBig) == 0 || quarantineSmallPath == "" {
+		return partsChecked, nil
+	}
+
+	if err := quarantineParts(smallPartsPath, quarantineSmallPath, corruptedSmall); err != nil {
+		return partsChecked, err
+	}
+	if err := quarantineParts(bigPartsPath, quarantineBigPath, corruptedBig); err != nil {
+		return partsChecked, err
+	}
+	data, err := json.Marshal(&partNamesJSON{
+		Small: goodSmall,
+		Big:   goodBig,
+	})
+	if err != nil {
+		return partsChecked, fmt.Errorf("cannot marshal part names: %w", err)
+	}
+	fs.MustMkdirIfNotExist(smallPartsPath)
+	fs.MustWriteAtomic(partsFile, data, true)
+	return partsChecked, nil
+}
+
+func quarantineParts(partsPath, quarantinePath string, partNames []string) error {
+	if len(partNames) == 0 {
+		return nil
+	}
+	fs.MustMkdirIfNotExist(quarantinePath)
+	for _, partName := range partNames {
+		partPath := filepath.Join(partsPath, partName)
+		if !fs.IsPathExist(partPath) {
+			continue
+		}
+		if err := os.Rename(partPath, filepath.Join(quarantinePath, partName)); err != nil {
+			return fmt.Errorf("cannot move corrupted part %q to quarantine: %w", partPath, err)
+		}
+	}
+	fs.MustSyncPath(quarantinePath)
+	fs.MustSyncPath(partsPath)
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckDataDir(t *testing.T) {
+	defer testRemoveAll(t)
+
+	path := filepath.Join(t.Name(), "storage")
+	quarantinePath := filepath.Join(t.Name(), "quarantine")
+
+	startTimestamp := time.Now().Add(-time.Hour).Truncate(time.Minute).UnixMilli()
+	var mn MetricName
+	mn.MetricGroup = []byte("metric")
+	metricNameRaw := mn.marshalRaw(nil)
+	var mrs []MetricRow
+	for i := 0; i < 100; i++ {
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     startTimestamp + int64(i)*1000,
+			Value:         float64(i),
+		})
+	}
+	s := MustOpenStorage(path, 0, 0, 0)
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.MustClose()
+
+	checkDataDir := func(quarantinePath string) []string {
+		t.Helper()
+		var corruptedParts []string
+		n, err := CheckDataDir(path, quarantinePath, func(partPath string, _ error) {
+			corruptedParts = append(corruptedParts, partPath)
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n == 0 {
+			t.Fatalf("expecting non-zero number of checked parts")
+		}
+		return corruptedParts
+	}
+	if corruptedParts := checkDataDir(""); len(corruptedParts) != 0 {
+		t.Fatalf("unexpected corrupted parts: %q", corruptedParts)
+	}
+
+	// Corrupt the data part by truncating its index file.
+	smallPartitionsPath := filepath.Join(path, dataDirname, smallDirname)
+	ptNames := make(map[string]bool)
+	mustPopulatePartitionNames(smallPartitionsPath, ptNames)
+	ptName := sortedKeys(ptNames)[0]
+	partNames := mustReadPartNamesFromDir(filepath.Join(smallPartitionsPath, ptName))
+	if len(partNames) == 0 {
+		t.Fatalf("expecting non-zero number of small parts")
+	}
+	corruptedPartPath := filepath.Join(smallPartitionsPath, ptName, partNames[0])
+	var ph partHeader
+	if err := ph.readMetadata(corruptedPartPath); err != nil {
+		t.Fatalf("cannot read part metadata: %s", err)
+	}
+	rowsExpected := uint64(len(mrs)) - ph.RowsCount
+	if err := os.Truncate(filepath.Join(corruptedPartPath, indexFilename), 1); err != nil {
+		t.Fatalf("cannot truncate index file: %s", err)
+	}
+
+	if corruptedParts := checkDataDir(""); len(corruptedParts) != 1 || corruptedParts[0] != corruptedPartPath {
+		t.Fatalf("unexpected corrupted parts; got %q; want %q", corruptedParts, []string{corruptedPartPath})
+	}
+	if corruptedParts := checkDataDir(quarantinePath); len(corruptedParts) != 1 {
+		t.Fatalf("unexpected corrupted parts; got %q; want 1 part", corruptedParts)
+	}
+	if _, err := os.Stat(filepath.Join(quarantinePath, dataDirname, smallDirname, ptName, partNames[0])); err != nil {
+		t.Fatalf("cannot find the corrupted part in quarantine: %s", err)
+	}
+	if corruptedParts := checkDataDir(""); len(corruptedParts) != 0 {
+		t.Fatalf("unexpected corrupted parts after quarantine: %q", corruptedParts)
+	}
+
+	// The storage must be opened without the corrupted part.
+	s = MustOpenStorage(path, 0, 0, 0)
+	var m Metrics
+	s.UpdateMetrics(&m)
+	s.MustClose()
+	if n := m.TableMetrics.SmallRowsCount + m.TableMetrics.BigRowsCount; n != rowsExpected {
+		t.Fatalf("unexpected number of rows after quarantine; got %d; want %d", n, rowsExpected)
+	}
+}
@@ -0,0 +1,260 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// CopySeriesStats contains stats for CopySeries call.
+type CopySeriesStats struct {
+	// SeriesCopied is the number of copied time series.
+	SeriesCopied uint64
+
+	// BlocksCopied is the number of data blocks copied from the source storage.
+	BlocksCopied uint64
+
+	// SamplesCopied is the number of samples written to the destination storage.
+	SamplesCopied uint64
+}
+
+// CopySeries copies samples for time series matching tfss on the time range tr from src to dst.
+//
+// Data blocks for the matching series are merged from src parts into new parts at the corresponding dst partitions,
+// while indexdb entries for the copied series are created at dst with the same TSIDs as at src.
+// This means that dst must be empty or it must contain only data copied from src.
+// Samples deleted via tombstones and samples outside src retention aren't copied.
+//
+// src must be opened via MustOpenStorageReadOnly, so its parts aren't modified by background merges during the copy.
+// The copied series become visible for search in dst after dst.DebugFlush() call or after dst is closed.
+//
+// An error is returned without copying the data if the number of series matching tfss on tr exceeds maxSeries.
+//
+// progressCb is called after every copied partition if it isn't nil.
+func CopySeries(dst, src *Storage, tfss []*TagFilters, tr TimeRange, maxSeries int, progressCb func(stats *CopySeriesStats)) (*CopySeriesStats, error) {
+	metricIDs, err := src.idb().searchMetricIDs(nil, tfss, tr, maxSeries, noDeadline)
+	var e *tooManyTimeseriesError
+	if errors.As(err, &e) || len(metricIDs) > maxSeries {
+		// The len(metricIDs) check is needed, since searchMetricIDs may return cached results without checking maxSeries.
+		return nil, fmt.Errorf("the number of series matching %s on the time range %s exceeds the limit of %d series to copy; "+
+			"narrow down the series filter or the time range, or increase the limit", tfss, &tr, maxSeries)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot search for series to copy: %w", err)
+	}
+	tsids, err := src.idb().getTSIDsFromMetricIDs(nil, metricIDs, noDeadline)
+	if err != nil {
+		return nil, fmt.Errorf("cannot obtain TSIDs for series to copy: %w", err)
+	}
+	bf := &blockFilter{
+		tr:          tr,
+		seriesDates: make(map[uint64][]uint64),
+	}
+	bf.metricIDs.AddMulti(metricIDs)
+
+	var stats CopySeriesStats
+	ptws := src.tb.GetPartitions(nil)
+	defer src.tb.PutPartitions(ptws)
+	for _, ptw := range ptws {
+		pt := ptw.pt
+		if pt.tr.MinTimestamp > tr.MaxTimestamp || pt.tr.MaxTimestamp < tr.MinTimestamp {
+			continue
+		}
+		if !hasBlocksForTSIDs(pt, tsids, tr) {
+			// Do not create the partition at dst if there is nothing to copy from pt.
+			continue
+		}
+		rowsCopied, err := copyPartitionData(dst, pt, bf)
+		if err != nil {
+			return nil, err
+		}
+		stats.SeriesCopied = uint64(len(bf.seriesDates))
+		stats.BlocksCopied = bf.blocksCopied
+		stats.SamplesCopied += rowsCopied
+		if progressCb != nil {
+			progressCb(&stats)
+		}
+	}
+
+	if err := copyIndexes(dst, src, bf); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// copyPartitionData copies data blocks matching bf from srcPt parts into a new part at the corresponding dst partition.
+//
+// It returns the number of copied samples.
+func copyPartitionData(dst *Storage, srcPt *partition, bf *blockFilter) (uint64, error) {
+	pws := srcPt.GetParts(nil, true)
+	defer srcPt.PutParts(pws)
+	if len(pws) == 0 {
+		return 0, nil
+	}
+
+	ptw := dst.tb.mustGetOrCreatePartition(srcPt.tr.MinTimestamp)
+	defer dst.tb.PutPartitions([]*partitionWrapper{ptw})
+	dstPt := ptw.pt
+
+	srcRowsCount := uint64(0)
+	srcBlocksCount := uint64(0)
+	for _, pw := range pws {
+		srcRowsCount += pw.p.ph.RowsCount
+		srcBlocksCount += pw.p.ph.BlocksCount
+	}
+	rowsPerBlock := float64(srcRowsCount) / float64(srcBlocksCount)
+	dstPartType := dstPt.getDstPartType(pws, true)
+	dstPartPath := dstPt.getDstPartPath(dstPartType, dstPt.nextMergeIdx())
+	bsrs := mustOpenBlockStreamReaders(pws)
+	bsw := getBlockStreamWriter()
+	nocache := dstPartType == partBig
+	bsw.MustInitFromFilePart(dstPartPath, nocache, getCompressLevel(rowsPerBlock))
+
+	var ph partHeader
+	var rowsMerged, rowsDeleted atomic.Uint64
+	retentionDeadline := timestampFromTime(time.Now()) - srcPt.s.retentionMsecs
+	err := mergeBlockStreamsWithFilter(&ph, bsw, bsrs, nil, srcPt.s, retentionDeadline, bf, &rowsMerged, &rowsDeleted)
+	putBlockStreamWriter(bsw)
+	for _, bsr := range bsrs {
+		putBlockStreamReader(bsr)
+	}
+	if err != nil {
+		fs.MustRemoveAll(dstPartPath)
+		return 0, fmt.Errorf("cannot copy data from partition %q to %q: %w", srcPt.name, dstPartPath, err)
+	}
+
+	// Src tombstones and retention filters are already applied to the copied data,
+	// while dst tombstones and retention filters must be applied to it by background merges at dst.
+	ph.TombstonesGeneration = 0
+	ph.RetentionFiltersTimestamp = 0
+	ph.MinDedupInterval = dstPt.getDedupInterval()
+	ph.MustWriteMetadata(dstPartPath)
+	fs.MustSyncPath(dstPartPath)
+
+	pwNew := dstPt.openCreatedPart(&ph, nil, nil, dstPartPath)
+	dstPt.swapSrcWithDstParts(nil, pwNew, dstPartType)
+	return ph.RowsCount, nil
+}
+
+// hasBlocksForTSIDs returns true if pt contains blocks for the given tsids on the given tr.
+//
+// tsids must be sorted.
+func hasBlocksForTSIDs(pt *partition, tsids []TSID, tr TimeRange) bool {
+	var pts partitionSearch
+	pts.Init(pt, tsids, tr)
+	defer pts.MustClose()
+	return pts.NextBlock()
+}
+
+// copyIndexes creates global and per-day indexdb entries at dst for the series copied from src according to bf.
+func copyIndexes(dst, src *Storage, bf *blockFilter) error {
+	if len(bf.seriesDates) == 0 {
+		return nil
+	}
+	metricIDs := make([]uint64, 0, len(bf.seriesDates))
+	for metricID := range bf.seriesDates {
+		metricIDs = append(metricIDs, metricID)
+	}
+	slices.Sort(metricIDs)
+	tsids, err := src.idb().getTSIDsFromMetricIDs(nil, metricIDs, noDeadline)
+	if err != nil {
+		return fmt.Errorf("cannot obtain TSIDs for the copied series: %w", err)
+	}
+
+	is := dst.idb().getIndexSearch(noDeadline)
+	defer dst.idb().putIndexSearch(is)
+	mn := GetMetricName()
+	defer PutMetricName(mn)
+	var metricName []byte
+	for i := range tsids {
+		tsid := &tsids[i]
+		var ok bool
+		metricName, ok = src.idb().searchMetricNameWithCache(metricName[:0], tsid.MetricID)
+		if !ok {
+			// The series is missing in src indexdb, so its data cannot be found at src too. Skip it.
+			continue
+		}
+		if err := mn.Unmarshal(metricName); err != nil {
+			return fmt.Errorf("cannot unmarshal metric name for metricID=%d: %w", tsid.MetricID, err)
+		}
+		is.createGlobalIndexes(tsid, mn)
+		dates := bf.seriesDates[tsid.MetricID]
+		slices.Sort(dates)
+		for _, date := range slices.Compact(dates) {
+			is.createPerDayIndexes(date, tsid, mn)
+		}
+	}
+
+	// Reset the cache for tag filters at dst, since it may contain empty results for the copied series.
+	invalidateTagFiltersCache()
+	return nil
+}
+
+// blockFilter selects blocks and samples to copy during CopySeries.
+//
+// All the methods of blockFilter may be called on nil blockFilter, which selects all the blocks and samples.
+type blockFilter struct {
+	// metricIDs contains metricIDs for series to copy.
+	metricIDs uint64set.Set
+
+	// tr is the time range for samples to copy.
+	tr TimeRange
+
+	// seriesDates contains dates with the copied samples per every copied metricID.
+	//
+	// Dates may be unsorted and may contain duplicates.
+	seriesDates map[uint64][]uint64
+
+	// blocksCopied is the number of copied blocks.
+	blocksCopied uint64
+}
+
+func (bf *blockFilter) hasMetricID(metricID uint64) bool {
+	if bf == nil {
+		return true
+	}
+	return bf.metricIDs.Has(metricID)
+}
+
+// appendSkippedTimeRanges appends time ranges outside bf.tr, which intersect with [minTimestamp ... maxTimestamp], to dst and returns the result.
+func (bf *blockFilter) appendSkippedTimeRanges(dst []TimeRange, minTimestamp, maxTimestamp int64) []TimeRange {
+	if bf == nil {
+		return dst
+	}
+	if minTimestamp < bf.tr.MinTimestamp {
+		dst = append(dst, TimeRange{
+			MinTimestamp: minTimestamp,
+			MaxTimestamp: bf.tr.MinTimestamp - 1,
+		})
+	}
+	if maxTimestamp > bf.tr.MaxTimestamp {
+		dst = append(dst, TimeRange{
+			MinTimestamp: bf.tr.MaxTimestamp + 1,
+			MaxTimestamp: maxTimestamp,
+		})
+	}
+	return dst
+}
+
+// registerBlock registers the block with the given bh as copied.
+func (bf *blockFilter) registerBlock(bh *blockHeader) {
+	if bf == nil {
+		return
+	}
+	bf.blocksCopied++
+	metricID := bh.TSID.MetricID
+	dates := bf.seriesDates[metricID]
+	minDate := uint64(bh.MinTimestamp) / msecPerDay
+	maxDate := uint64(bh.MaxTimestamp) / msecPerDay
+	for date := minDate; date <= maxDate; date++ {
+		if len(dates) == 0 || dates[len(dates)-1] != date {
+			dates = append(dates, date)
+		}
+	}
+	bf.seriesDates[metricID] = dates
+}
@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopySeries(t *testing.T) {
+	defer testRemoveAll(t)
+
+	startTimestamp := time.Now().Add(-time.Hour).Truncate(time.Minute).UnixMilli()
+	newRows := func(team string) []MetricRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("metric")
+		mn.AddTag("team", team)
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < 100; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     startTimestamp + int64(i)*1000,
+				Value:         float64(i) + 0.5,
+			})
+		}
+		return mrs
+	}
+	newTagFilters := func(team string) []*TagFilters {
+		tfs := NewTagFilters()
+		if err := tfs.Add([]byte("team"), []byte(team), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		return []*TagFilters{tfs}
+	}
+	tr := TimeRange{
+		MinTimestamp: startTimestamp,
+		MaxTimestamp: startTimestamp + 100*1000,
+	}
+	copyTr := TimeRange{
+		MinTimestamp: startTimestamp + 20*1000,
+		MaxTimestamp: startTimestamp + 69*1000,
+	}
+	searchSamples := func(s *Storage, team string, tr TimeRange) ([]int64, []float64) {
+		t.Helper()
+		var sr Search
+		var b Block
+		var timestamps []int64
+		var values []float64
+		sr.Init(nil, s, newTagFilters(team), tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			timestamps, values = b.AppendRowsWithTimeRangeFilter(timestamps, values, tr)
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error in search: %s", err)
+		}
+		sr.MustClose()
+		return timestamps, values
+	}
+
+	src := MustOpenStorage(filepath.Join(t.Name(), "src"), 0, 0, 0)
+	src.AddRows(newRows("foo"), defaultPrecisionBits)
+	src.AddRows(newRows("bar"), defaultPrecisionBits)
+	src.MustClose()
+	src = MustOpenStorageReadOnly(filepath.Join(t.Name(), "src"))
+
+	dst := MustOpenStorage(filepath.Join(t.Name(), "dst"), 0, 0, 0)
+	stats, err := CopySeries(dst, src, newTagFilters("foo"), copyTr, 1e5, nil)
+	if err != nil {
+		t.Fatalf("cannot copy series: %s", err)
+	}
+	if stats.SeriesCopied != 1 {
+		t.Fatalf("unexpected number of copied series; got %d; want %d", stats.SeriesCopied, 1)
+	}
+	if stats.SamplesCopied != 50 {
+		t.Fatalf("unexpected number of copied samples; got %d; want %d", stats.SamplesCopied, 50)
+	}
+
+	// The copied data must survive dst restart.
+	dst.MustClose()
+	dst = MustOpenStorage(filepath.Join(t.Name(), "dst"), 0, 0, 0)
+	defer dst.MustClose()
+	defer src.MustClose()
+
+	// Only samples on copyTr must be copied.
+	timestampsExpected, valuesExpected := searchSamples(src, "foo", copyTr)
+	timestamps, values := searchSamples(dst, "foo", tr)
+	if len(timestamps) != len(timestampsExpected) {
+		t.Fatalf("unexpected number of samples in dst; got %d; want %d", len(timestamps), len(timestampsExpected))
+	}
+	for i := range timestamps {
+		if timestamps[i] != timestampsExpected[i] || values[i] != valuesExpected[i] {
+			t.Fatalf("unexpected sample #%d in dst; got (%d, %v); want (%d, %v)", i, timestamps[i], values[i], timestampsExpected[i], valuesExpected[i])
+		}
+	}
+
+	// Series not matching the filter mustn't be copied.
+	if timestamps, _ := searchSamples(dst, "bar", tr); len(timestamps) != 0 {
+		t.Fatalf("unexpected samples for team=bar in dst: %d", len(timestamps))
+	}
+
+	// Partitions mustn't be created at dst if there are no series to copy.
+	dstEmpty := MustOpenStorage(filepath.Join(t.Name(), "dst-empty"), 0, 0, 0)
+	defer dstEmpty.MustClose()
+	stats, err = CopySeries(dstEmpty, src, newTagFilters("baz"), tr, 1e5, nil)
+	if err != nil {
+		t.Fatalf("cannot copy missing series: %s", err)
+	}
+	if stats.SeriesCopied != 0 {
+		t.Fatalf("unexpected number of copied series; got %d; want %d", stats.SeriesCopied, 0)
+	}
+	ptws := dstEmpty.tb.GetPartitions(nil)
+	dstEmpty.tb.PutPartitions(ptws)
+	if len(ptws) != 0 {
+		t.Fatalf("unexpected number of partitions at dst; got %d; want %d", len(ptws), 0)
+	}
+
+	// The copy must fail if the number of matching series exceeds maxSeries.
+	if _, err := CopySeries(dstEmpty, src, newTagFilters("foo"), tr, 0, nil); err == nil {
+		t.Fatalf("expecting non-nil error when the number of series exceeds maxSeries")
+	}
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// DownsamplingPeriod defines downsampling for samples older than Offset.
+//
+// Only a single sample per each Interval is left for samples with timestamps older than now-Offset.
+type DownsamplingPeriod struct {
+	// Offset is the age of samples in milliseconds, after which the downsampling is applied.
+	Offset int64
+
+	// Interval is the downsampling interval in milliseconds.
+	Interval int64
+}
+
+// String returns string representation of dp in the form `offset:interval`.
+func (dp *DownsamplingPeriod) String() string {
+	return fmt.Sprintf("%dms:%dms", dp.Offset, dp.Interval)
+}
+
+// ParseDownsamplingPeriods parses downsampling periods from ss.
+//
+// Every item in ss must have the form `offset:interval`, e.g. `30d:5m`.
+func ParseDownsamplingPeriods(ss []string) ([]DownsamplingPeriod, error) {
+	dps := make([]DownsamplingPeriod, 0, len(ss))
+	for _, s := range ss {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		offsetStr, intervalStr, ok := strings.Cut(s, ":")
+		if !ok {
+			return nil, fmt.Errorf("missing `:` in downsampling period %q; it must have the form `offset:interval`, e.g. `30d:5m`", s)
+		}
+		offset, err := promutils.ParseDuration(offsetStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse offset in downsampling period %q: %w", s, err)
+		}
+		interval, err := promutils.ParseDuration(intervalStr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse interval in downsampling period %q: %w", s, err)
+		}
+		if offset < 0 {
+			return nil, fmt.Errorf("offset in downsampling period %q cannot be negative", s)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval in downsampling period %q must be positive", s)
+		}
+		dps = append(dps, DownsamplingPeriod{
+			Offset:   offset.Milliseconds(),
+			Interval: interval.Milliseconds(),
+		})
+	}
+	if err := validateDownsamplingPeriods(dps); err != nil {
+		return nil, err
+	}
+	return dps, nil
+}
+
+func validateDownsamplingPeriods(dps []DownsamplingPeriod) error {
+	a := append([]DownsamplingPeriod{}, dps...)
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Offset < a[j].Offset
+	})
+	for i := 1; i < len(a); i++ {
+		prev := &a[i-1]
+		dp := &a[i]
+		if dp.Offset == prev.Offset {
+			return fmt.Errorf("duplicate downsampling offset %dms", dp.Offset)
+		}
+		if dp.Interval < prev.Interval || dp.Interval%prev.Interval != 0 {
+			return fmt.Errorf("downsampling interval %dms for offset %dms must be a multiple of the interval %dms for the smaller offset %dms",
+				dp.Interval, dp.Offset, prev.Interval, prev.Offset)
+		}
+	}
+	return nil
+}
+
+// SetDownsamplingPeriods sets downsampling periods, which are applied to samples during background merges and querying.
+//
+// Downsampling is disabled if dps is empty.
+//
+// This function must be called after SetDedupInterval and before initializing the storage.
+func SetDownsamplingPeriods(dps []DownsamplingPeriod) error {
+	if err := validateDownsamplingPeriods(dps); err != nil {
+		return err
+	}
+	if dedupInterval := GetDedupInterval(); dedupInterval > 0 {
+		for _, dp := range dps {
+			if dp.Interval%dedupInterval != 0 {
+				return fmt.Errorf("downsampling interval %dms must be a multiple of the deduplication interval %dms", dp.Interval, dedupInterval)
+			}
+		}
+	}
+	a := append([]DownsamplingPeriod{}, dps...)
+	// Sort periods by offset in descending order, so the oldest samples are matched first.
+	sort.Slice(a, func(i, j int) bool {
+		return a[i].Offset > a[j].Offset
+	})
+	globalDownsamplingPeriods.Store(&a)
+	return nil
+}
+
+// GetDownsamplingPeriods returns downsampling periods set via SetDownsamplingPeriods.
+//
+// The returned periods are sorted by Offset in descending order.
+func GetDownsamplingPeriods() []DownsamplingPeriod {
+	p := globalDownsamplingPeriods.Load()
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+var globalDownsamplingPeriods atomic.Pointer[[]DownsamplingPeriod]
+
+func isDownsamplingEnabled() bool {
+	return len(GetDownsamplingPeriods()) > 0
+}
+
+// GetDownsamplingInterval returns the interval in milliseconds, which must be applied to samples with the given timestamp at currentTimestamp.
+//
+// The returned interval doesn't take into account the deduplication interval set via SetDedupInterval.
+// Zero is returned if downsampling isn't applied to samples with the given timestamp.
+func GetDownsamplingInterval(timestamp, currentTimestamp int64) int64 {
+	for _, dp := range GetDownsamplingPeriods() {
+		if timestamp < getDownsamplingDeadline(&dp, currentTimestamp) {
+			return dp.Interval
+		}
+	}
+	return 0
+}
+
+// getDownsamplingDeadline returns the timestamp for dp, so samples with smaller timestamps must be downsampled.
+//
+// The deadline is aligned to dp.Interval, so downsampled time buckets do not cross it.
+func getDownsamplingDeadline(dp *DownsamplingPeriod, currentTimestamp int64) int64 {
+	deadline := currentTimestamp - dp.Offset
+	return deadline - deadline%dp.Interval
+}
+
+// DownsampleSamples applies downsampling periods set via SetDownsamplingPeriods to srcTimestamps and srcValues.
+//
+// It is expected that srcTimestamps are sorted in ascending order.
+// Samples, which aren't covered by downsampling periods, are left as is.
+func DownsampleSamples(srcTimestamps []int64, srcValues []float64, currentTimestamp int64) ([]int64, []float64) {
+	return downsampleSamplesGeneric(srcTimestamps, srcValues, currentTimestamp, DeduplicateSamples)
+}
+
+func downsampleSamplesDuringMerge(srcTimestamps, srcValues []int64, currentTimestamp int64) ([]int64, []int64) {
+	return downsampleSamplesGeneric(srcTimestamps, srcValues, currentTimestamp, deduplicateSamplesDuringMerge)
+}
+
+func downsampleSamplesGeneric[V int64 | float64](srcTimestamps []int64, srcValues []V, currentTimestamp int64,
+	dedup func(timestamps []int64, values []V, dedupInterval int64) ([]int64, []V)) ([]int64, []V) {
+	dps := GetDownsamplingPeriods()
+	if len(dps) == 0 || len(srcTimestamps) < 2 {
+		return srcTimestamps, srcValues
+	}
+	if srcTimestamps[0] >= getDownsamplingDeadline(&dps[len(dps)-1], currentTimestamp) {
+		// Fast path - all the samples are too young for downsampling.
+		return srcTimestamps, srcValues
+	}
+
+	// dps are sorted by offset in descending order, so the oldest samples are processed first.
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	i := 0
+	for k := range dps {
+		dp := &dps[k]
+		deadline := getDownsamplingDeadline(dp, currentTimestamp)
+		n := sort.Search(len(srcTimestamps)-i, func(n int) bool {
+			return srcTimestamps[i+n] >= deadline
+		})
+		if n == 0 {
+			continue
+		}
+		// It is safe appending to dstTimestamps and dstValues here, since they never outrun the source position i.
+		timestamps, values := dedup(srcTimestamps[i:i+n], srcValues[i:i+n], dp.Interval)
+		dstTimestamps = append(dstTimestamps, timestamps...)
+		dstValues = append(dstValues, values...)
+		i += n
+	}
+	dstTimestamps = append(dstTimestamps, srcTimestamps[i:]...)
+	dstValues = append(dstValues, srcValues[i:]...)
+	return dstTimestamps, dstValues
+}
+
+func getCurrentTimestampForDownsampling() int64 {
+	return int64(fasttime.UnixTimestamp()) * 1000
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDownsamplingPeriodsSuccess(t *testing.T) {
+	f := func(ss []string, dpsExpected []DownsamplingPeriod) {
+		t.Helper()
+		dps, err := ParseDownsamplingPeriods(ss)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(dps, dpsExpected) {
+			t.Fatalf("unexpected downsampling periods;\ngot\n%v\nwant\n%v", dps, dpsExpected)
+		}
+	}
+	f(nil, []DownsamplingPeriod{})
+	f([]string{"30d:5m"}, []DownsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+	})
+	f([]string{"30d:5m", " 180d:1h"}, []DownsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 5 * 60 * 1000},
+		{Offset: 180 * 24 * 3600 * 1000, Interval: 3600 * 1000},
+	})
+}
+
+func TestParseDownsamplingPeriodsFailure(t *testing.T) {
+	f := func(ss []string) {
+		t.Helper()
+		_, err := ParseDownsamplingPeriods(ss)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing interval
+	f([]string{"30d"})
+
+	// invalid offset
+	f([]string{"foo:5m"})
+
+	// invalid interval
+	f([]string{"30d:bar"})
+
+	// zero interval
+	f([]string{"30d:0s"})
+
+	// duplicate offsets
+	f([]string{"30d:5m", "30d:1h"})
+
+	// interval for bigger offset isn't a multiple of interval for smaller offset
+	f([]string{"30d:5m", "180d:7m"})
+	f([]string{"30d:1h", "180d:5m"})
+}
+
+func TestGetDownsamplingInterval(t *testing.T) {
+	defer mustSetDownsamplingPeriods(nil)
+
+	mustSetDownsamplingPeriods([]DownsamplingPeriod{
+		{Offset: 1000, Interval: 10},
+		{Offset: 10000, Interval: 100},
+	})
+	f := func(timestamp, currentTimestamp, intervalExpected int64) {
+		t.Helper()
+		interval := GetDownsamplingInterval(timestamp, currentTimestamp)
+		if interval != intervalExpected {
+			t.Fatalf("unexpected interval for timestamp=%d, currentTimestamp=%d; got %d; want %d", timestamp, currentTimestamp, interval, intervalExpected)
+		}
+	}
+	f(20000, 20000, 0)
+	f(19000, 20000, 0)
+	f(18999, 20000, 10)
+	f(10001, 20000, 10)
+	f(9999, 20000, 100)
+	f(0, 20000, 100)
+}
+
+func TestDownsampleSamplesDuringMerge(t *testing.T) {
+	defer mustSetDownsamplingPeriods(nil)
+
+	f := func(dps []DownsamplingPeriod, currentTimestamp int64, timestamps []int64, timestampsExpected []int64) {
+		t.Helper()
+		mustSetDownsamplingPeriods(dps)
+		timestampsCopy := append([]int64{}, timestamps...)
+		values := append([]int64{}, timestamps...)
+		timestampsCopy, values = downsampleSamplesDuringMerge(timestampsCopy, values, currentTimestamp)
+		if !reflect.DeepEqual(timestampsCopy, timestampsExpected) {
+			t.Fatalf("invalid downsampleSamplesDuringMerge(%v) timestamps;\ngot\n%v\nwant\n%v", timestamps, timestampsCopy, timestampsExpected)
+		}
+		// values must match timestamps, since they are initialized with timestamps.
+		if !reflect.DeepEqual(values, timestampsExpected) {
+			t.Fatalf("invalid downsampleSamplesDuringMerge(%v) values;\ngot\n%v\nwant\n%v", timestamps, values, timestampsExpected)
+		}
+	}
+
+	// downsampling is disabled
+	f(nil, 100, []int64{1, 2, 3, 4}, []int64{1, 2, 3, 4})
+
+	// all the samples are too young
+	f([]DownsamplingPeriod{{Offset: 50, Interval: 10}}, 100, []int64{51, 52, 53, 60, 70}, []int64{51, 52, 53, 60, 70})
+
+	// all the samples are downsampled
+	f([]DownsamplingPeriod{{Offset: 50, Interval: 10}}, 100, []int64{1, 2, 3, 11, 15, 20, 21, 29}, []int64{3, 20, 29})
+
+	// only old samples are downsampled
+	f([]DownsamplingPeriod{{Offset: 50, Interval: 10}}, 100, []int64{1, 2, 3, 41, 45, 49, 51, 52, 53}, []int64{3, 49, 51, 52, 53})
+
+	// multiple downsampling periods
+	f([]DownsamplingPeriod{
+		{Offset: 50, Interval: 10},
+		{Offset: 80, Interval: 20},
+	}, 100, []int64{1, 2, 3, 15, 19, 21, 25, 29, 31, 49, 50, 51, 52}, []int64{19, 29, 31, 49, 50, 51, 52})
+}
+
+func TestStorageDownsamplingDuringMerge(t *testing.T) {
+	defer testRemoveAll(t)
+	defer mustSetDownsamplingPeriods(nil)
+
+	mustSetDownsamplingPeriods([]DownsamplingPeriod{
+		{Offset: 30 * 24 * 3600 * 1000, Interval: 60 * 1000},
+	})
+
+	// Generate samples with 10s interval for a single hour 60 days ago.
+	startTimestamp := time.Now().Add(-60 * 24 * time.Hour).Truncate(time.Hour).UnixMilli()
+	var mn MetricName
+	mn.MetricGroup = []byte("metric")
+	metricNameRaw := mn.marshalRaw(nil)
+	var mrs []MetricRow
+	for i := 0; i < 360; i++ {
+		mrs = append(mrs, MetricRow{
+			MetricNameRaw: metricNameRaw,
+			Timestamp:     startTimestamp + 1000 + int64(i)*10*1000,
+			Value:         float64(i),
+		})
+	}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.DebugFlush()
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	// A single sample per minute must be left after the merge.
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.TotalRowsCount(); rowsCount != 60 {
+		t.Fatalf("unexpected number of rows after the merge; got %d; want %d", rowsCount, 60)
+	}
+	if m.DownsampledSamplesDuringMerge < 300 {
+		t.Fatalf("unexpected number of downsampled samples; got %d; want at least %d", m.DownsampledSamplesDuringMerge, 300)
+	}
+}
+
+func mustSetDownsamplingPeriods(dps []DownsamplingPeriod) {
+	if err := SetDownsamplingPeriods(dps); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,483 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// Exemplar is an exemplar attached to a sample, such as trace_id for the request with the sampled latency.
+//
+// See https://github.com/OpenObservability/OpenMetrics/blob/main/specification/OpenMetrics.md#exemplars
+type Exemplar struct {
+	// Labels contains exemplar labels such as trace_id.
+	Labels []Tag
+
+	// Value is the exemplar value.
+	Value float64
+
+	// Timestamp is the exemplar timestamp in milliseconds.
+	Timestamp int64
+}
+
+// ExemplarRow is an exemplar for the series with the given MetricNameRaw.
+type ExemplarRow struct {
+	// MetricNameRaw contains raw metric name, which must be decoded
+	// with MetricName.UnmarshalRaw.
+	MetricNameRaw []byte
+
+	// Exemplar is the exemplar for the series.
+	Exemplar Exemplar
+}
+
+// SeriesExemplars contains exemplars for a single series.
+type SeriesExemplars struct {
+	// MetricName is the series name.
+	MetricName MetricName
+
+	// Exemplars contains exemplars for the series sorted by timestamp.
+	Exemplars []Exemplar
+}
+
+// SetMaxExemplars sets the maximum number of exemplars, which can be stored in the storage.
+//
+// Exemplars aren't stored if maxItems is set to 0.
+//
+// This function must be called before opening the storage.
+func SetMaxExemplars(maxItems int) {
+	maxExemplars = maxItems
+}
+
+var maxExemplars = 100000
+
+// exemplarStorage is a bounded in-memory storage for exemplars.
+//
+// The oldest exemplars are overwritten by new exemplars when the storage is full.
+// The contents of the storage is persisted to disk periodically and on the storage close.
+type exemplarStorage struct {
+	// maxItems is the maximum number of exemplars in the storage.
+	maxItems int
+
+	mu sync.Mutex
+
+	// items is a ring buffer with exemplars.
+	items []exemplarItem
+
+	// nextIdx is the index of the oldest item in items, which is overwritten by the next exemplar when items is full.
+	nextIdx int
+
+	// series contains exemplar series keyed by canonical marshaled MetricName.
+	series map[string]*exemplarSeries
+
+	// generation is incremented on every change of items. It is used for detecting whether items must be persisted.
+	generation uint64
+
+	// savedGeneration is the generation of the last persisted items.
+	savedGeneration uint64
+
+	addedTotal      uint64
+	outOfOrderTotal uint64
+	duplicatesTotal uint64
+
+	mn     MetricName
+	keyBuf []byte
+}
+
+type exemplarSeries struct {
+	// key is canonical marshaled MetricName for the series.
+	key string
+
+	// itemsCount is the number of exemplars for the series in exemplarStorage.items.
+	itemsCount int
+
+	// lastIdx is the index of the last added exemplar for the series in exemplarStorage.items.
+	//
+	// The last exemplar cannot be overwritten while the series exists,
+	// since older exemplars for the series are overwritten first.
+	lastIdx int
+}
+
+type exemplarItem struct {
+	series *exemplarSeries
+
+	// labels contains a copy of exemplar labels. It mustn't be modified after the creation.
+	labels []Tag
+
+	value     float64
+	timestamp int64
+}
+
+func newExemplarStorage(maxItems int) *exemplarStorage {
+	return &exemplarStorage{
+		maxItems: maxItems,
+		series:   make(map[string]*exemplarSeries),
+	}
+}
+
+// add adds ers to es.
+func (es *exemplarStorage) add(ers []ExemplarRow) {
+	if es.maxItems <= 0 {
+		return
+	}
+	var firstWarn error
+	es.mu.Lock()
+	for i := range ers {
+		er := &ers[i]
+		if err := es.mn.UnmarshalRaw(er.MetricNameRaw); err != nil {
+			if firstWarn == nil {
+				firstWarn = fmt.Errorf("cannot unmarshal MetricNameRaw %q for the exemplar: %w", er.MetricNameRaw, err)
+			}
+			continue
+		}
+		es.mn.sortTags()
+		es.keyBuf = es.mn.Marshal(es.keyBuf[:0])
+		es.addLocked(bytesutil.ToUnsafeString(es.keyBuf), er.Exemplar.Labels, er.Exemplar.Value, er.Exemplar.Timestamp)
+	}
+	es.mu.Unlock()
+	if firstWarn != nil {
+		logger.WithThrottler("exemplarsAdd", 5*time.Second).Warnf("cannot add exemplars: %s", firstWarn)
+	}
+}
+
+func (es *exemplarStorage) addLocked(key string, labels []Tag, value float64, timestamp int64) {
+	ser := es.series[key]
+	if ser != nil {
+		last := &es.items[ser.lastIdx]
+		if timestamp < last.timestamp {
+			// Drop out-of-order exemplar like Prometheus does.
+			es.outOfOrderTotal++
+			return
+		}
+		if value == last.value && areEqualTags(labels, last.labels) {
+			// Drop duplicate exemplar. Such exemplars are usually obtained
+			// when scraping the same exemplar multiple times.
+			es.duplicatesTotal++
+			return
+		}
+	} else {
+		ser = &exemplarSeries{
+			key: strings.Clone(key),
+		}
+		es.series[ser.key] = ser
+	}
+	ser.itemsCount++
+	es.addedTotal++
+	es.generation++
+
+	item := exemplarItem{
+		series:    ser,
+		labels:    copyTags(nil, labels),
+		value:     value,
+		timestamp: timestamp,
+	}
+	if len(es.items) < es.maxItems {
+		ser.lastIdx = len(es.items)
+		es.items = append(es.items, item)
+		return
+	}
+
+	// Overwrite the oldest exemplar.
+	oldItem := &es.items[es.nextIdx]
+	oldSeries := oldItem.series
+	oldSeries.itemsCount--
+	if oldSeries.itemsCount == 0 {
+		delete(es.series, oldSeries.key)
+	}
+	*oldItem = item
+	ser.lastIdx = es.nextIdx
+	es.nextIdx++
+	if es.nextIdx >= len(es.items) {
+		es.nextIdx = 0
+	}
+}
+
+func areEqualTags(a, b []Tag) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(&b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// forEachItemLocked calls f for every item in es in the order of addition.
+//
+// es.mu must be locked when calling this function.
+func (es *exemplarStorage) forEachItemLocked(f func(item *exemplarItem) bool) {
+	items := es.items
+	for i := es.nextIdx; i < len(items); i++ {
+		if !f(&items[i]) {
+			return
+		}
+	}
+	for i := 0; i < es.nextIdx; i++ {
+		if !f(&items[i]) {
+			return
+		}
+	}
+}
+
+// search returns exemplars on the given tr for series matching any of tfss.
+//
+// An error is returned if the number of matching series exceeds maxSeries.
+func (es *exemplarStorage) search(tfss []*TagFilters, tr TimeRange, maxSeries int) ([]SeriesExemplars, error) {
+	// Make copies of tag filters, since matchTagFilters may re-order them.
+	tfssCopy := make([][]*tagFilter, len(tfss))
+	for i, tfs := range tfss {
+		a := make([]*tagFilter, len(tfs.tfs))
+		for j := range tfs.tfs {
+			a[j] = &tfs.tfs[j]
+		}
+		tfssCopy[i] = a
+	}
+
+	var result []SeriesExemplars
+	var err error
+	var mn MetricName
+	var kb bytesutil.ByteBuffer
+	// seriesIdxs contains indexes in result for the matching series and -1 for non-matching series.
+	seriesIdxs := make(map[*exemplarSeries]int)
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	es.forEachItemLocked(func(item *exemplarItem) bool {
+		if item.timestamp < tr.MinTimestamp || item.timestamp > tr.MaxTimestamp {
+			return true
+		}
+		idx, ok := seriesIdxs[item.series]
+		if !ok {
+			idx = -1
+			if err = mn.UnmarshalString(item.series.key); err != nil {
+				err = fmt.Errorf("cannot unmarshal exemplar series name: %w", err)
+				return false
+			}
+			var matched bool
+			matched, err = matchTagFiltersAny(&mn, tfssCopy, &kb)
+			if err != nil {
+				return false
+			}
+			if matched {
+				if len(result) >= maxSeries {
+					err = fmt.Errorf("the number of series with exemplars exceeds %d", maxSeries)
+					return false
+				}
+				idx = len(result)
+				result = append(result, SeriesExemplars{})
+				result[idx].MetricName.CopyFrom(&mn)
+			}
+			seriesIdxs[item.series] = idx
+		}
+		if idx < 0 {
+			return true
+		}
+		se := &result[idx]
+		se.Exemplars = append(se.Exemplars, Exemplar{
+			Labels:    item.labels,
+			Value:     item.value,
+			Timestamp: item.timestamp,
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func matchTagFiltersAny(mn *MetricName, tfss [][]*tagFilter, kb *bytesutil.ByteBuffer) (bool, error) {
+	for _, tfs := range tfss {
+		ok, err := matchTagFilters(mn, tfs, kb)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (es *exemplarStorage) updateMetrics(m *Metrics) {
+	es.mu.Lock()
+	m.ExemplarsCount += uint64(len(es.items))
+	m.ExemplarSeriesCount += uint64(len(es.series))
+	m.ExemplarsMaxCount += uint64(es.maxItems)
+	m.ExemplarsAddedTotal += es.addedTotal
+	m.ExemplarsOutOfOrderTotal += es.outOfOrderTotal
+	m.ExemplarsDuplicatesTotal += es.duplicatesTotal
+	es.mu.Unlock()
+}
+
+// marshalLocked appends marshaled items from es to dst and returns the result.
+//
+// es.mu must be locked when calling this function.
+func (es *exemplarStorage) marshalLocked(dst []byte) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(es.items)))
+	es.forEachItemLocked(func(item *exemplarItem) bool {
+		dst = encoding.MarshalBytes(dst, bytesutil.ToUnsafeBytes(item.series.key))
+		dst = encoding.MarshalVarUint64(dst, uint64(len(item.labels)))
+		for i := range item.labels {
+			t := &item.labels[i]
+			dst = encoding.MarshalBytes(dst, t.Key)
+			dst = encoding.MarshalBytes(dst, t.Value)
+		}
+		dst = encoding.MarshalUint64(dst, math.Float64bits(item.value))
+		dst = encoding.MarshalInt64(dst, item.timestamp)
+		return true
+	})
+	return dst
+}
+
+// unmarshal adds exemplars from src to es.
+func (es *exemplarStorage) unmarshal(src []byte) error {
+	itemsLen, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return fmt.Errorf("cannot unmarshal the number of exemplars")
+	}
+	src = src[nSize:]
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	var labels []Tag
+	for i := uint64(0); i < itemsLen; i++ {
+		key, nSize := encoding.UnmarshalBytes(src)
+		if nSize <= 0 {
+			return fmt.Errorf("cannot unmarshal series name for exemplar #%d", i)
+		}
+		src = src[nSize:]
+		labelsLen, nSize := encoding.UnmarshalVarUint64(src)
+		if nSize <= 0 {
+			return fmt.Errorf("cannot unmarshal the number of labels for exemplar #%d", i)
+		}
+		src = src[nSize:]
+		labels = labels[:0]
+		for j := uint64(0); j < labelsLen; j++ {
+			k, nSize := encoding.UnmarshalBytes(src)
+			if nSize <= 0 {
+				return fmt.Errorf("cannot unmarshal label name #%d for exemplar #%d", j, i)
+			}
+			src = src[nSize:]
+			v, nSize := encoding.UnmarshalBytes(src)
+			if nSize <= 0 {
+				return fmt.Errorf("cannot unmarshal label value #%d for exemplar #%d", j, i)
+			}
+			src = src[nSize:]
+			labels = append(labels, Tag{
+				Key:   k,
+				Value: v,
+			})
+		}
+		if len(src) < 16 {
+			return fmt.Errorf("cannot unmarshal value and timestamp for exemplar #%d; got %d bytes; want at least 16 bytes", i, len(src))
+		}
+		value := math.Float64frombits(encoding.UnmarshalUint64(src))
+		timestamp := encoding.UnmarshalInt64(src[8:])
+		src = src[16:]
+		es.addLocked(bytesutil.ToUnsafeString(key), labels, value, timestamp)
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling exemplars; len(tail)=%d", len(src))
+	}
+	es.savedGeneration = es.generation
+	return nil
+}
+
+const exemplarsFilename = "exemplars.bin"
+
+func mustLoadExemplarStorage(path string, maxItems int) *exemplarStorage {
+	es := newExemplarStorage(maxItems)
+	filePath := filepath.Join(path, exemplarsFilename)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return es
+		}
+		logger.Panicf("FATAL: cannot read exemplars: %s", err)
+	}
+	if maxItems <= 0 {
+		return es
+	}
+	if err := es.unmarshal(data); err != nil {
+		logger.Errorf("discarding exemplars at %q, since they cannot be unmarshaled: %s", filePath, err)
+		return newExemplarStorage(maxItems)
+	}
+	return es
+}
+
+// mustSave persists es to the given path if it has been changed since the last save.
+func (es *exemplarStorage) mustSave(path string) {
+	es.mu.Lock()
+	if es.generation == es.savedGeneration {
+		es.mu.Unlock()
+		return
+	}
+	data := es.marshalLocked(nil)
+	generation := es.generation
+	es.mu.Unlock()
+
+	fs.MustMkdirIfNotExist(path)
+	fs.MustWriteAtomic(filepath.Join(path, exemplarsFilename), data, true)
+
+	es.mu.Lock()
+	es.savedGeneration = generation
+	es.mu.Unlock()
+}
+
+// AddExemplars adds ers to s.
+//
+// Exemplars are stored in a bounded storage, which overwrites the oldest exemplars when it is full.
+// See SetMaxExemplars.
+func (s *Storage) AddExemplars(ers []ExemplarRow) {
+	s.exemplars.add(ers)
+}
+
+// SearchExemplars returns exemplars on the given tr for series matching any of tfss.
+func (s *Storage) SearchExemplars(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxSeries int) ([]SeriesExemplars, error) {
+	qt = qt.NewChild("search exemplars: filters=%s, timeRange=%s", tfss, &tr)
+	defer qt.Done()
+	result, err := s.exemplars.search(tfss, tr, maxSeries)
+	if err != nil {
+		return nil, err
+	}
+	qt.Printf("found exemplars for %d series", len(result))
+	return result, nil
+}
+
+func (s *Storage) startExemplarsSaver() {
+	s.exemplarsSaverWG.Add(1)
+	go func() {
+		s.exemplarsSaver()
+		s.exemplarsSaverWG.Done()
+	}()
+}
+
+func (s *Storage) exemplarsSaver() {
+	path := filepath.Join(s.path, exemplarsDirname)
+	d := timeutil.AddJitterToDuration(time.Minute)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.exemplars.mustSave(path)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestExemplarStorageAddSearch(t *testing.T) {
+	newExemplarRow := func(job, traceID string, value float64, timestamp int64) ExemplarRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("latency")
+		mn.AddTag("job", job)
+		return ExemplarRow{
+			MetricNameRaw: mn.marshalRaw(nil),
+			Exemplar: Exemplar{
+				Labels: []Tag{{
+					Key:   []byte("trace_id"),
+					Value: []byte(traceID),
+				}},
+				Value:     value,
+				Timestamp: timestamp,
+			},
+		}
+	}
+	newTagFilters := func(job string) []*TagFilters {
+		tfs := NewTagFilters()
+		if err := tfs.Add([]byte("job"), []byte(job), false, true); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		return []*TagFilters{tfs}
+	}
+	f := func(es *exemplarStorage, job string, tr TimeRange, resultExpected []string) {
+		t.Helper()
+		result, err := es.search(newTagFilters(job), tr, 10)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var a []string
+		for _, se := range result {
+			for _, e := range se.Exemplars {
+				a = append(a, fmt.Sprintf("%s %s=%s %v %d", &se.MetricName, e.Labels[0].Key, e.Labels[0].Value, e.Value, e.Timestamp))
+			}
+		}
+		if !reflect.DeepEqual(a, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", a, resultExpected)
+		}
+	}
+
+	es := newExemplarStorage(4)
+	es.add([]ExemplarRow{
+		newExemplarRow("foo", "a", 1, 10),
+		newExemplarRow("bar", "b", 2, 20),
+		newExemplarRow("foo", "c", 3, 30),
+
+		// out-of-order exemplar must be dropped
+		newExemplarRow("foo", "d", 4, 25),
+
+		// duplicate exemplar must be dropped
+		newExemplarRow("foo", "c", 3, 40),
+	})
+	f(es, "foo", TimeRange{MinTimestamp: 0, MaxTimestamp: 100}, []string{
+		`latency{job="foo"} trace_id=a 1 10`,
+		`latency{job="foo"} trace_id=c 3 30`,
+	})
+	f(es, "foo|bar", TimeRange{MinTimestamp: 15, MaxTimestamp: 100}, []string{
+		`latency{job="bar"} trace_id=b 2 20`,
+		`latency{job="foo"} trace_id=c 3 30`,
+	})
+	f(es, "baz", TimeRange{MinTimestamp: 0, MaxTimestamp: 100}, nil)
+
+	// The oldest exemplars must be overwritten when the storage is full.
+	es.add([]ExemplarRow{
+		newExemplarRow("baz", "e", 5, 50),
+		newExemplarRow("baz", "f", 6, 60),
+		newExemplarRow("baz", "g", 7, 70),
+	})
+	f(es, "foo|bar|baz", TimeRange{MinTimestamp: 0, MaxTimestamp: 100}, []string{
+		`latency{job="foo"} trace_id=c 3 30`,
+		`latency{job="baz"} trace_id=e 5 50`,
+		`latency{job="baz"} trace_id=f 6 60`,
+		`latency{job="baz"} trace_id=g 7 70`,
+	})
+	if n := len(es.series); n != 2 {
+		t.Fatalf("unexpected number of exemplar series; got %d; want %d", n, 2)
+	}
+
+	// Exemplars must be preserved after marshaling and unmarshaling.
+	es.mu.Lock()
+	data := es.marshalLocked(nil)
+	es.mu.Unlock()
+	es2 := newExemplarStorage(4)
+	if err := es2.unmarshal(data); err != nil {
+		t.Fatalf("cannot unmarshal exemplars: %s", err)
+	}
+	f(es2, "foo|bar|baz", TimeRange{MinTimestamp: 0, MaxTimestamp: 100}, []string{
+		`latency{job="foo"} trace_id=c 3 30`,
+		`latency{job="baz"} trace_id=e 5 50`,
+		`latency{job="baz"} trace_id=f 6 60`,
+		`latency{job="baz"} trace_id=g 7 70`,
+	})
+
+	// Too many series must result in an error.
+	if _, err := es.search(newTagFilters("foo|baz"), TimeRange{MinTimestamp: 0, MaxTimestamp: 100}, 1); err == nil {
+		t.Fatalf("expecting non-nil error when the number of series exceeds the limit")
+	}
+}
+
+func TestStorageExemplarsPersistence(t *testing.T) {
+	defer testRemoveAll(t)
+
+	var mn MetricName
+	mn.MetricGroup = []byte("latency")
+	mn.AddTag("job", "foo")
+	ers := []ExemplarRow{{
+		MetricNameRaw: mn.marshalRaw(nil),
+		Exemplar: Exemplar{
+			Labels: []Tag{{
+				Key:   []byte("trace_id"),
+				Value: []byte("abc"),
+			}},
+			Value:     0.5,
+			Timestamp: 1000,
+		},
+	}}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	s.AddExemplars(ers)
+	s.MustClose()
+
+	s = MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("latency"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	result, err := s.SearchExemplars(nil, []*TagFilters{tfs}, TimeRange{MinTimestamp: 0, MaxTimestamp: 2000}, 10)
+	if err != nil {
+		t.Fatalf("cannot search exemplars: %s", err)
+	}
+	if len(result) != 1 || len(result[0].Exemplars) != 1 {
+		t.Fatalf("unexpected exemplars after restart: %v", result)
+	}
+	e := result[0].Exemplars[0]
+	if string(e.Labels[0].Value) != "abc" || e.Value != 0.5 || e.Timestamp != 1000 {
+		t.Fatalf("unexpected exemplar after restart: %v", e)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.ExemplarsCount != 1 {
+		t.Fatalf("unexpected ExemplarsCount; got %d; want %d", m.ExemplarsCount, 1)
+	}
+}
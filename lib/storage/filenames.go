@@ -21,4 +21,8 @@ const (
 	metadataDirname  = "metadata"
 	snapshotsDirname = "snapshots"
 	cacheDirname     = "cache"
+	exemplarsDirname = "exemplars"
+
+	metricMetadataDirname   = "metric_metadata"
+	metricNamesStatsDirname = "metric_names_stats"
 )
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -1428,6 +1429,248 @@ func (is *indexSearch) getTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters,
 	return status, nil
 }
 
+// GetTSDBStatusForDateRange returns topN entries for tsdb status for the given tfss, focusLabel and [minDate..maxDate] date range.
+//
+// Series are counted only once on the whole date range. The returned status also contains stats for new series
+// per each day on the date range, e.g. series, which were missing on the previous day.
+//
+// The stats are collected from the global index if minDate and maxDate are set to 0.
+func (db *indexDB) GetTSDBStatusForDateRange(qt *querytracer.Tracer, tfss []*TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*TSDBStatus, error) {
+	if minDate > maxDate {
+		return nil, fmt.Errorf("minDate=%d cannot exceed maxDate=%d", minDate, maxDate)
+	}
+	if maxDate == 0 {
+		return db.GetTSDBStatus(qt, tfss, 0, focusLabel, topN, maxMetrics, deadline)
+	}
+	if minDate == 0 {
+		// Zero date is reserved for the global index.
+		minDate = 1
+	}
+
+	var status *TSDBStatus
+	if minDate == maxDate {
+		// Fast path - obtain the stats directly from per-day index without the need to read metric names.
+		var err error
+		status, err = db.GetTSDBStatus(qt, tfss, minDate, focusLabel, topN, maxMetrics, deadline)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	qt = qt.NewChild("collect tsdb stats on the date range [%d..%d]", minDate, maxDate)
+	defer qt.Done()
+	prevMetricIDs := &uint64set.Set{}
+	if minDate > 1 {
+		var err error
+		prevMetricIDs, err = db.getTSDBStatusMetricIDsForDate(qt, tfss, minDate-1, maxMetrics, deadline)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var allMetricIDs uint64set.Set
+	var seriesChurnByDate []TSDBChurnEntry
+	for date := minDate; date <= maxDate; date++ {
+		metricIDs, err := db.getTSDBStatusMetricIDsForDate(qt, tfss, date, maxMetrics, deadline)
+		if err != nil {
+			return nil, err
+		}
+		if metricIDs.Len() > 0 {
+			newMetricIDs := metricIDs.Clone()
+			newMetricIDs.Subtract(prevMetricIDs)
+			sls, err := db.getSeriesLabelsStats(newMetricIDs, deadline)
+			if err != nil {
+				return nil, err
+			}
+			seriesChurnByDate = append(seriesChurnByDate, TSDBChurnEntry{
+				Date:                           date,
+				NewSeries:                      sls.totalSeries,
+				NewSeriesCountByMetricName:     sls.getSeriesCountByMetricName(topN),
+				NewSeriesCountByLabelValuePair: sls.getSeriesCountByLabelValuePair(topN),
+			})
+		}
+		if status == nil {
+			allMetricIDs.Union(metricIDs)
+			if allMetricIDs.Len() > maxMetrics {
+				return nil, errTooManyTimeseries(maxMetrics)
+			}
+		}
+		prevMetricIDs = metricIDs
+	}
+	qt.Printf("collected churn stats for %d days", len(seriesChurnByDate))
+
+	if status == nil {
+		sls, err := db.getSeriesLabelsStats(&allMetricIDs, deadline)
+		if err != nil {
+			return nil, err
+		}
+		status = sls.getTSDBStatus(focusLabel, topN)
+		qt.Printf("collected stats for %d unique series", status.TotalSeries)
+	}
+	status.SeriesChurnByDate = seriesChurnByDate
+	return status, nil
+}
+
+// getTSDBStatusMetricIDsForDate returns metricIDs for series matching tfss on the given date in db and in extDB.
+//
+// Deleted metricIDs are excluded from the returned set.
+func (db *indexDB) getTSDBStatusMetricIDsForDate(qt *querytracer.Tracer, tfss []*TagFilters, date uint64, maxMetrics int, deadline uint64) (*uint64set.Set, error) {
+	is := db.getIndexSearch(deadline)
+	metricIDs, err := is.getTSDBStatusMetricIDsForDate(qt, tfss, date, maxMetrics)
+	db.putIndexSearch(is)
+	if err != nil {
+		return nil, err
+	}
+	db.doExtDB(func(extDB *indexDB) {
+		is := extDB.getIndexSearch(deadline)
+		var extMetricIDs *uint64set.Set
+		extMetricIDs, err = is.getTSDBStatusMetricIDsForDate(qt, tfss, date, maxMetrics)
+		extDB.putIndexSearch(is)
+		if err == nil {
+			metricIDs.UnionMayOwn(extMetricIDs)
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error when searching for metricIDs on the date %d in extDB: %w", date, err)
+	}
+	metricIDs.Subtract(db.s.getDeletedMetricIDs())
+	if metricIDs.Len() > maxMetrics {
+		return nil, errTooManyTimeseries(maxMetrics)
+	}
+	return metricIDs, nil
+}
+
+func (is *indexSearch) getTSDBStatusMetricIDsForDate(qt *querytracer.Tracer, tfss []*TagFilters, date uint64, maxMetrics int) (*uint64set.Set, error) {
+	if len(tfss) > 0 {
+		return is.searchMetricIDsWithFiltersOnDate(qt, tfss, date, maxMetrics)
+	}
+	if !is.containsTimeRange(TimeRange{MinTimestamp: int64(date) * msecPerDay, MaxTimestamp: int64(date+1)*msecPerDay - 1}) {
+		return &uint64set.Set{}, nil
+	}
+	return is.getMetricIDsForDate(date, maxMetrics+1)
+}
+
+// seriesLabelsStats contains the number of series per each label=value pair.
+type seriesLabelsStats struct {
+	totalSeries                 uint64
+	seriesCountByLabelValuePair map[string]uint64
+}
+
+// getSeriesLabelsStats returns seriesLabelsStats for series with the given metricIDs.
+func (db *indexDB) getSeriesLabelsStats(metricIDs *uint64set.Set, deadline uint64) (*seriesLabelsStats, error) {
+	sls := &seriesLabelsStats{
+		seriesCountByLabelValuePair: make(map[string]uint64),
+	}
+	var metricName, buf []byte
+	var mn MetricName
+	var err error
+	loopsPaceLimiter := 0
+	metricIDs.ForEach(func(part []uint64) bool {
+		for _, metricID := range part {
+			if loopsPaceLimiter&paceLimiterSlowIterationsMask == 0 {
+				if err = checkSearchDeadlineAndPace(deadline); err != nil {
+					return false
+				}
+			}
+			loopsPaceLimiter++
+			var ok bool
+			metricName, ok = db.searchMetricNameWithCache(metricName[:0], metricID)
+			if !ok {
+				// The metric name may be missing for the series registered in the per-day index
+				// of the indexdb, which is being rotated. Skip such series.
+				continue
+			}
+			if err = mn.Unmarshal(metricName); err != nil {
+				err = fmt.Errorf("cannot unmarshal metricName %q for metricID=%d: %w", metricName, metricID, err)
+				return false
+			}
+			sls.totalSeries++
+			buf = append(buf[:0], "__name__="...)
+			buf = append(buf, mn.MetricGroup...)
+			sls.seriesCountByLabelValuePair[string(buf)]++
+			for _, tag := range mn.Tags {
+				buf = append(buf[:0], tag.Key...)
+				buf = append(buf, '=')
+				buf = append(buf, tag.Value...)
+				sls.seriesCountByLabelValuePair[string(buf)]++
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sls, nil
+}
+
+func (sls *seriesLabelsStats) getSeriesCountByMetricName(topN int) []TopHeapEntry {
+	th := newTopHeap(topN)
+	for _, labelValuePair := range sls.getSortedLabelValuePairs() {
+		if metricName, ok := strings.CutPrefix(labelValuePair, "__name__="); ok {
+			th.push([]byte(metricName), sls.seriesCountByLabelValuePair[labelValuePair])
+		}
+	}
+	return th.getSortedResult()
+}
+
+func (sls *seriesLabelsStats) getSeriesCountByLabelValuePair(topN int) []TopHeapEntry {
+	th := newTopHeap(topN)
+	for _, labelValuePair := range sls.getSortedLabelValuePairs() {
+		th.push([]byte(labelValuePair), sls.seriesCountByLabelValuePair[labelValuePair])
+	}
+	return th.getSortedResult()
+}
+
+func (sls *seriesLabelsStats) getTSDBStatus(focusLabel string, topN int) *TSDBStatus {
+	thSeriesCountByMetricName := newTopHeap(topN)
+	thSeriesCountByLabelName := newTopHeap(topN)
+	thSeriesCountByFocusLabelValue := newTopHeap(topN)
+	thSeriesCountByLabelValuePair := newTopHeap(topN)
+	thLabelValueCountByLabelName := newTopHeap(topN)
+	var totalLabelValuePairs, labelSeries, labelValueCountByLabelName uint64
+	var prevLabelName string
+	for _, labelValuePair := range sls.getSortedLabelValuePairs() {
+		seriesCount := sls.seriesCountByLabelValuePair[labelValuePair]
+		labelName, labelValue, _ := strings.Cut(labelValuePair, "=")
+		if labelName != prevLabelName {
+			thSeriesCountByLabelName.push([]byte(prevLabelName), labelSeries)
+			thLabelValueCountByLabelName.push([]byte(prevLabelName), labelValueCountByLabelName)
+			labelSeries = 0
+			labelValueCountByLabelName = 0
+			prevLabelName = labelName
+		}
+		thSeriesCountByLabelValuePair.push([]byte(labelValuePair), seriesCount)
+		if labelName == "__name__" {
+			thSeriesCountByMetricName.push([]byte(labelValue), seriesCount)
+		}
+		if labelName == focusLabel {
+			thSeriesCountByFocusLabelValue.push([]byte(labelValue), seriesCount)
+		}
+		labelSeries += seriesCount
+		labelValueCountByLabelName++
+		totalLabelValuePairs += seriesCount
+	}
+	thSeriesCountByLabelName.push([]byte(prevLabelName), labelSeries)
+	thLabelValueCountByLabelName.push([]byte(prevLabelName), labelValueCountByLabelName)
+	return &TSDBStatus{
+		TotalSeries:                  sls.totalSeries,
+		TotalLabelValuePairs:         totalLabelValuePairs,
+		SeriesCountByMetricName:      thSeriesCountByMetricName.getSortedResult(),
+		SeriesCountByLabelName:       thSeriesCountByLabelName.getSortedResult(),
+		SeriesCountByFocusLabelValue: thSeriesCountByFocusLabelValue.getSortedResult(),
+		SeriesCountByLabelValuePair:  thSeriesCountByLabelValuePair.getSortedResult(),
+		LabelValueCountByLabelName:   thLabelValueCountByLabelName.getSortedResult(),
+	}
+}
+
+func (sls *seriesLabelsStats) getSortedLabelValuePairs() []string {
+	labelValuePairs := make([]string, 0, len(sls.seriesCountByLabelValuePair))
+	for labelValuePair := range sls.seriesCountByLabelValuePair {
+		labelValuePairs = append(labelValuePairs, labelValuePair)
+	}
+	sort.Strings(labelValuePairs)
+	return labelValuePairs
+}
+
 // TSDBStatus contains TSDB status data for /api/v1/status/tsdb.
 //
 // See https://prometheus.io/docs/prometheus/latest/querying/api/#tsdb-stats
@@ -1439,6 +1682,19 @@ type TSDBStatus struct {
 	SeriesCountByFocusLabelValue []TopHeapEntry
 	SeriesCountByLabelValuePair  []TopHeapEntry
 	LabelValueCountByLabelName   []TopHeapEntry
+
+	// SeriesChurnByDate contains stats for new series per each day on the requested date range.
+	//
+	// It is set only by GetTSDBStatusForDateRange.
+	SeriesChurnByDate []TSDBChurnEntry
+}
+
+// TSDBChurnEntry contains stats for new series at the given date, e.g. series, which were missing on the previous day.
+type TSDBChurnEntry struct {
+	Date                           uint64
+	NewSeries                      uint64
+	NewSeriesCountByMetricName     []TopHeapEntry
+	NewSeriesCountByLabelValuePair []TopHeapEntry
 }
 
 func (status *TSDBStatus) hasEntries() bool {
@@ -2285,10 +2541,21 @@ func (is *indexSearch) searchMetricIDs(qt *querytracer.Tracer, tfss []*TagFilter
 }
 
 func errTooManyTimeseries(maxMetrics int) error {
-	return fmt.Errorf("the number of matching timeseries exceeds %d; "+
+	return &tooManyTimeseriesError{
+		maxMetrics: maxMetrics,
+	}
+}
+
+// tooManyTimeseriesError is returned when the number of matching timeseries exceeds maxMetrics.
+type tooManyTimeseriesError struct {
+	maxMetrics int
+}
+
+func (e *tooManyTimeseriesError) Error() string {
+	return fmt.Sprintf("the number of matching timeseries exceeds %d; "+
 		"either narrow down the search or increase -search.max* command-line flag values "+
 		"(the most likely limit is -search.maxUniqueTimeseries); "+
-		"see https://docs.victoriametrics.com/#resource-usage-limits", maxMetrics)
+		"see https://docs.victoriametrics.com/#resource-usage-limits", e.maxMetrics)
 }
 
 func (is *indexSearch) searchMetricIDsInternal(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxMetrics int) (*uint64set.Set, error) {
@@ -2791,6 +3058,8 @@ const (
 )
 
 func (is *indexSearch) createPerDayIndexes(date uint64, tsid *TSID, mn *MetricName) {
+	is.db.s.metricNamesStats.registerIngestedMetricName(mn.MetricGroup)
+
 	ii := getIndexItems()
 	defer putIndexItems(ii)
 
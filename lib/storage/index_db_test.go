@@ -2228,3 +2228,157 @@ func TestSearchContainsTimeRange(t *testing.T) {
 	s.MustClose()
 	fs.MustRemoveAll(path)
 }
+
+func TestIndexDBGetTSDBStatusForDateRange(t *testing.T) {
+	const path = "TestIndexDBGetTSDBStatusForDateRange"
+	s := MustOpenStorage(path, retentionMax, 0, 0)
+	defer func() {
+		s.MustClose()
+		fs.MustRemoveAll(path)
+	}()
+
+	// Register the following series:
+	//
+	// - day 1: a{instance="0".."9"}
+	// - day 2: a{instance="0".."9"}, b{instance="0".."4"}
+	// - day 3: b{instance="0".."4"}, c{instance="0".."2"}
+	baseDate := uint64(time.Now().UnixMilli()/msecPerDay) - 4
+	addSeries := func(date uint64, metricName string, n int) {
+		var mrs []MetricRow
+		for i := 0; i < n; i++ {
+			var mn MetricName
+			mn.MetricGroup = []byte(metricName)
+			mn.AddTag("instance", fmt.Sprintf("%d", i))
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     int64(date)*msecPerDay + 3600*1000,
+				Value:         float64(i),
+			})
+		}
+		s.AddRows(mrs, defaultPrecisionBits)
+	}
+	addSeries(baseDate+1, "a", 10)
+	addSeries(baseDate+2, "a", 10)
+	addSeries(baseDate+2, "b", 5)
+	addSeries(baseDate+3, "b", 5)
+	addSeries(baseDate+3, "c", 3)
+	s.DebugFlush()
+
+	f := func(tfss []*TagFilters, minDate, maxDate uint64, totalSeriesExpected uint64, seriesCountByMetricNameExpected []TopHeapEntry, seriesChurnByDateExpected []TSDBChurnEntry) {
+		t.Helper()
+		status, err := s.GetTSDBStatusForDateRange(nil, tfss, minDate, maxDate, "instance", 10, 1e6, noDeadline)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if status.TotalSeries != totalSeriesExpected {
+			t.Fatalf("unexpected TotalSeries; got %d; want %d", status.TotalSeries, totalSeriesExpected)
+		}
+		if !reflect.DeepEqual(status.SeriesCountByMetricName, seriesCountByMetricNameExpected) {
+			t.Fatalf("unexpected SeriesCountByMetricName;\ngot\n%v\nwant\n%v", status.SeriesCountByMetricName, seriesCountByMetricNameExpected)
+		}
+		if len(status.SeriesChurnByDate) != len(seriesChurnByDateExpected) {
+			t.Fatalf("unexpected number of SeriesChurnByDate entries; got %d; want %d", len(status.SeriesChurnByDate), len(seriesChurnByDateExpected))
+		}
+		for i, e := range status.SeriesChurnByDate {
+			eExpected := seriesChurnByDateExpected[i]
+			if e.Date != eExpected.Date || e.NewSeries != eExpected.NewSeries {
+				t.Fatalf("unexpected SeriesChurnByDate entry #%d; got date=%d, newSeries=%d; want date=%d, newSeries=%d",
+					i, e.Date, e.NewSeries, eExpected.Date, eExpected.NewSeries)
+			}
+			if !reflect.DeepEqual(e.NewSeriesCountByMetricName, eExpected.NewSeriesCountByMetricName) {
+				t.Fatalf("unexpected NewSeriesCountByMetricName in the entry #%d;\ngot\n%v\nwant\n%v", i, e.NewSeriesCountByMetricName, eExpected.NewSeriesCountByMetricName)
+			}
+		}
+	}
+
+	// Multi-day range without filters
+	f(nil, baseDate, baseDate+3, 18, []TopHeapEntry{
+		{Name: "a", Count: 10},
+		{Name: "b", Count: 5},
+		{Name: "c", Count: 3},
+	}, []TSDBChurnEntry{
+		{
+			Date:                       baseDate + 1,
+			NewSeries:                  10,
+			NewSeriesCountByMetricName: []TopHeapEntry{{Name: "a", Count: 10}},
+		},
+		{
+			Date:                       baseDate + 2,
+			NewSeries:                  5,
+			NewSeriesCountByMetricName: []TopHeapEntry{{Name: "b", Count: 5}},
+		},
+		{
+			Date:                       baseDate + 3,
+			NewSeries:                  3,
+			NewSeriesCountByMetricName: []TopHeapEntry{{Name: "c", Count: 3}},
+		},
+	})
+
+	// Single day
+	f(nil, baseDate+2, baseDate+2, 15, []TopHeapEntry{
+		{Name: "a", Count: 10},
+		{Name: "b", Count: 5},
+	}, []TSDBChurnEntry{
+		{
+			Date:                       baseDate + 2,
+			NewSeries:                  5,
+			NewSeriesCountByMetricName: []TopHeapEntry{{Name: "b", Count: 5}},
+		},
+	})
+
+	// Multi-day range with filters
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("a|b"), false, true); err != nil {
+		t.Fatalf("cannot add filter: %s", err)
+	}
+	f([]*TagFilters{tfs}, baseDate+2, baseDate+3, 15, []TopHeapEntry{
+		{Name: "a", Count: 10},
+		{Name: "b", Count: 5},
+	}, []TSDBChurnEntry{
+		{
+			Date:                       baseDate + 2,
+			NewSeries:                  5,
+			NewSeriesCountByMetricName: []TopHeapEntry{{Name: "b", Count: 5}},
+		},
+		{
+			Date:                       baseDate + 3,
+			NewSeries:                  0,
+			NewSeriesCountByMetricName: []TopHeapEntry{},
+		},
+	})
+
+	// Invalid date range
+	if _, err := s.GetTSDBStatusForDateRange(nil, nil, baseDate+1, baseDate, "", 10, 1e6, noDeadline); err == nil {
+		t.Fatalf("expecting non-nil error for invalid date range")
+	}
+}
+
+func TestStorageGetTSDBStatusForDateRangeOutsideRetention(t *testing.T) {
+	const path = "TestStorageGetTSDBStatusForDateRangeOutsideRetention"
+	const retentionDays = 31
+	s := MustOpenStorage(path, retentionDays*24*time.Hour, 0, 0)
+	defer func() {
+		s.MustClose()
+		fs.MustRemoveAll(path)
+	}()
+
+	minRetentionDate := s.GetMinRetentionDate()
+	today := uint64(time.Now().UnixMilli() / msecPerDay)
+	if minRetentionDate+retentionDays < today || minRetentionDate+retentionDays > today+1 {
+		t.Fatalf("unexpected min retention date; got %d; want %d", minRetentionDate, today-retentionDays)
+	}
+
+	// The whole date range is outside the retention
+	status, err := s.GetTSDBStatusForDateRange(nil, nil, 1, minRetentionDate-1, "", 10, 1e6, noDeadline)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if status.TotalSeries != 0 || len(status.SeriesChurnByDate) != 0 {
+		t.Fatalf("expecting empty status; got %+v", status)
+	}
+
+	// The date range is clamped to the retention
+	if _, err := s.GetTSDBStatusForDateRange(nil, nil, 1, today, "", 10, 1e6, noDeadline); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
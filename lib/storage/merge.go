@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 
@@ -16,11 +17,19 @@ import (
 // rowsMerged is atomically updated with the number of merged rows during the merge.
 func mergeBlockStreams(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{}, s *Storage, retentionDeadline int64,
 	rowsMerged, rowsDeleted *atomic.Uint64) error {
+	return mergeBlockStreamsWithFilter(ph, bsw, bsrs, stopCh, s, retentionDeadline, nil, rowsMerged, rowsDeleted)
+}
+
+// mergeBlockStreamsWithFilter works like mergeBlockStreams, but writes only the blocks and the samples matching bf to bsw.
+//
+// All the blocks and samples are written if bf is nil.
+func mergeBlockStreamsWithFilter(ph *partHeader, bsw *blockStreamWriter, bsrs []*blockStreamReader, stopCh <-chan struct{}, s *Storage, retentionDeadline int64,
+	bf *blockFilter, rowsMerged, rowsDeleted *atomic.Uint64) error {
 	ph.Reset()
 
 	bsm := bsmPool.Get().(*blockStreamMerger)
-	bsm.Init(bsrs, retentionDeadline)
-	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, s, rowsMerged, rowsDeleted)
+	bsm.Init(bsrs, s, retentionDeadline)
+	err := mergeBlockStreamsInternal(ph, bsw, bsm, stopCh, s, bf, rowsMerged, rowsDeleted)
 	bsm.reset()
 	bsmPool.Put(bsm)
 	bsw.MustClose()
@@ -38,8 +47,11 @@ var bsmPool = &sync.Pool{
 
 var errForciblyStopped = fmt.Errorf("forcibly stopped")
 
-func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{}, s *Storage, rowsMerged, rowsDeleted *atomic.Uint64) error {
+func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *blockStreamMerger, stopCh <-chan struct{}, s *Storage, bf *blockFilter,
+	rowsMerged, rowsDeleted *atomic.Uint64) error {
 	dmis := s.getDeletedMetricIDs()
+	tss := s.getTombstones()
+	var deletedTimeRanges []TimeRange
 	pendingBlockIsEmpty := true
 	pendingBlock := getBlock()
 	defer putBlock(pendingBlock)
@@ -57,12 +69,43 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 			rowsDeleted.Add(uint64(b.bh.RowsCount))
 			continue
 		}
+		if !bf.hasMetricID(b.bh.TSID.MetricID) {
+			// Skip blocks for metrics not matching the filter.
+			continue
+		}
 		retentionDeadline := bsm.getRetentionDeadline(&b.bh)
 		if b.bh.MaxTimestamp < retentionDeadline {
 			// Skip blocks out of the given retention.
 			rowsDeleted.Add(uint64(b.bh.RowsCount))
 			continue
 		}
+		deletedTimeRanges = deletedTimeRanges[:0]
+		if b.bh.MinTimestamp < retentionDeadline {
+			// The block contains samples outside the given retention.
+			deletedTimeRanges = append(deletedTimeRanges, TimeRange{
+				MinTimestamp: math.MinInt64,
+				MaxTimestamp: retentionDeadline - 1,
+			})
+		}
+		deletedTimeRanges = tss.appendDeletedTimeRanges(deletedTimeRanges, b.bh.TSID.MetricID, bsm.getTombstonesGeneration(), b.bh.MinTimestamp, b.bh.MaxTimestamp)
+		deletedTimeRanges = bf.appendSkippedTimeRanges(deletedTimeRanges, b.bh.MinTimestamp, b.bh.MaxTimestamp)
+		if len(deletedTimeRanges) > 0 {
+			if isCoveredByTimeRanges(deletedTimeRanges, b.bh.MinTimestamp, b.bh.MaxTimestamp) {
+				// Skip blocks with all the samples deleted.
+				rowsDeleted.Add(uint64(b.bh.RowsCount))
+				continue
+			}
+			// Slow path - remove samples outside the retention and samples covered by tombstones from the block.
+			n, err := b.removeSamplesInTimeRanges(deletedTimeRanges)
+			if err != nil {
+				return fmt.Errorf("cannot unmarshal block for removing deleted samples: %w", err)
+			}
+			rowsDeleted.Add(uint64(n))
+			if b.rowsCount() == 0 {
+				continue
+			}
+		}
+		bf.registerBlock(&b.bh)
 		if pendingBlockIsEmpty {
 			// Load the next block if pendingBlock is empty.
 			pendingBlock.CopyFrom(b)
@@ -128,6 +171,8 @@ func mergeBlockStreamsInternal(ph *partHeader, bsw *blockStreamWriter, bsm *bloc
 	if !pendingBlockIsEmpty {
 		bsw.WriteExternalBlock(pendingBlock, ph, rowsMerged)
 	}
+	ph.TombstonesGeneration = tss.getGeneration()
+	ph.RetentionFiltersTimestamp = bsm.rfm.getTimestamp()
 	return nil
 }
 
@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// MetricMetadata is metadata for the metric family, such as its type, help and unit.
+//
+// See https://prometheus.io/docs/prometheus/latest/querying/api/#querying-metric-metadata
+type MetricMetadata struct {
+	// MetricFamilyName is the name of the metric family, such as http_requests_total.
+	MetricFamilyName string
+
+	// Type is the metric type, such as counter, gauge, histogram or summary.
+	Type string
+
+	// Help is the description for the metric family.
+	Help string
+
+	// Unit is the unit for the metric family.
+	Unit string
+
+	// LastSeenTimestamp is unix timestamp in seconds when the metadata has been received for the last time.
+	//
+	// It is ignored by Storage.AddMetricMetadata.
+	LastSeenTimestamp uint64
+}
+
+// SetMaxMetricMetadata sets the maximum number of metric metadata entries, which can be stored in the storage.
+//
+// Metric metadata isn't stored if maxEntries is set to 0.
+//
+// This function must be called before opening the storage.
+func SetMaxMetricMetadata(maxEntries int) {
+	maxMetricMetadata = maxEntries
+}
+
+var maxMetricMetadata = 100000
+
+// metricMetadataMaxStaleness is the duration after which metadata entries without updates are removed.
+const metricMetadataMaxStaleness = 24 * time.Hour
+
+// metricMetadataKey is the key for metadata entry in metricMetadataStorage.
+type metricMetadataKey struct {
+	metricFamilyName string
+	typ              string
+	help             string
+	unit             string
+}
+
+// metricMetadataStorage is a bounded in-memory storage for metric metadata.
+//
+// New metadata entries are dropped when the storage is full.
+// Metadata entries, which weren't updated during metricMetadataMaxStaleness, are removed.
+// The contents of the storage is persisted to disk periodically and on the storage close.
+type metricMetadataStorage struct {
+	// maxEntries is the maximum number of entries in the storage.
+	maxEntries int
+
+	mu sync.Mutex
+
+	// entries contains the last seen timestamp in seconds per each metadata entry.
+	entries map[metricMetadataKey]uint64
+
+	// generation is incremented on every change of entries.
+	generation uint64
+
+	// savedGeneration is the generation of entries persisted to disk.
+	savedGeneration uint64
+
+	addedTotal   uint64
+	droppedTotal uint64
+}
+
+func newMetricMetadataStorage(maxEntries int) *metricMetadataStorage {
+	return &metricMetadataStorage{
+		maxEntries: maxEntries,
+		entries:    make(map[metricMetadataKey]uint64),
+	}
+}
+
+func (ms *metricMetadataStorage) add(mms []MetricMetadata, timestamp uint64) {
+	if ms.maxEntries <= 0 {
+		return
+	}
+
+	ms.mu.Lock()
+	for i := range mms {
+		mm := &mms[i]
+		if mm.MetricFamilyName == "" {
+			continue
+		}
+		key := metricMetadataKey{
+			metricFamilyName: mm.MetricFamilyName,
+			typ:              mm.Type,
+			help:             mm.Help,
+			unit:             mm.Unit,
+		}
+		ms.addLocked(key, timestamp)
+	}
+	ms.mu.Unlock()
+}
+
+func (ms *metricMetadataStorage) addLocked(key metricMetadataKey, timestamp uint64) {
+	if lastSeen, ok := ms.entries[key]; ok {
+		if timestamp > lastSeen {
+			ms.entries[key] = timestamp
+			ms.generation++
+		}
+		return
+	}
+	if len(ms.entries) >= ms.maxEntries {
+		ms.droppedTotal++
+		return
+	}
+	// Clone the strings, since they may refer to the request buffer.
+	key.metricFamilyName = strings.Clone(key.metricFamilyName)
+	key.typ = strings.Clone(key.typ)
+	key.help = strings.Clone(key.help)
+	key.unit = strings.Clone(key.unit)
+	ms.entries[key] = timestamp
+	ms.generation++
+	ms.addedTotal++
+}
+
+// removeStale removes entries, which weren't updated since the given deadline in unix seconds.
+func (ms *metricMetadataStorage) removeStale(deadline uint64) {
+	ms.mu.Lock()
+	for key, lastSeen := range ms.entries {
+		if lastSeen < deadline {
+			delete(ms.entries, key)
+			ms.generation++
+		}
+	}
+	ms.mu.Unlock()
+}
+
+// search returns metadata entries for the given metricFamilyName.
+//
+// Metadata entries for all the metric families are returned if metricFamilyName is empty.
+// The number of returned metric families is limited by limit, while the number of entries per each metric family is limited by limitPerMetric.
+// Zero limit means no limit.
+//
+// The returned entries are sorted by metric family name. Entries for the same metric family are sorted by the last seen timestamp in descending order.
+func (ms *metricMetadataStorage) search(metricFamilyName string, limit, limitPerMetric int) []MetricMetadata {
+	var result []MetricMetadata
+	ms.mu.Lock()
+	for key, lastSeen := range ms.entries {
+		if metricFamilyName != "" && key.metricFamilyName != metricFamilyName {
+			continue
+		}
+		result = append(result, MetricMetadata{
+			MetricFamilyName:  key.metricFamilyName,
+			Type:              key.typ,
+			Help:              key.help,
+			Unit:              key.unit,
+			LastSeenTimestamp: lastSeen,
+		})
+	}
+	ms.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		a, b := &result[i], &result[j]
+		if a.MetricFamilyName != b.MetricFamilyName {
+			return a.MetricFamilyName < b.MetricFamilyName
+		}
+		if a.LastSeenTimestamp != b.LastSeenTimestamp {
+			return a.LastSeenTimestamp > b.LastSeenTimestamp
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Help != b.Help {
+			return a.Help < b.Help
+		}
+		return a.Unit < b.Unit
+	})
+
+	if limit <= 0 && limitPerMetric <= 0 {
+		return result
+	}
+	dst := result[:0]
+	families := 0
+	perMetric := 0
+	for i := range result {
+		mm := &result[i]
+		if i == 0 || mm.MetricFamilyName != result[i-1].MetricFamilyName {
+			if limit > 0 && families >= limit {
+				break
+			}
+			families++
+			perMetric = 0
+		}
+		if limitPerMetric > 0 && perMetric >= limitPerMetric {
+			continue
+		}
+		perMetric++
+		dst = append(dst, *mm)
+	}
+	return dst
+}
+
+func (ms *metricMetadataStorage) updateMetrics(m *Metrics) {
+	ms.mu.Lock()
+	m.MetricMetadataCount += uint64(len(ms.entries))
+	m.MetricMetadataMaxCount += uint64(ms.maxEntries)
+	m.MetricMetadataAddedTotal += ms.addedTotal
+	m.MetricMetadataDroppedTotal += ms.droppedTotal
+	ms.mu.Unlock()
+}
+
+// marshalLocked appends marshaled entries from ms to dst and returns the result.
+//
+// ms.mu must be locked when calling this function.
+func (ms *metricMetadataStorage) marshalLocked(dst []byte) []byte {
+	dst = encoding.MarshalVarUint64(dst, uint64(len(ms.entries)))
+	for key, lastSeen := range ms.entries {
+		dst = encoding.MarshalBytes(dst, []byte(key.metricFamilyName))
+		dst = encoding.MarshalBytes(dst, []byte(key.typ))
+		dst = encoding.MarshalBytes(dst, []byte(key.help))
+		dst = encoding.MarshalBytes(dst, []byte(key.unit))
+		dst = encoding.MarshalUint64(dst, lastSeen)
+	}
+	return dst
+}
+
+// unmarshal adds metadata entries from src to ms.
+func (ms *metricMetadataStorage) unmarshal(src []byte) error {
+	entriesLen, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return fmt.Errorf("cannot unmarshal the number of metadata entries")
+	}
+	src = src[nSize:]
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	var fields [4]string
+	for i := uint64(0); i < entriesLen; i++ {
+		for j := range fields {
+			data, nSize := encoding.UnmarshalBytes(src)
+			if nSize <= 0 {
+				return fmt.Errorf("cannot unmarshal field #%d for metadata entry #%d", j, i)
+			}
+			src = src[nSize:]
+			fields[j] = string(data)
+		}
+		if len(src) < 8 {
+			return fmt.Errorf("cannot unmarshal last seen timestamp for metadata entry #%d; got %d bytes; want at least 8 bytes", i, len(src))
+		}
+		lastSeen := encoding.UnmarshalUint64(src)
+		src = src[8:]
+		key := metricMetadataKey{
+			metricFamilyName: fields[0],
+			typ:              fields[1],
+			help:             fields[2],
+			unit:             fields[3],
+		}
+		ms.addLocked(key, lastSeen)
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling metadata entries; len(tail)=%d", len(src))
+	}
+	ms.savedGeneration = ms.generation
+	return nil
+}
+
+const metricMetadataFilename = "metadata.bin"
+
+func mustLoadMetricMetadataStorage(path string, maxEntries int) *metricMetadataStorage {
+	ms := newMetricMetadataStorage(maxEntries)
+	filePath := filepath.Join(path, metricMetadataFilename)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ms
+		}
+		logger.Panicf("FATAL: cannot read metric metadata: %s", err)
+	}
+	if maxEntries <= 0 {
+		return ms
+	}
+	if err := ms.unmarshal(data); err != nil {
+		logger.Errorf("discarding metric metadata at %q, since it cannot be unmarshaled: %s", filePath, err)
+		return newMetricMetadataStorage(maxEntries)
+	}
+	return ms
+}
+
+// mustSave persists ms to the given path if it has been changed since the last save.
+func (ms *metricMetadataStorage) mustSave(path string) {
+	ms.mu.Lock()
+	if ms.generation == ms.savedGeneration {
+		ms.mu.Unlock()
+		return
+	}
+	data := ms.marshalLocked(nil)
+	generation := ms.generation
+	ms.mu.Unlock()
+
+	fs.MustMkdirIfNotExist(path)
+	fs.MustWriteAtomic(filepath.Join(path, metricMetadataFilename), data, true)
+
+	ms.mu.Lock()
+	ms.savedGeneration = generation
+	ms.mu.Unlock()
+}
+
+// AddMetricMetadata adds mms to s.
+//
+// The last seen timestamp for mms is set to the current time.
+// See SetMaxMetricMetadata.
+func (s *Storage) AddMetricMetadata(mms []MetricMetadata) {
+	s.metricMetadata.add(mms, fasttime.UnixTimestamp())
+}
+
+// SearchMetricMetadata returns metadata entries for the given metricFamilyName.
+//
+// Metadata for all the metric families is returned if metricFamilyName is empty.
+// limit limits the number of returned metric families, while limitPerMetric limits the number of entries per each metric family.
+// Zero limit means no limit.
+func (s *Storage) SearchMetricMetadata(qt *querytracer.Tracer, metricFamilyName string, limit, limitPerMetric int) []MetricMetadata {
+	qt = qt.NewChild("search metric metadata: metric=%q, limit=%d, limitPerMetric=%d", metricFamilyName, limit, limitPerMetric)
+	defer qt.Done()
+	result := s.metricMetadata.search(metricFamilyName, limit, limitPerMetric)
+	qt.Printf("found %d metadata entries", len(result))
+	return result
+}
+
+func (s *Storage) startMetricMetadataSaver() {
+	s.metricMetadataSaverWG.Add(1)
+	go func() {
+		s.metricMetadataSaver()
+		s.metricMetadataSaverWG.Done()
+	}()
+}
+
+func (s *Storage) metricMetadataSaver() {
+	path := filepath.Join(s.path, metricMetadataDirname)
+	d := timeutil.AddJitterToDuration(time.Minute)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			deadline := fasttime.UnixTimestamp() - uint64(metricMetadataMaxStaleness.Seconds())
+			s.metricMetadata.removeStale(deadline)
+			s.metricMetadata.mustSave(path)
+		}
+	}
+}
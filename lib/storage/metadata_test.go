@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestMetricMetadataStorageAddSearch(t *testing.T) {
+	f := func(ms *metricMetadataStorage, metricFamilyName string, limit, limitPerMetric int, resultExpected []string) {
+		t.Helper()
+		var result []string
+		for _, mm := range ms.search(metricFamilyName, limit, limitPerMetric) {
+			result = append(result, fmt.Sprintf("%s %s %q %s %d", mm.MetricFamilyName, mm.Type, mm.Help, mm.Unit, mm.LastSeenTimestamp))
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+
+	ms := newMetricMetadataStorage(3)
+	ms.add([]MetricMetadata{
+		{
+			MetricFamilyName: "foo",
+			Type:             "counter",
+			Help:             "foo help",
+		},
+		{
+			MetricFamilyName: "bar",
+			Type:             "gauge",
+			Help:             "bar help",
+			Unit:             "seconds",
+		},
+		{
+			// Metadata without metric family name must be ignored.
+			Type: "gauge",
+		},
+	}, 10)
+	ms.add([]MetricMetadata{
+		{
+			MetricFamilyName: "foo",
+			Type:             "counter",
+			Help:             "new foo help",
+		},
+		{
+			// Metadata for existing entry must update its last seen timestamp.
+			MetricFamilyName: "bar",
+			Type:             "gauge",
+			Help:             "bar help",
+			Unit:             "seconds",
+		},
+		{
+			// New entries must be dropped when the storage is full.
+			MetricFamilyName: "baz",
+			Type:             "gauge",
+		},
+	}, 20)
+	f(ms, "", 0, 0, []string{
+		`bar gauge "bar help" seconds 20`,
+		`foo counter "new foo help"  20`,
+		`foo counter "foo help"  10`,
+	})
+	f(ms, "foo", 0, 0, []string{
+		`foo counter "new foo help"  20`,
+		`foo counter "foo help"  10`,
+	})
+	f(ms, "foo", 0, 1, []string{
+		`foo counter "new foo help"  20`,
+	})
+	f(ms, "", 1, 0, []string{
+		`bar gauge "bar help" seconds 20`,
+	})
+	f(ms, "missing", 0, 0, nil)
+	if ms.droppedTotal != 1 {
+		t.Fatalf("unexpected droppedTotal; got %d; want %d", ms.droppedTotal, 1)
+	}
+
+	// Metadata must be preserved after marshaling and unmarshaling.
+	ms.mu.Lock()
+	data := ms.marshalLocked(nil)
+	ms.mu.Unlock()
+	ms2 := newMetricMetadataStorage(3)
+	if err := ms2.unmarshal(data); err != nil {
+		t.Fatalf("cannot unmarshal metric metadata: %s", err)
+	}
+	f(ms2, "", 0, 0, []string{
+		`bar gauge "bar help" seconds 20`,
+		`foo counter "new foo help"  20`,
+		`foo counter "foo help"  10`,
+	})
+
+	// Stale entries must be removed.
+	ms.removeStale(15)
+	f(ms, "", 0, 0, []string{
+		`bar gauge "bar help" seconds 20`,
+		`foo counter "new foo help"  20`,
+	})
+}
+
+func TestStorageMetricMetadataPersistence(t *testing.T) {
+	defer testRemoveAll(t)
+
+	mms := []MetricMetadata{{
+		MetricFamilyName: "http_requests_total",
+		Type:             "counter",
+		Help:             "The total number of requests",
+	}}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	s.AddMetricMetadata(mms)
+	s.MustClose()
+
+	s = MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	result := s.SearchMetricMetadata(nil, "http_requests_total", 0, 0)
+	if len(result) != 1 {
+		t.Fatalf("unexpected number of metadata entries after restart; got %d; want %d", len(result), 1)
+	}
+	mm := result[0]
+	if mm.Type != "counter" || mm.Help != "The total number of requests" || mm.LastSeenTimestamp == 0 {
+		t.Fatalf("unexpected metadata after restart: %+v", mm)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.MetricMetadataCount != 1 {
+		t.Fatalf("unexpected MetricMetadataCount; got %d; want %d", m.MetricMetadataCount, 1)
+	}
+}
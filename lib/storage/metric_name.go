@@ -622,7 +622,7 @@ func labelsToString(labels []prompb.Label) string {
 //
 // The results may be unmarshaled with MetricName.UnmarshalRaw.
 //
-// This function is for testing purposes. MarshalMetricNameRaw must be used
+// This function is for testing purposes and for CopySeries. MarshalMetricNameRaw must be used
 // in prod instead.
 func (mn *MetricName) marshalRaw(dst []byte) []byte {
 	dst = marshalBytesFast(dst, nil)
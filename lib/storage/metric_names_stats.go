@@ -0,0 +1,356 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fasttime"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+)
+
+// MetricNameStats contains usage stats for the given metric name.
+type MetricNameStats struct {
+	// MetricName is the metric name.
+	MetricName string
+
+	// QueryRequestsCount is the number of search requests, which selected series with the given metric name.
+	QueryRequestsCount uint64
+
+	// LastRequestTimestamp is unix timestamp in seconds for the last search request, which selected series with the given metric name.
+	//
+	// It is set to 0 if the metric name has been never queried since the stats collection start.
+	LastRequestTimestamp uint64
+}
+
+// MetricNamesStatsResult is the result returned by Storage.GetMetricNamesStats.
+type MetricNamesStatsResult struct {
+	// CollectedSinceTimestamp is unix timestamp in seconds when the stats collection has been started.
+	CollectedSinceTimestamp uint64
+
+	// TotalRecords is the total number of tracked metric names.
+	TotalRecords uint64
+
+	// MaxRecords is the maximum number of tracked metric names.
+	MaxRecords uint64
+
+	// Records contains stats for the matching metric names.
+	Records []MetricNameStats
+}
+
+// SetMaxMetricNamesStats sets the maximum number of metric names to track usage stats for.
+//
+// Metric names usage stats isn't tracked if maxEntries is set to 0.
+//
+// This function must be called before opening the storage.
+func SetMaxMetricNamesStats(maxEntries int) {
+	maxMetricNamesStats = maxEntries
+}
+
+var maxMetricNamesStats = 0
+
+// metricNameStatsEntry contains usage stats for a single metric name.
+type metricNameStatsEntry struct {
+	queryRequestsCount   atomic.Uint64
+	lastRequestTimestamp atomic.Uint64
+}
+
+// metricNamesStatsTracker tracks the number of search requests and the last search request time per each metric name.
+//
+// Metric names are registered during data ingestion when per-day index entries are created for the series,
+// so metric names without search requests are tracked too. New metric names are dropped when the tracker is full.
+// The contents of the tracker is persisted to disk periodically and on the storage close.
+type metricNamesStatsTracker struct {
+	// maxEntries is the maximum number of entries in the tracker.
+	maxEntries int
+
+	mu sync.RWMutex
+
+	// entries contains usage stats per each metric name.
+	entries map[string]*metricNameStatsEntry
+
+	// collectedSinceTimestamp is unix timestamp in seconds when the stats collection has been started.
+	collectedSinceTimestamp uint64
+
+	// generation is incremented on every change of entries.
+	generation atomic.Uint64
+
+	// savedGeneration is the generation of entries persisted to disk.
+	savedGeneration uint64
+
+	droppedTotal atomic.Uint64
+}
+
+func newMetricNamesStatsTracker(maxEntries int) *metricNamesStatsTracker {
+	return &metricNamesStatsTracker{
+		maxEntries:              maxEntries,
+		entries:                 make(map[string]*metricNameStatsEntry),
+		collectedSinceTimestamp: fasttime.UnixTimestamp(),
+	}
+}
+
+func (mt *metricNamesStatsTracker) isEnabled() bool {
+	return mt != nil && mt.maxEntries > 0
+}
+
+// registerIngestedMetricName registers the given metricName in mt.
+func (mt *metricNamesStatsTracker) registerIngestedMetricName(metricName []byte) {
+	if !mt.isEnabled() {
+		return
+	}
+	mt.getOrCreateEntry(metricName)
+}
+
+// registerQueryRequest registers a search request for series with the given metricName at the given timestamp in unix seconds.
+func (mt *metricNamesStatsTracker) registerQueryRequest(metricName []byte, timestamp uint64) {
+	if !mt.isEnabled() {
+		return
+	}
+	e := mt.getOrCreateEntry(metricName)
+	if e == nil {
+		return
+	}
+	e.queryRequestsCount.Add(1)
+	if e.lastRequestTimestamp.Load() != timestamp {
+		e.lastRequestTimestamp.Store(timestamp)
+	}
+	mt.generation.Add(1)
+}
+
+func (mt *metricNamesStatsTracker) getOrCreateEntry(metricName []byte) *metricNameStatsEntry {
+	mt.mu.RLock()
+	e := mt.entries[string(metricName)]
+	mt.mu.RUnlock()
+	if e != nil {
+		return e
+	}
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+	if e := mt.entries[string(metricName)]; e != nil {
+		return e
+	}
+	if len(mt.entries) >= mt.maxEntries {
+		mt.droppedTotal.Add(1)
+		return nil
+	}
+	e = &metricNameStatsEntry{}
+	mt.entries[string(metricName)] = e
+	mt.generation.Add(1)
+	return e
+}
+
+// reset removes all the tracked stats from mt and restarts the stats collection.
+func (mt *metricNamesStatsTracker) reset() {
+	mt.mu.Lock()
+	mt.entries = make(map[string]*metricNameStatsEntry)
+	mt.collectedSinceTimestamp = fasttime.UnixTimestamp()
+	mt.mu.Unlock()
+	mt.generation.Add(1)
+}
+
+// getStats returns stats for metric names containing matchPattern with up to le query requests.
+//
+// All the metric names are returned if matchPattern is empty. The number of query requests isn't limited if le is negative.
+// The number of returned records is limited by limit. Zero limit means no limit.
+//
+// The returned records are sorted by the number of query requests in ascending order, so unused metric names are returned first.
+func (mt *metricNamesStatsTracker) getStats(limit, le int, matchPattern string) *MetricNamesStatsResult {
+	result := &MetricNamesStatsResult{
+		MaxRecords: uint64(mt.maxEntries),
+	}
+	mt.mu.RLock()
+	result.CollectedSinceTimestamp = mt.collectedSinceTimestamp
+	result.TotalRecords = uint64(len(mt.entries))
+	for metricName, e := range mt.entries {
+		if matchPattern != "" && !strings.Contains(metricName, matchPattern) {
+			continue
+		}
+		queryRequestsCount := e.queryRequestsCount.Load()
+		if le >= 0 && queryRequestsCount > uint64(le) {
+			continue
+		}
+		result.Records = append(result.Records, MetricNameStats{
+			MetricName:           metricName,
+			QueryRequestsCount:   queryRequestsCount,
+			LastRequestTimestamp: e.lastRequestTimestamp.Load(),
+		})
+	}
+	mt.mu.RUnlock()
+
+	records := result.Records
+	sort.Slice(records, func(i, j int) bool {
+		a, b := &records[i], &records[j]
+		if a.QueryRequestsCount != b.QueryRequestsCount {
+			return a.QueryRequestsCount < b.QueryRequestsCount
+		}
+		return a.MetricName < b.MetricName
+	})
+	if limit > 0 && len(records) > limit {
+		result.Records = records[:limit]
+	}
+	return result
+}
+
+func (mt *metricNamesStatsTracker) updateMetrics(m *Metrics) {
+	mt.mu.RLock()
+	m.MetricNamesStatsCount += uint64(len(mt.entries))
+	mt.mu.RUnlock()
+	m.MetricNamesStatsMaxCount += uint64(mt.maxEntries)
+	m.MetricNamesStatsDroppedTotal += mt.droppedTotal.Load()
+}
+
+// marshal appends marshaled mt to dst and returns the result.
+func (mt *metricNamesStatsTracker) marshal(dst []byte) []byte {
+	mt.mu.RLock()
+	defer mt.mu.RUnlock()
+
+	dst = encoding.MarshalUint64(dst, mt.collectedSinceTimestamp)
+	dst = encoding.MarshalVarUint64(dst, uint64(len(mt.entries)))
+	for metricName, e := range mt.entries {
+		dst = encoding.MarshalBytes(dst, []byte(metricName))
+		dst = encoding.MarshalVarUint64(dst, e.queryRequestsCount.Load())
+		dst = encoding.MarshalUint64(dst, e.lastRequestTimestamp.Load())
+	}
+	return dst
+}
+
+// unmarshal adds entries from src to mt.
+func (mt *metricNamesStatsTracker) unmarshal(src []byte) error {
+	if len(src) < 8 {
+		return fmt.Errorf("cannot unmarshal stats collection start timestamp; got %d bytes; want at least 8 bytes", len(src))
+	}
+	collectedSinceTimestamp := encoding.UnmarshalUint64(src)
+	src = src[8:]
+	entriesLen, nSize := encoding.UnmarshalVarUint64(src)
+	if nSize <= 0 {
+		return fmt.Errorf("cannot unmarshal the number of metric names")
+	}
+	src = src[nSize:]
+
+	mt.mu.Lock()
+	defer mt.mu.Unlock()
+
+	mt.collectedSinceTimestamp = collectedSinceTimestamp
+	for i := uint64(0); i < entriesLen; i++ {
+		metricName, nSize := encoding.UnmarshalBytes(src)
+		if nSize <= 0 {
+			return fmt.Errorf("cannot unmarshal metric name #%d", i)
+		}
+		src = src[nSize:]
+		queryRequestsCount, nSize := encoding.UnmarshalVarUint64(src)
+		if nSize <= 0 {
+			return fmt.Errorf("cannot unmarshal query requests count for metric name #%d", i)
+		}
+		src = src[nSize:]
+		if len(src) < 8 {
+			return fmt.Errorf("cannot unmarshal last request timestamp for metric name #%d; got %d bytes; want at least 8 bytes", i, len(src))
+		}
+		lastRequestTimestamp := encoding.UnmarshalUint64(src)
+		src = src[8:]
+		if len(mt.entries) >= mt.maxEntries {
+			// The limit on the number of tracked metric names has been decreased since the last save.
+			continue
+		}
+		e := &metricNameStatsEntry{}
+		e.queryRequestsCount.Store(queryRequestsCount)
+		e.lastRequestTimestamp.Store(lastRequestTimestamp)
+		mt.entries[string(metricName)] = e
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling metric names stats; len(tail)=%d", len(src))
+	}
+	return nil
+}
+
+const metricNamesStatsFilename = "metric_names_stats.bin"
+
+func mustLoadMetricNamesStatsTracker(path string, maxEntries int) *metricNamesStatsTracker {
+	mt := newMetricNamesStatsTracker(maxEntries)
+	filePath := filepath.Join(path, metricNamesStatsFilename)
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return mt
+		}
+		logger.Panicf("FATAL: cannot read metric names stats: %s", err)
+	}
+	if maxEntries <= 0 {
+		return mt
+	}
+	if err := mt.unmarshal(data); err != nil {
+		logger.Errorf("discarding metric names stats at %q, since it cannot be unmarshaled: %s", filePath, err)
+		return newMetricNamesStatsTracker(maxEntries)
+	}
+	mt.savedGeneration = mt.generation.Load()
+	return mt
+}
+
+// mustSave persists mt to the given path if it has been changed since the last save.
+func (mt *metricNamesStatsTracker) mustSave(path string) {
+	if !mt.isEnabled() {
+		return
+	}
+	generation := mt.generation.Load()
+	if generation == mt.savedGeneration {
+		return
+	}
+	data := mt.marshal(nil)
+	fs.MustMkdirIfNotExist(path)
+	fs.MustWriteAtomic(filepath.Join(path, metricNamesStatsFilename), data, true)
+	mt.savedGeneration = generation
+}
+
+// GetMetricNamesStats returns usage stats for metric names containing matchPattern with up to le query requests.
+//
+// All the metric names are returned if matchPattern is empty. The number of query requests isn't limited if le is negative.
+// The number of returned records is limited by limit. Zero limit means no limit.
+// See SetMaxMetricNamesStats.
+func (s *Storage) GetMetricNamesStats(qt *querytracer.Tracer, limit, le int, matchPattern string) *MetricNamesStatsResult {
+	qt = qt.NewChild("get metric names stats: limit=%d, le=%d, matchPattern=%q", limit, le, matchPattern)
+	defer qt.Done()
+	result := s.metricNamesStats.getStats(limit, le, matchPattern)
+	qt.Printf("found %d metric names", len(result.Records))
+	return result
+}
+
+// ResetMetricNamesStats removes all the collected metric names usage stats and restarts the stats collection.
+func (s *Storage) ResetMetricNamesStats(qt *querytracer.Tracer) {
+	qt.Printf("reset metric names stats")
+	s.metricNamesStats.reset()
+}
+
+func (s *Storage) startMetricNamesStatsSaver() {
+	if !s.metricNamesStats.isEnabled() {
+		return
+	}
+	s.metricNamesStatsSaverWG.Add(1)
+	go func() {
+		s.metricNamesStatsSaver()
+		s.metricNamesStatsSaverWG.Done()
+	}()
+}
+
+func (s *Storage) metricNamesStatsSaver() {
+	path := filepath.Join(s.path, metricNamesStatsDirname)
+	d := timeutil.AddJitterToDuration(time.Minute)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.metricNamesStats.mustSave(path)
+		}
+	}
+}
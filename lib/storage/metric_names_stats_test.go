@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMetricNamesStatsTrackerGetStats(t *testing.T) {
+	f := func(mt *metricNamesStatsTracker, limit, le int, matchPattern string, resultExpected []string) {
+		t.Helper()
+		var result []string
+		for _, r := range mt.getStats(limit, le, matchPattern).Records {
+			result = append(result, fmt.Sprintf("%s %d %d", r.MetricName, r.QueryRequestsCount, r.LastRequestTimestamp))
+		}
+		if !reflect.DeepEqual(result, resultExpected) {
+			t.Fatalf("unexpected result;\ngot\n%q\nwant\n%q", result, resultExpected)
+		}
+	}
+
+	mt := newMetricNamesStatsTracker(3)
+	mt.registerIngestedMetricName([]byte("foo"))
+	mt.registerIngestedMetricName([]byte("bar"))
+	mt.registerQueryRequest([]byte("bar"), 10)
+	mt.registerQueryRequest([]byte("bar"), 20)
+	// Metric names registered at query time must be tracked too.
+	mt.registerQueryRequest([]byte("foo_total"), 15)
+	// New metric names must be dropped when the tracker is full.
+	mt.registerIngestedMetricName([]byte("baz"))
+	mt.registerQueryRequest([]byte("baz"), 20)
+
+	f(mt, 0, -1, "", []string{
+		"foo 0 0",
+		"foo_total 1 15",
+		"bar 2 20",
+	})
+	f(mt, 2, -1, "", []string{
+		"foo 0 0",
+		"foo_total 1 15",
+	})
+	f(mt, 0, 0, "", []string{
+		"foo 0 0",
+	})
+	f(mt, 0, 1, "foo", []string{
+		"foo 0 0",
+		"foo_total 1 15",
+	})
+	f(mt, 0, -1, "missing", nil)
+	if n := mt.droppedTotal.Load(); n != 2 {
+		t.Fatalf("unexpected droppedTotal; got %d; want %d", n, 2)
+	}
+
+	// Stats must be preserved after marshaling and unmarshaling.
+	data := mt.marshal(nil)
+	mt2 := newMetricNamesStatsTracker(3)
+	if err := mt2.unmarshal(data); err != nil {
+		t.Fatalf("cannot unmarshal metric names stats: %s", err)
+	}
+	if mt2.collectedSinceTimestamp != mt.collectedSinceTimestamp {
+		t.Fatalf("unexpected collectedSinceTimestamp; got %d; want %d", mt2.collectedSinceTimestamp, mt.collectedSinceTimestamp)
+	}
+	f(mt2, 0, -1, "", []string{
+		"foo 0 0",
+		"foo_total 1 15",
+		"bar 2 20",
+	})
+
+	// Reset must remove all the stats.
+	mt.reset()
+	f(mt, 0, -1, "", nil)
+
+	// Disabled tracker mustn't track anything.
+	mt = newMetricNamesStatsTracker(0)
+	mt.registerIngestedMetricName([]byte("foo"))
+	mt.registerQueryRequest([]byte("foo"), 10)
+	f(mt, 0, -1, "", nil)
+}
+
+func TestStorageMetricNamesStats(t *testing.T) {
+	defer testRemoveAll(t)
+
+	SetMaxMetricNamesStats(100)
+	defer SetMaxMetricNamesStats(0)
+
+	timestamp := time.Now().Add(-time.Hour).UnixMilli()
+	var mrs []MetricRow
+	for _, metricName := range []string{"used_metric", "unused_metric"} {
+		for i := 0; i < 10; i++ {
+			var mn MetricName
+			mn.MetricGroup = []byte(metricName)
+			mn.AddTag("instance", fmt.Sprintf("host-%d", i))
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: mn.marshalRaw(nil),
+				Timestamp:     timestamp,
+				Value:         float64(i),
+			})
+		}
+	}
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.DebugFlush()
+
+	tfs := NewTagFilters()
+	if err := tfs.Add(nil, []byte("used_metric"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tr := TimeRange{
+		MinTimestamp: timestamp - 1000,
+		MaxTimestamp: timestamp + 1000,
+	}
+	// Query used_metric twice with metric names stats tracking and once without it.
+	// The search without tracking must be ignored in the stats.
+	for i := 0; i < 3; i++ {
+		var sr Search
+		sr.Init(nil, s, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		if i < 2 {
+			sr.TrackMetricNamesStats()
+		}
+		blocks := 0
+		for sr.NextMetricBlock() {
+			blocks++
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error in search: %s", err)
+		}
+		sr.MustClose()
+		if blocks != 10 {
+			t.Fatalf("unexpected number of found blocks; got %d; want %d", blocks, 10)
+		}
+	}
+
+	checkStats := func() {
+		t.Helper()
+		result := s.GetMetricNamesStats(nil, 0, -1, "")
+		if result.TotalRecords != 2 {
+			t.Fatalf("unexpected TotalRecords; got %d; want %d", result.TotalRecords, 2)
+		}
+		if len(result.Records) != 2 {
+			t.Fatalf("unexpected number of records; got %d; want %d", len(result.Records), 2)
+		}
+		r := result.Records[0]
+		if r.MetricName != "unused_metric" || r.QueryRequestsCount != 0 || r.LastRequestTimestamp != 0 {
+			t.Fatalf("unexpected stats for unused metric: %+v", r)
+		}
+		r = result.Records[1]
+		if r.MetricName != "used_metric" || r.QueryRequestsCount != 2 || r.LastRequestTimestamp == 0 {
+			t.Fatalf("unexpected stats for used metric: %+v", r)
+		}
+	}
+	checkStats()
+
+	// The stats must survive storage restart.
+	s.MustClose()
+	s = MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	checkStats()
+
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if m.MetricNamesStatsCount != 2 {
+		t.Fatalf("unexpected MetricNamesStatsCount; got %d; want %d", m.MetricNamesStatsCount, 2)
+	}
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+)
+
+// checkPart verifies the integrity of the file-based part at the given path.
+//
+// It verifies that metaindex, index and data blocks can be decoded, that their offsets and sizes
+// match the sizes of part files, that blocks are sorted by TSID and that block timestamps
+// are in the time ranges registered in the part metadata, metaindex and block headers.
+func checkPart(path string) error {
+	var ph partHeader
+	if err := ph.readMetadata(path); err != nil {
+		return err
+	}
+
+	var sizes [3]uint64
+	for i, filename := range []string{indexFilename, timestampsFilename, valuesFilename} {
+		fi, err := os.Stat(filepath.Join(path, filename))
+		if err != nil {
+			return fmt.Errorf("cannot access part file: %w", err)
+		}
+		sizes[i] = uint64(fi.Size())
+	}
+	indexSize, timestampsSize, valuesSize := sizes[0], sizes[1], sizes[2]
+
+	metaindexPath := filepath.Join(path, metaindexFilename)
+	metaindexData, err := os.ReadFile(metaindexPath)
+	if err != nil {
+		return fmt.Errorf("cannot read metaindex: %w", err)
+	}
+	mrs, err := unmarshalMetaindexRows(nil, bytes.NewReader(metaindexData))
+	if err != nil {
+		return fmt.Errorf("cannot unmarshal metaindex rows from %q: %w", metaindexPath, err)
+	}
+
+	indexPath := filepath.Join(path, indexFilename)
+	indexFile, err := os.Open(indexPath)
+	if err != nil {
+		return fmt.Errorf("cannot open index file: %w", err)
+	}
+	defer indexFile.Close()
+
+	// Verify index blocks and block headers, so the data blocks could be safely read afterwards.
+	var indexOffset, timestampsEnd, valuesOffset, blocksCount, rowsCount uint64
+	var compressedIndexData, indexData []byte
+	var bhs []blockHeader
+	var tsidPrev TSID
+	for i := range mrs {
+		mr := &mrs[i]
+		if mr.IndexBlockOffset != indexOffset {
+			return fmt.Errorf("invalid IndexBlockOffset in metaindex row #%d; got %d; want %d", i, mr.IndexBlockOffset, indexOffset)
+		}
+		if mr.IndexBlockOffset+uint64(mr.IndexBlockSize) > indexSize {
+			return fmt.Errorf("index block #%d at offset %d with size %d exceeds %q size %d", i, mr.IndexBlockOffset, mr.IndexBlockSize, indexPath, indexSize)
+		}
+		if mr.MinTimestamp < ph.MinTimestamp || mr.MaxTimestamp > ph.MaxTimestamp {
+			return fmt.Errorf("time range [%d..%d] in metaindex row #%d is outside the part time range [%d..%d]",
+				mr.MinTimestamp, mr.MaxTimestamp, i, ph.MinTimestamp, ph.MaxTimestamp)
+		}
+		compressedIndexData = bytesutil.ResizeNoCopyMayOverallocate(compressedIndexData, int(mr.IndexBlockSize))
+		if _, err := indexFile.ReadAt(compressedIndexData, int64(mr.IndexBlockOffset)); err != nil {
+			return fmt.Errorf("cannot read index block #%d: %w", i, err)
+		}
+		indexData, err = encoding.DecompressZSTD(indexData[:0], compressedIndexData)
+		if err != nil {
+			return fmt.Errorf("cannot decompress index block #%d: %w", i, err)
+		}
+		bhs, err = unmarshalBlockHeaders(bhs[:0], indexData, int(mr.BlockHeadersCount))
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal block headers in the index block #%d: %w", i, err)
+		}
+		if bhs[0].TSID != mr.TSID {
+			return fmt.Errorf("unexpected TSID in metaindex row #%d; got %+v; want %+v", i, &mr.TSID, &bhs[0].TSID)
+		}
+		for j := range bhs {
+			bh := &bhs[j]
+			if bh.TSID.Less(&tsidPrev) {
+				return fmt.Errorf("block headers aren't sorted by TSID; TSID=%+v in block header #%d of the index block #%d is smaller than the previous TSID=%+v",
+					&bh.TSID, j, i, &tsidPrev)
+			}
+			tsidPrev = bh.TSID
+			if bh.MinTimestamp > bh.MaxTimestamp || bh.MinTimestamp < mr.MinTimestamp || bh.MaxTimestamp > mr.MaxTimestamp {
+				return fmt.Errorf("time range [%d..%d] in block header #%d of the index block #%d is outside the index block time range [%d..%d]",
+					bh.MinTimestamp, bh.MaxTimestamp, j, i, mr.MinTimestamp, mr.MaxTimestamp)
+			}
+			// Timestamps blocks may be shared among adjacent blocks, so verify only their bounds.
+			if bh.TimestampsBlockOffset+uint64(bh.TimestampsBlockSize) > timestampsSize {
+				return fmt.Errorf("timestamps block in block header #%d of the index block #%d at offset %d with size %d exceeds %q size %d",
+					j, i, bh.TimestampsBlockOffset, bh.TimestampsBlockSize, timestampsFilename, timestampsSize)
+			}
+			if end := bh.TimestampsBlockOffset + uint64(bh.TimestampsBlockSize); end > timestampsEnd {
+				timestampsEnd = end
+			}
+			if bh.ValuesBlockOffset != valuesOffset {
+				return fmt.Errorf("invalid ValuesBlockOffset in block header #%d of the index block #%d; got %d; want %d", j, i, bh.ValuesBlockOffset, valuesOffset)
+			}
+			valuesOffset += uint64(bh.ValuesBlockSize)
+			rowsCount += uint64(bh.RowsCount)
+			blocksCount++
+		}
+		indexOffset += uint64(mr.IndexBlockSize)
+	}
+	if indexOffset != indexSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; index blocks occupy %d bytes", indexPath, indexSize, indexOffset)
+	}
+	if timestampsEnd != timestampsSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; block headers refer to %d bytes", filepath.Join(path, timestampsFilename), timestampsSize, timestampsEnd)
+	}
+	if valuesOffset != valuesSize {
+		return fmt.Errorf("unexpected size of %q; got %d bytes; block headers refer to %d bytes", filepath.Join(path, valuesFilename), valuesSize, valuesOffset)
+	}
+	if blocksCount != ph.BlocksCount {
+		return fmt.Errorf("unexpected number of blocks; got %d; want %d", blocksCount, ph.BlocksCount)
+	}
+	if rowsCount != ph.RowsCount {
+		return fmt.Errorf("unexpected number of rows; got %d; want %d", rowsCount, ph.RowsCount)
+	}
+
+	// Verify data blocks.
+	bsr := getBlockStreamReader()
+	defer putBlockStreamReader(bsr)
+	bsr.MustInitFromFilePart(path)
+	for bsr.NextBlock() {
+		b := &bsr.Block
+		if err := b.UnmarshalData(); err != nil {
+			return fmt.Errorf("cannot unmarshal data block #%d for TSID=%+v: %w", bsr.blocksCount, &b.bh.TSID, err)
+		}
+		if err := checkTimestampsBounds(b.timestamps, b.bh.MinTimestamp, b.bh.MaxTimestamp); err != nil {
+			return fmt.Errorf("invalid timestamps in data block #%d for TSID=%+v: %w", bsr.blocksCount, &b.bh.TSID, err)
+		}
+	}
+	if err := bsr.Error(); err != nil {
+		return fmt.Errorf("cannot read data blocks: %w", err)
+	}
+	return nil
+}
@@ -560,6 +560,11 @@ func (pt *partition) flushRowssToInmemoryParts(rowss [][]rawRow) {
 		return
 	}
 
+	// All the rowss were added before obtaining the tombstones generation,
+	// so the tombstones registered at this point must be applied to the created parts.
+	// Tombstones registered later must be skipped for the created parts, since they may contain backfilled samples.
+	tombstonesGeneration := pt.s.getTombstones().getGeneration()
+
 	// Convert rowss into in-memory parts.
 	var pwsLock sync.Mutex
 	pws := make([]*partWrapper, 0, len(rowss))
@@ -573,7 +578,7 @@ func (pt *partition) flushRowssToInmemoryParts(rowss [][]rawRow) {
 				wg.Done()
 			}()
 
-			pw := pt.createInmemoryPart(rowsChunk)
+			pw := pt.createInmemoryPart(rowsChunk, tombstonesGeneration)
 			if pw != nil {
 				pwsLock.Lock()
 				pws = append(pws, pw)
@@ -817,12 +822,13 @@ func (pt *partition) mustMergeInmemoryPartsFinal(pws []*partWrapper) *partWrappe
 	return newPartWrapperFromInmemoryPart(mpDst, flushToDiskDeadline)
 }
 
-func (pt *partition) createInmemoryPart(rows []rawRow) *partWrapper {
+func (pt *partition) createInmemoryPart(rows []rawRow, tombstonesGeneration uint64) *partWrapper {
 	if len(rows) == 0 {
 		return nil
 	}
 	mp := getInmemoryPart()
 	mp.InitFromRows(rows)
+	mp.ph.TombstonesGeneration = tombstonesGeneration
 
 	// Make sure the part may be added.
 	if mp.ph.MinTimestamp > mp.ph.MaxTimestamp {
@@ -1344,7 +1350,7 @@ func (pt *partition) runFinalDedup(stopCh <-chan struct{}) error {
 }
 
 func (pt *partition) isFinalDedupNeeded() bool {
-	dedupInterval := GetDedupInterval()
+	dedupInterval := pt.getDedupInterval()
 
 	pws := pt.GetParts(nil, false)
 	minDedupInterval := getMinDedupInterval(pws)
@@ -1353,6 +1359,40 @@ func (pt *partition) isFinalDedupNeeded() bool {
 	return dedupInterval > minDedupInterval
 }
 
+// getDedupInterval returns the interval in milliseconds, which is applied to all the samples in pt during merges.
+//
+// The returned interval takes into account both the deduplication interval and downsampling periods.
+func (pt *partition) getDedupInterval() int64 {
+	dedupInterval := GetDedupInterval()
+	if d := GetDownsamplingInterval(pt.tr.MaxTimestamp, getCurrentTimestampForDownsampling()); d > dedupInterval {
+		dedupInterval = d
+	}
+	return dedupInterval
+}
+
+// hasPartsWithTombstonesGenerationLess returns true if pt contains parts with samples on the given tr,
+// which were created before the tombstone with the given generation.
+func (pt *partition) hasPartsWithTombstonesGenerationLess(tr TimeRange, generation uint64) bool {
+	if pt.tr.MinTimestamp > tr.MaxTimestamp || pt.tr.MaxTimestamp < tr.MinTimestamp {
+		// Fast path - the partition doesn't contain samples on the given tr.
+		return false
+	}
+
+	pws := pt.GetParts(nil, true)
+	defer pt.PutParts(pws)
+
+	for _, pw := range pws {
+		ph := &pw.p.ph
+		if ph.MinTimestamp > tr.MaxTimestamp || ph.MaxTimestamp < tr.MinTimestamp {
+			continue
+		}
+		if ph.TombstonesGeneration < generation {
+			return true
+		}
+	}
+	return false
+}
+
 func getMinDedupInterval(pws []*partWrapper) int64 {
 	if len(pws) == 0 {
 		return 0
@@ -1394,7 +1434,7 @@ func (pt *partition) mergeParts(pws []*partWrapper, stopCh <-chan struct{}, isFi
 	mergeIdx := pt.nextMergeIdx()
 	dstPartPath := pt.getDstPartPath(dstPartType, mergeIdx)
 
-	if !isDedupEnabled() && isFinal && len(pws) == 1 && pws[0].mp != nil {
+	if !isDedupEnabled() && !isDownsamplingEnabled() && len(GetRetentionFilters()) == 0 && isFinal && len(pws) == 1 && pws[0].mp != nil {
 		// Fast path: flush a single in-memory part to disk.
 		mp := pws[0].mp
 		mp.MustStoreToDisk(dstPartPath)
@@ -1577,7 +1617,7 @@ func (pt *partition) mergePartsInternal(dstPartPath string, bsw *blockStreamWrit
 		return nil, fmt.Errorf("cannot merge %d parts to %s: %w", len(bsrs), dstPartPath, err)
 	}
 	if dstPartPath != "" {
-		ph.MinDedupInterval = GetDedupInterval()
+		ph.MinDedupInterval = pt.getDedupInterval()
 		ph.MustWriteMetadata(dstPartPath)
 	}
 	return &ph, nil
@@ -24,6 +24,12 @@ type partitionSearch struct {
 	psPool []partSearch
 	psHeap partSearchHeap
 
+	// tss contains tombstones snapshot obtained during Init call.
+	tss *tombstones
+
+	// deletedTimeRanges is a buffer for time ranges with deleted samples for the current block.
+	deletedTimeRanges []TimeRange
+
 	err error
 
 	nextBlockNoop bool
@@ -49,6 +55,9 @@ func (pts *partitionSearch) reset() {
 	}
 	pts.psHeap = pts.psHeap[:0]
 
+	pts.tss = nil
+	pts.deletedTimeRanges = pts.deletedTimeRanges[:0]
+
 	pts.err = nil
 	pts.nextBlockNoop = false
 	pts.needClosing = false
@@ -82,6 +91,9 @@ func (pts *partitionSearch) Init(pt *partition, tsids []TSID, tr TimeRange) {
 	}
 
 	pts.pws = pt.GetParts(pts.pws[:0], true)
+	if tss := pt.s.getTombstones(); len(tss.getItems()) > 0 {
+		pts.tss = tss
+	}
 
 	// Initialize psPool.
 	pts.psPool = slicesutil.SetLength(pts.psPool, len(pts.pws))
@@ -120,18 +132,45 @@ func (pts *partitionSearch) NextBlock() bool {
 	if pts.err != nil {
 		return false
 	}
-	if pts.nextBlockNoop {
-		pts.nextBlockNoop = false
-		return true
+	for {
+		if pts.nextBlockNoop {
+			pts.nextBlockNoop = false
+		} else {
+			pts.err = pts.nextBlock()
+			if pts.err != nil {
+				if pts.err != io.EOF {
+					pts.err = fmt.Errorf("cannot obtain the next block to search in the partition: %w", pts.err)
+				}
+				return false
+			}
+		}
+		if pts.applyTombstones() {
+			return true
+		}
+		// Skip the block, since all its samples are deleted via tombstones.
 	}
+}
 
-	pts.err = pts.nextBlock()
-	if pts.err != nil {
-		if pts.err != io.EOF {
-			pts.err = fmt.Errorf("cannot obtain the next block to search in the partition: %w", pts.err)
-		}
+// applyTombstones attaches tombstones to pts.BlockRef if it contains samples deleted via tombstones.
+//
+// It returns false if all the samples in pts.BlockRef are deleted.
+// The block isn't read here, so it may become empty after reading it via BlockRef.MustReadBlock
+// if it is covered by multiple tombstones.
+func (pts *partitionSearch) applyTombstones() bool {
+	if pts.tss == nil {
+		// Fast path - there are no tombstones.
+		return true
+	}
+	br := pts.BlockRef
+	bh := &br.bh
+	pts.deletedTimeRanges = pts.tss.appendDeletedTimeRanges(pts.deletedTimeRanges[:0], bh.TSID.MetricID, br.p.ph.TombstonesGeneration, bh.MinTimestamp, bh.MaxTimestamp)
+	if len(pts.deletedTimeRanges) == 0 {
+		return true
+	}
+	if isCoveredByTimeRanges(pts.deletedTimeRanges, bh.MinTimestamp, bh.MaxTimestamp) {
 		return false
 	}
+	br.tss = pts.tss
 	return true
 }
 
@@ -0,0 +1,310 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/VictoriaMetrics/metricsql"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/promutils"
+)
+
+// RetentionFilter defines the retention for series matching the given filter.
+type RetentionFilter struct {
+	// Filter is the original series filter, e.g. `{env="dev"}`.
+	Filter string
+
+	// Retention is the retention in milliseconds for series matching Filter.
+	Retention int64
+
+	// tfss contains or-delimited tag filters obtained from Filter.
+	tfss []*TagFilters
+}
+
+// String returns string representation of rf in the form `filter:retention`.
+func (rf *RetentionFilter) String() string {
+	return fmt.Sprintf("%s:%dms", rf.Filter, rf.Retention)
+}
+
+// ParseRetentionFilter parses retention filter from s.
+//
+// s must have the form `filter:retention`, e.g. `{env="dev"}:7d`.
+func ParseRetentionFilter(s string) (*RetentionFilter, error) {
+	n := strings.LastIndexByte(s, ':')
+	if n < 0 {
+		return nil, fmt.Errorf("missing `:` in retention filter %q; it must have the form `filter:retention`, e.g. `{env=\"dev\"}:7d`", s)
+	}
+	filter := strings.TrimSpace(s[:n])
+	retentionStr := strings.TrimSpace(s[n+1:])
+	retention, err := promutils.ParseDuration(retentionStr)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse retention in retention filter %q: %w", s, err)
+	}
+	if retention <= 0 {
+		return nil, fmt.Errorf("retention in retention filter %q must be positive", s)
+	}
+	tfss, err := ParseSeriesFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse series filter in retention filter %q: %w", s, err)
+	}
+	rf := &RetentionFilter{
+		Filter:    filter,
+		Retention: retention.Milliseconds(),
+		tfss:      tfss,
+	}
+	return rf, nil
+}
+
+// ParseSeriesFilter parses series filter s such as `{env="dev"}` or `{env="dev" or team="foo"}` into tag filters.
+//
+// The returned tag filters are joined with `or`.
+func ParseSeriesFilter(s string) ([]*TagFilters, error) {
+	expr, err := metricsql.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	me, ok := expr.(*metricsql.MetricExpr)
+	if !ok {
+		return nil, fmt.Errorf("expecting series filter; got %q", expr.AppendString(nil))
+	}
+	if len(me.LabelFilterss) == 0 {
+		return nil, fmt.Errorf("series filter cannot be empty")
+	}
+	tfss := make([]*TagFilters, 0, len(me.LabelFilterss))
+	for _, lfs := range me.LabelFilterss {
+		tfs := NewTagFilters()
+		for _, lf := range lfs {
+			var key []byte
+			if lf.Label != "__name__" {
+				key = []byte(lf.Label)
+			}
+			if err := tfs.Add(key, []byte(lf.Value), lf.IsNegative, lf.IsRegexp); err != nil {
+				return nil, fmt.Errorf("cannot parse label filter %s: %w", lf.AppendString(nil), err)
+			}
+		}
+		tfss = append(tfss, tfs)
+	}
+	return tfss, nil
+}
+
+// SetRetentionFilters sets retention filters, which are applied to samples during searches and background merges.
+//
+// Samples for series matching the given filters become invisible to search when they become older than the filter retention.
+// Such samples are physically removed during background merges.
+// If series matches multiple filters, then the smallest retention is applied.
+//
+// This function must be called before initializing the storage.
+func SetRetentionFilters(rfs []*RetentionFilter) {
+	a := append([]*RetentionFilter{}, rfs...)
+	globalRetentionFilters.Store(&a)
+}
+
+// GetRetentionFilters returns retention filters set via SetRetentionFilters.
+func GetRetentionFilters() []*RetentionFilter {
+	p := globalRetentionFilters.Load()
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+var globalRetentionFilters atomic.Pointer[[]*RetentionFilter]
+
+// retentionFiltersMatcher returns retention deadlines for series during searches and background merges.
+//
+// It mustn't be used from concurrently running goroutines.
+type retentionFiltersMatcher struct {
+	s *Storage
+
+	// currentTimestamp is the timestamp in milliseconds used for calculating retention deadlines.
+	currentTimestamp int64
+
+	// retentionDeadline is the deadline for series, which do not match any retention filter.
+	retentionDeadline int64
+
+	// filters contains per-filter tag filters and deadlines.
+	filters []retentionFilterDeadline
+
+	// maxDeadline is the maximum deadline across filters.
+	//
+	// Blocks with MinTimestamp bigger or equal to maxDeadline cannot be affected by retention filters.
+	maxDeadline int64
+
+	prevMetricID uint64
+	prevDeadline int64
+
+	metricName []byte
+	mn         MetricName
+	kb         bytesutil.ByteBuffer
+}
+
+type retentionFilterDeadline struct {
+	// tfss contains copies of tag filters, since matchTagFilters may re-order them.
+	tfss     [][]*tagFilter
+	deadline int64
+}
+
+func (rfm *retentionFiltersMatcher) reset() {
+	rfm.s = nil
+	rfm.currentTimestamp = 0
+	rfm.retentionDeadline = 0
+	clear(rfm.filters)
+	rfm.filters = rfm.filters[:0]
+	rfm.maxDeadline = 0
+	rfm.prevMetricID = 0
+	rfm.prevDeadline = 0
+	rfm.metricName = rfm.metricName[:0]
+	rfm.mn.Reset()
+	rfm.kb.Reset()
+}
+
+func (rfm *retentionFiltersMatcher) init(s *Storage, retentionDeadline, currentTimestamp int64) {
+	rfm.reset()
+	rfm.s = s
+	rfm.currentTimestamp = currentTimestamp
+	rfm.retentionDeadline = retentionDeadline
+	rfm.maxDeadline = retentionDeadline
+	for _, rf := range GetRetentionFilters() {
+		deadline := currentTimestamp - rf.Retention
+		if deadline <= retentionDeadline {
+			// The filter retention exceeds the global retention, so it has no effect.
+			continue
+		}
+		tfss := make([][]*tagFilter, len(rf.tfss))
+		for i, tfs := range rf.tfss {
+			a := make([]*tagFilter, len(tfs.tfs))
+			for j := range tfs.tfs {
+				a[j] = &tfs.tfs[j]
+			}
+			tfss[i] = a
+		}
+		rfm.filters = append(rfm.filters, retentionFilterDeadline{
+			tfss:     tfss,
+			deadline: deadline,
+		})
+		if deadline > rfm.maxDeadline {
+			rfm.maxDeadline = deadline
+		}
+	}
+}
+
+// getTimestamp returns the timestamp used for calculating retention deadlines.
+//
+// It returns 0 if there are no retention filters, which may reduce the retention.
+func (rfm *retentionFiltersMatcher) getTimestamp() int64 {
+	if len(rfm.filters) == 0 {
+		return 0
+	}
+	return rfm.currentTimestamp
+}
+
+// getRetentionDeadline returns retention deadline for the block with the given bh.
+func (rfm *retentionFiltersMatcher) getRetentionDeadline(bh *blockHeader) int64 {
+	if len(rfm.filters) == 0 || bh.MinTimestamp >= rfm.maxDeadline {
+		// Fast path - the block cannot contain samples outside retention filters.
+		return rfm.retentionDeadline
+	}
+	metricID := bh.TSID.MetricID
+	if metricID == rfm.prevMetricID {
+		return rfm.prevDeadline
+	}
+	rfm.prevMetricID = metricID
+	rfm.prevDeadline = rfm.getRetentionDeadlineForMetricID(metricID)
+	return rfm.prevDeadline
+}
+
+func (rfm *retentionFiltersMatcher) getRetentionDeadlineForMetricID(metricID uint64) int64 {
+	deadline := rfm.retentionDeadline
+	var ok bool
+	rfm.metricName, ok = rfm.s.idb().searchMetricNameWithCache(rfm.metricName[:0], metricID)
+	if !ok {
+		// Cannot find metric name for the given metricID. Apply the global retention then.
+		return deadline
+	}
+	if err := rfm.mn.Unmarshal(rfm.metricName); err != nil {
+		logger.Panicf("FATAL: cannot unmarshal metricName %q for metricID=%d: %s", rfm.metricName, metricID, err)
+	}
+	for i := range rfm.filters {
+		f := &rfm.filters[i]
+		if f.deadline <= deadline {
+			// The filter cannot reduce the retention for the given series.
+			continue
+		}
+		if rfm.matchFilter(f) {
+			deadline = f.deadline
+		}
+	}
+	return deadline
+}
+
+func (rfm *retentionFiltersMatcher) matchFilter(f *retentionFilterDeadline) bool {
+	for _, tfs := range f.tfss {
+		ok, err := matchTagFilters(&rfm.mn, tfs, &rfm.kb)
+		if err != nil {
+			logger.Panicf("BUG: cannot match retention filter against metricName %s: %s", &rfm.mn, err)
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// retentionFiltersMergeInterval is the minimum interval in milliseconds between merges of the same part
+// for removing samples outside the retention filters.
+//
+// Samples outside the retention filters are invisible to search, so there is no need in merging parts more frequently.
+const retentionFiltersMergeInterval = 24 * 3600 * 1000
+
+// isRetentionFiltersMergeNeeded returns true if pt contains parts with samples,
+// which became outside the retention filters since the last merge of these parts.
+func (pt *partition) isRetentionFiltersMergeNeeded(currentTimestamp int64) bool {
+	rfs := GetRetentionFilters()
+	if len(rfs) == 0 {
+		return false
+	}
+	retentionDeadline := currentTimestamp - pt.s.retentionMsecs
+
+	pws := pt.GetParts(nil, false)
+	defer pt.PutParts(pws)
+
+	for _, pw := range pws {
+		ph := &pw.p.ph
+		if currentTimestamp-ph.RetentionFiltersTimestamp < retentionFiltersMergeInterval {
+			continue
+		}
+		for _, rf := range rfs {
+			deadline := currentTimestamp - rf.Retention
+			if deadline <= retentionDeadline {
+				// The filter retention exceeds the global retention, so it has no effect.
+				continue
+			}
+			prevDeadline := int64(math.MinInt64)
+			if ph.RetentionFiltersTimestamp > 0 {
+				prevDeadline = ph.RetentionFiltersTimestamp - rf.Retention
+			}
+			if ph.MinTimestamp < deadline && ph.MaxTimestamp >= prevDeadline {
+				// The part may contain samples on the [prevDeadline ... deadline) time range,
+				// which became outside the filter retention since the last merge.
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (pt *partition) runRetentionFiltersMerge(stopCh <-chan struct{}) error {
+	t := time.Now()
+	logger.Infof("start removing samples outside retention filters from partition (%s, %s)", pt.bigPartsPath, pt.smallPartsPath)
+	if err := pt.ForceMergeAllParts(stopCh); err != nil {
+		return fmt.Errorf("cannot remove samples outside retention filters from partition (%s, %s): %w", pt.bigPartsPath, pt.smallPartsPath, err)
+	}
+	logger.Infof("samples outside retention filters have been removed from partition (%s, %s) in %.3f seconds",
+		pt.bigPartsPath, pt.smallPartsPath, time.Since(t).Seconds())
+	return nil
+}
@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRetentionFilterSuccess(t *testing.T) {
+	f := func(s, filterExpected string, retentionExpected int64, tfssLenExpected int) {
+		t.Helper()
+		rf, err := ParseRetentionFilter(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if rf.Filter != filterExpected {
+			t.Fatalf("unexpected filter; got %q; want %q", rf.Filter, filterExpected)
+		}
+		if rf.Retention != retentionExpected {
+			t.Fatalf("unexpected retention; got %d; want %d", rf.Retention, retentionExpected)
+		}
+		if len(rf.tfss) != tfssLenExpected {
+			t.Fatalf("unexpected number of or-delimited tag filters; got %d; want %d", len(rf.tfss), tfssLenExpected)
+		}
+	}
+	f(`{env="dev"}:7d`, `{env="dev"}`, 7*24*3600*1000, 1)
+	f(`foo{env=~"dev|staging"} : 1h`, `foo{env=~"dev|staging"}`, 3600*1000, 1)
+	f(`{env="dev" or team="juniors"}:3d`, `{env="dev" or team="juniors"}`, 3*24*3600*1000, 2)
+}
+
+func TestParseRetentionFilterFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		_, err := ParseRetentionFilter(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error")
+		}
+	}
+
+	// missing retention
+	f(`{env="dev"}`)
+
+	// invalid retention
+	f(`{env="dev"}:foo`)
+
+	// zero retention
+	f(`{env="dev"}:0s`)
+
+	// invalid filter
+	f(`{env="dev":7d`)
+
+	// non-filter expression
+	f(`rate(foo):7d`)
+}
+
+func TestParseSeriesFilterSuccess(t *testing.T) {
+	f := func(s, resultExpected string) {
+		t.Helper()
+		tfss, err := ParseSeriesFilter(s)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var result string
+		for i, tfs := range tfss {
+			if i > 0 {
+				result += " or "
+			}
+			result += tfs.String()
+		}
+		if result != resultExpected {
+			t.Fatalf("unexpected result;\ngot\n%s\nwant\n%s", result, resultExpected)
+		}
+	}
+	f(`{team="foo"}`, `{team="foo"}`)
+	f(`up{team=~"foo|bar"}`, `{__name__="up",team=~"foo|bar"}`)
+	f(`{team="foo" or team="bar"}`, `{team="foo"} or {team="bar"}`)
+}
+
+func TestParseSeriesFilterFailure(t *testing.T) {
+	f := func(s string) {
+		t.Helper()
+		_, err := ParseSeriesFilter(s)
+		if err == nil {
+			t.Fatalf("expecting non-nil error for %q", s)
+		}
+	}
+
+	// empty filter
+	f(``)
+
+	// invalid filter
+	f(`{team="foo"`)
+
+	// non-filter expression
+	f(`rate(foo[5m])`)
+
+	// invalid regexp
+	f(`{team=~"("}`)
+}
+
+func TestStorageRetentionFiltersDuringMerge(t *testing.T) {
+	defer testRemoveAll(t)
+	defer SetRetentionFilters(nil)
+
+	rf, err := ParseRetentionFilter(`{env="dev"}:7d`)
+	if err != nil {
+		t.Fatalf("cannot parse retention filter: %s", err)
+	}
+	SetRetentionFilters([]*RetentionFilter{rf})
+
+	// Generate samples for dev and prod series 10 days ago.
+	startTimestamp := time.Now().Add(-10 * 24 * time.Hour).UnixMilli()
+	newRows := func(env string) []MetricRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("metric")
+		mn.AddTag("env", env)
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < 100; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     startTimestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+		return mrs
+	}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	s.AddRows(newRows("dev"), defaultPrecisionBits)
+	s.DebugFlush()
+	s.AddRows(newRows("prod"), defaultPrecisionBits)
+	s.DebugFlush()
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+
+	// Only samples for env="prod" must be left after the merge.
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.TotalRowsCount(); rowsCount != 100 {
+		t.Fatalf("unexpected number of rows after the merge; got %d; want %d", rowsCount, 100)
+	}
+}
+
+func TestStorageRetentionFiltersDuringSearch(t *testing.T) {
+	defer testRemoveAll(t)
+	defer SetRetentionFilters(nil)
+
+	rf, err := ParseRetentionFilter(`{env="dev"}:7d`)
+	if err != nil {
+		t.Fatalf("cannot parse retention filter: %s", err)
+	}
+	SetRetentionFilters([]*RetentionFilter{rf})
+
+	// Generate samples for dev and prod series around the retention filter deadline.
+	// The first 50 samples are outside the retention filter, while the last 50 samples are inside it.
+	startTimestamp := time.Now().Add(-7*24*time.Hour).UnixMilli() - 495*1000
+	newRows := func(env string) []MetricRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("metric")
+		mn.AddTag("env", env)
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < 100; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     startTimestamp + int64(i)*10*1000,
+				Value:         float64(i),
+			})
+		}
+		return mrs
+	}
+	countSamples := func(s *Storage, env string) int {
+		t.Helper()
+		tfs := NewTagFilters()
+		if err := tfs.Add([]byte("env"), []byte(env), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		tr := TimeRange{
+			MinTimestamp: startTimestamp,
+			MaxTimestamp: startTimestamp + 1000*1000,
+		}
+		var sr Search
+		var b Block
+		n := 0
+		sr.Init(nil, s, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			n += len(b.timestamps)
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error in search: %s", err)
+		}
+		sr.MustClose()
+		return n
+	}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	s.AddRows(newRows("dev"), defaultPrecisionBits)
+	s.AddRows(newRows("prod"), defaultPrecisionBits)
+	s.DebugFlush()
+
+	// Samples outside the retention filter must be invisible to search before the merge.
+	if n := countSamples(s, "dev"); n != 50 {
+		t.Fatalf("unexpected number of samples for env=dev; got %d; want %d", n, 50)
+	}
+	if n := countSamples(s, "prod"); n != 100 {
+		t.Fatalf("unexpected number of samples for env=prod; got %d; want %d", n, 100)
+	}
+
+	// Parts must be merged after the retention filters are applied to them.
+	ptws := s.tb.GetPartitions(nil)
+	defer s.tb.PutPartitions(ptws)
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	currentTimestamp := time.Now().UnixMilli()
+	for _, ptw := range ptws {
+		if ptw.pt.isRetentionFiltersMergeNeeded(currentTimestamp) {
+			t.Fatalf("unexpected merge needed for partition %s right after the merge", ptw.pt.name)
+		}
+		if !ptw.pt.isRetentionFiltersMergeNeeded(currentTimestamp + retentionFiltersMergeInterval) {
+			t.Fatalf("expecting merge needed for partition %s after %dms", ptw.pt.name, retentionFiltersMergeInterval)
+		}
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.TotalRowsCount(); rowsCount != 150 {
+		t.Fatalf("unexpected number of rows after the merge; got %d; want %d", rowsCount, 150)
+	}
+	if n := countSamples(s, "dev"); n != 50 {
+		t.Fatalf("unexpected number of samples for env=dev after the merge; got %d; want %d", n, 50)
+	}
+}
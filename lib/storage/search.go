@@ -3,6 +3,7 @@ package storage
 import (
 	"fmt"
 	"io"
+	"math"
 	"strings"
 
 	"github.com/VictoriaMetrics/VictoriaMetrics/lib/bytesutil"
@@ -21,37 +22,57 @@ import (
 type BlockRef struct {
 	p  *part
 	bh blockHeader
+
+	// tss contains tombstones, which must be applied to the block when reading it.
+	//
+	// It is nil if the block doesn't contain samples covered by tombstones.
+	tss *tombstones
+
+	// retentionDeadline is the retention deadline for the block series.
+	//
+	// Samples with smaller timestamps are skipped when reading the block.
+	// They are physically removed during background merges.
+	retentionDeadline int64
 }
 
 func (br *BlockRef) reset() {
 	br.p = nil
 	br.bh = blockHeader{}
+	br.tss = nil
+	br.retentionDeadline = 0
 }
 
 func (br *BlockRef) init(p *part, bh *blockHeader) {
 	br.p = p
 	br.bh = *bh
+	br.tss = nil
+	br.retentionDeadline = math.MinInt64
 }
 
 // Init initializes br from pr and data
 func (br *BlockRef) Init(pr PartRef, data []byte) error {
 	br.p = pr.p
+	br.tss = pr.tss
 	tail, err := br.bh.Unmarshal(data)
 	if err != nil {
 		return err
 	}
-	if len(tail) > 0 {
-		return fmt.Errorf("unexpected non-empty tail left after unmarshaling blockHeader; len(tail)=%d; tail=%q", len(tail), tail)
+	if len(tail) != 8 {
+		return fmt.Errorf("unexpected tail left after unmarshaling blockHeader; got %d bytes; want 8 bytes; tail=%q", len(tail), tail)
 	}
+	br.retentionDeadline = encoding.UnmarshalInt64(tail)
 	return nil
 }
 
 // Marshal marshals br to dst.
 func (br *BlockRef) Marshal(dst []byte) []byte {
-	return br.bh.Marshal(dst)
+	dst = br.bh.Marshal(dst)
+	return encoding.MarshalInt64(dst, br.retentionDeadline)
 }
 
 // RowsCount returns the number of rows in br.
+//
+// The returned number may exceed the number of rows read via MustReadBlock if br contains samples deleted via tombstones.
 func (br *BlockRef) RowsCount() int {
 	return int(br.bh.RowsCount)
 }
@@ -59,16 +80,22 @@ func (br *BlockRef) RowsCount() int {
 // PartRef returns PartRef from br.
 func (br *BlockRef) PartRef() PartRef {
 	return PartRef{
-		p: br.p,
+		p:   br.p,
+		tss: br.tss,
 	}
 }
 
 // PartRef is Part reference.
 type PartRef struct {
 	p *part
+
+	// tss contains tombstones, which must be applied to blocks referred by PartRef.
+	tss *tombstones
 }
 
 // MustReadBlock reads block from br to dst.
+//
+// Samples deleted via tombstones and samples outside the retention are removed from dst, so dst may become empty.
 func (br *BlockRef) MustReadBlock(dst *Block) {
 	dst.Reset()
 	dst.bh = br.bh
@@ -78,6 +105,23 @@ func (br *BlockRef) MustReadBlock(dst *Block) {
 
 	dst.valuesData = bytesutil.ResizeNoCopyMayOverallocate(dst.valuesData, int(br.bh.ValuesBlockSize))
 	br.p.valuesFile.MustReadAt(dst.valuesData, int64(br.bh.ValuesBlockOffset))
+
+	var trs []TimeRange
+	if br.bh.MinTimestamp < br.retentionDeadline {
+		trs = append(trs, TimeRange{
+			MinTimestamp: math.MinInt64,
+			MaxTimestamp: br.retentionDeadline - 1,
+		})
+	}
+	if br.tss != nil {
+		trs = br.tss.appendDeletedTimeRanges(trs, br.bh.TSID.MetricID, br.p.ph.TombstonesGeneration, br.bh.MinTimestamp, br.bh.MaxTimestamp)
+	}
+	if len(trs) == 0 {
+		return
+	}
+	if _, err := dst.removeSamplesInTimeRanges(trs); err != nil {
+		logger.Panicf("FATAL: cannot unmarshal block from %s for removing deleted samples: %s", br.p.path, err)
+	}
 }
 
 // MetricBlockRef contains reference to time series block for a single metric.
@@ -100,6 +144,9 @@ type Search struct {
 	// retentionDeadline is used for filtering out blocks outside the configured retention.
 	retentionDeadline int64
 
+	// rfm is used for filtering out samples outside the configured retention filters.
+	rfm retentionFiltersMatcher
+
 	ts tableSearch
 
 	// tr contains time range used in the search.
@@ -118,6 +165,21 @@ type Search struct {
 	loops int
 
 	prevMetricID uint64
+
+	// metricNamesStats is used for registering search requests for the found metric names.
+	metricNamesStats *metricNamesStatsTracker
+
+	// trackMetricNamesStats is set to true if the found metric names must be registered at metricNamesStats.
+	//
+	// See TrackMetricNamesStats for details.
+	trackMetricNamesStats bool
+
+	// prevMetricGroupID is the MetricGroupID for the last registered metric name.
+	//
+	// Blocks are sorted by TSID, which starts with MetricGroupID, so every metric name is registered once per search.
+	prevMetricGroupID uint64
+
+	metricGroupBuf []byte
 }
 
 func (s *Search) reset() {
@@ -126,6 +188,7 @@ func (s *Search) reset() {
 
 	s.idb = nil
 	s.retentionDeadline = 0
+	s.rfm.reset()
 	s.ts.reset()
 	s.tr = TimeRange{}
 	s.tfss = nil
@@ -134,6 +197,10 @@ func (s *Search) reset() {
 	s.needClosing = false
 	s.loops = 0
 	s.prevMetricID = 0
+	s.metricNamesStats = nil
+	s.trackMetricNamesStats = false
+	s.prevMetricGroupID = 0
+	s.metricGroupBuf = s.metricGroupBuf[:0]
 }
 
 // Init initializes s from the given storage, tfss and tr.
@@ -147,11 +214,14 @@ func (s *Search) Init(qt *querytracer.Tracer, storage *Storage, tfss []*TagFilte
 	if s.needClosing {
 		logger.Panicf("BUG: missing MustClose call before the next call to Init")
 	}
-	retentionDeadline := int64(fasttime.UnixTimestamp()*1e3) - storage.retentionMsecs
+	currentTimestamp := int64(fasttime.UnixTimestamp() * 1e3)
+	retentionDeadline := currentTimestamp - storage.retentionMsecs
 
 	s.reset()
 	s.idb = storage.idb()
+	s.metricNamesStats = storage.metricNamesStats
 	s.retentionDeadline = retentionDeadline
+	s.rfm.init(storage, retentionDeadline, currentTimestamp)
 	s.tr = tr
 	s.tfss = tfss
 	s.deadline = deadline
@@ -194,6 +264,27 @@ func (s *Search) Error() error {
 	return fmt.Errorf("error when searching for tagFilters=%s on the time range %s: %w", s.tfss, s.tr.String(), s.err)
 }
 
+// TrackMetricNamesStats enables registering query requests for the metric names found by s.
+//
+// It must be called after Init and only for searches performed for user queries such as /api/v1/query and /api/v1/query_range,
+// so internal searches such as data export and remote read don't affect metric names usage stats.
+func (s *Search) TrackMetricNamesStats() {
+	s.trackMetricNamesStats = true
+}
+
+func (s *Search) registerMetricNameQuery() {
+	if !s.trackMetricNamesStats || !s.metricNamesStats.isEnabled() {
+		return
+	}
+	_, metricGroup, err := unmarshalTagValue(s.metricGroupBuf[:0], s.MetricBlockRef.MetricName)
+	if err != nil {
+		// Skip invalid metric name. It is reported by the caller when unmarshaling MetricBlockRef.MetricName.
+		return
+	}
+	s.metricGroupBuf = metricGroup
+	s.metricNamesStats.registerQueryRequest(metricGroup, fasttime.UnixTimestamp())
+}
+
 // NextMetricBlock proceeds to the next MetricBlockRef.
 func (s *Search) NextMetricBlock() bool {
 	if s.err != nil {
@@ -207,12 +298,15 @@ func (s *Search) NextMetricBlock() bool {
 			}
 		}
 		s.loops++
-		tsid := &s.ts.BlockRef.bh.TSID
+		br := s.ts.BlockRef
+		retentionDeadline := s.rfm.getRetentionDeadline(&br.bh)
+		if br.bh.MaxTimestamp < retentionDeadline {
+			// Skip the block, since it contains only data outside the configured retention.
+			continue
+		}
+		br.retentionDeadline = retentionDeadline
+		tsid := &br.bh.TSID
 		if tsid.MetricID != s.prevMetricID {
-			if s.ts.BlockRef.bh.MaxTimestamp < s.retentionDeadline {
-				// Skip the block, since it contains only data outside the configured retention.
-				continue
-			}
 			var ok bool
 			s.MetricBlockRef.MetricName, ok = s.idb.searchMetricNameWithCache(s.MetricBlockRef.MetricName[:0], tsid.MetricID)
 			if !ok {
@@ -221,8 +315,12 @@ func (s *Search) NextMetricBlock() bool {
 				continue
 			}
 			s.prevMetricID = tsid.MetricID
+			if tsid.MetricGroupID != s.prevMetricGroupID {
+				s.registerMetricNameQuery()
+				s.prevMetricGroupID = tsid.MetricGroupID
+			}
 		}
-		s.MetricBlockRef.BlockRef = s.ts.BlockRef
+		s.MetricBlockRef.BlockRef = br
 		return true
 	}
 	if err := s.ts.Error(); err != nil {
@@ -245,6 +343,11 @@ type SearchQuery struct {
 
 	// The maximum number of time series the search query can return.
 	MaxMetrics int
+
+	// TrackMetricNamesStats must be set to true for search queries performed for /api/v1/query and /api/v1/query_range requests.
+	//
+	// Only such search queries are registered in metric names usage stats. See Search.TrackMetricNamesStats.
+	TrackMetricNamesStats bool
 }
 
 // GetTimeRange returns time range for the given sq.
@@ -134,6 +134,10 @@ type Storage struct {
 	nextDayMetricIDsUpdaterWG  sync.WaitGroup
 	retentionWatcherWG         sync.WaitGroup
 	freeDiskSpaceWatcherWG     sync.WaitGroup
+	tombstonesWatcherWG        sync.WaitGroup
+	exemplarsSaverWG           sync.WaitGroup
+	metricMetadataSaverWG      sync.WaitGroup
+	metricNamesStatsSaverWG    sync.WaitGroup
 
 	// The snapshotLock prevents from concurrent creation of snapshots,
 	// since this may result in snapshots without recently added data,
@@ -151,6 +155,27 @@ type Storage struct {
 	deletedMetricIDs           atomic.Pointer[uint64set.Set]
 	deletedMetricIDsUpdateLock sync.Mutex
 
+	// tombstones contains the currently registered tombstones for samples deleted on time ranges.
+	//
+	// See DeleteSeriesOnTimeRange for details.
+	tombstones     atomic.Pointer[tombstones]
+	tombstonesLock sync.Mutex
+
+	// exemplars contains exemplars for the stored series.
+	//
+	// See AddExemplars and SearchExemplars for details.
+	exemplars *exemplarStorage
+
+	// metricMetadata contains metadata for metric families.
+	//
+	// See AddMetricMetadata and SearchMetricMetadata for details.
+	metricMetadata *metricMetadataStorage
+
+	// metricNamesStats tracks usage stats for metric names.
+	//
+	// See GetMetricNamesStats for details.
+	metricNamesStats *metricNamesStatsTracker
+
 	// missingMetricIDs maps metricID to the deadline in unix timestamp seconds
 	// after which all the indexdb entries for the given metricID
 	// must be deleted if index entry isn't found by the given metricID.
@@ -163,10 +188,29 @@ type Storage struct {
 
 	// isReadOnly is set to true when the storage is in read-only mode.
 	isReadOnly atomic.Bool
+
+	// forceReadOnly is set to true when the storage is opened via MustOpenStorageReadOnly.
+	//
+	// The storage stays in read-only mode until it is closed in this case, and background activities,
+	// which modify the stored data such as merges, retention enforcement and tombstones removal, are disabled.
+	forceReadOnly bool
 }
 
 // MustOpenStorage opens storage on the given path with the given retentionMsecs.
 func MustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxDailySeries int) *Storage {
+	return mustOpenStorage(path, retention, maxHourlySeries, maxDailySeries, false)
+}
+
+// MustOpenStorageReadOnly opens storage on the given path in read-only mode.
+//
+// Background merges, retention enforcement and tombstones removal are disabled for the returned storage,
+// so the stored data isn't modified while it is read. Caches aren't saved on MustClose call.
+// Data mustn't be added to the returned storage.
+func MustOpenStorageReadOnly(path string) *Storage {
+	return mustOpenStorage(path, 0, 0, 0, true)
+}
+
+func mustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxDailySeries int, forceReadOnly bool) *Storage {
 	path, err := filepath.Abs(path)
 	if err != nil {
 		logger.Panicf("FATAL: cannot determine absolute path for %q: %s", path, err)
@@ -179,6 +223,12 @@ func MustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxD
 		cachePath:      filepath.Join(path, cacheDirname),
 		retentionMsecs: retention.Milliseconds(),
 		stopCh:         make(chan struct{}),
+		forceReadOnly:  forceReadOnly,
+	}
+	if forceReadOnly {
+		// Switch the storage to read-only mode before opening indexdb and data tables,
+		// so they do not start background merges.
+		s.isReadOnly.Store(true)
 	}
 	fs.MustMkdirIfNotExist(path)
 
@@ -239,6 +289,16 @@ func MustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxD
 	isEmptyDB := !fs.IsPathExist(filepath.Join(path, indexdbDirname))
 	fs.MustMkdirIfNotExist(metadataDir)
 	s.minTimestampForCompositeIndex = mustGetMinTimestampForCompositeIndex(metadataDir, isEmptyDB)
+	s.tombstones.Store(mustLoadTombstones(metadataDir))
+
+	// Load exemplars
+	s.exemplars = mustLoadExemplarStorage(filepath.Join(path, exemplarsDirname), maxExemplars)
+
+	// Load metric metadata
+	s.metricMetadata = mustLoadMetricMetadataStorage(filepath.Join(path, metricMetadataDirname), maxMetricMetadata)
+
+	// Load metric names stats
+	s.metricNamesStats = mustLoadMetricNamesStatsTracker(filepath.Join(path, metricNamesStatsDirname), maxMetricNamesStats)
 
 	// Load indexdb
 	idbPath := filepath.Join(path, indexdbDirname)
@@ -278,7 +338,9 @@ func MustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxD
 
 	// check for free disk space before opening the table
 	// to prevent unexpected part merges. See https://github.com/VictoriaMetrics/VictoriaMetrics/issues/4023
-	s.startFreeDiskSpaceWatcher()
+	if !forceReadOnly {
+		s.startFreeDiskSpaceWatcher()
+	}
 
 	// Load data
 	tablePath := filepath.Join(path, dataDirname)
@@ -287,7 +349,13 @@ func MustOpenStorage(path string, retention time.Duration, maxHourlySeries, maxD
 
 	s.startCurrHourMetricIDsUpdater()
 	s.startNextDayMetricIDsUpdater()
-	s.startRetentionWatcher()
+	if !forceReadOnly {
+		s.startRetentionWatcher()
+		s.startTombstonesWatcher()
+		s.startExemplarsSaver()
+		s.startMetricMetadataSaver()
+		s.startMetricNamesStatsSaver()
+	}
 
 	return s
 }
@@ -484,10 +552,11 @@ func (s *Storage) idb() *indexDB {
 
 // Metrics contains essential metrics for the Storage.
 type Metrics struct {
-	RowsReceivedTotal uint64
-	RowsAddedTotal    uint64
-	DedupsDuringMerge uint64
-	SnapshotsCount    uint64
+	RowsReceivedTotal             uint64
+	RowsAddedTotal                uint64
+	DedupsDuringMerge             uint64
+	DownsampledSamplesDuringMerge uint64
+	SnapshotsCount                uint64
 
 	TooSmallTimestampRows uint64
 	TooBigTimestampRows   uint64
@@ -548,6 +617,22 @@ type Metrics struct {
 
 	NextRetentionSeconds uint64
 
+	ExemplarsCount           uint64
+	ExemplarSeriesCount      uint64
+	ExemplarsMaxCount        uint64
+	ExemplarsAddedTotal      uint64
+	ExemplarsOutOfOrderTotal uint64
+	ExemplarsDuplicatesTotal uint64
+
+	MetricMetadataCount        uint64
+	MetricMetadataMaxCount     uint64
+	MetricMetadataAddedTotal   uint64
+	MetricMetadataDroppedTotal uint64
+
+	MetricNamesStatsCount        uint64
+	MetricNamesStatsMaxCount     uint64
+	MetricNamesStatsDroppedTotal uint64
+
 	IndexDBMetrics IndexDBMetrics
 	TableMetrics   TableMetrics
 }
@@ -562,6 +647,7 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	m.RowsReceivedTotal += s.rowsReceivedTotal.Load()
 	m.RowsAddedTotal += s.rowsAddedTotal.Load()
 	m.DedupsDuringMerge = dedupsDuringMerge.Load()
+	m.DownsampledSamplesDuringMerge = downsampledSamplesDuringMerge.Load()
 	m.SnapshotsCount += uint64(s.mustGetSnapshotsCount())
 
 	m.TooSmallTimestampRows += s.tooSmallTimestampRows.Load()
@@ -648,6 +734,10 @@ func (s *Storage) UpdateMetrics(m *Metrics) {
 	}
 	m.NextRetentionSeconds = uint64(d)
 
+	s.exemplars.updateMetrics(m)
+	s.metricMetadata.updateMetrics(m)
+	s.metricNamesStats.updateMetrics(m)
+
 	s.idb().UpdateMetrics(&m.IndexDBMetrics)
 	s.tb.UpdateMetrics(&m.TableMetrics)
 }
@@ -869,12 +959,26 @@ func (s *Storage) MustClose() {
 
 	s.freeDiskSpaceWatcherWG.Wait()
 	s.retentionWatcherWG.Wait()
+	s.tombstonesWatcherWG.Wait()
+	s.exemplarsSaverWG.Wait()
+	s.metricMetadataSaverWG.Wait()
+	s.metricNamesStatsSaverWG.Wait()
 	s.currHourMetricIDsUpdaterWG.Wait()
 	s.nextDayMetricIDsUpdaterWG.Wait()
 
 	s.tb.MustClose()
 	s.idb().MustClose()
 
+	if s.forceReadOnly {
+		// Do not save caches and in-memory state, since the storage files mustn't be modified in read-only mode.
+		s.tsidCache.Stop()
+		s.metricIDCache.Stop()
+		s.metricNameCache.Stop()
+		fs.MustClose(s.flockF)
+		s.flockF = nil
+		return
+	}
+
 	// Save caches.
 	s.mustSaveCache(s.tsidCache, "metricName_tsid")
 	s.tsidCache.Stop()
@@ -891,6 +995,10 @@ func (s *Storage) MustClose() {
 	nextDayMetricIDs := s.nextDayMetricIDs.Load()
 	s.mustSaveNextDayMetricIDs(nextDayMetricIDs)
 
+	s.exemplars.mustSave(filepath.Join(s.path, exemplarsDirname))
+	s.metricMetadata.mustSave(filepath.Join(s.path, metricMetadataDirname))
+	s.metricNamesStats.mustSave(filepath.Join(s.path, metricNamesStatsDirname))
+
 	// Release lock file.
 	fs.MustClose(s.flockF)
 	s.flockF = nil
@@ -1176,6 +1284,53 @@ func (s *Storage) SearchMetricNames(qt *querytracer.Tracer, tfss []*TagFilters,
 	return metricNames, nil
 }
 
+// SeriesEstimate contains the estimated costs for searching series matching the given filters.
+type SeriesEstimate struct {
+	// SeriesCount is the number of series matching the filters on the given time range.
+	SeriesCount int
+
+	// IndexLookups is the number of inverted index lookups needed for locating the matching series.
+	IndexLookups int
+}
+
+// EstimateSeries returns the estimated costs for searching series matching tfss on the given tr.
+//
+// Only the indexdb is used for the estimation, so data blocks aren't read.
+func (s *Storage) EstimateSeries(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxMetrics int, deadline uint64) (*SeriesEstimate, error) {
+	qt = qt.NewChild("estimate series: filters=%s, timeRange=%s", tfss, &tr)
+	defer qt.Done()
+
+	metricIDs, err := s.idb().searchMetricIDs(qt, tfss, tr, maxMetrics, deadline)
+	if err != nil {
+		return nil, err
+	}
+	se := &SeriesEstimate{
+		SeriesCount:  len(metricIDs),
+		IndexLookups: getIndexLookupsCount(tfss, tr),
+	}
+	qt.Printf("found %d series; index lookups: %d", se.SeriesCount, se.IndexLookups)
+	return se, nil
+}
+
+// getIndexLookupsCount returns the number of inverted index lookups needed for searching series matching tfss on the given tr.
+//
+// Every tag filter is looked up in the per-day index for every day in tr if tr is small enough.
+// Otherwise it is looked up in the global index once. See indexSearch.updateMetricIDsForTagFilters.
+func getIndexLookupsCount(tfss []*TagFilters, tr TimeRange) int {
+	days := 1
+	minDate := uint64(tr.MinTimestamp) / msecPerDay
+	maxDate := uint64(tr.MaxTimestamp-1) / msecPerDay
+	if minDate <= maxDate && maxDate-minDate <= maxDaysForPerDaySearch {
+		days = int(maxDate-minDate) + 1
+	}
+	n := 0
+	for _, tfs := range tfss {
+		// An empty filters are equivalent to `{__name__!=""}`.
+		n += max(len(tfs.tfs), 1) * days
+	}
+	return n
+}
+
 // prefetchMetricNames pre-fetches metric names for the given srcMetricIDs into metricID->metricName cache.
 //
 // This should speed-up further searchMetricNameWithCache calls for srcMetricIDs from tsids.
@@ -1547,6 +1702,31 @@ func (s *Storage) GetTSDBStatus(qt *querytracer.Tracer, tfss []*TagFilters, date
 	return s.idb().GetTSDBStatus(qt, tfss, date, focusLabel, topN, maxMetrics, deadline)
 }
 
+// GetTSDBStatusForDateRange returns TSDB status data for /api/v1/status/tsdb on the [minDate..maxDate] date range.
+//
+// The date range is clamped to the retention, since there is no data outside it.
+func (s *Storage) GetTSDBStatusForDateRange(qt *querytracer.Tracer, tfss []*TagFilters, minDate, maxDate uint64, focusLabel string, topN, maxMetrics int, deadline uint64) (*TSDBStatus, error) {
+	if maxDate > 0 {
+		if minRetentionDate := s.GetMinRetentionDate(); minDate < minRetentionDate {
+			if maxDate < minRetentionDate {
+				// The whole date range is outside the retention.
+				return &TSDBStatus{}, nil
+			}
+			minDate = minRetentionDate
+		}
+	}
+	return s.idb().GetTSDBStatusForDateRange(qt, tfss, minDate, maxDate, focusLabel, topN, maxMetrics, deadline)
+}
+
+// GetMinRetentionDate returns the first date, which may contain data according to the retention for s.
+func (s *Storage) GetMinRetentionDate() uint64 {
+	minTimestamp := int64(fasttime.UnixTimestamp()*1000) - s.retentionMsecs
+	if minTimestamp <= 0 {
+		return 0
+	}
+	return uint64(minTimestamp / msecPerDay)
+}
+
 // MetricRow is a metric to insert into storage.
 type MetricRow struct {
 	// MetricNameRaw contains raw metric name, which must be decoded
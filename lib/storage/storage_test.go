@@ -2162,3 +2162,72 @@ func testGenerateMetricRowBatches(opts *batchOptions) ([][]MetricRow, *counts) {
 	}
 	return batches, &want
 }
+
+func TestStorageEstimateSeries(t *testing.T) {
+	defer testRemoveAll(t)
+
+	const numRows = 10
+	rng := rand.New(rand.NewSource(1))
+	tr := TimeRange{
+		MinTimestamp: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli(),
+		MaxTimestamp: time.Date(2000, 1, 2, 23, 59, 59, 999, time.UTC).UnixMilli(),
+	}
+	mrs := testGenerateMetricRowsWithPrefix(rng, numRows, "metric1", tr)
+	mrs = append(mrs, testGenerateMetricRowsWithPrefix(rng, numRows, "metric2", tr)...)
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	defer s.MustClose()
+	s.AddRows(mrs, defaultPrecisionBits)
+	s.DebugFlush()
+
+	f := func(filter string, seriesCountExpected, indexLookupsExpected int) {
+		t.Helper()
+		tfs := NewTagFilters()
+		if err := tfs.Add(nil, []byte(filter), false, true); err != nil {
+			t.Fatalf("unexpected error in TagFilters.Add: %s", err)
+		}
+		se, err := s.EstimateSeries(nil, []*TagFilters{tfs}, tr, 1e5, noDeadline)
+		if err != nil {
+			t.Fatalf("unexpected error in EstimateSeries(%q): %s", filter, err)
+		}
+		if se.SeriesCount != seriesCountExpected {
+			t.Fatalf("unexpected series count for %q; got %d; want %d", filter, se.SeriesCount, seriesCountExpected)
+		}
+		if se.IndexLookups != indexLookupsExpected {
+			t.Fatalf("unexpected index lookups for %q; got %d; want %d", filter, se.IndexLookups, indexLookupsExpected)
+		}
+	}
+
+	// The time range covers two days, so the filter is looked up in the per-day index twice.
+	f("metric1.*", numRows, 2)
+	f("metric.*", 2*numRows, 2)
+	f("nonexisting.*", 0, 2)
+}
+
+func TestGetIndexLookupsCount(t *testing.T) {
+	f := func(filtersCount int, tr TimeRange, resultExpected int) {
+		t.Helper()
+		tfs := NewTagFilters()
+		for i := 0; i < filtersCount; i++ {
+			if err := tfs.Add([]byte(fmt.Sprintf("label_%d", i)), []byte("value"), false, false); err != nil {
+				t.Fatalf("unexpected error in TagFilters.Add: %s", err)
+			}
+		}
+		result := getIndexLookupsCount([]*TagFilters{tfs}, tr)
+		if result != resultExpected {
+			t.Fatalf("unexpected result for %d filters on %s; got %d; want %d", filtersCount, &tr, result, resultExpected)
+		}
+	}
+
+	day := func(n int) int64 {
+		return time.Date(2000, 1, n, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+
+	// Empty filters are equivalent to a single filter.
+	f(0, TimeRange{day(1), day(2)}, 1)
+	f(2, TimeRange{day(1), day(2)}, 2)
+	f(2, TimeRange{day(1), day(3)}, 4)
+
+	// The global index is used for big time ranges.
+	f(3, TimeRange{day(1), day(1) + (maxDaysForPerDaySearch+2)*msecPerDay}, 3)
+}
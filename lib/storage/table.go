@@ -27,9 +27,10 @@ type table struct {
 
 	stopCh chan struct{}
 
-	retentionWatcherWG  sync.WaitGroup
-	finalDedupWatcherWG sync.WaitGroup
-	forceMergeWG        sync.WaitGroup
+	retentionWatcherWG        sync.WaitGroup
+	finalDedupWatcherWG       sync.WaitGroup
+	retentionFiltersWatcherWG sync.WaitGroup
+	forceMergeWG              sync.WaitGroup
 }
 
 // partitionWrapper provides refcounting mechanism for the partition.
@@ -113,8 +114,11 @@ func mustOpenTable(path string, s *Storage) *table {
 	for _, pt := range pts {
 		tb.addPartitionNolock(pt)
 	}
-	tb.startRetentionWatcher()
-	tb.startFinalDedupWatcher()
+	if !s.forceReadOnly {
+		tb.startRetentionWatcher()
+		tb.startFinalDedupWatcher()
+		tb.startRetentionFiltersWatcher()
+	}
 	return tb
 }
 
@@ -169,6 +173,7 @@ func (tb *table) MustClose() {
 	close(tb.stopCh)
 	tb.retentionWatcherWG.Wait()
 	tb.finalDedupWatcherWG.Wait()
+	tb.retentionFiltersWatcherWG.Wait()
 	tb.forceMergeWG.Wait()
 
 	tb.ptwsLock.Lock()
@@ -435,8 +440,8 @@ func (tb *table) startFinalDedupWatcher() {
 }
 
 func (tb *table) finalDedupWatcher() {
-	if !isDedupEnabled() {
-		// Deduplication is disabled.
+	if !isDedupEnabled() && !isDownsamplingEnabled() {
+		// Deduplication and downsampling are disabled.
 		return
 	}
 	f := func() {
@@ -478,6 +483,46 @@ func (tb *table) finalDedupWatcher() {
 	}
 }
 
+func (tb *table) startRetentionFiltersWatcher() {
+	tb.retentionFiltersWatcherWG.Add(1)
+	go func() {
+		tb.retentionFiltersWatcher()
+		tb.retentionFiltersWatcherWG.Done()
+	}()
+}
+
+// retentionFiltersWatcher periodically merges partitions with samples outside the configured retention filters.
+func (tb *table) retentionFiltersWatcher() {
+	if len(GetRetentionFilters()) == 0 {
+		// Retention filters are disabled.
+		return
+	}
+	f := func() {
+		ptws := tb.GetPartitions(nil)
+		defer tb.PutPartitions(ptws)
+		timestamp := timestampFromTime(time.Now())
+		for _, ptw := range ptws {
+			if !ptw.pt.isRetentionFiltersMergeNeeded(timestamp) {
+				continue
+			}
+			if err := ptw.pt.runRetentionFiltersMerge(tb.stopCh); err != nil {
+				logger.Errorf("cannot apply retention filters to partition %s: %s", ptw.pt.name, err)
+			}
+		}
+	}
+	d := timeutil.AddJitterToDuration(time.Hour)
+	t := time.NewTicker(d)
+	defer t.Stop()
+	for {
+		select {
+		case <-tb.stopCh:
+			return
+		case <-t.C:
+			f()
+		}
+	}
+}
+
 // GetPartitions appends tb's partitions snapshot to dst and returns the result.
 //
 // The returned partitions must be passed to PutPartitions
@@ -493,6 +538,27 @@ func (tb *table) GetPartitions(dst []*partitionWrapper) []*partitionWrapper {
 	return dst
 }
 
+// mustGetOrCreatePartition returns the partition for the given timestamp.
+//
+// The partition is created if it is missing. The returned partition must be passed to PutPartitions
+// when it is no longer needed.
+func (tb *table) mustGetOrCreatePartition(timestamp int64) *partitionWrapper {
+	tb.ptwsLock.Lock()
+	defer tb.ptwsLock.Unlock()
+
+	for _, ptw := range tb.ptws {
+		if ptw.pt.HasTimestamp(timestamp) {
+			ptw.incRef()
+			return ptw
+		}
+	}
+	pt := mustCreatePartition(timestamp, tb.smallPartitionsPath, tb.bigPartitionsPath, tb.s)
+	tb.addPartitionNolock(pt)
+	ptw := tb.ptws[len(tb.ptws)-1]
+	ptw.incRef()
+	return ptw
+}
+
 // PutPartitions deregisters ptws obtained via GetPartitions.
 func (tb *table) PutPartitions(ptws []*partitionWrapper) {
 	for _, ptw := range ptws {
@@ -0,0 +1,313 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/encoding"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/fs"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/logger"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/querytracer"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/timeutil"
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+// tombstone marks samples for metricIDs on the time range tr as deleted.
+type tombstone struct {
+	// generation is the generation of the tombstone. It is unique across tombstones for the given storage.
+	generation uint64
+
+	// tr is the time range with deleted samples.
+	tr TimeRange
+
+	// metricIDs contains metricIDs for series with deleted samples.
+	metricIDs *uint64set.Set
+}
+
+// tombstones is an immutable snapshot of tombstones registered in the storage.
+//
+// Samples covered by tombstones are skipped during search and are physically removed during background merges.
+// Every part has partHeader.TombstonesGeneration set to the tombstones generation at the time the part is created,
+// so tombstones are applied only to parts created before them. This allows backfilling samples on the time range with deleted samples.
+// A tombstone is removed when all the parts created before it are re-created by merges.
+type tombstones struct {
+	// generation is the generation of the most recently created tombstone.
+	generation uint64
+
+	items []*tombstone
+}
+
+// getGeneration returns the tombstones generation for tss.
+func (tss *tombstones) getGeneration() uint64 {
+	if tss == nil {
+		return 0
+	}
+	return tss.generation
+}
+
+func (tss *tombstones) getItems() []*tombstone {
+	if tss == nil {
+		return nil
+	}
+	return tss.items
+}
+
+// appendDeletedTimeRanges appends time ranges with deleted samples for the given metricID on [minTimestamp ... maxTimestamp] to dst
+// and returns the result.
+//
+// partGeneration must contain partHeader.TombstonesGeneration for the part with the samples.
+// Tombstones with generations smaller or equal to partGeneration are skipped.
+func (tss *tombstones) appendDeletedTimeRanges(dst []TimeRange, metricID, partGeneration uint64, minTimestamp, maxTimestamp int64) []TimeRange {
+	if tss == nil || tss.generation <= partGeneration {
+		return dst
+	}
+	for _, t := range tss.items {
+		if t.generation <= partGeneration {
+			continue
+		}
+		if t.tr.MinTimestamp > maxTimestamp || t.tr.MaxTimestamp < minTimestamp {
+			continue
+		}
+		if t.metricIDs.Has(metricID) {
+			dst = append(dst, t.tr)
+		}
+	}
+	return dst
+}
+
+// isCoveredByTimeRanges returns true if [minTimestamp ... maxTimestamp] is fully covered by some of trs.
+//
+// It returns false if [minTimestamp ... maxTimestamp] is covered by multiple trs.
+func isCoveredByTimeRanges(trs []TimeRange, minTimestamp, maxTimestamp int64) bool {
+	for _, tr := range trs {
+		if tr.MinTimestamp <= minTimestamp && tr.MaxTimestamp >= maxTimestamp {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSamplesInTimeRanges removes samples with timestamps covered by trs from b.
+//
+// It returns the number of removed samples. The block may become empty after the call.
+func (b *Block) removeSamplesInTimeRanges(trs []TimeRange) (int, error) {
+	if err := b.UnmarshalData(); err != nil {
+		return 0, err
+	}
+	srcTimestamps := b.timestamps[b.nextIdx:]
+	srcValues := b.values[b.nextIdx:]
+	dstTimestamps := srcTimestamps[:0]
+	dstValues := srcValues[:0]
+	for i, ts := range srcTimestamps {
+		if isTimestampInTimeRanges(trs, ts) {
+			continue
+		}
+		dstTimestamps = append(dstTimestamps, ts)
+		dstValues = append(dstValues, srcValues[i])
+	}
+	removed := len(srcTimestamps) - len(dstTimestamps)
+	if removed == 0 {
+		return 0, nil
+	}
+	b.timestamps = b.timestamps[:b.nextIdx+len(dstTimestamps)]
+	b.values = b.values[:b.nextIdx+len(dstValues)]
+	b.bh.RowsCount = uint32(len(dstTimestamps))
+	if len(dstTimestamps) > 0 {
+		b.fixupTimestamps()
+	}
+	return removed, nil
+}
+
+func isTimestampInTimeRanges(trs []TimeRange, timestamp int64) bool {
+	for _, tr := range trs {
+		if timestamp >= tr.MinTimestamp && timestamp <= tr.MaxTimestamp {
+			return true
+		}
+	}
+	return false
+}
+
+func (tss *tombstones) marshal(dst []byte) []byte {
+	dst = encoding.MarshalUint64(dst, tss.generation)
+	dst = encoding.MarshalUint64(dst, uint64(len(tss.items)))
+	for _, t := range tss.items {
+		dst = encoding.MarshalUint64(dst, t.generation)
+		dst = encoding.MarshalInt64(dst, t.tr.MinTimestamp)
+		dst = encoding.MarshalInt64(dst, t.tr.MaxTimestamp)
+		dst = marshalUint64Set(dst, t.metricIDs)
+	}
+	return dst
+}
+
+func (tss *tombstones) unmarshal(src []byte) error {
+	if len(src) < 16 {
+		return fmt.Errorf("cannot unmarshal tombstones header; got %d bytes; want at least 16 bytes", len(src))
+	}
+	tss.generation = encoding.UnmarshalUint64(src)
+	itemsLen := encoding.UnmarshalUint64(src[8:])
+	src = src[16:]
+	tss.items = make([]*tombstone, 0, itemsLen)
+	for i := uint64(0); i < itemsLen; i++ {
+		if len(src) < 32 {
+			return fmt.Errorf("cannot unmarshal tombstone #%d; got %d bytes; want at least 32 bytes", i, len(src))
+		}
+		t := &tombstone{
+			generation: encoding.UnmarshalUint64(src),
+			tr: TimeRange{
+				MinTimestamp: encoding.UnmarshalInt64(src[8:]),
+				MaxTimestamp: encoding.UnmarshalInt64(src[16:]),
+			},
+		}
+		metricIDs, tail, err := unmarshalUint64Set(src[24:])
+		if err != nil {
+			return fmt.Errorf("cannot unmarshal metricIDs for tombstone #%d: %w", i, err)
+		}
+		t.metricIDs = metricIDs
+		src = tail
+		tss.items = append(tss.items, t)
+	}
+	if len(src) > 0 {
+		return fmt.Errorf("unexpected non-empty tail left after unmarshaling tombstones; len(tail)=%d", len(src))
+	}
+	return nil
+}
+
+const tombstonesFilename = "tombstones"
+
+func mustLoadTombstones(metadataDir string) *tombstones {
+	path := filepath.Join(metadataDir, tombstonesFilename)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &tombstones{}
+		}
+		logger.Panicf("FATAL: cannot read tombstones: %s", err)
+	}
+	var tss tombstones
+	if err := tss.unmarshal(data); err != nil {
+		logger.Panicf("FATAL: cannot unmarshal tombstones from %q: %s", path, err)
+	}
+	return &tss
+}
+
+func mustSaveTombstones(metadataDir string, tss *tombstones) {
+	path := filepath.Join(metadataDir, tombstonesFilename)
+	data := tss.marshal(nil)
+	fs.MustWriteAtomic(path, data, true)
+}
+
+func (s *Storage) getTombstones() *tombstones {
+	return s.tombstones.Load()
+}
+
+// DeleteSeriesOnTimeRange deletes samples on the given tr for series matching the given tfss.
+//
+// Samples are deleted by registering a tombstone, which is honored by search immediately.
+// The tombstone is applied only to samples added before the call, so samples can be backfilled on tr after the call.
+// The deleted samples are physically removed from the storage during subsequent merges.
+// The tombstone is dropped after all the parts with the deleted samples are merged.
+//
+// Returns the number of series with deleted samples.
+func (s *Storage) DeleteSeriesOnTimeRange(qt *querytracer.Tracer, tfss []*TagFilters, tr TimeRange, maxMetrics int) (int, error) {
+	metricIDs, err := s.idb().searchMetricIDs(qt, tfss, tr, maxMetrics, noDeadline)
+	if err != nil {
+		return 0, fmt.Errorf("cannot find series to delete on the time range %s: %w", &tr, err)
+	}
+	if len(metricIDs) == 0 {
+		return 0, nil
+	}
+	var m uint64set.Set
+	m.AddMulti(metricIDs)
+
+	// Convert recently added rows to parts before registering the tombstone, so the tombstone is applied to them.
+	s.tb.flushPendingRows()
+
+	s.tombstonesLock.Lock()
+	tssOld := s.getTombstones()
+	tssNew := &tombstones{
+		generation: tssOld.generation + 1,
+		items:      append([]*tombstone{}, tssOld.items...),
+	}
+	tssNew.items = append(tssNew.items, &tombstone{
+		generation: tssNew.generation,
+		tr:         tr,
+		metricIDs:  &m,
+	})
+	mustSaveTombstones(filepath.Join(s.path, metadataDirname), tssNew)
+	s.tombstones.Store(tssNew)
+	s.tombstonesLock.Unlock()
+	qt.Printf("registered tombstone for %d series on the time range %s", len(metricIDs), &tr)
+
+	return len(metricIDs), nil
+}
+
+func (s *Storage) startTombstonesWatcher() {
+	s.tombstonesWatcherWG.Add(1)
+	go func() {
+		s.tombstonesWatcher()
+		s.tombstonesWatcherWG.Done()
+	}()
+}
+
+func (s *Storage) tombstonesWatcher() {
+	d := timeutil.AddJitterToDuration(time.Minute)
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+			s.removeAppliedTombstones()
+		}
+	}
+}
+
+// removeAppliedTombstones removes tombstones, which no longer cover samples stored in s.
+func (s *Storage) removeAppliedTombstones() {
+	if len(s.getTombstones().getItems()) == 0 {
+		// Fast path - nothing to remove.
+		return
+	}
+
+	s.tombstonesLock.Lock()
+	defer s.tombstonesLock.Unlock()
+
+	tssOld := s.getTombstones()
+	ptws := s.tb.GetPartitions(nil)
+	defer s.tb.PutPartitions(ptws)
+
+	minTimestamp := timestampFromTime(time.Now()) - s.retentionMsecs
+	items := make([]*tombstone, 0, len(tssOld.items))
+	for _, t := range tssOld.items {
+		if t.tr.MaxTimestamp < minTimestamp {
+			// The tombstone covers only samples outside the retention.
+			continue
+		}
+		if !hasPartsCoveredByTombstone(ptws, t) {
+			// All the samples covered by the tombstone have been removed during merges.
+			continue
+		}
+		items = append(items, t)
+	}
+	if len(items) == len(tssOld.items) {
+		return
+	}
+	tssNew := &tombstones{
+		generation: tssOld.generation,
+		items:      items,
+	}
+	mustSaveTombstones(filepath.Join(s.path, metadataDirname), tssNew)
+	s.tombstones.Store(tssNew)
+}
+
+func hasPartsCoveredByTombstone(ptws []*partitionWrapper, t *tombstone) bool {
+	for _, ptw := range ptws {
+		if ptw.pt.hasPartsWithTombstonesGenerationLess(t.tr, t.generation) {
+			return true
+		}
+	}
+	return false
+}
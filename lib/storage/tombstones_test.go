@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/VictoriaMetrics/VictoriaMetrics/lib/uint64set"
+)
+
+func TestTombstonesMarshalUnmarshal(t *testing.T) {
+	f := func(tss *tombstones) {
+		t.Helper()
+		data := tss.marshal(nil)
+		var tss2 tombstones
+		if err := tss2.unmarshal(data); err != nil {
+			t.Fatalf("cannot unmarshal tombstones: %s", err)
+		}
+		if tss2.generation != tss.generation {
+			t.Fatalf("unexpected generation; got %d; want %d", tss2.generation, tss.generation)
+		}
+		if len(tss2.items) != len(tss.items) {
+			t.Fatalf("unexpected number of items; got %d; want %d", len(tss2.items), len(tss.items))
+		}
+		for i, item := range tss.items {
+			item2 := tss2.items[i]
+			if item2.generation != item.generation {
+				t.Fatalf("unexpected generation for item #%d; got %d; want %d", i, item2.generation, item.generation)
+			}
+			if item2.tr != item.tr {
+				t.Fatalf("unexpected time range for item #%d; got %s; want %s", i, &item2.tr, &item.tr)
+			}
+			if !item2.metricIDs.Equal(item.metricIDs) {
+				t.Fatalf("unexpected metricIDs for item #%d; got %v; want %v", i, item2.metricIDs.AppendTo(nil), item.metricIDs.AppendTo(nil))
+			}
+		}
+	}
+	newSet := func(metricIDs ...uint64) *uint64set.Set {
+		var m uint64set.Set
+		m.AddMulti(metricIDs)
+		return &m
+	}
+	f(&tombstones{})
+	f(&tombstones{
+		generation: 3,
+		items: []*tombstone{
+			{
+				generation: 2,
+				tr:         TimeRange{MinTimestamp: 10, MaxTimestamp: 20},
+				metricIDs:  newSet(1, 2, 3),
+			},
+			{
+				generation: 3,
+				tr:         TimeRange{MinTimestamp: -5, MaxTimestamp: 1e12},
+				metricIDs:  newSet(123456789),
+			},
+		},
+	})
+}
+
+func TestBlockRemoveSamplesInTimeRanges(t *testing.T) {
+	f := func(timestamps []int64, trs []TimeRange, timestampsExpected []int64) {
+		t.Helper()
+		var b Block
+		b.Init(&TSID{MetricID: 1}, timestamps, timestamps, 0, 64)
+		n, err := b.removeSamplesInTimeRanges(trs)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if n != len(timestamps)-len(timestampsExpected) {
+			t.Fatalf("unexpected number of removed samples; got %d; want %d", n, len(timestamps)-len(timestampsExpected))
+		}
+		if b.rowsCount() != len(timestampsExpected) {
+			t.Fatalf("unexpected rowsCount; got %d; want %d", b.rowsCount(), len(timestampsExpected))
+		}
+		if len(timestampsExpected) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(b.timestamps, timestampsExpected) {
+			t.Fatalf("unexpected timestamps;\ngot\n%v\nwant\n%v", b.timestamps, timestampsExpected)
+		}
+		if !reflect.DeepEqual(b.values, timestampsExpected) {
+			t.Fatalf("unexpected values;\ngot\n%v\nwant\n%v", b.values, timestampsExpected)
+		}
+		if b.bh.MinTimestamp != timestampsExpected[0] || b.bh.MaxTimestamp != timestampsExpected[len(timestampsExpected)-1] {
+			t.Fatalf("unexpected time range for the block; got [%d..%d]", b.bh.MinTimestamp, b.bh.MaxTimestamp)
+		}
+	}
+
+	// no samples are removed
+	f([]int64{1, 2, 3}, []TimeRange{{MinTimestamp: 10, MaxTimestamp: 20}}, []int64{1, 2, 3})
+
+	// samples in the middle are removed
+	f([]int64{1, 2, 3, 4, 5}, []TimeRange{{MinTimestamp: 2, MaxTimestamp: 3}}, []int64{1, 4, 5})
+
+	// samples at the edges are removed
+	f([]int64{1, 2, 3, 4, 5}, []TimeRange{{MinTimestamp: 0, MaxTimestamp: 1}, {MinTimestamp: 5, MaxTimestamp: 7}}, []int64{2, 3, 4})
+
+	// all the samples are removed
+	f([]int64{1, 2, 3}, []TimeRange{{MinTimestamp: 1, MaxTimestamp: 2}, {MinTimestamp: 3, MaxTimestamp: 3}}, nil)
+}
+
+func TestStorageDeleteSeriesOnTimeRange(t *testing.T) {
+	defer testRemoveAll(t)
+
+	startTimestamp := time.Now().Add(-time.Hour).Truncate(time.Minute).UnixMilli()
+	newRows := func(job string) []MetricRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("metric")
+		mn.AddTag("job", job)
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := 0; i < 100; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     startTimestamp + int64(i)*1000,
+				Value:         float64(i),
+			})
+		}
+		return mrs
+	}
+	newTagFilters := func(job string) []*TagFilters {
+		tfs := NewTagFilters()
+		if err := tfs.Add([]byte("job"), []byte(job), false, false); err != nil {
+			t.Fatalf("cannot add tag filter: %s", err)
+		}
+		return []*TagFilters{tfs}
+	}
+	tr := TimeRange{
+		MinTimestamp: startTimestamp,
+		MaxTimestamp: startTimestamp + 100*1000,
+	}
+	countSamples := func(s *Storage, job string) int {
+		t.Helper()
+		var sr Search
+		var b Block
+		n := 0
+		sr.Init(nil, s, newTagFilters(job), tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			n += len(b.timestamps)
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error in search: %s", err)
+		}
+		sr.MustClose()
+		return n
+	}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	s.AddRows(newRows("foo"), defaultPrecisionBits)
+	s.AddRows(newRows("bar"), defaultPrecisionBits)
+	s.DebugFlush()
+
+	// Delete samples for job="foo" on [10s ... 19s] time range.
+	trDelete := TimeRange{
+		MinTimestamp: startTimestamp + 10*1000,
+		MaxTimestamp: startTimestamp + 19*1000,
+	}
+	n, err := s.DeleteSeriesOnTimeRange(nil, newTagFilters("foo"), trDelete, 1e5)
+	if err != nil {
+		t.Fatalf("cannot delete series on time range: %s", err)
+	}
+	if n != 1 {
+		t.Fatalf("unexpected number of series with deleted samples; got %d; want %d", n, 1)
+	}
+
+	// The deleted samples must be invisible to search before the merge.
+	if n := countSamples(s, "foo"); n != 90 {
+		t.Fatalf("unexpected number of samples for job=foo; got %d; want %d", n, 90)
+	}
+	if n := countSamples(s, "bar"); n != 100 {
+		t.Fatalf("unexpected number of samples for job=bar; got %d; want %d", n, 100)
+	}
+
+	// The tombstone must survive storage restart.
+	s.MustClose()
+	s = MustOpenStorage(t.Name(), 0, 0, 0)
+	if n := len(s.getTombstones().items); n != 1 {
+		t.Fatalf("unexpected number of tombstones after restart; got %d; want %d", n, 1)
+	}
+	if n := countSamples(s, "foo"); n != 90 {
+		t.Fatalf("unexpected number of samples for job=foo after restart; got %d; want %d", n, 90)
+	}
+
+	// The tombstone cannot be removed until the parts with deleted samples are merged.
+	s.removeAppliedTombstones()
+	if n := len(s.getTombstones().items); n != 1 {
+		t.Fatalf("unexpected number of tombstones before the merge; got %d; want %d", n, 1)
+	}
+
+	// The deleted samples must be physically removed during the merge.
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.TotalRowsCount(); rowsCount != 190 {
+		t.Fatalf("unexpected number of rows after the merge; got %d; want %d", rowsCount, 190)
+	}
+
+	// The tombstone must be removed after the merge.
+	s.removeAppliedTombstones()
+	if n := len(s.getTombstones().items); n != 0 {
+		t.Fatalf("unexpected number of tombstones after the merge; got %d; want %d", n, 0)
+	}
+	if n := countSamples(s, "foo"); n != 90 {
+		t.Fatalf("unexpected number of samples for job=foo after the merge; got %d; want %d", n, 90)
+	}
+	s.MustClose()
+}
+
+func TestStorageDeleteSeriesOnTimeRangeBackfill(t *testing.T) {
+	defer testRemoveAll(t)
+
+	startTimestamp := time.Now().Add(-time.Hour).Truncate(time.Minute).UnixMilli()
+	newRows := func(start, end int, value float64) []MetricRow {
+		var mn MetricName
+		mn.MetricGroup = []byte("metric")
+		mn.AddTag("job", "foo")
+		metricNameRaw := mn.marshalRaw(nil)
+		var mrs []MetricRow
+		for i := start; i < end; i++ {
+			mrs = append(mrs, MetricRow{
+				MetricNameRaw: metricNameRaw,
+				Timestamp:     startTimestamp + int64(i)*1000,
+				Value:         value,
+			})
+		}
+		return mrs
+	}
+	tfs := NewTagFilters()
+	if err := tfs.Add([]byte("job"), []byte("foo"), false, false); err != nil {
+		t.Fatalf("cannot add tag filter: %s", err)
+	}
+	tfss := []*TagFilters{tfs}
+	tr := TimeRange{
+		MinTimestamp: startTimestamp,
+		MaxTimestamp: startTimestamp + 100*1000,
+	}
+	// getSamples returns the number of found samples and the sum of their values.
+	getSamples := func(s *Storage) (int, float64) {
+		t.Helper()
+		var sr Search
+		var b Block
+		n := 0
+		sum := float64(0)
+		sr.Init(nil, s, tfss, tr, 1e5, noDeadline)
+		for sr.NextMetricBlock() {
+			sr.MetricBlockRef.BlockRef.MustReadBlock(&b)
+			if err := b.UnmarshalData(); err != nil {
+				t.Fatalf("cannot unmarshal block: %s", err)
+			}
+			timestamps, values := b.AppendRowsWithTimeRangeFilter(nil, nil, tr)
+			n += len(timestamps)
+			for _, v := range values {
+				sum += v
+			}
+		}
+		if err := sr.Error(); err != nil {
+			t.Fatalf("unexpected error in search: %s", err)
+		}
+		sr.MustClose()
+		return n, sum
+	}
+	checkSamples := func(s *Storage, nExpected int, sumExpected float64) {
+		t.Helper()
+		n, sum := getSamples(s)
+		if n != nExpected {
+			t.Fatalf("unexpected number of samples; got %d; want %d", n, nExpected)
+		}
+		if sum != sumExpected {
+			t.Fatalf("unexpected sum of samples; got %v; want %v", sum, sumExpected)
+		}
+	}
+
+	s := MustOpenStorage(t.Name(), 0, 0, 0)
+	s.AddRows(newRows(0, 100, 1), defaultPrecisionBits)
+	s.DebugFlush()
+
+	// Delete samples on [10s ... 19s] time range.
+	trDelete := TimeRange{
+		MinTimestamp: startTimestamp + 10*1000,
+		MaxTimestamp: startTimestamp + 19*1000,
+	}
+	if _, err := s.DeleteSeriesOnTimeRange(nil, tfss, trDelete, 1e5); err != nil {
+		t.Fatalf("cannot delete series on time range: %s", err)
+	}
+	checkSamples(s, 90, 90)
+
+	// Backfill samples on the deleted time range. They must be visible to search, since they are added after the deletion.
+	s.AddRows(newRows(10, 20, 2), defaultPrecisionBits)
+	s.DebugFlush()
+	checkSamples(s, 100, 110)
+
+	// The tombstone cannot be removed until the parts created before it are merged.
+	s.removeAppliedTombstones()
+	if n := len(s.getTombstones().items); n != 1 {
+		t.Fatalf("unexpected number of tombstones before the merge; got %d; want %d", n, 1)
+	}
+
+	// The backfilled samples must survive the merge, which removes the deleted samples.
+	if err := s.ForceMergePartitions(""); err != nil {
+		t.Fatalf("cannot force merge partitions: %s", err)
+	}
+	var m Metrics
+	s.UpdateMetrics(&m)
+	if rowsCount := m.TableMetrics.TotalRowsCount(); rowsCount != 100 {
+		t.Fatalf("unexpected number of rows after the merge; got %d; want %d", rowsCount, 100)
+	}
+	checkSamples(s, 100, 110)
+
+	// The tombstone must be removed after the merge.
+	s.removeAppliedTombstones()
+	if n := len(s.getTombstones().items); n != 0 {
+		t.Fatalf("unexpected number of tombstones after the merge; got %d; want %d", n, 0)
+	}
+	checkSamples(s, 100, 110)
+
+	// Samples added after the tombstone removal must be visible after restart.
+	s.MustClose()
+	s = MustOpenStorage(t.Name(), 0, 0, 0)
+	checkSamples(s, 100, 110)
+	s.MustClose()
+}